@@ -0,0 +1,206 @@
+// Package aikadba 提供在其他 Go 程式中嵌入 aika-dba 的程式化介面，
+// 不需透過命令列，也不依賴工作目錄下的 config.yaml 或 knowledge/ 目錄
+package aikadba
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/analyzer"
+	"github.com/masato25/aika-dba/pkg/llm"
+	"github.com/masato25/aika-dba/pkg/phases"
+	"github.com/masato25/aika-dba/pkg/vectorstore"
+)
+
+// Client aika-dba 的程式化入口，封裝資料庫連線與各 phase 執行器
+type Client struct {
+	config *config.Config
+	db     *sql.DB
+	ownsDB bool // 連線由 New 開啟，Close 時一併關閉
+
+	queryOnce   sync.Once
+	queryRunner *phases.MarketingQueryRunner
+}
+
+// options New 的設定
+type options struct {
+	db           *sql.DB
+	llm          *config.LLMConfig
+	embedder     vectorstore.Embedder
+	knowledgeDir string
+}
+
+// Option New 的功能選項
+type Option func(*options)
+
+// WithDB 使用既有的資料庫連線，而非依 cfg.Database 開啟新連線（Close 不會關閉此連線）
+func WithDB(db *sql.DB) Option {
+	return func(o *options) {
+		o.db = db
+	}
+}
+
+// WithLLM 以指定的 LLM 設定取代 cfg.LLM
+func WithLLM(llmConfig config.LLMConfig) Option {
+	return func(o *options) {
+		o.llm = &llmConfig
+	}
+}
+
+// WithEmbedder 使用自訂的嵌入生成器，取代 vectorstore.embedder_type 指定的類型（只影響此 Client）
+func WithEmbedder(embedder vectorstore.Embedder) Option {
+	return func(o *options) {
+		o.embedder = embedder
+	}
+}
+
+// WithKnowledgeDir 設定此 Client 的分析結果與知識檔案目錄（預設為 knowledge.dir 或工作目錄下的 knowledge/）
+// 使用相對路徑的 SQLite 向量資料庫也會移到此目錄下，不同目錄的 Client 互不影響
+func WithKnowledgeDir(dir string) Option {
+	return func(o *options) {
+		o.knowledgeDir = dir
+	}
+}
+
+// New 依配置創建 Client；cfg 會被複製，選項不會修改呼叫端的配置
+func New(cfg *config.Config, opts ...Option) (*Client, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config is required")
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	clientConfig := *cfg
+	if o.llm != nil {
+		clientConfig.LLM = *o.llm
+	}
+
+	if o.knowledgeDir != "" {
+		dir, err := filepath.Abs(o.knowledgeDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve knowledge directory: %w", err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create knowledge directory: %w", err)
+		}
+		clientConfig.Knowledge.Dir = dir
+		clientConfig.VectorStore.DatabasePath = vectorDatabasePath(dir, clientConfig.VectorStore.DatabasePath)
+	}
+
+	client := &Client{config: &clientConfig, db: o.db}
+	if client.db == nil {
+		db, err := sql.Open(clientConfig.GetDatabaseDriver(), clientConfig.GetDatabaseDSN())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		client.db = db
+		client.ownsDB = true
+	}
+
+	// 嵌入生成器綁定到此 Client 的配置，Close 時解除
+	if o.embedder != nil {
+		vectorstore.BindEmbedder(client.config, o.embedder)
+	}
+
+	return client, nil
+}
+
+// vectorDatabasePath 將相對路徑的 SQLite 向量資料庫放到知識目錄下，空白或絕對路徑維持不變
+func vectorDatabasePath(knowledgeDir, databasePath string) string {
+	if databasePath == "" || filepath.IsAbs(databasePath) {
+		return databasePath
+	}
+	return filepath.Join(knowledgeDir, databasePath)
+}
+
+// Config 返回 Client 使用的配置（已套用選項）
+func (c *Client) Config() *config.Config {
+	return c.config
+}
+
+// RunPhase1 執行 Phase 1 統計分析
+func (c *Client) RunPhase1() error {
+	dbAnalyzer := analyzer.NewDatabaseAnalyzerWithSchemas(c.db, c.config.Database.Type, c.config.GetDatabaseSchemas()).WithCollapsePartitions(c.config.Schema.CollapsePartitions)
+	runner, err := phases.NewPhase1Runner(dbAnalyzer, c.config)
+	if err != nil {
+		return fmt.Errorf("failed to create Phase 1 runner: %w", err)
+	}
+	defer runner.Close()
+	return runner.Run()
+}
+
+// RunPhase2 執行 Phase 2 AI 分析（需要 Phase 1 的結果）
+func (c *Client) RunPhase2() error {
+	runner, err := phases.NewPhase2Runner(c.config, c.db, false)
+	if err != nil {
+		return fmt.Errorf("failed to create Phase 2 runner: %w", err)
+	}
+	defer runner.Close()
+	return runner.Run()
+}
+
+// RunPhase3 執行 Phase 3 商業邏輯描述生成（需要 Phase 2 的結果）
+func (c *Client) RunPhase3(ctx context.Context) error {
+	knowledgeMgr, err := vectorstore.NewKnowledgeManager(c.config)
+	if err != nil {
+		return fmt.Errorf("failed to create knowledge manager: %w", err)
+	}
+	defer knowledgeMgr.Close()
+
+	return phases.NewPhase3Runner(c.config, llm.NewClient(c.config), knowledgeMgr).Run(ctx)
+}
+
+// Prepare 依序執行 Phase 1、2、3，建立查詢所需的知識庫
+func (c *Client) Prepare(ctx context.Context) error {
+	if err := c.RunPhase1(); err != nil {
+		return fmt.Errorf("phase1: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.RunPhase2(); err != nil {
+		return fmt.Errorf("phase2: %w", err)
+	}
+	if err := c.RunPhase3(ctx); err != nil {
+		return fmt.Errorf("phase3: %w", err)
+	}
+	return nil
+}
+
+// Query 以自然語言提問，返回產生的 SQL 與查詢結果
+func (c *Client) Query(question string) (*phases.MarketingQueryResult, error) {
+	c.queryOnce.Do(func() {
+		c.queryRunner = phases.NewMarketingQueryRunner(c.config, c.db)
+	})
+	return c.queryRunner.ExecuteMarketingQuery(question)
+}
+
+// Close 關閉查詢執行器、解除自訂嵌入生成器的綁定，並關閉由 New 開啟的資料庫連線
+func (c *Client) Close() error {
+	var errs []error
+	if c.queryRunner != nil {
+		if err := c.queryRunner.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close query runner: %w", err))
+		}
+	}
+	vectorstore.UnbindEmbedder(c.config)
+	if c.ownsDB {
+		if err := c.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close database: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
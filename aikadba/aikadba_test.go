@@ -0,0 +1,124 @@
+package aikadba
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/vectorstore"
+)
+
+// countingEmbedder 計算被呼叫次數的固定維度嵌入生成器
+type countingEmbedder struct {
+	calls int64
+}
+
+func (e *countingEmbedder) GenerateEmbedding(text string) ([]float64, error) {
+	atomic.AddInt64(&e.calls, 1)
+	return []float64{1, 0, 0, 0}, nil
+}
+
+func (e *countingEmbedder) GenerateEmbeddings(texts []string) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i], _ = e.GenerateEmbedding(text)
+	}
+	return vectors, nil
+}
+
+func newTestClient(t *testing.T, cfg *config.Config, opts ...Option) *Client {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	client, err := New(cfg, append(opts, WithDB(db))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestClientsKeepSeparateKnowledgeDirs(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.VectorStore.Backend = "sqlite"
+	cfg.VectorStore.DatabasePath = "data/knowledge_vector.db"
+	cfg.VectorStore.EmbeddingDimension = 4
+
+	dirA, dirB := t.TempDir(), t.TempDir()
+	clientA := newTestClient(t, cfg, WithKnowledgeDir(dirA))
+	defer clientA.Close()
+	clientB := newTestClient(t, cfg, WithKnowledgeDir(dirB))
+	defer clientB.Close()
+
+	tests := []struct {
+		client *Client
+		dir    string
+	}{
+		{clientA, dirA},
+		{clientB, dirB},
+	}
+	for _, tt := range tests {
+		if got, want := tt.client.Config().KnowledgePath("phase1_analysis.json"), filepath.Join(tt.dir, "phase1_analysis.json"); got != want {
+			t.Errorf("KnowledgePath() = %q, want %q", got, want)
+		}
+		if got, want := tt.client.Config().VectorStore.DatabasePath, filepath.Join(tt.dir, "data", "knowledge_vector.db"); got != want {
+			t.Errorf("vector database path = %q, want %q", got, want)
+		}
+	}
+
+	if got := config.KnowledgeDir(); got != config.DefaultKnowledgeDir {
+		t.Errorf("process knowledge dir changed to %q", got)
+	}
+	if cfg.Knowledge.Dir != "" || cfg.VectorStore.DatabasePath != "data/knowledge_vector.db" {
+		t.Errorf("caller config mutated: %+v", cfg.Knowledge)
+	}
+}
+
+func TestClientEmbedderIsPerClient(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.VectorStore.Backend = "memory"
+	cfg.VectorStore.EmbeddingDimension = 4
+	cfg.VectorStore.ChunkSize = 200
+	cfg.VectorStore.ChunkOverlap = 20
+
+	embedder := &countingEmbedder{}
+	custom := newTestClient(t, cfg, WithEmbedder(embedder), WithKnowledgeDir(t.TempDir()))
+	plain := newTestClient(t, cfg, WithKnowledgeDir(t.TempDir()))
+	defer plain.Close()
+
+	store := func(client *Client) {
+		t.Helper()
+		km, err := vectorstore.NewKnowledgeManager(client.Config())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer km.Close()
+		if err := km.StorePhaseKnowledge("phase1", map[string]interface{}{"tables": "customers orders"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store(plain)
+	if got := atomic.LoadInt64(&embedder.calls); got != 0 {
+		t.Fatalf("custom embedder used by another client (%d calls)", got)
+	}
+
+	store(custom)
+	if atomic.LoadInt64(&embedder.calls) == 0 {
+		t.Fatal("custom embedder not used by its client")
+	}
+
+	if err := custom.Close(); err != nil {
+		t.Fatal(err)
+	}
+	calls := atomic.LoadInt64(&embedder.calls)
+	store(custom)
+	if got := atomic.LoadInt64(&embedder.calls); got != calls {
+		t.Errorf("custom embedder still bound after Close (%d new calls)", got-calls)
+	}
+}
@@ -3,13 +3,16 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/masato25/aika-dba/config"
 	"github.com/masato25/aika-dba/pkg/analyzer"
@@ -26,7 +29,7 @@ func runServer(db *sql.DB, cfg *config.Config) {
 
 // runPhase1 執行 Phase 1: 統計分析
 func runPhase1(db *sql.DB, cfg *config.Config) {
-	analyzer := analyzer.NewDatabaseAnalyzer(db)
+	analyzer := analyzer.NewDatabaseAnalyzerWithSchemas(db, cfg.Database.Type, cfg.GetDatabaseSchemas()).WithCollapsePartitions(cfg.Schema.CollapsePartitions)
 	runner, err := phases.NewPhase1Runner(analyzer, cfg)
 	if err != nil {
 		log.Fatalf("Failed to create Phase 1 runner: %v", err)
@@ -61,9 +64,9 @@ func runPhase1Put(cfg *config.Config) {
 	}
 }
 
-// runPhase2 執行 Phase 2: AI 分析
-func runPhase2(db *sql.DB, cfg *config.Config) {
-	runner, err := phases.NewPhase2Runner(cfg, db)
+// runPhase2 執行 Phase 2: AI 分析，resume 時略過上次中斷前已完成的表格
+func runPhase2(db *sql.DB, cfg *config.Config, resume bool) {
+	runner, err := phases.NewPhase2Runner(cfg, db, resume)
 	if err != nil {
 		log.Fatalf("Failed to create Phase 2 runner: %v", err)
 	}
@@ -111,8 +114,28 @@ func runPhase3(cfg *config.Config) {
 	}
 }
 
+// runPhase5 執行 Phase 5: 由 Phase 4 維度建模結果產生星形模式 DDL
+func runPhase5(cfg *config.Config, dialect string) {
+	knowledgeMgr, err := vectorstore.NewKnowledgeManager(cfg)
+	if err != nil {
+		log.Printf("Warning: Failed to create knowledge manager, DDL will not be stored in vector store: %v", err)
+		knowledgeMgr = nil
+	} else {
+		defer knowledgeMgr.Close()
+	}
+
+	runner, err := phases.NewPhase5Runner(cfg, knowledgeMgr, dialect)
+	if err != nil {
+		log.Fatalf("Failed to create Phase 5 runner: %v", err)
+	}
+
+	if err := runner.Run(); err != nil {
+		log.Fatalf("Phase 5 failed: %v", err)
+	}
+}
+
 // runMarketingQuery 執行營銷查詢
-func runMarketingQuery(db *sql.DB, cfg *config.Config, query string) {
+func runMarketingQuery(db *sql.DB, cfg *config.Config, query, outputPath string, jsonOutput bool) {
 	if query == "" {
 		log.Fatalf("Query parameter is required for marketing command. Use -query flag.")
 	}
@@ -126,7 +149,34 @@ func runMarketingQuery(db *sql.DB, cfg *config.Config, query string) {
 		log.Fatalf("Marketing query failed: %v", err)
 	}
 
-	// 輸出結果
+	// JSON 輸出與 POST /api/marketing/query 的回應格式相同
+	if jsonOutput {
+		printMarketingResultJSON(result)
+	} else {
+		printMarketingResult(cfg, result)
+	}
+	// preview 模式沒有執行 SQL，不匯出也不保存
+	if result.Error != "" || result.Mode == phases.MarketingModePreview {
+		return
+	}
+
+	// 匯出完整結果
+	if outputPath != "" {
+		if err := exportFullResult(runner, result.SQLQuery, outputPath); err != nil {
+			log.Printf("Warning: Failed to export full result: %v", err)
+		}
+	}
+
+	// 保存查詢結果
+	if err := runner.SaveQueryResult(result); err != nil {
+		log.Printf("Warning: Failed to save query result: %v", err)
+	} else {
+		log.Println("Query result saved to vector store")
+	}
+}
+
+// printMarketingResult 以文字格式輸出營銷查詢結果
+func printMarketingResult(cfg *config.Config, result *phases.MarketingQueryResult) {
 	fmt.Println("\n=== Marketing Query Results ===")
 	fmt.Printf("Query: %s\n", result.Query)
 	fmt.Printf("Timestamp: %s\n", result.Timestamp.Format("2006-01-02 15:04:05"))
@@ -138,13 +188,36 @@ func runMarketingQuery(db *sql.DB, cfg *config.Config, query string) {
 
 	fmt.Printf("SQL Query: %s\n", result.SQLQuery)
 	fmt.Printf("Explanation: %s\n", result.Explanation)
-	fmt.Printf("Results: %d rows\n", len(result.Results))
+	if result.Mode == phases.MarketingModePreview {
+		for _, warning := range result.Warnings {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+		fmt.Println("Preview mode: SQL was not executed")
+		return
+	}
+	// 顯示行數限制
+	displayLimit := cfg.Marketing.DisplayLimit
+	if displayLimit <= 0 {
+		displayLimit = 5
+	}
+	shown := len(result.Results)
+	if shown > displayLimit {
+		shown = displayLimit
+	}
+	if result.Truncated && result.TotalRows == 0 {
+		// 總行數無法取得
+		fmt.Printf("Results: more than %d rows (showing %d)\n", len(result.Results), shown)
+	} else {
+		fmt.Printf("Results: %d rows (showing %d)\n", result.TotalRows, shown)
+	}
+	if result.Truncated {
+		fmt.Printf("Note: only the first %d rows were returned. Use -output to export the full result.\n", len(result.Results))
+	}
 
 	if len(result.Results) > 0 {
 		fmt.Println("\nSample Results:")
-		// 顯示前 5 行結果
 		for i, row := range result.Results {
-			if i >= 5 {
+			if i >= displayLimit {
 				break
 			}
 			fmt.Printf("Row %d: ", i+1)
@@ -159,12 +232,110 @@ func runMarketingQuery(db *sql.DB, cfg *config.Config, query string) {
 		fmt.Println("\nBusiness Insights:")
 		fmt.Println(result.BusinessInsights)
 	}
+}
 
-	// 保存查詢結果
-	if err := runner.SaveQueryResult(result); err != nil {
-		log.Printf("Warning: Failed to save query result: %v", err)
+// printMarketingResultJSON 以 JSON 輸出營銷查詢結果
+func printMarketingResultJSON(result *phases.MarketingQueryResult) {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode marketing query result: %v", err)
+	}
+	fmt.Println(string(data))
+}
+
+// exportFullResult 重新執行查詢並將結果寫入 CSV 檔案（最多 marketing.download_row_limit 行）
+func exportFullResult(runner *phases.MarketingQueryRunner, sqlQuery, outputPath string) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer file.Close()
+
+	rows, truncated, err := runner.ExportResultsCSV(context.Background(), file, sqlQuery)
+	if err != nil {
+		return err
+	}
+
+	if truncated {
+		log.Printf("Warning: Export truncated to %d rows (marketing.download_row_limit)", rows)
+	}
+	log.Printf("Full result (%d rows) exported to %s", rows, outputPath)
+	return nil
+}
+
+// runCorrectTable 提交 Phase 2 表格分析的人工修正
+func runCorrectTable(cfg *config.Config, tableName, correctionText, author string) {
+	knowledgeMgr, err := vectorstore.NewKnowledgeManager(cfg)
+	if err != nil {
+		log.Printf("Warning: Failed to create knowledge manager: %v", err)
+		knowledgeMgr = nil
 	} else {
-		log.Println("Query result saved to vector store")
+		defer knowledgeMgr.Close()
+	}
+
+	correction := &phases.TableCorrection{
+		TableName: tableName,
+		Analysis:  correctionText,
+		Author:    author,
+	}
+	if err := phases.SubmitTableCorrection(cfg, knowledgeMgr, correction); err != nil {
+		log.Fatalf("Failed to submit correction: %v", err)
+	}
+}
+
+// runReport 產生整合 HTML 報告及 Markdown 分析文件
+func runReport(cfg *config.Config) {
+	generator := phases.NewReportGenerator(cfg.KnowledgeDir())
+	if _, err := generator.WriteReport(phases.ReportPath(cfg)); err != nil {
+		log.Fatalf("Report generation failed: %v", err)
+	}
+	if _, err := generator.WriteMarkdown(phases.MarkdownPath(cfg)); err != nil {
+		log.Fatalf("Markdown export failed: %v", err)
+	}
+}
+
+// runSchemaDiff 比較兩份 phase1_analysis.json 並輸出 schema 漂移，破壞性變更與新增性變更分開列出
+func runSchemaDiff(cfg *config.Config, args []string, jsonOutput bool) {
+	if len(args) != 2 {
+		log.Fatalf("Usage: -command diff <old.json> <new.json>")
+	}
+
+	report, err := phases.SchemaDiff(cfg, args[0], args[1])
+	if err != nil {
+		log.Fatalf("Schema diff failed: %v", err)
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode schema diff: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("Schema diff: %s -> %s\n", report.From, report.To)
+	if !report.HasChanges() {
+		fmt.Println("No changes")
+		return
+	}
+	printSchemaChanges("Breaking changes", report.Breaking)
+	printSchemaChanges("Additive changes", report.Additive)
+}
+
+// printSchemaChanges 逐行列出 schema 變更
+func printSchemaChanges(title string, changes []phases.SchemaChange) {
+	fmt.Printf("\n%s (%d):\n", title, len(changes))
+	for _, change := range changes {
+		target := change.Table
+		if change.Column != "" {
+			target += "." + change.Column
+		}
+		line := fmt.Sprintf("  %-18s %s", change.Kind, target)
+		if change.From != "" || change.To != "" {
+			line += fmt.Sprintf(" (%s -> %s)", change.From, change.To)
+		}
+		fmt.Println(line)
 	}
 }
 
@@ -204,12 +375,149 @@ func runDeleteVectorData(cfg *config.Config, phasesStr string) {
 	}
 }
 
+// runRechunk 以目前的分塊設定重新分塊並替換指定 phase 的向量知識
+func runRechunk(cfg *config.Config, phasesStr string) {
+	log.Printf("Starting rechunk for phases: %s", phasesStr)
+
+	// 創建知識管理器
+	knowledgeMgr, err := vectorstore.NewKnowledgeManager(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create knowledge manager: %v", err)
+	}
+	defer knowledgeMgr.Close()
+
+	failed := 0
+	for _, phase := range strings.Split(phasesStr, ",") {
+		phase = strings.TrimSpace(phase)
+		if phase == "" {
+			continue
+		}
+
+		count, err := knowledgeMgr.RechunkPhase(phase)
+		if err != nil {
+			log.Printf("Warning: Failed to rechunk phase %s: %v", phase, err)
+			failed++
+			continue
+		}
+		log.Printf("Phase %s rechunked into %d chunks", phase, count)
+	}
+
+	if failed > 0 {
+		log.Fatalf("Rechunk failed for %d phase(s)", failed)
+	}
+	log.Println("Rechunk completed")
+}
+
+// runExportBundle 將 phase 結果與向量知識打包為單一 zip 檔
+func runExportBundle(cfg *config.Config, bundlePath string) {
+	if bundlePath == "" {
+		log.Fatalf("export-bundle requires a bundle path (e.g. -command export-bundle knowledge.zip)")
+	}
+
+	knowledgeMgr, err := vectorstore.NewKnowledgeManager(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create knowledge manager: %v", err)
+	}
+	defer knowledgeMgr.Close()
+
+	if err := knowledgeMgr.ExportBundle(bundlePath); err != nil {
+		log.Fatalf("Failed to export bundle: %v", err)
+	}
+	log.Printf("Knowledge bundle exported to %s", bundlePath)
+}
+
+// bundlePathArg 知識包路徑：優先使用位置參數，其次為 -output
+func bundlePathArg(args []string, output string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return output
+}
+
+// runImportBundle 匯入 export-bundle 產生的知識包，嵌入設定不同時重新生成向量
+func runImportBundle(cfg *config.Config, bundlePath string) {
+	if bundlePath == "" {
+		log.Fatalf("import-bundle requires a bundle path (e.g. -command import-bundle knowledge.zip)")
+	}
+
+	knowledgeMgr, err := vectorstore.NewKnowledgeManager(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create knowledge manager: %v", err)
+	}
+	defer knowledgeMgr.Close()
+
+	manifest, err := knowledgeMgr.ImportBundle(bundlePath)
+	if err != nil {
+		log.Fatalf("Failed to import bundle: %v", err)
+	}
+	log.Printf("Knowledge bundle imported (database: %s, exported %s, %d chunks)",
+		manifest.Database, manifest.CreatedAt.Format("2006-01-02 15:04:05"), manifest.TotalChunks)
+}
+
+// runPlan 輸出 phase 的範圍預覽（JSON），不執行分析
+func runPlan(db *sql.DB, cfg *config.Config, phase string) {
+	knowledgeMgr, err := vectorstore.NewKnowledgeManager(cfg)
+	if err != nil {
+		log.Printf("Warning: Failed to create knowledge manager: %v", err)
+		knowledgeMgr = nil
+	} else {
+		defer knowledgeMgr.Close()
+	}
+
+	plan, err := phases.PlanPhase(cfg, analyzer.NewDatabaseAnalyzerWithSchemas(db, cfg.Database.Type, cfg.GetDatabaseSchemas()).WithCollapsePartitions(cfg.Schema.CollapsePartitions), knowledgeMgr, phase)
+	if err != nil {
+		log.Fatalf("Failed to plan %s: %v", phase, err)
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode plan: %v", err)
+	}
+	fmt.Println(string(data))
+}
+
+// runValidateRules 驗證 Phase 4 使用的 Lua 規則可正常載入與呼叫，不執行完整 Phase
+func runValidateRules(cfg *config.Config) {
+	knowledgeMgr, err := vectorstore.NewKnowledgeManager(cfg)
+	if err != nil {
+		log.Printf("Warning: Failed to create knowledge manager, validating default rules file only: %v", err)
+		knowledgeMgr = nil
+	} else {
+		defer knowledgeMgr.Close()
+	}
+
+	result := phases.ValidatePhase4Rules(cfg, knowledgeMgr)
+	log.Printf("Lua rules source: %s", result.Source)
+	if result.LoadError != "" {
+		log.Fatalf("Lua rules failed to load: %s", result.LoadError)
+	}
+	for _, fn := range result.Functions {
+		if fn.Error != "" {
+			log.Printf("  %s: FAILED (%s)", fn.Name, fn.Error)
+		} else {
+			log.Printf("  %s: OK", fn.Name)
+		}
+	}
+	if !result.Valid {
+		log.Fatalf("Lua rules validation failed")
+	}
+	log.Println("Lua rules validation passed")
+}
+
 func main() {
 	// 命令行參數
-	var command = flag.String("command", "server", "Command to run: server, phase1, phase1_post, phase1_put, phase2, phase2_prefix, phase3, marketing, delete-vector")
+	var command = flag.String("command", "server", "Command to run: server, phase1, phase1_post, phase1_put, phase2, phase2_prefix, phase3, phase5, marketing, correct, report, diff, delete-vector, rechunk, validate-rules, export-bundle, import-bundle")
 	var configPath = flag.String("config", "config.yaml", "Path to config file")
-	var phases = flag.String("phases", "phase3", "Comma-separated list of phases to delete (for delete-vector command)")
-	var query = flag.String("query", "", "Natural language query for marketing command")
+	var phases = flag.String("phases", "phase3", "Comma-separated list of phases (for delete-vector and rechunk commands)")
+	var query = flag.String("query", "", "Natural language query for marketing command (prefix A:, Q: or P: for analysis, raw or preview mode; preview does not execute the SQL)")
+	var output = flag.String("output", "", "Path to export the full marketing query result as CSV (also the bundle path for export-bundle/import-bundle)")
+	var jsonOutput = flag.Bool("json", false, "Print the marketing query result as JSON (same schema as POST /api/marketing/query); also used by diff")
+	var table = flag.String("table", "", "Table name for correct command")
+	var correction = flag.String("correction", "", "Corrected table description for correct command")
+	var author = flag.String("author", "", "Author of the correction (for correct command)")
+	var dialect = flag.String("dialect", "", "DDL dialect for phase5 command: postgres or mysql (default: database.type)")
+	var plan = flag.Bool("plan", false, "Preview what phase1, phase2 or phase4 will process without running it")
+	var resume = flag.Bool("resume", false, "Resume phase2 from knowledge/phase2_partial.json, skipping tables already analyzed")
 	flag.Parse()
 
 	// 載入配置
@@ -218,9 +526,13 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	if cfg.App.SafeMode {
+		log.Println("Safe mode enabled: database connection is read-only and data-modifying features are disabled")
+	}
+
 	// 建立資料庫連接
 	log.Println("DEBUG: Opening database connection...")
-	db, err := sql.Open(cfg.Database.Type, cfg.GetDatabaseDSN())
+	db, err := sql.Open(cfg.GetDatabaseDriver(), cfg.GetDatabaseDSN())
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -238,6 +550,12 @@ func main() {
 
 	log.Printf("Connected to %s database at %s:%d", cfg.Database.Type, cfg.Database.Host, cfg.Database.Port)
 
+	// 只預覽範圍，不執行 phase
+	if *plan {
+		runPlan(db, cfg, *command)
+		return
+	}
+
 	// 根據命令執行不同的操作
 	switch *command {
 	case "server":
@@ -249,16 +567,38 @@ func main() {
 	case "phase1_put":
 		runPhase1Put(cfg)
 	case "phase2":
-		runPhase2(db, cfg)
+		runPhase2(db, cfg, *resume)
 	case "phase2_prefix":
 		runPhase2Prefix(cfg)
 	case "phase3":
 		runPhase3(cfg)
+	case "phase5":
+		runPhase5(cfg, *dialect)
 	case "marketing":
-		runMarketingQuery(db, cfg, *query)
+		runMarketingQuery(db, cfg, *query, *output, *jsonOutput)
+	case "correct":
+		runCorrectTable(cfg, *table, *correction, *author)
+	case "report":
+		runReport(cfg)
+	case "diff":
+		runSchemaDiff(cfg, flag.Args(), *jsonOutput)
 	case "delete-vector":
 		runDeleteVectorData(cfg, *phases)
+	case "rechunk":
+		runRechunk(cfg, *phases)
+	case "validate-rules":
+		runValidateRules(cfg)
+	case "export-bundle":
+		runExportBundle(cfg, bundlePathArg(flag.Args(), *output))
+	case "import-bundle":
+		runImportBundle(cfg, bundlePathArg(flag.Args(), *output))
 	default:
-		log.Fatalf("Unknown command: %s. Available commands: server, phase1, phase1_post, phase1_put, phase2, phase2_prefix, phase3, marketing, delete-vector", *command)
+		log.Fatalf("Unknown command: %s. Available commands: server, phase1, phase1_post, phase1_put, phase2, phase2_prefix, phase3, phase5, marketing, correct, report, diff, delete-vector, rechunk, validate-rules, export-bundle, import-bundle", *command)
+	}
+
+	// 顯示本次執行的 LLM token 用量總計
+	if usage := llm.TotalUsage(cfg); usage.Requests > 0 {
+		log.Printf("LLM usage total: %d requests, %d prompt + %d completion tokens, estimated cost %.4f",
+			usage.Requests, usage.PromptTokens, usage.CompletionTokens, usage.EstimatedCost)
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/masato25/aika-dba/config"
 	"github.com/masato25/aika-dba/pkg/mcp"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
@@ -23,7 +24,7 @@ func main() {
 	}
 
 	// 建立資料庫連接
-	db, err := sql.Open(cfg.Database.Type, cfg.GetDatabaseDSN())
+	db, err := sql.Open(cfg.GetDatabaseDriver(), cfg.GetDatabaseDSN())
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// databaseURLTypes URL scheme 對應的資料庫類型
+var databaseURLTypes = map[string]string{
+	"postgres":   "postgres",
+	"postgresql": "postgres",
+	"mysql":      "mysql",
+	"file":       "sqlite",
+}
+
+// applyDatabaseURL 驗證 database.url 的 scheme 與 database.type 一致（未設定 type 時依 scheme 推斷），
+// 並以 URL 中的主機、端口、使用者與資料庫名稱覆寫分散欄位，供日誌與輸出使用
+func (d *DatabaseConfig) applyDatabaseURL() error {
+	if d.URL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(d.URL)
+	if err != nil {
+		return fmt.Errorf("invalid database.url: %w", err)
+	}
+
+	urlType, ok := databaseURLTypes[strings.ToLower(u.Scheme)]
+	if !ok {
+		return fmt.Errorf("unsupported database.url scheme %q (supported: postgres, postgresql, mysql, file)", u.Scheme)
+	}
+	dbType := d.Type
+	if dbType == "sqlite3" {
+		dbType = "sqlite"
+	}
+	if dbType == "" {
+		d.Type = urlType
+	} else if dbType != urlType {
+		return fmt.Errorf("database.url scheme %q does not match database.type %q", u.Scheme, d.Type)
+	}
+
+	// SQLite 的 file: URL 只有路徑
+	if urlType == "sqlite" {
+		d.DBName = u.Opaque
+		if d.DBName == "" {
+			d.DBName = u.Path
+		}
+		return nil
+	}
+
+	d.Host = u.Hostname()
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		d.Port = port
+	}
+	if u.User != nil {
+		d.User = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			d.Password = password
+		}
+	}
+	d.DBName = strings.TrimPrefix(u.Path, "/")
+	return nil
+}
+
+// urlDSN 由 database.url 產生連接字串，保留 URL 中的所有查詢參數（如 sslmode、connect_timeout）
+// PostgreSQL 與 SQLite 直接使用 URL；MySQL 驅動不接受 URL，轉換為 user:pass@tcp(host:port)/db?params
+// 安全模式下附加唯讀參數，URL 已設定相同參數（例如 mode=rw）時改寫為唯讀值
+func (c *Config) urlDSN() string {
+	dsn := c.Database.URL
+	readOnlyParam := ""
+
+	switch c.Database.Type {
+	case "postgres":
+		readOnlyParam = "default_transaction_read_only=on"
+	case "mysql":
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return ""
+		}
+		userinfo := ""
+		if u.User != nil {
+			userinfo = u.User.Username()
+			if password, ok := u.User.Password(); ok {
+				userinfo += ":" + password
+			}
+			userinfo += "@"
+		}
+		dsn = fmt.Sprintf("%stcp(%s)/%s", userinfo, u.Host, strings.TrimPrefix(u.Path, "/"))
+		if u.RawQuery != "" {
+			dsn += "?" + u.RawQuery
+		}
+		readOnlyParam = "transaction_read_only=1"
+	case "sqlite", "sqlite3":
+		readOnlyParam = "mode=ro"
+	}
+
+	if c.App.SafeMode && readOnlyParam != "" {
+		dsn = setDSNParam(dsn, readOnlyParam)
+	}
+	return dsn
+}
+
+// setDSNParam 在連接字串的查詢參數中設定 key=value，已有同名參數時取代其值（只保留一個）
+func setDSNParam(dsn, param string) string {
+	key := param[:strings.Index(param, "=")+1]
+	i := strings.Index(dsn, "?")
+	if i < 0 {
+		return dsn + "?" + param
+	}
+
+	var params []string
+	for _, existing := range strings.Split(dsn[i+1:], "&") {
+		if existing != "" && !strings.HasPrefix(existing, key) {
+			params = append(params, existing)
+		}
+	}
+	return dsn[:i+1] + strings.Join(append(params, param), "&")
+}
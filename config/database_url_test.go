@@ -0,0 +1,34 @@
+package config
+
+import "testing"
+
+func TestURLDSNSafeModeForcesReadOnly(t *testing.T) {
+	tests := []struct {
+		name   string
+		dbType string
+		url    string
+		want   string
+	}{
+		{"postgres adds param", "postgres", "postgres://u:p@db:5432/shop?sslmode=disable",
+			"postgres://u:p@db:5432/shop?sslmode=disable&default_transaction_read_only=on"},
+		{"postgres overrides off", "postgres", "postgres://u:p@db:5432/shop?default_transaction_read_only=off&sslmode=disable",
+			"postgres://u:p@db:5432/shop?sslmode=disable&default_transaction_read_only=on"},
+		{"mysql overrides 0", "mysql", "mysql://u:p@db:3306/shop?transaction_read_only=0",
+			"u:p@tcp(db:3306)/shop?transaction_read_only=1"},
+		{"sqlite overrides rw", "sqlite", "file:shop.db?mode=rw&cache=shared",
+			"file:shop.db?cache=shared&mode=ro"},
+		{"sqlite without params", "sqlite", "file:shop.db", "file:shop.db?mode=ro"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Database.Type = tt.dbType
+			cfg.Database.URL = tt.url
+			cfg.App.SafeMode = true
+			if got := cfg.GetDatabaseDSN(); got != tt.want {
+				t.Errorf("GetDatabaseDSN() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import "fmt"
+
+// LLMParams 單一 phase 或操作的 LLM 取樣參數；未設定的欄位使用呼叫端的預設值
+type LLMParams struct {
+	Temperature *float64 `yaml:"temperature"` // 0 到 2
+	MaxTokens   int      `yaml:"max_tokens"`  // <= 0 時使用預設值
+	TopP        *float64 `yaml:"top_p"`       // 大於 0 且不超過 1
+}
+
+// ParamsFor 返回 phase 的取樣參數：llm.phase_params 中有設定的欄位覆蓋 defaults
+func (l LLMConfig) ParamsFor(phase string, defaults LLMParams) LLMParams {
+	params := defaults
+	configured, ok := l.PhaseParams[phase]
+	if !ok {
+		return params
+	}
+	if configured.Temperature != nil {
+		params.Temperature = configured.Temperature
+	}
+	if configured.MaxTokens > 0 {
+		params.MaxTokens = configured.MaxTokens
+	}
+	if configured.TopP != nil {
+		params.TopP = configured.TopP
+	}
+	return params
+}
+
+// validatePhaseParams 檢查 llm.phase_params 的數值範圍
+func (l LLMConfig) validatePhaseParams() error {
+	for phase, params := range l.PhaseParams {
+		if params.Temperature != nil && (*params.Temperature < 0 || *params.Temperature > 2) {
+			return fmt.Errorf("phase_params.%s.temperature must be between 0 and 2, got %g", phase, *params.Temperature)
+		}
+		if params.TopP != nil && (*params.TopP <= 0 || *params.TopP > 1) {
+			return fmt.Errorf("phase_params.%s.top_p must be greater than 0 and at most 1, got %g", phase, *params.TopP)
+		}
+		if params.MaxTokens < 0 {
+			return fmt.Errorf("phase_params.%s.max_tokens must not be negative, got %d", phase, params.MaxTokens)
+		}
+	}
+	return nil
+}
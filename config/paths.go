@@ -0,0 +1,51 @@
+package config
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// DefaultKnowledgeDir 預設的知識目錄（相對於工作目錄）
+const DefaultKnowledgeDir = "knowledge"
+
+var (
+	knowledgeDirMu sync.RWMutex
+	knowledgeDir   = DefaultKnowledgeDir
+)
+
+// SetKnowledgeDir 設定程序預設的知識目錄，空字串恢復預設值
+// 此設定為整個程序共用；配置設定 knowledge.dir 時以該配置的目錄為準
+func SetKnowledgeDir(dir string) {
+	if dir == "" {
+		dir = DefaultKnowledgeDir
+	}
+
+	knowledgeDirMu.Lock()
+	defer knowledgeDirMu.Unlock()
+	knowledgeDir = dir
+}
+
+// KnowledgeDir 返回程序預設的知識目錄
+func KnowledgeDir() string {
+	knowledgeDirMu.RLock()
+	defer knowledgeDirMu.RUnlock()
+	return knowledgeDir
+}
+
+// KnowledgePath 返回程序預設知識目錄下的檔案路徑
+func KnowledgePath(elem ...string) string {
+	return filepath.Join(append([]string{KnowledgeDir()}, elem...)...)
+}
+
+// KnowledgeDir 返回此配置的知識目錄：設定 knowledge.dir 時使用該目錄，否則為程序預設的知識目錄
+func (c *Config) KnowledgeDir() string {
+	if c != nil && c.Knowledge.Dir != "" {
+		return c.Knowledge.Dir
+	}
+	return KnowledgeDir()
+}
+
+// KnowledgePath 返回此配置的知識目錄下的檔案路徑
+func (c *Config) KnowledgePath(elem ...string) string {
+	return filepath.Join(append([]string{c.KnowledgeDir()}, elem...)...)
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// TableAllowed 判斷表格是否通過 schema.include_patterns / exclude_patterns 過濾
+// 模式為不分大小寫的 glob（如 *_log、schema_*）；帶 schema 前綴的名稱（sales.orders）同時比對完整名稱與表格部分
+// 設定 include_patterns 時只保留符合任一模式的表格，exclude_patterns 優先於 include_patterns
+func (s SchemaConfig) TableAllowed(tableName string) bool {
+	if s.TableExcluded(tableName) {
+		return false
+	}
+	return len(s.IncludePatterns) == 0 || matchesTablePattern(s.IncludePatterns, tableName)
+}
+
+// TableExcluded 判斷名稱是否符合 schema.exclude_patterns（不考慮 include_patterns）
+func (s SchemaConfig) TableExcluded(tableName string) bool {
+	return matchesTablePattern(s.ExcludePatterns, tableName)
+}
+
+// FilterTables 返回通過過濾的表格，保留原有順序
+func (s SchemaConfig) FilterTables(tables []string) []string {
+	if len(s.IncludePatterns) == 0 && len(s.ExcludePatterns) == 0 {
+		return tables
+	}
+
+	filtered := make([]string, 0, len(tables))
+	for _, tableName := range tables {
+		if s.TableAllowed(tableName) {
+			filtered = append(filtered, tableName)
+		}
+	}
+	return filtered
+}
+
+// validateTablePatterns 檢查表格過濾模式是否為合法的 glob
+func (s SchemaConfig) validateTablePatterns() error {
+	for _, patterns := range [][]string{s.IncludePatterns, s.ExcludePatterns} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+				return fmt.Errorf("invalid table pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matchesTablePattern 表格名稱（或去除 schema 前綴後的名稱）符合任一模式時返回 true
+func matchesTablePattern(patterns []string, tableName string) bool {
+	name := strings.ToLower(tableName)
+	candidates := []string{name}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		candidates = append(candidates, name[i+1:])
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		for _, candidate := range candidates {
+			if matched, _ := path.Match(pattern, candidate); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
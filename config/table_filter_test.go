@@ -0,0 +1,72 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTableAllowed(t *testing.T) {
+	exclude := SchemaConfig{ExcludePatterns: []string{"*_log", "schema_*"}}
+	includeAndExclude := SchemaConfig{IncludePatterns: []string{"sales_*", "schema_*"}, ExcludePatterns: []string{"*_log", "schema_*"}}
+
+	tests := []struct {
+		name   string
+		schema SchemaConfig
+		table  string
+		want   bool
+	}{
+		{"suffix pattern matches", exclude, "audit_log", false},
+		{"suffix pattern is case insensitive", exclude, "Audit_LOG", false},
+		{"suffix pattern matches schema qualified name", exclude, "public.audit_log", false},
+		{"prefix pattern matches", exclude, "schema_migrations", false},
+		{"prefix pattern matches schema qualified name", exclude, "app.schema_versions", false},
+		{"suffix pattern requires suffix", exclude, "log_entries", true},
+		{"suffix pattern requires underscore", exclude, "catalog", true},
+		{"prefix pattern requires prefix", exclude, "user_schema_settings", true},
+		{"prefix pattern does not match schema name", exclude, "schema.orders", true},
+		{"unmatched table", exclude, "orders", true},
+		{"no patterns", SchemaConfig{}, "audit_log", true},
+		{"include pattern matches", includeAndExclude, "sales_orders", true},
+		{"include pattern does not match", includeAndExclude, "orders", false},
+		{"exclude wins over include", includeAndExclude, "schema_migrations", false},
+		{"exclude wins over included prefix", includeAndExclude, "sales_log", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schema.TableAllowed(tt.table); got != tt.want {
+				t.Errorf("TableAllowed(%q) = %v, want %v", tt.table, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterTables(t *testing.T) {
+	schema := SchemaConfig{ExcludePatterns: []string{"*_log", "schema_*"}}
+	tables := []string{"orders", "audit_log", "schema_migrations", "customers", "log_entries"}
+
+	want := []string{"orders", "customers", "log_entries"}
+	if got := schema.FilterTables(tables); !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterTables() = %v, want %v", got, want)
+	}
+}
+
+func TestValidateTablePatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  SchemaConfig
+		wantErr bool
+	}{
+		{"valid globs", SchemaConfig{IncludePatterns: []string{"sales_*"}, ExcludePatterns: []string{"*_log", "schema_*"}}, false},
+		{"invalid include", SchemaConfig{IncludePatterns: []string{"sales_["}}, true},
+		{"invalid exclude", SchemaConfig{ExcludePatterns: []string{"[*_log"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.schema.validateTablePatterns(); (err != nil) != tt.wantErr {
+				t.Errorf("validateTablePatterns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 支援的資料庫類型與 LLM 提供者
+var (
+	supportedDatabaseTypes = []string{"postgres", "mysql", "sqlite", "sqlite3"}
+	supportedLLMProviders  = []string{"openai", "anthropic", "local", "ollama"}
+)
+
+var (
+	embedderTypesMu sync.RWMutex
+	// embedderTypes 內建嵌入生成器類型，加上以 RegisterEmbedderType 註冊的自訂類型
+	embedderTypes = map[string]bool{"": true, "simple": true, "qwen": true, "llm": true}
+)
+
+// RegisterEmbedderType 將自訂嵌入生成器類型加入 Validate 接受的 embedder_type（由 vectorstore.RegisterEmbedder 呼叫）
+// 自訂類型須在 LoadConfig 之前註冊
+func RegisterEmbedderType(name string) {
+	embedderTypesMu.Lock()
+	defer embedderTypesMu.Unlock()
+	embedderTypes[name] = true
+}
+
+// knownEmbedderType 判斷嵌入生成器類型是否為內建或已註冊
+func knownEmbedderType(name string) bool {
+	embedderTypesMu.RLock()
+	defer embedderTypesMu.RUnlock()
+	return embedderTypes[name]
+}
+
+// knownEmbedderTypes 返回所有可用的嵌入生成器類型（供錯誤訊息使用）
+func knownEmbedderTypes() []string {
+	embedderTypesMu.RLock()
+	defer embedderTypesMu.RUnlock()
+	var names []string
+	for name := range embedderTypes {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate 檢查配置是否足以執行，一次返回所有問題（每個問題一行，並指出應修改的設定）
+// 檢查項目：資料庫類型、應用程式端口、LLM 提供者所需的主機/端口或 API 金鑰、
+// 嵌入生成器類型與其所需設定、嵌入維度（llm 類型可為 0）、表格過濾模式與 llm.phase_params
+func (c *Config) Validate() error {
+	var problems []error
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	// 資料庫
+	if !contains(supportedDatabaseTypes, c.Database.Type) {
+		add("database.type %q is not supported (supported: %s)", c.Database.Type, strings.Join(supportedDatabaseTypes, ", "))
+	}
+	if (c.Database.Type == "sqlite" || c.Database.Type == "sqlite3") && c.Database.URL == "" && c.Database.DBName == "" {
+		add("database.dbname is required for sqlite (path to the database file)")
+	}
+
+	// 應用程式
+	if c.App.Port <= 0 || c.App.Port > 65535 {
+		add("app.port %d is out of range (1-65535)", c.App.Port)
+	}
+
+	// LLM：未設定提供者時不檢查（只執行不需要 LLM 的命令）
+	switch c.LLM.Provider {
+	case "":
+	case "local", "ollama":
+		if c.LLM.Host == "" {
+			add("llm.host is required for provider %q (or set LLM_HOST)", c.LLM.Provider)
+		}
+		if c.LLM.Port <= 0 || c.LLM.Port > 65535 {
+			add("llm.port is required for provider %q and must be 1-65535, got %d (or set LLM_PORT)", c.LLM.Provider, c.LLM.Port)
+		}
+	case "openai":
+		// 自訂 base_url 通常為不需要金鑰的 OpenAI 相容伺服器
+		if c.LLM.APIKey == "" && c.LLM.BaseURL == "" {
+			add("llm.api_key is required for provider \"openai\" (or set OPENAI_API_KEY)")
+		}
+	case "anthropic":
+		if c.LLM.APIKey == "" {
+			add("llm.api_key is required for provider \"anthropic\" (or set ANTHROPIC_API_KEY)")
+		}
+	default:
+		add("llm.provider %q is not supported (supported: %s)", c.LLM.Provider, strings.Join(supportedLLMProviders, ", "))
+	}
+	if err := c.LLM.validatePhaseParams(); err != nil {
+		add("llm.%v", err)
+	}
+
+	// 嵌入生成器
+	embedderType := c.VectorStore.EmbedderType
+	if !knownEmbedderType(embedderType) && !c.VectorStore.FallbackToSimpleEmbedder {
+		add("vectorstore.embedder_type %q is unknown (supported: %s); set vectorstore.fallback_to_simple_embedder to use the simple embedder instead",
+			embedderType, strings.Join(knownEmbedderTypes(), ", "))
+	}
+	if embedderType == "llm" {
+		if c.LLM.Host == "" || c.LLM.Port <= 0 {
+			add("vectorstore.embedder_type \"llm\" requires llm.host and llm.port for the embeddings endpoint")
+		}
+	}
+	// llm 類型依實際嵌入回應偵測維度，embedding_dimension 為 0 表示完全自動偵測
+	if c.VectorStore.EmbeddingDimension < 0 || (c.VectorStore.EmbeddingDimension == 0 && embedderType != "llm") {
+		add("vectorstore.embedding_dimension must be greater than 0, got %d", c.VectorStore.EmbeddingDimension)
+	}
+
+	// 表格過濾
+	if err := c.Schema.validateTablePatterns(); err != nil {
+		add("schema: %v", err)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid config (%d problems):\n%w", len(problems), errors.Join(problems...))
+}
+
+// contains 判斷 values 是否包含 value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
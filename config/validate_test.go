@@ -0,0 +1,161 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validConfig 返回通過 Validate 的最小配置
+func validConfig() *Config {
+	cfg := &Config{}
+	cfg.Database.Type = "postgres"
+	cfg.App.Port = 8080
+	cfg.LLM.Provider = "local"
+	cfg.LLM.Host = "localhost"
+	cfg.LLM.Port = 11434
+	cfg.VectorStore.EmbedderType = "simple"
+	cfg.VectorStore.EmbeddingDimension = 384
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	outOfRange := 3.0
+
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string // 空字串表示應通過驗證
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"no llm provider", func(c *Config) { c.LLM = LLMConfig{} }, ""},
+		{"openai with base url", func(c *Config) { c.LLM = LLMConfig{Provider: "openai", BaseURL: "http://localhost:8000/v1"} }, ""},
+		{"sqlite with dbname", func(c *Config) { c.Database.Type = "sqlite"; c.Database.DBName = "shop.db" }, ""},
+		{"llm embedder detects dimension", func(c *Config) {
+			c.VectorStore.EmbedderType = "llm"
+			c.VectorStore.EmbeddingDimension = 0
+		}, ""},
+		{"unknown embedder with fallback", func(c *Config) {
+			c.VectorStore.EmbedderType = "openai"
+			c.VectorStore.FallbackToSimpleEmbedder = true
+		}, ""},
+
+		{"unsupported database type", func(c *Config) { c.Database.Type = "oracle" },
+			`database.type "oracle" is not supported (supported: postgres, mysql, sqlite, sqlite3)`},
+		{"sqlite without dbname", func(c *Config) { c.Database.Type = "sqlite" },
+			"database.dbname is required for sqlite (path to the database file)"},
+		{"app port out of range", func(c *Config) { c.App.Port = 70000 },
+			"app.port 70000 is out of range (1-65535)"},
+		{"app port zero", func(c *Config) { c.App.Port = 0 },
+			"app.port 0 is out of range (1-65535)"},
+		{"local provider without host", func(c *Config) { c.LLM.Host = "" },
+			`llm.host is required for provider "local" (or set LLM_HOST)`},
+		{"ollama provider without port", func(c *Config) { c.LLM.Provider = "ollama"; c.LLM.Port = 0 },
+			`llm.port is required for provider "ollama" and must be 1-65535, got 0 (or set LLM_PORT)`},
+		{"openai without api key", func(c *Config) { c.LLM = LLMConfig{Provider: "openai"} },
+			`llm.api_key is required for provider "openai" (or set OPENAI_API_KEY)`},
+		{"anthropic without api key", func(c *Config) { c.LLM = LLMConfig{Provider: "anthropic"} },
+			`llm.api_key is required for provider "anthropic" (or set ANTHROPIC_API_KEY)`},
+		{"unsupported llm provider", func(c *Config) { c.LLM.Provider = "cohere" },
+			`llm.provider "cohere" is not supported (supported: openai, anthropic, local, ollama)`},
+		{"phase params out of range", func(c *Config) { c.LLM.PhaseParams = map[string]LLMParams{"phase2": {Temperature: &outOfRange}} },
+			"llm.phase_params.phase2.temperature must be between 0 and 2, got 3"},
+		{"unknown embedder type", func(c *Config) { c.VectorStore.EmbedderType = "openai" },
+			`vectorstore.embedder_type "openai" is unknown (supported: llm, qwen, simple`},
+		{"llm embedder without endpoint", func(c *Config) { c.LLM = LLMConfig{}; c.VectorStore.EmbedderType = "llm" },
+			`vectorstore.embedder_type "llm" requires llm.host and llm.port for the embeddings endpoint`},
+		{"missing embedding dimension", func(c *Config) { c.VectorStore.EmbeddingDimension = 0 },
+			"vectorstore.embedding_dimension must be greater than 0, got 0"},
+		{"negative llm embedding dimension", func(c *Config) {
+			c.VectorStore.EmbedderType = "llm"
+			c.VectorStore.EmbeddingDimension = -1
+		}, "vectorstore.embedding_dimension must be greater than 0, got -1"},
+		{"invalid table pattern", func(c *Config) { c.Schema.ExcludePatterns = []string{"[*_log"} },
+			`schema: invalid table pattern "[*_log"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.mutate(cfg)
+			err := cfg.Validate()
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), "invalid config (1 problems):") {
+				t.Errorf("Validate() reported more than one problem: %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateListsEveryProblem(t *testing.T) {
+	cfg := validConfig()
+	cfg.Database.Type = "oracle"
+	cfg.App.Port = -1
+	cfg.VectorStore.EmbeddingDimension = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want error")
+	}
+	for _, want := range []string{
+		"invalid config (3 problems):",
+		`database.type "oracle" is not supported`,
+		"app.port -1 is out of range",
+		"vectorstore.embedding_dimension must be greater than 0",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want error containing %q", err, want)
+		}
+	}
+}
+
+func TestValidateAcceptsRegisteredEmbedderType(t *testing.T) {
+	cfg := validConfig()
+	cfg.VectorStore.EmbedderType = "validate-test-embedder"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("Validate() accepted an unregistered embedder type")
+	}
+
+	RegisterEmbedderType("validate-test-embedder")
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil after RegisterEmbedderType", err)
+	}
+}
+
+func TestLoadConfigValidates(t *testing.T) {
+	// 避免環境變數覆蓋測試配置
+	t.Setenv("DB_TYPE", "")
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("PORT", "")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("database:\n  type: postgres\nvectorstore:\n  embedding_dimension: 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatal("LoadConfig() = nil error, want validation error")
+	}
+	for _, want := range []string{path, "vectorstore.embedding_dimension must be greater than 0"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadConfig() = %v, want error containing %q", err, want)
+		}
+	}
+	// 未設定的 app.port 使用預設值，不應被報告
+	if strings.Contains(err.Error(), "app.port") {
+		t.Errorf("LoadConfig() = %v, want default app.port", err)
+	}
+}
@@ -1,31 +1,50 @@
 package analyzer
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	"strings"
+	"time"
 )
 
 // DatabaseAnalyzer 資料庫分析器
 type DatabaseAnalyzer struct {
-	db *sql.DB
+	db      *sql.DB
+	dbType  string   // postgres（預設）或 sqlite
+	schemas []string // 掃描的 PostgreSQL schema，空時為 public
+
+	collapsePartitions bool // 表格列表只包含分區父表，不列出分區子表
 }
 
-// NewDatabaseAnalyzer 創建資料庫分析器
+// NewDatabaseAnalyzer 創建 PostgreSQL 資料庫分析器
 func NewDatabaseAnalyzer(db *sql.DB) *DatabaseAnalyzer {
-	return &DatabaseAnalyzer{db: db}
+	return &DatabaseAnalyzer{db: db, dbType: "postgres"}
+}
+
+// NewDatabaseAnalyzerForType 依資料庫類型（database.type）創建資料庫分析器
+func NewDatabaseAnalyzerForType(db *sql.DB, dbType string) *DatabaseAnalyzer {
+	return &DatabaseAnalyzer{db: db, dbType: dbType}
 }
 
 // GetAllTables 獲取所有表格名稱
 func (a *DatabaseAnalyzer) GetAllTables() ([]string, error) {
-	query := `
-		SELECT tablename
-		FROM pg_tables
-		WHERE schemaname = 'public'
-		ORDER BY tablename
-	`
+	if a.isSQLite() {
+		return a.sqliteGetAllTables()
+	}
 
-	rows, err := a.db.Query(query)
+	placeholders, args := a.schemaPlaceholders(1)
+	// 一般表格、分區表與物化視圖（pg_matviews）；依 schema.collapse_partitions 排除分區子表
+	query := fmt.Sprintf(`
+		SELECT n.nspname, c.relname
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE %s AND n.nspname IN (%s)
+		ORDER BY n.nspname, c.relname
+	`, a.relationFilter(), placeholders)
+
+	rows, err := a.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -33,11 +52,11 @@ func (a *DatabaseAnalyzer) GetAllTables() ([]string, error) {
 
 	var tables []string
 	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
+		var schemaName, tableName string
+		if err := rows.Scan(&schemaName, &tableName); err != nil {
 			return nil, err
 		}
-		tables = append(tables, tableName)
+		tables = append(tables, a.qualifyTableName(schemaName, tableName))
 	}
 
 	return tables, rows.Err()
@@ -45,6 +64,15 @@ func (a *DatabaseAnalyzer) GetAllTables() ([]string, error) {
 
 // GetTableSchema 獲取表格的 schema 信息
 func (a *DatabaseAnalyzer) GetTableSchema(tableName string) ([]map[string]interface{}, error) {
+	return a.GetTableSchemaContext(context.Background(), tableName)
+}
+
+// GetTableSchemaContext 獲取表格的 schema 信息（可透過 context 設定逾時）
+func (a *DatabaseAnalyzer) GetTableSchemaContext(ctx context.Context, tableName string) ([]map[string]interface{}, error) {
+	if a.isSQLite() {
+		return a.sqliteGetTableSchema(ctx, tableName)
+	}
+
 	query := `
 		SELECT
 			column_name,
@@ -53,29 +81,38 @@ func (a *DatabaseAnalyzer) GetTableSchema(tableName string) ([]map[string]interf
 			column_default,
 			character_maximum_length,
 			numeric_precision,
-			numeric_scale
+			numeric_scale,
+			udt_schema,
+			udt_name,
+			col_description(format('%I.%I', table_schema, table_name)::regclass, ordinal_position) AS column_comment
 		FROM information_schema.columns
-		WHERE table_name = $1 AND table_schema = 'public'
+		WHERE table_name = $1 AND table_schema = $2
 		ORDER BY ordinal_position
 	`
 
-	rows, err := a.db.Query(query, tableName)
+	rows, err := a.queryColumns(ctx, query, tableName)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	type userDefinedType struct{ schema, name string }
 	var schema []map[string]interface{}
+	userDefined := make(map[int]userDefinedType)
 	for rows.Next() {
 		var colName, dataType string
 		var isNullable string
 		var columnDefault sql.NullString
 		var charMaxLen, numPrecision, numScale sql.NullInt64
+		var udtSchema, udtName, comment sql.NullString
 
-		err := rows.Scan(&colName, &dataType, &isNullable, &columnDefault, &charMaxLen, &numPrecision, &numScale)
+		err := rows.Scan(&colName, &dataType, &isNullable, &columnDefault, &charMaxLen, &numPrecision, &numScale, &udtSchema, &udtName, &comment)
 		if err != nil {
 			return nil, err
 		}
+		if dataType == "USER-DEFINED" && udtName.Valid {
+			userDefined[len(schema)] = userDefinedType{schema: udtSchema.String, name: udtName.String}
+		}
 
 		column := map[string]interface{}{
 			"name":       colName,
@@ -89,15 +126,43 @@ func (a *DatabaseAnalyzer) GetTableSchema(tableName string) ([]map[string]interf
 		if columnDefault.Valid {
 			column["default"] = columnDefault.String
 		}
+		// COMMENT ON COLUMN 設定的欄位說明
+		if comment.Valid && strings.TrimSpace(comment.String) != "" {
+			column["description"] = comment.String
+		}
 
 		schema = append(schema, column)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 原生 ENUM 型別：從 pg_enum 取得完整值域（非 ENUM 的自訂型別沒有值）
+	for i, udt := range userDefined {
+		labels, err := a.enumLabelsContext(ctx, udt.schema, udt.name)
+		if err != nil {
+			log.Printf("Warning: Failed to load enum labels for type %s.%s: %v", udt.schema, udt.name, err)
+			continue
+		}
+		if len(labels) > 0 {
+			setEnumColumn(schema[i], udt.name, labels)
+		}
+	}
 
-	return schema, rows.Err()
+	return schema, nil
 }
 
 // GetTableConstraints 獲取表格的約束信息（外鍵、主鍵等）
 func (a *DatabaseAnalyzer) GetTableConstraints(tableName string) (map[string]interface{}, error) {
+	return a.GetTableConstraintsContext(context.Background(), tableName)
+}
+
+// GetTableConstraintsContext 獲取表格的約束信息（外鍵、主鍵等）（可透過 context 設定逾時）
+func (a *DatabaseAnalyzer) GetTableConstraintsContext(ctx context.Context, tableName string) (map[string]interface{}, error) {
+	if a.isSQLite() {
+		return a.sqliteGetTableConstraints(ctx, tableName)
+	}
+
 	constraints := map[string]interface{}{
 		"primary_keys": []string{},
 		"foreign_keys": []map[string]interface{}{},
@@ -108,12 +173,13 @@ func (a *DatabaseAnalyzer) GetTableConstraints(tableName string) (map[string]int
 	pkQuery := `
 		SELECT kc.column_name
 		FROM information_schema.table_constraints tc
-		JOIN information_schema.key_column_usage kc ON tc.constraint_name = kc.constraint_name
-		WHERE tc.table_name = $1 AND tc.table_schema = 'public' AND tc.constraint_type = 'PRIMARY KEY'
+		JOIN information_schema.key_column_usage kc ON tc.constraint_name = kc.constraint_name AND tc.table_schema = kc.table_schema
+		WHERE tc.table_name = $1 AND tc.table_schema = $2 AND tc.constraint_type = 'PRIMARY KEY'
 		ORDER BY kc.ordinal_position
 	`
 
-	pkRows, err := a.db.Query(pkQuery, tableName)
+	schemaName, table := a.splitTableName(tableName)
+	pkRows, err := a.db.QueryContext(ctx, pkQuery, table, schemaName)
 	if err == nil {
 		defer pkRows.Close()
 		var pks []string
@@ -126,36 +192,40 @@ func (a *DatabaseAnalyzer) GetTableConstraints(tableName string) (map[string]int
 		constraints["primary_keys"] = pks
 	}
 
-	// 獲取外鍵
+	// 獲取外鍵：參照欄位以 position_in_unique_constraint 對應，複合外鍵的欄位不會交叉配對
 	fkQuery := `
 		SELECT
-			tc.constraint_name,
+			kcu.constraint_name,
 			kcu.column_name,
-			ccu.table_name AS referenced_table,
-			ccu.column_name AS referenced_column
+			rcu.table_schema AS referenced_schema,
+			rcu.table_name AS referenced_table,
+			rcu.column_name AS referenced_column
 		FROM information_schema.table_constraints tc
-		JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name
-		JOIN information_schema.constraint_column_usage ccu ON tc.constraint_name = ccu.constraint_name
-		WHERE tc.table_name = $1 AND tc.table_schema = 'public' AND tc.constraint_type = 'FOREIGN KEY'
-		ORDER BY tc.constraint_name, kcu.ordinal_position
+		JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
+		JOIN information_schema.referential_constraints rc ON tc.constraint_name = rc.constraint_name AND tc.table_schema = rc.constraint_schema
+		JOIN information_schema.key_column_usage rcu ON rc.unique_constraint_name = rcu.constraint_name
+			AND rc.unique_constraint_schema = rcu.constraint_schema
+			AND kcu.position_in_unique_constraint = rcu.ordinal_position
+		WHERE tc.table_name = $1 AND tc.table_schema = $2 AND tc.constraint_type = 'FOREIGN KEY'
+		ORDER BY kcu.constraint_name, kcu.ordinal_position
 	`
 
-	fkRows, err := a.db.Query(fkQuery, tableName)
+	fkRows, err := a.db.QueryContext(ctx, fkQuery, table, schemaName)
 	if err == nil {
 		defer fkRows.Close()
-		var fks []map[string]interface{}
+		var fkColumns []foreignKeyColumn
 		for fkRows.Next() {
-			var constraintName, columnName, refTable, refColumn string
-			if err := fkRows.Scan(&constraintName, &columnName, &refTable, &refColumn); err == nil {
-				fks = append(fks, map[string]interface{}{
-					"constraint_name":   constraintName,
-					"column":            columnName,
-					"referenced_table":  refTable,
-					"referenced_column": refColumn,
+			var constraintName, columnName, refSchema, refTable, refColumn string
+			if err := fkRows.Scan(&constraintName, &columnName, &refSchema, &refTable, &refColumn); err == nil {
+				fkColumns = append(fkColumns, foreignKeyColumn{
+					constraintName:   constraintName,
+					column:           columnName,
+					referencedTable:  a.qualifyTableName(refSchema, refTable),
+					referencedColumn: refColumn,
 				})
 			}
 		}
-		constraints["foreign_keys"] = fks
+		constraints["foreign_keys"] = groupForeignKeys(fkColumns)
 	}
 
 	// 獲取唯一鍵
@@ -164,12 +234,12 @@ func (a *DatabaseAnalyzer) GetTableConstraints(tableName string) (map[string]int
 			tc.constraint_name,
 			kcu.column_name
 		FROM information_schema.table_constraints tc
-		JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name
-		WHERE tc.table_name = $1 AND tc.table_schema = 'public' AND tc.constraint_type = 'UNIQUE'
+		JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
+		WHERE tc.table_name = $1 AND tc.table_schema = $2 AND tc.constraint_type = 'UNIQUE'
 		ORDER BY tc.constraint_name, kcu.ordinal_position
 	`
 
-	ukRows, err := a.db.Query(ukQuery, tableName)
+	ukRows, err := a.db.QueryContext(ctx, ukQuery, table, schemaName)
 	if err == nil {
 		defer ukRows.Close()
 		ukMap := make(map[string][]string)
@@ -195,16 +265,26 @@ func (a *DatabaseAnalyzer) GetTableConstraints(tableName string) (map[string]int
 
 // GetTableIndexes 獲取表格的索引信息
 func (a *DatabaseAnalyzer) GetTableIndexes(tableName string) ([]map[string]interface{}, error) {
+	return a.GetTableIndexesContext(context.Background(), tableName)
+}
+
+// GetTableIndexesContext 獲取表格的索引信息（可透過 context 設定逾時）
+func (a *DatabaseAnalyzer) GetTableIndexesContext(ctx context.Context, tableName string) ([]map[string]interface{}, error) {
+	if a.isSQLite() {
+		return a.sqliteGetTableIndexes(ctx, tableName)
+	}
+
 	query := `
 		SELECT
 			indexname,
 			indexdef
 		FROM pg_indexes
-		WHERE tablename = $1 AND schemaname = 'public'
+		WHERE tablename = $1 AND schemaname = $2
 		ORDER BY indexname
 	`
 
-	rows, err := a.db.Query(query, tableName)
+	schemaName, table := a.splitTableName(tableName)
+	rows, err := a.db.QueryContext(ctx, query, table, schemaName)
 	if err != nil {
 		return nil, err
 	}
@@ -253,35 +333,91 @@ func (a *DatabaseAnalyzer) GetTableIndexes(tableName string) ([]map[string]inter
 
 // GetTableSamples 獲取表格的樣本數據
 func (a *DatabaseAnalyzer) GetTableSamples(tableName string, maxSamples int) ([]map[string]interface{}, error) {
-	// 檢查表格是否有 created_at 或 updated_at 欄位來排序
-	hasTimestamp := false
-	schema, err := a.GetTableSchema(tableName)
+	return a.GetTableSamplesContext(context.Background(), tableName, maxSamples)
+}
+
+// GetTableSamplesContext 獲取表格的樣本數據（可透過 context 設定逾時）
+func (a *DatabaseAnalyzer) GetTableSamplesContext(ctx context.Context, tableName string, maxSamples int) ([]map[string]interface{}, error) {
+	schema, err := a.GetTableSchemaContext(ctx, tableName)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, col := range schema {
-		colName := col["name"].(string)
-		if colName == "created_at" || colName == "updated_at" {
-			hasTimestamp = true
-			break
+	// 依時間戳欄位排序，沒有時改用主鍵降序
+	orderBy, _ := latestRowsOrder(schema, nil)
+	if orderBy == "" {
+		if constraints, err := a.GetTableConstraintsContext(ctx, tableName); err == nil {
+			orderBy, _ = latestRowsOrder(schema, PrimaryKeyColumns(constraints))
 		}
 	}
 
 	// 構建查詢
 	var query string
-	if hasTimestamp {
-		query = fmt.Sprintf("SELECT * FROM %s ORDER BY COALESCE(updated_at, created_at) DESC LIMIT %d", tableName, maxSamples)
+	if orderBy != "" {
+		query = fmt.Sprintf("SELECT * FROM %s ORDER BY %s LIMIT %d", tableName, orderBy, maxSamples)
 	} else {
+		log.Printf("Warning: Table %s has no timestamp column or primary key; samples are in arbitrary order", tableName)
 		query = fmt.Sprintf("SELECT * FROM %s LIMIT %d", tableName, maxSamples)
 	}
 
-	rows, err := a.db.Query(query)
+	rows, err := a.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanSampleRows(rows)
+}
+
+// latestRowsOrder 返回取得最新資料的排序子句與使用的排序鍵：優先使用 created_at/updated_at，其次主鍵降序
+// 找不到穩定排序鍵時返回空字串
+func latestRowsOrder(schema []map[string]interface{}, primaryKeys []string) (string, string) {
+	hasCreated, hasUpdated := false, false
+	for _, col := range schema {
+		switch col["name"] {
+		case "created_at":
+			hasCreated = true
+		case "updated_at":
+			hasUpdated = true
+		}
+	}
+
+	switch {
+	case hasCreated && hasUpdated:
+		return "COALESCE(updated_at, created_at) DESC", "updated_at, created_at"
+	case hasUpdated:
+		return "updated_at DESC", "updated_at"
+	case hasCreated:
+		return "created_at DESC", "created_at"
+	case len(primaryKeys) > 0:
+		keys := make([]string, len(primaryKeys))
+		for i, pk := range primaryKeys {
+			keys[i] = pk + " DESC"
+		}
+		return strings.Join(keys, ", "), strings.Join(primaryKeys, ", ")
+	}
+	return "", ""
+}
+
+// PrimaryKeyColumns 從約束信息取出主鍵欄位（相容分析結果與 JSON 解碼後的格式）
+func PrimaryKeyColumns(constraints map[string]interface{}) []string {
+	switch pks := constraints["primary_keys"].(type) {
+	case []string:
+		return pks
+	case []interface{}:
+		columns := make([]string, 0, len(pks))
+		for _, pk := range pks {
+			if name, ok := pk.(string); ok {
+				columns = append(columns, name)
+			}
+		}
+		return columns
+	}
+	return nil
+}
+
+// scanSampleRows 將查詢結果轉換為樣本數據
+func scanSampleRows(rows *sql.Rows) ([]map[string]interface{}, error) {
 	// 獲取欄位名稱
 	columns, err := rows.Columns()
 	if err != nil {
@@ -325,10 +461,15 @@ func (a *DatabaseAnalyzer) GetTableSamples(tableName string, maxSamples int) ([]
 
 // GetTableStats 獲取表格的統計信息
 func (a *DatabaseAnalyzer) GetTableStats(tableName string) (map[string]interface{}, error) {
+	return a.GetTableStatsContext(context.Background(), tableName)
+}
+
+// GetTableStatsContext 獲取表格的統計信息（可透過 context 設定逾時）
+func (a *DatabaseAnalyzer) GetTableStatsContext(ctx context.Context, tableName string) (map[string]interface{}, error) {
 	// 獲取總行數
 	var rowCount int64
 	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)
-	err := a.db.QueryRow(countQuery).Scan(&rowCount)
+	err := a.db.QueryRowContext(ctx, countQuery).Scan(&rowCount)
 	if err != nil {
 		return nil, err
 	}
@@ -337,6 +478,16 @@ func (a *DatabaseAnalyzer) GetTableStats(tableName string) (map[string]interface
 		"row_count": rowCount,
 	}
 
+	a.addTableSizeStats(ctx, tableName, stats)
+	return stats, nil
+}
+
+// addTableSizeStats 嘗試加入表格大小信息（PostgreSQL 特定），失敗時略過
+func (a *DatabaseAnalyzer) addTableSizeStats(ctx context.Context, tableName string, stats map[string]interface{}) {
+	if a.isSQLite() {
+		return
+	}
+
 	// 嘗試獲取表格大小信息（PostgreSQL 特定）
 	sizeQuery := `
 		SELECT
@@ -345,54 +496,97 @@ func (a *DatabaseAnalyzer) GetTableStats(tableName string) (map[string]interface
 			pg_size_pretty(pg_total_relation_size($1) - pg_relation_size($1)) as index_size
 	`
 
+	// 以 schema 限定名稱解析 regclass，不依賴 search_path
+	schemaName, table := a.splitTableName(tableName)
 	var totalSize, tableSize, indexSize string
-	err = a.db.QueryRow(sizeQuery, tableName).Scan(&totalSize, &tableSize, &indexSize)
+	err := a.db.QueryRowContext(ctx, sizeQuery, schemaName+"."+table).Scan(&totalSize, &tableSize, &indexSize)
 	if err == nil {
 		stats["total_size"] = totalSize
 		stats["table_size"] = tableSize
 		stats["index_size"] = indexSize
 	}
-
-	return stats, nil
 }
 
 // AnalyzeTable 分析單個表格，返回完整的分析結果
-func (a *DatabaseAnalyzer) AnalyzeTable(tableName string, maxSamples int) (map[string]interface{}, error) {
+// 若 ctx 逾時，已收集的部分仍會返回，並標記 analysis_status 為 partial 及原因
+func (a *DatabaseAnalyzer) AnalyzeTable(ctx context.Context, tableName string, maxSamples int) (map[string]interface{}, error) {
+	return a.AnalyzeTableWithPolicy(ctx, tableName, SamplingPolicy{MaxSamples: maxSamples})
+}
+
+// analyzeTable 收集表格的 schema、約束、索引、樣本與統計，樣本與統計的取得方式由呼叫端決定
+func (a *DatabaseAnalyzer) analyzeTable(ctx context.Context, tableName string,
+	sampleFn func(ctx context.Context, tableName string) ([]map[string]interface{}, error),
+	statsFn func(ctx context.Context, tableName string) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	var partialReasons []string
+	recordTimeout := func(step string, err error) {
+		if ctx.Err() != nil {
+			partialReasons = append(partialReasons, fmt.Sprintf("%s timed out: %v", step, err))
+		}
+	}
+
 	// 獲取表格 schema
-	schema, err := a.GetTableSchema(tableName)
+	schema, err := a.GetTableSchemaContext(ctx, tableName)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to get schema for table %s: %w", tableName, ctx.Err())
+		}
 		return nil, fmt.Errorf("failed to get schema for table %s: %w", tableName, err)
 	}
 
 	// 獲取表格約束
-	constraints, err := a.GetTableConstraints(tableName)
+	constraints, err := a.GetTableConstraintsContext(ctx, tableName)
 	if err != nil {
+		recordTimeout("constraints", err)
 		constraints = map[string]interface{}{}
 	}
 
 	// 獲取表格索引
-	indexes, err := a.GetTableIndexes(tableName)
+	indexes, err := a.GetTableIndexesContext(ctx, tableName)
 	if err != nil {
+		recordTimeout("indexes", err)
 		indexes = []map[string]interface{}{}
 	}
 
 	// 獲取樣本數據
-	samples, err := a.GetTableSamples(tableName, maxSamples)
+	samples, err := sampleFn(ctx, tableName)
 	if err != nil {
+		recordTimeout("samples", err)
 		samples = []map[string]interface{}{}
 	}
 
 	// 獲取表格統計
-	stats, err := a.GetTableStats(tableName)
+	stats, err := statsFn(ctx, tableName)
 	if err != nil {
+		recordTimeout("stats", err)
 		stats = map[string]interface{}{}
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"schema":      schema,
 		"constraints": constraints,
 		"indexes":     indexes,
 		"samples":     samples,
 		"stats":       stats,
-	}, nil
+		"analyzed_at": time.Now().Format(time.RFC3339),
+	}
+
+	// 主鍵與樣本排序鍵：沒有主鍵的表格在維度建模與樣本排序上都不穩定
+	primaryKeys := PrimaryKeyColumns(constraints)
+	if _, ok := constraints["primary_keys"]; ok {
+		result["has_primary_key"] = len(primaryKeys) > 0
+	}
+	if _, sortKey := latestRowsOrder(schema, primaryKeys); sortKey != "" {
+		result["sample_sort_key"] = sortKey
+	} else {
+		result["sample_order_note"] = "no timestamp column or primary key; samples are in arbitrary order"
+	}
+
+	a.addTableType(ctx, tableName, result)
+
+	if len(partialReasons) > 0 {
+		result["analysis_status"] = "partial"
+		result["partial_reason"] = strings.Join(partialReasons, "; ")
+	}
+
+	return result, nil
 }
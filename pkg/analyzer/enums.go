@@ -0,0 +1,83 @@
+package analyzer
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+var (
+	enumTypePattern       = regexp.MustCompile(`(?is)^enum\s*\((.*)\)$`)
+	createTypeEnumPattern = regexp.MustCompile(`(?is)^CREATE\s+TYPE\s+([^\s]+)\s+AS\s+ENUM\s*\((.*)\)\s*$`)
+)
+
+// ParseEnumValues 解析 MySQL 的 column_type（如 enum('active','inactive')），非 ENUM 型別返回 nil
+func ParseEnumValues(columnType string) []string {
+	m := enumTypePattern.FindStringSubmatch(strings.TrimSpace(columnType))
+	if m == nil {
+		return nil
+	}
+	return parseQuotedList(m[1])
+}
+
+// parseQuotedList 解析以逗號分隔的單引號字串列表，連續兩個單引號視為跳脫的單引號
+func parseQuotedList(list string) []string {
+	values := []string{}
+	var current strings.Builder
+	inQuote := false
+	for i := 0; i < len(list); i++ {
+		ch := list[i]
+		switch {
+		case ch == '\'' && inQuote && i+1 < len(list) && list[i+1] == '\'':
+			current.WriteByte('\'')
+			i++
+		case ch == '\'':
+			if inQuote {
+				values = append(values, current.String())
+				current.Reset()
+			}
+			inQuote = !inQuote
+		case inQuote:
+			current.WriteByte(ch)
+		}
+	}
+	return values
+}
+
+// setEnumColumn 將欄位標記為原生 ENUM，enum_values 為資料庫定義的完整值域
+func setEnumColumn(column map[string]interface{}, typeName string, values []string) {
+	column["type"] = "enum"
+	column["enum_values"] = values
+	if typeName != "" {
+		column["enum_type"] = typeName
+	}
+}
+
+// enumLabelsContext 從 pg_enum 依定義順序讀取 PostgreSQL ENUM 型別的值
+func (a *DatabaseAnalyzer) enumLabelsContext(ctx context.Context, schemaName, typeName string) ([]string, error) {
+	query := `
+		SELECT e.enumlabel
+		FROM pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		WHERE n.nspname = $1 AND t.typname = $2
+		ORDER BY e.enumsortorder
+	`
+
+	rows, err := a.db.QueryContext(ctx, query, schemaName, typeName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := []string{}
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+
+	return labels, rows.Err()
+}
@@ -0,0 +1,34 @@
+package analyzer
+
+// foreignKeyColumn 外鍵約束中的一組欄位對應（依欄位在約束中的順序）
+type foreignKeyColumn struct {
+	constraintName   string
+	column           string
+	referencedTable  string
+	referencedColumn string
+}
+
+// groupForeignKeys 將依約束名稱與欄位順序排列的欄位對應合併為每個約束一筆
+// columns / referenced_columns 為依序的完整欄位列表；column / referenced_column 保留第一個欄位供舊的讀取端使用
+func groupForeignKeys(rows []foreignKeyColumn) []map[string]interface{} {
+	var fks []map[string]interface{}
+	byName := make(map[string]map[string]interface{})
+	for _, row := range rows {
+		fk, ok := byName[row.constraintName]
+		if !ok {
+			fk = map[string]interface{}{
+				"constraint_name":    row.constraintName,
+				"column":             row.column,
+				"referenced_table":   row.referencedTable,
+				"referenced_column":  row.referencedColumn,
+				"columns":            []string{},
+				"referenced_columns": []string{},
+			}
+			byName[row.constraintName] = fk
+			fks = append(fks, fk)
+		}
+		fk["columns"] = append(fk["columns"].([]string), row.column)
+		fk["referenced_columns"] = append(fk["referenced_columns"].([]string), row.referencedColumn)
+	}
+	return fks
+}
@@ -0,0 +1,124 @@
+package analyzer
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// compositeFK 兩欄位複合外鍵 order_items(order_id, order_version) → orders(id, version) 的預期結果
+func compositeFK(constraintName string) map[string]interface{} {
+	return map[string]interface{}{
+		"constraint_name":    constraintName,
+		"column":             "order_id",
+		"referenced_table":   "orders",
+		"referenced_column":  "id",
+		"columns":            []string{"order_id", "order_version"},
+		"referenced_columns": []string{"id", "version"},
+	}
+}
+
+func TestGroupForeignKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []foreignKeyColumn
+		want []map[string]interface{}
+	}{
+		{
+			name: "composite foreign key",
+			rows: []foreignKeyColumn{
+				{constraintName: "fk_items_order", column: "order_id", referencedTable: "orders", referencedColumn: "id"},
+				{constraintName: "fk_items_order", column: "order_version", referencedTable: "orders", referencedColumn: "version"},
+			},
+			want: []map[string]interface{}{compositeFK("fk_items_order")},
+		},
+		{
+			name: "separate constraints stay separate",
+			rows: []foreignKeyColumn{
+				{constraintName: "fk_items_order", column: "order_id", referencedTable: "orders", referencedColumn: "id"},
+				{constraintName: "fk_items_order", column: "order_version", referencedTable: "orders", referencedColumn: "version"},
+				{constraintName: "fk_items_product", column: "product_id", referencedTable: "products", referencedColumn: "id"},
+			},
+			want: []map[string]interface{}{
+				compositeFK("fk_items_order"),
+				{
+					"constraint_name":    "fk_items_product",
+					"column":             "product_id",
+					"referenced_table":   "products",
+					"referenced_column":  "id",
+					"columns":            []string{"product_id"},
+					"referenced_columns": []string{"id"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := groupForeignKeys(tt.rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("groupForeignKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteCompositeForeignKey(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	if _, err := db.Exec(`
+		CREATE TABLE orders (id INTEGER, version INTEGER, PRIMARY KEY (id, version));
+		CREATE TABLE order_items (
+			id INTEGER PRIMARY KEY,
+			order_id INTEGER,
+			order_version INTEGER,
+			FOREIGN KEY (order_id, order_version) REFERENCES orders (id, version)
+		)`); err != nil {
+		t.Fatal(err)
+	}
+
+	constraints, err := NewDatabaseAnalyzerForType(db, "sqlite").GetTableConstraints("order_items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]interface{}{compositeFK("fk_order_items_0")}
+	if got := constraints["foreign_keys"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("foreign_keys = %v, want %v", got, want)
+	}
+}
+
+func TestSchemaDumpCompositeForeignKey(t *testing.T) {
+	dump := filepath.Join(t.TempDir(), "schema.sql")
+	if err := os.WriteFile(dump, []byte(`
+		CREATE TABLE orders (id integer, version integer, PRIMARY KEY (id, version));
+		CREATE TABLE order_items (
+			id integer PRIMARY KEY,
+			order_id integer,
+			order_version integer,
+			CONSTRAINT fk_items_order FOREIGN KEY (order_id, order_version) REFERENCES orders (id, version)
+		);`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewSchemaDumpReader(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysis, err := reader.AnalyzeTable("order_items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	constraints := analysis["constraints"].(map[string]interface{})
+	want := []map[string]interface{}{compositeFK("fk_items_order")}
+	if got := constraints["foreign_keys"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("foreign_keys = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// overviewTopTables 總覽中列出的最大表格數量
+const overviewTopTables = 10
+
+// DatabaseOverview 資料庫總覽統計
+type DatabaseOverview struct {
+	TableCount       int            `json:"table_count"`
+	ColumnCount      int            `json:"column_count"`
+	EstimatedRows    int64          `json:"estimated_rows"`               // 不含行數未知（-1）的表格
+	UnknownRowTables int            `json:"unknown_row_tables,omitempty"` // 行數未知的表格數（PostgreSQL 尚未 ANALYZE）
+	ForeignKeyCount  int            `json:"foreign_key_count"`
+	LargestTables    []TableSummary `json:"largest_tables"`
+	GeneratedAt      time.Time      `json:"generated_at"`
+}
+
+// OverviewContext 統計表格數、欄位數、估計總行數、外鍵關係數與行數最多的表格
+// allowed 不為 nil 時只統計其返回 true 的表格（schema include/exclude 規則）
+func (a *DatabaseAnalyzer) OverviewContext(ctx context.Context, allowed func(string) bool) (*DatabaseOverview, error) {
+	summaries, err := a.ListTableSummariesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	overview := &DatabaseOverview{GeneratedAt: time.Now()}
+	var tables []TableSummary
+	for _, summary := range summaries {
+		if allowed != nil && !allowed(summary.Name) {
+			continue
+		}
+		tables = append(tables, summary)
+
+		overview.TableCount++
+		overview.ColumnCount += summary.ColumnCount
+		if summary.EstimatedRows >= 0 {
+			overview.EstimatedRows += summary.EstimatedRows
+		} else {
+			overview.UnknownRowTables++
+		}
+
+		constraints, err := a.GetTableConstraintsContext(ctx, summary.Name)
+		if err != nil {
+			return nil, err
+		}
+		overview.ForeignKeyCount += countForeignKeyConstraints(constraints)
+	}
+
+	sort.SliceStable(tables, func(i, j int) bool { return tables[i].EstimatedRows > tables[j].EstimatedRows })
+	if len(tables) > overviewTopTables {
+		tables = tables[:overviewTopTables]
+	}
+	overview.LargestTables = tables
+	if overview.LargestTables == nil {
+		overview.LargestTables = []TableSummary{}
+	}
+
+	return overview, nil
+}
+
+// countForeignKeyConstraints 以約束名稱計算外鍵關係數（複合外鍵只算一次）
+func countForeignKeyConstraints(constraints map[string]interface{}) int {
+	fks, _ := constraints["foreign_keys"].([]map[string]interface{})
+	names := make(map[string]bool, len(fks))
+	for _, fk := range fks {
+		name, _ := fk["constraint_name"].(string)
+		names[name] = true
+	}
+	return len(names)
+}
+
+// OverviewCache 在 TTL 內重用資料庫總覽，避免儀表板重複刷新時反覆掃描 information_schema
+type OverviewCache struct {
+	analyzer *DatabaseAnalyzer
+	allowed  func(string) bool
+	ttl      time.Duration
+
+	mu       sync.Mutex
+	overview *DatabaseOverview
+}
+
+// NewOverviewCache 創建資料庫總覽快取
+func NewOverviewCache(a *DatabaseAnalyzer, ttl time.Duration, allowed func(string) bool) *OverviewCache {
+	return &OverviewCache{analyzer: a, allowed: allowed, ttl: ttl}
+}
+
+// Get 返回總覽；快取未過期時返回快取結果且 cached 為 true
+func (c *OverviewCache) Get(ctx context.Context) (*DatabaseOverview, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.overview != nil && time.Since(c.overview.GeneratedAt) < c.ttl {
+		return c.overview, true, nil
+	}
+
+	overview, err := c.analyzer.OverviewContext(ctx, c.allowed)
+	if err != nil {
+		return nil, false, err
+	}
+	c.overview = overview
+	return overview, false, nil
+}
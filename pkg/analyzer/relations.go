@@ -0,0 +1,144 @@
+package analyzer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// 表格類型（analysis 結果的 table_type）
+const (
+	TableTypeBase        = "BASE TABLE"
+	TableTypePartitioned = "PARTITIONED TABLE" // PostgreSQL 分區表的父表
+	TableTypeMatView     = "MATERIALIZED VIEW"
+)
+
+// WithCollapsePartitions 設定是否將 PostgreSQL 分區子表合併到父表（schema.collapse_partitions）
+// 合併時表格列表只包含父表，父表的樣本與統計涵蓋所有分區；返回同一個分析器以便串接
+func (a *DatabaseAnalyzer) WithCollapsePartitions(collapse bool) *DatabaseAnalyzer {
+	a.collapsePartitions = collapse
+	return a
+}
+
+// relationFilter 表格列表使用的 pg_class 條件（別名 c）：一般表格、分區父表與物化視圖，
+// 合併分區時排除分區子表
+func (a *DatabaseAnalyzer) relationFilter() string {
+	filter := "c.relkind IN ('r', 'p', 'm')"
+	if a.collapsePartitions {
+		filter += " AND NOT c.relispartition"
+	}
+	return filter
+}
+
+// TableTypeContext 返回表格類型：BASE TABLE、PARTITIONED TABLE 或 MATERIALIZED VIEW；SQLite 一律為 BASE TABLE
+func (a *DatabaseAnalyzer) TableTypeContext(ctx context.Context, tableName string) (string, error) {
+	if a.isSQLite() {
+		return TableTypeBase, nil
+	}
+
+	var relkind string
+	query := `
+		SELECT c.relkind
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relname = $1 AND n.nspname = $2
+	`
+	schemaName, table := a.splitTableName(tableName)
+	if err := a.db.QueryRowContext(ctx, query, table, schemaName).Scan(&relkind); err != nil {
+		return "", err
+	}
+
+	switch relkind {
+	case "p":
+		return TableTypePartitioned, nil
+	case "m":
+		return TableTypeMatView, nil
+	default:
+		return TableTypeBase, nil
+	}
+}
+
+// partitionNamesContext 返回分區父表（pg_partitioned_table）的直接分區名稱
+func (a *DatabaseAnalyzer) partitionNamesContext(ctx context.Context, tableName string) ([]string, error) {
+	query := `
+		SELECT cn.nspname, child.relname
+		FROM pg_partitioned_table pt
+		JOIN pg_class parent ON parent.oid = pt.partrelid
+		JOIN pg_namespace pn ON pn.oid = parent.relnamespace
+		JOIN pg_inherits i ON i.inhparent = parent.oid
+		JOIN pg_class child ON child.oid = i.inhrelid
+		JOIN pg_namespace cn ON cn.oid = child.relnamespace
+		WHERE parent.relname = $1 AND pn.nspname = $2
+		ORDER BY child.relname
+	`
+	schemaName, table := a.splitTableName(tableName)
+	rows, err := a.db.QueryContext(ctx, query, table, schemaName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var partitions []string
+	for rows.Next() {
+		var partitionSchema, partition string
+		if err := rows.Scan(&partitionSchema, &partition); err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, a.qualifyTableName(partitionSchema, partition))
+	}
+	return partitions, rows.Err()
+}
+
+// addTableType 在分析結果中記錄表格類型；分區父表另外記錄分區列表
+func (a *DatabaseAnalyzer) addTableType(ctx context.Context, tableName string, result map[string]interface{}) {
+	tableType, err := a.TableTypeContext(ctx, tableName)
+	if err != nil {
+		return
+	}
+	result["table_type"] = tableType
+
+	if tableType == TableTypePartitioned {
+		if partitions, err := a.partitionNamesContext(ctx, tableName); err == nil {
+			result["partitions"] = partitions
+		}
+	}
+}
+
+// matviewColumnsQuery 物化視圖不在 information_schema.columns 中，改由 pg_attribute 取得欄位，
+// 輸出欄位與 GetTableSchemaContext 的 information_schema 查詢相同
+const matviewColumnsQuery = `
+	SELECT
+		att.attname,
+		CASE WHEN t.typtype = 'e' THEN 'USER-DEFINED' ELSE format_type(att.atttypid, NULL) END,
+		CASE WHEN att.attnotnull THEN 'NO' ELSE 'YES' END,
+		NULL::text,
+		CASE WHEN att.atttypid IN ('varchar'::regtype, 'bpchar'::regtype) AND att.atttypmod > 4 THEN att.atttypmod - 4 END,
+		CASE WHEN att.atttypid = 'numeric'::regtype AND att.atttypmod > 4 THEN ((att.atttypmod - 4) >> 16) & 65535 END,
+		CASE WHEN att.atttypid = 'numeric'::regtype AND att.atttypmod > 4 THEN (att.atttypmod - 4) & 65535 END,
+		tn.nspname,
+		t.typname,
+		col_description(c.oid, att.attnum)
+	FROM pg_attribute att
+	JOIN pg_class c ON c.oid = att.attrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	JOIN pg_type t ON t.oid = att.atttypid
+	JOIN pg_namespace tn ON tn.oid = t.typnamespace
+	WHERE c.relname = $1 AND n.nspname = $2 AND c.relkind = 'm' AND att.attnum > 0 AND NOT att.attisdropped
+	ORDER BY att.attnum
+`
+
+// queryColumns 查詢欄位定義；information_schema 沒有結果時（物化視圖）改用 pg_attribute
+func (a *DatabaseAnalyzer) queryColumns(ctx context.Context, informationSchemaQuery, tableName string) (*sql.Rows, error) {
+	schemaName, table := a.splitTableName(tableName)
+
+	var exists bool
+	if err := a.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = $1 AND table_schema = $2)`,
+		table, schemaName).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check columns: %w", err)
+	}
+	if exists {
+		return a.db.QueryContext(ctx, informationSchemaQuery, table, schemaName)
+	}
+	return a.db.QueryContext(ctx, matviewColumnsQuery, table, schemaName)
+}
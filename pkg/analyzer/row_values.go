@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"database/sql"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 資料庫欄位型別名稱（ColumnType.DatabaseTypeName，大寫，MySQL 的 UNSIGNED 前綴已去除）對應的 Go 型別
+var (
+	integerTypeNames = map[string]bool{
+		"INT": true, "INT2": true, "INT4": true, "INT8": true, "INTEGER": true,
+		"SMALLINT": true, "TINYINT": true, "MEDIUMINT": true, "BIGINT": true,
+		"SERIAL": true, "BIGSERIAL": true,
+	}
+	floatTypeNames = map[string]bool{
+		"NUMERIC": true, "DECIMAL": true, "FLOAT": true, "FLOAT4": true, "FLOAT8": true,
+		"DOUBLE": true, "REAL": true,
+	}
+	boolTypeNames = map[string]bool{"BOOL": true, "BOOLEAN": true}
+	timeTypeNames = map[string]bool{"DATE": true, "DATETIME": true, "TIMESTAMP": true, "TIMESTAMPTZ": true}
+)
+
+// timeLayouts 驅動程式以文字返回日期時間時嘗試的格式
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// ScanTypedRow 讀取目前這一行，欄位值依 columnTypes（rows.ColumnTypes()）轉為 int64、float64、bool 或 time.Time，
+// 讓 JSON 輸出保有數字與日期型別
+func ScanTypedRow(rows *sql.Rows, columns []string, columnTypes []*sql.ColumnType) (map[string]interface{}, error) {
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]interface{}, len(columns))
+	for i, col := range columns {
+		var columnType *sql.ColumnType
+		if i < len(columnTypes) {
+			columnType = columnTypes[i]
+		}
+		row[col] = TypedValue(values[i], columnType)
+	}
+	return row, nil
+}
+
+// TypedValue 將驅動程式返回的值轉為欄位型別對應的 Go 型別
+// 驅動程式以 []byte 返回的數字（如 PostgreSQL NUMERIC、MySQL 所有欄位）與日期依型別名稱解析，
+// 無法解析或型別不明時保留為字串
+func TypedValue(value interface{}, columnType *sql.ColumnType) interface{} {
+	raw, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+	text := string(raw)
+	if columnType == nil {
+		return text
+	}
+
+	typeName := strings.TrimPrefix(strings.ToUpper(columnType.DatabaseTypeName()), "UNSIGNED ")
+	switch {
+	case integerTypeNames[typeName]:
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(text, 10, 64); err == nil {
+			return n
+		}
+	case floatTypeNames[typeName]:
+		// NaN 與 Inf 無法以 JSON 數字表示
+		if f, err := strconv.ParseFloat(text, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	case boolTypeNames[typeName]:
+		switch text {
+		case "t", "true", "1":
+			return true
+		case "f", "false", "0":
+			return false
+		}
+	case timeTypeNames[typeName]:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, text); err == nil {
+				return t
+			}
+		}
+	}
+	return text
+}
@@ -0,0 +1,70 @@
+package analyzer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestScanTypedRow(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	// 以 BLOB 寫入，讓驅動程式像 MySQL / PostgreSQL NUMERIC 一樣以 []byte 返回
+	if _, err := db.Exec(`
+		CREATE TABLE typed (total BIGINT, price DOUBLE, active BOOLEAN, created DATE, note TEXT, raw BLOB);
+		INSERT INTO typed VALUES (CAST('42' AS BLOB), CAST('19.5' AS BLOB), CAST('t' AS BLOB), CAST('2024-03-01' AS BLOB), 'hello', CAST('opaque' AS BLOB))`); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT total, price, active, created, note, raw, COUNT(*) OVER () AS row_count FROM typed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatal("no rows")
+	}
+	row, err := ScanTypedRow(rows, columns, columnTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		column string
+		want   string
+	}{
+		{"total", "42"},
+		{"price", "19.5"},
+		{"active", "true"},
+		{"created", `"2024-03-01T00:00:00Z"`},
+		{"note", `"hello"`},
+		{"raw", `"opaque"`},
+		{"row_count", "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.column, func(t *testing.T) {
+			data, err := json.Marshal(row[tt.column])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(data); got != tt.want {
+				t.Errorf("%s = %s, want %s", tt.column, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// SamplingPolicy 依表格估計行數調整樣本與統計的成本
+type SamplingPolicy struct {
+	MaxSamples      int    // 一般表格的樣本數
+	Adaptive        bool   // 啟用依行數調整，關閉時所有表格都取 MaxSamples 筆
+	SmallTableRows  int64  // 行數不超過此值的表格全部取樣
+	LargeTableRows  int64  // 行數超過此值的表格使用 TABLESAMPLE，並以估計行數取代 COUNT(*)
+	LargeMaxSamples int    // 大表格的樣本數上限
+	SampleStrategy  string // 一般取樣的選取方式：latest（預設）、random、stratified
+}
+
+// 取樣策略
+const (
+	SamplingFull        = "full"        // 表格全部取樣
+	SamplingLimit       = "limit"       // 取最近（或前）N 筆
+	SamplingTableSample = "tablesample" // 以 TABLESAMPLE SYSTEM 隨機取樣
+)
+
+// samplingPlan 單一表格的實際取樣方式
+type samplingPlan struct {
+	Strategy      string
+	EstimatedRows int64 // -1 表示未知
+	RowsExact     bool  // EstimatedRows 來自 COUNT(*)
+	Samples       int
+}
+
+// EstimateRowCountContext 從 pg_class 取得估計行數，未曾 ANALYZE 的表格返回 -1
+// SQLite 沒有低成本的估計值，一律返回 -1，由呼叫端改用 COUNT(*)
+func (a *DatabaseAnalyzer) EstimateRowCountContext(ctx context.Context, tableName string) (int64, error) {
+	if a.isSQLite() {
+		return -1, nil
+	}
+
+	var estimate float64
+	query := `
+		SELECT c.reltuples
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relname = $1 AND n.nspname = $2 AND c.relkind IN ('r', 'm')
+	`
+	schemaName, table := a.splitTableName(tableName)
+	if err := a.db.QueryRowContext(ctx, query, table, schemaName).Scan(&estimate); err != nil {
+		return -1, err
+	}
+	if estimate < 0 {
+		return -1, nil
+	}
+	return int64(estimate), nil
+}
+
+// RowCountContext 返回表格行數：有估計值時使用估計值，否則執行 COUNT(*)
+func (a *DatabaseAnalyzer) RowCountContext(ctx context.Context, tableName string) (int64, error) {
+	if estimate, err := a.EstimateRowCountContext(ctx, tableName); err == nil && estimate >= 0 {
+		return estimate, nil
+	}
+
+	var rowCount int64
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)
+	if err := a.db.QueryRowContext(ctx, countQuery).Scan(&rowCount); err != nil {
+		return -1, err
+	}
+	return rowCount, nil
+}
+
+// planSampling 依估計行數決定取樣方式；估計不可用時改用 COUNT(*)
+func (a *DatabaseAnalyzer) planSampling(ctx context.Context, tableName string, policy SamplingPolicy) samplingPlan {
+	plan := samplingPlan{Strategy: SamplingLimit, EstimatedRows: -1, Samples: policy.MaxSamples}
+	if !policy.Adaptive {
+		return plan
+	}
+
+	if estimate, err := a.EstimateRowCountContext(ctx, tableName); err == nil && estimate > 0 {
+		plan.EstimatedRows = estimate
+	} else {
+		var rowCount int64
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)
+		if err := a.db.QueryRowContext(ctx, countQuery).Scan(&rowCount); err == nil {
+			plan.EstimatedRows = rowCount
+			plan.RowsExact = true
+		}
+	}
+
+	switch {
+	case plan.EstimatedRows < 0:
+		// 無法得知行數，維持一般取樣
+	case plan.EstimatedRows <= policy.SmallTableRows:
+		plan.Strategy = SamplingFull
+		plan.Samples = int(policy.SmallTableRows)
+	// SQLite 不支援 TABLESAMPLE，大表格維持一般取樣
+	case policy.LargeTableRows > 0 && plan.EstimatedRows > policy.LargeTableRows && !a.isSQLite():
+		plan.Strategy = SamplingTableSample
+		if policy.LargeMaxSamples > 0 {
+			plan.Samples = policy.LargeMaxSamples
+		}
+	}
+
+	return plan
+}
+
+// getTableSamplesTableSample 以 TABLESAMPLE SYSTEM 從大表格隨機取樣，避免排序掃描整個表格
+func (a *DatabaseAnalyzer) getTableSamplesTableSample(ctx context.Context, tableName string, plan samplingPlan) ([]map[string]interface{}, error) {
+	// 取樣比例預留 10 倍餘量，因為 SYSTEM 以資料頁為單位取樣
+	percent := float64(plan.Samples) * 10 / float64(plan.EstimatedRows) * 100
+	if percent < 0.0001 {
+		percent = 0.0001
+	}
+	if percent > 100 {
+		percent = 100
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s TABLESAMPLE SYSTEM (%f) LIMIT %d", tableName, percent, plan.Samples)
+	rows, err := a.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSampleRows(rows)
+}
+
+// samplingSummary 記錄實際取樣方式與信心程度，供後續啟發式判斷參考
+func (plan samplingPlan) summary(sampleCount int) map[string]interface{} {
+	summary := map[string]interface{}{
+		"strategy":          plan.Strategy,
+		"requested_samples": plan.Samples,
+		"sample_count":      sampleCount,
+		"confidence":        "unknown",
+	}
+	if plan.EstimatedRows < 0 {
+		return summary
+	}
+
+	summary["estimated_rows"] = plan.EstimatedRows
+	summary["row_count_exact"] = plan.RowsExact
+
+	ratio := 1.0
+	if plan.EstimatedRows > 0 {
+		ratio = float64(sampleCount) / float64(plan.EstimatedRows)
+		if ratio > 1 {
+			ratio = 1
+		}
+	}
+	summary["sample_ratio"] = ratio
+
+	switch {
+	case plan.Strategy == SamplingFull || ratio >= 0.5:
+		summary["confidence"] = "high"
+	case ratio >= 0.01:
+		summary["confidence"] = "medium"
+	default:
+		summary["confidence"] = "low"
+	}
+	return summary
+}
+
+// AnalyzeTableWithPolicy 依取樣策略分析單個表格，結果中的 sampling 欄位記錄實際取樣方式
+func (a *DatabaseAnalyzer) AnalyzeTableWithPolicy(ctx context.Context, tableName string, policy SamplingPolicy) (map[string]interface{}, error) {
+	plan := a.planSampling(ctx, tableName, policy)
+	sampleStrategy := NormalizeSampleStrategy(policy.SampleStrategy)
+
+	var sampleFn func(ctx context.Context, tableName string) ([]map[string]interface{}, error)
+	if plan.Strategy == SamplingTableSample {
+		sampleFn = func(ctx context.Context, tableName string) ([]map[string]interface{}, error) {
+			samples, err := a.getTableSamplesTableSample(ctx, tableName, plan)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Warning: TABLESAMPLE failed for table %s, falling back to LIMIT sampling: %v", tableName, err)
+				return a.GetTableSamplesContext(ctx, tableName, plan.Samples)
+			}
+			return samples, err
+		}
+	} else if plan.Strategy == SamplingLimit && sampleStrategy != SampleLatest {
+		sampleFn = func(ctx context.Context, tableName string) ([]map[string]interface{}, error) {
+			var samples []map[string]interface{}
+			var err error
+			if sampleStrategy == SampleRandom {
+				samples, err = a.getTableSamplesRandom(ctx, tableName, plan.Samples)
+			} else {
+				samples, err = a.getTableSamplesStratified(ctx, tableName, plan.Samples)
+			}
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Warning: %s sampling failed for table %s, falling back to latest rows: %v", sampleStrategy, tableName, err)
+				return a.GetTableSamplesContext(ctx, tableName, plan.Samples)
+			}
+			return samples, err
+		}
+	} else {
+		sampleFn = func(ctx context.Context, tableName string) ([]map[string]interface{}, error) {
+			return a.GetTableSamplesContext(ctx, tableName, plan.Samples)
+		}
+	}
+
+	// 大表格不執行 COUNT(*)，直接使用估計行數
+	var statsFn func(ctx context.Context, tableName string) (map[string]interface{}, error)
+	if plan.Strategy == SamplingTableSample || (plan.RowsExact && plan.EstimatedRows >= 0) {
+		statsFn = func(ctx context.Context, tableName string) (map[string]interface{}, error) {
+			stats := map[string]interface{}{
+				"row_count":           plan.EstimatedRows,
+				"row_count_estimated": !plan.RowsExact,
+			}
+			a.addTableSizeStats(ctx, tableName, stats)
+			return stats, nil
+		}
+	} else {
+		statsFn = a.GetTableStatsContext
+	}
+
+	result, err := a.analyzeTable(ctx, tableName, sampleFn, statsFn)
+	if err != nil {
+		return nil, err
+	}
+
+	// TABLESAMPLE 與 random 為隨機取樣，不依排序鍵
+	if plan.Strategy == SamplingTableSample || (plan.Strategy == SamplingLimit && sampleStrategy == SampleRandom) {
+		delete(result, "sample_sort_key")
+		delete(result, "sample_order_note")
+	}
+	if plan.Strategy == SamplingLimit && sampleStrategy != SampleLatest {
+		result["sample_strategy"] = sampleStrategy
+	}
+
+	if policy.Adaptive {
+		samples, _ := result["samples"].([]map[string]interface{})
+		result["sampling"] = plan.summary(len(samples))
+	}
+	return result, nil
+}
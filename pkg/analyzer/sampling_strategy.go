@@ -0,0 +1,181 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 樣本列的選取方式（schema.sampling_strategy）
+const (
+	SampleLatest     = "latest"     // 依時間戳或主鍵取最新的資料（預設）
+	SampleRandom     = "random"     // ORDER BY RANDOM() 隨機取樣
+	SampleStratified = "stratified" // 每個低基數欄位的每個值各取一筆，再補上最新資料
+)
+
+const (
+	// stratifiedMaxDistinct 欄位不同值數不超過此值才視為低基數欄位
+	stratifiedMaxDistinct = 20
+	// stratifiedProbeRows 判斷基數時最多掃描的行數，避免大表格全表 DISTINCT
+	stratifiedProbeRows = 10000
+	// stratifiedMaxColumns 最多分層的欄位數
+	stratifiedMaxColumns = 5
+)
+
+// NormalizeSampleStrategy 返回有效的樣本選取方式，空值或未知值使用 latest
+func NormalizeSampleStrategy(strategy string) string {
+	switch strings.ToLower(strings.TrimSpace(strategy)) {
+	case "", SampleLatest:
+		return SampleLatest
+	case SampleRandom:
+		return SampleRandom
+	case SampleStratified:
+		return SampleStratified
+	}
+	log.Printf("Warning: Unknown sampling strategy %q, using %s", strategy, SampleLatest)
+	return SampleLatest
+}
+
+// getTableSamplesRandom 隨機取樣；需要排序整個表格，大表格建議搭配 adaptive_sampling 改用 TABLESAMPLE
+func (a *DatabaseAnalyzer) getTableSamplesRandom(ctx context.Context, tableName string, maxSamples int) ([]map[string]interface{}, error) {
+	query := fmt.Sprintf("SELECT * FROM %s ORDER BY RANDOM() LIMIT %d", tableName, maxSamples)
+	rows, err := a.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSampleRows(rows)
+}
+
+// getTableSamplesStratified 分層取樣：每個低基數欄位以 DISTINCT 取得各個值的代表列，
+// 其餘名額（至少四分之一）以最新資料補滿，讓 Phase 2 前綴分析看到更完整的狀態值
+func (a *DatabaseAnalyzer) getTableSamplesStratified(ctx context.Context, tableName string, maxSamples int) ([]map[string]interface{}, error) {
+	schema, err := a.GetTableSchemaContext(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	var primaryKeys []string
+	if constraints, err := a.GetTableConstraintsContext(ctx, tableName); err == nil {
+		primaryKeys = PrimaryKeyColumns(constraints)
+	}
+
+	tail := maxSamples / 4
+	if tail < 1 {
+		tail = 1
+	}
+	budget := maxSamples - tail
+
+	var samples []map[string]interface{}
+	seen := make(map[string]bool)
+	add := func(rows []map[string]interface{}) {
+		for _, row := range rows {
+			if len(samples) >= maxSamples {
+				return
+			}
+			key := fmt.Sprint(row)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			samples = append(samples, row)
+		}
+	}
+
+	for _, column := range a.lowCardinalityColumns(ctx, tableName, schema, primaryKeys) {
+		if len(samples) >= budget {
+			break
+		}
+		rows, err := a.distinctValueRows(ctx, tableName, column, budget-len(samples))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			log.Printf("Warning: Stratified sampling failed for %s.%s: %v", tableName, column, err)
+			continue
+		}
+		add(rows)
+	}
+
+	// 最新資料可能與分層樣本重複，多取一些以補滿名額
+	latest, err := a.GetTableSamplesContext(ctx, tableName, maxSamples)
+	if err != nil {
+		return nil, err
+	}
+	add(latest)
+
+	return samples, nil
+}
+
+// lowCardinalityColumns 返回可分層的欄位：字串、布林與 ENUM 型別（不含主鍵），
+// 且在前 stratifiedProbeRows 行中的不同值不超過 stratifiedMaxDistinct 個
+func (a *DatabaseAnalyzer) lowCardinalityColumns(ctx context.Context, tableName string, schema []map[string]interface{}, primaryKeys []string) []string {
+	isPrimaryKey := make(map[string]bool, len(primaryKeys))
+	for _, pk := range primaryKeys {
+		isPrimaryKey[pk] = true
+	}
+
+	var columns []string
+	for _, col := range schema {
+		name, _ := col["name"].(string)
+		colType, _ := col["type"].(string)
+		if name == "" || isPrimaryKey[name] || !stratifiableType(colType, col) {
+			continue
+		}
+
+		var distinct int
+		query := fmt.Sprintf(
+			`SELECT COUNT(*) FROM (SELECT DISTINCT "%s" FROM (SELECT "%s" FROM %s LIMIT %d) probe) d`,
+			name, name, tableName, stratifiedProbeRows)
+		if err := a.db.QueryRowContext(ctx, query).Scan(&distinct); err != nil {
+			if ctx.Err() != nil {
+				return columns
+			}
+			log.Printf("Warning: Failed to probe cardinality of %s.%s: %v", tableName, name, err)
+			continue
+		}
+		if distinct > 1 && distinct <= stratifiedMaxDistinct {
+			columns = append(columns, name)
+			if len(columns) >= stratifiedMaxColumns {
+				break
+			}
+		}
+	}
+	return columns
+}
+
+// stratifiableType 判斷欄位型別是否適合分層；長文字欄位（max_length > 255）不列入
+func stratifiableType(colType string, col map[string]interface{}) bool {
+	if _, ok := col["enum_values"]; ok {
+		return true
+	}
+	lower := strings.ToLower(colType)
+	switch {
+	case strings.Contains(lower, "bool"):
+		return true
+	case strings.Contains(lower, "char"), lower == "text":
+		maxLength, _ := col["max_length"].(int64)
+		return maxLength <= 255
+	}
+	return false
+}
+
+// distinctValueRows 對欄位的每個不同值各取一筆代表列
+// PostgreSQL 使用 DISTINCT ON，SQLite 使用 GROUP BY（未聚合欄位取自群組中的任一列）
+func (a *DatabaseAnalyzer) distinctValueRows(ctx context.Context, tableName, column string, limit int) ([]map[string]interface{}, error) {
+	var query string
+	if a.isSQLite() {
+		query = fmt.Sprintf(`SELECT * FROM %s WHERE "%s" IS NOT NULL GROUP BY "%s" LIMIT %d`, tableName, column, column, limit)
+	} else {
+		query = fmt.Sprintf(`SELECT DISTINCT ON ("%s") * FROM %s WHERE "%s" IS NOT NULL ORDER BY "%s" LIMIT %d`, column, tableName, column, column, limit)
+	}
+
+	rows, err := a.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSampleRows(rows)
+}
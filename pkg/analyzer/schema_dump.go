@@ -0,0 +1,461 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SchemaDumpReader 從 CREATE TABLE 匯出檔（postgres/mysql）解析資料庫結構，無需連線資料庫
+type SchemaDumpReader struct {
+	tables    map[string]*dumpTable
+	order     []string
+	enumTypes map[string][]string // CREATE TYPE ... AS ENUM 定義的型別與值
+}
+
+// dumpTable 解析中的表格結構
+type dumpTable struct {
+	schema      []map[string]interface{}
+	primaryKeys []string
+	foreignKeys []map[string]interface{}
+	uniqueKeys  []map[string]interface{}
+	indexes     []map[string]interface{}
+}
+
+var (
+	createTablePattern = regexp.MustCompile(`(?is)^CREATE\s+(?:UNLOGGED\s+|TEMPORARY\s+|TEMP\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(]+)\s*\((.*)\)[^)]*$`)
+	alterTablePattern  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:ONLY\s+)?(?:IF\s+EXISTS\s+)?([^\s]+)\s+ADD\s+(.*)$`)
+	createIndexPattern = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([^\s]+)\s+ON\s+(?:ONLY\s+)?([^\s(]+)(?:\s+USING\s+\w+)?\s*\((.*)\)`)
+	referencesPattern  = regexp.MustCompile(`(?is)REFERENCES\s+([^\s(]+)\s*\(([^)]*)\)`)
+	columnListPattern  = regexp.MustCompile(`\(([^)]*)\)`)
+	typeSizePattern    = regexp.MustCompile(`^([a-zA-Z_ ]+?)\s*\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)`)
+	// MySQL 欄位定義中的 COMMENT '...' 與 PostgreSQL 的 COMMENT ON COLUMN 敘述
+	columnCommentPattern   = regexp.MustCompile(`(?is)\sCOMMENT\s+'((?:[^'\\]|''|\\.)*)'`)
+	commentOnColumnPattern = regexp.MustCompile(`(?is)^COMMENT\s+ON\s+COLUMN\s+(\S+)\s+IS\s+'((?:[^']|'')*)'\s*$`)
+)
+
+// NewSchemaDumpReader 讀取並解析 schema 匯出檔
+func NewSchemaDumpReader(filePath string) (*SchemaDumpReader, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema dump %s: %w", filePath, err)
+	}
+
+	reader := &SchemaDumpReader{tables: make(map[string]*dumpTable), enumTypes: make(map[string][]string)}
+	for _, statement := range splitSQLStatements(stripSQLComments(string(data))) {
+		reader.parseStatement(statement)
+	}
+
+	if len(reader.tables) == 0 {
+		return nil, fmt.Errorf("no CREATE TABLE statements found in %s", filePath)
+	}
+
+	return reader, nil
+}
+
+// GetAllTables 獲取匯出檔中的所有表格名稱（依出現順序）
+func (r *SchemaDumpReader) GetAllTables() []string {
+	return append([]string(nil), r.order...)
+}
+
+// AnalyzeTable 返回與 DatabaseAnalyzer.AnalyzeTable 相同格式的分析結果（樣本與統計為空）
+func (r *SchemaDumpReader) AnalyzeTable(tableName string) (map[string]interface{}, error) {
+	table, ok := r.tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("table %s not found in schema dump", tableName)
+	}
+
+	primaryKeys := table.primaryKeys
+	if primaryKeys == nil {
+		primaryKeys = []string{}
+	}
+
+	foreignKeys := table.foreignKeys
+	if foreignKeys == nil {
+		foreignKeys = []map[string]interface{}{}
+	}
+
+	uniqueKeys := table.uniqueKeys
+	if uniqueKeys == nil {
+		uniqueKeys = []map[string]interface{}{}
+	}
+
+	indexes := table.indexes
+	if indexes == nil {
+		indexes = []map[string]interface{}{}
+	}
+
+	// PostgreSQL 的 ENUM 型別可能在表格之後才定義，於此時對應
+	for _, column := range table.schema {
+		colType, _ := column["type"].(string)
+		if values, ok := r.enumTypes[normalizeIdentifier(colType)]; ok {
+			setEnumColumn(column, normalizeIdentifier(colType), values)
+		}
+	}
+
+	return map[string]interface{}{
+		"schema": table.schema,
+		"constraints": map[string]interface{}{
+			"primary_keys": primaryKeys,
+			"foreign_keys": foreignKeys,
+			"unique_keys":  uniqueKeys,
+		},
+		"indexes":         indexes,
+		"samples":         []map[string]interface{}{},
+		"stats":           map[string]interface{}{},
+		"analysis_source": "schema_dump",
+	}, nil
+}
+
+// parseStatement 解析單一 SQL 敘述
+func (r *SchemaDumpReader) parseStatement(statement string) {
+	if m := createTypeEnumPattern.FindStringSubmatch(statement); m != nil {
+		r.enumTypes[strings.ToLower(normalizeIdentifier(m[1]))] = parseQuotedList(m[2])
+		return
+	}
+
+	if m := createTablePattern.FindStringSubmatch(statement); m != nil {
+		tableName := normalizeIdentifier(m[1])
+		table := r.table(tableName)
+		for _, def := range splitTopLevel(m[2]) {
+			r.parseTableElement(tableName, table, def)
+		}
+		return
+	}
+
+	if m := commentOnColumnPattern.FindStringSubmatch(statement); m != nil {
+		r.setColumnComment(m[1], unquoteSQLString(m[2]))
+		return
+	}
+
+	if m := alterTablePattern.FindStringSubmatch(statement); m != nil {
+		tableName := normalizeIdentifier(m[1])
+		if _, ok := r.tables[tableName]; ok {
+			r.parseTableConstraint(tableName, r.tables[tableName], m[2])
+		}
+		return
+	}
+
+	if m := createIndexPattern.FindStringSubmatch(statement); m != nil {
+		tableName := normalizeIdentifier(m[3])
+		if table, ok := r.tables[tableName]; ok {
+			table.indexes = append(table.indexes, map[string]interface{}{
+				"name":       normalizeIdentifier(m[2]),
+				"definition": statement,
+				"is_unique":  strings.TrimSpace(m[1]) != "",
+				"columns":    splitIdentifierList(m[4]),
+			})
+		}
+	}
+}
+
+// table 取得或建立表格
+func (r *SchemaDumpReader) table(name string) *dumpTable {
+	table, ok := r.tables[name]
+	if !ok {
+		table = &dumpTable{}
+		r.tables[name] = table
+		r.order = append(r.order, name)
+	}
+	return table
+}
+
+// parseTableElement 解析 CREATE TABLE 內的欄位或表格約束定義
+func (r *SchemaDumpReader) parseTableElement(tableName string, table *dumpTable, def string) {
+	def = strings.TrimSpace(def)
+	if def == "" {
+		return
+	}
+
+	upper := strings.ToUpper(def)
+	for _, prefix := range []string{"CONSTRAINT ", "PRIMARY KEY", "FOREIGN KEY", "UNIQUE ", "UNIQUE(", "CHECK ", "CHECK(", "EXCLUDE "} {
+		if strings.HasPrefix(upper, prefix) {
+			r.parseTableConstraint(tableName, table, def)
+			return
+		}
+	}
+
+	// MySQL 的 KEY/INDEX 定義
+	for _, prefix := range []string{"KEY ", "INDEX ", "UNIQUE KEY ", "UNIQUE INDEX ", "FULLTEXT ", "SPATIAL "} {
+		if strings.HasPrefix(upper, prefix) {
+			if m := columnListPattern.FindStringSubmatch(def); m != nil {
+				fields := strings.Fields(def)
+				name := ""
+				if n := len(strings.Fields(prefix)); len(fields) > n {
+					name = normalizeIdentifier(strings.Split(fields[n], "(")[0])
+				}
+				table.indexes = append(table.indexes, map[string]interface{}{
+					"name":       name,
+					"definition": def,
+					"is_unique":  strings.HasPrefix(upper, "UNIQUE"),
+					"columns":    splitIdentifierList(m[1]),
+				})
+			}
+			return
+		}
+	}
+
+	r.parseColumn(table, def)
+}
+
+// parseColumn 解析欄位定義
+func (r *SchemaDumpReader) parseColumn(table *dumpTable, def string) {
+	fields := strings.Fields(def)
+	if len(fields) < 2 {
+		return
+	}
+
+	colName := normalizeIdentifier(fields[0])
+	rest := strings.TrimSpace(def[len(fields[0]):])
+	upperRest := strings.ToUpper(rest)
+
+	// 型別直到第一個約束關鍵字為止
+	typeEnd := len(rest)
+	for _, keyword := range []string{" NOT NULL", " NULL", " DEFAULT", " PRIMARY KEY", " REFERENCES", " UNIQUE", " CHECK", " COLLATE", " AUTO_INCREMENT", " COMMENT", " GENERATED", " CONSTRAINT", " ON UPDATE"} {
+		if idx := strings.Index(upperRest, keyword); idx >= 0 && idx < typeEnd {
+			typeEnd = idx
+		}
+	}
+	colType := strings.ToLower(strings.TrimSpace(rest[:typeEnd]))
+
+	column := map[string]interface{}{
+		"name":       colName,
+		"type":       colType,
+		"nullable":   !strings.Contains(upperRest, "NOT NULL") && !strings.Contains(upperRest, "PRIMARY KEY"),
+		"max_length": int64(0),
+		"precision":  int64(0),
+		"scale":      int64(0),
+	}
+
+	// 拆分型別長度/精度，與 information_schema 的表示方式一致
+	if values := ParseEnumValues(rest[:typeEnd]); values != nil {
+		setEnumColumn(column, "", values)
+	} else if m := typeSizePattern.FindStringSubmatch(colType); m != nil {
+		baseType := strings.TrimSpace(m[1])
+		size, _ := strconv.ParseInt(m[2], 10, 64)
+		column["type"] = baseType
+		if m[3] != "" || strings.Contains(baseType, "numeric") || strings.Contains(baseType, "decimal") {
+			scale, _ := strconv.ParseInt(m[3], 10, 64)
+			column["precision"] = size
+			column["scale"] = scale
+		} else {
+			column["max_length"] = size
+		}
+	}
+
+	if idx := strings.Index(upperRest, " DEFAULT "); idx >= 0 {
+		defaultValue := strings.TrimSpace(rest[idx+len(" DEFAULT "):])
+		for _, keyword := range []string{" NOT NULL", " NULL", " PRIMARY KEY", " REFERENCES", " UNIQUE", " CHECK", " COMMENT"} {
+			if end := strings.Index(strings.ToUpper(defaultValue), keyword); end >= 0 {
+				defaultValue = defaultValue[:end]
+			}
+		}
+		column["default"] = strings.TrimSpace(defaultValue)
+	}
+
+	if m := columnCommentPattern.FindStringSubmatch(rest); m != nil && m[1] != "" {
+		column["description"] = unquoteSQLString(m[1])
+	}
+
+	table.schema = append(table.schema, column)
+
+	if strings.Contains(upperRest, "PRIMARY KEY") {
+		table.primaryKeys = append(table.primaryKeys, colName)
+	}
+	if m := referencesPattern.FindStringSubmatch(rest); m != nil {
+		table.foreignKeys = append(table.foreignKeys, groupForeignKeys([]foreignKeyColumn{{
+			column:           colName,
+			referencedTable:  normalizeIdentifier(m[1]),
+			referencedColumn: normalizeIdentifier(strings.Split(m[2], ",")[0]),
+		}})...)
+	}
+}
+
+// setColumnComment 套用 COMMENT ON COLUMN [schema.]table.column 的欄位說明；表格須已定義
+func (r *SchemaDumpReader) setColumnComment(target, comment string) {
+	parts := strings.Split(target, ".")
+	if len(parts) < 2 || comment == "" {
+		return
+	}
+	table, ok := r.tables[normalizeIdentifier(parts[len(parts)-2])]
+	if !ok {
+		return
+	}
+	colName := normalizeIdentifier(parts[len(parts)-1])
+	for _, column := range table.schema {
+		if column["name"] == colName {
+			column["description"] = comment
+			return
+		}
+	}
+}
+
+// unquoteSQLString 還原單引號字串內容中的跳脫（” 與 MySQL 的反斜線跳脫）
+func unquoteSQLString(s string) string {
+	s = strings.ReplaceAll(s, "''", "'")
+	return strings.ReplaceAll(s, "\\'", "'")
+}
+
+// parseTableConstraint 解析表格層級的約束（PRIMARY KEY / FOREIGN KEY / UNIQUE）
+func (r *SchemaDumpReader) parseTableConstraint(tableName string, table *dumpTable, def string) {
+	def = strings.TrimSpace(def)
+	upper := strings.ToUpper(def)
+
+	constraintName := ""
+	if strings.HasPrefix(upper, "CONSTRAINT ") {
+		fields := strings.Fields(def)
+		if len(fields) < 3 {
+			return
+		}
+		constraintName = normalizeIdentifier(fields[1])
+		def = strings.TrimSpace(def[strings.Index(def, fields[1])+len(fields[1]):])
+		upper = strings.ToUpper(def)
+	}
+
+	switch {
+	case strings.HasPrefix(upper, "PRIMARY KEY"):
+		if m := columnListPattern.FindStringSubmatch(def); m != nil {
+			table.primaryKeys = append(table.primaryKeys, splitIdentifierList(m[1])...)
+		}
+	case strings.HasPrefix(upper, "FOREIGN KEY"):
+		cols := columnListPattern.FindStringSubmatch(def)
+		ref := referencesPattern.FindStringSubmatch(def)
+		if cols == nil || ref == nil {
+			return
+		}
+		localColumns := splitIdentifierList(cols[1])
+		refColumns := splitIdentifierList(ref[2])
+		fkColumns := make([]foreignKeyColumn, 0, len(localColumns))
+		for i, column := range localColumns {
+			refColumn := ""
+			if i < len(refColumns) {
+				refColumn = refColumns[i]
+			}
+			fkColumns = append(fkColumns, foreignKeyColumn{
+				constraintName:   constraintName,
+				column:           column,
+				referencedTable:  normalizeIdentifier(ref[1]),
+				referencedColumn: refColumn,
+			})
+		}
+		table.foreignKeys = append(table.foreignKeys, groupForeignKeys(fkColumns)...)
+	case strings.HasPrefix(upper, "UNIQUE"):
+		// MySQL: UNIQUE KEY `name` (cols)
+		if fields := strings.Fields(def); constraintName == "" && len(fields) > 2 {
+			if keyword := strings.ToUpper(fields[1]); keyword == "KEY" || keyword == "INDEX" {
+				constraintName = normalizeIdentifier(strings.Split(fields[2], "(")[0])
+			}
+		}
+		if m := columnListPattern.FindStringSubmatch(def); m != nil {
+			table.uniqueKeys = append(table.uniqueKeys, map[string]interface{}{
+				"constraint_name": constraintName,
+				"columns":         splitIdentifierList(m[1]),
+			})
+		}
+	}
+}
+
+// stripSQLComments 移除 -- 與 /* */ 註解
+func stripSQLComments(sql string) string {
+	var result strings.Builder
+	inString := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if c == '\'' {
+			inString = !inString
+		}
+		if !inString {
+			if c == '-' && i+1 < len(sql) && sql[i+1] == '-' {
+				for i < len(sql) && sql[i] != '\n' {
+					i++
+				}
+				result.WriteByte('\n')
+				continue
+			}
+			if c == '/' && i+1 < len(sql) && sql[i+1] == '*' {
+				end := strings.Index(sql[i+2:], "*/")
+				if end < 0 {
+					break
+				}
+				i += end + 3
+				continue
+			}
+		}
+		result.WriteByte(c)
+	}
+	return result.String()
+}
+
+// splitSQLStatements 以分號分割 SQL 敘述（忽略字串內的分號）
+func splitSQLStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+	inString := false
+	for _, c := range sql {
+		if c == '\'' {
+			inString = !inString
+		}
+		if c == ';' && !inString {
+			if statement := strings.TrimSpace(current.String()); statement != "" {
+				statements = append(statements, statement)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteRune(c)
+	}
+	if statement := strings.TrimSpace(current.String()); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+// splitTopLevel 以最外層的逗號分割（忽略括號與字串內的逗號）
+func splitTopLevel(s string) []string {
+	var parts []string
+	var current strings.Builder
+	depth := 0
+	inString := false
+	for _, c := range s {
+		switch {
+		case c == '\'':
+			inString = !inString
+		case inString:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteRune(c)
+	}
+	parts = append(parts, current.String())
+	return parts
+}
+
+// splitIdentifierList 分割以逗號分隔的欄位列表
+func splitIdentifierList(s string) []string {
+	var identifiers []string
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		identifiers = append(identifiers, normalizeIdentifier(fields[0]))
+	}
+	return identifiers
+}
+
+// normalizeIdentifier 移除引號及 schema 前綴（例如 public."users" -> users）
+func normalizeIdentifier(identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	if idx := strings.LastIndex(identifier, "."); idx >= 0 {
+		identifier = identifier[idx+1:]
+	}
+	return strings.Trim(identifier, "\"`[]")
+}
@@ -0,0 +1,67 @@
+package analyzer
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// defaultSchema 未配置 database.schemas 時掃描的 PostgreSQL schema
+const defaultSchema = "public"
+
+// NewDatabaseAnalyzerWithSchemas 依資料庫類型創建分析器，並指定要掃描的 PostgreSQL schema
+// schemas 為空時只掃描 public；掃描多個 schema 時表格名稱以 schema.table 表示
+func NewDatabaseAnalyzerWithSchemas(db *sql.DB, dbType string, schemas []string) *DatabaseAnalyzer {
+	a := NewDatabaseAnalyzerForType(db, dbType)
+	for _, schema := range schemas {
+		if schema = strings.TrimSpace(schema); schema != "" {
+			a.schemas = append(a.schemas, schema)
+		}
+	}
+	return a
+}
+
+// scanSchemas 返回要掃描的 schema 列表
+func (a *DatabaseAnalyzer) scanSchemas() []string {
+	if len(a.schemas) == 0 {
+		return []string{defaultSchema}
+	}
+	return a.schemas
+}
+
+// multiSchema 是否掃描多個 schema（此時輸出的表格名稱帶 schema 前綴）
+func (a *DatabaseAnalyzer) multiSchema() bool {
+	return len(a.scanSchemas()) > 1
+}
+
+// qualifyTableName 掃描多個 schema 時返回 schema.table，否則返回表格名稱
+func (a *DatabaseAnalyzer) qualifyTableName(schema, table string) string {
+	if a.multiSchema() {
+		return schema + "." + table
+	}
+	return table
+}
+
+// splitTableName 將表格名稱拆為 schema 與表格；前綴不是已配置的 schema 時視為未限定，使用第一個 schema
+func (a *DatabaseAnalyzer) splitTableName(name string) (string, string) {
+	if i := strings.Index(name, "."); i > 0 {
+		for _, schema := range a.scanSchemas() {
+			if name[:i] == schema {
+				return schema, name[i+1:]
+			}
+		}
+	}
+	return a.scanSchemas()[0], name
+}
+
+// schemaPlaceholders 產生 IN 子句使用的 $n 佔位符與對應參數，編號從 start 開始
+func (a *DatabaseAnalyzer) schemaPlaceholders(start int) (string, []interface{}) {
+	schemas := a.scanSchemas()
+	placeholders := make([]string, len(schemas))
+	args := make([]interface{}, len(schemas))
+	for i, schema := range schemas {
+		placeholders[i] = fmt.Sprintf("$%d", start+i)
+		args[i] = schema
+	}
+	return strings.Join(placeholders, ", "), args
+}
@@ -0,0 +1,292 @@
+package analyzer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// isSQLite 判斷分析器是否連接 SQLite 資料庫
+func (a *DatabaseAnalyzer) isSQLite() bool {
+	return a.dbType == "sqlite" || a.dbType == "sqlite3"
+}
+
+// sqliteGetAllTables 從 sqlite_master 取得使用者表格（排除 sqlite_ 內部表格）
+func (a *DatabaseAnalyzer) sqliteGetAllTables() ([]string, error) {
+	query := `
+		SELECT name
+		FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name
+	`
+
+	rows, err := a.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return nil, err
+		}
+		tables = append(tables, tableName)
+	}
+
+	return tables, rows.Err()
+}
+
+// sqliteColumn PRAGMA table_info 的一行
+type sqliteColumn struct {
+	name         string
+	dataType     string
+	notNull      bool
+	defaultValue sql.NullString
+	pk           int // 主鍵中的序號（從 1 開始），0 表示不是主鍵
+}
+
+// sqliteTableInfo 以 PRAGMA table_info 讀取表格欄位
+func (a *DatabaseAnalyzer) sqliteTableInfo(ctx context.Context, tableName string) ([]sqliteColumn, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid`, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []sqliteColumn
+	for rows.Next() {
+		var column sqliteColumn
+		if err := rows.Scan(&column.name, &column.dataType, &column.notNull, &column.defaultValue, &column.pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+
+	return columns, nil
+}
+
+// sqliteGetTableSchema 以 PRAGMA table_info 獲取表格 schema，型別參數（如 VARCHAR(255)、DECIMAL(10,2)）解析為長度與精度
+func (a *DatabaseAnalyzer) sqliteGetTableSchema(ctx context.Context, tableName string) ([]map[string]interface{}, error) {
+	columns, err := a.sqliteTableInfo(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema []map[string]interface{}
+	for _, col := range columns {
+		dataType, params := splitSQLiteType(col.dataType)
+		column := map[string]interface{}{
+			"name":       col.name,
+			"type":       dataType,
+			"nullable":   !col.notNull && col.pk == 0,
+			"max_length": int64(0),
+			"precision":  int64(0),
+			"scale":      int64(0),
+		}
+
+		switch {
+		case len(params) == 2:
+			column["precision"] = params[0]
+			column["scale"] = params[1]
+		case len(params) == 1 && strings.Contains(dataType, "char"):
+			column["max_length"] = params[0]
+		case len(params) == 1:
+			column["precision"] = params[0]
+		}
+
+		if col.defaultValue.Valid {
+			column["default"] = col.defaultValue.String
+		}
+
+		schema = append(schema, column)
+	}
+
+	return schema, nil
+}
+
+// splitSQLiteType 拆分宣告型別與括號內的數值參數，例如 "VARCHAR(255)" → "varchar", [255]
+func splitSQLiteType(declared string) (string, []int64) {
+	declared = strings.ToLower(strings.TrimSpace(declared))
+	start := strings.Index(declared, "(")
+	end := strings.LastIndex(declared, ")")
+	if start < 0 || end < start {
+		return declared, nil
+	}
+
+	var params []int64
+	for _, part := range strings.Split(declared[start+1:end], ",") {
+		var value int64
+		if _, err := fmt.Sscanf(strings.TrimSpace(part), "%d", &value); err == nil {
+			params = append(params, value)
+		}
+	}
+	return strings.TrimSpace(declared[:start]), params
+}
+
+// sqliteGetTableConstraints 獲取 SQLite 表格約束
+// 主鍵依 table_info 的 pk 序號排序（複合主鍵的每個欄位 pk > 0），外鍵來自 PRAGMA foreign_key_list，唯一鍵來自 UNIQUE 約束建立的索引
+func (a *DatabaseAnalyzer) sqliteGetTableConstraints(ctx context.Context, tableName string) (map[string]interface{}, error) {
+	constraints := map[string]interface{}{
+		"primary_keys": []string{},
+		"foreign_keys": []map[string]interface{}{},
+		"unique_keys":  []map[string]interface{}{},
+	}
+
+	// 獲取主鍵
+	if columns, err := a.sqliteTableInfo(ctx, tableName); err == nil {
+		var keyColumns []sqliteColumn
+		for _, col := range columns {
+			if col.pk > 0 {
+				keyColumns = append(keyColumns, col)
+			}
+		}
+		sort.Slice(keyColumns, func(i, j int) bool { return keyColumns[i].pk < keyColumns[j].pk })
+
+		var pks []string
+		for _, col := range keyColumns {
+			pks = append(pks, col.name)
+		}
+		constraints["primary_keys"] = pks
+	}
+
+	// 獲取外鍵
+	fkRows, err := a.db.QueryContext(ctx, `SELECT id, "table", "from", "to" FROM pragma_foreign_key_list(?) ORDER BY id, seq`, tableName)
+	if err == nil {
+		defer fkRows.Close()
+		var fkColumns []foreignKeyColumn
+		for fkRows.Next() {
+			var id int
+			var columnName, refTable string
+			var refColumn sql.NullString
+			if err := fkRows.Scan(&id, &refTable, &columnName, &refColumn); err == nil {
+				// 省略參照欄位時 SQLite 參照對方的主鍵
+				if !refColumn.Valid {
+					refColumn.String = "id"
+				}
+				fkColumns = append(fkColumns, foreignKeyColumn{
+					constraintName:   fmt.Sprintf("fk_%s_%d", tableName, id),
+					column:           columnName,
+					referencedTable:  refTable,
+					referencedColumn: refColumn.String,
+				})
+			}
+		}
+		constraints["foreign_keys"] = groupForeignKeys(fkColumns)
+	}
+
+	// 獲取唯一鍵
+	if indexes, err := a.sqliteIndexList(ctx, tableName); err == nil {
+		var uks []map[string]interface{}
+		for _, index := range indexes {
+			if index.origin != "u" {
+				continue
+			}
+			columns, err := a.sqliteIndexColumns(ctx, index.name)
+			if err != nil {
+				continue
+			}
+			uks = append(uks, map[string]interface{}{
+				"constraint_name": index.name,
+				"columns":         columns,
+			})
+		}
+		constraints["unique_keys"] = uks
+	}
+
+	return constraints, nil
+}
+
+// sqliteIndex PRAGMA index_list 的一行
+type sqliteIndex struct {
+	name   string
+	unique bool
+	origin string // c: CREATE INDEX，u: UNIQUE 約束，pk: PRIMARY KEY
+}
+
+// sqliteIndexList 以 PRAGMA index_list 讀取表格的索引
+func (a *DatabaseAnalyzer) sqliteIndexList(ctx context.Context, tableName string) ([]sqliteIndex, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT name, "unique", origin FROM pragma_index_list(?) ORDER BY name`, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []sqliteIndex
+	for rows.Next() {
+		var index sqliteIndex
+		if err := rows.Scan(&index.name, &index.unique, &index.origin); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+
+	return indexes, rows.Err()
+}
+
+// sqliteIndexColumns 以 PRAGMA index_info 依序讀取索引欄位
+func (a *DatabaseAnalyzer) sqliteIndexColumns(ctx context.Context, indexName string) ([]string, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT name FROM pragma_index_info(?) ORDER BY seqno`, indexName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name sql.NullString
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		// 表達式索引的欄位名稱為 NULL
+		if name.Valid {
+			columns = append(columns, name.String)
+		}
+	}
+
+	return columns, rows.Err()
+}
+
+// sqliteGetTableIndexes 以 PRAGMA index_list / index_info 獲取索引，definition 取自 sqlite_master（自動索引則依欄位組成）
+func (a *DatabaseAnalyzer) sqliteGetTableIndexes(ctx context.Context, tableName string) ([]map[string]interface{}, error) {
+	list, err := a.sqliteIndexList(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []map[string]interface{}
+	for _, entry := range list {
+		columns, err := a.sqliteIndexColumns(ctx, entry.name)
+		if err != nil {
+			continue
+		}
+
+		var definition sql.NullString
+		_ = a.db.QueryRowContext(ctx, `SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?`, entry.name).Scan(&definition)
+		if !definition.Valid {
+			unique := ""
+			if entry.unique {
+				unique = "UNIQUE "
+			}
+			definition.String = fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, entry.name, tableName, strings.Join(columns, ", "))
+		}
+
+		indexes = append(indexes, map[string]interface{}{
+			"name":       entry.name,
+			"definition": definition.String,
+			"is_unique":  entry.unique,
+			"columns":    columns,
+		})
+	}
+
+	return indexes, nil
+}
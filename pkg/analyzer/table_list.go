@@ -0,0 +1,86 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+)
+
+// TableSummary 表格概要，供瀏覽 schema 時使用
+type TableSummary struct {
+	Name          string `json:"name"`
+	EstimatedRows int64  `json:"estimated_rows"` // -1 表示未知（PostgreSQL 表格尚未 ANALYZE）
+	ColumnCount   int    `json:"column_count"`
+}
+
+// ListTableSummariesContext 返回所有表格的估計行數與欄位數（依表格名稱排序）
+// PostgreSQL 以單一 pg_class 查詢取得，不執行 COUNT(*)；SQLite 沒有估計值，逐表以 COUNT(*) 與 table_info 取得
+func (a *DatabaseAnalyzer) ListTableSummariesContext(ctx context.Context) ([]TableSummary, error) {
+	if a.isSQLite() {
+		return a.sqliteListTableSummaries(ctx)
+	}
+
+	placeholders, args := a.schemaPlaceholders(1)
+	query := fmt.Sprintf(`
+		SELECT n.nspname, c.relname, c.reltuples,
+			(SELECT COUNT(*) FROM pg_attribute att WHERE att.attrelid = c.oid AND att.attnum > 0 AND NOT att.attisdropped)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE %s AND n.nspname IN (%s)
+		ORDER BY n.nspname, c.relname
+	`, a.relationFilter(), placeholders)
+
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []TableSummary
+	for rows.Next() {
+		var schemaName, tableName string
+		var estimate float64
+		var columnCount int
+		if err := rows.Scan(&schemaName, &tableName, &estimate, &columnCount); err != nil {
+			return nil, err
+		}
+
+		estimatedRows := int64(estimate)
+		if estimate < 0 {
+			estimatedRows = -1
+		}
+		summaries = append(summaries, TableSummary{
+			Name:          a.qualifyTableName(schemaName, tableName),
+			EstimatedRows: estimatedRows,
+			ColumnCount:   columnCount,
+		})
+	}
+
+	return summaries, rows.Err()
+}
+
+// sqliteListTableSummaries 逐表取得 SQLite 表格的行數與欄位數
+func (a *DatabaseAnalyzer) sqliteListTableSummaries(ctx context.Context) ([]TableSummary, error) {
+	tables, err := a.sqliteGetAllTables()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]TableSummary, 0, len(tables))
+	for _, tableName := range tables {
+		columns, err := a.sqliteTableInfo(ctx, tableName)
+		if err != nil {
+			return nil, err
+		}
+		rowCount, err := a.RowCountContext(ctx, tableName)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, TableSummary{
+			Name:          tableName,
+			EstimatedRows: rowCount,
+			ColumnCount:   len(columns),
+		})
+	}
+
+	return summaries, nil
+}
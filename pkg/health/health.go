@@ -0,0 +1,106 @@
+// Package health 檢查資料庫、LLM 與嵌入生成器的實際連線狀態，供深度健康檢查 API 使用
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/masato25/aika-dba/pkg/llm"
+	"github.com/masato25/aika-dba/pkg/vectorstore"
+)
+
+// 整體與元件狀態
+const (
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"  // 資料庫正常，但 LLM 或嵌入生成器無法使用
+	StatusUnhealthy = "unhealthy" // 資料庫無法連線
+)
+
+// componentTimeout 單一元件檢查的逾時
+const componentTimeout = 5 * time.Second
+
+// Component 單一元件的檢查結果
+type Component struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report 深度健康檢查結果
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components"`
+	Time       time.Time            `json:"time"`
+}
+
+// Check 同時檢查資料庫（db.Ping）、LLM（列出模型）與嵌入生成器（一次短文字嵌入）
+// 資料庫失敗時整體為 unhealthy，其他元件失敗時為 degraded；km 為 nil 表示向量存儲未能開啟
+func Check(ctx context.Context, db *sql.DB, llmClient *llm.Client, km *vectorstore.KnowledgeManager) Report {
+	checks := map[string]func(ctx context.Context) error{
+		"database": db.PingContext,
+		"llm":      llmClient.Ping,
+		"embedder": func(ctx context.Context) error {
+			if km == nil {
+				return fmt.Errorf("vector store not available")
+			}
+			return withContext(ctx, km.PingEmbedder)
+		},
+	}
+
+	report := Report{Status: StatusHealthy, Components: make(map[string]Component, len(checks)), Time: time.Now()}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			component := run(ctx, check)
+			mu.Lock()
+			report.Components[name] = component
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	for name, component := range report.Components {
+		if component.Status == StatusHealthy {
+			continue
+		}
+		if name == "database" {
+			report.Status = StatusUnhealthy
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+// run 在逾時內執行一項檢查並記錄延遲
+func run(ctx context.Context, check func(ctx context.Context) error) Component {
+	ctx, cancel := context.WithTimeout(ctx, componentTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	component := Component{Status: StatusHealthy, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		component.Status = StatusUnhealthy
+		component.Error = err.Error()
+	}
+	return component
+}
+
+// withContext 讓不接受 context 的檢查在逾時後提前返回（背景中的呼叫會自行結束）
+func withContext(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+const (
+	// AnthropicVersion is sent as the anthropic-version header on every Messages API request
+	AnthropicVersion = "2023-06-01"
+	// anthropicMaxTokens is the max_tokens sent when the caller does not set one; the Messages API requires it
+	anthropicMaxTokens = 4096
+)
+
+// anthropicBaseURL returns the API base URL; llm.base_url overrides the default https://api.anthropic.com/v1
+func anthropicBaseURL(cfg *config.Config) string {
+	baseURL := cfg.LLM.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com/v1"
+	}
+	return strings.TrimRight(baseURL, "/")
+}
+
+// AnthropicMessagesURL returns the Messages API URL
+func AnthropicMessagesURL(cfg *config.Config) string {
+	return anthropicBaseURL(cfg) + "/messages"
+}
+
+// AnthropicEndpoint returns the host:port of the Messages API, used to label errors
+func AnthropicEndpoint(cfg *config.Config) string {
+	return endpointOf(AnthropicMessagesURL(cfg))
+}
+
+// AnthropicHeaders returns the authentication and version headers for the Messages API
+func AnthropicHeaders(cfg *config.Config) map[string]string {
+	return map[string]string{
+		"x-api-key":         cfg.LLM.APIKey,
+		"anthropic-version": AnthropicVersion,
+	}
+}
+
+// AnthropicRequestBody converts an OpenAI-style chat request (model, messages, temperature, max_tokens, top_p)
+// into a Messages API request: system messages move to the top-level system field and every
+// other message is wrapped in a text content block.
+func AnthropicRequestBody(chatRequest map[string]interface{}) map[string]interface{} {
+	body := map[string]interface{}{
+		"model":      chatRequest["model"],
+		"max_tokens": anthropicMaxTokens,
+	}
+	if maxTokens, ok := chatRequest["max_tokens"]; ok {
+		body["max_tokens"] = maxTokens
+	}
+	if temperature, ok := chatRequest["temperature"]; ok {
+		body["temperature"] = temperature
+	}
+	if topP, ok := chatRequest["top_p"]; ok {
+		body["top_p"] = topP
+	}
+	if stream, ok := chatRequest["stream"]; ok {
+		body["stream"] = stream
+	}
+
+	var systemParts []string
+	messages := []map[string]interface{}{}
+	for _, message := range chatMessages(chatRequest["messages"]) {
+		role, _ := message["role"].(string)
+		content := fmt.Sprintf("%v", message["content"])
+		if role == "system" {
+			systemParts = append(systemParts, content)
+			continue
+		}
+		messages = append(messages, map[string]interface{}{
+			"role": role,
+			"content": []map[string]interface{}{
+				{"type": "text", "text": content},
+			},
+		})
+	}
+	if len(systemParts) > 0 {
+		body["system"] = strings.Join(systemParts, "\n\n")
+	}
+	body["messages"] = messages
+	return body
+}
+
+// chatMessages accepts the message slice shapes used by callers in this repo
+func chatMessages(value interface{}) []map[string]interface{} {
+	switch messages := value.(type) {
+	case []map[string]interface{}:
+		return messages
+	case []map[string]string:
+		converted := make([]map[string]interface{}, 0, len(messages))
+		for _, message := range messages {
+			converted = append(converted, map[string]interface{}{"role": message["role"], "content": message["content"]})
+		}
+		return converted
+	case []interface{}:
+		converted := make([]map[string]interface{}, 0, len(messages))
+		for _, message := range messages {
+			if m, ok := message.(map[string]interface{}); ok {
+				converted = append(converted, m)
+			}
+		}
+		return converted
+	}
+	return nil
+}
+
+// AnthropicResponseText returns the concatenated text blocks of a Messages API response (content[].text)
+func AnthropicResponseText(response map[string]interface{}) (string, error) {
+	blocks, ok := response["content"].([]interface{})
+	if !ok || len(blocks) == 0 {
+		return "", fmt.Errorf("invalid response format: no content")
+	}
+
+	var builder strings.Builder
+	for _, block := range blocks {
+		blockMap, ok := block.(map[string]interface{})
+		if !ok || blockMap["type"] != "text" {
+			continue
+		}
+		text, _ := blockMap["text"].(string)
+		builder.WriteString(text)
+	}
+	if builder.Len() == 0 {
+		return "", fmt.Errorf("invalid response format: no text content")
+	}
+	return builder.String(), nil
+}
+
+// AnthropicUsage returns input and output token counts from a Messages API response
+func AnthropicUsage(response map[string]interface{}) (int, int) {
+	usage, _ := response["usage"].(map[string]interface{})
+	inputTokens, _ := usage["input_tokens"].(float64)
+	outputTokens, _ := usage["output_tokens"].(float64)
+	return int(inputTokens), int(outputTokens)
+}
+
+// streamAnthropicCompletion streams a completion from the Anthropic Messages API.
+// Text arrives in content_block_delta events and the stream is complete at message_stop.
+func (c *Client) streamAnthropicCompletion(ctx context.Context, prompt string, emit func(string) error) error {
+	url := AnthropicMessagesURL(c.config)
+	endpoint := AnthropicEndpoint(c.config)
+
+	resp, err := c.openStream(ctx, url, AnthropicRequestBody(c.chatRequestBody(prompt)), AnthropicHeaders(c.config))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return c.readAnthropicStream(resp.Body, endpoint, emit)
+}
+
+// readAnthropicStream parses the Messages API Server-Sent Events stream
+func (c *Client) readAnthropicStream(body io.Reader, endpoint string, emit func(string) error) error {
+	var inputTokens, outputTokens int
+	finished := false
+
+	scanner := newStreamScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Message struct {
+				Usage struct {
+					InputTokens int `json:"input_tokens"`
+				} `json:"usage"`
+			} `json:"message"`
+			Usage struct {
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return NewParseError(endpoint, err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			inputTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Text != "" {
+				if err := emit(event.Delta.Text); err != nil {
+					return NewRequestError(endpoint, err)
+				}
+			}
+		case "message_delta":
+			outputTokens = event.Usage.OutputTokens
+		case "message_stop":
+			finished = true
+		case "error":
+			return NewParseError(endpoint, fmt.Errorf("%s: %s", event.Error.Type, event.Error.Message))
+		}
+		if finished {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return NewRequestError(endpoint, err)
+	}
+
+	RecordUsage(c.phase, inputTokens, outputTokens)
+
+	if !finished {
+		return newIncompleteError(endpoint)
+	}
+	return nil
+}
@@ -1,11 +1,8 @@
 package llm
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 
@@ -16,6 +13,9 @@ import (
 type Client struct {
 	config     *config.Config
 	httpClient *http.Client
+	retry      RetryPolicy
+	phase      string // phase that token usage is recorded under
+	jsonMode   bool   // ask the server to return a single JSON object
 }
 
 // NewClient creates a new LLM client
@@ -25,184 +25,46 @@ func NewClient(cfg *config.Config) *Client {
 		httpClient: &http.Client{
 			Timeout: time.Duration(cfg.LLM.TimeoutSeconds) * time.Second,
 		},
+		retry: NewRetryPolicy(cfg),
 	}
 }
 
-// GenerateCompletion generates a completion using the LLM
-func (c *Client) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
-	switch c.config.LLM.Provider {
-	case "openai":
-		return c.generateOpenAICompletion(ctx, prompt)
-	case "local":
-		return c.generateLocalOpenAICompletion(ctx, prompt)
-	case "ollama":
-		return c.generateOllamaCompletion(ctx, prompt)
-	default:
-		return "", fmt.Errorf("unsupported LLM provider: %s", c.config.LLM.Provider)
-	}
+// WithPhase returns a copy of the client that records token usage under the given phase
+func (c *Client) WithPhase(phase string) *Client {
+	clone := *c
+	clone.phase = phase
+	return &clone
 }
 
-// generateOpenAICompletion generates a completion using OpenAI API
-func (c *Client) generateOpenAICompletion(ctx context.Context, prompt string) (string, error) {
-	requestBody := map[string]interface{}{
-		"model": c.config.LLM.Model,
-		"messages": []map[string]string{
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-		"temperature": 0.7,
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	baseURL := c.config.LLM.BaseURL
-	if baseURL == "" {
-		baseURL = "https://api.openai.com/v1"
-	}
-
-	url := fmt.Sprintf("%s/chat/completions", baseURL)
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.config.LLM.APIKey))
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var response struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
-	}
-
-	return response.Choices[0].Message.Content, nil
+// WithJSONMode returns a copy of the client that asks the server for a JSON object response:
+// response_format json_object for OpenAI-compatible servers and format "json" for Ollama.
+// Anthropic has no JSON mode, so the prompt alone has to ask for JSON there.
+func (c *Client) WithJSONMode() *Client {
+	clone := *c
+	clone.jsonMode = true
+	return &clone
 }
 
-// generateLocalOpenAICompletion generates a completion using local OpenAI-compatible API
-func (c *Client) generateLocalOpenAICompletion(ctx context.Context, prompt string) (string, error) {
-	requestBody := map[string]interface{}{
-		"model": c.config.LLM.Model,
-		"messages": []map[string]string{
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-		"temperature": 0.7,
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	baseURL := fmt.Sprintf("http://%s:%d", c.config.LLM.Host, c.config.LLM.Port)
-	url := fmt.Sprintf("%s/v1/chat/completions", baseURL)
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var response struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+// Endpoint returns the host:port of the configured LLM server, used to label errors
+func (c *Client) Endpoint() string {
+	switch c.config.LLM.Provider {
+	case "openai":
+		if c.config.LLM.BaseURL == "" {
+			return "api.openai.com"
+		}
+		return endpointOf(c.config.LLM.BaseURL)
+	case "anthropic":
+		return AnthropicEndpoint(c.config)
+	default:
+		return fmt.Sprintf("%s:%d", c.config.LLM.Host, c.config.LLM.Port)
 	}
-
-	return response.Choices[0].Message.Content, nil
 }
 
-// generateOllamaCompletion generates a completion using Ollama API
-func (c *Client) generateOllamaCompletion(ctx context.Context, prompt string) (string, error) {
-	requestBody := map[string]interface{}{
-		"model":  c.config.LLM.Model,
-		"prompt": prompt,
-		"stream": false,
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	url := fmt.Sprintf("http://%s:%d/api/generate", c.config.LLM.Host, c.config.LLM.Port)
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
+// GenerateCompletion generates a completion using the LLM by draining GenerateCompletionStream
+func (c *Client) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	text, err := CollectStream(c.GenerateCompletionStream(ctx, prompt))
 	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var response struct {
-		Response string `json:"response"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+		return "", err
 	}
-
-	return response.Response, nil
+	return text, nil
 }
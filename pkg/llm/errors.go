@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+)
+
+// ErrorKind classifies why an LLM call failed
+type ErrorKind string
+
+const (
+	ErrorKindTimeout           ErrorKind = "timeout"
+	ErrorKindConnectionRefused ErrorKind = "connection_refused"
+	ErrorKindStatus            ErrorKind = "http_status"
+	ErrorKindParse             ErrorKind = "parse"
+	ErrorKindRequest           ErrorKind = "request"
+	ErrorKindIncomplete        ErrorKind = "incomplete"
+)
+
+// ErrStreamIncomplete means a streamed response ended before the server signalled completion
+var ErrStreamIncomplete = errors.New("stream ended before completion")
+
+// LLMError describes a failed LLM call: the endpoint, what went wrong and the HTTP status if one was returned
+type LLMError struct {
+	Kind       ErrorKind
+	Endpoint   string // host:port of the LLM server
+	StatusCode int    // non-zero for ErrorKindStatus
+	Err        error
+}
+
+// Error implements error
+func (e *LLMError) Error() string {
+	switch e.Kind {
+	case ErrorKindTimeout:
+		return fmt.Sprintf("request to %s timed out: %v", e.Endpoint, e.Err)
+	case ErrorKindConnectionRefused:
+		return fmt.Sprintf("connection refused to %s", e.Endpoint)
+	case ErrorKindStatus:
+		return fmt.Sprintf("%s returned status %d: %v", e.Endpoint, e.StatusCode, e.Err)
+	case ErrorKindParse:
+		return fmt.Sprintf("failed to parse response from %s: %v", e.Endpoint, e.Err)
+	case ErrorKindIncomplete:
+		return fmt.Sprintf("response from %s was cut off: %v", e.Endpoint, e.Err)
+	default:
+		return fmt.Sprintf("request to %s failed: %v", e.Endpoint, e.Err)
+	}
+}
+
+// Unwrap returns the underlying error
+func (e *LLMError) Unwrap() error {
+	return e.Err
+}
+
+// NewRequestError classifies a transport error returned by http.Client.Do
+func NewRequestError(endpoint string, err error) *LLMError {
+	kind := ErrorKindRequest
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		kind = ErrorKindTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		kind = ErrorKindConnectionRefused
+	}
+	return &LLMError{Kind: kind, Endpoint: endpoint, Err: err}
+}
+
+// NewStatusError reports a non-200 response; body is included in the message
+func NewStatusError(endpoint string, statusCode int, body string) *LLMError {
+	return &LLMError{Kind: ErrorKindStatus, Endpoint: endpoint, StatusCode: statusCode, Err: errors.New(body)}
+}
+
+// NewParseError reports a response that could not be decoded or had no usable content
+func NewParseError(endpoint string, err error) *LLMError {
+	return &LLMError{Kind: ErrorKindParse, Endpoint: endpoint, Err: err}
+}
+
+// newIncompleteError reports a stream that ended without a finish signal
+func newIncompleteError(endpoint string) *LLMError {
+	return &LLMError{Kind: ErrorKindIncomplete, Endpoint: endpoint, Err: ErrStreamIncomplete}
+}
+
+// endpointOf returns the host:port part of a request URL for error messages
+func endpointOf(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return rawURL
+}
+
+// Status records whether an LLM-backed step actually used the LLM or fell back to defaults.
+// Runners write it to their output JSON under llm_status.
+type Status struct {
+	UsedFallback bool      `json:"used_fallback"`
+	Reason       string    `json:"reason,omitempty"`
+	Kind         ErrorKind `json:"kind,omitempty"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	// Fallbacks lists the items that used fallback output when a run makes many calls (e.g. Phase 2 tables)
+	Fallbacks []string `json:"fallbacks,omitempty"`
+}
+
+// OKStatus is the status of a step whose LLM call succeeded
+func OKStatus() *Status {
+	return &Status{}
+}
+
+// FallbackStatus builds the status of a step that fell back because of err
+func FallbackStatus(err error) *Status {
+	status := &Status{UsedFallback: true, Kind: ErrorKindRequest}
+	if err == nil {
+		return status
+	}
+
+	status.Reason = err.Error()
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		status.Kind = llmErr.Kind
+		status.StatusCode = llmErr.StatusCode
+	}
+	return status
+}
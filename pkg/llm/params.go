@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"github.com/masato25/aika-dba/config"
+)
+
+// DefaultTemperature is the sampling temperature used when llm.phase_params does not set one
+const DefaultTemperature = 0.7
+
+// DefaultParams returns sampling parameters with the given temperature and max_tokens (0 leaves it to the server)
+func DefaultParams(temperature float64, maxTokens int) config.LLMParams {
+	return config.LLMParams{Temperature: &temperature, MaxTokens: maxTokens}
+}
+
+// ApplyChatParams sets temperature, max_tokens and top_p on an OpenAI-style chat request.
+// AnthropicRequestBody carries the same fields over to the Messages API.
+func ApplyChatParams(body map[string]interface{}, params config.LLMParams) {
+	if params.Temperature != nil {
+		body["temperature"] = *params.Temperature
+	}
+	if params.MaxTokens > 0 {
+		body["max_tokens"] = params.MaxTokens
+	}
+	if params.TopP != nil {
+		body["top_p"] = *params.TopP
+	}
+}
+
+// params returns the sampling parameters for the client's phase
+func (c *Client) params() config.LLMParams {
+	return c.config.LLM.ParamsFor(c.phase, DefaultParams(DefaultTemperature, 0))
+}
+
+// ollamaOptions converts the phase's configured sampling parameters to Ollama generate options.
+// Only values set in llm.phase_params are sent, so Ollama keeps using the model's own defaults otherwise.
+func (c *Client) ollamaOptions() map[string]interface{} {
+	params := c.config.LLM.ParamsFor(c.phase, config.LLMParams{})
+	options := make(map[string]interface{})
+	if params.Temperature != nil {
+		options["temperature"] = *params.Temperature
+	}
+	if params.MaxTokens > 0 {
+		options["num_predict"] = params.MaxTokens
+	}
+	if params.TopP != nil {
+		options["top_p"] = *params.TopP
+	}
+	return options
+}
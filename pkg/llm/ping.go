@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Ping checks that the configured LLM server is reachable by listing its models.
+// The model-list endpoints cost no tokens; Ping is not retried so health checks stay fast.
+func (c *Client) Ping(ctx context.Context) error {
+	var url string
+	headers := map[string]string{}
+	switch c.config.LLM.Provider {
+	case "openai":
+		baseURL := c.config.LLM.BaseURL
+		if baseURL == "" {
+			baseURL = "https://api.openai.com/v1"
+		}
+		url = strings.TrimRight(baseURL, "/") + "/models"
+		headers["Authorization"] = fmt.Sprintf("Bearer %s", c.config.LLM.APIKey)
+	case "local":
+		url = fmt.Sprintf("http://%s:%d/v1/models", c.config.LLM.Host, c.config.LLM.Port)
+	case "ollama":
+		url = fmt.Sprintf("http://%s:%d/api/tags", c.config.LLM.Host, c.config.LLM.Port)
+	case "anthropic":
+		url = anthropicBaseURL(c.config) + "/models"
+		headers = AnthropicHeaders(c.config)
+	default:
+		return fmt.Errorf("unsupported LLM provider: %s", c.config.LLM.Provider)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	endpoint := endpointOf(url)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return NewRequestError(endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, retryStatusBodyLimit))
+		return NewStatusError(endpoint, resp.StatusCode, string(body))
+	}
+	return nil
+}
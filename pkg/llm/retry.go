@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+const (
+	defaultMaxRetries      = 3
+	defaultRetryBackoff    = 500 * time.Millisecond
+	maxRetryBackoff        = 30 * time.Second
+	retryStatusBodyLimit   = 1024
+	retryableStatusMinimum = http.StatusInternalServerError
+)
+
+// RetryPolicy controls how LLM requests are retried on connection errors and 5xx/429 responses
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration // base delay, doubled on every attempt
+}
+
+// NewRetryPolicy builds the retry policy from llm.max_retries and llm.retry_backoff_ms.
+// max_retries 0 means the default (3) and a negative value disables retries.
+func NewRetryPolicy(cfg *config.Config) RetryPolicy {
+	policy := RetryPolicy{MaxRetries: cfg.LLM.MaxRetries, Backoff: defaultRetryBackoff}
+	if policy.MaxRetries == 0 {
+		policy.MaxRetries = defaultMaxRetries
+	}
+	if policy.MaxRetries < 0 {
+		policy.MaxRetries = 0
+	}
+	if cfg.LLM.RetryBackoffMs > 0 {
+		policy.Backoff = time.Duration(cfg.LLM.RetryBackoffMs) * time.Millisecond
+	}
+	return policy
+}
+
+// Do sends the request built by newRequest and retries retryable failures with exponential backoff and jitter.
+// It returns the response only for 200 OK; other outcomes are returned as *LLMError.
+// A retry is skipped when the wait would run past the context deadline.
+func (p RetryPolicy) Do(ctx context.Context, client *http.Client, endpoint string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		var llmErr *LLMError
+		resp, err := client.Do(req)
+		if err != nil {
+			llmErr = NewRequestError(endpoint, err)
+		} else if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, retryStatusBodyLimit))
+			resp.Body.Close()
+			llmErr = NewStatusError(endpoint, resp.StatusCode, string(body))
+		} else {
+			return resp, nil
+		}
+
+		if attempt >= p.MaxRetries || !retryable(ctx, llmErr) {
+			return nil, llmErr
+		}
+
+		delay := p.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return nil, llmErr
+		}
+		log.Printf("Warning: LLM request failed (%v), retrying in %v (%d/%d)", llmErr, delay, attempt+1, p.MaxRetries)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, llmErr
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns the exponential backoff for the attempt plus up to 50% random jitter
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.Backoff << uint(attempt)
+	if delay <= 0 || delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// retryable reports whether a failed call is worth retrying: connection errors, 429 and 5xx.
+// Client errors such as 400/401/422, timeouts and a cancelled context are not retried.
+func retryable(ctx context.Context, err *LLMError) bool {
+	if ctx.Err() != nil || errors.Is(err.Err, context.Canceled) {
+		return false
+	}
+	switch err.Kind {
+	case ErrorKindConnectionRefused, ErrorKindRequest:
+		return true
+	case ErrorKindStatus:
+		return err.StatusCode == http.StatusTooManyRequests || err.StatusCode >= retryableStatusMinimum
+	default:
+		return false
+	}
+}
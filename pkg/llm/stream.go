@@ -0,0 +1,278 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxStreamLineBytes bounds a single SSE/NDJSON line so one oversized event cannot exhaust memory
+const maxStreamLineBytes = 1024 * 1024
+
+// GenerateCompletionStream streams a completion from the LLM.
+// Token deltas are sent on the first channel, which is closed when the stream ends.
+// The error channel then receives exactly one value: nil on success, otherwise the failure,
+// which wraps ErrStreamIncomplete if the server stopped before signalling completion.
+func (c *Client) GenerateCompletionStream(ctx context.Context, prompt string) (<-chan string, <-chan error) {
+	tokens := make(chan string)
+	errs := make(chan error, 1)
+
+	go func() {
+		emit := func(token string) error {
+			select {
+			case tokens <- token:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		var err error
+		switch c.config.LLM.Provider {
+		case "openai":
+			err = c.streamOpenAICompletion(ctx, prompt, emit)
+		case "local":
+			err = c.streamLocalOpenAICompletion(ctx, prompt, emit)
+		case "ollama":
+			err = c.streamOllamaCompletion(ctx, prompt, emit)
+		case "anthropic":
+			err = c.streamAnthropicCompletion(ctx, prompt, emit)
+		default:
+			err = fmt.Errorf("unsupported LLM provider: %s", c.config.LLM.Provider)
+		}
+
+		close(tokens)
+		errs <- err
+		close(errs)
+	}()
+
+	return tokens, errs
+}
+
+// CollectStream accumulates a completion stream. On failure the text received so far is
+// returned together with the error so callers can tell how far generation got.
+func CollectStream(tokens <-chan string, errs <-chan error) (string, error) {
+	var builder strings.Builder
+	for token := range tokens {
+		builder.WriteString(token)
+	}
+	return builder.String(), <-errs
+}
+
+// chatRequestBody builds a streaming chat completion request for OpenAI-compatible APIs
+func (c *Client) chatRequestBody(prompt string) map[string]interface{} {
+	body := map[string]interface{}{
+		"model": c.config.LLM.Model,
+		"messages": []map[string]string{
+			{
+				"role":    "user",
+				"content": prompt,
+			},
+		},
+		"stream": true,
+	}
+	ApplyChatParams(body, c.params())
+	if c.jsonMode {
+		body["response_format"] = map[string]interface{}{"type": "json_object"}
+	}
+	return body
+}
+
+// streamOpenAICompletion streams a completion from the OpenAI API
+func (c *Client) streamOpenAICompletion(ctx context.Context, prompt string, emit func(string) error) error {
+	baseURL := c.config.LLM.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	url := fmt.Sprintf("%s/chat/completions", baseURL)
+
+	// OpenAI only reports token usage in a stream when asked to
+	requestBody := c.chatRequestBody(prompt)
+	requestBody["stream_options"] = map[string]interface{}{"include_usage": true}
+
+	resp, err := c.openStream(ctx, url, requestBody, map[string]string{
+		"Authorization": fmt.Sprintf("Bearer %s", c.config.LLM.APIKey),
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return c.readChatStream(resp.Body, endpointOf(url), emit)
+}
+
+// streamLocalOpenAICompletion streams a completion from a local OpenAI-compatible API
+func (c *Client) streamLocalOpenAICompletion(ctx context.Context, prompt string, emit func(string) error) error {
+	baseURL := fmt.Sprintf("http://%s:%d", c.config.LLM.Host, c.config.LLM.Port)
+	url := fmt.Sprintf("%s/v1/chat/completions", baseURL)
+
+	resp, err := c.openStream(ctx, url, c.chatRequestBody(prompt), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return c.readChatStream(resp.Body, endpointOf(url), emit)
+}
+
+// streamOllamaCompletion streams a completion from the Ollama API, which sends one JSON object per line
+func (c *Client) streamOllamaCompletion(ctx context.Context, prompt string, emit func(string) error) error {
+	requestBody := map[string]interface{}{
+		"model":  c.config.LLM.Model,
+		"prompt": prompt,
+		"stream": true,
+	}
+	if options := c.ollamaOptions(); len(options) > 0 {
+		requestBody["options"] = options
+	}
+	if c.jsonMode {
+		requestBody["format"] = "json"
+	}
+
+	url := fmt.Sprintf("http://%s:%d/api/generate", c.config.LLM.Host, c.config.LLM.Port)
+	endpoint := endpointOf(url)
+
+	resp, err := c.openStream(ctx, url, requestBody, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := newStreamScanner(resp.Body)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk struct {
+			Response        string `json:"response"`
+			Done            bool   `json:"done"`
+			Error           string `json:"error"`
+			PromptEvalCount int    `json:"prompt_eval_count"`
+			EvalCount       int    `json:"eval_count"`
+		}
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return NewParseError(endpoint, err)
+		}
+		if chunk.Error != "" {
+			return NewParseError(endpoint, fmt.Errorf("%s", chunk.Error))
+		}
+		if chunk.Response != "" {
+			if err := emit(chunk.Response); err != nil {
+				return NewRequestError(endpoint, err)
+			}
+		}
+		if chunk.Done {
+			RecordUsage(c.phase, chunk.PromptEvalCount, chunk.EvalCount)
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return NewRequestError(endpoint, err)
+	}
+
+	return newIncompleteError(endpoint)
+}
+
+// openStream sends a streaming request and returns the response once the server accepted it.
+// Connection errors and 5xx/429 responses are retried according to llm.max_retries.
+func (c *Client) openStream(ctx context.Context, url string, requestBody map[string]interface{}, headers map[string]string) (*http.Response, error) {
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// 每次重試都需要新的 request body
+	return c.retry.Do(ctx, c.httpClient, endpointOf(url), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		return req, nil
+	})
+}
+
+// readChatStream parses Server-Sent Events from an OpenAI-compatible chat completion stream.
+// The stream is complete once "data: [DONE]" arrives or a choice reports a finish_reason.
+func (c *Client) readChatStream(body io.Reader, endpoint string, emit func(string) error) error {
+	var promptTokens, completionTokens int
+	gotChoice, finished := false, false
+
+	scanner := newStreamScanner(body)
+	for scanner.Scan() {
+		// Skip blank separators, comments (":") and event/id fields
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			finished = true
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return NewParseError(endpoint, err)
+		}
+
+		if chunk.Usage != nil {
+			promptTokens, completionTokens = chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens
+		}
+		for _, choice := range chunk.Choices {
+			gotChoice = true
+			if choice.Delta.Content != "" {
+				if err := emit(choice.Delta.Content); err != nil {
+					return NewRequestError(endpoint, err)
+				}
+			}
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finished = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return NewRequestError(endpoint, err)
+	}
+
+	RecordUsage(c.phase, promptTokens, completionTokens)
+
+	if !finished {
+		return newIncompleteError(endpoint)
+	}
+	if !gotChoice {
+		return NewParseError(endpoint, fmt.Errorf("no choices in response"))
+	}
+	return nil
+}
+
+// newStreamScanner returns a line scanner that accepts lines up to maxStreamLineBytes
+func newStreamScanner(body io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineBytes)
+	return scanner
+}
@@ -0,0 +1,168 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// UsagePath returns the per-phase token usage summary file shared across runs
+func UsagePath(cfg *config.Config) string {
+	return cfg.KnowledgePath("llm_usage.json")
+}
+
+// defaultUsagePhase is used when a client has not been assigned a phase
+const defaultUsagePhase = "general"
+
+// Usage summarizes token consumption and estimated cost
+type Usage struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	EstimatedCost    float64 `json:"estimated_cost"`
+}
+
+// add accumulates another usage record into u
+func (u *Usage) add(other Usage) {
+	u.Requests += other.Requests
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.EstimatedCost += other.EstimatedCost
+}
+
+// usageTracker accumulates usage per phase for the current process
+type usageTracker struct {
+	mu      sync.Mutex
+	byPhase map[string]*Usage
+}
+
+var tracker = &usageTracker{byPhase: make(map[string]*Usage)}
+
+// RecordUsage adds the token counts of one LLM response to the given phase
+func RecordUsage(phase string, promptTokens, completionTokens int) {
+	if phase == "" {
+		phase = defaultUsagePhase
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	u, ok := tracker.byPhase[phase]
+	if !ok {
+		u = &Usage{}
+		tracker.byPhase[phase] = u
+	}
+	u.add(Usage{
+		Requests:         1,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	})
+}
+
+// ResetPhaseUsage clears the accumulated usage of a phase before it runs again
+func ResetPhaseUsage(phase string) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	delete(tracker.byPhase, phase)
+}
+
+// PhaseUsage returns the usage accumulated for a phase in this process, with estimated cost
+func PhaseUsage(cfg *config.Config, phase string) Usage {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	var u Usage
+	if recorded, ok := tracker.byPhase[phase]; ok {
+		u = *recorded
+	}
+	u.EstimatedCost = EstimateCost(cfg, u)
+	return u
+}
+
+// TotalUsage returns the usage accumulated across all phases in this process
+func TotalUsage(cfg *config.Config) Usage {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	var total Usage
+	for _, u := range tracker.byPhase {
+		total.add(*u)
+	}
+	total.EstimatedCost = EstimateCost(cfg, total)
+	return total
+}
+
+// EstimateCost estimates cost from the configured per-1k-token prices
+func EstimateCost(cfg *config.Config, u Usage) float64 {
+	if cfg == nil {
+		return 0
+	}
+	return float64(u.PromptTokens)/1000*cfg.LLM.PromptPricePer1K +
+		float64(u.CompletionTokens)/1000*cfg.LLM.CompletionPricePer1K
+}
+
+// LoadUsage reads the persisted per-phase usage summary; a missing file yields an empty map
+func LoadUsage(cfg *config.Config) (map[string]Usage, error) {
+	usage := make(map[string]Usage)
+
+	data, err := os.ReadFile(UsagePath(cfg))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return usage, nil
+		}
+		return nil, fmt.Errorf("failed to read usage file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return nil, fmt.Errorf("failed to parse usage file: %w", err)
+	}
+	return usage, nil
+}
+
+// SavePhaseUsage persists the current usage of a phase, replacing its previous run
+func SavePhaseUsage(cfg *config.Config, phase string) (Usage, error) {
+	u := PhaseUsage(cfg, phase)
+
+	usage, err := LoadUsage(cfg)
+	if err != nil {
+		return u, err
+	}
+	usage[phase] = u
+
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return u, fmt.Errorf("failed to marshal usage: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(UsagePath(cfg)), 0755); err != nil {
+		return u, fmt.Errorf("failed to create usage directory: %w", err)
+	}
+	if err := os.WriteFile(UsagePath(cfg), data, 0644); err != nil {
+		return u, fmt.Errorf("failed to write usage file: %w", err)
+	}
+	return u, nil
+}
+
+// UsageReport summarizes persisted usage per phase plus the overall total
+func UsageReport(cfg *config.Config) (map[string]interface{}, error) {
+	usage, err := LoadUsage(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var total Usage
+	for _, u := range usage {
+		total.add(u)
+	}
+
+	return map[string]interface{}{
+		"phases": usage,
+		"total":  total,
+	}, nil
+}
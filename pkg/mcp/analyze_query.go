@@ -0,0 +1,201 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/llm"
+	"github.com/masato25/aika-dba/pkg/sqlguard"
+	"github.com/masato25/aika-dba/pkg/types"
+)
+
+// phase1AnalysisPath Phase 1 分析結果的位置，用於提供查詢解釋的 schema 背景
+func phase1AnalysisPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("phase1_analysis.json")
+}
+
+// sqlIdentifierPattern 用於從 SQL 中擷取可能的表格名稱
+var sqlIdentifierPattern = regexp.MustCompile("[A-Za-z_][A-Za-z0-9_]*")
+
+// analyzeQuery 對 SQL 執行 EXPLAIN，並由 LLM 根據 Phase 1 schema 知識解釋查詢內容與效能疑慮
+func (s *MCPServer) analyzeQuery(args map[string]interface{}) (interface{}, error) {
+	query, ok := args["query"].(string)
+	if !ok || strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+
+	explainOnly := false
+	if eo, ok := args["explain_only"].(bool); ok {
+		explainOnly = eo
+	}
+
+	log.Printf("Analyzing query: %s (explain_only: %v)", query, explainOnly)
+
+	// 與 executeQuery 相同，只允許 SELECT 查詢
+	if err := sqlguard.CheckQuery(s.config, query); err != nil {
+		return nil, err
+	}
+
+	ctx, release, err := s.acquireQuery()
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.explainQuery(ctx, query)
+	release()
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"query":         query,
+		"database_type": s.config.Database.Type,
+		"plan":          plan,
+	}
+
+	if explainOnly {
+		return result, nil
+	}
+
+	schemaContext, tables := s.querySchemaContext(query)
+	result["referenced_tables"] = tables
+
+	prompt := buildAnalyzeQueryPrompt(query, plan, schemaContext)
+	explanation, err := llm.NewClient(s.config).WithPhase("mcp").GenerateCompletion(context.Background(), prompt)
+	if err != nil {
+		// LLM 不可用時仍返回執行計劃
+		log.Printf("Warning: failed to generate query explanation: %v", err)
+		result["explanation_error"] = err.Error()
+		return result, nil
+	}
+	result["explanation"] = explanation
+
+	return result, nil
+}
+
+// explainQuery 依資料庫類型執行 EXPLAIN，將每一行計劃輸出合併為字串
+func (s *MCPServer) explainQuery(ctx context.Context, query string) ([]string, error) {
+	explainSQL := "EXPLAIN " + query
+	if s.config.Database.Type == "sqlite" || s.config.Database.Type == "sqlite3" {
+		explainSQL = "EXPLAIN QUERY PLAN " + query
+	}
+
+	rows, err := s.db.QueryContext(ctx, explainSQL)
+	if err != nil {
+		return nil, s.queryError(ctx, "failed to explain query", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %v", err)
+	}
+
+	var plan []string
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("failed to scan plan row: %v", err)
+		}
+
+		parts := make([]string, 0, len(columns))
+		for _, val := range values {
+			switch v := val.(type) {
+			case nil:
+				parts = append(parts, "NULL")
+			case []byte:
+				parts = append(parts, string(v))
+			default:
+				parts = append(parts, fmt.Sprintf("%v", v))
+			}
+		}
+		plan = append(plan, strings.Join(parts, " | "))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading plan rows: %v", err)
+	}
+
+	return plan, nil
+}
+
+// querySchemaContext 從 Phase 1 分析結果中找出查詢引用的表格，返回其欄位描述
+func (s *MCPServer) querySchemaContext(query string) (string, []string) {
+	data, err := os.ReadFile(phase1AnalysisPath(s.config))
+	if err != nil {
+		log.Printf("Warning: failed to read phase1 analysis for query context: %v", err)
+		return "", []string{}
+	}
+
+	var phase1 types.Phase1Result
+	if err := json.Unmarshal(data, &phase1); err != nil {
+		log.Printf("Warning: failed to parse phase1 analysis for query context: %v", err)
+		return "", []string{}
+	}
+
+	identifiers := make(map[string]bool)
+	for _, ident := range sqlIdentifierPattern.FindAllString(query, -1) {
+		identifiers[strings.ToLower(ident)] = true
+	}
+
+	tables := []string{}
+	for tableName := range phase1.Tables {
+		if identifiers[strings.ToLower(tableName)] {
+			tables = append(tables, tableName)
+		}
+	}
+	sort.Strings(tables)
+
+	var sb strings.Builder
+	for _, tableName := range tables {
+		table := phase1.Tables[tableName]
+		sb.WriteString(fmt.Sprintf("表格 %s", tableName))
+		if rowCount, ok := table.Stats["row_count"]; ok {
+			sb.WriteString(fmt.Sprintf("（約 %v 行）", rowCount))
+		}
+		sb.WriteString(":\n")
+		for _, col := range table.Schema {
+			sb.WriteString(fmt.Sprintf("- %v %v", col["name"], col["type"]))
+			if nullable, ok := col["nullable"]; ok {
+				sb.WriteString(fmt.Sprintf(" nullable=%v", nullable))
+			}
+			sb.WriteString("\n")
+		}
+		for _, index := range table.Indexes {
+			if name, ok := index["name"]; ok {
+				sb.WriteString(fmt.Sprintf("- 索引 %v: %v\n", name, index["columns"]))
+			}
+		}
+	}
+
+	return sb.String(), tables
+}
+
+// buildAnalyzeQueryPrompt 構建查詢解釋的提示
+func buildAnalyzeQueryPrompt(query string, plan []string, schemaContext string) string {
+	var sb strings.Builder
+	sb.WriteString("你是一位資深的資料庫管理員。請用淺白的語言解釋以下 SQL 查詢在做什麼，並根據執行計劃指出潛在的效能問題與改善建議。\n\n")
+	sb.WriteString("SQL 查詢:\n")
+	sb.WriteString(query)
+	sb.WriteString("\n\n執行計劃 (EXPLAIN):\n")
+	sb.WriteString(strings.Join(plan, "\n"))
+	sb.WriteString("\n\n")
+	if schemaContext != "" {
+		sb.WriteString("相關表格結構（來自 Phase 1 分析）:\n")
+		sb.WriteString(schemaContext)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("請以以下結構回答:\n1. 查詢目的\n2. 執行方式（依執行計劃說明）\n3. 潛在效能疑慮\n4. 改善建議（如索引或改寫方式）\n")
+	return sb.String()
+}
@@ -0,0 +1,113 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/masato25/aika-dba/pkg/types"
+)
+
+// dimensionCategories Phase 4 的維度分類，依報告中的順序排列
+var dimensionCategories = []string{"people", "time", "product", "event", "location"}
+
+// phase4DimensionModel phase4_dimensions.json 中的維度與事實表
+type phase4DimensionModel struct {
+	Database        string `json:"database"`
+	DatabaseType    string `json:"database_type"`
+	Timestamp       string `json:"timestamp"`
+	Classifications map[string]struct {
+		Description string            `json:"description"`
+		Dimensions  []types.Dimension `json:"dimensions"`
+	} `json:"classifications"`
+	FactTables []types.FactTable `json:"fact_tables"`
+}
+
+// getDimensionModel 直接讀取 phase4_dimensions.json，返回分類後的維度與事實表
+// category 不為空時只返回該分類的維度；include_ddl 為 true 時附上 Phase 5 產生的 DDL
+func (s *MCPServer) getDimensionModel(args map[string]interface{}) (interface{}, error) {
+	category := ""
+	if c, ok := args["category"].(string); ok {
+		category = strings.ToLower(strings.TrimSpace(c))
+	}
+	if category != "" && !validDimensionCategory(category) {
+		return nil, fmt.Errorf("invalid category %q (expected one of: %s)", category, strings.Join(dimensionCategories, ", "))
+	}
+
+	includeDDL := false
+	if d, ok := args["include_ddl"].(bool); ok {
+		includeDDL = d
+	}
+
+	log.Printf("Getting dimension model (category: %s, include_ddl: %v)", category, includeDDL)
+
+	data, err := os.ReadFile(s.config.KnowledgePath("phase4_dimensions.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read phase4_dimensions.json (run phase4 first): %v", err)
+	}
+	var model phase4DimensionModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("failed to parse phase4_dimensions.json: %v", err)
+	}
+
+	dimensions := make(map[string]interface{})
+	total := 0
+	for _, name := range dimensionCategories {
+		if category != "" && name != category {
+			continue
+		}
+		classification := model.Classifications[name]
+		list := classification.Dimensions
+		if list == nil {
+			list = []types.Dimension{}
+		}
+		dimensions[name] = map[string]interface{}{
+			"description": classification.Description,
+			"dimensions":  list,
+			"count":       len(list),
+		}
+		total += len(list)
+	}
+
+	factTables := model.FactTables
+	if factTables == nil {
+		factTables = []types.FactTable{}
+	}
+
+	result := map[string]interface{}{
+		"phase":            "phase4",
+		"database":         model.Database,
+		"database_type":    model.DatabaseType,
+		"generated_at":     model.Timestamp,
+		"dimensions":       dimensions,
+		"dimension_count":  total,
+		"fact_tables":      factTables,
+		"fact_table_count": len(factTables),
+	}
+	if category != "" {
+		result["category"] = category
+	}
+
+	if includeDDL {
+		ddl, err := os.ReadFile(s.config.KnowledgePath("phase5_ddl.sql"))
+		if err != nil {
+			result["ddl_error"] = "phase5_ddl.sql not found (run phase5 first)"
+		} else {
+			result["ddl"] = string(ddl)
+		}
+	}
+
+	return result, nil
+}
+
+// validDimensionCategory 判斷分類名稱是否為 Phase 4 的維度分類
+func validDimensionCategory(category string) bool {
+	for _, name := range dimensionCategories {
+		if name == category {
+			return true
+		}
+	}
+	return false
+}
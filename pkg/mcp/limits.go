@@ -0,0 +1,102 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/sqlguard"
+)
+
+// errServerBusy 同時執行的查詢已達 security.mcp_max_concurrent_queries 上限
+var errServerBusy = errors.New("server busy: too many concurrent queries, please retry later")
+
+// serverBusyCode 伺服器忙碌時返回的 JSON-RPC 錯誤碼（實作定義的伺服器錯誤範圍）
+const serverBusyCode = -32001
+
+// 未配置時的預設值
+const (
+	defaultMaxConcurrentQueries = 4
+	defaultQueryTimeout         = 30 * time.Second
+	defaultMaxQueryRows         = 1000
+)
+
+// newQuerySlots 依 security.mcp_max_concurrent_queries 建立查詢信號量，<= 0 時使用預設值
+func newQuerySlots(limit int) chan struct{} {
+	if limit <= 0 {
+		limit = defaultMaxConcurrentQueries
+	}
+	return make(chan struct{}, limit)
+}
+
+// acquireQuery 取得查詢名額並返回帶逾時的 context；名額已滿時立即返回 errServerBusy 而不等待
+// 呼叫端必須在查詢結束後呼叫返回的 release
+func (s *MCPServer) acquireQuery() (context.Context, func(), error) {
+	select {
+	case s.querySlots <- struct{}{}:
+	default:
+		return nil, nil, errServerBusy
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout())
+
+	release := func() {
+		cancel()
+		<-s.querySlots
+	}
+	return ctx, release, nil
+}
+
+// queryTimeout 單一查詢的逾時（security.max_query_time，未配置時 30 秒）
+func (s *MCPServer) queryTimeout() time.Duration {
+	if s.config.Security.MaxQueryTime > 0 {
+		return time.Duration(s.config.Security.MaxQueryTime) * time.Second
+	}
+	return defaultQueryTimeout
+}
+
+// queryError 將逾時轉為明確的錯誤訊息（如 "query exceeded 30s timeout"）
+func (s *MCPServer) queryError(ctx context.Context, action string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("query exceeded %v timeout (%s: %v)", s.queryTimeout(), action, err)
+	}
+	return fmt.Errorf("%s: %v", action, err)
+}
+
+// clampMaxRows 將 execute_query 的 max_rows 限制在 1 到 security.mcp_max_query_rows 之間（未配置時 1000）
+func (s *MCPServer) clampMaxRows(maxRows int) int {
+	maxLimit := s.config.Security.MCPMaxQueryRows
+	if maxLimit <= 0 {
+		maxLimit = defaultMaxQueryRows
+	}
+
+	if maxRows < 1 {
+		return 1
+	}
+	if maxRows > maxLimit {
+		log.Printf("Warning: requested max_rows %d exceeds max %d, clamping", maxRows, maxLimit)
+		return maxLimit
+	}
+	return maxRows
+}
+
+// limitQuery 為 SELECT/CTE 查詢加上 LIMIT maxRows+1，多取一行用於判斷結果是否被截斷
+// 查詢最外層沒有 LIMIT、OFFSET、FETCH 或 FOR（鎖定子句）時直接在結尾附加（換行後附加，結尾的 -- 註解不會吃掉它）；
+// 已有時 PostgreSQL 與 SQLite 包成子查詢，MySQL 的衍生表不接受重複的欄位名稱且可能忽略內層的 ORDER BY，
+// 因此維持原查詢，由讀取端在 maxRows+1 行後停止
+func limitQuery(cfg *config.Config, query string, maxRows int) string {
+	query = sqlguard.TrimTerminator(query)
+	if maxRows <= 0 || !sqlguard.IsSelectStatement(query) {
+		return query
+	}
+	if !sqlguard.HasTopLevelKeyword(cfg, query, "LIMIT", "OFFSET", "FETCH", "FOR") {
+		return fmt.Sprintf("%s\nLIMIT %d", query, maxRows+1)
+	}
+	if sqlguard.DialectFor(cfg) == sqlguard.DialectMySQL {
+		return query
+	}
+	return fmt.Sprintf("SELECT * FROM (\n%s\n) AS limited_query LIMIT %d", query, maxRows+1)
+}
@@ -0,0 +1,102 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+func TestLimitQuery(t *testing.T) {
+	wrapped := func(query string) string {
+		return "SELECT * FROM (\n" + query + "\n) AS limited_query LIMIT 3"
+	}
+	tests := []struct {
+		name   string
+		dbType string
+		query  string
+		want   string
+	}{
+		{"plain select", "postgres", "SELECT * FROM numbers", "SELECT * FROM numbers\nLIMIT 3"},
+		{"trailing semicolon", "postgres", "SELECT * FROM numbers;", "SELECT * FROM numbers\nLIMIT 3"},
+		{"trailing comment", "postgres", "SELECT * FROM numbers -- all rows", "SELECT * FROM numbers -- all rows\nLIMIT 3"},
+		{"cte", "postgres", "WITH x AS (SELECT 1) SELECT * FROM x", "WITH x AS (SELECT 1) SELECT * FROM x\nLIMIT 3"},
+		{"limit in subquery", "postgres", "SELECT * FROM (SELECT * FROM numbers LIMIT 10) s", "SELECT * FROM (SELECT * FROM numbers LIMIT 10) s\nLIMIT 3"},
+		{"limit in string", "postgres", "SELECT * FROM numbers WHERE note = 'no limit'", "SELECT * FROM numbers WHERE note = 'no limit'\nLIMIT 3"},
+		{"limit all", "postgres", "SELECT * FROM numbers LIMIT ALL", wrapped("SELECT * FROM numbers LIMIT ALL")},
+		{"fetch first", "postgres", "SELECT * FROM numbers FETCH FIRST 10 ROWS ONLY", wrapped("SELECT * FROM numbers FETCH FIRST 10 ROWS ONLY")},
+		{"sqlite offset", "sqlite", "SELECT * FROM numbers LIMIT 10 OFFSET 3", wrapped("SELECT * FROM numbers LIMIT 10 OFFSET 3")},
+		{"mysql plain select", "mysql", "SELECT a.id, b.id FROM a JOIN b ON a.id = b.a_id ORDER BY a.id", "SELECT a.id, b.id FROM a JOIN b ON a.id = b.a_id ORDER BY a.id\nLIMIT 3"},
+		// 衍生表中重複的 id 欄位會造成 MySQL 錯誤 1060，已有 LIMIT 時不包裝
+		{"mysql existing limit", "mysql", "SELECT a.id, b.id FROM a JOIN b ON a.id = b.a_id ORDER BY a.id LIMIT 10", "SELECT a.id, b.id FROM a JOIN b ON a.id = b.a_id ORDER BY a.id LIMIT 10"},
+		{"mysql hash comment", "mysql", "SELECT * FROM numbers # limit 5", "SELECT * FROM numbers # limit 5\nLIMIT 3"},
+		{"not a select", "postgres", "EXPLAIN SELECT 1", "EXPLAIN SELECT 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Database.Type = tt.dbType
+			if got := limitQuery(cfg, tt.query, 2); got != tt.want {
+				t.Errorf("limitQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteQueryLimitsRows(t *testing.T) {
+	s := newTestServer(t, &config.Config{})
+
+	tests := []struct {
+		name          string
+		query         string
+		maxRows       float64
+		wantRows      int
+		wantTruncated bool
+	}{
+		{"truncated", "SELECT n FROM numbers ORDER BY n", 2, 2, true},
+		{"trailing comment", "SELECT n FROM numbers ORDER BY n -- every row", 2, 2, true},
+		{"larger inner limit", "SELECT n FROM numbers ORDER BY n LIMIT 4", 2, 2, true},
+		{"limit with offset", "SELECT n FROM numbers ORDER BY n LIMIT 10 OFFSET 3", 5, 2, false},
+		{"fits", "SELECT n FROM numbers", 5, 5, false},
+		{"non-positive max_rows", "SELECT n FROM numbers", 0, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.executeQuery(map[string]interface{}{"query": tt.query, "max_rows": tt.maxRows})
+			if err != nil {
+				t.Fatal(err)
+			}
+			output := result.(map[string]interface{})
+			if got := output["row_count"]; got != tt.wantRows {
+				t.Errorf("row_count = %v, want %d", got, tt.wantRows)
+			}
+			if got := output["truncated"]; got != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", got, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestClampMaxRows(t *testing.T) {
+	tests := []struct {
+		configured int
+		requested  int
+		want       int
+	}{
+		{0, 100, 100},
+		{0, 5000, defaultMaxQueryRows},
+		{50, 100, 50},
+		{50, 10, 10},
+		{50, -1, 1},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{}
+		cfg.Security.MCPMaxQueryRows = tt.configured
+		s := &MCPServer{config: cfg}
+		if got := s.clampMaxRows(tt.requested); got != tt.want {
+			t.Errorf("clampMaxRows(%d) with mcp_max_query_rows %d = %d, want %d", tt.requested, tt.configured, got, tt.want)
+		}
+	}
+}
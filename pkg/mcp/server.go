@@ -4,13 +4,18 @@ import (
 	"bufio"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/masato25/aika-dba/config"
 	"github.com/masato25/aika-dba/pkg/analyzer"
+	"github.com/masato25/aika-dba/pkg/privacy"
+	"github.com/masato25/aika-dba/pkg/sqlguard"
 	"github.com/masato25/aika-dba/pkg/vectorstore"
 )
 
@@ -20,6 +25,7 @@ type MCPServer struct {
 	analyzer     *analyzer.DatabaseAnalyzer
 	knowledgeMgr *vectorstore.KnowledgeManager
 	config       *config.Config
+	querySlots   chan struct{} // 限制同時執行的資料庫查詢數量
 }
 
 // NewMCPServer 創建 MCP 服務器
@@ -31,9 +37,15 @@ func NewMCPServer(db *sql.DB) *MCPServer {
 		cfg = &config.Config{} // 使用默認配置
 	}
 
+	return NewMCPServerWithConfig(db, cfg)
+}
+
+// NewMCPServerWithConfig 使用已載入的配置創建 MCP 服務器，不讀取工作目錄下的 config.yaml
+func NewMCPServerWithConfig(db *sql.DB, cfg *config.Config) *MCPServer {
 	// 創建知識管理器
 	var knowledgeMgr *vectorstore.KnowledgeManager
 	if cfg.VectorStore.Enabled {
+		var err error
 		knowledgeMgr, err = vectorstore.NewKnowledgeManager(cfg)
 		if err != nil {
 			log.Printf("Warning: failed to create knowledge manager: %v", err)
@@ -42,9 +54,10 @@ func NewMCPServer(db *sql.DB) *MCPServer {
 
 	return &MCPServer{
 		db:           db,
-		analyzer:     analyzer.NewDatabaseAnalyzer(db),
+		analyzer:     analyzer.NewDatabaseAnalyzerWithSchemas(db, cfg.Database.Type, cfg.GetDatabaseSchemas()).WithCollapsePartitions(cfg.Schema.CollapsePartitions),
 		knowledgeMgr: knowledgeMgr,
 		config:       cfg,
+		querySlots:   newQuerySlots(cfg.Security.MCPMaxConcurrentQueries),
 	}
 }
 
@@ -52,6 +65,10 @@ func NewMCPServer(db *sql.DB) *MCPServer {
 func (s *MCPServer) Start() error {
 	log.Println("Starting MCP Server...")
 
+	// 每個請求在獨立的 goroutine 中處理，長時間查詢不會阻塞其他請求；回應依 id 對應，輸出需串行
+	var outputMu sync.Mutex
+	var wg sync.WaitGroup
+
 	scanner := bufio.NewScanner(os.Stdin)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -59,17 +76,25 @@ func (s *MCPServer) Start() error {
 			continue
 		}
 
-		// 處理請求
-		response, err := s.handleRequest(line)
-		if err != nil {
-			log.Printf("Error handling request: %v", err)
-			continue
-		}
+		wg.Add(1)
+		go func(line string) {
+			defer wg.Done()
+
+			// 處理請求
+			response, err := s.handleRequest(line)
+			if err != nil {
+				log.Printf("Error handling request: %v", err)
+				return
+			}
 
-		// 發送回應
-		fmt.Println(response)
+			// 發送回應
+			outputMu.Lock()
+			fmt.Println(response)
+			outputMu.Unlock()
+		}(line)
 	}
 
+	wg.Wait()
 	return scanner.Err()
 }
 
@@ -131,6 +156,26 @@ func (s *MCPServer) handleInitialize(req map[string]interface{}) (string, error)
 // handleToolsList 處理工具列表請求
 func (s *MCPServer) handleToolsList(req map[string]interface{}) (string, error) {
 	tools := []map[string]interface{}{
+		{
+			"name":        "database_list_tables",
+			"description": "列出所有資料表及其估計行數與欄位數，用於在查看特定資料表前瀏覽 schema",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pattern": map[string]interface{}{
+						"type":        "string",
+						"description": "只返回名稱包含此字串的資料表（不分大小寫）",
+						"default":     "",
+					},
+					"sort_by": map[string]interface{}{
+						"type":        "string",
+						"description": "排序方式：name（名稱，預設）或 rows（估計行數由多到少）",
+						"enum":        []string{"name", "rows"},
+						"default":     "name",
+					},
+				},
+			},
+		},
 		{
 			"name":        "database_get_table_schema",
 			"description": "獲取特定資料表的所有資訊，包括 schema、constraints、indexes 和樣本數據",
@@ -141,6 +186,16 @@ func (s *MCPServer) handleToolsList(req map[string]interface{}) (string, error)
 						"type":        "string",
 						"description": "資料表名稱",
 					},
+					"include_samples": map[string]interface{}{
+						"type":        "boolean",
+						"description": "是否返回樣本數據（PII 欄位依配置遮罩），預設 true",
+						"default":     true,
+					},
+					"sample_limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "樣本數量，預設 10，上限由伺服器配置 security.max_sample_limit 決定",
+						"default":     10,
+					},
 				},
 				"required": []string{"table_name"},
 			},
@@ -157,13 +212,32 @@ func (s *MCPServer) handleToolsList(req map[string]interface{}) (string, error)
 					},
 					"max_rows": map[string]interface{}{
 						"type":        "integer",
-						"description": "最大返回行數，預設 100",
+						"description": "最大返回行數，預設 100，上限為 security.mcp_max_query_rows",
 						"default":     100,
 					},
 				},
 				"required": []string{"query"},
 			},
 		},
+		{
+			"name":        "database_analyze_query",
+			"description": "對 SQL 查詢執行 EXPLAIN，返回執行計劃以及根據 Phase 1 架構知識產生的白話解釋與效能疑慮",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "要分析的 SQL 查詢",
+					},
+					"explain_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "只返回執行計劃，不產生 LLM 解釋，預設 false",
+						"default":     false,
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
 		{
 			"name":        "database_get_table_samples",
 			"description": "獲取特定資料表的更多樣本數據",
@@ -245,6 +319,26 @@ func (s *MCPServer) handleToolsList(req map[string]interface{}) (string, error)
 				},
 			},
 		},
+		{
+			"name":        "analysis_get_dimension_model",
+			"description": "直接讀取 Phase 4 的維度建模結果，以結構化 JSON 返回分類後的維度（people/time/product/event/location）與事實表",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"category": map[string]interface{}{
+						"type":        "string",
+						"description": "只返回此分類的維度，留空返回全部分類",
+						"enum":        []string{"", "people", "time", "product", "event", "location"},
+						"default":     "",
+					},
+					"include_ddl": map[string]interface{}{
+						"type":        "boolean",
+						"description": "附上 Phase 5 產生的星形模式 DDL，預設 false",
+						"default":     false,
+					},
+				},
+			},
+		},
 		{
 			"name":        "knowledge_get_statistics",
 			"description": "獲取知識庫統計信息，包括各 phase 的知識塊數量",
@@ -291,10 +385,14 @@ func (s *MCPServer) handleToolsCall(req map[string]interface{}) (string, error)
 	var err error
 
 	switch toolName {
+	case "database_list_tables":
+		result, err = s.listTables(toolArgs)
 	case "database_get_table_schema":
 		result, err = s.getTableInfo(toolArgs)
 	case "database_execute_sql_query":
 		result, err = s.executeQuery(toolArgs)
+	case "database_analyze_query":
+		result, err = s.analyzeQuery(toolArgs)
 	case "database_get_table_samples":
 		result, err = s.getMoreSamples(toolArgs)
 	case "analysis_get_schema_analysis":
@@ -303,20 +401,30 @@ func (s *MCPServer) handleToolsCall(req map[string]interface{}) (string, error)
 		result, err = s.getBusinessLogicAnalysis(toolArgs)
 	case "analysis_get_business_overview":
 		result, err = s.getComprehensiveBusinessOverview(toolArgs)
+	case "analysis_get_dimension_model":
+		result, err = s.getDimensionModel(toolArgs)
 	case "knowledge_get_statistics":
 		result, err = s.getKnowledgeStats(toolArgs)
 	default:
 		return s.createErrorResponse(req, -32601, "Tool not found")
 	}
 
+	if errors.Is(err, errServerBusy) {
+		return s.createErrorResponse(req, serverBusyCode, err.Error())
+	}
 	if err != nil {
 		return s.createErrorResponse(req, -32000, err.Error())
 	}
 
+	content, err := toolResultContent(result)
+	if err != nil {
+		return s.createErrorResponse(req, -32603, fmt.Sprintf("failed to encode tool result: %v", err))
+	}
+
 	response := map[string]interface{}{
 		"jsonrpc": "2.0",
 		"id":      req["id"],
-		"result":  result,
+		"result":  content,
 	}
 
 	jsonData, err := json.Marshal(response)
@@ -326,14 +434,49 @@ func (s *MCPServer) handleToolsCall(req map[string]interface{}) (string, error)
 	return string(jsonData), nil
 }
 
+// toolResultContent 依 MCP 規範將工具輸出包裝為 content 陣列
+// 輸出以 JSON 文字放在 text 類型的項目中，資料內容與原始結果相同
+func toolResultContent(result interface{}) (map[string]interface{}, error) {
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"content": []map[string]interface{}{
+			{
+				"type": "text",
+				"text": string(jsonData),
+			},
+		},
+		"isError": false,
+	}, nil
+}
+
 // getTableInfo 獲取資料表資訊
 func (s *MCPServer) getTableInfo(args map[string]interface{}) (interface{}, error) {
 	tableName, ok := args["table_name"].(string)
 	if !ok {
 		return nil, fmt.Errorf("table_name is required")
 	}
+	if !s.config.Schema.TableAllowed(tableName) {
+		return nil, fmt.Errorf("table %s is excluded by schema.include_patterns/exclude_patterns", tableName)
+	}
 
-	log.Printf("Getting info for table: %s", tableName)
+	includeSamples := true
+	if is, ok := args["include_samples"].(bool); ok {
+		includeSamples = is
+	}
+
+	sampleLimit := 10 // 預設 10 個樣本
+	if sl, ok := args["sample_limit"]; ok {
+		if sampleLimitFloat, ok := sl.(float64); ok {
+			sampleLimit = int(sampleLimitFloat)
+		}
+	}
+	sampleLimit = s.clampSampleLimit(sampleLimit)
+
+	log.Printf("Getting info for table: %s (include_samples: %v)", tableName, includeSamples)
 
 	// 獲取 schema
 	schema, err := s.analyzer.GetTableSchema(tableName)
@@ -355,11 +498,16 @@ func (s *MCPServer) getTableInfo(args map[string]interface{}) (interface{}, erro
 		indexes = []map[string]interface{}{}
 	}
 
-	// 獲取樣本數據
-	samples, err := s.analyzer.GetTableSamples(tableName, 10) // 預設 10 個樣本
-	if err != nil {
-		log.Printf("Warning: failed to get samples: %v", err)
-		samples = []map[string]interface{}{}
+	// 獲取樣本數據，並依 security.sample_masking 遮罩 PII 欄位
+	samples := []map[string]interface{}{}
+	var maskedColumns []string
+	if includeSamples {
+		samples, err = s.analyzer.GetTableSamples(tableName, sampleLimit)
+		if err != nil {
+			log.Printf("Warning: failed to get samples: %v", err)
+			samples = []map[string]interface{}{}
+		}
+		samples, maskedColumns = privacy.MaskSamples(s.config.Security.SampleMasking, tableName, schema, samples)
 	}
 
 	// 獲取統計信息
@@ -369,14 +517,19 @@ func (s *MCPServer) getTableInfo(args map[string]interface{}) (interface{}, erro
 		stats = map[string]interface{}{}
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"table_name":  tableName,
 		"schema":      schema,
 		"constraints": constraints,
 		"indexes":     indexes,
 		"samples":     samples,
 		"stats":       stats,
-	}, nil
+	}
+	if len(maskedColumns) > 0 {
+		result["masked_columns"] = maskedColumns
+	}
+
+	return result, nil
 }
 
 // executeQuery 執行自定義查詢
@@ -392,17 +545,25 @@ func (s *MCPServer) executeQuery(args map[string]interface{}) (interface{}, erro
 			maxRows = int(maxRowsFloat)
 		}
 	}
+	maxRows = s.clampMaxRows(maxRows)
 
 	log.Printf("Executing query: %s (max_rows: %d)", query, maxRows)
 
-	// 檢查是否為 SELECT 查詢（為了安全）
-	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "SELECT") {
-		return nil, fmt.Errorf("only SELECT queries are allowed")
+	// 檢查是否為 SELECT 查詢（為了安全，安全模式下規則更嚴格）
+	if err := sqlguard.CheckQuery(s.config, query); err != nil {
+		return nil, err
+	}
+
+	ctx, release, err := s.acquireQuery()
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
-	rows, err := s.db.Query(query)
+	// 在 SQL 層限制行數，避免資料庫產生整個結果集
+	rows, err := s.db.QueryContext(ctx, limitQuery(s.config, query, maxRows))
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %v", err)
+		return nil, s.queryError(ctx, "failed to execute query", err)
 	}
 	defer rows.Close()
 
@@ -411,42 +572,34 @@ func (s *MCPServer) executeQuery(args map[string]interface{}) (interface{}, erro
 	if err != nil {
 		return nil, fmt.Errorf("failed to get columns: %v", err)
 	}
+	// 欄位型別用於將數字與日期轉為對應的 Go 型別，而非字串
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column types: %v", err)
+	}
 
 	// 讀取數據
 	var results []map[string]interface{}
 	count := 0
-	for rows.Next() && count < maxRows {
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
+	truncated := false
+	for rows.Next() {
+		// 超過 max_rows 的第一行只用於標記截斷
+		if count >= maxRows {
+			truncated = true
+			break
 		}
 
-		if err := rows.Scan(valuePtrs...); err != nil {
+		row, err := analyzer.ScanTypedRow(rows, columns, columnTypes)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %v", err)
 		}
 
-		row := make(map[string]interface{})
-		for i, col := range columns {
-			val := values[i]
-			if val != nil {
-				switch v := val.(type) {
-				case []byte:
-					row[col] = string(v)
-				default:
-					row[col] = v
-				}
-			} else {
-				row[col] = nil
-			}
-		}
-
 		results = append(results, row)
 		count++
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error reading rows: %v", err)
+		return nil, s.queryError(ctx, "error reading rows", err)
 	}
 
 	return map[string]interface{}{
@@ -454,7 +607,55 @@ func (s *MCPServer) executeQuery(args map[string]interface{}) (interface{}, erro
 		"columns":   columns,
 		"rows":      results,
 		"row_count": len(results),
-		"truncated": count >= maxRows,
+		"truncated": truncated,
+	}, nil
+}
+
+// listTables 列出所有資料表的估計行數與欄位數，可依名稱子字串過濾並依名稱或行數排序
+func (s *MCPServer) listTables(args map[string]interface{}) (interface{}, error) {
+	pattern, _ := args["pattern"].(string)
+	sortBy, _ := args["sort_by"].(string)
+	if sortBy == "" {
+		sortBy = "name"
+	}
+	if sortBy != "name" && sortBy != "rows" {
+		return nil, fmt.Errorf("invalid sort_by %q (supported: name, rows)", sortBy)
+	}
+
+	ctx, release, err := s.acquireQuery()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	summaries, err := s.analyzer.ListTableSummariesContext(ctx)
+	if err != nil {
+		return nil, s.queryError(ctx, "failed to list tables", err)
+	}
+
+	pattern = strings.ToLower(pattern)
+	tables := make([]analyzer.TableSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		if !s.config.Schema.TableAllowed(summary.Name) {
+			continue
+		}
+		if pattern != "" && !strings.Contains(strings.ToLower(summary.Name), pattern) {
+			continue
+		}
+		tables = append(tables, summary)
+	}
+
+	sort.SliceStable(tables, func(i, j int) bool {
+		if sortBy == "rows" && tables[i].EstimatedRows != tables[j].EstimatedRows {
+			return tables[i].EstimatedRows > tables[j].EstimatedRows
+		}
+		return tables[i].Name < tables[j].Name
+	})
+
+	return map[string]interface{}{
+		"tables":      tables,
+		"table_count": len(tables),
+		"sort_by":     sortBy,
 	}, nil
 }
 
@@ -464,6 +665,9 @@ func (s *MCPServer) getMoreSamples(args map[string]interface{}) (interface{}, er
 	if !ok {
 		return nil, fmt.Errorf("table_name is required")
 	}
+	if !s.config.Schema.TableAllowed(tableName) {
+		return nil, fmt.Errorf("table %s is excluded by schema.include_patterns/exclude_patterns", tableName)
+	}
 
 	limit := 50 // 預設 50 個樣本
 	if l, ok := args["limit"]; ok {
@@ -479,12 +683,23 @@ func (s *MCPServer) getMoreSamples(args map[string]interface{}) (interface{}, er
 		}
 	}
 
+	limit = s.clampSampleLimit(limit)
+	if offset < 0 {
+		offset = 0
+	}
+
 	log.Printf("Getting more samples for table: %s (limit: %d, offset: %d)", tableName, limit, offset)
 
+	ctx, release, err := s.acquireQuery()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// 使用現有的 GetTableSamples 方法，然後進行分頁
-	allSamples, err := s.analyzer.GetTableSamples(tableName, limit+offset)
+	allSamples, err := s.analyzer.GetTableSamplesContext(ctx, tableName, limit+offset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get samples: %v", err)
+		return nil, s.queryError(ctx, "failed to get samples", err)
 	}
 
 	// 進行分頁
@@ -498,13 +713,45 @@ func (s *MCPServer) getMoreSamples(args map[string]interface{}) (interface{}, er
 		samples = allSamples[start:end]
 	}
 
-	return map[string]interface{}{
+	// 與 database_get_table_schema 使用相同的遮罩規則
+	var maskedColumns []string
+	if s.config.Security.SampleMasking.Enabled {
+		schema, err := s.analyzer.GetTableSchema(tableName)
+		if err != nil {
+			log.Printf("Warning: failed to get schema for sample masking: %v", err)
+		}
+		samples, maskedColumns = privacy.MaskSamples(s.config.Security.SampleMasking, tableName, schema, samples)
+	}
+
+	result := map[string]interface{}{
 		"table_name": tableName,
 		"samples":    samples,
 		"limit":      limit,
 		"offset":     offset,
 		"count":      len(samples),
-	}, nil
+	}
+	if len(maskedColumns) > 0 {
+		result["masked_columns"] = maskedColumns
+	}
+
+	return result, nil
+}
+
+// clampSampleLimit 將客戶端要求的樣本數量限制在 1 到 security.max_sample_limit 之間，保護資料庫
+func (s *MCPServer) clampSampleLimit(limit int) int {
+	maxLimit := s.config.Security.MaxSampleLimit
+	if maxLimit <= 0 {
+		maxLimit = 100
+	}
+
+	if limit < 1 {
+		return 1
+	}
+	if limit > maxLimit {
+		log.Printf("Warning: requested sample limit %d exceeds max %d, clamping", limit, maxLimit)
+		return maxLimit
+	}
+	return limit
 }
 
 // createErrorResponse 創建錯誤回應
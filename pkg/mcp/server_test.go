@@ -0,0 +1,210 @@
+package mcp
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// newTestServer 以記憶體 SQLite 建立 MCP 服務器，numbers 表格含 1 到 5
+func newTestServer(t *testing.T, cfg *config.Config) *MCPServer {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec("CREATE TABLE numbers (n INTEGER, label TEXT); INSERT INTO numbers VALUES (1, 'one'), (2, 'two'), (3, 'three'), (4, 'four'), (5, 'five')"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Database.Type = "sqlite"
+	return NewMCPServerWithConfig(db, cfg)
+}
+
+// callTool 透過 JSON-RPC 調用工具並解析回應
+func callTool(t *testing.T, s *MCPServer, name string, args map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	request, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]interface{}{"name": name, "arguments": args},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := s.HandleRequest(string(request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &response); err != nil {
+		t.Fatalf("invalid response %q: %v", raw, err)
+	}
+	return response
+}
+
+// registeredTools 返回 tools/list 中的工具名稱
+func registeredTools(t *testing.T, s *MCPServer) []string {
+	t.Helper()
+	raw, err := s.HandleRequest(`{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response struct {
+		Result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(raw), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, len(response.Result.Tools))
+	for i, tool := range response.Result.Tools {
+		names[i] = tool.Name
+	}
+	return names
+}
+
+func TestToolsCallContentShape(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Knowledge.Dir = t.TempDir()
+	cfg.VectorStore.Enabled = true
+	cfg.VectorStore.Backend = "memory"
+	cfg.VectorStore.EmbeddingDimension = 8
+	cfg.VectorStore.ChunkSize = 200
+	cfg.VectorStore.ChunkOverlap = 20
+	if err := os.WriteFile(filepath.Join(cfg.Knowledge.Dir, "phase4_dimensions.json"), []byte(`{"database":"test","classifications":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, cfg)
+
+	tests := map[string]map[string]interface{}{
+		"database_list_tables":           {},
+		"database_get_table_schema":      {"table_name": "numbers", "sample_limit": float64(2)},
+		"database_execute_sql_query":     {"query": "SELECT n FROM numbers", "max_rows": float64(2)},
+		"database_analyze_query":         {"query": "SELECT n FROM numbers", "explain_only": true},
+		"database_get_table_samples":     {"table_name": "numbers", "limit": float64(2)},
+		"analysis_get_schema_analysis":   {"query": "numbers"},
+		"analysis_get_business_logic":    {"query": "numbers"},
+		"analysis_get_business_overview": {"query": "numbers"},
+		"analysis_get_dimension_model":   {},
+		"knowledge_get_statistics":       {},
+	}
+
+	for _, name := range registeredTools(t, s) {
+		t.Run(name, func(t *testing.T) {
+			args, ok := tests[name]
+			if !ok {
+				t.Fatalf("no test arguments for registered tool %s", name)
+			}
+
+			response := callTool(t, s, name, args)
+			if response["error"] != nil {
+				t.Fatalf("tool returned error: %v", response["error"])
+			}
+			result, ok := response["result"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("result is not an object: %v", response["result"])
+			}
+			if result["isError"] != false {
+				t.Errorf("isError = %v, want false", result["isError"])
+			}
+
+			content, ok := result["content"].([]interface{})
+			if !ok || len(content) != 1 {
+				t.Fatalf("content = %v, want a single item array", result["content"])
+			}
+			item, _ := content[0].(map[string]interface{})
+			if item["type"] != "text" {
+				t.Errorf("content type = %v, want text", item["type"])
+			}
+			text, ok := item["text"].(string)
+			if !ok {
+				t.Fatalf("content text = %v, want string", item["text"])
+			}
+			var output map[string]interface{}
+			if err := json.Unmarshal([]byte(text), &output); err != nil {
+				t.Errorf("content text is not a JSON object: %v", err)
+			}
+		})
+	}
+}
+
+func TestToolsCallErrorIsJSONRPCError(t *testing.T) {
+	s := newTestServer(t, &config.Config{})
+
+	tests := []struct {
+		name     string
+		tool     string
+		args     map[string]interface{}
+		wantCode float64
+	}{
+		{"unknown tool", "no_such_tool", map[string]interface{}{}, -32601},
+		{"tool failure", "database_get_table_samples", map[string]interface{}{}, -32000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callTool(t, s, tt.tool, tt.args)
+			if response["result"] != nil {
+				t.Errorf("result = %v, want none", response["result"])
+			}
+			rpcError, ok := response["error"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("error = %v, want object", response["error"])
+			}
+			if rpcError["code"] != tt.wantCode {
+				t.Errorf("error code = %v, want %v", rpcError["code"], tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestExecuteQueryReturnsJSONNumbers(t *testing.T) {
+	s := newTestServer(t, &config.Config{})
+
+	response := callTool(t, s, "database_execute_sql_query", map[string]interface{}{"query": "SELECT COUNT(*) AS total, SUM(n) AS sum, label FROM numbers WHERE n = 2"})
+	if response["error"] != nil {
+		t.Fatalf("tool returned error: %v", response["error"])
+	}
+	content := response["result"].(map[string]interface{})["content"].([]interface{})
+	text := content[0].(map[string]interface{})["text"].(string)
+
+	var output struct {
+		Rows []map[string]json.RawMessage `json:"rows"`
+	}
+	if err := json.Unmarshal([]byte(text), &output); err != nil {
+		t.Fatal(err)
+	}
+	if len(output.Rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(output.Rows))
+	}
+
+	tests := []struct {
+		column string
+		want   string
+	}{
+		{"total", "1"},
+		{"sum", "2"},
+		{"label", `"two"`},
+	}
+	for _, tt := range tests {
+		if got := string(output.Rows[0][tt.column]); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.column, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// columnDescriptionSeparators 分析文字中欄位名稱與描述之間的分隔符號
+var columnDescriptionSeparators = []string{":", "：", " - ", " – ", " — ", "("}
+
+// LoadColumnDescriptions 從 Phase 2 分析結果組合 表格 -> 欄位 -> 描述 的對照表
+// 優先使用結構化的 column_descriptions，否則從分析文字中以 "欄位: 描述" 形式的行擷取
+func LoadColumnDescriptions(phase2Path string, schemas map[string][]string) (map[string]map[string]string, error) {
+	data, err := os.ReadFile(phase2Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var phase2 Phase2AnalysisResult
+	if err := json.Unmarshal(data, &phase2); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", phase2Path, err)
+	}
+
+	descriptions := make(map[string]map[string]string)
+	for tableName, result := range phase2.AnalysisResults {
+		columns, ok := schemas[tableName]
+		if !ok {
+			continue
+		}
+
+		tableDescriptions := make(map[string]string)
+		for column, description := range result.ColumnDescriptions {
+			if description = strings.TrimSpace(description); description != "" {
+				tableDescriptions[column] = description
+			}
+		}
+		for column, description := range extractColumnDescriptions(result.Analysis, columns) {
+			if _, exists := tableDescriptions[column]; !exists {
+				tableDescriptions[column] = description
+			}
+		}
+
+		if len(tableDescriptions) > 0 {
+			descriptions[tableName] = tableDescriptions
+		}
+	}
+
+	return descriptions, nil
+}
+
+// extractColumnDescriptions 從分析文字擷取已知欄位的描述，每個欄位取第一個符合的行
+func extractColumnDescriptions(analysis string, columns []string) map[string]string {
+	descriptions := make(map[string]string)
+	if analysis == "" || len(columns) == 0 {
+		return descriptions
+	}
+
+	for _, line := range strings.Split(analysis, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "-*•0123456789. ")
+		line = strings.NewReplacer("**", "", "`", "").Replace(line)
+
+		for _, column := range columns {
+			if _, found := descriptions[column]; found {
+				continue
+			}
+			if len(line) <= len(column) || !strings.EqualFold(line[:len(column)], column) {
+				continue
+			}
+
+			rest := line[len(column):]
+			for _, sep := range columnDescriptionSeparators {
+				if !strings.HasPrefix(strings.TrimLeft(rest, " "), strings.TrimSpace(sep)) {
+					continue
+				}
+				description := strings.TrimSpace(strings.TrimLeft(rest, " "))
+				description = strings.TrimSpace(strings.TrimPrefix(description, strings.TrimSpace(sep)))
+				if sep == "(" {
+					description = strings.TrimSuffix(description, ")")
+				}
+				if description != "" {
+					descriptions[column] = description
+				}
+				break
+			}
+		}
+	}
+
+	return descriptions
+}
+
+// formatColumnDescriptions 將指定表格的欄位描述格式化為提示文字，無描述時返回空字串
+func formatColumnDescriptions(descriptions map[string]map[string]string, tables []string) string {
+	var builder strings.Builder
+	for _, tableName := range tables {
+		tableDescriptions := descriptions[tableName]
+		if len(tableDescriptions) == 0 {
+			continue
+		}
+
+		columns := make([]string, 0, len(tableDescriptions))
+		for column := range tableDescriptions {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		builder.WriteString(fmt.Sprintf("\nTable: %s\n", tableName))
+		for _, column := range columns {
+			builder.WriteString(fmt.Sprintf("  - %s: %s\n", column, tableDescriptions[column]))
+		}
+	}
+
+	if builder.Len() == 0 {
+		return ""
+	}
+	return "Column Descriptions:\n" + builder.String()
+}
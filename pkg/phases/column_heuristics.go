@@ -0,0 +1,346 @@
+package phases
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// ColumnHeuristics Phase 2 前置處理使用的欄位判斷規則與門檻
+type ColumnHeuristics struct {
+	UnusedNullRatio           float64 // 空值比例超過此值視為可能未使用
+	CollectionIndicatorMin    int     // 含集合特徵的樣本數超過此值視為集合欄位
+	EnumMinSamples            int     // 判斷枚舉所需的最少樣本數
+	EnumMaxUniqueRatio        float64 // 唯一值比例低於此值視為枚舉
+	ValueCollectionMinSamples int     // 判斷值搜集所需的最少樣本數與非空值數
+	StatusMaxUnique           int     // 狀態類欄位的最大唯一值數量
+	ValueCollectionMinRatio   float64 // 字串欄位唯一值比例下限
+	ValueCollectionMaxRatio   float64 // 字串欄位唯一值比例上限
+	ValueCollectionMaxUnique  int     // 字串欄位最大唯一值數量
+	MinSamplesForInference    int     // 表格樣本數低於此值時不產生依賴樣本推論的問題
+}
+
+// NewColumnHeuristics 創建使用預設門檻的欄位判斷規則
+func NewColumnHeuristics() *ColumnHeuristics {
+	return &ColumnHeuristics{
+		UnusedNullRatio:           0.8,
+		CollectionIndicatorMin:    2,
+		EnumMinSamples:            5,
+		EnumMaxUniqueRatio:        0.2,
+		ValueCollectionMinSamples: 3,
+		StatusMaxUnique:           20,
+		ValueCollectionMinRatio:   0.1,
+		ValueCollectionMaxRatio:   0.9,
+		ValueCollectionMaxUnique:  50,
+		MinSamplesForInference:    3,
+	}
+}
+
+// NewColumnHeuristicsFromConfig 根據配置創建欄位判斷規則，未設定的門檻使用預設值
+func NewColumnHeuristicsFromConfig(cfg config.Phase2PrefixConfig) *ColumnHeuristics {
+	h := NewColumnHeuristics()
+
+	if cfg.UnusedNullRatio > 0 {
+		h.UnusedNullRatio = cfg.UnusedNullRatio
+	}
+	if cfg.CollectionIndicatorMin > 0 {
+		h.CollectionIndicatorMin = cfg.CollectionIndicatorMin
+	}
+	if cfg.EnumMinSamples > 0 {
+		h.EnumMinSamples = cfg.EnumMinSamples
+	}
+	if cfg.EnumMaxUniqueRatio > 0 {
+		h.EnumMaxUniqueRatio = cfg.EnumMaxUniqueRatio
+	}
+	if cfg.ValueCollectionMinSamples > 0 {
+		h.ValueCollectionMinSamples = cfg.ValueCollectionMinSamples
+	}
+	if cfg.StatusMaxUnique > 0 {
+		h.StatusMaxUnique = cfg.StatusMaxUnique
+	}
+	if cfg.ValueCollectionMinRatio > 0 {
+		h.ValueCollectionMinRatio = cfg.ValueCollectionMinRatio
+	}
+	if cfg.ValueCollectionMaxRatio > 0 {
+		h.ValueCollectionMaxRatio = cfg.ValueCollectionMaxRatio
+	}
+	if cfg.ValueCollectionMaxUnique > 0 {
+		h.ValueCollectionMaxUnique = cfg.ValueCollectionMaxUnique
+	}
+	if cfg.MinSamplesForInference > 0 {
+		h.MinSamplesForInference = cfg.MinSamplesForInference
+	}
+
+	return h
+}
+
+// dataDependentQuestionTypes 需要依樣本數據推論的問題類型
+var dataDependentQuestionTypes = map[string]bool{
+	"unused_column_check":    true,
+	"collection_check":       true,
+	"enum_check":             true,
+	"value_collection_check": true,
+}
+
+// SampleCount 返回表格的樣本數
+func (h *ColumnHeuristics) SampleCount(tableInfo map[string]interface{}) int {
+	samples, _ := tableInfo["samples"].([]interface{})
+	return len(samples)
+}
+
+// HasSufficientSamples 判斷表格樣本數是否足以進行樣本推論
+func (h *ColumnHeuristics) HasSufficientSamples(tableInfo map[string]interface{}) bool {
+	return h.SampleCount(tableInfo) >= h.MinSamplesForInference
+}
+
+// IsPotentiallyUnusedColumn 檢查欄位是否可能沒有在使用（樣本中大量空值）
+func (h *ColumnHeuristics) IsPotentiallyUnusedColumn(col map[string]interface{}, tableInfo map[string]interface{}) bool {
+	// 檢查是否可為空且在樣本數據中大量為空
+	nullable, ok := col["nullable"].(bool)
+	if !ok || !nullable {
+		return false
+	}
+
+	// 檢查樣本數據
+	samples, ok := tableInfo["samples"].([]interface{})
+	if !ok || len(samples) == 0 {
+		return false
+	}
+
+	// 計算空值比例
+	colName, ok := col["name"].(string)
+	if !ok {
+		return false
+	}
+	nullCount := 0
+	totalCount := len(samples)
+
+	for _, sample := range samples {
+		sampleData, ok := sample.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		value, exists := sampleData[colName]
+		if !exists || value == nil || value == "" {
+			nullCount++
+		}
+	}
+
+	// 如果空值比例超過門檻（預設 80%），認為可能沒有使用
+	return float64(nullCount)/float64(totalCount) > h.UnusedNullRatio
+}
+
+// IsPotentialCollectionColumn 檢查欄位是否可能是集合類型
+func (h *ColumnHeuristics) IsPotentialCollectionColumn(col map[string]interface{}, tableInfo map[string]interface{}) bool {
+	colType, ok := col["type"].(string)
+	if !ok {
+		return false
+	}
+
+	// 如果是 ARRAY 或原生 JSON / JSONB 類型，直接視為集合欄位
+	if strings.ToUpper(colType) == "ARRAY" || isJSONColumnType(colType) {
+		return true
+	}
+
+	// 檢查是否是可能儲存集合的類型
+	if !strings.Contains(strings.ToLower(colType), "text") && !strings.Contains(strings.ToLower(colType), "varchar") {
+		return false
+	}
+
+	// 檢查樣本數據中是否有集合特徵
+	samples, ok := tableInfo["samples"].([]interface{})
+	if !ok || len(samples) == 0 {
+		return false
+	}
+
+	colName, ok := col["name"].(string)
+	if !ok {
+		return false
+	}
+	collectionIndicators := 0
+
+	for _, sample := range samples {
+		sampleData, ok := sample.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		value, exists := sampleData[colName]
+		if !exists {
+			continue
+		}
+
+		valueStr := fmt.Sprintf("%v", value)
+
+		// 檢查集合指標
+		if strings.Contains(valueStr, ",") || strings.Contains(valueStr, ";") ||
+			strings.Contains(valueStr, "[") || strings.Contains(valueStr, "{") ||
+			strings.Contains(valueStr, "|") {
+			collectionIndicators++
+		}
+	}
+
+	// 如果有多個樣本顯示集合特徵
+	return collectionIndicators > h.CollectionIndicatorMin
+}
+
+// IsValueCollectionColumn 檢查欄位是否需要搜集值
+func (h *ColumnHeuristics) IsValueCollectionColumn(col map[string]interface{}, tableInfo map[string]interface{}) bool {
+	colType, ok := col["type"].(string)
+	if !ok {
+		return false
+	}
+
+	colName, ok := col["name"].(string)
+	if !ok {
+		return false
+	}
+
+	// 檢查樣本數據
+	samples, ok := tableInfo["samples"].([]interface{})
+	if !ok || len(samples) < h.ValueCollectionMinSamples {
+		return false
+	}
+
+	// 計算非空值的數量和唯一值
+	nonNullCount := 0
+	uniqueValues := make(map[string]bool)
+
+	for _, sample := range samples {
+		sampleData, ok := sample.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		value, exists := sampleData[colName]
+		if exists && value != nil && value != "" {
+			valueStr := fmt.Sprintf("%v", value)
+			uniqueValues[valueStr] = true
+			nonNullCount++
+		}
+	}
+
+	// 如果非空值太少，跳過
+	if nonNullCount < h.ValueCollectionMinSamples {
+		return false
+	}
+
+	uniqueCount := len(uniqueValues)
+
+	// 對於狀態類欄位（包含 status, type, state 等關鍵字）
+	if strings.Contains(strings.ToLower(colName), "status") ||
+		strings.Contains(strings.ToLower(colName), "type") ||
+		strings.Contains(strings.ToLower(colName), "state") ||
+		strings.Contains(strings.ToLower(colName), "gender") ||
+		strings.Contains(strings.ToLower(colName), "category") {
+		return uniqueCount <= h.StatusMaxUnique // 狀態類欄位通常枚舉值不會太多
+	}
+
+	// 對於名稱類欄位（包含 name, title 等關鍵字）
+	if strings.Contains(strings.ToLower(colName), "name") ||
+		strings.Contains(strings.ToLower(colName), "title") ||
+		strings.Contains(strings.ToLower(colName), "city") ||
+		strings.Contains(strings.ToLower(colName), "address") {
+		return uniqueCount > 1 && uniqueCount <= len(samples)/2 // 名稱類欄位重複度可能較高
+	}
+
+	// 對於 ID 類欄位
+	if strings.Contains(strings.ToLower(colName), "id") &&
+		!strings.Contains(strings.ToLower(colName), "uuid") &&
+		!strings.Contains(strings.ToLower(colName), "guid") {
+		return uniqueCount > 1 && uniqueCount <= len(samples)/3 // ID 類欄位重複度不高
+	}
+
+	// 對於 varchar/char 類型，如果唯一值比例適中，可能需要搜集
+	if strings.Contains(strings.ToLower(colType), "varchar") ||
+		strings.Contains(strings.ToLower(colType), "char") {
+		uniqueRatio := float64(uniqueCount) / float64(nonNullCount)
+		return uniqueRatio > h.ValueCollectionMinRatio && uniqueRatio < h.ValueCollectionMaxRatio && uniqueCount <= h.ValueCollectionMaxUnique
+	}
+
+	return false
+}
+
+// IsEnumColumn 檢查欄位是否使用枚舉
+func (h *ColumnHeuristics) IsEnumColumn(col map[string]interface{}, tableInfo map[string]interface{}) bool {
+	colType, ok := col["type"].(string)
+	if !ok {
+		return false
+	}
+
+	// 檢查是否是可能的枚舉類型
+	if !strings.Contains(strings.ToLower(colType), "varchar") && !strings.Contains(strings.ToLower(colType), "char") {
+		return false
+	}
+
+	// 檢查樣本數據中的唯一值數量
+	samples, ok := tableInfo["samples"].([]interface{})
+	if !ok || len(samples) < h.EnumMinSamples {
+		return false
+	}
+
+	colName, ok := col["name"].(string)
+	if !ok {
+		return false
+	}
+	uniqueValues := make(map[string]bool)
+
+	for _, sample := range samples {
+		sampleData, ok := sample.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		value, exists := sampleData[colName]
+		if exists && value != nil && value != "" {
+			uniqueValues[fmt.Sprintf("%v", value)] = true
+		}
+	}
+
+	// 如果唯一值比例低於門檻（預設 20%），可能是枚舉
+	uniqueCount := len(uniqueValues)
+	return uniqueCount > 1 && uniqueCount < int(float64(len(samples))*h.EnumMaxUniqueRatio)
+}
+
+// IsUndefinedIntColumn 檢查 int 欄位是否沒有定義
+func (h *ColumnHeuristics) IsUndefinedIntColumn(col map[string]interface{}, tableInfo map[string]interface{}) bool {
+	colType, ok := col["type"].(string)
+	if !ok {
+		return false
+	}
+
+	// 檢查是否是 int 類型
+	if !strings.Contains(strings.ToLower(colType), "int") {
+		return false
+	}
+
+	// 資料庫中已有欄位說明（COMMENT），不需要再詢問用途
+	if description, ok := col["description"].(string); ok && strings.TrimSpace(description) != "" {
+		return false
+	}
+
+	// 檢查是否有約束或外鍵
+	constraints, ok := tableInfo["constraints"].(map[string]interface{})
+	if !ok {
+		return true // 沒有約束，可能需要定義
+	}
+
+	// 檢查外鍵
+	if foreignKeys, ok := constraints["foreign_keys"].([]interface{}); ok && len(foreignKeys) > 0 {
+		return false // 有外鍵，已經定義
+	}
+
+	// 檢查主鍵
+	if primaryKeys, ok := constraints["primary_keys"].([]interface{}); ok {
+		colName, _ := col["name"].(string)
+		for _, pk := range primaryKeys {
+			if pkStr, ok := pk.(string); ok && pkStr == colName {
+				return false // 是主鍵，已經定義
+			}
+		}
+	}
+
+	return true // 沒有外鍵也不是主鍵，可能需要更好的定義
+}
@@ -0,0 +1,117 @@
+package phases
+
+import (
+	"fmt"
+	"testing"
+)
+
+// columnSamples 以單一欄位的樣本值建立 Phase 1 表格資訊
+func columnSamples(column string, values ...interface{}) map[string]interface{} {
+	samples := make([]interface{}, len(values))
+	for i, value := range values {
+		samples[i] = map[string]interface{}{column: value}
+	}
+	return map[string]interface{}{"samples": samples}
+}
+
+// repeated 返回 n 個相同的值
+func repeated(value interface{}, n int) []interface{} {
+	values := make([]interface{}, n)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
+
+// distinct 返回 n 個互不相同的字串值
+func distinct(prefix string, n int) []interface{} {
+	values := make([]interface{}, n)
+	for i := range values {
+		values[i] = fmt.Sprintf("%s-%d", prefix, i)
+	}
+	return values
+}
+
+func TestColumnHeuristics(t *testing.T) {
+	type check func(*ColumnHeuristics, map[string]interface{}, map[string]interface{}) bool
+
+	var (
+		unused          check = (*ColumnHeuristics).IsPotentiallyUnusedColumn
+		enum            check = (*ColumnHeuristics).IsEnumColumn
+		valueCollection check = (*ColumnHeuristics).IsValueCollectionColumn
+		collection      check = (*ColumnHeuristics).IsPotentialCollectionColumn
+		undefinedInt    check = (*ColumnHeuristics).IsUndefinedIntColumn
+	)
+
+	// 20 筆樣本時枚舉門檻為 int(20*0.2) = 4，唯一值數量必須小於 4
+	threeOfTwenty := append(repeated("gold", 10), append(repeated("silver", 5), repeated("bronze", 5)...)...)
+	fourOfTwenty := append(repeated("gold", 8), append(repeated("silver", 4), append(repeated("bronze", 4), repeated("platinum", 4)...)...)...)
+
+	// 10 筆樣本中 8 筆為空值（剛好 80%）或 9 筆為空值（90%）
+	eightyPercentNull := append(repeated(nil, 6), "", "", "a", "b")
+	ninetyPercentNull := append(repeated(nil, 9), "a")
+
+	tests := []struct {
+		name        string
+		check       check
+		col         map[string]interface{}
+		values      []interface{}
+		constraints map[string]interface{}
+		want        bool
+	}{
+		// 空值比例：門檻為「超過」80%
+		{"exactly 80% null is not unused", unused, map[string]interface{}{"name": "note", "type": "varchar", "nullable": true}, eightyPercentNull, nil, false},
+		{"90% null is unused", unused, map[string]interface{}{"name": "note", "type": "varchar", "nullable": true}, ninetyPercentNull, nil, true},
+		{"not nullable is never unused", unused, map[string]interface{}{"name": "note", "type": "varchar", "nullable": false}, ninetyPercentNull, nil, false},
+		{"no samples is not unused", unused, map[string]interface{}{"name": "note", "type": "varchar", "nullable": true}, nil, nil, false},
+
+		// 單一值欄位
+		{"single value is not unused", unused, map[string]interface{}{"name": "status", "type": "varchar", "nullable": true}, repeated("active", 10), nil, false},
+		{"single value is not an enum", enum, map[string]interface{}{"name": "status", "type": "varchar"}, repeated("active", 10), nil, false},
+		{"single value status collects values", valueCollection, map[string]interface{}{"name": "status", "type": "varchar"}, repeated("active", 10), nil, true},
+		{"single value name does not collect values", valueCollection, map[string]interface{}{"name": "city_name", "type": "varchar"}, repeated("Taipei", 10), nil, false},
+		{"single value code does not collect values", valueCollection, map[string]interface{}{"name": "code", "type": "varchar"}, repeated("A", 10), nil, false},
+
+		// 高基數欄位（每筆樣本都不同）
+		{"high cardinality is not an enum", enum, map[string]interface{}{"name": "code", "type": "varchar"}, distinct("code", 20), nil, false},
+		{"high cardinality code does not collect values", valueCollection, map[string]interface{}{"name": "code", "type": "varchar"}, distinct("code", 20), nil, false},
+		{"high cardinality name does not collect values", valueCollection, map[string]interface{}{"name": "customer_name", "type": "varchar"}, distinct("name", 20), nil, false},
+		{"high cardinality status exceeds status limit", valueCollection, map[string]interface{}{"name": "status", "type": "varchar"}, distinct("status", 30), nil, false},
+
+		// 枚舉的唯一值比例門檻（低於 20%）
+		{"3 of 20 distinct values is an enum", enum, map[string]interface{}{"name": "tier", "type": "varchar"}, threeOfTwenty, nil, true},
+		{"4 of 20 distinct values is not an enum", enum, map[string]interface{}{"name": "tier", "type": "varchar"}, fourOfTwenty, nil, false},
+		{"too few samples is not an enum", enum, map[string]interface{}{"name": "tier", "type": "varchar"}, []interface{}{"gold", "silver", "gold", "silver"}, nil, false},
+		{"integer column is not an enum", enum, map[string]interface{}{"name": "tier", "type": "integer"}, threeOfTwenty, nil, false},
+
+		// 集合欄位：超過 2 筆樣本含分隔符號
+		{"array type is a collection", collection, map[string]interface{}{"name": "tags", "type": "ARRAY"}, nil, nil, true},
+		{"three delimited samples is a collection", collection, map[string]interface{}{"name": "tags", "type": "text"}, []interface{}{"a,b", "c;d", "[1]", "plain"}, nil, true},
+		{"two delimited samples is not a collection", collection, map[string]interface{}{"name": "tags", "type": "varchar"}, []interface{}{"a,b", "c|d", "plain", "other"}, nil, false},
+		{"integer type is not a collection", collection, map[string]interface{}{"name": "tags", "type": "integer"}, []interface{}{"1,2", "3,4", "5,6"}, nil, false},
+		{"no samples is not a collection", collection, map[string]interface{}{"name": "tags", "type": "text"}, nil, nil, false},
+
+		// 未定義的 int 欄位：沒有外鍵也不是主鍵
+		{"int without constraints is undefined", undefinedInt, map[string]interface{}{"name": "owner_id", "type": "integer"}, nil, nil, true},
+		{"int with foreign keys is defined", undefinedInt, map[string]interface{}{"name": "owner_id", "type": "integer"},
+			nil, map[string]interface{}{"foreign_keys": []interface{}{map[string]interface{}{"column": "owner_id"}}}, false},
+		{"int primary key is defined", undefinedInt, map[string]interface{}{"name": "id", "type": "bigint"},
+			nil, map[string]interface{}{"primary_keys": []interface{}{"id"}}, false},
+		{"int outside the primary key is undefined", undefinedInt, map[string]interface{}{"name": "quantity", "type": "int"},
+			nil, map[string]interface{}{"primary_keys": []interface{}{"id"}}, true},
+		{"varchar is not an undefined int", undefinedInt, map[string]interface{}{"name": "code", "type": "varchar"}, nil, nil, false},
+	}
+
+	h := NewColumnHeuristics()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tableInfo := columnSamples(tt.col["name"].(string), tt.values...)
+			if tt.constraints != nil {
+				tableInfo["constraints"] = tt.constraints
+			}
+			if got := tt.check(h, tt.col, tableInfo); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
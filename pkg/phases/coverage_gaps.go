@@ -0,0 +1,129 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// CoverageGapsPath 知識庫覆蓋缺口記錄檔案路徑
+func CoverageGapsPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("coverage_gaps.json")
+}
+
+// maxCoverageGaps 最多保留的覆蓋缺口記錄數（超過時移除最舊的記錄）
+const maxCoverageGaps = 500
+
+// coverageGapsMu 保護覆蓋缺口記錄檔案的讀寫
+var coverageGapsMu sync.Mutex
+
+// CoverageGap 單次查詢的知識覆蓋缺口
+type CoverageGap struct {
+	Query         string    `json:"query"`
+	BestScore     float64   `json:"best_score"`
+	ResultCount   int       `json:"result_count"`
+	MissingPhases []string  `json:"missing_phases"` // 沒有任何相關知識的 phase
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// CoverageGapSummary 依查詢彙總的覆蓋缺口
+type CoverageGapSummary struct {
+	Query     string    `json:"query"`
+	Count     int       `json:"count"`
+	BestScore float64   `json:"best_score"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// RecordCoverageGap 追加一筆覆蓋缺口記錄
+func RecordCoverageGap(filename string, gap CoverageGap) error {
+	coverageGapsMu.Lock()
+	defer coverageGapsMu.Unlock()
+
+	gaps, err := loadCoverageGaps(filename)
+	if err != nil {
+		return err
+	}
+
+	if gap.Timestamp.IsZero() {
+		gap.Timestamp = time.Now()
+	}
+	gaps = append(gaps, gap)
+	if len(gaps) > maxCoverageGaps {
+		gaps = gaps[len(gaps)-maxCoverageGaps:]
+	}
+
+	return writeJSONFile(filename, gaps)
+}
+
+// LoadCoverageGaps 讀取覆蓋缺口記錄（由新到舊），limit <= 0 表示全部
+func LoadCoverageGaps(filename string, limit int) ([]CoverageGap, error) {
+	coverageGapsMu.Lock()
+	gaps, err := loadCoverageGaps(filename)
+	coverageGapsMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(gaps, func(i, j int) bool {
+		return gaps[i].Timestamp.After(gaps[j].Timestamp)
+	})
+	if limit > 0 && len(gaps) > limit {
+		gaps = gaps[:limit]
+	}
+	return gaps, nil
+}
+
+// SummarizeCoverageGaps 依查詢（忽略大小寫與空白）彙總覆蓋缺口，出現次數多的排在前面
+func SummarizeCoverageGaps(gaps []CoverageGap) []CoverageGapSummary {
+	byQuery := make(map[string]*CoverageGapSummary)
+	for _, gap := range gaps {
+		key := strings.ToLower(strings.Join(strings.Fields(gap.Query), " "))
+		summary, exists := byQuery[key]
+		if !exists {
+			summary = &CoverageGapSummary{Query: gap.Query, BestScore: gap.BestScore}
+			byQuery[key] = summary
+		}
+		summary.Count++
+		if gap.BestScore > summary.BestScore {
+			summary.BestScore = gap.BestScore
+		}
+		if gap.Timestamp.After(summary.LastSeen) {
+			summary.LastSeen = gap.Timestamp
+		}
+	}
+
+	summaries := make([]CoverageGapSummary, 0, len(byQuery))
+	for _, summary := range byQuery {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].LastSeen.After(summaries[j].LastSeen)
+	})
+	return summaries
+}
+
+// loadCoverageGaps 讀取記錄檔案，檔案不存在時返回空記錄
+func loadCoverageGaps(filename string) ([]CoverageGap, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []CoverageGap{}, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	var gaps []CoverageGap
+	if err := json.Unmarshal(data, &gaps); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	return gaps, nil
+}
@@ -0,0 +1,193 @@
+package phases
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// relationshipEdge 關聯圖中的一條外鍵邊：table.column -> referencedTable.referencedColumn
+type relationshipEdge struct {
+	Column           string
+	ReferencedTable  string
+	ReferencedColumn string
+}
+
+// denormalizedColumn 可能從其他表格複製而來的欄位
+type denormalizedColumn struct {
+	Table        string
+	Column       string
+	ViaColumn    string // 指向權威來源的外鍵欄位
+	SourceTable  string
+	SourceColumn string
+}
+
+// denormalizationIgnoredColumns 各表格普遍存在、不視為重複資料的欄位
+var denormalizationIgnoredColumns = map[string]bool{
+	"id": true, "created_at": true, "updated_at": true, "deleted_at": true,
+	"created_by": true, "updated_by": true, "status": true, "version": true,
+}
+
+// buildRelationshipGraph 從 Phase 1 的外鍵約束建立關聯圖（表格名稱 -> 外鍵邊）
+func buildRelationshipGraph(tables map[string]interface{}) map[string][]relationshipEdge {
+	graph := make(map[string][]relationshipEdge)
+
+	for tableName, tableData := range tables {
+		tableInfo, ok := tableData.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		constraints, ok := tableInfo["constraints"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fks, ok := constraints["foreign_keys"].([]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, fkData := range fks {
+			fk, ok := fkData.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			refTable, _ := fk["referenced_table"].(string)
+			if refTable == "" {
+				continue
+			}
+			columns, refColumns := foreignKeyColumns(fk)
+			for i, column := range columns {
+				refColumn := ""
+				if i < len(refColumns) {
+					refColumn = refColumns[i]
+				}
+				graph[tableName] = append(graph[tableName], relationshipEdge{
+					Column:           column,
+					ReferencedTable:  refTable,
+					ReferencedColumn: refColumn,
+				})
+			}
+		}
+	}
+
+	return graph
+}
+
+// tableColumnNames 返回表格 schema 中的欄位名稱
+func tableColumnNames(tableData interface{}) []string {
+	tableInfo, ok := tableData.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	schema, ok := tableInfo["schema"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var names []string
+	for _, colData := range schema {
+		col, ok := colData.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, ok := col["name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// detectDenormalizedColumns 找出經由外鍵可追溯到權威來源的重複欄位
+// 例如 orders.customer_name（customer_id -> customers.name）或 order_items.product_price（product_id -> products.price）
+func detectDenormalizedColumns(tables map[string]interface{}) []denormalizedColumn {
+	graph := buildRelationshipGraph(tables)
+
+	var results []denormalizedColumn
+	for tableName, edges := range graph {
+		columns := tableColumnNames(tables[tableName])
+
+		fkColumns := make(map[string]bool)
+		for _, edge := range edges {
+			fkColumns[edge.Column] = true
+		}
+
+		seen := make(map[string]bool)
+		for _, edge := range edges {
+			if edge.ReferencedTable == tableName {
+				continue
+			}
+			sourceColumns := make(map[string]bool)
+			for _, name := range tableColumnNames(tables[edge.ReferencedTable]) {
+				sourceColumns[strings.ToLower(name)] = true
+			}
+			if len(sourceColumns) == 0 {
+				continue
+			}
+
+			// customer_id -> customer，用於比對 customer_name 這類帶前綴的欄位
+			prefix := strings.TrimSuffix(strings.ToLower(edge.Column), "_id") + "_"
+
+			for _, column := range columns {
+				lower := strings.ToLower(column)
+				if seen[column] || fkColumns[column] || denormalizationIgnoredColumns[lower] {
+					continue
+				}
+
+				sourceColumn := ""
+				if strings.HasPrefix(lower, prefix) && sourceColumns[strings.TrimPrefix(lower, prefix)] {
+					sourceColumn = strings.TrimPrefix(lower, prefix)
+				} else if sourceColumns[lower] {
+					sourceColumn = lower
+				}
+				if sourceColumn == "" || denormalizationIgnoredColumns[sourceColumn] {
+					continue
+				}
+
+				seen[column] = true
+				results = append(results, denormalizedColumn{
+					Table:        tableName,
+					Column:       column,
+					ViaColumn:    edge.Column,
+					SourceTable:  edge.ReferencedTable,
+					SourceColumn: sourceColumn,
+				})
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Table != results[j].Table {
+			return results[i].Table < results[j].Table
+		}
+		return results[i].Column < results[j].Column
+	})
+	return results
+}
+
+// generateDenormalizationQuestions 為疑似反正規化的欄位產生 denormalization_check 問題
+func (p *Phase2PrefixRunner) generateDenormalizationQuestions(phase1Data map[string]interface{}) []map[string]interface{} {
+	tables, ok := phase1Data["tables"].(map[string]interface{})
+	if !ok {
+		return []map[string]interface{}{}
+	}
+
+	var questions []map[string]interface{}
+	for i, d := range detectDenormalizedColumns(tables) {
+		questions = append(questions, map[string]interface{}{
+			"question_id":   fmt.Sprintf("dq%d", i+1),
+			"question_type": "denormalization_check",
+			"question": fmt.Sprintf("表格 '%s' 的欄位 '%s' 看起來是透過 '%s' 從 '%s.%s' 複製而來的重複資料。是否應該移除此欄位並改為 JOIN '%s' 取得？",
+				d.Table, d.Column, d.ViaColumn, d.SourceTable, d.SourceColumn, d.SourceTable),
+			"table_name":  d.Table,
+			"column_name": d.Column,
+			"options":     []string{"應該正規化", "保留（刻意保存歷史快照）", "需要進一步檢查"},
+			"analysis_data": map[string]interface{}{
+				"via_column":    d.ViaColumn,
+				"source_table":  d.SourceTable,
+				"source_column": d.SourceColumn,
+			},
+		})
+	}
+
+	return questions
+}
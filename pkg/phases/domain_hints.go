@@ -0,0 +1,127 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// PrePhase3SummaryPath Phase 3 準備文件路徑（包含使用者填寫的 custom_notes）
+func PrePhase3SummaryPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("pre_phase3_summary.json")
+}
+
+// DomainHints 使用者提供的領域提示（來自 pre_phase3_summary.json 的 custom_notes）
+type DomainHints struct {
+	BusinessDomain         string      `json:"business_domain,omitempty"`
+	KeyEntities            []string    `json:"key_entities,omitempty"`
+	ImportantRelationships []string    `json:"important_relationships,omitempty"`
+	AnalysisFocusAreas     []string    `json:"analysis_focus_areas,omitempty"`
+	CustomDimensions       []Dimension `json:"custom_dimensions,omitempty"`
+	AdditionalNotes        string      `json:"additional_notes,omitempty"`
+}
+
+// LoadDomainHints 從 Phase 3 準備文件讀取並驗證使用者提供的領域提示
+// 檔案不存在或未填寫任何提示時返回 nil
+func LoadDomainHints(filename string) (*DomainHints, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	var summary struct {
+		CustomNotes *DomainHints `json:"custom_notes"`
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse custom_notes in %s: %w", filename, err)
+	}
+
+	hints := summary.CustomNotes
+	if hints == nil {
+		return nil, nil
+	}
+
+	hints.normalize()
+	if hints.IsEmpty() {
+		return nil, nil
+	}
+
+	return hints, nil
+}
+
+// normalize 清理空白、移除空值與重複項目，並略過不完整的自訂維度
+func (h *DomainHints) normalize() {
+	h.BusinessDomain = strings.TrimSpace(h.BusinessDomain)
+	h.AdditionalNotes = strings.TrimSpace(h.AdditionalNotes)
+	h.KeyEntities = cleanHintList(h.KeyEntities)
+	h.ImportantRelationships = cleanHintList(h.ImportantRelationships)
+	h.AnalysisFocusAreas = cleanHintList(h.AnalysisFocusAreas)
+
+	var dimensions []Dimension
+	for _, dim := range h.CustomDimensions {
+		dim.Name = strings.TrimSpace(dim.Name)
+		dim.SourceTable = strings.TrimSpace(dim.SourceTable)
+		if dim.Name == "" || dim.SourceTable == "" {
+			log.Printf("Warning: Skipping custom dimension without name or source_table: %+v", dim)
+			continue
+		}
+		dimensions = append(dimensions, dim)
+	}
+	h.CustomDimensions = dimensions
+}
+
+// IsEmpty 檢查是否沒有任何提示
+func (h *DomainHints) IsEmpty() bool {
+	return h.BusinessDomain == "" && h.AdditionalNotes == "" &&
+		len(h.KeyEntities) == 0 && len(h.ImportantRelationships) == 0 &&
+		len(h.AnalysisFocusAreas) == 0 && len(h.CustomDimensions) == 0
+}
+
+// PromptSection 產生供 LLM 提示使用的領域提示段落
+func (h *DomainHints) PromptSection() string {
+	if h == nil || h.IsEmpty() {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("User-Provided Domain Hints (authoritative - prefer these over assumptions):\n")
+	if h.BusinessDomain != "" {
+		sb.WriteString(fmt.Sprintf("- Business domain: %s\n", h.BusinessDomain))
+	}
+	if len(h.KeyEntities) > 0 {
+		sb.WriteString(fmt.Sprintf("- Key entities: %s\n", strings.Join(h.KeyEntities, ", ")))
+	}
+	if len(h.ImportantRelationships) > 0 {
+		sb.WriteString(fmt.Sprintf("- Important relationships: %s\n", strings.Join(h.ImportantRelationships, "; ")))
+	}
+	if len(h.AnalysisFocusAreas) > 0 {
+		sb.WriteString(fmt.Sprintf("- Analysis focus areas: %s\n", strings.Join(h.AnalysisFocusAreas, ", ")))
+	}
+	if h.AdditionalNotes != "" {
+		sb.WriteString(fmt.Sprintf("- Additional notes: %s\n", h.AdditionalNotes))
+	}
+
+	return sb.String()
+}
+
+// cleanHintList 移除空白及重複的項目
+func cleanHintList(items []string) []string {
+	seen := make(map[string]bool)
+	var cleaned []string
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[strings.ToLower(item)] {
+			continue
+		}
+		seen[strings.ToLower(item)] = true
+		cleaned = append(cleaned, item)
+	}
+	return cleaned
+}
@@ -0,0 +1,160 @@
+package phases
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/vectorstore"
+)
+
+// KnowledgeHistoryTimeFormat 歷史版本檔名中的時間戳格式（UTC，取自被覆蓋檔案的修改時間）
+const KnowledgeHistoryTimeFormat = "20060102T150405Z"
+
+// KnowledgeVersion phase 輸出的一個歷史版本
+type KnowledgeVersion struct {
+	Phase     string    `json:"phase"`
+	Timestamp string    `json:"timestamp"`
+	Name      string    `json:"name"`
+	SizeBytes int64     `json:"size_bytes"`
+	ModTime   time.Time `json:"mod_time"`
+}
+
+// KnowledgeHistoryDir 返回歷史版本目錄，未設定 knowledge.history_dir 時為知識目錄下的 history
+func KnowledgeHistoryDir(cfg *config.Config) string {
+	if cfg != nil && cfg.Knowledge.HistoryDir != "" {
+		return cfg.Knowledge.HistoryDir
+	}
+	return cfg.KnowledgePath("history")
+}
+
+// ArchiveKnowledgeFile 在 knowledge.keep_history 啟用時，將即將被覆蓋的 phase 輸出複製為
+// history/<name>.<timestamp><ext>；非 phase 主要輸出或檔案尚不存在時不做任何事
+// 複製失敗只記錄警告，不阻止新結果寫入
+func ArchiveKnowledgeFile(cfg *config.Config, filename string) {
+	if cfg == nil || !cfg.Knowledge.KeepHistory || historyPhase(filepath.Base(filename)) == "" {
+		return
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: Failed to archive %s: %v", filename, err)
+		}
+		return
+	}
+
+	target := filepath.Join(KnowledgeHistoryDir(cfg), historyFileName(filepath.Base(filename), info.ModTime()))
+	if err := copyHistoryFile(filename, target, info.ModTime()); err != nil {
+		log.Printf("Warning: Failed to archive %s: %v", filename, err)
+		return
+	}
+	log.Printf("Archived previous %s to %s", filepath.Base(filename), target)
+}
+
+// ListKnowledgeHistory 列出 phase 輸出的歷史版本，最新的在前
+func ListKnowledgeHistory(cfg *config.Config, phase string) ([]KnowledgeVersion, error) {
+	name, ok := vectorstore.PhaseKnowledgeFiles[phase]
+	if !ok {
+		return nil, fmt.Errorf("unknown phase %q", phase)
+	}
+
+	versions := []KnowledgeVersion{}
+	entries, err := os.ReadDir(KnowledgeHistoryDir(cfg))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return versions, nil
+		}
+		return nil, err
+	}
+
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "."
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) || !strings.HasSuffix(entry.Name(), ext) {
+			continue
+		}
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(entry.Name(), prefix), ext)
+		if _, err := time.Parse(KnowledgeHistoryTimeFormat, timestamp); err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			log.Printf("Warning: failed to read knowledge history file info %s: %v", entry.Name(), err)
+			continue
+		}
+		versions = append(versions, KnowledgeVersion{
+			Phase:     phase,
+			Timestamp: timestamp,
+			Name:      entry.Name(),
+			SizeBytes: info.Size(),
+			ModTime:   info.ModTime(),
+		})
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Timestamp > versions[j].Timestamp
+	})
+	return versions, nil
+}
+
+// KnowledgeHistoryPath 返回 phase 在指定時間戳的歷史版本路徑；時間戳須符合 KnowledgeHistoryTimeFormat
+func KnowledgeHistoryPath(cfg *config.Config, phase, timestamp string) (string, error) {
+	name, ok := vectorstore.PhaseKnowledgeFiles[phase]
+	if !ok {
+		return "", fmt.Errorf("unknown phase %q", phase)
+	}
+	modTime, err := time.Parse(KnowledgeHistoryTimeFormat, timestamp)
+	if err != nil {
+		return "", fmt.Errorf("invalid timestamp %q, expected format %s", timestamp, KnowledgeHistoryTimeFormat)
+	}
+	return filepath.Join(KnowledgeHistoryDir(cfg), historyFileName(name, modTime)), nil
+}
+
+// historyPhase 返回輸出檔名對應的 phase，不是 phase 主要輸出時返回空字串
+func historyPhase(name string) string {
+	for phase, file := range vectorstore.PhaseKnowledgeFiles {
+		if file == name {
+			return phase
+		}
+	}
+	return ""
+}
+
+// historyFileName 例如 phase2_analysis.json → phase2_analysis.20260102T150405Z.json
+func historyFileName(name string, modTime time.Time) string {
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(name, ext), modTime.UTC().Format(KnowledgeHistoryTimeFormat), ext)
+}
+
+// copyHistoryFile 複製檔案並保留原本的修改時間
+func copyHistoryFile(source, target string, modTime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %v", err)
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(target, modTime, modTime)
+}
@@ -0,0 +1,116 @@
+package phases
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// 支援的提示語言
+const (
+	LanguageChinese  = "zh"
+	LanguageJapanese = "ja"
+	LanguageEnglish  = "en"
+)
+
+// DetectSchemaLanguage 依表格與欄位名稱的主要文字系統判斷 schema 語言
+// 含假名的名稱視為日文；只含漢字的名稱視為中文；其餘視為英文，取多數決
+func DetectSchemaLanguage(tableAnalyses map[string]interface{}) string {
+	var names []string
+	for tableName, table := range tableAnalyses {
+		names = append(names, tableName)
+
+		tableMap, ok := table.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, col := range schemaColumns(tableMap["schema"]) {
+			if name, ok := col["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	kanaNames, hanNames, latinNames := 0, 0, 0
+	for _, name := range names {
+		switch nameScript(name) {
+		case LanguageJapanese:
+			kanaNames++
+		case LanguageChinese:
+			hanNames++
+		case LanguageEnglish:
+			latinNames++
+		}
+	}
+
+	if kanaNames+hanNames == 0 || kanaNames+hanNames < latinNames {
+		return LanguageEnglish
+	}
+	// 日文名稱也常只使用漢字，只要出現假名就視為日文 schema
+	if kanaNames > 0 {
+		return LanguageJapanese
+	}
+	return LanguageChinese
+}
+
+// ResolvePromptLanguage 決定分析提示使用的語言：config.App.Language 優先，其次為偵測結果，預設中文
+func ResolvePromptLanguage(cfg *config.Config, detected string) string {
+	switch strings.ToLower(strings.TrimSpace(cfg.App.Language)) {
+	case LanguageChinese:
+		return LanguageChinese
+	case LanguageJapanese:
+		return LanguageJapanese
+	case LanguageEnglish:
+		return LanguageEnglish
+	}
+
+	switch detected {
+	case LanguageChinese, LanguageJapanese, LanguageEnglish:
+		return detected
+	default:
+		return LanguageChinese
+	}
+}
+
+// nameScript 判斷單一名稱使用的文字系統，無字母時返回空字串
+func nameScript(name string) string {
+	hasHan, hasLatin := false, false
+	for _, r := range name {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			return LanguageJapanese
+		case unicode.Is(unicode.Han, r):
+			hasHan = true
+		case unicode.Is(unicode.Latin, r):
+			hasLatin = true
+		}
+	}
+
+	switch {
+	case hasHan:
+		return LanguageChinese
+	case hasLatin:
+		return LanguageEnglish
+	default:
+		return ""
+	}
+}
+
+// schemaColumns 將 JSON 解析後或分析器產生的 schema 轉換為欄位列表
+func schemaColumns(schema interface{}) []map[string]interface{} {
+	switch cols := schema.(type) {
+	case []map[string]interface{}:
+		return cols
+	case []interface{}:
+		columns := make([]map[string]interface{}, 0, len(cols))
+		for _, col := range cols {
+			if colMap, ok := col.(map[string]interface{}); ok {
+				columns = append(columns, colMap)
+			}
+		}
+		return columns
+	default:
+		return nil
+	}
+}
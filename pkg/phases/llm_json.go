@@ -0,0 +1,135 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// extractJSON 從 LLM 回應中取出 JSON：去除 ```json 程式碼區塊標記與前後說明文字，
+// 只保留第一個物件或陣列到其對應的結尾括號，並移除 } 或 ] 前多餘的逗號
+func extractJSON(response string) (string, error) {
+	text := stripCodeFence(response)
+
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return "", fmt.Errorf("no JSON object or array found in LLM response")
+	}
+
+	end := matchingBracket(text, start)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated JSON in LLM response")
+	}
+
+	return removeTrailingCommas(text[start : end+1]), nil
+}
+
+// stripCodeFence 若回應包含 markdown 程式碼區塊，返回第一個區塊的內容
+func stripCodeFence(response string) string {
+	open := strings.Index(response, "```")
+	if open < 0 {
+		return response
+	}
+	body := response[open+3:]
+	// 略過語言標記（例如 json）
+	if newline := strings.Index(body, "\n"); newline >= 0 && !strings.ContainsAny(body[:newline], "{[") {
+		body = body[newline+1:]
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return body
+}
+
+// matchingBracket 返回 start 位置的 { 或 [ 對應的結尾位置（略過字串內容），找不到時返回 -1
+func matchingBracket(text string, start int) int {
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// removeTrailingCommas 移除字串以外、緊接在 } 或 ] 之前（可隔空白）的逗號
+func removeTrailingCommas(text string) string {
+	var builder strings.Builder
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			builder.WriteByte(ch)
+			continue
+		}
+		if ch == '"' {
+			inString = true
+		}
+		if ch == ',' {
+			next := strings.TrimLeft(text[i+1:], " \t\r\n")
+			if strings.HasPrefix(next, "}") || strings.HasPrefix(next, "]") {
+				continue
+			}
+		}
+		builder.WriteByte(ch)
+	}
+	return builder.String()
+}
+
+// parseQuestionList 解析 LLM 產生的問題列表，接受 JSON 陣列或含 questions 欄位的物件
+func parseQuestionList(response string) ([]map[string]interface{}, error) {
+	jsonStr, err := extractJSON(response)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(jsonStr), &parsed); err != nil {
+		return nil, err
+	}
+
+	items, ok := parsed.([]interface{})
+	if obj, isObject := parsed.(map[string]interface{}); isObject {
+		items, ok = obj["questions"].([]interface{})
+	}
+	if !ok {
+		return nil, fmt.Errorf("LLM response has no question list")
+	}
+
+	questions := []map[string]interface{}{}
+	for _, item := range items {
+		if question, ok := item.(map[string]interface{}); ok {
+			questions = append(questions, question)
+		}
+	}
+	return questions, nil
+}
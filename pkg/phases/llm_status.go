@@ -0,0 +1,56 @@
+package phases
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/llm"
+)
+
+// llmStatusFiles 各 phase 記錄 llm_status 的輸出檔名（位於知識目錄下）
+var llmStatusFiles = map[string]string{
+	"phase2_prefix": "phase2_prefix_questions.json",
+	"phase2":        "phase2_analysis.json",
+	"phase3":        "phase3_analysis.json",
+}
+
+// phase2LLMStatus 彙總 Phase 2 各表格的 LLM 狀態，任何表格使用後備分析即視為使用後備
+// 原因取自第一個（依表格名稱排序）使用後備的表格
+func phase2LLMStatus(results map[string]*LLMAnalysisResult) *llm.Status {
+	var fallbackTables []string
+	for tableName, result := range results {
+		if result != nil && result.LLMStatus != nil && result.LLMStatus.UsedFallback {
+			fallbackTables = append(fallbackTables, tableName)
+		}
+	}
+	if len(fallbackTables) == 0 {
+		return llm.OKStatus()
+	}
+
+	sort.Strings(fallbackTables)
+	status := *results[fallbackTables[0]].LLMStatus
+	status.Fallbacks = fallbackTables
+	return &status
+}
+
+// LastLLMStatus 讀取各 phase 最近一次輸出中的 llm_status；沒有輸出或未記錄的 phase 不列出
+func LastLLMStatus(cfg *config.Config) map[string]*llm.Status {
+	statuses := make(map[string]*llm.Status)
+	for phase, name := range llmStatusFiles {
+		data, err := os.ReadFile(cfg.KnowledgePath(name))
+		if err != nil {
+			continue
+		}
+
+		var output struct {
+			LLMStatus *llm.Status `json:"llm_status"`
+		}
+		if err := json.Unmarshal(data, &output); err != nil || output.LLMStatus == nil {
+			continue
+		}
+		statuses[phase] = output.LLMStatus
+	}
+	return statuses
+}
@@ -0,0 +1,20 @@
+package phases
+
+import (
+	"log"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/llm"
+)
+
+// finishPhaseUsage 保存 phase 的 LLM token 用量並記錄日誌，返回可寫入 phase 輸出的摘要
+func finishPhaseUsage(cfg *config.Config, phase string) llm.Usage {
+	usage, err := llm.SavePhaseUsage(cfg, phase)
+	if err != nil {
+		log.Printf("Warning: Failed to save LLM usage for %s: %v", phase, err)
+	}
+
+	log.Printf("LLM usage for %s: %d requests, %d prompt + %d completion tokens, estimated cost %.4f",
+		phase, usage.Requests, usage.PromptTokens, usage.CompletionTokens, usage.EstimatedCost)
+	return usage
+}
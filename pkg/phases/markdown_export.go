@@ -0,0 +1,143 @@
+package phases
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// MarkdownPath 商業邏輯分析 Markdown 輸出路徑
+func MarkdownPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("analysis.md")
+}
+
+// GenerateMarkdown 將 Phase 2/Phase 3 分析結果輸出為 Markdown 文件
+func (g *ReportGenerator) GenerateMarkdown() []byte {
+	data := g.loadReportData()
+
+	var md strings.Builder
+	title := "Business Logic Analysis"
+	if data.Database != "" {
+		title += " - " + data.Database
+	}
+	md.WriteString(fmt.Sprintf("# %s\n\n", title))
+	md.WriteString(fmt.Sprintf("_Generated at %s_\n\n", data.GeneratedAt))
+	if len(data.MissingSources) > 0 {
+		md.WriteString(fmt.Sprintf("> Missing phase outputs: %s\n\n", strings.Join(data.MissingSources, ", ")))
+	}
+
+	// 目錄
+	var described []reportTable
+	for _, table := range data.Tables {
+		if table.Description != "" {
+			described = append(described, table)
+		}
+	}
+	sort.Slice(described, func(i, j int) bool {
+		return described[i].Name < described[j].Name
+	})
+
+	md.WriteString("## Contents\n\n")
+	md.WriteString("- [Business Summary](#business-summary)\n")
+	md.WriteString("- [Table Categories](#table-categories)\n")
+	md.WriteString("- [Key Business Processes](#key-business-processes)\n")
+	md.WriteString("- [Recommendations](#recommendations)\n")
+	md.WriteString("- [Table Descriptions](#table-descriptions)\n")
+	for _, table := range described {
+		md.WriteString(fmt.Sprintf("  - [%s](#%s)\n", table.Name, markdownAnchor(table.Name)))
+	}
+	md.WriteString("\n")
+
+	md.WriteString("## Business Summary\n\n")
+	if data.BusinessSummary != "" {
+		md.WriteString(strings.TrimSpace(data.BusinessSummary) + "\n\n")
+	} else {
+		md.WriteString("_No business summary available._\n\n")
+	}
+
+	md.WriteString("## Table Categories\n\n")
+	if len(data.TableCategories) > 0 {
+		categories := make([]string, 0, len(data.TableCategories))
+		for category := range data.TableCategories {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+
+		md.WriteString("| Category | Tables |\n|---|---|\n")
+		for _, category := range categories {
+			md.WriteString(fmt.Sprintf("| %s | %s |\n", escapeMarkdownCell(category), escapeMarkdownCell(strings.Join(data.TableCategories[category], ", "))))
+		}
+		md.WriteString("\n")
+	} else {
+		md.WriteString("_No table categories available._\n\n")
+	}
+
+	md.WriteString("## Key Business Processes\n\n")
+	writeMarkdownList(&md, data.BusinessProcess, "_No business processes available._")
+	if len(data.DataFlowPatterns) > 0 {
+		md.WriteString("### Data Flow Patterns\n\n")
+		writeMarkdownList(&md, data.DataFlowPatterns, "")
+	}
+
+	md.WriteString("## Recommendations\n\n")
+	writeMarkdownList(&md, data.Recommendations, "_No recommendations available._")
+
+	md.WriteString("## Table Descriptions\n\n")
+	if len(described) == 0 {
+		md.WriteString("_No table descriptions available._\n\n")
+	}
+	for _, table := range described {
+		md.WriteString(fmt.Sprintf("<a id=\"%s\"></a>\n### %s\n\n", markdownAnchor(table.Name), table.Name))
+		md.WriteString(fmt.Sprintf("Rows: %d · Columns: %d", table.RowCount, table.ColumnCount))
+		if len(table.PrimaryKeys) > 0 {
+			md.WriteString(fmt.Sprintf(" · Primary keys: `%s`", strings.Join(table.PrimaryKeys, "`, `")))
+		}
+		md.WriteString("\n\n")
+		md.WriteString(strings.TrimSpace(table.Description) + "\n\n")
+		md.WriteString("[Back to contents](#contents)\n\n")
+	}
+
+	return []byte(md.String())
+}
+
+// WriteMarkdown 產生 Markdown 文件並寫入指定路徑
+func (g *ReportGenerator) WriteMarkdown(outputPath string) ([]byte, error) {
+	content := g.GenerateMarkdown()
+	return content, writeReportFile(outputPath, content)
+}
+
+// writeMarkdownList 輸出 Markdown 列表，沒有項目時輸出提示文字
+func writeMarkdownList(md *strings.Builder, items []string, emptyText string) {
+	if len(items) == 0 {
+		if emptyText != "" {
+			md.WriteString(emptyText + "\n\n")
+		}
+		return
+	}
+	for _, item := range items {
+		md.WriteString(fmt.Sprintf("- %s\n", item))
+	}
+	md.WriteString("\n")
+}
+
+// markdownAnchor 將表格名稱轉換為錨點 ID
+func markdownAnchor(name string) string {
+	var anchor strings.Builder
+	anchor.WriteString("table-")
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			anchor.WriteRune(r)
+		} else {
+			anchor.WriteRune('-')
+		}
+	}
+	return anchor.String()
+}
+
+// escapeMarkdownCell 轉義 Markdown 表格儲存格中的特殊字元
+func escapeMarkdownCell(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.ReplaceAll(value, "\n", " ")
+}
@@ -0,0 +1,47 @@
+package phases
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 營銷查詢模式
+const (
+	MarketingModeAuto     = "auto"     // 依問題前綴決定：A: 為 analysis、Q: 為 raw、P: 為 preview，無前綴時為 analysis
+	MarketingModeAnalysis = "analysis" // 生成並執行 SQL，再由 LLM 產生業務洞察
+	MarketingModeRaw      = "raw"      // 只生成並執行 SQL，不產生業務洞察
+	MarketingModePreview  = "preview"  // 只生成並檢查 SQL，不執行（供執行前審查）
+)
+
+// marketingModePrefixes 問題前綴對應的模式
+var marketingModePrefixes = map[string]string{
+	"A:": MarketingModeAnalysis,
+	"Q:": MarketingModeRaw,
+	"P:": MarketingModePreview,
+}
+
+// ResolveMarketingMode 決定實際模式並返回去除前綴後的問題；mode 為空時視為 auto
+// auto 模式下以 A:/Q:/P: 前綴選擇模式，其他模式下前綴也會被去除
+func ResolveMarketingMode(mode, query string) (string, string, error) {
+	query = strings.TrimSpace(query)
+	prefixMode := ""
+	for prefix, candidate := range marketingModePrefixes {
+		if len(query) >= len(prefix) && strings.EqualFold(query[:len(prefix)], prefix) {
+			prefixMode = candidate
+			query = strings.TrimSpace(query[len(prefix):])
+			break
+		}
+	}
+
+	switch mode {
+	case "", MarketingModeAuto:
+		if prefixMode != "" {
+			return prefixMode, query, nil
+		}
+		return MarketingModeAnalysis, query, nil
+	case MarketingModeAnalysis, MarketingModeRaw, MarketingModePreview:
+		return mode, query, nil
+	default:
+		return "", "", fmt.Errorf("unknown marketing query mode %q (supported: auto, analysis, raw, preview)", mode)
+	}
+}
@@ -3,13 +3,22 @@ package phases
 import (
 	"context"
 	"database/sql"
+	"encoding/csv"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/analyzer"
 	"github.com/masato25/aika-dba/pkg/llm"
+	"github.com/masato25/aika-dba/pkg/privacy"
+	"github.com/masato25/aika-dba/pkg/sqlguard"
 	"github.com/masato25/aika-dba/pkg/vectorstore"
 )
 
@@ -19,10 +28,34 @@ type MarketingQueryRunner struct {
 	db           *sql.DB
 	knowledgeMgr *vectorstore.KnowledgeManager
 	llmClient    *llm.Client
+
+	// ReadOnly 只執行 SELECT/CTE 查詢，含寫入關鍵字的查詢一律拒絕（預設 true；安全模式下永遠唯讀）
+	ReadOnly bool
+
+	// 架構摘要快取，phase1 分析檔案修改時間變更時失效
+	schemaCacheMu      sync.Mutex
+	schemaCacheModTime time.Time
+	schemaSummary      string
+	keySummary         string
+}
+
+// phase1AnalysisPath phase1 分析結果檔案路徑
+func phase1AnalysisPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("phase1_analysis.json")
+}
+
+// MarketingQueryOptions 營銷查詢執行器的選項
+type MarketingQueryOptions struct {
+	ReadOnly bool // 拒絕 SELECT/CTE 以外的查詢
 }
 
-// NewMarketingQueryRunner 創建營銷查詢執行器
+// NewMarketingQueryRunner 創建唯讀的營銷查詢執行器
 func NewMarketingQueryRunner(cfg *config.Config, db *sql.DB) *MarketingQueryRunner {
+	return NewMarketingQueryRunnerWithOptions(cfg, db, MarketingQueryOptions{ReadOnly: true})
+}
+
+// NewMarketingQueryRunnerWithOptions 依選項創建營銷查詢執行器
+func NewMarketingQueryRunnerWithOptions(cfg *config.Config, db *sql.DB, opts MarketingQueryOptions) *MarketingQueryRunner {
 	// 創建知識管理器
 	knowledgeMgr, err := vectorstore.NewKnowledgeManager(cfg)
 	if err != nil {
@@ -34,35 +67,54 @@ func NewMarketingQueryRunner(cfg *config.Config, db *sql.DB) *MarketingQueryRunn
 		config:       cfg,
 		db:           db,
 		knowledgeMgr: knowledgeMgr,
-		llmClient:    llm.NewClient(cfg),
+		llmClient:    llm.NewClient(cfg).WithPhase("marketing_query"),
+		ReadOnly:     opts.ReadOnly,
 	}
 }
 
-// QueryResult 查詢結果結構
-type QueryResult struct {
-	Query            string                   `json:"query"`
-	SQLQuery         string                   `json:"sql_query,omitempty"`
-	Results          []map[string]interface{} `json:"results,omitempty"`
-	Explanation      string                   `json:"explanation"`
-	BusinessInsights string                   `json:"business_insights,omitempty"`
-	Timestamp        time.Time                `json:"timestamp"`
-	Error            string                   `json:"error,omitempty"`
+// Close 關閉營銷查詢執行器的知識管理器
+func (m *MarketingQueryRunner) Close() error {
+	if m.knowledgeMgr != nil {
+		return m.knowledgeMgr.Close()
+	}
+	return nil
 }
 
-// ExecuteMarketingQuery 執行營銷查詢
-func (m *MarketingQueryRunner) ExecuteMarketingQuery(naturalLanguageQuery string) (*QueryResult, error) {
-	log.Printf("=== Executing Marketing Query: %s ===", naturalLanguageQuery)
+// ExecuteMarketingQuery 以 auto 模式執行營銷查詢（問題可用 A:/Q:/P: 前綴選擇模式）
+func (m *MarketingQueryRunner) ExecuteMarketingQuery(naturalLanguageQuery string) (*MarketingQueryResult, error) {
+	return m.ExecuteMarketingQueryWithMode(naturalLanguageQuery, MarketingModeAuto)
+}
 
-	result := &QueryResult{
+// ExecuteMarketingQueryWithMode 依指定模式執行營銷查詢，raw 模式不產生業務洞察，preview 模式只生成 SQL 不執行
+func (m *MarketingQueryRunner) ExecuteMarketingQueryWithMode(naturalLanguageQuery, mode string) (*MarketingQueryResult, error) {
+	mode, naturalLanguageQuery, err := ResolveMarketingMode(mode, naturalLanguageQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("=== Executing Marketing Query (%s): %s ===", mode, naturalLanguageQuery)
+	result := &MarketingQueryResult{
 		Query:     naturalLanguageQuery,
+		Mode:      mode,
 		Timestamp: time.Now(),
 	}
 
+	// 用量為伺服器啟動以來的累計值，以前後差額作為本次查詢的成本
+	costBefore := llm.PhaseUsage(m.config, "marketing_query").EstimatedCost
+	defer func() {
+		result.EstimatedCost = finishPhaseUsage(m.config, "marketing_query").EstimatedCost - costBefore
+	}()
+
 	// 步驟 1: 從向量存儲檢索相關業務知識
 	relevantKnowledge, err := m.retrieveRelevantKnowledge(naturalLanguageQuery)
 	if err != nil {
 		log.Printf("Warning: Failed to retrieve relevant knowledge: %v", err)
-		relevantKnowledge = "No relevant business knowledge found."
+		relevantKnowledge = noKnowledgeFound
+	}
+
+	if mode == MarketingModePreview {
+		m.previewSQLQuery(result, naturalLanguageQuery, relevantKnowledge)
+		return result, nil
 	}
 
 	// 步驟 2: 生成 SQL 查詢
@@ -76,13 +128,21 @@ func (m *MarketingQueryRunner) ExecuteMarketingQuery(naturalLanguageQuery string
 	result.Explanation = explanation
 
 	// 步驟 3: 執行 SQL 查詢
-	queryResults, err := m.executeSQLQuery(sqlQuery)
+	queryResults, totalRows, truncated, err := m.executeSQLQuery(sqlQuery, m.resultLimit())
 	if err != nil {
 		result.Error = fmt.Sprintf("Failed to execute SQL query: %v", err)
 		return result, nil
 	}
 
 	result.Results = queryResults
+	result.RowCount = len(queryResults)
+	result.TotalRows = totalRows
+	result.Truncated = truncated
+
+	if mode == MarketingModeRaw {
+		log.Printf("Marketing query executed in raw mode, returned %d of %d results", len(queryResults), totalRows)
+		return result, nil
+	}
 
 	// 步驟 4: 生成業務洞察
 	businessInsights, err := m.generateBusinessInsights(naturalLanguageQuery, queryResults, relevantKnowledge)
@@ -93,110 +153,298 @@ func (m *MarketingQueryRunner) ExecuteMarketingQuery(naturalLanguageQuery string
 
 	result.BusinessInsights = businessInsights
 
-	log.Printf("Marketing query executed successfully, returned %d results", len(queryResults))
+	log.Printf("Marketing query executed successfully, returned %d of %d results", len(queryResults), totalRows)
 	return result, nil
 }
 
-// retrieveRelevantKnowledge 從向量存儲檢索相關業務知識
-func (m *MarketingQueryRunner) retrieveRelevantKnowledge(query string) (string, error) {
+// RetrievedKnowledge 從向量存儲檢索到、將放入 LLM 提示的知識塊
+type RetrievedKnowledge struct {
+	Phase    string                 `json:"phase"`
+	Label    string                 `json:"label"`
+	Content  string                 `json:"content"` // 截斷後實際放入提示的內容
+	Score    float64                `json:"score"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// knowledgeRetrieval 一次知識檢索的結果
+type knowledgeRetrieval struct {
+	chunks        []RetrievedKnowledge
+	bestScore     float64
+	missingPhases []string
+}
+
+// searchKnowledge 從各 phase 檢索相關知識，不產生任何副作用
+func (m *MarketingQueryRunner) searchKnowledge(query string) (*knowledgeRetrieval, error) {
 	if m.knowledgeMgr == nil {
-		return "", fmt.Errorf("knowledge manager not available")
+		return nil, fmt.Errorf("knowledge manager not available")
 	}
 
-	// 從所有 phase 檢索相關知識
-	var allKnowledge []string
+	retrieval := &knowledgeRetrieval{}
 
-	// 檢索 Phase 1 知識 (架構分析)
-	phase1Results, err := m.knowledgeMgr.RetrievePhaseKnowledge("phase1", query, 1)
-	if err == nil {
-		for _, result := range phase1Results {
+	knowledgeSources := []struct {
+		phase string
+		label string
+	}{
+		{"phase1", "Phase 1 - Schema Analysis"},      // 架構分析
+		{"phase2", "Phase 2 - Business Logic"},       // 業務邏輯分析
+		{"phase3", "Phase 3 - Business Description"}, // 商業邏輯描述
+	}
+	for _, source := range knowledgeSources {
+		results, err := m.knowledgeMgr.RetrievePhaseKnowledge(source.phase, query, 1)
+		if err != nil || len(results) == 0 {
+			retrieval.missingPhases = append(retrieval.missingPhases, source.phase)
+			continue
+		}
+		for _, result := range results {
 			// 截斷知識內容以適應 LLM 上下文限制
 			truncatedContent := result.Content
 			if len(truncatedContent) > 500 {
 				truncatedContent = truncatedContent[:500] + "..."
 			}
-			allKnowledge = append(allKnowledge, fmt.Sprintf("Phase 1 - Schema Analysis: %s", truncatedContent))
+			retrieval.chunks = append(retrieval.chunks, RetrievedKnowledge{
+				Phase:    source.phase,
+				Label:    source.label,
+				Content:  truncatedContent,
+				Score:    result.Score,
+				Metadata: result.Metadata,
+			})
+			if result.Score > retrieval.bestScore {
+				retrieval.bestScore = result.Score
+			}
 		}
 	}
 
-	// 檢索 Phase 2 知識 (業務邏輯分析)
-	phase2Results, err := m.knowledgeMgr.RetrievePhaseKnowledge("phase2", query, 1)
-	if err == nil {
-		for _, result := range phase2Results {
-			// 截斷知識內容以適應 LLM 上下文限制
-			truncatedContent := result.Content
-			if len(truncatedContent) > 500 {
-				truncatedContent = truncatedContent[:500] + "..."
+	return retrieval, nil
+}
+
+// 知識載入策略（marketing.knowledge_strategy）
+const (
+	KnowledgeStrategyVector         = "vector"           // 只使用向量檢索結果
+	KnowledgeStrategyFile           = "file"             // 只使用 Phase 1 分析檔案的鍵結構摘要
+	KnowledgeStrategyHybrid         = "hybrid"           // 向量檢索結果加上檔案摘要
+	KnowledgeStrategyVectorThenFile = "vector_then_file" // 向量檢索沒有結果時改用檔案摘要（預設）
+)
+
+// noKnowledgeFound 所有知識來源都沒有結果時放入提示的內容
+const noKnowledgeFound = "No relevant business knowledge found."
+
+// knowledgeStrategy 返回配置的知識載入策略，未設定或無法識別時使用 vector_then_file
+func (m *MarketingQueryRunner) knowledgeStrategy() string {
+	switch strategy := m.config.Marketing.KnowledgeStrategy; strategy {
+	case KnowledgeStrategyVector, KnowledgeStrategyFile, KnowledgeStrategyHybrid, KnowledgeStrategyVectorThenFile:
+		return strategy
+	case "":
+		return KnowledgeStrategyVectorThenFile
+	default:
+		log.Printf("Warning: Unknown marketing knowledge_strategy %q, using %s", strategy, KnowledgeStrategyVectorThenFile)
+		return KnowledgeStrategyVectorThenFile
+	}
+}
+
+// vectorKnowledgeText 將向量檢索結果組合為提示中的業務知識段落
+func vectorKnowledgeText(retrieval *knowledgeRetrieval) string {
+	if retrieval == nil || len(retrieval.chunks) == 0 {
+		return ""
+	}
+
+	allKnowledge := make([]string, 0, len(retrieval.chunks))
+	for _, chunk := range retrieval.chunks {
+		allKnowledge = append(allKnowledge, fmt.Sprintf("%s: %s", chunk.Label, chunk.Content))
+	}
+	return strings.Join(allKnowledge, "\n\n")
+}
+
+// fileKnowledgeText 使用 Phase 1 分析檔案中實際資料庫的鍵結構作為上下文
+func (m *MarketingQueryRunner) fileKnowledgeText() string {
+	_, keySummary, err := m.getCachedSchemaSummaries()
+	if err != nil {
+		log.Printf("Warning: Failed to build key summary from phase1 analysis: %v", err)
+		return ""
+	}
+	return keySummary
+}
+
+// loadKnowledge 依知識載入策略組合業務知識，返回知識文字、向量檢索結果（可能為 nil）與實際使用的來源
+// recordGaps 為 true 時記錄低覆蓋率的查詢
+func (m *MarketingQueryRunner) loadKnowledge(query string, recordGaps bool) (string, *knowledgeRetrieval, string, error) {
+	strategy := m.knowledgeStrategy()
+
+	var retrieval *knowledgeRetrieval
+	if strategy != KnowledgeStrategyFile {
+		var err error
+		retrieval, err = m.searchKnowledge(query)
+		if err != nil {
+			if strategy == KnowledgeStrategyVector {
+				return "", nil, "", err
 			}
-			allKnowledge = append(allKnowledge, fmt.Sprintf("Phase 2 - Business Logic: %s", truncatedContent))
+			log.Printf("Warning: Vector knowledge search failed, continuing with file knowledge: %v", err)
 		}
 	}
 
-	// 檢索 Phase 3 知識 (商業邏輯描述)
-	phase3Results, err := m.knowledgeMgr.RetrievePhaseKnowledge("phase3", query, 1)
-	if err == nil {
-		for _, result := range phase3Results {
-			// 截斷知識內容以適應 LLM 上下文限制
-			truncatedContent := result.Content
-			if len(truncatedContent) > 500 {
-				truncatedContent = truncatedContent[:500] + "..."
-			}
-			allKnowledge = append(allKnowledge, fmt.Sprintf("Phase 3 - Business Description: %s", truncatedContent))
+	// 記錄低覆蓋率的查詢，供營運人員了解需要補充的知識
+	if recordGaps && retrieval != nil && (len(retrieval.chunks) == 0 || retrieval.bestScore < m.gapScoreThreshold()) {
+		gap := CoverageGap{
+			Query:         query,
+			BestScore:     retrieval.bestScore,
+			ResultCount:   len(retrieval.chunks),
+			MissingPhases: retrieval.missingPhases,
+		}
+		if err := RecordCoverageGap(CoverageGapsPath(m.config), gap); err != nil {
+			log.Printf("Warning: Failed to record knowledge coverage gap: %v", err)
+		}
+	}
+
+	vectorText := vectorKnowledgeText(retrieval)
+	var knowledge, source string
+	switch strategy {
+	case KnowledgeStrategyVector:
+		knowledge, source = vectorText, "vector"
+	case KnowledgeStrategyFile:
+		knowledge, source = m.fileKnowledgeText(), "file"
+	case KnowledgeStrategyHybrid:
+		fileText := m.fileKnowledgeText()
+		switch {
+		case vectorText != "" && fileText != "":
+			knowledge, source = vectorText+"\n\n"+fileText, "vector+file"
+		case vectorText != "":
+			knowledge, source = vectorText, "vector"
+		default:
+			knowledge, source = fileText, "file"
+		}
+	default:
+		if vectorText != "" {
+			knowledge, source = vectorText, "vector"
+		} else {
+			knowledge, source = m.fileKnowledgeText(), "file"
 		}
 	}
 
-	if len(allKnowledge) == 0 {
-		return "No relevant business knowledge found in vector store.", nil
+	if knowledge == "" {
+		knowledge, source = noKnowledgeFound, "none"
 	}
 
-	return strings.Join(allKnowledge, "\n\n"), nil
+	log.Printf("Knowledge loaded via %s (strategy: %s, %d chars)", source, strategy, len(knowledge))
+	return knowledge, retrieval, source, nil
 }
 
-// generateSQLQuery 生成 SQL 查詢
-func (m *MarketingQueryRunner) generateSQLQuery(naturalLanguageQuery, relevantKnowledge string) (string, string, error) {
-	// 獲取數據庫架構信息
+// retrieveRelevantKnowledge 依知識載入策略檢索相關業務知識
+func (m *MarketingQueryRunner) retrieveRelevantKnowledge(query string) (string, error) {
+	knowledge, _, _, err := m.loadKnowledge(query, true)
+	return knowledge, err
+}
+
+// PromptDebug 營銷查詢送給 LLM 的完整上下文，用於診斷 SQL 生成問題
+type PromptDebug struct {
+	Query             string               `json:"query"`
+	KnowledgeStrategy string               `json:"knowledge_strategy"`
+	KnowledgeSource   string               `json:"knowledge_source"`
+	RetrievedChunks   []RetrievedKnowledge `json:"retrieved_chunks"`
+	BestScore         float64              `json:"best_score"`
+	MissingPhases     []string             `json:"missing_phases"`
+	Knowledge         string               `json:"knowledge"`
+	SchemaContext     string               `json:"schema_context"`
+	Prompt            string               `json:"prompt"`
+	RetrievalError    string               `json:"retrieval_error,omitempty"`
+}
+
+// DebugPrompt 組合與 ExecuteMarketingQuery 相同的 SQL 生成提示，但不呼叫 LLM、不記錄覆蓋缺口
+func (m *MarketingQueryRunner) DebugPrompt(naturalLanguageQuery string) (*PromptDebug, error) {
+	debug := &PromptDebug{
+		Query:             naturalLanguageQuery,
+		KnowledgeStrategy: m.knowledgeStrategy(),
+		RetrievedChunks:   []RetrievedKnowledge{},
+		MissingPhases:     []string{},
+	}
+
+	knowledge, retrieval, source, err := m.loadKnowledge(naturalLanguageQuery, false)
+	if err != nil {
+		// 與 ExecuteMarketingQuery 相同的降級行為
+		debug.RetrievalError = err.Error()
+		knowledge, source = noKnowledgeFound, "none"
+	}
+	debug.Knowledge = knowledge
+	debug.KnowledgeSource = source
+	if retrieval != nil {
+		debug.RetrievedChunks = append(debug.RetrievedChunks, retrieval.chunks...)
+		debug.BestScore = retrieval.bestScore
+		debug.MissingPhases = append(debug.MissingPhases, retrieval.missingPhases...)
+	}
+
 	schemaInfo, err := m.getDatabaseSchemaInfo()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get database schema: %v", err)
+		return nil, fmt.Errorf("failed to get database schema: %v", err)
 	}
+	if columnContext := m.columnDescriptionContext(naturalLanguageQuery, debug.Knowledge); columnContext != "" {
+		schemaInfo += "\n" + columnContext
+	}
+	debug.SchemaContext = schemaInfo
+	debug.Prompt = buildSQLGenerationPrompt(m.config.Database.Type, schemaInfo, debug.Knowledge, naturalLanguageQuery)
 
-	// 構造 LLM 提示 - 強制使用向量知識生成 SQL
-	prompt := fmt.Sprintf(`You are a SQL expert. You MUST use the business knowledge from our vector database to generate accurate SQL queries.
+	return debug, nil
+}
 
-Database Schema:
-%s
+// generateSQLQuery 生成 SQL 查詢，未通過安全檢查時返回錯誤
+func (m *MarketingQueryRunner) generateSQLQuery(naturalLanguageQuery, relevantKnowledge string) (string, string, error) {
+	sqlQuery, err := m.draftSQLQuery(naturalLanguageQuery, relevantKnowledge)
+	if err != nil {
+		return "", "", err
+	}
 
-Business Knowledge from Vector Database:
-%s
+	// 驗證 SQL 查詢安全性
+	if err := m.validateSQLQuery(sqlQuery); err != nil {
+		log.Printf("SQL query failed security validation: %s", sqlQuery)
+		return "", "", fmt.Errorf("generated SQL query failed security validation: %v", err)
+	}
 
-User Question: %s
+	return sqlQuery, sqlQueryExplanation(naturalLanguageQuery), nil
+}
 
-CRITICAL REQUIREMENTS:
-1. You MUST analyze the business knowledge to understand table relationships and column meanings
-2. Generate ONLY SELECT queries that directly answer the user's question
-3. Use EXACTLY the table names and column names found in the Database Schema above
-4. Do NOT invent column names - use only the columns listed in the schema
-5. For date filtering, use 'created_at' column if available, not 'order_date'
-6. Include appropriate JOINs, WHERE, GROUP BY, ORDER BY clauses as needed
-7. Limit results to maximum 50 rows for performance
-8. If the business knowledge doesn't contain enough information, still attempt to generate the best possible SQL based on the schema
+// previewSQLQuery 生成 SQL 並執行安全檢查但不執行查詢；未通過檢查的 SQL 仍會返回，原因記錄在 Warnings
+func (m *MarketingQueryRunner) previewSQLQuery(result *MarketingQueryResult, naturalLanguageQuery, relevantKnowledge string) {
+	sqlQuery, err := m.draftSQLQuery(naturalLanguageQuery, relevantKnowledge)
+	if err != nil {
+		result.Error = fmt.Sprintf("Failed to generate SQL query: %v", err)
+		return
+	}
 
-Return ONLY the SQL query without any explanations or markdown formatting:`, schemaInfo, relevantKnowledge, naturalLanguageQuery)
+	result.SQLQuery = sqlQuery
+	result.Explanation = sqlQueryExplanation(naturalLanguageQuery)
+	if err := m.validateSQLQuery(sqlQuery); err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("SQL query failed security validation: %v", err))
+	}
+	log.Printf("Marketing query previewed, SQL not executed (%d warnings)", len(result.Warnings))
+}
+
+// sqlQueryExplanation SQL 的生成說明
+func sqlQueryExplanation(naturalLanguageQuery string) string {
+	return fmt.Sprintf("SQL query generated by LLM using business knowledge from vector database to answer: %s", naturalLanguageQuery)
+}
+
+// draftSQLQuery 以 LLM 生成 SQL 並取出第一個 SELECT 語句，不做安全檢查
+func (m *MarketingQueryRunner) draftSQLQuery(naturalLanguageQuery, relevantKnowledge string) (string, error) {
+	// 獲取數據庫架構信息
+	schemaInfo, err := m.getDatabaseSchemaInfo()
+	if err != nil {
+		return "", fmt.Errorf("failed to get database schema: %v", err)
+	}
+
+	// 加入相關表格的欄位描述，讓 LLM 理解欄位含義
+	if columnContext := m.columnDescriptionContext(naturalLanguageQuery, relevantKnowledge); columnContext != "" {
+		schemaInfo += "\n" + columnContext
+	}
+
+	// 構造 LLM 提示 - 強制使用向量知識生成 SQL
+	prompt := buildSQLGenerationPrompt(m.config.Database.Type, schemaInfo, relevantKnowledge, naturalLanguageQuery)
 
 	// 調用 LLM 生成 SQL
 	response, err := m.llmClient.GenerateCompletion(context.Background(), prompt)
 	if err != nil {
 		// 如果 LLM 完全失敗，返回錯誤而不是使用寫死 SQL
-		return "", "", fmt.Errorf("LLM failed to generate SQL query: %v. Business knowledge may be insufficient or LLM service unavailable", err)
+		return "", fmt.Errorf("LLM failed to generate SQL query: %v. Business knowledge may be insufficient or LLM service unavailable", err)
 	}
 
 	// 清理響應，提取 SQL 查詢
-	sqlQuery := strings.TrimSpace(response)
-
-	// 移除可能的 markdown 代碼塊標記
-	sqlQuery = strings.TrimPrefix(sqlQuery, "```sql")
-	sqlQuery = strings.TrimPrefix(sqlQuery, "```")
-	sqlQuery = strings.TrimSuffix(sqlQuery, "```")
+	sqlQuery := trimSQLCodeFence(response)
 
 	// 如果響應包含多行，只取第一個 SELECT 語句
 	lines := strings.Split(sqlQuery, "\n")
@@ -222,35 +470,103 @@ Return ONLY the SQL query without any explanations or markdown formatting:`, sch
 
 	log.Printf("Generated SQL query: %s", sqlQuery)
 
-	// 驗證 SQL 查詢安全性
-	if !m.isSafeSQLQuery(sqlQuery) {
-		log.Printf("SQL query failed security validation: %s", sqlQuery)
-		return "", "", fmt.Errorf("generated SQL query failed security validation")
+	return sqlQuery, nil
+}
+
+// trimSQLCodeFence 移除 LLM 回應前後的 markdown 代碼塊標記（```sql、```mysql 等語言標記）
+// 只處理三個反引號，MySQL 以單一反引號引用的識別字保持不變
+func trimSQLCodeFence(response string) string {
+	text := strings.TrimSpace(response)
+	if strings.HasPrefix(text, "```") {
+		text = text[3:]
+		if newline := strings.Index(text, "\n"); newline >= 0 && isCodeFenceLanguage(text[:newline]) {
+			text = text[newline+1:]
+		}
 	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
 
-	// 確保是 SELECT 查詢
-	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(sqlQuery)), "SELECT") {
-		return "", "", fmt.Errorf("generated query is not a SELECT statement")
+// isCodeFenceLanguage 判斷代碼塊開頭的文字是否為語言標記（空白或只含字母）
+func isCodeFenceLanguage(tag string) bool {
+	for _, r := range strings.TrimSpace(tag) {
+		if !unicode.IsLetter(r) {
+			return false
+		}
 	}
+	return true
+}
 
-	explanation := fmt.Sprintf("SQL query generated by LLM using business knowledge from vector database to answer: %s", naturalLanguageQuery)
+// buildSQLGenerationPrompt 構造 SQL 生成的 LLM 提示，SQL 語法依 database.type 指定
+func buildSQLGenerationPrompt(dbType, schemaInfo, relevantKnowledge, naturalLanguageQuery string) string {
+	return fmt.Sprintf(`You are a SQL expert. You MUST use the business knowledge from our vector database to generate accurate SQL queries.
+
+Database Schema:
+%s
+
+Business Knowledge from Vector Database:
+%s
 
-	return sqlQuery, explanation, nil
+User Question: %s
+
+CRITICAL REQUIREMENTS:
+1. You MUST analyze the business knowledge to understand table relationships and column meanings
+2. Generate ONLY SELECT queries that directly answer the user's question
+3. Use EXACTLY the table names and column names found in the Database Schema above
+4. Do NOT invent column names - use only the columns listed in the schema
+5. For date filtering, use 'created_at' column if available, not 'order_date'
+6. Include appropriate JOINs, WHERE, GROUP BY, ORDER BY clauses as needed
+7. Limit results to maximum 50 rows for performance
+8. If the business knowledge doesn't contain enough information, still attempt to generate the best possible SQL based on the schema
+9. %s
+
+Return ONLY the SQL query without any explanations or markdown formatting:`, schemaInfo, relevantKnowledge, naturalLanguageQuery, sqlDialectHint(dbType))
+}
+
+// sqlDialectHint 依資料庫類型說明 LLM 應使用的 SQL 方言與日期函數，避免在 MySQL 上生成 date_trunc 等 PostgreSQL 專用語法
+func sqlDialectHint(dbType string) string {
+	switch dbType {
+	case "mysql":
+		return "Use MySQL syntax: quote identifiers with backticks only when needed, format and group dates with DATE_FORMAT(col, '%Y-%m') or DATE(col), " +
+			"do date arithmetic with DATE_SUB(NOW(), INTERVAL 30 DAY), concatenate with CONCAT(), and page with LIMIT n OFFSET m. " +
+			"Do NOT use PostgreSQL-only syntax such as date_trunc, to_char, ILIKE, :: casts or double-quoted identifiers"
+	case "sqlite", "sqlite3":
+		return "Use SQLite syntax: format and group dates with strftime('%Y-%m', col), do date arithmetic with date('now', '-30 days'), " +
+			"concatenate with ||, and page with LIMIT n OFFSET m. Do NOT use date_trunc, to_char, DATE_FORMAT, ILIKE or :: casts"
+	default:
+		return "Use PostgreSQL syntax: group dates with date_trunc('month', col) and format them with to_char(col, 'YYYY-MM'), " +
+			"do date arithmetic with NOW() - INTERVAL '30 days', use ILIKE for case-insensitive matching, and page with LIMIT n OFFSET m. " +
+			"Do NOT use MySQL-only syntax such as backtick-quoted identifiers, DATE_FORMAT or LIMIT m, n"
+	}
 }
 
 // getDatabaseSchemaInfo 獲取數據庫架構信息
 func (m *MarketingQueryRunner) getDatabaseSchemaInfo() (string, error) {
-	// 查詢所有表格及其欄位
-	rows, err := m.db.Query(`
+	// 優先使用 phase1 分析結果建立架構摘要，反映實際資料庫內容
+	summary, _, err := m.getCachedSchemaSummaries()
+	if err == nil {
+		return summary, nil
+	}
+	log.Printf("Warning: Failed to build schema summary from phase1 analysis, querying database: %v", err)
+
+	// 查詢所有表格及其欄位（掃描多個 schema 時表格名稱帶 schema 前綴）
+	schemas := m.config.GetDatabaseSchemas()
+	placeholders := make([]string, len(schemas))
+	args := make([]interface{}, len(schemas))
+	for i, schema := range schemas {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = schema
+	}
+	rows, err := m.db.Query(fmt.Sprintf(`
 		SELECT
+			t.table_schema,
 			t.table_name,
 			array_agg(c.column_name || ' ' || c.data_type || CASE WHEN c.is_nullable = 'NO' THEN ' NOT NULL' ELSE '' END) as columns
 		FROM information_schema.tables t
-		JOIN information_schema.columns c ON t.table_name = c.table_name
-		WHERE t.table_schema = 'public'
-		GROUP BY t.table_name
-		ORDER BY t.table_name
-	`)
+		JOIN information_schema.columns c ON t.table_name = c.table_name AND t.table_schema = c.table_schema
+		WHERE t.table_schema IN (%s)
+		GROUP BY t.table_schema, t.table_name
+		ORDER BY t.table_schema, t.table_name
+	`, strings.Join(placeholders, ", ")), args...)
 	if err != nil {
 		return "", fmt.Errorf("failed to query schema: %v", err)
 	}
@@ -260,9 +576,16 @@ func (m *MarketingQueryRunner) getDatabaseSchemaInfo() (string, error) {
 	schemaInfo.WriteString("Database Tables:\n")
 
 	for rows.Next() {
-		var tableName string
+		var schemaName, tableName string
 		var columns []string
-		if err := rows.Scan(&tableName, &columns); err != nil {
+		if err := rows.Scan(&schemaName, &tableName, &columns); err != nil {
+			continue
+		}
+		if len(schemas) > 1 {
+			tableName = schemaName + "." + tableName
+		}
+
+		if m.isExcludedTable(tableName) {
 			continue
 		}
 
@@ -275,93 +598,440 @@ func (m *MarketingQueryRunner) getDatabaseSchemaInfo() (string, error) {
 	return schemaInfo.String(), nil
 }
 
-// isSafeSQLQuery 檢查 SQL 查詢是否安全
-func (m *MarketingQueryRunner) isSafeSQLQuery(query string) bool {
-	upperQuery := strings.ToUpper(strings.TrimSpace(query))
+// getCachedSchemaSummaries 取得快取的架構摘要與鍵摘要，phase1 分析檔案更新後重新計算
+func (m *MarketingQueryRunner) getCachedSchemaSummaries() (string, string, error) {
+	info, err := os.Stat(phase1AnalysisPath(m.config))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to stat phase1 analysis: %v", err)
+	}
+
+	m.schemaCacheMu.Lock()
+	defer m.schemaCacheMu.Unlock()
 
-	// 只允許 SELECT 查詢
-	if !strings.HasPrefix(upperQuery, "SELECT") {
-		log.Printf("Query rejected: not a SELECT statement")
-		return false
+	if m.schemaSummary != "" && info.ModTime().Equal(m.schemaCacheModTime) {
+		return m.schemaSummary, m.keySummary, nil
 	}
 
-	// 不允許危險的關鍵字 (使用詞邊界檢查)
-	dangerousKeywords := []string{
-		"DROP", "DELETE", "UPDATE", "INSERT", "ALTER", "CREATE", "TRUNCATE",
-		"EXEC", "EXECUTE", "MERGE", "BULK", "BACKUP", "RESTORE",
+	schemaSummary, err := m.buildPhase1SchemaSummary()
+	if err != nil {
+		return "", "", err
+	}
+	keySummary, err := m.buildPhase1KeySummary()
+	if err != nil {
+		return "", "", err
 	}
 
-	// 將查詢分割成單詞來檢查
-	words := strings.Fields(upperQuery)
-	for _, word := range words {
-		// 移除標點符號
-		word = strings.Trim(word, ".,;()[]")
-		for _, keyword := range dangerousKeywords {
-			if word == keyword {
-				log.Printf("Query rejected: contains dangerous keyword '%s'", keyword)
-				return false
+	m.schemaSummary = schemaSummary
+	m.keySummary = keySummary
+	m.schemaCacheModTime = info.ModTime()
+	log.Printf("Schema summary cache refreshed from %s", phase1AnalysisPath(m.config))
+
+	return schemaSummary, keySummary, nil
+}
+
+// phase1SchemaTable 架構摘要使用的表格資訊
+type phase1SchemaTable struct {
+	name        string
+	rowCount    int
+	primaryKeys []string
+	foreignKeys map[string]string // 欄位 -> 參照表格.欄位
+	columns     []map[string]interface{}
+}
+
+// loadPhase1SchemaTables 從 phase1 分析結果載入表格，排除停用表格後依行數取前 N 個
+func (m *MarketingQueryRunner) loadPhase1SchemaTables() ([]phase1SchemaTable, error) {
+	reader := NewPhase1ResultReader(phase1AnalysisPath(m.config))
+	result, err := reader.ReadResult()
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []phase1SchemaTable
+	for tableName, analysis := range result.Tables {
+		if m.isExcludedTable(tableName) {
+			continue
+		}
+
+		table := phase1SchemaTable{
+			name:        tableName,
+			foreignKeys: make(map[string]string),
+			columns:     analysis.Schema,
+		}
+		table.rowCount, _ = getRowCount(analysis.Stats)
+
+		if pks, ok := analysis.Constraints["primary_keys"].([]interface{}); ok {
+			for _, pk := range pks {
+				if pkName, ok := pk.(string); ok {
+					table.primaryKeys = append(table.primaryKeys, pkName)
+				}
+			}
+		}
+
+		if fks, ok := analysis.Constraints["foreign_keys"].([]interface{}); ok {
+			for _, fk := range fks {
+				if fkMap, ok := fk.(map[string]interface{}); ok {
+					columns, refColumns := foreignKeyColumns(fkMap)
+					for i, column := range columns {
+						if i < len(refColumns) {
+							table.foreignKeys[column] = fmt.Sprintf("%v.%v", fkMap["referenced_table"], refColumns[i])
+						}
+					}
+				}
 			}
 		}
+
+		tables = append(tables, table)
 	}
 
-	return true
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no tables found in phase1 analysis")
+	}
+
+	// 依行數降序排序，行數相同時依名稱排序
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].rowCount != tables[j].rowCount {
+			return tables[i].rowCount > tables[j].rowCount
+		}
+		return tables[i].name < tables[j].name
+	})
+
+	limit := m.config.Marketing.SchemaTableLimit
+	if limit <= 0 {
+		limit = 30
+	}
+	if len(tables) > limit {
+		tables = tables[:limit]
+	}
+
+	return tables, nil
 }
 
-// executeSQLQuery 執行 SQL 查詢
-func (m *MarketingQueryRunner) executeSQLQuery(sqlQuery string) ([]map[string]interface{}, error) {
-	// 執行查詢
-	rows, err := m.db.Query(sqlQuery)
+// buildPhase1SchemaSummary 從 phase1 分析結果建立架構摘要（依行數取前 N 個表格及其欄位）
+func (m *MarketingQueryRunner) buildPhase1SchemaSummary() (string, error) {
+	tables, err := m.loadPhase1SchemaTables()
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %v", err)
+		return "", err
 	}
-	defer rows.Close()
 
-	// 獲取欄位名稱
-	columns, err := rows.Columns()
+	var schemaInfo strings.Builder
+	schemaInfo.WriteString("Database Tables:\n")
+
+	for _, table := range tables {
+		schemaInfo.WriteString(fmt.Sprintf("\nTable: %s (%d rows)\n", table.name, table.rowCount))
+		for _, col := range table.columns {
+			colName, _ := col["name"].(string)
+			line := fmt.Sprintf("  - %s %v", colName, col["type"])
+			if nullable, ok := col["nullable"].(bool); ok && !nullable {
+				line += " NOT NULL"
+			}
+			if containsStringInSlice(table.primaryKeys, colName) {
+				line += " PRIMARY KEY"
+			}
+			if ref, ok := table.foreignKeys[colName]; ok {
+				line += " REFERENCES " + ref
+			}
+			schemaInfo.WriteString(line + "\n")
+		}
+	}
+
+	return schemaInfo.String(), nil
+}
+
+// buildPhase1KeySummary 從 phase1 分析結果建立精簡的鍵摘要（表格名稱、主鍵與外鍵）
+func (m *MarketingQueryRunner) buildPhase1KeySummary() (string, error) {
+	tables, err := m.loadPhase1SchemaTables()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get columns: %v", err)
+		return "", err
 	}
 
-	// 讀取結果
-	var results []map[string]interface{}
-	count := 0
-	const maxRows = 50
+	var summary strings.Builder
+	summary.WriteString("Database Key Structure (from Phase 1 analysis):\n")
 
-	for rows.Next() && count < maxRows {
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
+	for _, table := range tables {
+		summary.WriteString(fmt.Sprintf("- %s", table.name))
+		if len(table.primaryKeys) > 0 {
+			summary.WriteString(fmt.Sprintf(" PK(%s)", strings.Join(table.primaryKeys, ", ")))
 		}
 
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %v", err)
+		columns := make([]string, 0, len(table.foreignKeys))
+		for column := range table.foreignKeys {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		for _, column := range columns {
+			summary.WriteString(fmt.Sprintf(" FK(%s -> %s)", column, table.foreignKeys[column]))
 		}
+		summary.WriteString("\n")
+	}
 
-		row := make(map[string]interface{})
-		for i, col := range columns {
-			val := values[i]
-			if val != nil {
-				switch v := val.(type) {
-				case []byte:
-					row[col] = string(v)
-				default:
-					row[col] = v
-				}
-			} else {
-				row[col] = nil
+	return summary.String(), nil
+}
+
+// columnDescriptionContext 為提示中的表格組合欄位描述；優先只列出查詢或知識中提到的表格
+func (m *MarketingQueryRunner) columnDescriptionContext(query, knowledge string) string {
+	tables, err := m.loadPhase1SchemaTables()
+	if err != nil {
+		return ""
+	}
+
+	descriptions, err := m.loadColumnDescriptions(tables)
+	if err != nil {
+		log.Printf("Warning: Failed to load column descriptions: %v", err)
+		return ""
+	}
+	if len(descriptions) == 0 {
+		return ""
+	}
+
+	text := strings.ToLower(query + "\n" + knowledge)
+	var mentioned, all []string
+	for _, table := range tables {
+		all = append(all, table.name)
+		if strings.Contains(text, strings.ToLower(table.name)) {
+			mentioned = append(mentioned, table.name)
+		}
+	}
+	if len(mentioned) > 0 {
+		return formatColumnDescriptions(descriptions, mentioned)
+	}
+	return formatColumnDescriptions(descriptions, all)
+}
+
+// loadColumnDescriptions 載入提示中表格的欄位描述對照表，尚無 Phase 2 結果時返回空
+func (m *MarketingQueryRunner) loadColumnDescriptions(tables []phase1SchemaTable) (map[string]map[string]string, error) {
+	if _, err := os.Stat(Phase2AnalysisPath(m.config)); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	schemas := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		for _, col := range table.columns {
+			if name, ok := col["name"].(string); ok {
+				schemas[table.name] = append(schemas[table.name], name)
 			}
 		}
+	}
 
-		results = append(results, row)
+	return LoadColumnDescriptions(Phase2AnalysisPath(m.config), schemas)
+}
+
+// isExcludedTable 檢查表格是否在營銷查詢的排除列表中
+func (m *MarketingQueryRunner) isExcludedTable(tableName string) bool {
+	for _, excluded := range m.config.Marketing.ExcludedTables {
+		if strings.EqualFold(excluded, tableName) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSQLQuery 檢查 SQL 查詢是否允許執行：ReadOnly 或安全模式下只允許不含寫入關鍵字的單一 SELECT/CTE，
+// 字串與註解中的內容不列入檢查，結尾以外的分號一律拒絕
+func (m *MarketingQueryRunner) validateSQLQuery(query string) error {
+	if !m.ReadOnly && !sqlguard.SafeMode(m.config) {
+		return nil
+	}
+	query = sqlguard.TrimTerminator(query)
+	if err := sqlguard.CheckReadOnly(m.config, query); err != nil {
+		log.Printf("Query rejected: %v", err)
+		return err
+	}
+	if err := sqlguard.CheckQuery(m.config, query); err != nil {
+		log.Printf("Query rejected: %v", err)
+		return err
+	}
+	return nil
+}
+
+// resultLimit 取得查詢結果回傳的最大行數
+func (m *MarketingQueryRunner) resultLimit() int {
+	if m.config.Marketing.ResultLimit > 0 {
+		return m.config.Marketing.ResultLimit
+	}
+	return 50
+}
+
+// gapScoreThreshold 返回判定知識覆蓋不足的相似度門檻
+func (m *MarketingQueryRunner) gapScoreThreshold() float64 {
+	if m.config.Marketing.GapScoreThreshold > 0 {
+		return m.config.Marketing.GapScoreThreshold
+	}
+	return 0.3
+}
+
+// 未設定時的查詢期限與下載行數上限
+const (
+	defaultMarketingQueryTimeout = 30 * time.Second
+	defaultDownloadRowLimit      = 10000
+)
+
+// queryTimeout 單一查詢的執行期限（security.max_query_time）
+func (m *MarketingQueryRunner) queryTimeout() time.Duration {
+	if m.config.Security.MaxQueryTime > 0 {
+		return time.Duration(m.config.Security.MaxQueryTime) * time.Second
+	}
+	return defaultMarketingQueryTimeout
+}
+
+// downloadRowLimit 下載/匯出完整結果的最大行數
+func (m *MarketingQueryRunner) downloadRowLimit() int {
+	if m.config.Marketing.DownloadRowLimit > 0 {
+		return m.config.Marketing.DownloadRowLimit
+	}
+	return defaultDownloadRowLimit
+}
+
+// checkQueryTables 拒絕引用 marketing.excluded_tables 或符合 schema.exclude_patterns 的名稱的查詢；
+// 查詢中的名稱無法區分表格與欄位，與排除表格同名的欄位也會被拒絕
+func (m *MarketingQueryRunner) checkQueryTables(query string) error {
+	names, err := sqlguard.ReferencedNames(m.config, query)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if m.config.Schema.TableExcluded(name) {
+			return fmt.Errorf("query references excluded table %s", name)
+		}
+		for _, excluded := range m.config.Marketing.ExcludedTables {
+			// 帶 schema 前綴的排除項目同時比對表格部分
+			if strings.EqualFold(name, excluded) || strings.EqualFold(name, excluded[strings.LastIndex(excluded, ".")+1:]) {
+				return fmt.Errorf("query references excluded table %s", name)
+			}
+		}
+	}
+	return nil
+}
+
+// streamQuery 驗證並以 security.max_query_time 為期限執行查詢，依序對最多 maxRows 行呼叫 emit；
+// 結果依 security.sample_masking 遮罩，columns（可為 nil）在讀取第一行前以輸出的欄位呼叫。
+// 只多讀一行判斷是否截斷，其餘結果不讀取，返回處理的行數與是否截斷
+func (m *MarketingQueryRunner) streamQuery(ctx context.Context, sqlQuery string, maxRows int, columns func([]string) error, emit func(map[string]interface{}) error) (int, bool, error) {
+	sqlQuery = sqlguard.TrimTerminator(sqlQuery)
+	// 執行前再次檢查，避免未經驗證的查詢直接送往資料庫
+	if err := m.validateSQLQuery(sqlQuery); err != nil {
+		return 0, false, fmt.Errorf("query rejected: %v", err)
+	}
+	if err := m.checkQueryTables(sqlQuery); err != nil {
+		return 0, false, fmt.Errorf("query rejected: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.queryTimeout())
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, sqlQuery)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get columns: %v", err)
+	}
+	// 欄位型別用於將數字與日期轉為對應的 Go 型別，而非字串
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get column types: %v", err)
+	}
+
+	masker := privacy.NewResultMasker(m.config.Security.SampleMasking)
+	if columns != nil {
+		if err := columns(masker.Columns(names)); err != nil {
+			return 0, false, err
+		}
+	}
+
+	count := 0
+	for rows.Next() {
+		if count == maxRows {
+			// 先取消查詢，避免驅動程式在關閉結果集時讀完其餘的行
+			cancel()
+			return count, true, nil
+		}
+
+		row, err := analyzer.ScanTypedRow(rows, names, columnTypes)
+		if err != nil {
+			return count, false, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if err := emit(masker.MaskRow(row)); err != nil {
+			return count, false, err
+		}
 		count++
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error reading rows: %v", err)
+		return count, false, fmt.Errorf("error reading rows: %v", err)
 	}
+	return count, false, nil
+}
 
-	return results, nil
+// executeSQLQuery 執行 SQL 查詢，最多回傳 limit 行；結果被截斷時另以 COUNT(*) 取得總行數，無法取得時總行數為 0
+func (m *MarketingQueryRunner) executeSQLQuery(sqlQuery string, limit int) ([]map[string]interface{}, int, bool, error) {
+	var results []map[string]interface{}
+	count, truncated, err := m.streamQuery(context.Background(), sqlQuery, limit, nil, func(row map[string]interface{}) error {
+		results = append(results, row)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if !truncated {
+		return results, count, false, nil
+	}
+
+	total, err := m.countQueryRows(sqlQuery)
+	if err != nil {
+		log.Printf("Warning: Failed to count query rows: %v", err)
+		total = 0
+	}
+	return results, total, true, nil
+}
+
+// countQueryRows 以 COUNT(*) 包裝已通過檢查的查詢取得總行數，同樣受 security.max_query_time 限制
+func (m *MarketingQueryRunner) countQueryRows(sqlQuery string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.queryTimeout())
+	defer cancel()
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (\n%s\n) AS total_rows", sqlguard.TrimTerminator(sqlQuery))
+	if err := m.db.QueryRowContext(ctx, countQuery).Scan(&total); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// ExportResultsCSV 重新執行查詢，將結果以 CSV 串流寫入 w（欄位依查詢順序），最多 marketing.download_row_limit 行；
+// 與營銷查詢套用相同的安全檢查、排除表格與遮罩，返回寫出的行數與是否截斷
+func (m *MarketingQueryRunner) ExportResultsCSV(ctx context.Context, w io.Writer, sqlQuery string) (int, bool, error) {
+	writer := csv.NewWriter(w)
+
+	var columns []string
+	count, truncated, err := m.streamQuery(ctx, sqlQuery, m.downloadRowLimit(),
+		func(names []string) error {
+			columns = names
+			if err := writer.Write(columns); err != nil {
+				return fmt.Errorf("failed to write CSV header: %v", err)
+			}
+			return nil
+		},
+		func(row map[string]interface{}) error {
+			record := make([]string, len(columns))
+			for i, col := range columns {
+				if row[col] != nil {
+					record[i] = fmt.Sprintf("%v", row[col])
+				}
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("failed to write CSV row: %v", err)
+			}
+			return nil
+		})
+
+	writer.Flush()
+	if err != nil {
+		return count, truncated, err
+	}
+	return count, truncated, writer.Error()
 }
 
 // generateBusinessInsights 生成業務洞察
@@ -401,7 +1071,7 @@ Keep the response concise but insightful. Focus on actionable business insights.
 }
 
 // SaveQueryResult 保存查詢結果
-func (m *MarketingQueryRunner) SaveQueryResult(result *QueryResult) error {
+func (m *MarketingQueryRunner) SaveQueryResult(result *MarketingQueryResult) error {
 	if m.knowledgeMgr == nil {
 		return fmt.Errorf("knowledge manager not available")
 	}
@@ -412,7 +1082,7 @@ func (m *MarketingQueryRunner) SaveQueryResult(result *QueryResult) error {
 		"description":        "Marketing query result with business insights",
 		"query":              result.Query,
 		"sql_query":          result.SQLQuery,
-		"result_count":       len(result.Results),
+		"result_count":       result.TotalRows,
 		"has_error":          result.Error != "",
 		"timestamp":          result.Timestamp,
 		"insights_generated": result.BusinessInsights != "",
@@ -0,0 +1,219 @@
+package phases
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/masato25/aika-dba/config"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestBuildSQLGenerationPromptDialectHint(t *testing.T) {
+	tests := []struct {
+		dbType  string
+		want    []string
+		notWant []string
+	}{
+		{"postgres", []string{"Use PostgreSQL syntax", "date_trunc", "to_char"}, []string{"Use MySQL syntax", "Use SQLite syntax"}},
+		{"", []string{"Use PostgreSQL syntax"}, []string{"Use MySQL syntax"}},
+		{"mysql", []string{"Use MySQL syntax", "DATE_FORMAT", "backticks"}, []string{"Use PostgreSQL syntax", "Use SQLite syntax"}},
+		{"sqlite", []string{"Use SQLite syntax", "strftime"}, []string{"Use PostgreSQL syntax", "Use MySQL syntax"}},
+		{"sqlite3", []string{"Use SQLite syntax"}, []string{"Use MySQL syntax"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dbType, func(t *testing.T) {
+			prompt := buildSQLGenerationPrompt(tt.dbType, "customers(id, name)", "knowledge", "top customers")
+			for _, want := range tt.want {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt for %q does not contain %q", tt.dbType, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(prompt, notWant) {
+					t.Errorf("prompt for %q contains %q", tt.dbType, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestTrimSQLCodeFence(t *testing.T) {
+	tests := []struct {
+		response string
+		want     string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"```sql\nSELECT 1\n```", "SELECT 1"},
+		{"```mysql\nSELECT `order` FROM t\n```", "SELECT `order` FROM t"},
+		{"```\nSELECT 1\n```", "SELECT 1"},
+		{"SELECT `order` FROM `t`", "SELECT `order` FROM `t`"},
+	}
+
+	for _, tt := range tests {
+		if got := trimSQLCodeFence(tt.response); got != tt.want {
+			t.Errorf("trimSQLCodeFence(%q) = %q, want %q", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSQLQueryReadOnly(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Database.Type = "postgres"
+
+	tests := []struct {
+		name     string
+		readOnly bool
+		query    string
+		wantErr  bool
+	}{
+		{"select", true, "SELECT id FROM customers", false},
+		{"trailing semicolon", true, "SELECT id FROM customers;", false},
+		{"keyword inside literal", true, "SELECT * FROM notes WHERE note = 'update me'", false},
+		{"keyword inside comment", true, "SELECT 1 /* delete */ FROM t", false},
+		{"created_at column", true, "SELECT created_at FROM orders", false},
+		{"delete", true, "DELETE FROM customers", true},
+		{"second statement", true, "SELECT 1; DELETE FROM customers", true},
+		{"semicolon between selects", true, "SELECT 1; SELECT 2", true},
+		{"backtick comment trick", true, "SELECT 1 /*`*/; DELETE FROM customers; --`", true},
+		{"not read only", false, "DELETE FROM customers", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &MarketingQueryRunner{config: cfg, ReadOnly: tt.readOnly}
+			err := runner.validateSQLQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateSQLQuery(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// newSQLiteMarketingRunner 以記憶體 SQLite 建立唯讀的營銷查詢執行器，customers 表格含 5 行
+func newSQLiteMarketingRunner(t *testing.T, cfg *config.Config) *MarketingQueryRunner {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(`CREATE TABLE customers (id INTEGER, email TEXT, note TEXT);
+		INSERT INTO customers VALUES (1, 'a@example.com', 'x'), (2, 'b@example.com', 'y'), (3, 'c@example.com', 'z@example.com'), (4, 'd@example.com', 'w'), (5, 'e@example.com', 'v');
+		CREATE TABLE audit_log (id INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Database.Type = "sqlite"
+	return &MarketingQueryRunner{config: cfg, db: db, ReadOnly: true}
+}
+
+func TestExecuteSQLQueryLimit(t *testing.T) {
+	runner := newSQLiteMarketingRunner(t, &config.Config{})
+
+	tests := []struct {
+		name          string
+		limit         int
+		wantRows      int
+		wantTotal     int
+		wantTruncated bool
+	}{
+		{"truncated", 2, 2, 5, true},
+		{"exact", 5, 5, 5, false},
+		{"under limit", 10, 5, 5, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, total, truncated, err := runner.executeSQLQuery("SELECT id FROM customers ORDER BY id;", tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != tt.wantRows || total != tt.wantTotal || truncated != tt.wantTruncated {
+				t.Errorf("got %d rows, total %d, truncated %v; want %d, %d, %v", len(results), total, truncated, tt.wantRows, tt.wantTotal, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestExportResultsCSV(t *testing.T) {
+	tests := []struct {
+		name          string
+		configure     func(cfg *config.Config)
+		query         string
+		wantCSV       string
+		wantTruncated bool
+		wantErr       string
+	}{
+		{
+			name:          "row limit",
+			configure:     func(cfg *config.Config) { cfg.Marketing.DownloadRowLimit = 2 },
+			query:         "SELECT note, id FROM customers ORDER BY id",
+			wantCSV:       "note,id\nx,1\ny,2\n",
+			wantTruncated: true,
+		},
+		{
+			name: "mask detected pii",
+			configure: func(cfg *config.Config) {
+				cfg.Security.SampleMasking = config.SampleMaskingConfig{Enabled: true, Mode: "mask", DetectPII: true}
+			},
+			query:   "SELECT id, email, note FROM customers WHERE id IN (1, 3) ORDER BY id",
+			wantCSV: "id,email,note\n1,***,x\n3,***,***\n",
+		},
+		{
+			name: "omit masked column",
+			configure: func(cfg *config.Config) {
+				cfg.Security.SampleMasking = config.SampleMaskingConfig{Enabled: true, Mode: "omit", Columns: []string{"NOTE"}}
+			},
+			query:   "SELECT id, note FROM customers WHERE id = 1",
+			wantCSV: "id\n1\n",
+		},
+		{
+			name:      "schema exclude pattern",
+			configure: func(cfg *config.Config) { cfg.Schema.ExcludePatterns = []string{"*_log"} },
+			query:     `SELECT * FROM "audit_log"`,
+			wantErr:   "excluded table audit_log",
+		},
+		{
+			name:      "marketing excluded table",
+			configure: func(cfg *config.Config) { cfg.Marketing.ExcludedTables = []string{"main.customers"} },
+			query:     "SELECT id FROM main.customers",
+			wantErr:   "excluded table",
+		},
+		{
+			name:    "write rejected",
+			query:   "DELETE FROM customers",
+			wantErr: "query rejected",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			if tt.configure != nil {
+				tt.configure(cfg)
+			}
+			runner := newSQLiteMarketingRunner(t, cfg)
+
+			var buf bytes.Buffer
+			_, truncated, err := runner.ExportResultsCSV(context.Background(), &buf, tt.query)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ExportResultsCSV(%q) error = %v, want %q", tt.query, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.wantCSV || truncated != tt.wantTruncated {
+				t.Errorf("got %q (truncated %v), want %q (truncated %v)", buf.String(), truncated, tt.wantCSV, tt.wantTruncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package phases
+
+import "fmt"
+
+// nativeEnumValues 返回欄位由資料庫 ENUM 型別定義的完整值域（phase1 schema 的 enum_values），非原生 ENUM 返回 nil
+func nativeEnumValues(col map[string]interface{}) []string {
+	switch v := col["enum_values"].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprintf("%v", item))
+		}
+		return values
+	}
+	return nil
+}
+
+// columnNativeEnumValues 在 phase1 表格資料中查找欄位的原生 ENUM 值域
+func columnNativeEnumValues(tableData map[string]interface{}, columnName string) []string {
+	schema, _ := tableData["schema"].([]interface{})
+	for _, colInfo := range schema {
+		col, ok := colInfo.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _ := col["name"].(string); name == columnName {
+			return nativeEnumValues(col)
+		}
+	}
+	return nil
+}
+
+// nativeEnumEntry 將原生 ENUM 值域轉為 enum_values_found 的項目，標記為完整定義
+func nativeEnumEntry(values []string) map[string]interface{} {
+	uniqueValues := make(map[string]int, len(values))
+	for _, value := range values {
+		uniqueValues[value] = 0
+	}
+	return map[string]interface{}{
+		"unique_values": uniqueValues,
+		"values":        values,
+		"source":        "native_enum",
+		"complete":      true,
+	}
+}
+
+// nativeEnumsComplete 判斷是否將原生 ENUM 視為完整定義（phase2_prefix.native_enums 預設 complete）
+// 設為 ask 時仍對原生 ENUM 欄位產生 enum_check 問題
+func (p *Phase2PrefixRunner) nativeEnumsComplete() bool {
+	return p.config.Phase2Prefix.NativeEnums != "ask"
+}
+
+// addNativeEnumValues 將所有原生 ENUM 欄位的完整值域寫入 enum_values_found，無需使用者確認
+func (p *Phase2PrefixRunner) addNativeEnumValues(phase1Data map[string]interface{}, decisions map[string]interface{}) {
+	tables, ok := phase1Data["tables"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	enums := decisions["summary"].(map[string]interface{})["enum_values_found"].(map[string]interface{})
+	for tableName, tableData := range tables {
+		tableInfo, ok := tableData.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		schema, _ := tableInfo["schema"].([]interface{})
+		for _, colInfo := range schema {
+			col, ok := colInfo.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if values := nativeEnumValues(col); values != nil {
+				enums[fmt.Sprintf("%s.%v", tableName, col["name"])] = nativeEnumEntry(values)
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package phases
+
+import (
+	"log"
+	"strings"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// defaultPhase2SystemPrompt Phase 2 預設的系統提示
+const defaultPhase2SystemPrompt = "你是一個資料庫及數據分析專家。請分析給定的表格結構，提供專業的見解和建議。"
+
+// builtinPersonas 內建的分析角色，可透過 prompts.personas 依 phase 選用
+var builtinPersonas = map[string]string{
+	"dba":              "你是一位資深的資料庫管理員（DBA）。請著重於效能、索引設計、資料量成長、約束完整性與維運風險，提供具體可執行的建議。",
+	"business_analyst": "你是一位商業分析師。請著重於表格代表的業務實體與流程、關鍵業務指標，以及資料可支援的商業決策，避免過多技術細節。",
+	"data_modeler":     "你是一位資料建模專家。請著重於實體關係、正規化程度、主外鍵設計、命名一致性，以及適合的維度與事實表建模方式。",
+}
+
+// SystemPrompt 返回指定 phase 的系統提示
+// 優先順序：prompts.system_prompts 中的自訂提示 > prompts.personas 指定的內建角色 > 預設提示
+func SystemPrompt(cfg *config.Config, phase, defaultPrompt string) string {
+	if custom := strings.TrimSpace(cfg.Prompts.SystemPrompts[phase]); custom != "" {
+		return custom
+	}
+
+	if persona := strings.TrimSpace(cfg.Prompts.Personas[phase]); persona != "" {
+		if prompt, ok := builtinPersonas[persona]; ok {
+			return prompt
+		}
+		log.Printf("Warning: Unknown persona %q for %s, using default system prompt", persona, phase)
+	}
+
+	return defaultPrompt
+}
@@ -1,7 +1,10 @@
 package phases
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -34,24 +37,41 @@ func NewPhase1Runner(dbAnalyzer *analyzer.DatabaseAnalyzer, cfg *config.Config)
 }
 
 // Run 執行 Phase 1 統計分析
-func (p *Phase1Runner) Run() error {
+func (p *Phase1Runner) Run() (err error) {
+	defer recoverPhasePanic("phase1", &err)
+
 	log.Println("=== Starting Phase 1: Statistical Analysis ===")
 
-	// 獲取所有表格
+	// 離線模式：從 schema 匯出檔解析結構
+	if p.config.Schema.DumpFile != "" {
+		tables, tableAnalyses, err := AnalyzeSchemaDump(p.config.Schema)
+		if err != nil {
+			return err
+		}
+		return p.saveOutput(tables, tableAnalyses, []map[string]interface{}{})
+	}
+
+	// 獲取所有表格（套用 schema.include_patterns / exclude_patterns）
 	tables, err := p.analyzer.GetAllTables()
 	if err != nil {
 		return err
 	}
+	tables = p.config.Schema.FilterTables(tables)
 
 	log.Printf("Found %d tables in database", len(tables))
 
 	// 分析每個表格
 	tableAnalyses := make(map[string]interface{})
+	timedOutTables := []map[string]interface{}{}
 	for _, tableName := range tables {
 		log.Printf("Analyzing table: %s", tableName)
 
-		// 使用分析器的 AnalyzeTable 方法
-		analysis, err := p.analyzer.AnalyzeTable(tableName, p.config.Schema.MaxSamples)
+		// 使用分析器的 AnalyzeTable 方法，每個表格有獨立的逾時限制
+		analysis, err := AnalyzeTableWithTimeout(p.analyzer, tableName, SamplingPolicyFromConfig(p.config.Schema), p.config.Schema.TableTimeoutSeconds)
+		if timedOut := TimedOutTableEntry(tableName, analysis, err); timedOut != nil {
+			log.Printf("Warning: Table %s timed out: %v", tableName, timedOut["reason"])
+			timedOutTables = append(timedOutTables, timedOut)
+		}
 		if err != nil {
 			log.Printf("Warning: Failed to analyze table %s: %v", tableName, err)
 			continue
@@ -60,17 +80,48 @@ func (p *Phase1Runner) Run() error {
 		tableAnalyses[tableName] = analysis
 	}
 
+	if len(timedOutTables) > 0 {
+		log.Printf("Warning: %d tables timed out during analysis", len(timedOutTables))
+	}
+
+	return p.saveOutput(tables, tableAnalyses, timedOutTables)
+}
+
+// saveOutput 建立 phase1 輸出，寫入文件並存儲到向量數據庫
+func (p *Phase1Runner) saveOutput(tables []string, tableAnalyses map[string]interface{}, timedOutTables []map[string]interface{}) error {
 	// 創建輸出
 	output := map[string]interface{}{
-		"database":      p.config.Database.DBName,
-		"database_type": p.config.Database.Type,
-		"timestamp":     time.Now(),
-		"tables_count":  len(tables),
-		"tables":        tableAnalyses,
+		"schema_version":   Phase1SchemaVersion,
+		"database":         p.config.Database.DBName,
+		"database_type":    p.config.Database.Type,
+		"timestamp":        time.Now(),
+		"tables_count":     len(tables),
+		"tables":           tableAnalyses,
+		"timed_out_tables": timedOutTables,
+		"schema_language":  DetectSchemaLanguage(tableAnalyses),
 	}
 
+	// 合併模式：只更新本次分析的表格，保留其他表格
+	if p.config.Schema.MergeOutput {
+		existing, err := LoadPhase1Output(p.config.KnowledgePath("phase1_analysis.json"))
+		if err != nil {
+			log.Printf("Warning: Failed to load existing phase1 output for merge, writing fresh output: %v", err)
+		} else {
+			output = MergeTableAnalyses(existing, output)
+			log.Printf("Merged %d analyzed tables into existing phase1 output", len(tableAnalyses))
+		}
+	}
+	DropFilteredTables(p.config.Schema, output)
+
+	markTablesWithoutPrimaryKey(output)
+
+	return p.persistOutput(output)
+}
+
+// persistOutput 將 phase1 輸出寫入文件並存儲到向量數據庫
+func (p *Phase1Runner) persistOutput(output map[string]interface{}) error {
 	// 寫入文件
-	if err := p.writeOutput(output, "knowledge/phase1_analysis.json"); err != nil {
+	if err := p.writeOutput(output, p.config.KnowledgePath("phase1_analysis.json")); err != nil {
 		return err
 	}
 
@@ -92,6 +143,7 @@ func (p *Phase1Runner) writeOutput(data interface{}, filename string) error {
 		return err
 	}
 
+	ArchiveKnowledgeFile(p.config, filename)
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -109,6 +161,143 @@ func (p *Phase1Runner) writeOutput(data interface{}, filename string) error {
 	return nil
 }
 
+// LoadPhase1Output 讀取現有的 phase1 輸出文件，驗證格式版本並遷移舊格式
+func LoadPhase1Output(filename string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var output map[string]interface{}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	output, err = MigratePhase1Output(output)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return output, nil
+}
+
+// MergeTableAnalyses 將新的表格分析合併到現有輸出中
+// 新輸出中的表格會覆蓋同名表格，其餘表格保持不變，並更新時間戳與表格數量
+func MergeTableAnalyses(existing, updated map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(existing))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range updated {
+		if k == "tables" {
+			continue
+		}
+		merged[k] = v
+	}
+
+	tables := make(map[string]interface{})
+	if existingTables, ok := existing["tables"].(map[string]interface{}); ok {
+		for name, analysis := range existingTables {
+			tables[name] = analysis
+		}
+	}
+	if updatedTables, ok := updated["tables"].(map[string]interface{}); ok {
+		for name, analysis := range updatedTables {
+			tables[name] = analysis
+		}
+	}
+
+	merged["tables"] = tables
+	merged["tables_count"] = len(tables)
+	if _, ok := updated["schema_language"]; ok {
+		merged["schema_language"] = DetectSchemaLanguage(tables)
+	}
+	merged["timestamp"] = time.Now()
+
+	return merged
+}
+
+// AnalyzeSchemaDump 從 schema.dump_file 解析通過表格過濾的表格，返回與線上分析相同格式的結果（無樣本與統計）
+func AnalyzeSchemaDump(schemaCfg config.SchemaConfig) ([]string, map[string]interface{}, error) {
+	reader, err := analyzer.NewSchemaDumpReader(schemaCfg.DumpFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tables := schemaCfg.FilterTables(reader.GetAllTables())
+	log.Printf("Found %d tables in schema dump %s", len(tables), schemaCfg.DumpFile)
+
+	tableAnalyses := make(map[string]interface{})
+	for _, tableName := range tables {
+		analysis, err := reader.AnalyzeTable(tableName)
+		if err != nil {
+			log.Printf("Warning: Failed to analyze table %s from dump: %v", tableName, err)
+			continue
+		}
+		tableAnalyses[tableName] = analysis
+	}
+
+	return tables, tableAnalyses, nil
+}
+
+// SamplingPolicyFromConfig 根據 schema 配置建立取樣策略，未設定的門檻使用預設值
+func SamplingPolicyFromConfig(cfg config.SchemaConfig) analyzer.SamplingPolicy {
+	policy := analyzer.SamplingPolicy{
+		MaxSamples:      cfg.MaxSamples,
+		Adaptive:        cfg.AdaptiveSampling,
+		SmallTableRows:  100,
+		LargeTableRows:  1000000,
+		LargeMaxSamples: cfg.MaxSamples,
+		SampleStrategy:  cfg.SamplingStrategy,
+	}
+	if cfg.SmallTableRows > 0 {
+		policy.SmallTableRows = cfg.SmallTableRows
+	}
+	if cfg.LargeTableRows > 0 {
+		policy.LargeTableRows = cfg.LargeTableRows
+	}
+	if cfg.LargeTableMaxSamples > 0 {
+		policy.LargeMaxSamples = cfg.LargeTableMaxSamples
+	}
+	return policy
+}
+
+// AnalyzeTableWithTimeout 在逾時限制內分析單個表格，timeoutSeconds <= 0 表示不限制
+func AnalyzeTableWithTimeout(dbAnalyzer *analyzer.DatabaseAnalyzer, tableName string, policy analyzer.SamplingPolicy, timeoutSeconds int) (map[string]interface{}, error) {
+	ctx := context.Background()
+	if timeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	return dbAnalyzer.AnalyzeTableWithPolicy(ctx, tableName, policy)
+}
+
+// TimedOutTableEntry 若表格分析逾時（完全失敗或部分完成），返回摘要項目，否則返回 nil
+func TimedOutTableEntry(tableName string, analysis map[string]interface{}, err error) map[string]interface{} {
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return map[string]interface{}{
+				"table":  tableName,
+				"status": "failed",
+				"reason": err.Error(),
+			}
+		}
+		return nil
+	}
+
+	if status, _ := analysis["analysis_status"].(string); status == "partial" {
+		return map[string]interface{}{
+			"table":  tableName,
+			"status": "partial",
+			"reason": analysis["partial_reason"],
+		}
+	}
+
+	return nil
+}
+
 // Close 關閉 Phase 1 執行器
 func (p *Phase1Runner) Close() error {
 	if p.knowledgeMgr != nil {
@@ -31,7 +31,7 @@ func NewPhase1PostRunner(cfg *config.Config) (*Phase1PostRunner, error) {
 	}
 
 	// 創建 LLM 客戶端
-	llmClient := llm.NewClient(cfg)
+	llmClient := llm.NewClient(cfg).WithPhase("phase1_post")
 
 	return &Phase1PostRunner{
 		config:       cfg,
@@ -41,7 +41,10 @@ func NewPhase1PostRunner(cfg *config.Config) (*Phase1PostRunner, error) {
 }
 
 // Run 執行 Phase 1 後置處理
-func (p *Phase1PostRunner) Run() error {
+func (p *Phase1PostRunner) Run() (err error) {
+	defer recoverPhasePanic("phase1_post", &err)
+	llm.ResetPhaseUsage("phase1_post")
+
 	log.Println("=== Starting Phase 1 Post-Processing: Interactive Database Analysis & Cleanup ===")
 
 	// 讀取 Phase 1 的分析結果
@@ -81,7 +84,7 @@ func (p *Phase1PostRunner) Run() error {
 		}
 
 		// 寫入文件
-		if err := p.writeOutput(output, "knowledge/phase1_post_analysis.json"); err != nil {
+		if err := p.writeOutput(output, p.config.KnowledgePath("phase1_post_analysis.json")); err != nil {
 			return err
 		}
 
@@ -115,7 +118,7 @@ func (p *Phase1PostRunner) Run() error {
 
 // loadUserResponses 讀取用戶回答
 func (p *Phase1PostRunner) loadUserResponses() (map[string]interface{}, error) {
-	file, err := os.Open("knowledge/phase1_post_responses.json")
+	file, err := os.Open(p.config.KnowledgePath("phase1_post_responses.json"))
 	if err != nil {
 		return nil, err
 	}
@@ -148,7 +151,7 @@ func (p *Phase1PostRunner) generateQuestions(phase1Data map[string]interface{})
 2. 對於空的表格：是否可以安全刪除？
 3. 對於低使用量的表格：是否仍然需要？
 
-請以 JSON 格式返回問題列表，每個問題包含：
+請以 JSON 物件返回問題列表，格式為 {"questions": [...]}，每個問題包含：
 - question_id: 問題的唯一標識符
 - question_type: "usage_check", "empty_table_check", "low_usage_check"
 - question: 具體問題內容
@@ -158,7 +161,7 @@ func (p *Phase1PostRunner) generateQuestions(phase1Data map[string]interface{})
 只返回 JSON 格式，不要其他解釋。`, summaryStr)
 
 	// 調用 LLM
-	response, err := p.llmClient.GenerateCompletion(context.Background(), prompt)
+	response, err := p.llmClient.WithJSONMode().GenerateCompletion(context.Background(), prompt)
 	if err != nil {
 		log.Printf("Warning: Failed to generate questions with LLM: %v", err)
 		// 返回默認問題
@@ -167,25 +170,11 @@ func (p *Phase1PostRunner) generateQuestions(phase1Data map[string]interface{})
 		return questions
 	}
 
-	// 解析 LLM 回應
-	var questions []map[string]interface{}
-	if err := json.Unmarshal([]byte(response), &questions); err != nil {
-		log.Printf("Warning: Failed to parse LLM response as array, trying as object: %v", err)
-		// 嘗試解析為對象
-		var responseObj map[string]interface{}
-		if err := json.Unmarshal([]byte(response), &responseObj); err != nil {
-			log.Printf("Warning: Failed to parse LLM response: %v", err)
-			questions = p.generateDefaultQuestions(phase1Data)
-		} else {
-			// 如果是對象，嘗試提取 questions 字段
-			if q, ok := responseObj["questions"].([]interface{}); ok {
-				for _, item := range q {
-					if question, ok := item.(map[string]interface{}); ok {
-						questions = append(questions, question)
-					}
-				}
-			}
-		}
+	// 解析 LLM 回應（容許程式碼區塊標記、前後說明文字與多餘的逗號）
+	questions, err := parseQuestionList(response)
+	if err != nil {
+		log.Printf("Warning: Failed to parse LLM response: %v", err)
+		questions = p.generateDefaultQuestions(phase1Data)
 	}
 
 	// 如果沒有生成問題，也返回默認問題
@@ -210,7 +199,11 @@ func (p *Phase1PostRunner) generateDefaultQuestions(phase1Data map[string]interf
 
 	// 檢查空表
 	for tableName, tableData := range tables {
-		tableInfo := tableData.(map[string]interface{})
+		tableInfo, ok := tableData.(map[string]interface{})
+		if !ok {
+			log.Printf("Warning: Skipping table %s with malformed analysis data", tableName)
+			continue
+		}
 		stats, ok := tableInfo["stats"].(map[string]interface{})
 		if !ok {
 			continue
@@ -235,7 +228,11 @@ func (p *Phase1PostRunner) generateDefaultQuestions(phase1Data map[string]interf
 
 	// 檢查低使用量表格
 	for tableName, tableData := range tables {
-		tableInfo := tableData.(map[string]interface{})
+		tableInfo, ok := tableData.(map[string]interface{})
+		if !ok {
+			log.Printf("Warning: Skipping table %s with malformed analysis data", tableName)
+			continue
+		}
 		stats, ok := tableInfo["stats"].(map[string]interface{})
 		if !ok {
 			continue
@@ -298,9 +295,10 @@ func (p *Phase1PostRunner) saveQuestionsForUser(questions []map[string]interface
 		"generated_at": time.Now(),
 		"questions":    questions,
 		"instructions": "請回答以下問題。對於每個問題，請提供您的決定。",
+		"llm_usage":    finishPhaseUsage(p.config, "phase1_post"),
 	}
 
-	return p.writeOutput(data, "knowledge/phase1_post_questions.json")
+	return p.writeOutput(data, p.config.KnowledgePath("phase1_post_questions.json"))
 }
 
 // processUserResponses 處理用戶回答
@@ -395,24 +393,12 @@ func (p *Phase1PostRunner) generateFinalAnalysis(phase1Data map[string]interface
 
 // loadPhase1Results 讀取 Phase 1 的分析結果
 func (p *Phase1PostRunner) loadPhase1Results() (map[string]interface{}, error) {
-	file, err := os.Open("knowledge/phase1_analysis.json")
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var data map[string]interface{}
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&data); err != nil {
-		return nil, err
-	}
-
-	return data, nil
+	return LoadPhase1Output(p.config.KnowledgePath("phase1_analysis.json"))
 }
 
 // loadQuestions 讀取問題文件
 func (p *Phase1PostRunner) loadQuestions() (map[string]interface{}, error) {
-	questionsFile := "knowledge/phase1_post_questions.json"
+	questionsFile := p.config.KnowledgePath("phase1_post_questions.json")
 
 	data, err := os.ReadFile(questionsFile)
 	if err != nil {
@@ -443,7 +429,11 @@ func (p *Phase1PostRunner) createDataSummary(phase1Data map[string]interface{})
 	}
 
 	for tableName, tableData := range tables {
-		tableInfo := tableData.(map[string]interface{})
+		tableInfo, ok := tableData.(map[string]interface{})
+		if !ok {
+			log.Printf("Warning: Skipping table %s with malformed analysis data", tableName)
+			continue
+		}
 
 		// 從 stats 中獲取 row_count
 		stats, ok := tableInfo["stats"].(map[string]interface{})
@@ -477,6 +467,7 @@ func (p *Phase1PostRunner) writeOutput(data interface{}, filename string) error
 		return err
 	}
 
+	ArchiveKnowledgeFile(p.config, filename)
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -32,7 +32,9 @@ func NewPhase1PutRunner(cfg *config.Config) (*Phase1PutRunner, error) {
 }
 
 // Run 執行 Phase 1 Put - 更新 phase1 結果
-func (p *Phase1PutRunner) Run() error {
+func (p *Phase1PutRunner) Run() (err error) {
+	defer recoverPhasePanic("phase1_put", &err)
+
 	log.Println("=== Starting Phase 1 Put: Update Phase 1 Results Based on Post Analysis ===")
 
 	// 讀取原始 phase1 分析結果
@@ -57,7 +59,7 @@ func (p *Phase1PutRunner) Run() error {
 	filteredData["excluded_count"] = len(excludedTables)
 
 	// 寫入更新後的 phase1 結果
-	if err := p.writeOutput(filteredData, "knowledge/phase1_analysis.json"); err != nil {
+	if err := p.writeOutput(filteredData, p.config.KnowledgePath("phase1_analysis.json")); err != nil {
 		return fmt.Errorf("failed to write updated phase1 results: %v", err)
 	}
 
@@ -68,7 +70,8 @@ func (p *Phase1PutRunner) Run() error {
 		log.Printf("Vector store updated with filtered phase1 results")
 	}
 
-	log.Printf("Phase 1 Put completed. Excluded %d tables, kept %d tables", len(excludedTables), len(filteredData["tables"].(map[string]interface{})))
+	keptTables, _ := filteredData["tables"].(map[string]interface{})
+	log.Printf("Phase 1 Put completed. Excluded %d tables, kept %d tables", len(excludedTables), len(keptTables))
 
 	if len(excludedTables) > 0 {
 		log.Println("Excluded tables:")
@@ -82,24 +85,12 @@ func (p *Phase1PutRunner) Run() error {
 
 // loadPhase1Results 讀取 Phase 1 的分析結果
 func (p *Phase1PutRunner) loadPhase1Results() (map[string]interface{}, error) {
-	file, err := os.Open("knowledge/phase1_analysis.json")
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var data map[string]interface{}
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&data); err != nil {
-		return nil, err
-	}
-
-	return data, nil
+	return LoadPhase1Output(p.config.KnowledgePath("phase1_analysis.json"))
 }
 
 // loadPhase1PostResults 讀取 Phase 1 Post 的分析結果
 func (p *Phase1PutRunner) loadPhase1PostResults() (map[string]interface{}, error) {
-	file, err := os.Open("knowledge/phase1_post_analysis.json")
+	file, err := os.Open(p.config.KnowledgePath("phase1_post_analysis.json"))
 	if err != nil {
 		return nil, err
 	}
@@ -193,6 +184,7 @@ func (p *Phase1PutRunner) writeOutput(data interface{}, filename string) error {
 		return err
 	}
 
+	ArchiveKnowledgeFile(p.config, filename)
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
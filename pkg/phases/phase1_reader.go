@@ -3,7 +3,6 @@ package phases
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 )
 
 // Phase1ResultReader Phase 1 結果讀取器
@@ -11,24 +10,6 @@ type Phase1ResultReader struct {
 	filePath string
 }
 
-// Phase1Result Phase 1 的分析結果
-type Phase1Result struct {
-	Database     string                         `json:"database"`
-	DatabaseType string                         `json:"database_type"`
-	Timestamp    string                         `json:"timestamp"`
-	TablesCount  int                            `json:"tables_count"`
-	Tables       map[string]TableAnalysisResult `json:"tables"`
-}
-
-// TableAnalysisResult 單個表格的分析結果
-type TableAnalysisResult struct {
-	Schema      []map[string]interface{} `json:"schema"`
-	Constraints map[string]interface{}   `json:"constraints"`
-	Indexes     []map[string]interface{} `json:"indexes"`
-	Samples     []map[string]interface{} `json:"samples"`
-	Stats       map[string]interface{}   `json:"stats"`
-}
-
 // NewPhase1ResultReader 創建 Phase 1 結果讀取器
 func NewPhase1ResultReader(filePath string) *Phase1ResultReader {
 	return &Phase1ResultReader{
@@ -36,17 +17,20 @@ func NewPhase1ResultReader(filePath string) *Phase1ResultReader {
 	}
 }
 
-// ReadResult 讀取 Phase 1 的分析結果
+// ReadResult 讀取 Phase 1 的分析結果（先驗證格式版本並遷移舊格式）
 func (r *Phase1ResultReader) ReadResult() (*Phase1Result, error) {
-	file, err := os.Open(r.filePath)
+	output, err := LoadPhase1Output(r.filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open phase1 result file: %v", err)
+		return nil, fmt.Errorf("failed to load phase1 result file: %v", err)
+	}
+
+	data, err := json.Marshal(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode phase1 result: %v", err)
 	}
-	defer file.Close()
 
 	var result Phase1Result
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&result); err != nil {
+	if err := json.Unmarshal(data, &result); err != nil {
 		return nil, fmt.Errorf("failed to decode phase1 result: %v", err)
 	}
 
@@ -110,6 +94,9 @@ func (r *Phase1ResultReader) GetTableSummary(tableName string) (map[string]inter
 		if def, ok := col["default"]; ok && def != nil {
 			column["default"] = def
 		}
+		if description, ok := col["description"].(string); ok && description != "" {
+			column["description"] = description
+		}
 		columns = append(columns, column)
 	}
 	summary["columns"] = columns
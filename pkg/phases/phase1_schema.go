@@ -0,0 +1,107 @@
+package phases
+
+import (
+	"fmt"
+	"log"
+)
+
+// Phase1SchemaVersion phase1_analysis.json 目前的格式版本
+// 格式有不相容的變更時需遞增此版本，並在 phase1Migrations 中加入對應的遷移
+const Phase1SchemaVersion = 1
+
+// phase1Migrations 將輸出從版本 N 遷移到版本 N+1（以來源版本為索引）
+var phase1Migrations = map[int]func(map[string]interface{}) error{
+	0: migratePhase1V0ToV1,
+}
+
+// MigratePhase1Output 驗證 phase1 輸出的格式版本，並將較舊的格式遷移到目前版本
+// 由較新版本產生的檔案無法安全讀取，會返回錯誤
+func MigratePhase1Output(data map[string]interface{}) (map[string]interface{}, error) {
+	version, err := phase1OutputVersion(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if version > Phase1SchemaVersion {
+		return nil, fmt.Errorf("phase1 output schema_version %d is newer than supported version %d, please upgrade aika-dba or re-run phase1", version, Phase1SchemaVersion)
+	}
+
+	for version < Phase1SchemaVersion {
+		migrate, ok := phase1Migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration available for phase1 output schema_version %d, please re-run phase1", version)
+		}
+		if err := migrate(data); err != nil {
+			return nil, fmt.Errorf("failed to migrate phase1 output from schema_version %d: %w", version, err)
+		}
+		version++
+		data["schema_version"] = version
+		log.Printf("Migrated phase1 output to schema_version %d", version)
+	}
+
+	if _, ok := data["tables"].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("invalid phase1 output: tables is missing or not an object")
+	}
+
+	return data, nil
+}
+
+// phase1OutputVersion 讀取 schema_version，缺少時視為版本 0（加入版本欄位前的舊格式）
+func phase1OutputVersion(data map[string]interface{}) (int, error) {
+	raw, exists := data["schema_version"]
+	if !exists {
+		return 0, nil
+	}
+
+	switch v := raw.(type) {
+	case float64:
+		if v != float64(int(v)) || v < 0 {
+			return 0, fmt.Errorf("invalid phase1 output schema_version: %v", v)
+		}
+		return int(v), nil
+	case int:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("invalid phase1 output schema_version type: %T", raw)
+	}
+}
+
+// migratePhase1V0ToV1 補齊舊格式缺少的欄位
+func migratePhase1V0ToV1(data map[string]interface{}) error {
+	tables, ok := data["tables"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("tables is missing or not an object")
+	}
+
+	if _, ok := data["tables_count"]; !ok {
+		data["tables_count"] = len(tables)
+	}
+	if _, ok := data["timed_out_tables"]; !ok {
+		data["timed_out_tables"] = []interface{}{}
+	}
+
+	// 確保每個表格都有下游階段預期的欄位
+	for tableName, table := range tables {
+		tableMap, ok := table.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("table %s has invalid format", tableName)
+		}
+		if _, ok := tableMap["schema"]; !ok {
+			tableMap["schema"] = []interface{}{}
+		}
+		if _, ok := tableMap["constraints"]; !ok {
+			tableMap["constraints"] = map[string]interface{}{}
+		}
+		if _, ok := tableMap["indexes"]; !ok {
+			tableMap["indexes"] = []interface{}{}
+		}
+		if _, ok := tableMap["samples"]; !ok {
+			tableMap["samples"] = []interface{}{}
+		}
+		if _, ok := tableMap["stats"]; !ok {
+			tableMap["stats"] = map[string]interface{}{}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package phases
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeTableAnalyses(t *testing.T) {
+	idColumn := map[string]interface{}{"name": "id", "type": "integer"}
+	customers := map[string]interface{}{"schema": []interface{}{idColumn}}
+	orders := map[string]interface{}{"schema": []interface{}{idColumn}}
+	ordersWithCustomer := map[string]interface{}{"schema": []interface{}{
+		idColumn,
+		map[string]interface{}{"name": "customer_id", "type": "integer"},
+	}}
+	ordersWithAmount := map[string]interface{}{"schema": []interface{}{
+		idColumn,
+		map[string]interface{}{"name": "amount", "type": "numeric"},
+	}}
+
+	tests := []struct {
+		name       string
+		existing   map[string]interface{}
+		updated    map[string]interface{}
+		wantTables map[string]interface{}
+	}{
+		{
+			name: "disjoint tables are kept",
+			existing: map[string]interface{}{
+				"database": "shop",
+				"tables":   map[string]interface{}{"customers": customers},
+			},
+			updated: map[string]interface{}{
+				"tables": map[string]interface{}{"orders": ordersWithCustomer},
+			},
+			wantTables: map[string]interface{}{
+				"customers": customers,
+				"orders":    ordersWithCustomer,
+			},
+		},
+		{
+			name: "overlapping tables are replaced",
+			existing: map[string]interface{}{
+				"database": "shop",
+				"tables": map[string]interface{}{
+					"customers": customers,
+					"orders":    orders,
+				},
+			},
+			updated: map[string]interface{}{
+				"tables": map[string]interface{}{"orders": ordersWithAmount},
+			},
+			wantTables: map[string]interface{}{
+				"customers": customers,
+				"orders":    ordersWithAmount,
+			},
+		},
+		{
+			name:     "no existing analysis",
+			existing: map[string]interface{}{},
+			updated: map[string]interface{}{
+				"database": "shop",
+				"tables":   map[string]interface{}{"orders": orders},
+			},
+			wantTables: map[string]interface{}{"orders": orders},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existingTables, _ := tt.existing["tables"].(map[string]interface{})
+			existingCount := len(existingTables)
+
+			merged := MergeTableAnalyses(tt.existing, tt.updated)
+
+			if got := merged["tables"]; !reflect.DeepEqual(got, tt.wantTables) {
+				t.Errorf("tables = %v, want %v", got, tt.wantTables)
+			}
+			if got := merged["tables_count"]; got != len(tt.wantTables) {
+				t.Errorf("tables_count = %v, want %d", got, len(tt.wantTables))
+			}
+			if got := merged["database"]; got != "shop" {
+				t.Errorf("database = %v, want shop", got)
+			}
+			if _, ok := merged["timestamp"]; !ok {
+				t.Error("timestamp not set")
+			}
+			if len(existingTables) != existingCount {
+				t.Errorf("existing tables mutated: %v", existingTables)
+			}
+		})
+	}
+}
@@ -4,17 +4,24 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/llm"
 	"github.com/masato25/aika-dba/pkg/mcp"
 	"github.com/masato25/aika-dba/pkg/vectorstore"
 )
 
+// ProgressFunc 回報 phase 進度：completed 為已完成的步驟數，total 為實際總步驟數
+type ProgressFunc func(completed, total int, message string)
+
 // Phase2Runner Phase 2 執行器
 type Phase2Runner struct {
 	config       *config.Config
@@ -22,10 +29,12 @@ type Phase2Runner struct {
 	knowledgeMgr *vectorstore.KnowledgeManager
 	analyzer     *TableAnalysisOrchestrator
 	mcpServer    *mcp.MCPServer
+	progressFn   ProgressFunc
 }
 
 // NewPhase2Runner 創建 Phase 2 執行器
-func NewPhase2Runner(cfg *config.Config, db *sql.DB) (*Phase2Runner, error) {
+// resume 為 true 時 Run 會略過 knowledge/phase2_partial.json 中已完成的表格，否則從頭分析
+func NewPhase2Runner(cfg *config.Config, db *sql.DB, resume bool) (*Phase2Runner, error) {
 	// 創建知識管理器
 	knowledgeMgr, err := vectorstore.NewKnowledgeManager(cfg)
 	if err != nil {
@@ -33,13 +42,14 @@ func NewPhase2Runner(cfg *config.Config, db *sql.DB) (*Phase2Runner, error) {
 	}
 
 	// 創建 phase1 結果讀取器（用於後備）
-	reader := NewPhase1ResultReader("knowledge/phase1_analysis.json")
+	reader := NewPhase1ResultReader(cfg.KnowledgePath("phase1_analysis.json"))
 
 	// 創建 MCP 服務器
-	mcpServer := mcp.NewMCPServer(db)
+	mcpServer := mcp.NewMCPServerWithConfig(db, cfg)
 
 	// 創建表格分析協調器
 	analyzer := NewTableAnalysisOrchestrator(cfg, reader, mcpServer, knowledgeMgr)
+	analyzer.resume = resume
 
 	return &Phase2Runner{
 		config:       cfg,
@@ -50,8 +60,25 @@ func NewPhase2Runner(cfg *config.Config, db *sql.DB) (*Phase2Runner, error) {
 	}, nil
 }
 
+// SetProgressFunc 設定進度回報函數，每分析完一個表格（成功或失敗）呼叫一次，總數為實際表格數
+func (p *Phase2Runner) SetProgressFunc(fn ProgressFunc) {
+	p.progressFn = fn
+}
+
+// reportProgress 以協調器的任務狀態回報已處理／總表格數
+func (p *Phase2Runner) reportProgress(message string) {
+	if p.progressFn == nil {
+		return
+	}
+	progress := p.analyzer.GetProgress()
+	p.progressFn(progress["completed"].(int)+progress["failed"].(int), progress["total"].(int), message)
+}
+
 // Run 執行 Phase 2 AI 分析
-func (p *Phase2Runner) Run() error {
+func (p *Phase2Runner) Run() (err error) {
+	defer recoverPhasePanic("phase2", &err)
+	llm.ResetPhaseUsage("phase2")
+
 	log.Println("=== Starting Phase 2: AI Analysis ===")
 
 	// 檢查 LLM 配置
@@ -62,6 +89,12 @@ func (p *Phase2Runner) Run() error {
 	log.Printf("  Port: %d", p.config.LLM.Port)
 	log.Printf("  Base URL: %s", p.config.LLM.BaseURL)
 
+	// 每完成一個表格寫入檢查點，中斷後可以 resume 繼續；不恢復時清除舊的檢查點
+	p.analyzer.checkpointPath = Phase2PartialPath(p.config)
+	if !p.analyzer.resume {
+		removeCheckpoint(Phase2PartialPath(p.config))
+	}
+
 	// 初始化分析任務
 	if err := p.analyzer.InitializeTasks(); err != nil {
 		return fmt.Errorf("failed to initialize analysis tasks: %v", err)
@@ -73,59 +106,192 @@ func (p *Phase2Runner) Run() error {
 		return fmt.Errorf("failed to run analysis: %v", err)
 	}
 
-	// 保存結果
+	// 保存結果（包含從檢查點恢復的表格），成功後刪除檢查點
 	if err := p.saveResults(); err != nil {
 		return fmt.Errorf("failed to save results: %v", err)
 	}
+	removeCheckpoint(Phase2PartialPath(p.config))
 
 	log.Printf("Phase 2 AI analysis completed successfully")
 	return nil
 }
 
-// runAnalysis 執行分析流程
-func (p *Phase2Runner) runAnalysis(ctx context.Context) error {
-	log.Println("Starting table analysis process...")
-
-	for {
-		// 獲取下一個任務
-		task := p.analyzer.GetNextTask()
-		if task == nil {
-			// 所有任務都完成了
-			break
-		}
+// RunTables 只重新分析指定表格，更新 phase2_analysis.json 中對應的結果並重新嵌入，removed 中的表格會被移除
+// 有人工修正的表格不重新分析；尚無 Phase 2 輸出時執行完整分析
+func (p *Phase2Runner) RunTables(tables, removed []string) (err error) {
+	defer recoverPhasePanic("phase2", &err)
 
-		log.Printf("Processing table: %s", task.TableName)
+	output, err := loadJSONMap(Phase2AnalysisPath(p.config))
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("No existing phase2 analysis, running full Phase 2")
+			return p.Run()
+		}
+		return fmt.Errorf("failed to load phase2 analysis: %w", err)
+	}
 
-		// 開始任務
-		p.analyzer.StartTask(task)
+	corrections, err := LoadTableCorrections(Phase2CorrectionsPath(p.config))
+	if err != nil {
+		log.Printf("Warning: Failed to load table corrections: %v", err)
+		corrections = nil
+	}
 
-		// 分析表格
-		result, err := p.analyzer.AnalyzeTable(ctx, task)
-		if err != nil {
-			log.Printf("Failed to analyze table %s: %v", task.TableName, err)
-			p.analyzer.FailTask(task, err)
+	var toAnalyze []string
+	for _, tableName := range tables {
+		if _, corrected := corrections[tableName]; corrected {
+			log.Printf("Skipping re-analysis of table %s: human correction takes precedence", tableName)
 			continue
 		}
+		toAnalyze = append(toAnalyze, tableName)
+	}
 
-		// 完成任務
-		p.analyzer.CompleteTask(task, result)
+	p.analyzer.initializeTasksFromNames(toAnalyze)
+	if err := p.runAnalysis(context.Background()); err != nil {
+		return fmt.Errorf("failed to run analysis: %v", err)
+	}
+
+	analysisResults, ok := output["analysis_results"].(map[string]interface{})
+	if !ok {
+		analysisResults = make(map[string]interface{})
+		output["analysis_results"] = analysisResults
+	}
+
+	for tableName, result := range p.analyzer.GetResults() {
+		analysisResults[tableName] = result
+		if err := p.knowledgeMgr.StoreTableKnowledge("phase2", tableName, tableKnowledge(tableName, result)); err != nil {
+			log.Printf("Warning: Failed to re-embed phase2 knowledge for table %s: %v", tableName, err)
+		}
+	}
+
+	for _, tableName := range removed {
+		delete(analysisResults, tableName)
+		if err := p.knowledgeMgr.DeleteTableKnowledge("phase2", tableName); err != nil {
+			log.Printf("Warning: Failed to delete phase2 knowledge for removed table %s: %v", tableName, err)
+		}
+	}
+	output["timestamp"] = time.Now()
+
+	ArchiveKnowledgeFile(p.config, Phase2AnalysisPath(p.config))
+	if err := writeJSONFile(Phase2AnalysisPath(p.config), output); err != nil {
+		return err
+	}
+
+	log.Printf("Phase 2 re-analyzed %d tables, removed %d tables", len(p.analyzer.GetResults()), len(removed))
+	return nil
+}
 
-		// 顯示進度
-		progress := p.analyzer.GetProgress()
-		log.Printf("Progress: %.1f%% (%d/%d completed)",
-			progress["percentage"].(float64),
-			progress["completed"].(int),
-			progress["total"].(int))
+// runAnalysis 執行分析流程，以 llm.concurrency 個 worker 同時分析表格（預設 1，依序分析）
+// 任一表格遇到 LLM 認證錯誤時取消其餘請求並返回錯誤，已完成的表格保留在檢查點中
+func (p *Phase2Runner) runAnalysis(ctx context.Context) error {
+	workers := p.config.LLM.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	log.Printf("Starting table analysis process with %d worker(s)...", workers)
+	p.reportProgress(fmt.Sprintf("Analyzing %d tables", len(p.analyzer.tasks)))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var fatalOnce sync.Once
+	var fatalErr error
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				// 獲取下一個任務，所有任務都已分派時結束
+				task := p.analyzer.ClaimNextTask()
+				if task == nil {
+					return
+				}
+				if err := p.analyzeTask(ctx, task); err != nil {
+					fatalOnce.Do(func() {
+						fatalErr = err
+						cancel()
+					})
+				}
+			}
+		}()
 	}
+	wg.Wait()
 
+	if fatalErr != nil {
+		return fatalErr
+	}
 	log.Println("All table analyses completed")
 	return nil
 }
 
+// analyzeTask 分析單一表格並更新任務狀態，單一表格的異常不影響其他表格；
+// 只有 LLM 認證失敗（401/403）這類所有表格都會遇到的錯誤才返回
+func (p *Phase2Runner) analyzeTask(ctx context.Context, task *TableAnalysisTask) error {
+	log.Printf("Processing table: %s", task.TableName)
+
+	var result *LLMAnalysisResult
+	err := runTableSafely(task.TableName, func() error {
+		var analyzeErr error
+		result, analyzeErr = p.analyzer.AnalyzeTable(ctx, task)
+		return analyzeErr
+	})
+
+	fatal := fatalLLMError(result, err)
+	switch {
+	case fatal != nil:
+		err = fatal
+	case ctx.Err() != nil:
+		// 其他 worker 遇到致命錯誤後取消的請求，不保留後備結果
+		err = ctx.Err()
+	}
+	if err != nil {
+		log.Printf("Failed to analyze table %s: %v", task.TableName, err)
+		p.analyzer.FailTask(task, err)
+		p.reportProgress(fmt.Sprintf("Failed to analyze table: %s", task.TableName))
+		return fatal
+	}
+
+	// 完成任務
+	p.analyzer.CompleteTask(task, result)
+	p.reportProgress(fmt.Sprintf("Analyzed table: %s", task.TableName))
+
+	// 顯示進度
+	progress := p.analyzer.GetProgress()
+	log.Printf("Progress: %.1f%% (%d/%d completed)",
+		progress["percentage"].(float64),
+		progress["completed"].(int),
+		progress["total"].(int))
+	return nil
+}
+
+// fatalLLMError 判斷表格分析是否因 LLM 認證失敗（401/403）而失敗或改用後備結果，是則返回錯誤
+func fatalLLMError(result *LLMAnalysisResult, err error) error {
+	statusCode := 0
+	var llmErr *llm.LLMError
+	if errors.As(err, &llmErr) {
+		statusCode = llmErr.StatusCode
+	} else if result != nil && result.LLMStatus != nil {
+		statusCode = result.LLMStatus.StatusCode
+	}
+
+	if statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden {
+		return fmt.Errorf("LLM authentication failed (status %d), stopping analysis", statusCode)
+	}
+	return nil
+}
+
 // saveResults 保存分析結果
 func (p *Phase2Runner) saveResults() error {
 	results := p.analyzer.GetResults()
 
+	// 人工修正優先，避免重新執行時覆蓋
+	corrections, err := LoadTableCorrections(Phase2CorrectionsPath(p.config))
+	if err != nil {
+		log.Printf("Warning: Failed to load table corrections: %v", err)
+	} else if applied := ApplyTableCorrections(results, corrections); applied > 0 {
+		log.Printf("Applied %d human corrections to Phase 2 results", applied)
+	}
+
 	// 創建輸出結構
 	output := map[string]interface{}{
 		"phase":         "phase2",
@@ -141,19 +307,36 @@ func (p *Phase2Runner) saveResults() error {
 		},
 		"analysis_results": results,
 		"summary":          p.generateSummary(results),
+		"llm_usage":        finishPhaseUsage(p.config, "phase2"),
+		"llm_status":       phase2LLMStatus(results),
 	}
 
 	// 寫入商業邏輯分析結果
-	if err := p.writeOutput(output, "knowledge/phase2_analysis.json"); err != nil {
+	if err := p.writeOutput(output, Phase2AnalysisPath(p.config)); err != nil {
 		return err
 	}
 
-	// 將知識存儲到向量數據庫
-	if err := p.knowledgeMgr.StorePhaseKnowledge("phase2", output); err != nil {
+	// 將知識存儲到向量數據庫：表格分析以外的內容作為 phase 知識，各表格分析另外以 table/knowledge_key 標記，
+	// 人工修正與增量分析才能只取代該表格的塊（phase 知識以 replace 保留策略存儲時會先清除所有 phase2 塊）
+	phaseKnowledge := make(map[string]interface{}, len(output))
+	for key, value := range output {
+		if key != "analysis_results" {
+			phaseKnowledge[key] = value
+		}
+	}
+	// 不返回錯誤，因為 JSON 文件已經寫入成功
+	if err := p.knowledgeMgr.StorePhaseKnowledge("phase2", phaseKnowledge); err != nil {
 		log.Printf("Warning: Failed to store phase2 knowledge in vector store: %v", err)
-		// 不返回錯誤，因為 JSON 文件已經寫入成功
 	} else {
-		log.Printf("Phase 2 knowledge stored in vector database")
+		stored := 0
+		for tableName, result := range results {
+			if err := p.knowledgeMgr.StoreTableKnowledge("phase2", tableName, tableKnowledge(tableName, result)); err != nil {
+				log.Printf("Warning: Failed to store phase2 knowledge for table %s: %v", tableName, err)
+				continue
+			}
+			stored++
+		}
+		log.Printf("Phase 2 knowledge stored in vector database (%d of %d tables)", stored, len(results))
 	}
 
 	// 生成 Phase 3 準備文件
@@ -183,6 +366,7 @@ func (p *Phase2Runner) writeOutput(data interface{}, filename string) error {
 		return err
 	}
 
+	ArchiveKnowledgeFile(p.config, filename)
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -225,14 +409,7 @@ func (p *Phase2Runner) generatePrePhase3Summary(results map[string]*LLMAnalysisR
 		"timestamp":          time.Now(),
 		"business_summary":   businessSummary,
 		"phase3_suggestions": phase3Suggestions,
-		"custom_notes": map[string]interface{}{
-			"business_domain":         "",
-			"key_entities":            []string{},
-			"important_relationships": []string{},
-			"analysis_focus_areas":    []string{},
-			"custom_dimensions":       []map[string]interface{}{},
-			"additional_notes":        "",
-		},
+		"custom_notes":       p.loadExistingCustomNotes(),
 		"instructions": map[string]string{
 			"business_domain":         "請描述這個資料庫的主要商業領域（例如：電商、物流、財務等）",
 			"key_entities":            "列出系統中的關鍵實體（例如：顧客、訂單、商品等）",
@@ -244,7 +421,29 @@ func (p *Phase2Runner) generatePrePhase3Summary(results map[string]*LLMAnalysisR
 	}
 
 	// 寫入預 Phase 3 文件
-	return p.writeOutput(prePhase3Data, "knowledge/pre_phase3_summary.json")
+	return p.writeOutput(prePhase3Data, PrePhase3SummaryPath(p.config))
+}
+
+// loadExistingCustomNotes 保留使用者已填寫的 custom_notes，避免重新執行 Phase 2 時被覆蓋
+func (p *Phase2Runner) loadExistingCustomNotes() interface{} {
+	if data, err := os.ReadFile(PrePhase3SummaryPath(p.config)); err == nil {
+		var existing map[string]interface{}
+		if err := json.Unmarshal(data, &existing); err == nil {
+			if notes, ok := existing["custom_notes"].(map[string]interface{}); ok {
+				log.Println("Preserving existing custom_notes from pre-Phase 3 summary")
+				return notes
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"business_domain":         "",
+		"key_entities":            []string{},
+		"important_relationships": []string{},
+		"analysis_focus_areas":    []string{},
+		"custom_dimensions":       []map[string]interface{}{},
+		"additional_notes":        "",
+	}
 }
 
 // analyzeBusinessLogic 分析整體商業邏輯
@@ -0,0 +1,85 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// Phase2PartialPath 返回 Phase 2 執行中的檢查點檔案路徑（完成後會合併進 phase2_analysis.json 並刪除）
+func Phase2PartialPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("phase2_partial.json")
+}
+
+// phase2Checkpoint 檢查點檔案內容：已完成表格的分析結果
+type phase2Checkpoint struct {
+	Timestamp       time.Time                     `json:"timestamp"`
+	AnalysisResults map[string]*LLMAnalysisResult `json:"analysis_results"`
+}
+
+// saveCheckpoint 將目前已完成的結果寫入檢查點檔案；未啟用檢查點時不做任何事
+// 先寫入暫存檔再改名，避免在寫入途中中斷留下損壞的檢查點；呼叫端須持有 o.mu
+func (o *TableAnalysisOrchestrator) saveCheckpoint() {
+	if o.checkpointPath == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(phase2Checkpoint{
+		Timestamp:       time.Now(),
+		AnalysisResults: o.results,
+	}, "", "  ")
+	if err != nil {
+		log.Printf("Warning: Failed to marshal phase2 checkpoint: %v", err)
+		return
+	}
+
+	tmpPath := o.checkpointPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Printf("Warning: Failed to write phase2 checkpoint: %v", err)
+		return
+	}
+	if err := os.Rename(tmpPath, o.checkpointPath); err != nil {
+		log.Printf("Warning: Failed to replace phase2 checkpoint: %v", err)
+	}
+}
+
+// resumeFromCheckpoint 將檢查點中已有結果的表格標記為完成，返回恢復的表格數
+// 使用後備結果（LLM 失敗）的表格不恢復，重新執行時會再次分析
+func (o *TableAnalysisOrchestrator) resumeFromCheckpoint() (int, error) {
+	data, err := os.ReadFile(o.checkpointPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var checkpoint phase2Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return 0, fmt.Errorf("failed to parse phase2 checkpoint: %w", err)
+	}
+
+	resumed := 0
+	for _, task := range o.tasks {
+		result, ok := checkpoint.AnalysisResults[task.TableName]
+		if !ok || result == nil || (result.LLMStatus != nil && result.LLMStatus.UsedFallback) {
+			continue
+		}
+		task.Status = "completed"
+		task.Result = result
+		o.results[task.TableName] = result
+		resumed++
+	}
+	return resumed, nil
+}
+
+// removeCheckpoint 刪除檢查點檔案
+func removeCheckpoint(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Failed to remove phase2 checkpoint %s: %v", path, err)
+	}
+}
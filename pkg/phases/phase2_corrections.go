@@ -0,0 +1,200 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/vectorstore"
+)
+
+// Phase2CorrectionsPath 人工修正記錄檔案路徑
+func Phase2CorrectionsPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("phase2_corrections.json")
+}
+
+// Phase2AnalysisPath Phase 2 分析結果檔案路徑
+func Phase2AnalysisPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("phase2_analysis.json")
+}
+
+// TableCorrection 單一表格的人工修正
+type TableCorrection struct {
+	TableName string            `json:"table_name"`
+	Analysis  string            `json:"analysis,omitempty"` // 自由文字修正，取代原有描述
+	Fields    map[string]string `json:"fields,omitempty"`   // 結構化修正，例如 business_purpose、key_columns
+	Author    string            `json:"author,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// Text 將修正內容轉換為分析文字
+func (c *TableCorrection) Text() string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(c.Analysis))
+
+	if len(c.Fields) > 0 {
+		keys := make([]string, 0, len(c.Fields))
+		for key := range c.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		for _, key := range keys {
+			sb.WriteString(fmt.Sprintf("%s: %s\n", key, strings.TrimSpace(c.Fields[key])))
+		}
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+// LoadTableCorrections 讀取人工修正記錄，檔案不存在時返回空記錄
+func LoadTableCorrections(filename string) (map[string]*TableCorrection, error) {
+	corrections := make(map[string]*TableCorrection)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return corrections, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	if err := json.Unmarshal(data, &corrections); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	return corrections, nil
+}
+
+// ApplyTableCorrections 以人工修正覆蓋分析結果（人工修正優先）
+func ApplyTableCorrections(results map[string]*LLMAnalysisResult, corrections map[string]*TableCorrection) int {
+	applied := 0
+	for tableName, correction := range corrections {
+		text := correction.Text()
+		if text == "" {
+			continue
+		}
+
+		results[tableName] = &LLMAnalysisResult{
+			TableName:     tableName,
+			Analysis:      text,
+			Timestamp:     correction.Timestamp,
+			HumanOverride: true,
+		}
+		applied++
+	}
+	return applied
+}
+
+// SubmitTableCorrection 記錄表格的人工修正，更新 phase2_analysis.json 並重新嵌入該表格的知識
+// 表格必須存在於 phase1_analysis.json，避免拼錯的表格名稱產生無法對應的修正
+func SubmitTableCorrection(cfg *config.Config, knowledgeMgr *vectorstore.KnowledgeManager, correction *TableCorrection) error {
+	correction.TableName = strings.TrimSpace(correction.TableName)
+	if correction.TableName == "" {
+		return fmt.Errorf("table name is required")
+	}
+	if correction.Text() == "" {
+		return fmt.Errorf("correction for table %s is empty", correction.TableName)
+	}
+	if err := requirePhase1Table(cfg, correction.TableName); err != nil {
+		return err
+	}
+	correction.Timestamp = time.Now()
+
+	// 記錄人工修正，供之後重新執行 Phase 2 時保留
+	corrections, err := LoadTableCorrections(Phase2CorrectionsPath(cfg))
+	if err != nil {
+		return err
+	}
+	corrections[correction.TableName] = correction
+	if err := writeJSONFile(Phase2CorrectionsPath(cfg), corrections); err != nil {
+		return err
+	}
+
+	// 更新 phase2_analysis.json 中該表格的分析
+	output, err := loadJSONMap(Phase2AnalysisPath(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to load phase2 analysis: %w", err)
+	}
+	analysisResults, ok := output["analysis_results"].(map[string]interface{})
+	if !ok {
+		analysisResults = make(map[string]interface{})
+		output["analysis_results"] = analysisResults
+	}
+	result := &LLMAnalysisResult{
+		TableName:     correction.TableName,
+		Analysis:      correction.Text(),
+		Timestamp:     correction.Timestamp,
+		HumanOverride: true,
+	}
+	analysisResults[correction.TableName] = result
+	ArchiveKnowledgeFile(cfg, Phase2AnalysisPath(cfg))
+	if err := writeJSONFile(Phase2AnalysisPath(cfg), output); err != nil {
+		return err
+	}
+
+	log.Printf("Recorded correction for table %s", correction.TableName)
+
+	// 只重新嵌入該表格的知識
+	if knowledgeMgr == nil {
+		log.Printf("Warning: Knowledge manager not available, skipping re-embedding for table %s", correction.TableName)
+		return nil
+	}
+	return knowledgeMgr.StoreTableKnowledge("phase2", correction.TableName, tableKnowledge(correction.TableName, result))
+}
+
+// requirePhase1Table 確認表格存在於 phase1_analysis.json
+func requirePhase1Table(cfg *config.Config, tableName string) error {
+	output, err := LoadPhase1Output(cfg.KnowledgePath("phase1_analysis.json"))
+	if err != nil {
+		return fmt.Errorf("failed to load phase1 analysis to validate table %s: %w", tableName, err)
+	}
+	tables, _ := output["tables"].(map[string]interface{})
+	if _, ok := tables[tableName]; !ok {
+		return fmt.Errorf("table %s is not in phase1_analysis.json", tableName)
+	}
+	return nil
+}
+
+// tableKnowledge 單一表格存入向量存儲的 Phase 2 知識（完整執行、增量分析與人工修正共用）
+func tableKnowledge(tableName string, result *LLMAnalysisResult) map[string]interface{} {
+	knowledge := map[string]interface{}{
+		"table_name": tableName,
+		"analysis":   result.Analysis,
+	}
+	if result.HumanOverride {
+		knowledge["human_override"] = true
+	}
+	return knowledge
+}
+
+// loadJSONMap 讀取 JSON 檔案為 map
+func loadJSONMap(filename string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var output map[string]interface{}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	return output, nil
+}
+
+// writeJSONFile 以縮排格式寫入 JSON 檔案
+func writeJSONFile(filename string, data interface{}) error {
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, jsonData, 0644)
+}
@@ -0,0 +1,79 @@
+package phases
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/vectorstore"
+)
+
+func TestSubmitTableCorrection(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Knowledge.Dir = t.TempDir()
+	cfg.Knowledge.KeepHistory = true
+	cfg.VectorStore.Backend = "memory"
+	cfg.VectorStore.EmbedderType = "simple"
+	cfg.VectorStore.EmbeddingDimension = 32
+	cfg.VectorStore.ChunkSize = 200
+	cfg.VectorStore.ChunkOverlap = 20
+
+	if err := writeJSONFile(cfg.KnowledgePath("phase1_analysis.json"), map[string]interface{}{
+		"tables": map[string]interface{}{"orders": map[string]interface{}{}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	km, err := vectorstore.NewKnowledgeManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer km.Close()
+
+	// 完整執行 Phase 2 寫入 phase2_analysis.json 與向量知識
+	runner := &Phase2Runner{config: cfg, knowledgeMgr: km, analyzer: &TableAnalysisOrchestrator{
+		config:  cfg,
+		results: map[string]*LLMAnalysisResult{"orders": {TableName: "orders", Analysis: "stale guess"}},
+	}}
+	if err := runner.saveResults(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("unknown table", func(t *testing.T) {
+		err := SubmitTableCorrection(cfg, km, &TableCorrection{TableName: "oders", Analysis: "typo"})
+		if err == nil || !strings.Contains(err.Error(), "not in phase1_analysis.json") {
+			t.Fatalf("SubmitTableCorrection() = %v, want unknown table error", err)
+		}
+	})
+
+	if err := SubmitTableCorrection(cfg, km, &TableCorrection{TableName: "orders", Analysis: "customer purchase orders"}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := km.RetrievePhaseKnowledge("phase2", "orders", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrected := false
+	for _, result := range results {
+		if strings.Contains(result.Content, "stale guess") {
+			t.Errorf("stale chunk survived the correction: %q", result.Content)
+		}
+		corrected = corrected || strings.Contains(result.Content, "customer purchase orders")
+	}
+	if !corrected {
+		t.Error("corrected analysis was not embedded")
+	}
+
+	archived, err := filepath.Glob(filepath.Join(KnowledgeHistoryDir(cfg), "phase2_analysis.*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 1 {
+		t.Errorf("archived versions = %v, want the previous phase2_analysis.json", archived)
+	}
+	if _, err := os.Stat(Phase2CorrectionsPath(cfg)); err != nil {
+		t.Errorf("corrections not recorded: %v", err)
+	}
+}
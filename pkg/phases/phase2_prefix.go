@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -19,6 +20,22 @@ type Phase2PrefixRunner struct {
 	config       *config.Config
 	llmClient    *llm.Client
 	knowledgeMgr *vectorstore.KnowledgeManager
+	heuristics   *ColumnHeuristics
+	// questionTypes 啟用的問題類型，為空時產生所有類型
+	questionTypes map[string]bool
+}
+
+// phase2PrefixQuestionTypes 所有問題類型與給 LLM 的說明（依顯示順序）
+var phase2PrefixQuestionTypes = []struct {
+	Type        string
+	Description string
+}{
+	{"unused_column_check", "對於看起來沒有使用的欄位（大量空值）：這個欄位是否還在被使用？"},
+	{"collection_check", "對於可能需要搜集 collections 的欄位（開發者懶惰沒有建 table 直接寫 string）：這個欄位應該轉換為關聯表格嗎？"},
+	{"enum_check", "對於使用 enum 的欄位：這個欄位的枚舉值是否完整定義？"},
+	{"int_definition_check", "對於使用 int 但沒有定義的欄位：這個欄位是否需要更好的定義？"},
+	{"value_collection_check", "對於包含多個不同值的字串欄位：這個欄位是否需要搜集可用的值選項？"},
+	{"denormalization_check", "對於透過外鍵可從其他表格取得的重複欄位：這個欄位是否應該正規化？"},
 }
 
 // NewPhase2PrefixRunner 創建 Phase 2 前置處理執行器
@@ -33,18 +50,58 @@ func NewPhase2PrefixRunner(cfg *config.Config) (*Phase2PrefixRunner, error) {
 
 	log.Println("DEBUG: Creating LLM client...")
 	// 創建 LLM 客戶端
-	llmClient := llm.NewClient(cfg)
+	llmClient := llm.NewClient(cfg).WithPhase("phase2_prefix")
 	log.Println("DEBUG: LLM client created successfully")
 
-	return &Phase2PrefixRunner{
+	runner := &Phase2PrefixRunner{
 		config:       cfg,
 		llmClient:    llmClient,
 		knowledgeMgr: knowledgeMgr,
-	}, nil
+		heuristics:   NewColumnHeuristicsFromConfig(cfg.Phase2Prefix),
+	}
+	runner.SetQuestionTypes(cfg.Phase2Prefix.QuestionTypes)
+
+	return runner, nil
+}
+
+// SetQuestionTypes 設定只產生哪些問題類型（覆寫 phase2_prefix.question_types），為空時產生所有類型
+func (p *Phase2PrefixRunner) SetQuestionTypes(questionTypes []string) {
+	p.questionTypes = nil
+	for _, questionType := range questionTypes {
+		questionType = strings.TrimSpace(questionType)
+		if questionType == "" {
+			continue
+		}
+
+		known := false
+		for _, qt := range phase2PrefixQuestionTypes {
+			if qt.Type == questionType {
+				known = true
+				break
+			}
+		}
+		if !known {
+			log.Printf("Warning: Unknown phase2_prefix question type %q, ignoring", questionType)
+			continue
+		}
+
+		if p.questionTypes == nil {
+			p.questionTypes = make(map[string]bool)
+		}
+		p.questionTypes[questionType] = true
+	}
+}
+
+// questionTypeEnabled 判斷問題類型是否在分析重點內
+func (p *Phase2PrefixRunner) questionTypeEnabled(questionType string) bool {
+	return len(p.questionTypes) == 0 || p.questionTypes[questionType]
 }
 
 // Run 執行 Phase 2 前置處理
-func (p *Phase2PrefixRunner) Run() error {
+func (p *Phase2PrefixRunner) Run() (err error) {
+	defer recoverPhasePanic("phase2_prefix", &err)
+	llm.ResetPhaseUsage("phase2_prefix")
+
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("=== Starting Phase 2 Prefix: Column Deep Analysis ===")
 
@@ -77,6 +134,16 @@ func (p *Phase2PrefixRunner) Run() error {
 		log.Println("Generating final analysis...")
 		finalAnalysis := p.generateFinalAnalysis(phase1Data, decisions)
 
+		// 將收集到的枚舉與集合值轉為 lookup/junction table DDL
+		log.Println("Generating lookup table DDL...")
+		ddlFile, err := p.writeLookupDDL(phase1Data, decisions)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if ddlFile != "" {
+			finalAnalysis["ddl_file"] = ddlFile
+		}
+
 		// 創建輸出
 		log.Println("Creating output data structure...")
 		output := map[string]interface{}{
@@ -91,7 +158,7 @@ func (p *Phase2PrefixRunner) Run() error {
 
 		// 寫入文件
 		log.Println("Writing analysis results to file...")
-		if err := p.writeOutput(output, "knowledge/phase2_prefix_analysis.json"); err != nil {
+		if err := p.writeOutput(output, p.config.KnowledgePath("phase2_prefix_analysis.json")); err != nil {
 			return err
 		}
 
@@ -109,7 +176,7 @@ func (p *Phase2PrefixRunner) Run() error {
 		log.Println("No user responses found, generating questions for review...")
 
 		log.Println("Starting question generation process...")
-		questions := p.generateQuestions(phase1Data)
+		questions, llmStatus := p.generateQuestions(phase1Data)
 
 		log.Printf("Generated %d questions total", len(questions))
 
@@ -119,7 +186,7 @@ func (p *Phase2PrefixRunner) Run() error {
 
 		// 保存問題供用戶回答
 		log.Println("Saving questions to file...")
-		if err := p.saveQuestionsForUser(questions); err != nil {
+		if err := p.saveQuestionsForUser(questions, p.insufficientSampleTables(phase1Data), llmStatus); err != nil {
 			return fmt.Errorf("failed to save questions: %v", err)
 		}
 
@@ -131,7 +198,7 @@ func (p *Phase2PrefixRunner) Run() error {
 
 // loadUserResponses 讀取用戶回答
 func (p *Phase2PrefixRunner) loadUserResponses() (map[string]interface{}, error) {
-	file, err := os.Open("knowledge/phase2_prefix_responses.json")
+	file, err := os.Open(p.config.KnowledgePath("phase2_prefix_responses.json"))
 	if err != nil {
 		return nil, err
 	}
@@ -146,8 +213,8 @@ func (p *Phase2PrefixRunner) loadUserResponses() (map[string]interface{}, error)
 	return data, nil
 }
 
-// generateQuestions 使用 LLM 生成問題
-func (p *Phase2PrefixRunner) generateQuestions(phase1Data map[string]interface{}) []map[string]interface{} {
+// generateQuestions 使用 LLM 生成問題，LLM 失敗時改用預設問題並在返回的狀態中記錄原因
+func (p *Phase2PrefixRunner) generateQuestions(phase1Data map[string]interface{}) ([]map[string]interface{}, *llm.Status) {
 	log.Println("Creating column analysis summary for LLM...")
 	// 創建數據摘要而不是使用完整數據
 	summary := p.createColumnAnalysisSummary(phase1Data)
@@ -157,73 +224,95 @@ func (p *Phase2PrefixRunner) generateQuestions(phase1Data map[string]interface{}
 	log.Printf("Column analysis summary created. Total tables: %d, columns with issues: %d",
 		len(summary["column_analysis"].([]map[string]interface{})), len(summary["column_analysis"].([]map[string]interface{})))
 
-	// 構建提示詞
+	// 構建提示詞，只列出啟用的問題類型（反正規化檢查由關聯圖產生，不交給 LLM）
 	log.Println("Preparing LLM prompt for question generation...")
+	var typeDescriptions strings.Builder
+	var typeNames []string
+	for _, qt := range phase2PrefixQuestionTypes {
+		if qt.Type == "denormalization_check" || !p.questionTypeEnabled(qt.Type) {
+			continue
+		}
+		typeNames = append(typeNames, fmt.Sprintf("%q", qt.Type))
+		typeDescriptions.WriteString(fmt.Sprintf("%d. %s\n", len(typeNames), qt.Description))
+	}
 	prompt := fmt.Sprintf(`請分析以下數據庫欄位分析摘要，並提出具體的問題來幫助用戶決定如何處理這些欄位。
 
 數據庫欄位摘要：
 %s
 
-請分析每個表格的欄位，提出以下類型的問題：
-1. 對於看起來沒有使用的欄位（大量空值）：這個欄位是否還在被使用？
-2. 對於可能需要搜集 collections 的欄位（開發者懶惰沒有建 table 直接寫 string）：這個欄位應該轉換為關聯表格嗎？
-3. 對於使用 enum 的欄位：這個欄位的枚舉值是否完整定義？
-4. 對於使用 int 但沒有定義的欄位：這個欄位是否需要更好的定義？
+請分析每個表格的欄位，只提出以下類型的問題：
+%s
+摘要中 insufficient_samples 列出的表格樣本數不足，請不要根據樣本為這些表格推論問題。
 
-請以 JSON 格式返回問題列表，每個問題包含：
+請以 JSON 物件返回問題列表，格式為 {"questions": [...]}，每個問題包含：
 - question_id: 問題的唯一標識符
-- question_type: "unused_column_check", "collection_check", "enum_check", "int_definition_check"
+- question_type: %s
 - question: 具體問題內容
 - table_name: 相關的表格名稱
 - column_name: 相關的欄位名稱
 - options: 可選的回答選項
 - analysis_data: 相關的分析數據（用於 LLM 自動回答）
 
-只返回 JSON 格式，不要其他解釋。`, summaryStr)
+只返回 JSON 格式，不要其他解釋。`, summaryStr, typeDescriptions.String(), strings.Join(typeNames, ", "))
 
 	// 調用 LLM
 	log.Println("Calling LLM to generate questions...")
-	response, err := p.llmClient.GenerateCompletion(context.Background(), prompt)
+	response, err := p.llmClient.WithJSONMode().GenerateCompletion(context.Background(), prompt)
 	if err != nil {
 		log.Printf("Warning: Failed to generate questions with LLM: %v", err)
 		log.Println("Falling back to default question generation...")
 		// 返回默認問題
 		questions := p.generateDefaultQuestions(phase1Data)
 		log.Printf("Generated %d default questions", len(questions))
-		return questions
+		return questions, llm.FallbackStatus(err)
 	}
+	status := llm.OKStatus()
 
 	log.Println("LLM response received, parsing questions...")
 
-	// 解析 LLM 回應
-	var questions []map[string]interface{}
-	if err := json.Unmarshal([]byte(response), &questions); err != nil {
-		log.Printf("Warning: Failed to parse LLM response as array, trying as object: %v", err)
-		// 嘗試解析為對象
-		var responseObj map[string]interface{}
-		if err := json.Unmarshal([]byte(response), &responseObj); err != nil {
-			log.Printf("Warning: Failed to parse LLM response: %v", err)
-			questions = p.generateDefaultQuestions(phase1Data)
-		} else {
-			// 如果是對象，嘗試提取 questions 字段
-			if q, ok := responseObj["questions"].([]interface{}); ok {
-				for _, item := range q {
-					if question, ok := item.(map[string]interface{}); ok {
-						questions = append(questions, question)
-					}
-				}
+	// 解析 LLM 回應（容許程式碼區塊標記、前後說明文字與多餘的逗號）
+	questions, err := parseQuestionList(response)
+	if err != nil {
+		log.Printf("Warning: Failed to parse LLM response: %v", err)
+		questions = p.generateDefaultQuestions(phase1Data)
+		status = llm.FallbackStatus(llm.NewParseError(p.llmClient.Endpoint(), err))
+	}
+
+	// 樣本不足的表格不保留樣本推論問題
+	questions = p.dropInsufficientSampleQuestions(questions, p.insufficientSampleTables(phase1Data))
+
+	// 過濾 LLM 產生的非分析重點問題
+	if len(p.questionTypes) > 0 {
+		filtered := questions[:0]
+		for _, q := range questions {
+			if questionType, _ := q["question_type"].(string); p.questionTypeEnabled(questionType) {
+				filtered = append(filtered, q)
 			}
 		}
+		questions = filtered
 	}
 
 	// 如果沒有生成問題，也返回默認問題
 	if len(questions) == 0 {
 		log.Printf("Warning: No questions generated by LLM, using defaults")
 		questions = p.generateDefaultQuestions(phase1Data)
+		if !status.UsedFallback {
+			status = llm.FallbackStatus(llm.NewParseError(p.llmClient.Endpoint(), fmt.Errorf("no questions in response")))
+		}
+	}
+
+	// 跨表格的反正規化檢查以關聯圖決定，不依賴 LLM
+	denormQuestions := []map[string]interface{}{}
+	if p.questionTypeEnabled("denormalization_check") {
+		denormQuestions = p.generateDenormalizationQuestions(phase1Data)
+	}
+	if len(denormQuestions) > 0 {
+		log.Printf("Detected %d potentially denormalized columns", len(denormQuestions))
+		questions = append(questions, denormQuestions...)
 	}
 
 	log.Printf("Successfully generated %d questions", len(questions))
-	return questions
+	return questions, status
 }
 
 // generateDefaultQuestions 生成默認問題（當 LLM 失敗時）
@@ -246,7 +335,11 @@ func (p *Phase2PrefixRunner) generateDefaultQuestions(phase1Data map[string]inte
 		processedTables++
 		log.Printf("Processing table %d/%d: %s", processedTables, totalTables, tableName)
 
-		tableInfo := tableData.(map[string]interface{})
+		tableInfo, ok := tableData.(map[string]interface{})
+		if !ok {
+			log.Printf("Warning: Skipping table %s with malformed analysis data", tableName)
+			continue
+		}
 
 		// 獲取 schema
 		schema, ok := tableInfo["schema"].([]interface{})
@@ -257,6 +350,13 @@ func (p *Phase2PrefixRunner) generateDefaultQuestions(phase1Data map[string]inte
 
 		log.Printf("  Analyzing %d columns in table %s", len(schema), tableName)
 
+		// 樣本不足時只產生不依賴樣本的問題
+		sufficientSamples := p.heuristics.HasSufficientSamples(tableInfo)
+		if !sufficientSamples {
+			log.Printf("  Table %s has insufficient samples (%d < %d), skipping data-dependent questions",
+				tableName, p.heuristics.SampleCount(tableInfo), p.heuristics.MinSamplesForInference)
+		}
+
 		// 分析每個欄位
 		columnsProcessed := 0
 		for _, colInfo := range schema {
@@ -283,7 +383,7 @@ func (p *Phase2PrefixRunner) generateDefaultQuestions(phase1Data map[string]inte
 			}
 
 			// 檢查可能的問題類型
-			if p.isPotentiallyUnusedColumn(col, tableInfo) {
+			if sufficientSamples && p.questionTypeEnabled("unused_column_check") && p.heuristics.IsPotentiallyUnusedColumn(col, tableInfo) {
 				questions = append(questions, map[string]interface{}{
 					"question_id":   fmt.Sprintf("q%d", questionID),
 					"question_type": "unused_column_check",
@@ -299,23 +399,48 @@ func (p *Phase2PrefixRunner) generateDefaultQuestions(phase1Data map[string]inte
 				questionID++
 			}
 
-			if p.isPotentialCollectionColumn(col, tableInfo) {
+			if sufficientSamples && p.questionTypeEnabled("collection_check") && p.heuristics.IsPotentialCollectionColumn(col, tableInfo) {
+				question := fmt.Sprintf("表格 '%s' 的欄位 '%s' 看起來像是儲存了多個值的集合。這個欄位是否應該轉換為關聯表格？", tableName, colName)
+				analysisData := map[string]interface{}{
+					"column_type": colType,
+					"nullable":    col["nullable"],
+				}
+				if isJSONColumnType(colType) {
+					question = fmt.Sprintf("表格 '%s' 的欄位 '%s' 是 %s 欄位，儲存了結構化的物件或陣列。這個 JSON 欄位是否應該正規化為子表格？", tableName, colName, strings.ToUpper(colType))
+					if structure := jsonStructureSample(colName, tableInfo); structure != nil {
+						analysisData["json_structure"] = structure
+					}
+				}
 				questions = append(questions, map[string]interface{}{
 					"question_id":   fmt.Sprintf("q%d", questionID),
 					"question_type": "collection_check",
-					"question":      fmt.Sprintf("表格 '%s' 的欄位 '%s' 看起來像是儲存了多個值的集合。這個欄位是否應該轉換為關聯表格？", tableName, colName),
+					"question":      question,
 					"table_name":    tableName,
 					"column_name":   colName,
 					"options":       []string{"應該轉換為關聯表格", "保持現狀", "需要進一步檢查"},
+					"analysis_data": analysisData,
+				})
+				questionID++
+			}
+
+			// 原生 ENUM 的值域由資料庫定義，預設視為完整，不產生 enum_check 與值搜集問題
+			nativeValues := nativeEnumValues(col)
+			if nativeValues != nil && !p.nativeEnumsComplete() && p.questionTypeEnabled("enum_check") {
+				questions = append(questions, map[string]interface{}{
+					"question_id":   fmt.Sprintf("q%d", questionID),
+					"question_type": "enum_check",
+					"question":      fmt.Sprintf("表格 '%s' 的欄位 '%s' 使用資料庫 ENUM 型別（%d 個值）。這個欄位的枚舉值是否完整定義？", tableName, colName, len(nativeValues)),
+					"table_name":    tableName,
+					"column_name":   colName,
+					"options":       []string{"枚舉值完整", "枚舉值不完整，需要補充", "需要進一步檢查"},
 					"analysis_data": map[string]interface{}{
 						"column_type": colType,
 						"nullable":    col["nullable"],
+						"enum_values": nativeValues,
 					},
 				})
 				questionID++
-			}
-
-			if p.isEnumColumn(col, tableInfo) {
+			} else if nativeValues == nil && sufficientSamples && p.questionTypeEnabled("enum_check") && p.heuristics.IsEnumColumn(col, tableInfo) {
 				questions = append(questions, map[string]interface{}{
 					"question_id":   fmt.Sprintf("q%d", questionID),
 					"question_type": "enum_check",
@@ -331,7 +456,7 @@ func (p *Phase2PrefixRunner) generateDefaultQuestions(phase1Data map[string]inte
 				questionID++
 			}
 
-			if p.isUndefinedIntColumn(col, tableInfo) {
+			if p.questionTypeEnabled("int_definition_check") && p.heuristics.IsUndefinedIntColumn(col, tableInfo) {
 				questions = append(questions, map[string]interface{}{
 					"question_id":   fmt.Sprintf("q%d", questionID),
 					"question_type": "int_definition_check",
@@ -347,7 +472,7 @@ func (p *Phase2PrefixRunner) generateDefaultQuestions(phase1Data map[string]inte
 				questionID++
 			}
 
-			if p.isValueCollectionColumn(col, tableInfo) {
+			if nativeValues == nil && sufficientSamples && p.questionTypeEnabled("value_collection_check") && p.heuristics.IsValueCollectionColumn(col, tableInfo) {
 				questions = append(questions, map[string]interface{}{
 					"question_id":   fmt.Sprintf("q%d", questionID),
 					"question_type": "value_collection_check",
@@ -370,241 +495,6 @@ func (p *Phase2PrefixRunner) generateDefaultQuestions(phase1Data map[string]inte
 	log.Printf("Default question generation completed. Total questions: %d", len(questions))
 	return questions
 }
-func (p *Phase2PrefixRunner) isPotentiallyUnusedColumn(col map[string]interface{}, tableInfo map[string]interface{}) bool {
-	// 檢查是否可為空且在樣本數據中大量為空
-	nullable, ok := col["nullable"].(bool)
-	if !ok || !nullable {
-		return false
-	}
-
-	// 檢查樣本數據
-	samples, ok := tableInfo["samples"].([]interface{})
-	if !ok || len(samples) == 0 {
-		return false
-	}
-
-	// 計算空值比例
-	colName := col["name"].(string)
-	nullCount := 0
-	totalCount := len(samples)
-
-	for _, sample := range samples {
-		sampleData, ok := sample.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		value, exists := sampleData[colName]
-		if !exists || value == nil || value == "" {
-			nullCount++
-		}
-	}
-
-	// 如果空值比例超過 80%，認為可能沒有使用
-	return float64(nullCount)/float64(totalCount) > 0.8
-}
-
-// isPotentialCollectionColumn 檢查欄位是否可能是集合類型
-func (p *Phase2PrefixRunner) isPotentialCollectionColumn(col map[string]interface{}, tableInfo map[string]interface{}) bool {
-	colType, ok := col["type"].(string)
-	if !ok {
-		return false
-	}
-
-	// 如果是 ARRAY 類型，直接視為集合欄位
-	if strings.ToUpper(colType) == "ARRAY" {
-		return true
-	}
-
-	// 檢查是否是可能儲存集合的類型
-	if !strings.Contains(strings.ToLower(colType), "text") && !strings.Contains(strings.ToLower(colType), "varchar") {
-		return false
-	}
-
-	// 檢查樣本數據中是否有集合特徵
-	samples, ok := tableInfo["samples"].([]interface{})
-	if !ok || len(samples) == 0 {
-		return false
-	}
-
-	colName := col["name"].(string)
-	collectionIndicators := 0
-
-	for _, sample := range samples {
-		sampleData, ok := sample.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		value, exists := sampleData[colName]
-		if !exists {
-			continue
-		}
-
-		valueStr := fmt.Sprintf("%v", value)
-
-		// 檢查集合指標
-		if strings.Contains(valueStr, ",") || strings.Contains(valueStr, ";") ||
-			strings.Contains(valueStr, "[") || strings.Contains(valueStr, "{") ||
-			strings.Contains(valueStr, "|") {
-			collectionIndicators++
-		}
-	}
-
-	// 如果有多個樣本顯示集合特徵
-	return collectionIndicators > 2
-}
-
-// isValueCollectionColumn 檢查欄位是否需要搜集值
-func (p *Phase2PrefixRunner) isValueCollectionColumn(col map[string]interface{}, tableInfo map[string]interface{}) bool {
-	colType, ok := col["type"].(string)
-	if !ok {
-		return false
-	}
-
-	colName, ok := col["name"].(string)
-	if !ok {
-		return false
-	}
-
-	// 檢查樣本數據
-	samples, ok := tableInfo["samples"].([]interface{})
-	if !ok || len(samples) < 3 {
-		return false
-	}
-
-	// 計算非空值的數量和唯一值
-	nonNullCount := 0
-	uniqueValues := make(map[string]bool)
-
-	for _, sample := range samples {
-		sampleData, ok := sample.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		value, exists := sampleData[colName]
-		if exists && value != nil && value != "" {
-			valueStr := fmt.Sprintf("%v", value)
-			uniqueValues[valueStr] = true
-			nonNullCount++
-		}
-	}
-
-	// 如果非空值太少，跳過
-	if nonNullCount < 3 {
-		return false
-	}
-
-	uniqueCount := len(uniqueValues)
-
-	// 對於狀態類欄位（包含 status, type, state 等關鍵字）
-	if strings.Contains(strings.ToLower(colName), "status") ||
-		strings.Contains(strings.ToLower(colName), "type") ||
-		strings.Contains(strings.ToLower(colName), "state") ||
-		strings.Contains(strings.ToLower(colName), "gender") ||
-		strings.Contains(strings.ToLower(colName), "category") {
-		return uniqueCount <= 20 // 狀態類欄位通常枚舉值不會太多
-	}
-
-	// 對於名稱類欄位（包含 name, title 等關鍵字）
-	if strings.Contains(strings.ToLower(colName), "name") ||
-		strings.Contains(strings.ToLower(colName), "title") ||
-		strings.Contains(strings.ToLower(colName), "city") ||
-		strings.Contains(strings.ToLower(colName), "address") {
-		return uniqueCount > 1 && uniqueCount <= len(samples)/2 // 名稱類欄位重複度可能較高
-	}
-
-	// 對於 ID 類欄位
-	if strings.Contains(strings.ToLower(colName), "id") &&
-		!strings.Contains(strings.ToLower(colName), "uuid") &&
-		!strings.Contains(strings.ToLower(colName), "guid") {
-		return uniqueCount > 1 && uniqueCount <= len(samples)/3 // ID 類欄位重複度不高
-	}
-
-	// 對於 varchar/char 類型，如果唯一值比例適中，可能需要搜集
-	if strings.Contains(strings.ToLower(colType), "varchar") ||
-		strings.Contains(strings.ToLower(colType), "char") {
-		uniqueRatio := float64(uniqueCount) / float64(nonNullCount)
-		return uniqueRatio > 0.1 && uniqueRatio < 0.9 && uniqueCount <= 50
-	}
-
-	return false
-}
-
-// isEnumColumn 檢查欄位是否使用枚舉
-func (p *Phase2PrefixRunner) isEnumColumn(col map[string]interface{}, tableInfo map[string]interface{}) bool {
-	colType, ok := col["type"].(string)
-	if !ok {
-		return false
-	}
-
-	// 檢查是否是可能的枚舉類型
-	if !strings.Contains(strings.ToLower(colType), "varchar") && !strings.Contains(strings.ToLower(colType), "char") {
-		return false
-	}
-
-	// 檢查樣本數據中的唯一值數量
-	samples, ok := tableInfo["samples"].([]interface{})
-	if !ok || len(samples) < 5 {
-		return false
-	}
-
-	colName := col["name"].(string)
-	uniqueValues := make(map[string]bool)
-
-	for _, sample := range samples {
-		sampleData, ok := sample.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		value, exists := sampleData[colName]
-		if exists && value != nil && value != "" {
-			uniqueValues[fmt.Sprintf("%v", value)] = true
-		}
-	}
-
-	// 如果唯一值數量少於總樣本數的 20%，可能是枚舉
-	uniqueCount := len(uniqueValues)
-	return uniqueCount > 1 && uniqueCount < len(samples)/5
-}
-
-// isUndefinedIntColumn 檢查 int 欄位是否沒有定義
-func (p *Phase2PrefixRunner) isUndefinedIntColumn(col map[string]interface{}, tableInfo map[string]interface{}) bool {
-	colType, ok := col["type"].(string)
-	if !ok {
-		return false
-	}
-
-	// 檢查是否是 int 類型
-	if !strings.Contains(strings.ToLower(colType), "int") {
-		return false
-	}
-
-	// 檢查是否有約束或外鍵
-	constraints, ok := tableInfo["constraints"].(map[string]interface{})
-	if !ok {
-		return true // 沒有約束，可能需要定義
-	}
-
-	// 檢查外鍵
-	if foreignKeys, ok := constraints["foreign_keys"].([]interface{}); ok && len(foreignKeys) > 0 {
-		return false // 有外鍵，已經定義
-	}
-
-	// 檢查主鍵
-	if primaryKeys, ok := constraints["primary_keys"].([]interface{}); ok {
-		colName := col["name"].(string)
-		for _, pk := range primaryKeys {
-			if pkStr, ok := pk.(string); ok && pkStr == colName {
-				return false // 是主鍵，已經定義
-			}
-		}
-	}
-
-	return true // 沒有外鍵也不是主鍵，可能需要更好的定義
-}
 
 // displayQuestions 顯示問題給用戶
 func (p *Phase2PrefixRunner) displayQuestions(questions []map[string]interface{}) {
@@ -640,14 +530,17 @@ func (p *Phase2PrefixRunner) displayQuestions(questions []map[string]interface{}
 }
 
 // saveQuestionsForUser 保存問題供用戶回答
-func (p *Phase2PrefixRunner) saveQuestionsForUser(questions []map[string]interface{}) error {
+func (p *Phase2PrefixRunner) saveQuestionsForUser(questions []map[string]interface{}, insufficientSamples []map[string]interface{}, llmStatus *llm.Status) error {
 	data := map[string]interface{}{
-		"generated_at": time.Now(),
-		"questions":    questions,
-		"instructions": "請回答以下問題。對於每個問題，請提供您的決定。",
+		"generated_at":         time.Now(),
+		"questions":            questions,
+		"insufficient_samples": insufficientSamples,
+		"instructions":         "請回答以下問題。對於每個問題，請提供您的決定。",
+		"llm_usage":            finishPhaseUsage(p.config, "phase2_prefix"),
+		"llm_status":           llmStatus,
 	}
 
-	return p.writeOutput(data, "knowledge/phase2_prefix_questions.json")
+	return p.writeOutput(data, p.config.KnowledgePath("phase2_prefix_questions.json"))
 }
 
 // processUserResponses 處理用戶回答
@@ -669,15 +562,21 @@ func (p *Phase2PrefixRunner) processUserResponses(phase1Data map[string]interfac
 	decisions := map[string]interface{}{
 		"column_decisions": map[string]interface{}{},
 		"summary": map[string]interface{}{
-			"columns_to_remove":  []map[string]interface{}{},
-			"columns_to_convert": []map[string]interface{}{},
-			"columns_to_define":  []map[string]interface{}{},
-			"columns_to_review":  []map[string]interface{}{},
-			"enum_values_found":  map[string]interface{}{},
-			"collection_values":  map[string]interface{}{},
+			"columns_to_remove":    []map[string]interface{}{},
+			"columns_to_convert":   []map[string]interface{}{},
+			"columns_to_define":    []map[string]interface{}{},
+			"columns_to_review":    []map[string]interface{}{},
+			"columns_to_normalize": []map[string]interface{}{},
+			"enum_values_found":    map[string]interface{}{},
+			"collection_values":    map[string]interface{}{},
 		},
 	}
 
+	// 原生 ENUM 欄位直接記錄完整值域
+	if p.nativeEnumsComplete() {
+		p.addNativeEnumValues(phase1Data, decisions)
+	}
+
 	// 處理每個用戶回答
 	log.Printf("Processing %d user responses...", len(userResponses))
 	processedResponses := 0
@@ -790,6 +689,22 @@ func (p *Phase2PrefixRunner) processUserResponses(phase1Data map[string]interfac
 					columnInfo)
 			}
 
+		case "denormalization_check":
+			if containsString(responseStr, "應該正規化") {
+				if analysisData, ok := currentQuestion["analysis_data"].(map[string]interface{}); ok {
+					columnInfo["source_table"] = analysisData["source_table"]
+					columnInfo["source_column"] = analysisData["source_column"]
+					columnInfo["via_column"] = analysisData["via_column"]
+				}
+				decisions["summary"].(map[string]interface{})["columns_to_normalize"] = append(
+					decisions["summary"].(map[string]interface{})["columns_to_normalize"].([]map[string]interface{}),
+					columnInfo)
+			} else if !containsString(responseStr, "保留") {
+				decisions["summary"].(map[string]interface{})["columns_to_review"] = append(
+					decisions["summary"].(map[string]interface{})["columns_to_review"].([]map[string]interface{}),
+					columnInfo)
+			}
+
 		default:
 			decisions["summary"].(map[string]interface{})["columns_to_review"] = append(
 				decisions["summary"].(map[string]interface{})["columns_to_review"].([]map[string]interface{}),
@@ -813,6 +728,14 @@ func (p *Phase2PrefixRunner) collectEnumValues(phase1Data map[string]interface{}
 		return
 	}
 
+	key := fmt.Sprintf("%s.%s", tableName, columnName)
+
+	// 原生 ENUM 使用資料庫定義的完整值域，而非樣本
+	if values := columnNativeEnumValues(tableData, columnName); values != nil {
+		decisions["summary"].(map[string]interface{})["enum_values_found"].(map[string]interface{})[key] = nativeEnumEntry(values)
+		return
+	}
+
 	samples, ok := tableData["samples"].([]interface{})
 	if !ok {
 		return
@@ -832,7 +755,6 @@ func (p *Phase2PrefixRunner) collectEnumValues(phase1Data map[string]interface{}
 		}
 	}
 
-	key := fmt.Sprintf("%s.%s", tableName, columnName)
 	decisions["summary"].(map[string]interface{})["enum_values_found"].(map[string]interface{})[key] = uniqueValues
 }
 
@@ -902,6 +824,14 @@ func (p *Phase2PrefixRunner) collectCollectionValues(phase1Data map[string]inter
 		return
 	}
 
+	key := fmt.Sprintf("%s.%s", tableName, columnName)
+
+	// JSON / JSONB 欄位（或樣本為 JSON 物件、陣列）記錄鍵與元素數量分佈，而非拆分字串
+	if jsonValues := collectJSONCollection(samples, columnName); jsonValues != nil {
+		decisions["summary"].(map[string]interface{})["collection_values"].(map[string]interface{})[key] = jsonValues
+		return
+	}
+
 	var collectionExamples []string
 	uniqueItems := make(map[string]int)
 
@@ -942,7 +872,6 @@ func (p *Phase2PrefixRunner) collectCollectionValues(phase1Data map[string]inter
 		}
 	}
 
-	key := fmt.Sprintf("%s.%s", tableName, columnName)
 	decisions["summary"].(map[string]interface{})["collection_values"].(map[string]interface{})[key] = map[string]interface{}{
 		"unique_items": uniqueItems,
 		"examples":     collectionExamples,
@@ -954,37 +883,26 @@ func (p *Phase2PrefixRunner) generateFinalAnalysis(phase1Data map[string]interfa
 	summary := decisions["summary"].(map[string]interface{})
 
 	return map[string]interface{}{
-		"total_questions_answered": len(decisions["column_decisions"].(map[string]interface{})),
-		"columns_to_remove_count":  len(summary["columns_to_remove"].([]map[string]interface{})),
-		"columns_to_convert_count": len(summary["columns_to_convert"].([]map[string]interface{})),
-		"columns_to_define_count":  len(summary["columns_to_define"].([]map[string]interface{})),
-		"columns_to_review_count":  len(summary["columns_to_review"].([]map[string]interface{})),
-		"enum_columns_found":       len(summary["enum_values_found"].(map[string]interface{})),
-		"collection_columns_found": len(summary["collection_values"].(map[string]interface{})),
-		"decisions_applied":        decisions,
+		"total_questions_answered":   len(decisions["column_decisions"].(map[string]interface{})),
+		"columns_to_remove_count":    len(summary["columns_to_remove"].([]map[string]interface{})),
+		"columns_to_convert_count":   len(summary["columns_to_convert"].([]map[string]interface{})),
+		"columns_to_define_count":    len(summary["columns_to_define"].([]map[string]interface{})),
+		"columns_to_review_count":    len(summary["columns_to_review"].([]map[string]interface{})),
+		"columns_to_normalize_count": len(summary["columns_to_normalize"].([]map[string]interface{})),
+		"enum_columns_found":         len(summary["enum_values_found"].(map[string]interface{})),
+		"collection_columns_found":   len(summary["collection_values"].(map[string]interface{})),
+		"decisions_applied":          decisions,
 	}
 }
 
 // loadPhase1Results 讀取 Phase 1 的分析結果
 func (p *Phase2PrefixRunner) loadPhase1Results() (map[string]interface{}, error) {
-	file, err := os.Open("knowledge/phase1_analysis.json")
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var data map[string]interface{}
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&data); err != nil {
-		return nil, err
-	}
-
-	return data, nil
+	return LoadPhase1Output(p.config.KnowledgePath("phase1_analysis.json"))
 }
 
 // loadQuestions 讀取問題文件
 func (p *Phase2PrefixRunner) loadQuestions() (map[string]interface{}, error) {
-	questionsFile := "knowledge/phase2_prefix_questions.json"
+	questionsFile := p.config.KnowledgePath("phase2_prefix_questions.json")
 
 	data, err := os.ReadFile(questionsFile)
 	if err != nil {
@@ -1023,7 +941,11 @@ func (p *Phase2PrefixRunner) createColumnAnalysisSummary(phase1Data map[string]i
 		processedTables++
 		log.Printf("Analyzing table %d/%d: %s", processedTables, totalTables, tableName)
 
-		tableInfo := tableData.(map[string]interface{})
+		tableInfo, ok := tableData.(map[string]interface{})
+		if !ok {
+			log.Printf("Warning: Skipping table %s with malformed analysis data", tableName)
+			continue
+		}
 
 		// 獲取 schema
 		schema, ok := tableInfo["schema"].([]interface{})
@@ -1034,6 +956,8 @@ func (p *Phase2PrefixRunner) createColumnAnalysisSummary(phase1Data map[string]i
 
 		log.Printf("  Processing %d columns in table %s", len(schema), tableName)
 
+		sufficientSamples := p.heuristics.HasSufficientSamples(tableInfo)
+
 		// 分析每個欄位
 		columnsWithIssues := 0
 		columnsProcessed := 0
@@ -1071,23 +995,23 @@ func (p *Phase2PrefixRunner) createColumnAnalysisSummary(phase1Data map[string]i
 			}
 
 			// 檢查可能的問題
-			if p.isPotentiallyUnusedColumn(col, tableInfo) {
+			if sufficientSamples && p.heuristics.IsPotentiallyUnusedColumn(col, tableInfo) {
 				columnAnalysis["issues"] = append(columnAnalysis["issues"].([]string), "potentially_unused")
 			}
 
-			if p.isPotentialCollectionColumn(col, tableInfo) {
+			if sufficientSamples && p.heuristics.IsPotentialCollectionColumn(col, tableInfo) {
 				columnAnalysis["issues"] = append(columnAnalysis["issues"].([]string), "potential_collection")
 			}
 
-			if p.isEnumColumn(col, tableInfo) {
+			if sufficientSamples && p.heuristics.IsEnumColumn(col, tableInfo) {
 				columnAnalysis["issues"] = append(columnAnalysis["issues"].([]string), "enum_usage")
 			}
 
-			if p.isValueCollectionColumn(col, tableInfo) {
+			if sufficientSamples && p.heuristics.IsValueCollectionColumn(col, tableInfo) {
 				columnAnalysis["issues"] = append(columnAnalysis["issues"].([]string), "value_collection_needed")
 			}
 
-			if p.isUndefinedIntColumn(col, tableInfo) {
+			if p.heuristics.IsUndefinedIntColumn(col, tableInfo) {
 				columnAnalysis["issues"] = append(columnAnalysis["issues"].([]string), "undefined_int")
 			}
 
@@ -1101,12 +1025,68 @@ func (p *Phase2PrefixRunner) createColumnAnalysisSummary(phase1Data map[string]i
 		log.Printf("  Table %s completed: %d columns analyzed, %d with issues", tableName, len(schema), columnsWithIssues)
 	}
 
+	summary["insufficient_samples"] = p.insufficientSampleTables(phase1Data)
+
 	log.Printf("Column analysis summary completed. Total columns analyzed: %d, columns with issues: %d",
 		totalColumnsAnalyzed, len(summary["column_analysis"].([]map[string]interface{})))
 
 	return summary
 }
 
+// insufficientSampleTables 列出樣本數不足以進行樣本推論的表格
+func (p *Phase2PrefixRunner) insufficientSampleTables(phase1Data map[string]interface{}) []map[string]interface{} {
+	tables, ok := phase1Data["tables"].(map[string]interface{})
+	if !ok {
+		return []map[string]interface{}{}
+	}
+
+	names := make([]string, 0, len(tables))
+	for tableName := range tables {
+		names = append(names, tableName)
+	}
+	sort.Strings(names)
+
+	result := []map[string]interface{}{}
+	for _, tableName := range names {
+		tableInfo, ok := tables[tableName].(map[string]interface{})
+		if !ok || p.heuristics.HasSufficientSamples(tableInfo) {
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"table_name":   tableName,
+			"sample_count": p.heuristics.SampleCount(tableInfo),
+			"required":     p.heuristics.MinSamplesForInference,
+			"status":       "insufficient samples",
+		})
+	}
+	return result
+}
+
+// dropInsufficientSampleQuestions 移除針對樣本不足表格的樣本推論問題（LLM 可能仍會產生）
+func (p *Phase2PrefixRunner) dropInsufficientSampleQuestions(questions []map[string]interface{}, insufficient []map[string]interface{}) []map[string]interface{} {
+	if len(insufficient) == 0 {
+		return questions
+	}
+
+	skipTables := make(map[string]bool, len(insufficient))
+	for _, t := range insufficient {
+		if name, ok := t["table_name"].(string); ok {
+			skipTables[name] = true
+		}
+	}
+
+	filtered := questions[:0]
+	for _, q := range questions {
+		tableName, _ := q["table_name"].(string)
+		questionType, _ := q["question_type"].(string)
+		if skipTables[tableName] && dataDependentQuestionTypes[questionType] {
+			continue
+		}
+		filtered = append(filtered, q)
+	}
+	return filtered
+}
+
 // writeOutput 寫入輸出到文件
 func (p *Phase2PrefixRunner) writeOutput(data interface{}, filename string) error {
 	jsonData, err := json.MarshalIndent(data, "", "  ")
@@ -1114,6 +1094,7 @@ func (p *Phase2PrefixRunner) writeOutput(data interface{}, filename string) erro
 		return err
 	}
 
+	ArchiveKnowledgeFile(p.config, filename)
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
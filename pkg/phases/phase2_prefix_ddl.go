@@ -0,0 +1,204 @@
+package phases
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// Phase2PrefixDDLPath Phase 2 前置處理產生的 lookup/junction table DDL 位置
+func Phase2PrefixDDLPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("phase2_prefix_ddl.sql")
+}
+
+var ddlIdentifierPattern = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// ddlIdentifier 將表格/欄位名稱轉為可直接用於 DDL 的識別字
+func ddlIdentifier(name string) string {
+	return strings.Trim(ddlIdentifierPattern.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+// ddlLiteral 將值轉為 SQL 字串常值
+func ddlLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// ddlSerialPrimaryKey 依資料庫類型返回自動遞增主鍵定義
+func ddlSerialPrimaryKey(dbType string) string {
+	switch dbType {
+	case "mysql":
+		return "id INT AUTO_INCREMENT PRIMARY KEY"
+	case "sqlite", "sqlite3":
+		return "id INTEGER PRIMARY KEY AUTOINCREMENT"
+	default:
+		return "id SERIAL PRIMARY KEY"
+	}
+}
+
+// collectedValues 從 enum_values_found / collection_values 的項目中取出排序後的不重複值
+// 同時支援 map[string]int（enum_check）與含 unique_values / unique_items 的 map（value_collection_check、collection_check）
+func collectedValues(entry interface{}) []string {
+	var counts map[string]interface{}
+	switch v := entry.(type) {
+	case map[string]int:
+		counts = make(map[string]interface{}, len(v))
+		for value, count := range v {
+			counts[value] = count
+		}
+	case map[string]interface{}:
+		counts = v
+		for _, key := range []string{"unique_values", "unique_items"} {
+			if nested, ok := v[key]; ok {
+				return collectedValues(nested)
+			}
+		}
+	}
+
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// parentKeyColumn 返回表格的主鍵欄位與型別，用於 junction table 的外鍵
+func parentKeyColumn(phase1Data map[string]interface{}, tableName string) (string, string) {
+	keyColumn, keyType := "id", "INTEGER"
+
+	tables, ok := phase1Data["tables"].(map[string]interface{})
+	if !ok {
+		return keyColumn, keyType
+	}
+	tableInfo, ok := tables[tableName].(map[string]interface{})
+	if !ok {
+		return keyColumn, keyType
+	}
+
+	if constraints, ok := tableInfo["constraints"].(map[string]interface{}); ok {
+		if pks, ok := constraints["primary_keys"].([]interface{}); ok && len(pks) > 0 {
+			if pk, ok := pks[0].(string); ok {
+				keyColumn = pk
+			}
+		}
+	}
+
+	if schema, ok := tableInfo["schema"].([]interface{}); ok {
+		for _, colData := range schema {
+			col, ok := colData.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if name, _ := col["name"].(string); name == keyColumn {
+				if colType, ok := col["type"].(string); ok && colType != "" {
+					keyType = strings.ToUpper(colType)
+				}
+				break
+			}
+		}
+	}
+
+	return keyColumn, keyType
+}
+
+// generateLookupDDL 將收集到的枚舉值轉為 lookup table，集合欄位轉為項目表與 junction table
+func (p *Phase2PrefixRunner) generateLookupDDL(phase1Data map[string]interface{}, decisions map[string]interface{}) string {
+	summary, ok := decisions["summary"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	dbType := p.config.Database.Type
+	var sb strings.Builder
+
+	if enums, ok := summary["enum_values_found"].(map[string]interface{}); ok {
+		keys := make([]string, 0, len(enums))
+		for key := range enums {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			values := collectedValues(enums[key])
+			parts := strings.SplitN(key, ".", 2)
+			if len(values) == 0 || len(parts) != 2 {
+				continue
+			}
+
+			lookupTable := fmt.Sprintf("%s_%s_lookup", ddlIdentifier(parts[0]), ddlIdentifier(parts[1]))
+			sb.WriteString(fmt.Sprintf("-- Lookup table for %s\n", key))
+			sb.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n    %s,\n    value VARCHAR(255) NOT NULL UNIQUE\n);\n", lookupTable, ddlSerialPrimaryKey(dbType)))
+			sb.WriteString(insertValuesSQL(lookupTable, values))
+			sb.WriteString("\n")
+		}
+	}
+
+	if collections, ok := summary["collection_values"].(map[string]interface{}); ok {
+		keys := make([]string, 0, len(collections))
+		for key := range collections {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if isJSONCollectionEntry(collections[key]) {
+				continue
+			}
+			values := collectedValues(collections[key])
+			parts := strings.SplitN(key, ".", 2)
+			if len(values) == 0 || len(parts) != 2 {
+				continue
+			}
+
+			parentTable := ddlIdentifier(parts[0])
+			itemTable := fmt.Sprintf("%s_%s_items", parentTable, ddlIdentifier(parts[1]))
+			junctionTable := fmt.Sprintf("%s_%s_map", parentTable, ddlIdentifier(parts[1]))
+			keyColumn, keyType := parentKeyColumn(phase1Data, parts[0])
+			parentRef := fmt.Sprintf("%s_%s", parentTable, ddlIdentifier(keyColumn))
+
+			sb.WriteString(fmt.Sprintf("-- Junction table for collection column %s\n", key))
+			sb.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n    %s,\n    value VARCHAR(255) NOT NULL UNIQUE\n);\n", itemTable, ddlSerialPrimaryKey(dbType)))
+			sb.WriteString(insertValuesSQL(itemTable, values))
+			sb.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n    %s %s NOT NULL REFERENCES %s(%s),\n    item_id INTEGER NOT NULL REFERENCES %s(id),\n    PRIMARY KEY (%s, item_id)\n);\n\n",
+				junctionTable, parentRef, keyType, parentTable, ddlIdentifier(keyColumn), itemTable, parentRef))
+		}
+	}
+
+	if sb.Len() == 0 {
+		return ""
+	}
+
+	header := fmt.Sprintf("-- Generated by Phase 2 prefix at %s\n-- Database type: %s\n\n", time.Now().Format(time.RFC3339), dbType)
+	return header + sb.String()
+}
+
+// insertValuesSQL 產生將不重複值寫入 lookup table 的 INSERT 語句
+func insertValuesSQL(table string, values []string) string {
+	literals := make([]string, 0, len(values))
+	for _, value := range values {
+		literals = append(literals, fmt.Sprintf("(%s)", ddlLiteral(value)))
+	}
+	return fmt.Sprintf("INSERT INTO %s (value) VALUES\n    %s;\n", table, strings.Join(literals, ",\n    "))
+}
+
+// writeLookupDDL 產生並寫入 DDL 檔案，沒有收集到任何值時不寫入
+func (p *Phase2PrefixRunner) writeLookupDDL(phase1Data map[string]interface{}, decisions map[string]interface{}) (string, error) {
+	ddl := p.generateLookupDDL(phase1Data, decisions)
+	if ddl == "" {
+		log.Println("No enum or collection values collected, skipping DDL generation")
+		return "", nil
+	}
+
+	if err := os.WriteFile(Phase2PrefixDDLPath(p.config), []byte(ddl), 0644); err != nil {
+		return "", fmt.Errorf("failed to write lookup table DDL: %v", err)
+	}
+
+	log.Printf("Lookup table DDL saved to %s", Phase2PrefixDDLPath(p.config))
+	return Phase2PrefixDDLPath(p.config), nil
+}
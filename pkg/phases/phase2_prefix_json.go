@@ -0,0 +1,168 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxJSONStructureKeys JSON 結構樣本中最多列出的鍵數量
+const maxJSONStructureKeys = 20
+
+// isJSONColumnType 判斷欄位型別是否為原生 JSON / JSONB
+func isJSONColumnType(colType string) bool {
+	switch strings.ToLower(strings.TrimSpace(colType)) {
+	case "json", "jsonb":
+		return true
+	}
+	return false
+}
+
+// parseJSONCollection 將樣本值解析為 JSON 物件或陣列；phase1 樣本可能已解碼，也可能是 JSON 字串
+func parseJSONCollection(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}, []interface{}:
+		return v, true
+	case string:
+		trimmed := strings.TrimSpace(v)
+		if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+			return nil, false
+		}
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(trimmed), &parsed); err != nil {
+			return nil, false
+		}
+		switch parsed.(type) {
+		case map[string]interface{}, []interface{}:
+			return parsed, true
+		}
+	}
+	return nil, false
+}
+
+// jsonStructureSample 從表格樣本中取第一個可解析的 JSON 值，描述其結構（物件的鍵或陣列長度與元素型別）
+func jsonStructureSample(colName string, tableInfo map[string]interface{}) map[string]interface{} {
+	samples, _ := tableInfo["samples"].([]interface{})
+	for _, sample := range samples {
+		sampleData, ok := sample.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		parsed, ok := parseJSONCollection(sampleData[colName])
+		if !ok {
+			continue
+		}
+
+		switch v := parsed.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if len(keys) > maxJSONStructureKeys {
+				keys = keys[:maxJSONStructureKeys]
+			}
+			return map[string]interface{}{
+				"kind": "object",
+				"keys": keys,
+			}
+		case []interface{}:
+			structure := map[string]interface{}{
+				"kind":   "array",
+				"length": len(v),
+			}
+			if len(v) > 0 {
+				structure["element_type"] = jsonValueKind(v[0])
+			}
+			return structure
+		}
+	}
+	return nil
+}
+
+// jsonValueKind 返回 JSON 值的型別名稱
+func jsonValueKind(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// collectJSONCollection 統計 JSON 欄位樣本：物件記錄各鍵出現次數，陣列記錄元素數量分佈；
+// 沒有任何樣本可解析為 JSON 物件或陣列時返回 nil
+func collectJSONCollection(samples []interface{}, columnName string) map[string]interface{} {
+	keyCounts := make(map[string]int)
+	lengthCounts := make(map[string]int)
+	var examples []string
+	objects, arrays := 0, 0
+
+	for _, sample := range samples {
+		sampleData, ok := sample.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		parsed, ok := parseJSONCollection(sampleData[columnName])
+		if !ok {
+			continue
+		}
+
+		switch v := parsed.(type) {
+		case map[string]interface{}:
+			objects++
+			for key := range v {
+				keyCounts[key]++
+			}
+		case []interface{}:
+			arrays++
+			lengthCounts[fmt.Sprintf("%d", len(v))]++
+		}
+
+		if len(examples) < 5 {
+			if encoded, err := json.Marshal(parsed); err == nil {
+				examples = append(examples, string(encoded))
+			}
+		}
+	}
+
+	if objects == 0 && arrays == 0 {
+		return nil
+	}
+
+	result := map[string]interface{}{
+		"json_structure": true,
+		"objects":        objects,
+		"arrays":         arrays,
+		"examples":       examples,
+	}
+	if objects > 0 {
+		result["key_counts"] = keyCounts
+	}
+	if arrays > 0 {
+		result["length_distribution"] = lengthCounts
+	}
+	return result
+}
+
+// isJSONCollectionEntry 判斷 collection_values 項目是否來自 JSON 欄位（不產生 junction table DDL）
+func isJSONCollectionEntry(entry interface{}) bool {
+	m, ok := entry.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	isJSON, _ := m["json_structure"].(bool)
+	return isJSON
+}
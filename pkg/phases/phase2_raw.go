@@ -0,0 +1,89 @@
+package phases
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/privacy"
+)
+
+// Phase2RawDir Phase 2 每個表格原始 LLM 請求與回應的存放目錄（llm.phase2_raw_output 啟用時）
+func Phase2RawDir(cfg *config.Config) string {
+	return cfg.KnowledgePath("phase2_raw")
+}
+
+var rawFileNamePattern = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Phase2RawRecord 單一表格的原始 LLM 分析記錄，用於稽核與診斷
+type Phase2RawRecord struct {
+	TableName    string                 `json:"table_name"`
+	Timestamp    time.Time              `json:"timestamp"`
+	Model        string                 `json:"model"`
+	SystemPrompt string                 `json:"system_prompt"`
+	Prompt       string                 `json:"prompt"`             // 樣本依 security.sample_masking 遮罩後的提示
+	Response     map[string]interface{} `json:"response,omitempty"` // LLM 的原始回應
+	Error        string                 `json:"error,omitempty"`    // 請求失敗原因（此時分析為後備內容）
+	Analysis     string                 `json:"analysis"`
+}
+
+// Phase2RawPath 返回表格原始記錄的檔案路徑
+func Phase2RawPath(cfg *config.Config, tableName string) string {
+	return filepath.Join(Phase2RawDir(cfg), rawFileNamePattern.ReplaceAllString(tableName, "_")+".json")
+}
+
+// saveRawAnalysis 在 llm.phase2_raw_output 啟用時保存表格的原始 LLM 請求與回應，失敗只記錄警告
+func (o *TableAnalysisOrchestrator) saveRawAnalysis(tableName string, summary map[string]interface{}, response *LLMResponse) {
+	if !o.config.LLM.Phase2RawOutput {
+		return
+	}
+
+	record := Phase2RawRecord{
+		TableName:    tableName,
+		Timestamp:    time.Now(),
+		Model:        o.config.LLM.Model,
+		SystemPrompt: SystemPrompt(o.config, "phase2", defaultPhase2SystemPrompt),
+		Prompt:       o.buildAnalysisPrompt(o.redactSummary(tableName, summary)),
+		Response:     response.raw,
+		Error:        describeRequestError(response.requestErr),
+		Analysis:     response.Analysis,
+	}
+
+	if err := os.MkdirAll(Phase2RawDir(o.config), 0755); err != nil {
+		log.Printf("Warning: Failed to create %s: %v", Phase2RawDir(o.config), err)
+		return
+	}
+	if err := writeJSONFile(Phase2RawPath(o.config, tableName), record); err != nil {
+		log.Printf("Warning: Failed to save raw LLM analysis for table %s: %v", tableName, err)
+	}
+}
+
+// redactSummary 返回樣本已遮罩的摘要副本，遮罩規則與 MCP 工具及向量存儲一致
+func (o *TableAnalysisOrchestrator) redactSummary(tableName string, summary map[string]interface{}) map[string]interface{} {
+	samples, ok := summary["samples"].([]map[string]interface{})
+	if !ok || len(samples) == 0 {
+		return summary
+	}
+
+	schema, _ := summary["columns"].([]map[string]interface{})
+	masked, _ := privacy.MaskSamples(o.config.Security.SampleMasking, tableName, schema, samples)
+
+	redacted := make(map[string]interface{}, len(summary))
+	for key, value := range summary {
+		redacted[key] = value
+	}
+	redacted["samples"] = masked
+	return redacted
+}
+
+// describeRequestError 將請求錯誤轉為記錄用字串
+func describeRequestError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return fmt.Sprintf("LLM request failed, fallback analysis used: %v", err)
+}
@@ -3,6 +3,7 @@ package phases
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -17,13 +18,17 @@ type Phase3Runner struct {
 	config      *config.Config
 	llmClient   *llm.Client
 	vectorStore *vectorstore.KnowledgeManager
+	progressFn  ProgressFunc
 }
 
+// phase3ProgressSteps is the number of steps Phase 3 reports: read Phase 2 output, generate the description, save it
+const phase3ProgressSteps = 3
+
 // NewPhase3Runner creates a new Phase3Runner instance
 func NewPhase3Runner(cfg *config.Config, llmClient *llm.Client, vectorStore *vectorstore.KnowledgeManager) *Phase3Runner {
 	return &Phase3Runner{
 		config:      cfg,
-		llmClient:   llmClient,
+		llmClient:   llmClient.WithPhase("phase3"),
 		vectorStore: vectorStore,
 	}
 }
@@ -37,11 +42,29 @@ type Phase3AnalysisResult struct {
 	KeyBusinessProcesses []string            `json:"key_business_processes"`
 	DataFlowPatterns     []string            `json:"data_flow_patterns"`
 	Recommendations      []string            `json:"recommendations"`
+	DomainHints          *DomainHints        `json:"domain_hints,omitempty"`
+	LLMUsage             *llm.Usage          `json:"llm_usage,omitempty"`
+	LLMStatus            *llm.Status         `json:"llm_status,omitempty"`
 	Timestamp            string              `json:"timestamp"`
 }
 
+// SetProgressFunc sets the callback invoked after each Phase 3 step
+func (p *Phase3Runner) SetProgressFunc(fn ProgressFunc) {
+	p.progressFn = fn
+}
+
+// reportProgress reports a completed step if a progress callback is set
+func (p *Phase3Runner) reportProgress(completed int, message string) {
+	if p.progressFn != nil {
+		p.progressFn(completed, phase3ProgressSteps, message)
+	}
+}
+
 // Run executes the phase 3 analysis
-func (p *Phase3Runner) Run(ctx context.Context) error {
+func (p *Phase3Runner) Run(ctx context.Context) (err error) {
+	defer recoverPhasePanic("phase3", &err)
+	llm.ResetPhaseUsage("phase3")
+
 	fmt.Println("Starting Phase 3: Business Logic Description Generation")
 
 	// Read phase 2 analysis results
@@ -49,17 +72,22 @@ func (p *Phase3Runner) Run(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to read phase 2 analysis: %w", err)
 	}
+	p.reportProgress(1, fmt.Sprintf("Loaded Phase 2 analysis of %d tables", len(phase2Data.AnalysisResults)))
 
 	// Generate business logic description using LLM
 	result, err := p.generateBusinessLogicDescription(ctx, phase2Data)
 	if err != nil {
 		return fmt.Errorf("failed to generate business logic description: %w", err)
 	}
+	p.reportProgress(2, "Business logic description generated")
 
-	// Save the result
+	// Save the result together with the token usage of this run
+	usage := finishPhaseUsage(p.config, "phase3")
+	result.LLMUsage = &usage
 	if err := p.saveResult(result); err != nil {
 		return fmt.Errorf("failed to save phase 3 result: %w", err)
 	}
+	p.reportProgress(3, "Phase 3 result saved")
 
 	fmt.Println("Phase 3 completed successfully")
 	return nil
@@ -67,7 +95,7 @@ func (p *Phase3Runner) Run(ctx context.Context) error {
 
 // readPhase2Analysis reads the phase 2 analysis results from file
 func (p *Phase3Runner) readPhase2Analysis() (*Phase2AnalysisResult, error) {
-	filePath := "knowledge/phase2_analysis.json"
+	filePath := p.config.KnowledgePath("phase2_analysis.json")
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read phase 2 analysis file: %w", err)
@@ -81,57 +109,79 @@ func (p *Phase3Runner) readPhase2Analysis() (*Phase2AnalysisResult, error) {
 	return &result, nil
 }
 
-// Phase2AnalysisResult represents the structure of phase 2 analysis results
-type Phase2AnalysisResult struct {
-	AnalysisResults map[string]TableAnalysis `json:"analysis_results"`
-	Database        string                   `json:"database"`
-	DatabaseType    string                   `json:"database_type"`
-	Description     string                   `json:"description"`
-	Summary         Phase2Summary            `json:"summary"`
-	Timestamp       string                   `json:"timestamp"`
-}
-
-// TableAnalysis represents the analysis of a single table
-type TableAnalysis struct {
-	TableName string `json:"table_name"`
-	Analysis  string `json:"analysis"`
-	Timestamp string `json:"timestamp"`
-}
-
-// Phase2Summary represents the summary of phase 2 analysis
-type Phase2Summary struct {
-	AnalysisTimestamp   string `json:"analysis_timestamp"`
-	Description         string `json:"description"`
-	Phase               string `json:"phase"`
-	TotalTablesAnalyzed int    `json:"total_tables_analyzed"`
-}
-
 // generateBusinessLogicDescription uses LLM to generate comprehensive business logic description
 func (p *Phase3Runner) generateBusinessLogicDescription(ctx context.Context, phase2Data *Phase2AnalysisResult) (*Phase3AnalysisResult, error) {
+	// Load user-provided domain hints from the pre-phase3 summary
+	hints, err := LoadDomainHints(PrePhase3SummaryPath(p.config))
+	if err != nil {
+		fmt.Printf("Failed to load domain hints, continuing without them: %v\n", err)
+	}
+
 	// Prepare the analysis data for LLM
 	analysisText := p.prepareAnalysisText(phase2Data)
+	if section := hints.PromptSection(); section != "" {
+		analysisText = section + "\n" + analysisText
+	}
 
-	// Create the prompt for LLM
+	// Create the prompt for LLM, prefixed with the configured persona if any
 	prompt := p.createBusinessLogicPrompt(analysisText)
+	if persona := SystemPrompt(p.config, "phase3", ""); persona != "" {
+		prompt = persona + "\n\n" + prompt
+	}
 
 	// Call LLM to generate business logic description
-	response, err := p.llmClient.GenerateCompletion(ctx, prompt)
+	// and record in llm_status whether the fallback had to be used
+	var result *Phase3AnalysisResult
+	status := llm.OKStatus()
+	// Stream the response so a generation cut off mid-way is detected rather than lost silently
+	response, err := llm.CollectStream(p.llmClient.WithJSONMode().GenerateCompletionStream(ctx, prompt))
 	if err != nil {
+		if errors.Is(err, llm.ErrStreamIncomplete) {
+			fmt.Printf("LLM stream ended early after %d characters\n", len(response))
+		}
 		// Fallback: generate basic business logic description without LLM
 		fmt.Printf("LLM call failed, using fallback method: %v\n", err)
-		return p.generateFallbackDescription(phase2Data), nil
-	}
-
-	// Parse the LLM response
-	result, err := p.parseLLMResponse(response, phase2Data)
-	if err != nil {
+		result = p.generateFallbackDescription(phase2Data)
+		status = llm.FallbackStatus(err)
+	} else if result, err = p.parseLLMResponse(response, phase2Data); err != nil {
 		fmt.Printf("Failed to parse LLM response, using fallback: %v\n", err)
-		return p.generateFallbackDescription(phase2Data), nil
+		result = p.generateFallbackDescription(phase2Data)
+		status = llm.FallbackStatus(llm.NewParseError(p.llmClient.Endpoint(), err))
 	}
+	result.LLMStatus = status
 
+	p.applyDomainHints(result, hints)
 	return result, nil
 }
 
+// applyDomainHints merges user-provided domain hints into the generated result
+func (p *Phase3Runner) applyDomainHints(result *Phase3AnalysisResult, hints *DomainHints) {
+	if hints == nil {
+		return
+	}
+
+	result.DomainHints = hints
+
+	// The user-stated domain takes precedence over the generated guess
+	if hints.BusinessDomain != "" && !strings.Contains(strings.ToLower(result.BusinessLogicSummary), strings.ToLower(hints.BusinessDomain)) {
+		result.BusinessLogicSummary = fmt.Sprintf("Business domain (user-provided): %s. %s", hints.BusinessDomain, result.BusinessLogicSummary)
+	}
+
+	// Make sure every focus area is reflected in the recommendations
+	for _, area := range hints.AnalysisFocusAreas {
+		covered := false
+		for _, rec := range result.Recommendations {
+			if strings.Contains(strings.ToLower(rec), strings.ToLower(area)) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			result.Recommendations = append(result.Recommendations, fmt.Sprintf("Prioritize analysis of %s (user-provided focus area)", area))
+		}
+	}
+}
+
 // prepareAnalysisText converts the phase 2 analysis results into a concise text format for LLM
 func (p *Phase3Runner) prepareAnalysisText(phase2Data *Phase2AnalysisResult) string {
 	var sb strings.Builder
@@ -186,16 +236,12 @@ Provide a JSON response with:
 
 // parseLLMResponse parses the LLM response into a Phase3AnalysisResult
 func (p *Phase3Runner) parseLLMResponse(response string, phase2Data *Phase2AnalysisResult) (*Phase3AnalysisResult, error) {
-	// Try to extract JSON from the response
-	jsonStart := strings.Index(response, "{")
-	jsonEnd := strings.LastIndex(response, "}")
-
-	if jsonStart == -1 || jsonEnd == -1 || jsonEnd <= jsonStart {
-		return nil, fmt.Errorf("no valid JSON found in LLM response")
+	// Strip code fences and surrounding prose, and repair trailing commas
+	jsonStr, err := extractJSON(response)
+	if err != nil {
+		return nil, err
 	}
 
-	jsonStr := response[jsonStart : jsonEnd+1]
-
 	var llmResult struct {
 		BusinessLogicSummary string              `json:"business_logic_summary"`
 		TableCategories      map[string][]string `json:"table_categories"`
@@ -326,18 +372,19 @@ func (p *Phase3Runner) identifyBusinessProcesses(analysisResults map[string]Tabl
 // saveResult saves the phase 3 analysis result to a JSON file
 func (p *Phase3Runner) saveResult(result *Phase3AnalysisResult) error {
 	// Create the output directory if it doesn't exist
-	outputDir := "knowledge"
+	outputDir := p.config.KnowledgeDir()
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	// Save to phase3_analysis.json
-	filePath := "knowledge/phase3_analysis.json"
+	filePath := p.config.KnowledgePath("phase3_analysis.json")
 	data, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal result: %w", err)
 	}
 
+	ArchiveKnowledgeFile(p.config, filePath)
 	if err := os.WriteFile(filePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write result file: %w", err)
 	}
@@ -14,26 +14,6 @@ import (
 	lua "github.com/yuin/gopher-lua"
 )
 
-// Dimension 維度定義
-type Dimension struct {
-	Name        string   `json:"name"`
-	Type        string   `json:"type"` // people, time, product, event, location
-	Description string   `json:"description"`
-	SourceTable string   `json:"source_table"`
-	KeyFields   []string `json:"key_fields"`
-	Attributes  []string `json:"attributes"`
-	BusinessUse string   `json:"business_use"`
-}
-
-// FactTable 事實表定義
-type FactTable struct {
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	SourceTable string   `json:"source_table"`
-	Measures    []string `json:"measures"`
-	Dimensions  []string `json:"dimensions"`
-}
-
 // Phase4Runner Phase 4 執行器 - 使用 Lua 規則引擎進行維度建模
 type Phase4Runner struct {
 	config       *config.Config
@@ -59,7 +39,9 @@ func NewPhase4Runner(cfg *config.Config, db *sql.DB) *Phase4Runner {
 }
 
 // Run 執行 Phase 4 維度建模分析（使用 Lua 規則引擎）
-func (p *Phase4Runner) Run() error {
+func (p *Phase4Runner) Run() (err error) {
+	defer recoverPhasePanic("phase4", &err)
+
 	log.Println("=== Starting Phase 4: Lua Rule Engine Dimension Modeling ===")
 
 	// 從向量存儲檢索 Phase 3 維度規則
@@ -80,17 +62,41 @@ func (p *Phase4Runner) Run() error {
 	}
 	defer p.luaState.Close()
 
+	// 載入使用者提供的領域提示，供 Lua 規則參考
+	hints, err := LoadDomainHints(PrePhase3SummaryPath(p.config))
+	if err != nil {
+		log.Printf("Warning: Failed to load domain hints: %v", err)
+	}
+	p.setLuaDomainHints(hints)
+
 	// 使用 Lua 規則引擎生成維度
 	dimensions, factTables, err := p.executeLuaRules(phase2Results)
 	if err != nil {
 		return fmt.Errorf("failed to execute Lua rules: %v", err)
 	}
 
+	// 沒有主鍵的來源表格不適合作為維度，保留提案但附上警告
+	var keylessTables []string
+	if phase1, err := LoadPhase1Output(p.config.KnowledgePath("phase1_analysis.json")); err != nil {
+		log.Printf("Warning: Failed to load phase1 output for primary key check: %v", err)
+	} else {
+		keylessTables = warnKeylessDimensions(dimensions, primaryKeylessTables(phase1))
+	}
+
+	// 合併使用者自訂的維度
+	dimensions = mergeCustomDimensions(dimensions, hints)
+
 	// 生成維度建模報告 - 按照分類組織
 	report := p.generateCategorizedReport(dimensions, factTables)
+	if hints != nil {
+		report["domain_hints"] = hints
+	}
+	if len(keylessTables) > 0 {
+		report["tables_without_primary_key"] = keylessTables
+	}
 
 	// 保存報告並存儲到向量數據庫
-	if err := p.writeOutput(report, "knowledge/phase4_dimensions.json"); err != nil {
+	if err := p.writeOutput(report, p.config.KnowledgePath("phase4_dimensions.json")); err != nil {
 		return err
 	}
 
@@ -105,13 +111,18 @@ func (p *Phase4Runner) Run() error {
 
 // retrievePhase3Rules 從向量存儲檢索 Phase 3 維度規則
 func (p *Phase4Runner) retrievePhase3Rules() (string, error) {
-	if p.knowledgeMgr == nil {
+	return retrievePhase3Rules(p.knowledgeMgr)
+}
+
+// retrievePhase3Rules 從指定的知識管理器檢索 Phase 3 維度規則，找不到時返回空字符串
+func retrievePhase3Rules(knowledgeMgr *vectorstore.KnowledgeManager) (string, error) {
+	if knowledgeMgr == nil {
 		return "", fmt.Errorf("knowledge manager not available")
 	}
 
 	// 檢索 Phase 3 的維度規則
 	query := "dimension rules lua script phase3"
-	results, err := p.knowledgeMgr.RetrievePhaseKnowledge("phase3", query, 5)
+	results, err := knowledgeMgr.RetrievePhaseKnowledge("phase3", query, 5)
 	if err != nil {
 		return "", fmt.Errorf("failed to retrieve phase3 rules: %v", err)
 	}
@@ -206,7 +217,7 @@ func (p *Phase4Runner) retrievePhase2Knowledge() (map[string]*LLMAnalysisResult,
 
 // retrievePhase2FromJSON 從 JSON 文件檢索 Phase 2 知識（備用方案）
 func (p *Phase4Runner) retrievePhase2FromJSON() (map[string]*LLMAnalysisResult, error) {
-	reader := NewPhase2ResultReader("knowledge/phase2_analysis.json")
+	reader := NewPhase2ResultReader(p.config.KnowledgePath("phase2_analysis.json"))
 	return reader.GetAnalysisResults()
 }
 
@@ -220,7 +231,7 @@ func (p *Phase4Runner) initLuaVM(rulesContent string) error {
 			return fmt.Errorf("failed to execute Lua rules string: %v", err)
 		}
 	} else {
-		if err := p.luaState.DoFile("knowledge/dimension_rules.lua"); err != nil {
+		if err := p.luaState.DoFile(DefaultLuaRulesPath(p.config)); err != nil {
 			return fmt.Errorf("failed to load Lua rules file: %v", err)
 		}
 	}
@@ -228,24 +239,61 @@ func (p *Phase4Runner) initLuaVM(rulesContent string) error {
 	return nil
 }
 
-// retrieveTableAnalysisFromFile 從 phase1_analysis.json 文件中檢索表格分析信息
-func (p *Phase4Runner) retrieveTableAnalysisFromFile(tableName string) (*TableAnalysisResult, error) {
-	// 讀取 phase1_analysis.json 文件
-	data, err := os.ReadFile("knowledge/phase1_analysis.json")
-	if err != nil {
-		return nil, fmt.Errorf("failed to read phase1_analysis.json: %v", err)
+// setLuaDomainHints 將領域提示設定為 Lua 全域變數 domain_hints，規則可依此調整判斷
+func (p *Phase4Runner) setLuaDomainHints(hints *DomainHints) {
+	hintsTable := p.luaState.NewTable()
+	if hints != nil {
+		hintsTable.RawSetString("business_domain", lua.LString(hints.BusinessDomain))
+		hintsTable.RawSetString("key_entities", p.stringSliceToLuaTable(hints.KeyEntities))
+		hintsTable.RawSetString("important_relationships", p.stringSliceToLuaTable(hints.ImportantRelationships))
+		hintsTable.RawSetString("analysis_focus_areas", p.stringSliceToLuaTable(hints.AnalysisFocusAreas))
+	}
+	p.luaState.SetGlobal("domain_hints", hintsTable)
+}
+
+// stringSliceToLuaTable 將字符串切片轉換為 Lua 表格
+func (p *Phase4Runner) stringSliceToLuaTable(items []string) *lua.LTable {
+	table := p.luaState.NewTable()
+	for i, item := range items {
+		table.RawSetInt(i+1, lua.LString(item))
+	}
+	return table
+}
+
+// mergeCustomDimensions 將使用者自訂維度加入結果，同名維度以使用者定義為準
+func mergeCustomDimensions(dimensions []Dimension, hints *DomainHints) []Dimension {
+	if hints == nil || len(hints.CustomDimensions) == 0 {
+		return dimensions
 	}
 
-	var phase1Data struct {
-		Tables map[string]interface{} `json:"tables"`
+	custom := make(map[string]bool)
+	for _, dim := range hints.CustomDimensions {
+		custom[dim.Name] = true
 	}
 
-	if err := json.Unmarshal(data, &phase1Data); err != nil {
-		return nil, fmt.Errorf("failed to parse phase1_analysis.json: %v", err)
+	merged := make([]Dimension, 0, len(dimensions)+len(hints.CustomDimensions))
+	for _, dim := range dimensions {
+		if !custom[dim.Name] {
+			merged = append(merged, dim)
+		}
+	}
+	merged = append(merged, hints.CustomDimensions...)
+
+	log.Printf("Merged %d custom dimensions from domain hints", len(hints.CustomDimensions))
+	return merged
+}
+
+// retrieveTableAnalysisFromFile 從 phase1_analysis.json 文件中檢索表格分析信息
+func (p *Phase4Runner) retrieveTableAnalysisFromFile(tableName string) (*TableAnalysisResult, error) {
+	// 讀取 phase1_analysis.json 文件（驗證格式版本並遷移舊格式）
+	phase1Data, err := LoadPhase1Output(p.config.KnowledgePath("phase1_analysis.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load phase1_analysis.json: %v", err)
 	}
 
 	// 查找指定的表格
-	tableData, ok := phase1Data.Tables[tableName]
+	tables, _ := phase1Data["tables"].(map[string]interface{})
+	tableData, ok := tables[tableName]
 	if !ok {
 		return nil, fmt.Errorf("table %s not found in phase1_analysis.json", tableName)
 	}
@@ -307,6 +355,8 @@ func (p *Phase4Runner) executeLuaRules(phase2Results map[string]*LLMAnalysisResu
 
 	// 跟踪已生成的維度名稱以避免重複
 	existingDimensions := make(map[string]bool)
+	// 跟踪已生成的事實表名稱與位置，同名事實表合併度量與維度
+	existingFactTables := make(map[string]int)
 
 	// 獲取 Lua 函數
 	detectDimensionsFn := p.luaState.GetGlobal("detect_dimensions")
@@ -365,13 +415,41 @@ func (p *Phase4Runner) executeLuaRules(phase2Results map[string]*LLMAnalysisResu
 
 		if factsTable, ok := factsResult.(*lua.LTable); ok {
 			tableFacts := p.convertLuaTableToFactTables(factsTable)
-			factTables = append(factTables, tableFacts...)
+			for _, fact := range tableFacts {
+				if index, exists := existingFactTables[fact.Name]; exists {
+					mergeFactTable(&factTables[index], fact)
+					continue
+				}
+				existingFactTables[fact.Name] = len(factTables)
+				factTables = append(factTables, fact)
+			}
 		}
 	}
 
 	return dimensions, factTables, nil
 }
 
+// mergeFactTable 將同名事實表的度量與維度併入既有項目（保留順序並去除重複），其餘欄位沿用先出現者
+func mergeFactTable(existing *FactTable, fact FactTable) {
+	existing.Measures = appendUnique(existing.Measures, fact.Measures...)
+	existing.Dimensions = appendUnique(existing.Dimensions, fact.Dimensions...)
+}
+
+// appendUnique 附加尚未出現在 list 中的值
+func appendUnique(list []string, values ...string) []string {
+	seen := make(map[string]bool, len(list))
+	for _, value := range list {
+		seen[value] = true
+	}
+	for _, value := range values {
+		if !seen[value] {
+			list = append(list, value)
+			seen[value] = true
+		}
+	}
+	return list
+}
+
 // createTableMeta 創建表格元數據（Lua 表格）
 func (p *Phase4Runner) createTableMeta(tableName string, result *LLMAnalysisResult) *lua.LTable {
 	meta := p.luaState.NewTable()
@@ -594,7 +672,7 @@ func (p *Phase4Runner) generateCategorizedSummary(categorizedDimensions map[stri
 			"location": len(categorizedDimensions["location"]),
 		},
 		"rule_engine_info": map[string]interface{}{
-			"lua_script": "knowledge/dimension_rules.lua",
+			"lua_script": p.config.KnowledgePath("dimension_rules.lua"),
 			"engine":     "Gopher-Lua v1.1.1",
 		},
 	}
@@ -673,6 +751,7 @@ func (p *Phase4Runner) writeOutput(data interface{}, filename string) error {
 		return err
 	}
 
+	ArchiveKnowledgeFile(p.config, filename)
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -703,7 +782,7 @@ func (p *Phase4Runner) storePhase4Results(dimensions []Dimension, factTables []F
 		"timestamp":             time.Now(),
 		"dimensions_count":      len(dimensions),
 		"fact_tables_count":     len(factTables),
-		"lua_rules_file":        "knowledge/dimension_rules.lua",
+		"lua_rules_file":        p.config.KnowledgePath("dimension_rules.lua"),
 		"dimensions_generated":  make([]map[string]interface{}, len(dimensions)),
 		"fact_tables_generated": make([]map[string]interface{}, len(factTables)),
 	}
@@ -0,0 +1,137 @@
+package phases
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/vectorstore"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// DefaultLuaRulesPath 向量存儲中沒有規則時使用的 Lua 規則文件
+func DefaultLuaRulesPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("dimension_rules.lua")
+}
+
+// requiredLuaRuleFunctions Phase 4 執行時必須存在的 Lua 全域函數
+var requiredLuaRuleFunctions = []string{"detect_dimensions", "detect_fact_tables"}
+
+// LuaFunctionCheck 單一 Lua 規則函數的檢查結果
+type LuaFunctionCheck struct {
+	Name     string `json:"name"`
+	Found    bool   `json:"found"`
+	Callable bool   `json:"callable"`
+	Error    string `json:"error,omitempty"`
+}
+
+// LuaRulesValidation Lua 規則驗證結果
+type LuaRulesValidation struct {
+	Source    string             `json:"source"`
+	Valid     bool               `json:"valid"`
+	LoadError string             `json:"load_error,omitempty"`
+	Functions []LuaFunctionCheck `json:"functions"`
+}
+
+// ValidatePhase4Rules 載入 Phase 4 實際會使用的 Lua 規則（向量存儲優先，否則使用預設文件）並驗證
+func ValidatePhase4Rules(cfg *config.Config, knowledgeMgr *vectorstore.KnowledgeManager) *LuaRulesValidation {
+	rulesContent := ""
+	if knowledgeMgr != nil {
+		content, err := retrievePhase3Rules(knowledgeMgr)
+		if err != nil {
+			return &LuaRulesValidation{
+				Source:    "vector_store",
+				LoadError: err.Error(),
+				Functions: []LuaFunctionCheck{},
+			}
+		}
+		rulesContent = content
+	}
+
+	if rulesContent != "" {
+		return ValidateLuaRules("vector_store", rulesContent)
+	}
+
+	data, err := os.ReadFile(DefaultLuaRulesPath(cfg))
+	if err != nil {
+		return &LuaRulesValidation{
+			Source:    DefaultLuaRulesPath(cfg),
+			LoadError: fmt.Sprintf("failed to read Lua rules file: %v", err),
+			Functions: []LuaFunctionCheck{},
+		}
+	}
+	return ValidateLuaRules(DefaultLuaRulesPath(cfg), string(data))
+}
+
+// ValidateLuaRules 在全新的 Lua 虛擬機中載入規則，確認必要函數存在且能以測試表格呼叫
+func ValidateLuaRules(source, rulesContent string) *LuaRulesValidation {
+	result := &LuaRulesValidation{
+		Source:    source,
+		Functions: []LuaFunctionCheck{},
+	}
+
+	L := lua.NewState()
+	defer L.Close()
+
+	// 與 Phase 4 相同，規則可能讀取 domain_hints
+	L.SetGlobal("domain_hints", L.NewTable())
+
+	if err := L.DoString(rulesContent); err != nil {
+		result.LoadError = err.Error()
+		return result
+	}
+
+	result.Valid = true
+	for _, name := range requiredLuaRuleFunctions {
+		check := LuaFunctionCheck{Name: name}
+		fn := L.GetGlobal(name)
+		if fn.Type() != lua.LTFunction {
+			check.Error = fmt.Sprintf("%s function not found in Lua script", name)
+			result.Valid = false
+			result.Functions = append(result.Functions, check)
+			continue
+		}
+		check.Found = true
+
+		if err := L.CallByParam(lua.P{
+			Fn:      fn,
+			NRet:    1,
+			Protect: true,
+		}, lua.LString("rules_check_table"), dummyLuaTableMeta(L)); err != nil {
+			check.Error = err.Error()
+			result.Valid = false
+		} else {
+			check.Callable = true
+			L.Pop(1)
+		}
+		result.Functions = append(result.Functions, check)
+	}
+
+	return result
+}
+
+// dummyLuaTableMeta 建立與 createTableMeta 結構相同的測試表格元數據
+func dummyLuaTableMeta(L *lua.LState) *lua.LTable {
+	columns := L.NewTable()
+	for i, col := range []struct {
+		name     string
+		colType  string
+		nullable bool
+	}{
+		{"id", "integer", false},
+		{"name", "varchar", true},
+		{"amount", "numeric", true},
+		{"created_at", "timestamp", true},
+	} {
+		colTable := L.NewTable()
+		colTable.RawSetString("name", lua.LString(col.name))
+		colTable.RawSetString("type", lua.LString(col.colType))
+		colTable.RawSetString("nullable", lua.LBool(col.nullable))
+		columns.RawSetInt(i+1, colTable)
+	}
+
+	meta := L.NewTable()
+	meta.RawSetString("columns", columns)
+	meta.RawSetString("existing_dimensions", L.NewTable())
+	return meta
+}
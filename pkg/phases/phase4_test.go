@@ -0,0 +1,104 @@
+package phases
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// duplicateFactRules 兩個訂單表格都產生 fact_sales，度量與維度部分重疊
+const duplicateFactRules = `
+function detect_dimensions(table_name, table_meta)
+	return {}
+end
+
+function detect_fact_tables(table_name, table_meta)
+	if table_name == "online_orders" then
+		return {{name = "fact_sales", source_table = table_name, measures = {"amount", "quantity"}, dimensions = {"dim_customer", "dim_date"}}}
+	end
+	if table_name == "store_orders" then
+		return {{name = "fact_sales", source_table = table_name, measures = {"amount", "discount"}, dimensions = {"dim_date", "dim_store"}}}
+	end
+	if table_name == "shipments" then
+		return {{name = "fact_shipments", source_table = table_name, measures = {"weight"}, dimensions = {"dim_date"}}}
+	end
+	return {}
+end
+`
+
+func TestExecuteLuaRulesMergesDuplicateFacts(t *testing.T) {
+	runner := &Phase4Runner{config: &config.Config{}}
+	if err := runner.initLuaVM(duplicateFactRules); err != nil {
+		t.Fatal(err)
+	}
+	defer runner.luaState.Close()
+
+	_, facts, err := runner.executeLuaRules(map[string]*LLMAnalysisResult{
+		"online_orders": {TableName: "online_orders"},
+		"store_orders":  {TableName: "store_orders"},
+		"shipments":     {TableName: "shipments"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string]FactTable)
+	for _, fact := range facts {
+		if _, ok := byName[fact.Name]; ok {
+			t.Errorf("fact table %s listed more than once", fact.Name)
+		}
+		byName[fact.Name] = fact
+	}
+	if len(facts) != 2 {
+		t.Fatalf("got %d fact tables, want 2: %+v", len(facts), facts)
+	}
+
+	// 表格的處理順序不固定，以排序後的結果比較
+	sorted := func(values []string) []string {
+		values = append([]string(nil), values...)
+		sort.Strings(values)
+		return values
+	}
+	tests := []struct {
+		name           string
+		wantMeasures   []string
+		wantDimensions []string
+		wantSources    []string
+	}{
+		{"fact_sales", []string{"amount", "discount", "quantity"}, []string{"dim_customer", "dim_date", "dim_store"}, []string{"online_orders", "store_orders"}},
+		{"fact_shipments", []string{"weight"}, []string{"dim_date"}, []string{"shipments"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fact, ok := byName[tt.name]
+			if !ok {
+				t.Fatalf("fact table %s missing", tt.name)
+			}
+			if got := sorted(fact.Measures); !reflect.DeepEqual(got, tt.wantMeasures) {
+				t.Errorf("Measures = %v, want %v", got, tt.wantMeasures)
+			}
+			if got := sorted(fact.Dimensions); !reflect.DeepEqual(got, tt.wantDimensions) {
+				t.Errorf("Dimensions = %v, want %v", got, tt.wantDimensions)
+			}
+			found := false
+			for _, source := range tt.wantSources {
+				found = found || fact.SourceTable == source
+			}
+			if !found {
+				t.Errorf("SourceTable = %q, want one of %v", fact.SourceTable, tt.wantSources)
+			}
+		})
+	}
+}
+
+func TestMergeFactTable(t *testing.T) {
+	existing := FactTable{Name: "fact_sales", SourceTable: "online_orders", Measures: []string{"amount", "quantity"}, Dimensions: []string{"dim_date"}}
+	mergeFactTable(&existing, FactTable{Name: "fact_sales", SourceTable: "store_orders", Measures: []string{"discount", "amount"}, Dimensions: []string{"dim_store", "dim_date"}})
+
+	want := FactTable{Name: "fact_sales", SourceTable: "online_orders", Measures: []string{"amount", "quantity", "discount"}, Dimensions: []string{"dim_date", "dim_store"}}
+	if !reflect.DeepEqual(existing, want) {
+		t.Errorf("mergeFactTable() = %+v, want %+v", existing, want)
+	}
+}
@@ -0,0 +1,365 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/vectorstore"
+)
+
+// Phase5DDLPath Phase 5 產生的星形模式 DDL 位置
+func Phase5DDLPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("phase5_ddl.sql")
+}
+
+// phase4Classifications Phase 4 報告的分類順序，輸出 DDL 時依此排列維度
+var phase4Classifications = []string{"people", "time", "product", "event", "location"}
+
+// phase4Report phase4_dimensions.json 中 Phase 5 需要的部分
+type phase4Report struct {
+	Classifications map[string]struct {
+		Dimensions []Dimension `json:"dimensions"`
+	} `json:"classifications"`
+	FactTables []FactTable `json:"fact_tables"`
+}
+
+// Phase5Runner Phase 5 執行器 - 由 Phase 4 的維度與事實表產生星形模式 DDL
+type Phase5Runner struct {
+	config       *config.Config
+	knowledgeMgr *vectorstore.KnowledgeManager
+	dialect      string
+}
+
+// NewPhase5Runner 創建 Phase 5 執行器
+// dialect 為 postgres 或 mysql，空字串時依 database.type 決定（其他資料庫類型使用 postgres）
+func NewPhase5Runner(cfg *config.Config, knowledgeMgr *vectorstore.KnowledgeManager, dialect string) (*Phase5Runner, error) {
+	if dialect == "" {
+		dialect = cfg.Database.Type
+		if dialect != "mysql" {
+			dialect = "postgres"
+		}
+	}
+
+	switch dialect {
+	case "postgres", "postgresql":
+		dialect = "postgres"
+	case "mysql":
+	default:
+		return nil, fmt.Errorf("unsupported DDL dialect %q (supported: postgres, mysql)", dialect)
+	}
+
+	return &Phase5Runner{
+		config:       cfg,
+		knowledgeMgr: knowledgeMgr,
+		dialect:      dialect,
+	}, nil
+}
+
+// Run 執行 Phase 5：讀取 Phase 4 報告，寫出 knowledge/phase5_ddl.sql 並存入向量知識庫
+func (p *Phase5Runner) Run() (err error) {
+	defer recoverPhasePanic("phase5", &err)
+
+	log.Printf("=== Starting Phase 5: Star Schema DDL Generation (%s) ===", p.dialect)
+
+	data, err := os.ReadFile(p.config.KnowledgePath("phase4_dimensions.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read phase4_dimensions.json (run phase4 first): %v", err)
+	}
+
+	var report phase4Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("failed to parse phase4_dimensions.json: %v", err)
+	}
+
+	// Phase 1 提供來源欄位型別；缺少時欄位退回通用型別
+	phase1Data, err := LoadPhase1Output(p.config.KnowledgePath("phase1_analysis.json"))
+	if err != nil {
+		log.Printf("Warning: Failed to load phase1 output, column types will fall back to defaults: %v", err)
+	}
+
+	var dimensions []Dimension
+	for _, category := range phase4Classifications {
+		dimensions = append(dimensions, report.Classifications[category].Dimensions...)
+	}
+
+	ddl := p.generateStarSchemaDDL(phase1Data, dimensions, report.FactTables)
+	if err := os.WriteFile(Phase5DDLPath(p.config), []byte(ddl), 0644); err != nil {
+		return fmt.Errorf("failed to write star schema DDL: %v", err)
+	}
+	log.Printf("Star schema DDL saved to %s (%d dimensions, %d fact tables)", Phase5DDLPath(p.config), len(dimensions), len(report.FactTables))
+
+	if err := p.storePhase5Results(ddl, dimensions, report.FactTables); err != nil {
+		log.Printf("Warning: Failed to store Phase 5 results in vector store: %v", err)
+	}
+
+	log.Println("Phase 5 completed successfully - star schema DDL generated")
+	return nil
+}
+
+// generateStarSchemaDDL 先輸出所有維度表，再輸出引用維度表的事實表
+func (p *Phase5Runner) generateStarSchemaDDL(phase1Data map[string]interface{}, dimensions []Dimension, factTables []FactTable) string {
+	var b strings.Builder
+	b.WriteString("-- Star schema DDL generated by aika-dba phase5\n")
+	fmt.Fprintf(&b, "-- Database: %s, dialect: %s\n", p.config.Database.DBName, p.dialect)
+	fmt.Fprintf(&b, "-- Generated at: %s\n", time.Now().Format(time.RFC3339))
+
+	// 維度名稱（去除 dim_ 前綴後）-> 維度表名稱與代理鍵欄位
+	dimensionKeys := make(map[string]string)
+	for _, dim := range dimensions {
+		base := starSchemaBaseName(dim.Name, "dim_")
+		if base == "" {
+			log.Printf("Warning: Skipping dimension with empty name from %s", dim.SourceTable)
+			continue
+		}
+		if _, exists := dimensionKeys[base]; exists {
+			continue
+		}
+		dimensionKeys[base] = base + "_key"
+
+		b.WriteString("\n")
+		b.WriteString(p.dimensionDDL(phase1Data, base, dim))
+	}
+
+	for _, fact := range factTables {
+		base := starSchemaBaseName(fact.Name, "fact_")
+		if base == "" {
+			log.Printf("Warning: Skipping fact table with empty name from %s", fact.SourceTable)
+			continue
+		}
+
+		b.WriteString("\n")
+		b.WriteString(p.factTableDDL(phase1Data, base, fact, dimensionKeys))
+	}
+
+	return b.String()
+}
+
+// dimensionDDL 產生維度表：代理鍵、KeyFields 作為自然鍵（UNIQUE）、Attributes 作為一般欄位
+func (p *Phase5Runner) dimensionDDL(phase1Data map[string]interface{}, base string, dim Dimension) string {
+	columns := phase1ColumnsByName(phase1Data, dim.SourceTable)
+	keyColumn := base + "_key"
+
+	lines := []string{fmt.Sprintf("    %s %s", keyColumn, p.surrogateKeyType())}
+	seen := map[string]bool{keyColumn: true}
+
+	var naturalKeys []string
+	for _, field := range dim.KeyFields {
+		name := ddlIdentifier(field)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		naturalKeys = append(naturalKeys, name)
+		lines = append(lines, fmt.Sprintf("    %s %s NOT NULL", name, p.columnType(columns[field])))
+	}
+
+	for _, attr := range dim.Attributes {
+		name := ddlIdentifier(attr)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		lines = append(lines, fmt.Sprintf("    %s %s", name, p.columnType(columns[attr])))
+	}
+
+	if len(naturalKeys) > 0 {
+		lines = append(lines, fmt.Sprintf("    UNIQUE (%s)", strings.Join(naturalKeys, ", ")))
+	}
+
+	header := fmt.Sprintf("-- %s", dim.Name)
+	if dim.SourceTable != "" {
+		header += fmt.Sprintf(" (source: %s)", dim.SourceTable)
+	}
+	return fmt.Sprintf("%s\nCREATE TABLE dim_%s (\n%s\n);\n", header, base, strings.Join(lines, ",\n"))
+}
+
+// factTableDDL 產生事實表：每個已產生的維度一個外鍵欄位，Measures 作為數值欄位
+func (p *Phase5Runner) factTableDDL(phase1Data map[string]interface{}, base string, fact FactTable, dimensionKeys map[string]string) string {
+	columns := phase1ColumnsByName(phase1Data, fact.SourceTable)
+
+	var lines, foreignKeys, missing []string
+	seen := make(map[string]bool)
+	for _, dimName := range fact.Dimensions {
+		keyColumn, ok := dimensionKeys[starSchemaBaseName(dimName, "dim_")]
+		if !ok {
+			missing = append(missing, dimName)
+			continue
+		}
+		if seen[keyColumn] {
+			continue
+		}
+		seen[keyColumn] = true
+		lines = append(lines, fmt.Sprintf("    %s BIGINT NOT NULL", keyColumn))
+		foreignKeys = append(foreignKeys, fmt.Sprintf("    FOREIGN KEY (%s) REFERENCES dim_%s (%s)", keyColumn, strings.TrimSuffix(keyColumn, "_key"), keyColumn))
+	}
+
+	for _, measure := range fact.Measures {
+		name := ddlIdentifier(measure)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		lines = append(lines, fmt.Sprintf("    %s %s", name, p.measureType(columns[measure])))
+	}
+
+	if len(lines) == 0 {
+		log.Printf("Warning: Fact table %s has no dimensions or measures, skipping", fact.Name)
+		return fmt.Sprintf("-- %s skipped: no dimensions or measures\n", fact.Name)
+	}
+	lines = append(lines, foreignKeys...)
+
+	header := fmt.Sprintf("-- %s", fact.Name)
+	if fact.SourceTable != "" {
+		header += fmt.Sprintf(" (source: %s)", fact.SourceTable)
+	}
+	if len(missing) > 0 {
+		log.Printf("Warning: Fact table %s references dimensions not found in phase4 output: %s", fact.Name, strings.Join(missing, ", "))
+		header += fmt.Sprintf("\n-- dimensions not found in phase4 output: %s", strings.Join(missing, ", "))
+	}
+	return fmt.Sprintf("%s\nCREATE TABLE fact_%s (\n%s\n);\n", header, base, strings.Join(lines, ",\n"))
+}
+
+// surrogateKeyType 維度表代理鍵的定義
+func (p *Phase5Runner) surrogateKeyType() string {
+	if p.dialect == "mysql" {
+		return "BIGINT AUTO_INCREMENT PRIMARY KEY"
+	}
+	return "BIGSERIAL PRIMARY KEY"
+}
+
+// measureType 度量欄位型別：來源為數值型別時沿用，否則使用 DECIMAL(18,4)
+func (p *Phase5Runner) measureType(col map[string]interface{}) string {
+	colType := p.columnType(col)
+	switch strings.SplitN(colType, "(", 2)[0] {
+	case "SMALLINT", "INTEGER", "INT", "BIGINT", "NUMERIC", "DECIMAL", "REAL", "FLOAT", "DOUBLE PRECISION", "DOUBLE":
+		return colType
+	}
+	if p.dialect == "mysql" {
+		return "DECIMAL(18,4)"
+	}
+	return "NUMERIC(18,4)"
+}
+
+// columnType 將 Phase 1 的來源欄位型別轉為目標方言的型別，找不到來源欄位時使用文字型別
+func (p *Phase5Runner) columnType(col map[string]interface{}) string {
+	mysql := p.dialect == "mysql"
+	pick := func(postgresType, mysqlType string) string {
+		if mysql {
+			return mysqlType
+		}
+		return postgresType
+	}
+
+	if col == nil {
+		return pick("TEXT", "VARCHAR(255)")
+	}
+
+	// 原生 ENUM：MySQL 保留值列表，PostgreSQL 的列舉型別不一定存在於目標庫，改用 TEXT
+	if values := nativeEnumValues(col); values != nil {
+		if !mysql {
+			return "TEXT"
+		}
+		literals := make([]string, 0, len(values))
+		for _, value := range values {
+			literals = append(literals, ddlLiteral(value))
+		}
+		return fmt.Sprintf("ENUM(%s)", strings.Join(literals, ", "))
+	}
+
+	colType, _ := col["type"].(string)
+	maxLength := toInt64(col["max_length"])
+	precision := toInt64(col["precision"])
+	scale := toInt64(col["scale"])
+
+	switch strings.ToLower(strings.TrimSpace(colType)) {
+	case "smallint", "int2", "tinyint":
+		return "SMALLINT"
+	case "integer", "int", "int4", "mediumint", "serial":
+		return pick("INTEGER", "INT")
+	case "bigint", "int8", "bigserial":
+		return "BIGINT"
+	case "numeric", "decimal":
+		if precision > 0 {
+			return fmt.Sprintf("%s(%d,%d)", pick("NUMERIC", "DECIMAL"), precision, scale)
+		}
+		return pick("NUMERIC", "DECIMAL(18,4)")
+	case "real", "float4", "float":
+		return pick("REAL", "FLOAT")
+	case "double precision", "double", "float8":
+		return pick("DOUBLE PRECISION", "DOUBLE")
+	case "money":
+		return pick("NUMERIC(19,4)", "DECIMAL(19,4)")
+	case "boolean", "bool", "bit":
+		return "BOOLEAN"
+	case "character varying", "varchar":
+		if maxLength > 0 {
+			return fmt.Sprintf("VARCHAR(%d)", maxLength)
+		}
+		return pick("TEXT", "VARCHAR(255)")
+	case "character", "char", "bpchar":
+		if maxLength > 0 {
+			return fmt.Sprintf("CHAR(%d)", maxLength)
+		}
+		return "CHAR(1)"
+	case "text", "tinytext", "mediumtext", "longtext", "citext":
+		return "TEXT"
+	case "date":
+		return "DATE"
+	case "time", "time without time zone", "time with time zone", "timetz":
+		return "TIME"
+	case "timestamp", "timestamp without time zone", "datetime":
+		return pick("TIMESTAMP", "DATETIME")
+	case "timestamp with time zone", "timestamptz":
+		return pick("TIMESTAMPTZ", "DATETIME")
+	case "json":
+		return "JSON"
+	case "jsonb":
+		return pick("JSONB", "JSON")
+	case "uuid":
+		return pick("UUID", "CHAR(36)")
+	case "bytea", "blob", "tinyblob", "mediumblob", "longblob", "binary", "varbinary":
+		return pick("BYTEA", "BLOB")
+	}
+	return pick("TEXT", "VARCHAR(255)")
+}
+
+// starSchemaBaseName 將維度/事實表名稱轉為識別字並去除既有前綴，避免產生 dim_dim_ 之類的名稱
+func starSchemaBaseName(name, prefix string) string {
+	return strings.TrimPrefix(ddlIdentifier(name), prefix)
+}
+
+// phase1ColumnsByName 返回 Phase 1 中來源表格的欄位信息（欄位名稱 -> 欄位）
+func phase1ColumnsByName(phase1Data map[string]interface{}, tableName string) map[string]map[string]interface{} {
+	columns := make(map[string]map[string]interface{})
+	tables, _ := phase1Data["tables"].(map[string]interface{})
+	table, _ := tables[tableName].(map[string]interface{})
+	for _, col := range mapList(table["schema"]) {
+		if name, ok := col["name"].(string); ok {
+			columns[name] = col
+		}
+	}
+	return columns
+}
+
+// storePhase5Results 將 Phase 5 DDL 存儲到向量數據庫
+func (p *Phase5Runner) storePhase5Results(ddl string, dimensions []Dimension, factTables []FactTable) error {
+	if p.knowledgeMgr == nil {
+		return fmt.Errorf("knowledge manager not available")
+	}
+
+	return p.knowledgeMgr.StorePhaseKnowledge("phase5", map[string]interface{}{
+		"phase":             "phase5",
+		"description":       "Star schema DDL generated from Phase 4 dimensions and fact tables",
+		"database":          p.config.Database.DBName,
+		"dialect":           p.dialect,
+		"timestamp":         time.Now(),
+		"dimensions_count":  len(dimensions),
+		"fact_tables_count": len(factTables),
+		"ddl":               ddl,
+	})
+}
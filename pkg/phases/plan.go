@@ -0,0 +1,156 @@
+package phases
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/analyzer"
+	"github.com/masato25/aika-dba/pkg/vectorstore"
+)
+
+// PhasePlan phase 執行前的範圍預覽，只讀取現有輸出與資料庫目錄，不執行分析也不呼叫 LLM
+type PhasePlan struct {
+	Phase      string   `json:"phase"`
+	Source     string   `json:"source"`      // 表格來源，如 database、schema_dump、phase1_output
+	TableCount int      `json:"table_count"` // 本次會處理的表格數
+	Tables     []string `json:"tables"`      // 本次會處理的表格
+	LLMCalls   int      `json:"llm_calls"`   // 預計的 LLM 分析呼叫次數
+	Notes      []string `json:"notes,omitempty"`
+}
+
+// PlannablePhases 支援範圍預覽的 phase
+var PlannablePhases = []string{"phase1", "phase2", "phase4"}
+
+// PlanPhase 返回 phase 的範圍預覽；dbAnalyzer 只在 phase1 從資料庫列出表格時使用
+func PlanPhase(cfg *config.Config, dbAnalyzer *analyzer.DatabaseAnalyzer, km *vectorstore.KnowledgeManager, phase string) (*PhasePlan, error) {
+	switch phase {
+	case "phase1":
+		return planPhase1(cfg, dbAnalyzer)
+	case "phase2":
+		return planPhase2(cfg)
+	case "phase4":
+		return planPhase4(cfg, km)
+	default:
+		return nil, fmt.Errorf("plan is not available for phase %s (supported: %v)", phase, PlannablePhases)
+	}
+}
+
+// planPhase1 列出資料庫（或 schema 匯出檔）中的表格；Phase 1 會分析所有表格
+func planPhase1(cfg *config.Config, dbAnalyzer *analyzer.DatabaseAnalyzer) (*PhasePlan, error) {
+	plan := &PhasePlan{Phase: "phase1", Source: "database"}
+
+	var tables []string
+	var err error
+	if cfg.Schema.DumpFile != "" {
+		plan.Source = "schema_dump"
+		tables, _, err = AnalyzeSchemaDump(cfg.Schema)
+	} else {
+		if dbAnalyzer == nil {
+			return nil, fmt.Errorf("database connection not available")
+		}
+		tables, err = dbAnalyzer.GetAllTables()
+		tables = cfg.Schema.FilterTables(tables)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %v", err)
+	}
+	sort.Strings(tables)
+	plan.Tables = tables
+	plan.TableCount = len(tables)
+
+	// Phase 1 Put 排除的表格在重新執行 Phase 1 後會再次出現，需重新執行 Phase 1 Put
+	if existing, err := LoadPhase1Output(cfg.KnowledgePath("phase1_analysis.json")); err == nil {
+		if excluded := stringList(existing["excluded_tables"]); len(excluded) > 0 {
+			plan.Notes = append(plan.Notes, fmt.Sprintf("%d tables previously excluded by phase1_put will be analyzed again: %v", len(excluded), excluded))
+		}
+	}
+	if cfg.Schema.MergeOutput {
+		plan.Notes = append(plan.Notes, "schema.merge_output is enabled: tables not in this run are kept from the existing output")
+	}
+	if cfg.Schema.DumpFile != "" {
+		plan.Notes = append(plan.Notes, "schema dump mode: no samples or statistics are collected")
+	}
+	return plan, nil
+}
+
+// planPhase2 依 Phase 1 輸出列出會送給 LLM 分析的表格；有人工修正的表格仍會分析，但結果會被修正覆蓋
+func planPhase2(cfg *config.Config) (*PhasePlan, error) {
+	tables, err := NewPhase1ResultReader(cfg.KnowledgePath("phase1_analysis.json")).GetTableNames()
+	if err != nil {
+		return nil, fmt.Errorf("phase1 output is required before phase2: %v", err)
+	}
+	sort.Strings(tables)
+
+	plan := &PhasePlan{
+		Phase:      "phase2",
+		Source:     "phase1_output",
+		Tables:     tables,
+		TableCount: len(tables),
+		LLMCalls:   len(tables),
+	}
+
+	corrections, err := LoadTableCorrections(Phase2CorrectionsPath(cfg))
+	if err != nil {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("failed to load table corrections: %v", err))
+	}
+	var corrected []string
+	for _, tableName := range tables {
+		if _, ok := corrections[tableName]; ok {
+			corrected = append(corrected, tableName)
+		}
+	}
+	if len(corrected) > 0 {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("%d tables have human corrections that will override the LLM analysis: %v", len(corrected), corrected))
+	}
+	return plan, nil
+}
+
+// planPhase4 列出 Lua 規則會評估的表格（Phase 2 分析結果），並檢查規則是否可載入
+func planPhase4(cfg *config.Config, km *vectorstore.KnowledgeManager) (*PhasePlan, error) {
+	runner := &Phase4Runner{config: cfg, knowledgeMgr: km}
+
+	var results map[string]*LLMAnalysisResult
+	var err error
+	if km != nil {
+		results, err = runner.retrievePhase2Knowledge()
+	} else {
+		results, err = runner.retrievePhase2FromJSON()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("phase2 output is required before phase4: %v", err)
+	}
+
+	tables := make([]string, 0, len(results))
+	for tableName := range results {
+		tables = append(tables, tableName)
+	}
+	sort.Strings(tables)
+
+	plan := &PhasePlan{
+		Phase:      "phase4",
+		Source:     "phase2_output",
+		Tables:     tables,
+		TableCount: len(tables),
+	}
+
+	rules := ValidatePhase4Rules(cfg, km)
+	plan.Notes = append(plan.Notes, fmt.Sprintf("Lua rules source: %s", rules.Source))
+	if !rules.Valid {
+		plan.Notes = append(plan.Notes, "Lua rules failed validation; phase4 will fail (see GET /api/rules/validate)")
+	}
+
+	if phase1, err := LoadPhase1Output(cfg.KnowledgePath("phase1_analysis.json")); err == nil {
+		keyless := primaryKeylessTables(phase1)
+		var flagged []string
+		for _, tableName := range tables {
+			if keyless[tableName] {
+				flagged = append(flagged, tableName)
+			}
+		}
+		if len(flagged) > 0 {
+			plan.Notes = append(plan.Notes, fmt.Sprintf("%d tables have no primary key; dimensions based on them will carry a warning: %v", len(flagged), flagged))
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,85 @@
+package phases
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/masato25/aika-dba/pkg/analyzer"
+)
+
+// TablesWithoutPrimaryKey 依約束信息找出沒有主鍵的表格（排序後返回）
+// 約束信息取得失敗（如逾時）的表格無法判斷，不列入
+func TablesWithoutPrimaryKey(tableAnalyses map[string]interface{}) []string {
+	tables := []string{}
+	for tableName, analysis := range tableAnalyses {
+		analysisMap, ok := analysis.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		constraints, ok := analysisMap["constraints"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, known := constraints["primary_keys"]; !known {
+			continue
+		}
+		if len(analyzer.PrimaryKeyColumns(constraints)) == 0 {
+			tables = append(tables, tableName)
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// markTablesWithoutPrimaryKey 在 phase1 輸出中記錄沒有主鍵的表格並輸出警告
+func markTablesWithoutPrimaryKey(output map[string]interface{}) {
+	tableAnalyses, _ := output["tables"].(map[string]interface{})
+	missing := TablesWithoutPrimaryKey(tableAnalyses)
+	output["tables_without_primary_key"] = missing
+	if len(missing) > 0 {
+		log.Printf("Warning: %d tables have no primary key: %v", len(missing), missing)
+	}
+}
+
+// primaryKeylessTables 從 phase1 輸出讀取沒有主鍵的表格集合；舊版輸出沒有此欄位時即時計算
+func primaryKeylessTables(phase1 map[string]interface{}) map[string]bool {
+	var names []string
+	switch list := phase1["tables_without_primary_key"].(type) {
+	case []string:
+		names = list
+	case []interface{}:
+		for _, item := range list {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+	default:
+		tableAnalyses, _ := phase1["tables"].(map[string]interface{})
+		names = TablesWithoutPrimaryKey(tableAnalyses)
+	}
+
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// warnKeylessDimensions 為來源表格沒有主鍵的維度附上警告，返回排序後的無主鍵表格
+func warnKeylessDimensions(dimensions []Dimension, keyless map[string]bool) []string {
+	for i := range dimensions {
+		if !keyless[dimensions[i].SourceTable] {
+			continue
+		}
+		dimensions[i].Warning = fmt.Sprintf("source table %s has no primary key; dimension keys may not be unique", dimensions[i].SourceTable)
+		log.Printf("Warning: Dimension %s is based on table %s without a primary key", dimensions[i].Name, dimensions[i].SourceTable)
+	}
+
+	tables := make([]string, 0, len(keyless))
+	for tableName := range keyless {
+		tables = append(tables, tableName)
+	}
+	sort.Strings(tables)
+	return tables
+}
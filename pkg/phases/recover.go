@@ -0,0 +1,27 @@
+package phases
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// recoverPhasePanic 將階段執行中的 panic 轉換為錯誤，避免格式錯誤的知識檔案讓整個程序崩潰
+// 使用方式：在具名返回 err 的 Run 方法中 defer recoverPhasePanic("phase1", &err)
+func recoverPhasePanic(phase string, err *error) {
+	if r := recover(); r != nil {
+		log.Printf("Error: %s panicked: %v\n%s", phase, r, debug.Stack())
+		*err = fmt.Errorf("%s aborted due to unexpected data: %v", phase, r)
+	}
+}
+
+// runTableSafely 執行單一表格的處理，將 panic 轉換為錯誤，讓其他表格可以繼續處理
+func runTableSafely(tableName string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Warning: Recovered from panic while processing table %s: %v", tableName, r)
+			err = fmt.Errorf("panic while processing table %s: %v", tableName, r)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,289 @@
+package phases
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// ReportPath 整合報告輸出路徑
+func ReportPath(cfg *config.Config) string {
+	return cfg.KnowledgePath("report.html")
+}
+
+// ReportGenerator 整合 phase1–phase4 輸出的 HTML 報告產生器
+type ReportGenerator struct {
+	knowledgeDir string
+}
+
+// NewReportGenerator 創建報告產生器
+func NewReportGenerator(knowledgeDir string) *ReportGenerator {
+	return &ReportGenerator{knowledgeDir: knowledgeDir}
+}
+
+// reportTable 報告中的表格概覽
+type reportTable struct {
+	Name        string
+	RowCount    int
+	ColumnCount int
+	PrimaryKeys []string
+	Description string
+}
+
+// reportDimensionGroup 報告中的維度分類
+type reportDimensionGroup struct {
+	Category    string
+	Description string
+	Dimensions  []Dimension
+}
+
+// reportData 報告模板資料
+type reportData struct {
+	Database         string
+	DatabaseType     string
+	GeneratedAt      string
+	Tables           []reportTable
+	BusinessSummary  string
+	TableCategories  map[string][]string
+	BusinessProcess  []string
+	DataFlowPatterns []string
+	Recommendations  []string
+	DimensionGroups  []reportDimensionGroup
+	FactTables       []FactTable
+	MissingSources   []string
+}
+
+// Generate 讀取各 phase 輸出並產生 HTML 報告內容，缺少的 phase 輸出會在報告中標示
+func (g *ReportGenerator) Generate() ([]byte, error) {
+	data := g.loadReportData()
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render report: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// loadReportData 讀取各 phase 輸出，缺少的檔案記錄在 MissingSources
+func (g *ReportGenerator) loadReportData() reportData {
+	data := reportData{
+		GeneratedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	descriptions := make(map[string]string)
+	if analyses, err := NewPhase2ResultReader(g.path("phase2_analysis.json")).GetAnalysisResults(); err == nil {
+		for tableName, analysis := range analyses {
+			if analysis != nil {
+				descriptions[tableName] = analysis.Analysis
+			}
+		}
+	} else {
+		log.Printf("Warning: Phase 2 output unavailable for report: %v", err)
+		data.MissingSources = append(data.MissingSources, "phase2_analysis.json")
+	}
+
+	if phase1, err := NewPhase1ResultReader(g.path("phase1_analysis.json")).ReadResult(); err == nil {
+		data.Database = phase1.Database
+		data.DatabaseType = phase1.DatabaseType
+		data.Tables = buildReportTables(phase1, descriptions)
+	} else {
+		log.Printf("Warning: Phase 1 output unavailable for report: %v", err)
+		data.MissingSources = append(data.MissingSources, "phase1_analysis.json")
+	}
+
+	var phase3 Phase3AnalysisResult
+	if err := g.readJSON("phase3_analysis.json", &phase3); err == nil {
+		data.BusinessSummary = phase3.BusinessLogicSummary
+		data.TableCategories = phase3.TableCategories
+		data.BusinessProcess = phase3.KeyBusinessProcesses
+		data.DataFlowPatterns = phase3.DataFlowPatterns
+		data.Recommendations = phase3.Recommendations
+	} else {
+		log.Printf("Warning: Phase 3 output unavailable for report: %v", err)
+		data.MissingSources = append(data.MissingSources, "phase3_analysis.json")
+	}
+
+	var phase4 struct {
+		Classifications map[string]struct {
+			Description string      `json:"description"`
+			Dimensions  []Dimension `json:"dimensions"`
+		} `json:"classifications"`
+		FactTables []FactTable `json:"fact_tables"`
+	}
+	if err := g.readJSON("phase4_dimensions.json", &phase4); err == nil {
+		for _, category := range []string{"people", "time", "product", "event", "location"} {
+			group, ok := phase4.Classifications[category]
+			if !ok {
+				continue
+			}
+			data.DimensionGroups = append(data.DimensionGroups, reportDimensionGroup{
+				Category:    category,
+				Description: group.Description,
+				Dimensions:  group.Dimensions,
+			})
+		}
+		data.FactTables = phase4.FactTables
+	} else {
+		log.Printf("Warning: Phase 4 output unavailable for report: %v", err)
+		data.MissingSources = append(data.MissingSources, "phase4_dimensions.json")
+	}
+
+	return data
+}
+
+// WriteReport 產生報告並寫入指定路徑
+func (g *ReportGenerator) WriteReport(outputPath string) ([]byte, error) {
+	content, err := g.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	return content, writeReportFile(outputPath, content)
+}
+
+// writeReportFile 將報告內容寫入檔案，必要時建立目錄
+func writeReportFile(outputPath string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	if err := os.WriteFile(outputPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %v", err)
+	}
+
+	log.Printf("Report written to %s", outputPath)
+	return nil
+}
+
+// path 取得知識目錄下的檔案路徑
+func (g *ReportGenerator) path(name string) string {
+	return filepath.Join(g.knowledgeDir, name)
+}
+
+// readJSON 讀取知識目錄下的 JSON 檔案
+func (g *ReportGenerator) readJSON(name string, target interface{}) error {
+	data, err := os.ReadFile(g.path(name))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// buildReportTables 建立表格概覽，依行數降序排序
+func buildReportTables(phase1 *Phase1Result, descriptions map[string]string) []reportTable {
+	tables := make([]reportTable, 0, len(phase1.Tables))
+	for tableName, analysis := range phase1.Tables {
+		table := reportTable{
+			Name:        tableName,
+			ColumnCount: len(analysis.Schema),
+			Description: descriptions[tableName],
+		}
+		table.RowCount, _ = getRowCount(analysis.Stats)
+
+		if pks, ok := analysis.Constraints["primary_keys"].([]interface{}); ok {
+			for _, pk := range pks {
+				if pkName, ok := pk.(string); ok {
+					table.PrimaryKeys = append(table.PrimaryKeys, pkName)
+				}
+			}
+		}
+
+		tables = append(tables, table)
+	}
+
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].RowCount != tables[j].RowCount {
+			return tables[i].RowCount > tables[j].RowCount
+		}
+		return tables[i].Name < tables[j].Name
+	})
+
+	return tables
+}
+
+// reportTemplate 自包含的 HTML 報告模板（不依賴外部資源）
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"join": func(items []string) string {
+		return strings.Join(items, ", ")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-Hant">
+<head>
+<meta charset="utf-8">
+<title>Aika DBA Report{{if .Database}} - {{.Database}}{{end}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "Noto Sans TC", sans-serif; margin: 2rem; color: #222; }
+h1, h2, h3 { color: #1a4d80; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5rem; }
+th, td { border: 1px solid #ccc; padding: 0.4rem 0.6rem; text-align: left; vertical-align: top; }
+th { background: #eef3f8; }
+.muted { color: #777; }
+.warning { background: #fff4e5; border: 1px solid #f0b429; padding: 0.6rem; margin-bottom: 1rem; }
+pre { white-space: pre-wrap; margin: 0; font-family: inherit; }
+</style>
+</head>
+<body>
+<h1>Aika DBA Report</h1>
+<p class="muted">Database: {{.Database}} ({{.DatabaseType}}) · Generated at {{.GeneratedAt}}</p>
+{{if .MissingSources}}<div class="warning">Missing phase outputs: {{join .MissingSources}}</div>{{end}}
+
+<h2>Tables Overview</h2>
+{{if .Tables}}
+<table>
+<tr><th>Table</th><th>Rows</th><th>Columns</th><th>Primary Keys</th></tr>
+{{range .Tables}}<tr><td><a href="#table-{{.Name}}">{{.Name}}</a></td><td>{{.RowCount}}</td><td>{{.ColumnCount}}</td><td>{{join .PrimaryKeys}}</td></tr>
+{{end}}</table>
+{{else}}<p class="muted">No table data available.</p>{{end}}
+
+<h2>Business Logic Summary</h2>
+{{if .BusinessSummary}}<pre>{{.BusinessSummary}}</pre>{{else}}<p class="muted">No business summary available.</p>{{end}}
+{{if .TableCategories}}
+<h3>Table Categories</h3>
+<table>
+<tr><th>Category</th><th>Tables</th></tr>
+{{range $category, $tables := .TableCategories}}<tr><td>{{$category}}</td><td>{{join $tables}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .BusinessProcess}}<h3>Key Business Processes</h3>
+<ul>{{range .BusinessProcess}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .DataFlowPatterns}}<h3>Data Flow Patterns</h3>
+<ul>{{range .DataFlowPatterns}}<li>{{.}}</li>{{end}}</ul>{{end}}
+
+<h2>Table Descriptions</h2>
+{{range .Tables}}{{if .Description}}
+<h3 id="table-{{.Name}}">{{.Name}}</h3>
+<pre>{{.Description}}</pre>
+{{end}}{{end}}
+
+<h2>Dimension Catalog</h2>
+{{if .DimensionGroups}}{{range .DimensionGroups}}{{if .Dimensions}}
+<h3>{{.Category}} <span class="muted">{{.Description}}</span></h3>
+<table>
+<tr><th>Dimension</th><th>Source Table</th><th>Key Fields</th><th>Attributes</th><th>Business Use</th></tr>
+{{range .Dimensions}}<tr><td>{{.Name}}</td><td>{{.SourceTable}}</td><td>{{join .KeyFields}}</td><td>{{join .Attributes}}</td><td>{{.BusinessUse}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}{{else}}<p class="muted">No dimension data available.</p>{{end}}
+
+<h2>Fact Tables</h2>
+{{if .FactTables}}
+<table>
+<tr><th>Fact Table</th><th>Source Table</th><th>Measures</th><th>Dimensions</th><th>Description</th></tr>
+{{range .FactTables}}<tr><td>{{.Name}}</td><td>{{.SourceTable}}</td><td>{{join .Measures}}</td><td>{{join .Dimensions}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>
+{{else}}<p class="muted">No fact table data available.</p>{{end}}
+
+<h2>Recommendations</h2>
+{{if .Recommendations}}<ul>{{range .Recommendations}}<li>{{.}}</li>{{end}}</ul>{{else}}<p class="muted">No recommendations available.</p>{{end}}
+</body>
+</html>
+`))
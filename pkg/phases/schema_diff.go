@@ -0,0 +1,443 @@
+package phases
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// 漂移變更類型
+const (
+	DriftTableAdded      = "table_added"
+	DriftTableRemoved    = "table_removed"
+	DriftColumnAdded     = "column_added"
+	DriftColumnRemoved   = "column_removed"
+	DriftColumnRetyped   = "column_retyped"
+	DriftColumnNullable  = "column_nullability_changed"
+	DriftRowCountChanged = "row_count_changed"
+)
+
+// SchemaChange 單一 schema 漂移；Breaking 表示可能破壞既有查詢或資料（刪除表格/欄位、型別縮小、改為 NOT NULL）
+type SchemaChange struct {
+	Kind     string `json:"kind"`
+	Table    string `json:"table"`
+	Column   string `json:"column,omitempty"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+	Breaking bool   `json:"breaking"`
+}
+
+// TableDrift 單一表格的欄位變化
+type TableDrift struct {
+	Table              string         `json:"table"`
+	AddedColumns       []string       `json:"added_columns"`
+	RemovedColumns     []string       `json:"removed_columns"`
+	RetypedColumns     []SchemaChange `json:"retyped_columns"`
+	NullabilityChanges []SchemaChange `json:"nullability_changes"`
+}
+
+// RowCountChange 行數變化超過門檻的表格
+type RowCountChange struct {
+	Table    string `json:"table"`
+	FromRows int64  `json:"from_rows"`
+	ToRows   int64  `json:"to_rows"`
+}
+
+// SchemaDriftReport 兩份 Phase 1 表格分析之間的 schema 漂移報告（schema diff 命令與增量刷新共用）
+type SchemaDriftReport struct {
+	From            string           `json:"from"`
+	To              string           `json:"to"`
+	AddedTables     []string         `json:"added_tables"`
+	RemovedTables   []string         `json:"removed_tables"`
+	ChangedTables   []TableDrift     `json:"changed_tables"`
+	RowCountChanges []RowCountChange `json:"row_count_changes"`
+	Breaking        []SchemaChange   `json:"breaking_changes"`
+	Additive        []SchemaChange   `json:"additive_changes"`
+}
+
+// HasBreakingChanges 是否有刪除表格、刪除欄位或型別縮小等破壞性變更
+func (r *SchemaDriftReport) HasBreakingChanges() bool {
+	return len(r.Breaking) > 0
+}
+
+// HasChanges 是否有任何 schema 或行數變化
+func (r *SchemaDriftReport) HasChanges() bool {
+	return len(r.Breaking) > 0 || len(r.Additive) > 0
+}
+
+// HasSchemaChanges 是否有任何表格新增、刪除或欄位變更（不含只有行數變化的表格）
+func (r *SchemaDriftReport) HasSchemaChanges() bool {
+	return len(r.AddedTables) > 0 || len(r.RemovedTables) > 0 || len(r.ChangedTables) > 0
+}
+
+// SchemaChangedTables 返回新增與欄位變更的表格
+func (r *SchemaDriftReport) SchemaChangedTables() []string {
+	tables := append([]string{}, r.AddedTables...)
+	for _, drift := range r.ChangedTables {
+		tables = append(tables, drift.Table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// TablesToReanalyze 返回 Phase 1 需要重新分析的表格（新增、欄位變更與行數變化）
+func (r *SchemaDriftReport) TablesToReanalyze() []string {
+	tables := r.SchemaChangedTables()
+	for _, change := range r.RowCountChanges {
+		tables = append(tables, change.Table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// SchemaDiff 讀取兩份 phase1_analysis.json 並比較 schema 漂移
+// 行數相對變化超過 schema.incremental_row_change_ratio（預設 0.1，負數不比較）的表格列為行數變化
+func SchemaDiff(cfg *config.Config, fromPath, toPath string) (*SchemaDriftReport, error) {
+	fromTables, err := loadPhase1Tables(fromPath)
+	if err != nil {
+		return nil, err
+	}
+	toTables, err := loadPhase1Tables(toPath)
+	if err != nil {
+		return nil, err
+	}
+
+	report := DiffPhase1Tables(fromTables, toTables, incrementalRowChangeRatio(cfg))
+	report.From = fromPath
+	report.To = toPath
+	return report, nil
+}
+
+// loadPhase1Tables 讀取 Phase 1 輸出中的 tables 區塊
+func loadPhase1Tables(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var output map[string]interface{}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	tables, ok := output["tables"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s has no tables section, not a Phase 1 analysis", path)
+	}
+	return tables, nil
+}
+
+// DiffPhase1Tables 比較兩份 Phase 1 表格分析：新增/刪除的表格、新增/刪除/改型別的欄位與顯著行數變化
+func DiffPhase1Tables(fromTables, toTables map[string]interface{}, rowChangeRatio float64) *SchemaDriftReport {
+	report := &SchemaDriftReport{
+		AddedTables:     []string{},
+		RemovedTables:   []string{},
+		ChangedTables:   []TableDrift{},
+		RowCountChanges: []RowCountChange{},
+		Breaking:        []SchemaChange{},
+		Additive:        []SchemaChange{},
+	}
+
+	for _, tableName := range sortedKeys(toTables) {
+		if _, ok := fromTables[tableName]; !ok {
+			report.AddedTables = append(report.AddedTables, tableName)
+			report.add(SchemaChange{Kind: DriftTableAdded, Table: tableName})
+		}
+	}
+
+	for _, tableName := range sortedKeys(fromTables) {
+		fromTable, _ := fromTables[tableName].(map[string]interface{})
+		toTable, ok := toTables[tableName].(map[string]interface{})
+		if !ok {
+			report.RemovedTables = append(report.RemovedTables, tableName)
+			report.add(SchemaChange{Kind: DriftTableRemoved, Table: tableName, Breaking: true})
+			continue
+		}
+
+		if drift, changed := report.diffColumns(tableName, fromTable, toTable); changed {
+			report.ChangedTables = append(report.ChangedTables, drift)
+		}
+
+		if rowChangeRatio < 0 {
+			continue
+		}
+		fromRows, fromOK := previousRowCount(fromTable)
+		toRows, toOK := previousRowCount(toTable)
+		if fromOK && toOK && rowCountChanged(fromRows, toRows, rowChangeRatio) {
+			report.addRowCountChange(tableName, fromRows, toRows)
+		}
+	}
+
+	return report
+}
+
+// addRowCountChange 記錄行數變化超過門檻的表格
+func (r *SchemaDriftReport) addRowCountChange(tableName string, fromRows, toRows int64) {
+	r.RowCountChanges = append(r.RowCountChanges, RowCountChange{Table: tableName, FromRows: fromRows, ToRows: toRows})
+	r.add(SchemaChange{
+		Kind:  DriftRowCountChanged,
+		Table: tableName,
+		From:  strconv.FormatInt(fromRows, 10),
+		To:    strconv.FormatInt(toRows, 10),
+	})
+}
+
+// diffColumns 比較同一表格前後的欄位，返回表格變化與是否有任何欄位變更
+func (r *SchemaDriftReport) diffColumns(tableName string, fromTable, toTable map[string]interface{}) (TableDrift, bool) {
+	drift := TableDrift{
+		Table:              tableName,
+		AddedColumns:       []string{},
+		RemovedColumns:     []string{},
+		RetypedColumns:     []SchemaChange{},
+		NullabilityChanges: []SchemaChange{},
+	}
+
+	fromColumns := columnsByName(previousSchemaColumns(fromTable))
+	toColumns := columnsByName(previousSchemaColumns(toTable))
+
+	for _, name := range sortedColumnNames(toColumns) {
+		if _, ok := fromColumns[name]; !ok {
+			drift.AddedColumns = append(drift.AddedColumns, name)
+			r.add(SchemaChange{Kind: DriftColumnAdded, Table: tableName, Column: name, To: columnTypeLabel(toColumns[name])})
+		}
+	}
+
+	for _, name := range sortedColumnNames(fromColumns) {
+		fromCol := fromColumns[name]
+		toCol, ok := toColumns[name]
+		if !ok {
+			drift.RemovedColumns = append(drift.RemovedColumns, name)
+			r.add(SchemaChange{Kind: DriftColumnRemoved, Table: tableName, Column: name, From: columnTypeLabel(fromCol), Breaking: true})
+			continue
+		}
+
+		if change, changed := nullabilityChange(tableName, name, fromCol, toCol); changed {
+			drift.NullabilityChanges = append(drift.NullabilityChanges, change)
+			r.add(change)
+		}
+
+		fromType, toType := columnTypeLabel(fromCol), columnTypeLabel(toCol)
+		if strings.EqualFold(fromType, toType) {
+			continue
+		}
+		change := SchemaChange{
+			Kind:     DriftColumnRetyped,
+			Table:    tableName,
+			Column:   name,
+			From:     fromType,
+			To:       toType,
+			Breaking: typeNarrowed(fromCol, toCol),
+		}
+		drift.RetypedColumns = append(drift.RetypedColumns, change)
+		r.add(change)
+	}
+
+	changed := len(drift.AddedColumns) > 0 || len(drift.RemovedColumns) > 0 || len(drift.RetypedColumns) > 0 || len(drift.NullabilityChanges) > 0
+	return drift, changed
+}
+
+// nullabilityChange 比較欄位前後的 nullable；改為 NOT NULL 可能拒絕既有的寫入，視為破壞性變更
+// 任一方沒有記錄 nullable 時不比較
+func nullabilityChange(tableName, column string, fromCol, toCol map[string]interface{}) (SchemaChange, bool) {
+	fromNullable, fromOK := fromCol["nullable"].(bool)
+	toNullable, toOK := toCol["nullable"].(bool)
+	if !fromOK || !toOK || fromNullable == toNullable {
+		return SchemaChange{}, false
+	}
+	label := func(nullable bool) string {
+		if nullable {
+			return "NULL"
+		}
+		return "NOT NULL"
+	}
+	return SchemaChange{
+		Kind:     DriftColumnNullable,
+		Table:    tableName,
+		Column:   column,
+		From:     label(fromNullable),
+		To:       label(toNullable),
+		Breaking: !toNullable,
+	}, true
+}
+
+// add 依 Breaking 將變更歸入破壞性或新增性變更
+func (r *SchemaDriftReport) add(change SchemaChange) {
+	if change.Breaking {
+		r.Breaking = append(r.Breaking, change)
+	} else {
+		r.Additive = append(r.Additive, change)
+	}
+}
+
+// incrementalRowChangeRatio 行數變化門檻（schema.incremental_row_change_ratio），未設定時為 0.1，負數表示不比較行數
+func incrementalRowChangeRatio(cfg *config.Config) float64 {
+	if cfg.Schema.IncrementalRowChangeRatio == 0 {
+		return 0.1
+	}
+	return cfg.Schema.IncrementalRowChangeRatio
+}
+
+// columnsByName 以欄位名稱索引欄位列表
+func columnsByName(columns []map[string]interface{}) map[string]map[string]interface{} {
+	byName := make(map[string]map[string]interface{}, len(columns))
+	for _, col := range columns {
+		if name, ok := col["name"].(string); ok {
+			byName[name] = col
+		}
+	}
+	return byName
+}
+
+// sortedColumnNames 返回排序後的欄位名稱
+func sortedColumnNames(columns map[string]map[string]interface{}) []string {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedKeys 返回排序後的表格名稱
+func sortedKeys(tables map[string]interface{}) []string {
+	keys := make([]string, 0, len(tables))
+	for key := range tables {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// typeSizePattern 型別字串中的長度/精度，如 varchar(50)、decimal(10,2)
+var typeSizePattern = regexp.MustCompile(`^\s*([^(]+?)\s*\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)`)
+
+// columnType 欄位的基本型別與長度/精度/小數位（0 表示未限制或未知）
+type columnType struct {
+	base      string
+	size      int64
+	precision int64
+	scale     int64
+}
+
+// parseColumnType 從 Phase 1 欄位取出型別；長度優先使用 max_length / precision / scale 欄位，否則解析型別字串
+func parseColumnType(col map[string]interface{}) columnType {
+	raw := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", col["type"])))
+	t := columnType{base: raw}
+	if m := typeSizePattern.FindStringSubmatch(raw); m != nil {
+		t.base = m[1]
+		t.size, _ = strconv.ParseInt(m[2], 10, 64)
+		t.precision = t.size
+		t.scale, _ = strconv.ParseInt(m[3], 10, 64)
+	}
+	if v := numericField(col["max_length"]); v > 0 {
+		t.size = v
+	}
+	if v := numericField(col["precision"]); v > 0 {
+		t.precision = v
+	}
+	if v := numericField(col["scale"]); v > 0 {
+		t.scale = v
+	}
+	return t
+}
+
+// numericField 將 JSON 解碼後的數值欄位轉為 int64
+func numericField(value interface{}) int64 {
+	switch v := value.(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	}
+	return 0
+}
+
+// columnTypeLabel 顯示用的型別（含長度或精度）
+func columnTypeLabel(col map[string]interface{}) string {
+	t := parseColumnType(col)
+	switch typeFamily(t.base) {
+	case "string":
+		if t.size > 0 {
+			return fmt.Sprintf("%s(%d)", t.base, t.size)
+		}
+	case "decimal":
+		if t.precision > 0 {
+			return fmt.Sprintf("%s(%d,%d)", t.base, t.precision, t.scale)
+		}
+	}
+	return t.base
+}
+
+// typeRanks 同一型別族群內的寬度順序，數字越大可容納的值越多
+var typeRanks = map[string]int{
+	"tinyint": 1, "smallint": 2, "int2": 2, "smallserial": 2, "mediumint": 3,
+	"integer": 4, "int": 4, "int4": 4, "serial": 4, "bigint": 5, "int8": 5, "bigserial": 5,
+	"real": 1, "float4": 1, "float": 1, "double precision": 2, "double": 2, "float8": 2,
+	"date": 1, "timestamp without time zone": 2, "timestamp": 2, "datetime": 2, "timestamp with time zone": 3, "timestamptz": 3,
+}
+
+// integerTypes 整數族群的型別名稱
+var integerTypes = map[string]bool{
+	"tinyint": true, "smallint": true, "int2": true, "mediumint": true, "integer": true, "int": true, "int4": true,
+	"bigint": true, "int8": true, "smallserial": true, "serial": true, "bigserial": true,
+}
+
+// typeFamily 返回型別所屬的族群，未知型別以自身為族群
+func typeFamily(base string) string {
+	switch {
+	case integerTypes[base]:
+		return "integer"
+	case base == "real" || strings.HasPrefix(base, "float") || strings.HasPrefix(base, "double"):
+		return "float"
+	case base == "numeric" || base == "decimal":
+		return "decimal"
+	case strings.Contains(base, "char") || strings.Contains(base, "text") || base == "clob":
+		return "string"
+	case base == "date" || strings.HasPrefix(base, "timestamp") || base == "datetime":
+		return "datetime"
+	}
+	return base
+}
+
+// typeNarrowed 判斷型別變更是否可能截斷或拒絕既有資料：同族群變窄、長度/精度變小，或改為不相容的族群
+// 任何型別改為無長度限制的字串（text）視為放寬
+func typeNarrowed(fromCol, toCol map[string]interface{}) bool {
+	from, to := parseColumnType(fromCol), parseColumnType(toCol)
+	fromFamily, toFamily := typeFamily(from.base), typeFamily(to.base)
+
+	if toFamily == "string" && to.size == 0 && !strings.Contains(to.base, "char") {
+		return false
+	}
+
+	if fromFamily != toFamily {
+		// 整數改為浮點或 decimal 可容納原有的值
+		if fromFamily == "integer" && (toFamily == "float" || toFamily == "decimal") {
+			return false
+		}
+		return true
+	}
+
+	switch fromFamily {
+	case "string":
+		// 0 表示無長度限制
+		if to.size == 0 {
+			return false
+		}
+		return from.size == 0 || to.size < from.size
+	case "decimal":
+		if to.precision == 0 {
+			return false
+		}
+		return from.precision == 0 || to.precision < from.precision || to.scale < from.scale
+	default:
+		return typeRanks[to.base] < typeRanks[from.base]
+	}
+}
@@ -0,0 +1,172 @@
+package phases
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// writePhase1Analysis 將合成的 Phase 1 分析寫入暫存目錄
+func writePhase1Analysis(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSchemaDiff(t *testing.T) {
+	before := writePhase1Analysis(t, "before.json", `{"tables": {
+		"customers": {
+			"schema": [
+				{"name": "id", "type": "integer"},
+				{"name": "name", "type": "varchar(100)"},
+				{"name": "fax", "type": "varchar(20)"}
+			],
+			"stats": {"row_count": 1000}
+		},
+		"orders": {
+			"schema": [
+				{"name": "id", "type": "integer"},
+				{"name": "amount", "type": "decimal(10,2)"},
+				{"name": "quantity", "type": "integer", "nullable": true}
+			],
+			"stats": {"row_count": 500}
+		},
+		"legacy_logs": {
+			"schema": [{"name": "id", "type": "integer"}],
+			"stats": {"row_count": 10}
+		}
+	}}`)
+	after := writePhase1Analysis(t, "after.json", `{"tables": {
+		"customers": {
+			"schema": [
+				{"name": "id", "type": "integer"},
+				{"name": "name", "type": "varchar(50)"},
+				{"name": "email", "type": "varchar(255)"}
+			],
+			"stats": {"row_count": 1050}
+		},
+		"orders": {
+			"schema": [
+				{"name": "id", "type": "bigint"},
+				{"name": "amount", "type": "decimal(10,2)"},
+				{"name": "quantity", "type": "integer", "nullable": false}
+			],
+			"stats": {"row_count": 800}
+		},
+		"payments": {
+			"schema": [{"name": "id", "type": "integer"}],
+			"stats": {"row_count": 20}
+		}
+	}}`)
+
+	report, err := SchemaDiff(&config.Config{}, before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.From != before || report.To != after {
+		t.Errorf("report paths = %q -> %q, want %q -> %q", report.From, report.To, before, after)
+	}
+	if want := []string{"payments"}; !reflect.DeepEqual(report.AddedTables, want) {
+		t.Errorf("AddedTables = %v, want %v", report.AddedTables, want)
+	}
+	if want := []string{"legacy_logs"}; !reflect.DeepEqual(report.RemovedTables, want) {
+		t.Errorf("RemovedTables = %v, want %v", report.RemovedTables, want)
+	}
+
+	wantChanged := []TableDrift{
+		{
+			Table:          "customers",
+			AddedColumns:   []string{"email"},
+			RemovedColumns: []string{"fax"},
+			RetypedColumns: []SchemaChange{
+				{Kind: DriftColumnRetyped, Table: "customers", Column: "name", From: "varchar(100)", To: "varchar(50)", Breaking: true},
+			},
+			NullabilityChanges: []SchemaChange{},
+		},
+		{
+			Table:          "orders",
+			AddedColumns:   []string{},
+			RemovedColumns: []string{},
+			RetypedColumns: []SchemaChange{
+				{Kind: DriftColumnRetyped, Table: "orders", Column: "id", From: "integer", To: "bigint"},
+			},
+			NullabilityChanges: []SchemaChange{
+				{Kind: DriftColumnNullable, Table: "orders", Column: "quantity", From: "NULL", To: "NOT NULL", Breaking: true},
+			},
+		},
+	}
+	if !reflect.DeepEqual(report.ChangedTables, wantChanged) {
+		t.Errorf("ChangedTables = %+v, want %+v", report.ChangedTables, wantChanged)
+	}
+
+	// customers 只增加 5%，未超過預設 10% 門檻
+	wantRows := []RowCountChange{{Table: "orders", FromRows: 500, ToRows: 800}}
+	if !reflect.DeepEqual(report.RowCountChanges, wantRows) {
+		t.Errorf("RowCountChanges = %+v, want %+v", report.RowCountChanges, wantRows)
+	}
+
+	kinds := func(changes []SchemaChange) []string {
+		var got []string
+		for _, change := range changes {
+			got = append(got, change.Kind+" "+change.Table+"."+change.Column)
+		}
+		return got
+	}
+	wantBreaking := []string{
+		DriftColumnRemoved + " customers.fax",
+		DriftColumnRetyped + " customers.name",
+		DriftTableRemoved + " legacy_logs.",
+		DriftColumnNullable + " orders.quantity",
+	}
+	if got := kinds(report.Breaking); !reflect.DeepEqual(got, wantBreaking) {
+		t.Errorf("Breaking = %v, want %v", got, wantBreaking)
+	}
+	wantAdditive := []string{
+		DriftTableAdded + " payments.",
+		DriftColumnAdded + " customers.email",
+		DriftColumnRetyped + " orders.id",
+		DriftRowCountChanged + " orders.",
+	}
+	if got := kinds(report.Additive); !reflect.DeepEqual(got, wantAdditive) {
+		t.Errorf("Additive = %v, want %v", got, wantAdditive)
+	}
+	if !report.HasBreakingChanges() {
+		t.Error("HasBreakingChanges() = false, want true")
+	}
+}
+
+func TestSchemaDiffNoChanges(t *testing.T) {
+	analysis := `{"tables": {"orders": {"schema": [{"name": "id", "type": "integer"}], "stats": {"row_count": 100}}}}`
+	report, err := SchemaDiff(&config.Config{}, writePhase1Analysis(t, "before.json", analysis), writePhase1Analysis(t, "after.json", analysis))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.HasChanges() {
+		t.Errorf("identical analyses reported changes: %+v", report)
+	}
+}
+
+func TestSchemaDiffRejectsNonPhase1File(t *testing.T) {
+	valid := writePhase1Analysis(t, "phase1.json", `{"tables": {}}`)
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"no tables section", `{"summary": "phase2"}`},
+		{"invalid json", `{"tables":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SchemaDiff(&config.Config{}, valid, writePhase1Analysis(t, "other.json", tt.content)); err == nil {
+				t.Error("SchemaDiff() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package phases
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// previousSchemaColumns 從 Phase 1 表格分析（JSON 解碼後）取出欄位列表
+func previousSchemaColumns(tableAnalysis map[string]interface{}) []map[string]interface{} {
+	switch schema := tableAnalysis["schema"].(type) {
+	case []map[string]interface{}:
+		return schema
+	case []interface{}:
+		columns := make([]map[string]interface{}, 0, len(schema))
+		for _, col := range schema {
+			if colMap, ok := col.(map[string]interface{}); ok {
+				columns = append(columns, colMap)
+			}
+		}
+		return columns
+	}
+	return nil
+}
+
+// previousRowCount 從 Phase 1 表格分析的 stats 取出行數，沒有記錄時返回 false
+func previousRowCount(tableAnalysis map[string]interface{}) (int64, bool) {
+	stats, _ := tableAnalysis["stats"].(map[string]interface{})
+	switch v := stats["row_count"].(type) {
+	case float64:
+		return int64(v), v >= 0
+	case int64:
+		return v, v >= 0
+	case int:
+		return int64(v), v >= 0
+	}
+	return 0, false
+}
+
+// rowCountChanged 行數相對變化是否超過 ratio（以上次行數為基準，上次為 0 時任何新增都算變化）
+func rowCountChanged(previous, current int64, ratio float64) bool {
+	delta := current - previous
+	if delta < 0 {
+		delta = -delta
+	}
+	if previous == 0 {
+		return delta > 0
+	}
+	return float64(delta)/float64(previous) > ratio
+}
+
+// rowChangeRatio 行數變化門檻，負數表示不比較行數
+func (p *Phase1Runner) rowChangeRatio() float64 {
+	return incrementalRowChangeRatio(p.config)
+}
+
+// diffRowCounts 對欄位結構未變的表格比較目前行數與上次分析的行數，變化超過門檻的加入報告
+func (p *Phase1Runner) diffRowCounts(report *SchemaDriftReport, previousTables map[string]interface{}, currentTables []string) {
+	ratio := p.rowChangeRatio()
+	if ratio < 0 {
+		return
+	}
+
+	schemaChanged := make(map[string]bool)
+	for _, tableName := range report.SchemaChangedTables() {
+		schemaChanged[tableName] = true
+	}
+
+	for _, tableName := range currentTables {
+		previous, ok := previousTables[tableName].(map[string]interface{})
+		if !ok || schemaChanged[tableName] {
+			continue
+		}
+		previousRows, ok := previousRowCount(previous)
+		if !ok {
+			continue
+		}
+
+		currentRows, err := p.countRows(tableName)
+		if err != nil {
+			log.Printf("Warning: Failed to count rows of table %s, keeping previous analysis: %v", tableName, err)
+			continue
+		}
+
+		if rowCountChanged(previousRows, currentRows, ratio) {
+			log.Printf("Table %s row count changed from %d to %d", tableName, previousRows, currentRows)
+			report.addRowCountChange(tableName, previousRows, currentRows)
+		}
+	}
+}
+
+// countRows 在單一表格逾時限制內取得目前行數
+func (p *Phase1Runner) countRows(tableName string) (int64, error) {
+	ctx := context.Background()
+	if p.config.Schema.TableTimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(p.config.Schema.TableTimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	return p.analyzer.RowCountContext(ctx, tableName)
+}
+
+// RunIncremental 只重新分析 schema 或行數有變化的表格並更新 phase1_analysis.json，返回偵測到的漂移
+// 欄位比較與 schema diff 命令相同（DiffPhase1Tables），行數只對欄位未變的表格查詢
+// 未變化的表格保留原有的樣本、統計與 analyzed_at；沒有先前的輸出時執行完整分析，並將所有表格視為新增
+func (p *Phase1Runner) RunIncremental() (report *SchemaDriftReport, err error) {
+	defer recoverPhasePanic("phase1", &err)
+
+	if p.config.Schema.DumpFile != "" {
+		return nil, fmt.Errorf("incremental refresh is not supported in schema dump mode")
+	}
+
+	existing, loadErr := LoadPhase1Output(p.config.KnowledgePath("phase1_analysis.json"))
+	if loadErr != nil {
+		log.Printf("No usable phase1 output for incremental refresh, running full analysis: %v", loadErr)
+		if err := p.Run(); err != nil {
+			return nil, err
+		}
+		existing, err = LoadPhase1Output(p.config.KnowledgePath("phase1_analysis.json"))
+		if err != nil {
+			return nil, err
+		}
+		tables, _ := existing["tables"].(map[string]interface{})
+		return DiffPhase1Tables(map[string]interface{}{}, tables, -1), nil
+	}
+
+	tables, err := p.analyzer.GetAllTables()
+	if err != nil {
+		return nil, err
+	}
+	tables = p.config.Schema.FilterTables(tables)
+
+	// 目前的欄位結構以 Phase 1 表格分析的格式比較；行數另外查詢，這裡不比較
+	currentTables := make(map[string]interface{}, len(tables))
+	for _, tableName := range tables {
+		schema, err := p.analyzer.GetTableSchemaContext(context.Background(), tableName)
+		if err != nil {
+			log.Printf("Warning: Failed to read schema of table %s: %v", tableName, err)
+			continue
+		}
+		currentTables[tableName] = map[string]interface{}{"schema": schema}
+	}
+
+	previousTables, _ := existing["tables"].(map[string]interface{})
+	report = DiffPhase1Tables(previousTables, currentTables, -1)
+	p.diffRowCounts(report, previousTables, tables)
+	if !report.HasChanges() {
+		log.Println("Schema refresh: no schema or row count changes detected")
+		return report, nil
+	}
+	log.Printf("Schema refresh: %d added, %d removed, %d changed, %d row count changed tables",
+		len(report.AddedTables), len(report.RemovedTables), len(report.ChangedTables), len(report.RowCountChanges))
+
+	tableAnalyses := make(map[string]interface{})
+	timedOutTables := []map[string]interface{}{}
+	for _, tableName := range report.TablesToReanalyze() {
+		analysis, err := AnalyzeTableWithTimeout(p.analyzer, tableName, SamplingPolicyFromConfig(p.config.Schema), p.config.Schema.TableTimeoutSeconds)
+		if timedOut := TimedOutTableEntry(tableName, analysis, err); timedOut != nil {
+			timedOutTables = append(timedOutTables, timedOut)
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to analyze table %s: %v", tableName, err)
+			continue
+		}
+		tableAnalyses[tableName] = analysis
+	}
+
+	output := MergeTableAnalyses(existing, map[string]interface{}{
+		"schema_version":   Phase1SchemaVersion,
+		"tables":           tableAnalyses,
+		"timed_out_tables": timedOutTables,
+	})
+	mergedTables := output["tables"].(map[string]interface{})
+	for _, tableName := range report.RemovedTables {
+		delete(mergedTables, tableName)
+	}
+	output["tables_count"] = len(mergedTables)
+	output["schema_language"] = DetectSchemaLanguage(mergedTables)
+	markTablesWithoutPrimaryKey(output)
+
+	return report, p.persistOutput(output)
+}
@@ -8,19 +8,14 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/llm"
 	"github.com/masato25/aika-dba/pkg/vectorstore"
 )
 
-// LLMAnalysisResult LLM 分析結果
-type LLMAnalysisResult struct {
-	TableName string    `json:"table_name"`
-	Analysis  string    `json:"analysis"`
-	Timestamp time.Time `json:"timestamp"`
-}
-
 // TableAnalysisTask 表格分析任務
 type TableAnalysisTask struct {
 	TableName string
@@ -31,7 +26,9 @@ type TableAnalysisTask struct {
 }
 
 // TableAnalysisOrchestrator 表格分析協調器
+// 任務狀態與結果由 mu 保護，可由多個 worker 同時分析表格（llm.concurrency）
 type TableAnalysisOrchestrator struct {
+	mu           sync.Mutex
 	config       *config.Config
 	reader       *Phase1ResultReader
 	tasks        []*TableAnalysisTask
@@ -40,6 +37,10 @@ type TableAnalysisOrchestrator struct {
 	currentTask  *TableAnalysisTask
 	results      map[string]*LLMAnalysisResult
 	knowledgeMgr *vectorstore.KnowledgeManager
+	language     string // 分析提示語言
+	// checkpointPath 非空時每完成一個表格就寫入檢查點；resume 為 true 時 InitializeTasks 從檢查點恢復已完成的表格
+	checkpointPath string
+	resume         bool
 }
 
 // NewTableAnalysisOrchestrator 創建表格分析協調器
@@ -52,6 +53,7 @@ func NewTableAnalysisOrchestrator(cfg *config.Config, reader *Phase1ResultReader
 		mcpServer:    mcpServer,
 		results:      make(map[string]*LLMAnalysisResult),
 		knowledgeMgr: knowledgeMgr,
+		language:     ResolvePromptLanguage(cfg, ""),
 	}
 }
 
@@ -78,8 +80,28 @@ func (o *TableAnalysisOrchestrator) InitializeTasks() error {
 		tableNames = o.extractTableNamesFromKnowledge(results)
 	}
 
+	// 依 Phase 1 偵測到的 schema 語言選用提示語言
+	detected := ""
+	if result, err := o.reader.ReadResult(); err == nil {
+		detected = result.SchemaLanguage
+	}
+	o.language = ResolvePromptLanguage(o.config, detected)
+	log.Printf("Using %s analysis prompts (detected schema language: %q)", o.language, detected)
+
+	// Phase 1 輸出可能早於表格過濾設定，這裡再套用一次
+	tableNames = o.config.Schema.FilterTables(tableNames)
+
 	log.Printf("Initializing analysis tasks for %d tables", len(tableNames))
 	o.initializeTasksFromNames(tableNames)
+
+	if o.resume && o.checkpointPath != "" {
+		resumed, err := o.resumeFromCheckpoint()
+		if err != nil {
+			log.Printf("Warning: Failed to resume from phase2 checkpoint, analyzing all tables: %v", err)
+		} else {
+			log.Printf("Resumed %d completed tables from %s", resumed, o.checkpointPath)
+		}
+	}
 	return nil
 }
 
@@ -177,6 +199,13 @@ func (o *TableAnalysisOrchestrator) isValidTableName(name string) bool {
 
 // GetNextTask 獲取下一個待處理的任務
 func (o *TableAnalysisOrchestrator) GetNextTask() *TableAnalysisTask {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.nextPendingTask()
+}
+
+// nextPendingTask 返回第一個待處理的任務，呼叫端須持有 mu
+func (o *TableAnalysisOrchestrator) nextPendingTask() *TableAnalysisTask {
 	for _, task := range o.tasks {
 		if task.Status == "pending" {
 			return task
@@ -185,8 +214,27 @@ func (o *TableAnalysisOrchestrator) GetNextTask() *TableAnalysisTask {
 	return nil
 }
 
+// ClaimNextTask 取得下一個待處理的任務並標記為處理中，避免多個 worker 取得同一任務；沒有任務時返回 nil
+func (o *TableAnalysisOrchestrator) ClaimNextTask() *TableAnalysisTask {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	task := o.nextPendingTask()
+	if task != nil {
+		o.startTask(task)
+	}
+	return task
+}
+
 // StartTask 開始處理任務
 func (o *TableAnalysisOrchestrator) StartTask(task *TableAnalysisTask) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.startTask(task)
+}
+
+// startTask 將任務標記為處理中，呼叫端須持有 mu
+func (o *TableAnalysisOrchestrator) startTask(task *TableAnalysisTask) {
 	task.Status = "in_progress"
 	o.currentTask = task
 	log.Printf("Starting analysis for table: %s", task.TableName)
@@ -194,15 +242,22 @@ func (o *TableAnalysisOrchestrator) StartTask(task *TableAnalysisTask) {
 
 // CompleteTask 完成任務
 func (o *TableAnalysisOrchestrator) CompleteTask(task *TableAnalysisTask, result *LLMAnalysisResult) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
 	task.Status = "completed"
 	task.Result = result
 	o.results[task.TableName] = result
 	o.currentTask = nil
+	o.saveCheckpoint()
 	log.Printf("Completed analysis for table: %s", task.TableName)
 }
 
 // FailTask 任務失敗
 func (o *TableAnalysisOrchestrator) FailTask(task *TableAnalysisTask, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
 	task.Status = "failed"
 	task.Error = err
 	o.currentTask = nil
@@ -239,6 +294,7 @@ func (o *TableAnalysisOrchestrator) AnalyzeTable(ctx context.Context, task *Tabl
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze table with LLM: %v", err)
 	}
+	o.saveRawAnalysis(task.TableName, summary, llmResponse)
 
 	// 解析 LLM 回應
 	result := &LLMAnalysisResult{
@@ -246,6 +302,9 @@ func (o *TableAnalysisOrchestrator) AnalyzeTable(ctx context.Context, task *Tabl
 		Analysis:  llmResponse.Analysis,
 		Timestamp: time.Now(),
 	}
+	if llmResponse.requestErr != nil {
+		result.LLMStatus = llm.FallbackStatus(llmResponse.requestErr)
+	}
 
 	return result, nil
 }
@@ -266,6 +325,7 @@ func (o *TableAnalysisOrchestrator) analyzeTableWithFileReader(ctx context.Conte
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze table with LLM: %v", err)
 	}
+	o.saveRawAnalysis(task.TableName, summary, llmResponse)
 
 	// 解析 LLM 回應
 	result := &LLMAnalysisResult{
@@ -273,6 +333,9 @@ func (o *TableAnalysisOrchestrator) analyzeTableWithFileReader(ctx context.Conte
 		Analysis:  llmResponse.Analysis,
 		Timestamp: time.Now(),
 	}
+	if llmResponse.requestErr != nil {
+		result.LLMStatus = llm.FallbackStatus(llmResponse.requestErr)
+	}
 
 	return result, nil
 }
@@ -343,79 +406,209 @@ func (o *TableAnalysisOrchestrator) extractNumber(s string) int {
 	return 0
 }
 
-// buildAnalysisPrompt 構建分析提示
+// analysisPromptText 表格分析提示的各語言文字
+type analysisPromptText struct {
+	Intro       string
+	TableName   string
+	ColumnCount string
+	SampleCount string
+	Columns     string
+	DDL         string
+	Constraints string
+	PrimaryKey  string
+	FKCount     string
+	UKCount     string
+	Samples     string
+	SampleN     string
+	Questions   []string
+	Closing     string
+}
+
+// analysisPromptTexts 依語言選用的表格分析提示
+var analysisPromptTexts = map[string]analysisPromptText{
+	LanguageChinese: {
+		Intro:       "請分析以下資料庫表格的商業邏輯和用途：\n\n",
+		TableName:   "表格名稱: %s\n",
+		ColumnCount: "欄位數量: %d\n",
+		SampleCount: "樣本數據數量: %d\n",
+		Columns:     "\n欄位結構:\n",
+		DDL:         "\n表格定義 (DDL):\n",
+		Constraints: "\n約束:\n",
+		PrimaryKey:  "- 主鍵: %v\n",
+		FKCount:     "- 外鍵數量: %d\n",
+		UKCount:     "- 唯一鍵數量: %d\n",
+		Samples:     "\n樣本數據:\n",
+		SampleN:     "樣本 %d:\n",
+		Questions: []string{
+			"\n請基於以上資訊，描述這個表格的商業邏輯用途：\n",
+			"1. 這個表格在整個系統中的角色和功能是什麼？\n",
+			"2. 根據欄位定義和樣本數據，這個表格存儲的是什麼類型的業務數據？\n",
+			"3. 這個表格與其他表格的業務關係是什麼？\n",
+			"4. 從樣本數據可以看出什麼業務模式或用戶行為？\n",
+			"5. 這個表格支持哪些業務流程？\n",
+			"6. 根據約束和索引設計，可以推斷出這個表格的主要查詢場景是什麼？\n",
+		},
+		Closing: "\n請用自然、易懂的語言描述這個表格的商業用途，不要過度關注技術細節。",
+	},
+	LanguageJapanese: {
+		Intro:       "以下のデータベーステーブルのビジネスロジックと用途を分析してください：\n\n",
+		TableName:   "テーブル名: %s\n",
+		ColumnCount: "カラム数: %d\n",
+		SampleCount: "サンプルデータ数: %d\n",
+		Columns:     "\nカラム構造:\n",
+		DDL:         "\nテーブル定義 (DDL):\n",
+		Constraints: "\n制約:\n",
+		PrimaryKey:  "- 主キー: %v\n",
+		FKCount:     "- 外部キー数: %d\n",
+		UKCount:     "- ユニークキー数: %d\n",
+		Samples:     "\nサンプルデータ:\n",
+		SampleN:     "サンプル %d:\n",
+		Questions: []string{
+			"\n上記の情報に基づき、このテーブルのビジネス上の用途を説明してください：\n",
+			"1. このテーブルはシステム全体でどのような役割と機能を持っていますか？\n",
+			"2. カラム定義とサンプルデータから、どのような種類の業務データを保存していますか？\n",
+			"3. 他のテーブルとのビジネス上の関係は何ですか？\n",
+			"4. サンプルデータからどのような業務パターンやユーザー行動が読み取れますか？\n",
+			"5. このテーブルはどの業務プロセスを支えていますか？\n",
+			"6. 制約とインデックスの設計から、主なクエリシナリオは何だと推測できますか？\n",
+		},
+		Closing: "\n技術的な詳細に偏らず、自然で分かりやすい言葉でこのテーブルのビジネス用途を説明してください。",
+	},
+	LanguageEnglish: {
+		Intro:       "Please analyze the business logic and purpose of the following database table:\n\n",
+		TableName:   "Table name: %s\n",
+		ColumnCount: "Column count: %d\n",
+		SampleCount: "Sample rows: %d\n",
+		Columns:     "\nColumns:\n",
+		DDL:         "\nTable definition (DDL):\n",
+		Constraints: "\nConstraints:\n",
+		PrimaryKey:  "- Primary key: %v\n",
+		FKCount:     "- Foreign keys: %d\n",
+		UKCount:     "- Unique keys: %d\n",
+		Samples:     "\nSample data:\n",
+		SampleN:     "Sample %d:\n",
+		Questions: []string{
+			"\nBased on the information above, describe the business purpose of this table:\n",
+			"1. What role and function does this table have in the overall system?\n",
+			"2. Based on the column definitions and sample data, what kind of business data does it store?\n",
+			"3. What are its business relationships with other tables?\n",
+			"4. What business patterns or user behavior can be seen in the sample data?\n",
+			"5. Which business processes does this table support?\n",
+			"6. Based on the constraints and indexes, what are the main query scenarios?\n",
+		},
+		Closing: "\nPlease describe the business purpose of this table in natural, easy-to-understand language without focusing too much on technical details.",
+	},
+}
+
+// buildAnalysisPrompt 構建分析提示（依 schema 語言選用提示語言）
 func (o *TableAnalysisOrchestrator) buildAnalysisPrompt(summary map[string]interface{}) string {
+	text, ok := analysisPromptTexts[o.language]
+	if !ok {
+		text = analysisPromptTexts[LanguageChinese]
+	}
+
 	var prompt strings.Builder
 
-	prompt.WriteString("請分析以下資料庫表格的商業邏輯和用途：\n\n")
+	prompt.WriteString(text.Intro)
 
 	// 表格基本信息
-	prompt.WriteString(fmt.Sprintf("表格名稱: %s\n", summary["table_name"]))
-	prompt.WriteString(fmt.Sprintf("欄位數量: %d\n", summary["column_count"]))
-	prompt.WriteString(fmt.Sprintf("樣本數據數量: %d\n", summary["sample_count"]))
+	prompt.WriteString(fmt.Sprintf(text.TableName, summary["table_name"]))
+	prompt.WriteString(fmt.Sprintf(text.ColumnCount, summary["column_count"]))
+	prompt.WriteString(fmt.Sprintf(text.SampleCount, summary["sample_count"]))
+
+	// 表格結構：prompts.phase2_schema_format 為 ddl 時提供實際的 CREATE TABLE 語句，取代欄位列表與約束摘要
+	if ddl := o.tableDDL(summary); ddl != "" {
+		prompt.WriteString(text.DDL)
+		prompt.WriteString(ddl)
+	} else {
+		o.writeColumnSummary(&prompt, text, summary)
+	}
+
+	// 樣本數據
+	if samples, ok := summary["samples"].([]map[string]interface{}); ok && len(samples) > 0 {
+		prompt.WriteString(text.Samples)
+		for i, sample := range samples {
+			if i >= 3 { // 只顯示前3個樣本
+				break
+			}
+			prompt.WriteString(fmt.Sprintf(text.SampleN, i+1))
+			for key, value := range sample {
+				prompt.WriteString(fmt.Sprintf("  %s: %v\n", key, value))
+			}
+			prompt.WriteString("\n")
+		}
+	}
+
+	for _, question := range text.Questions {
+		prompt.WriteString(question)
+	}
 
+	prompt.WriteString(text.Closing)
+
+	return prompt.String()
+}
+
+// writeColumnSummary 以欄位列表與約束摘要描述表格結構
+func (o *TableAnalysisOrchestrator) writeColumnSummary(prompt *strings.Builder, text analysisPromptText, summary map[string]interface{}) {
 	// 欄位信息
 	if columns, ok := summary["columns"].([]map[string]interface{}); ok {
-		prompt.WriteString("\n欄位結構:\n")
+		prompt.WriteString(text.Columns)
 		for _, col := range columns {
 			nullable := "NOT NULL"
-			if col["nullable"].(bool) {
+			if isNullable, _ := col["nullable"].(bool); isNullable {
 				nullable = "NULL"
 			}
 			prompt.WriteString(fmt.Sprintf("- %s: %s (%s)", col["name"], col["type"], nullable))
 			if def, ok := col["default"]; ok && def != nil {
 				prompt.WriteString(fmt.Sprintf(" DEFAULT %v", def))
 			}
+			if values := nativeEnumValues(col); values != nil {
+				prompt.WriteString(fmt.Sprintf(" ENUM(%s)", strings.Join(values, ", ")))
+			}
+			if description, ok := col["description"].(string); ok && description != "" {
+				prompt.WriteString(fmt.Sprintf(" -- %s", description))
+			}
 			prompt.WriteString("\n")
 		}
 	}
 
 	// 約束信息
 	if constraints, ok := summary["constraints"].(map[string]interface{}); ok {
-		prompt.WriteString("\n約束:\n")
+		prompt.WriteString(text.Constraints)
 		if pks, ok := constraints["primary_keys"]; ok {
 			if pkList, ok := pks.([]interface{}); ok && len(pkList) > 0 {
-				prompt.WriteString(fmt.Sprintf("- 主鍵: %v\n", pkList))
+				prompt.WriteString(fmt.Sprintf(text.PrimaryKey, pkList))
 			}
 		}
 		if fkCount, ok := constraints["foreign_keys_count"]; ok {
-			prompt.WriteString(fmt.Sprintf("- 外鍵數量: %d\n", fkCount))
+			prompt.WriteString(fmt.Sprintf(text.FKCount, fkCount))
 		}
 		if ukCount, ok := constraints["unique_keys_count"]; ok {
-			prompt.WriteString(fmt.Sprintf("- 唯一鍵數量: %d\n", ukCount))
+			prompt.WriteString(fmt.Sprintf(text.UKCount, ukCount))
 		}
 	}
+}
 
-	// 樣本數據
-	if samples, ok := summary["samples"].([]map[string]interface{}); ok && len(samples) > 0 {
-		prompt.WriteString("\n樣本數據:\n")
-		for i, sample := range samples {
-			if i >= 3 { // 只顯示前3個樣本
-				break
-			}
-			prompt.WriteString(fmt.Sprintf("樣本 %d:\n", i+1))
-			for key, value := range sample {
-				prompt.WriteString(fmt.Sprintf("  %s: %v\n", key, value))
-			}
-			prompt.WriteString("\n")
-		}
+// tableDDL 在 prompts.phase2_schema_format 設為 ddl 時，由 phase1 結果重建表格的 CREATE TABLE 語句
+// 未啟用或讀取失敗時返回空字串，改用欄位列表
+func (o *TableAnalysisOrchestrator) tableDDL(summary map[string]interface{}) string {
+	if o.config == nil || o.config.Prompts.Phase2SchemaFormat != "ddl" || o.reader == nil {
+		return ""
 	}
-
-	prompt.WriteString("\n請基於以上資訊，描述這個表格的商業邏輯用途：\n")
-	prompt.WriteString("1. 這個表格在整個系統中的角色和功能是什麼？\n")
-	prompt.WriteString("2. 根據欄位定義和樣本數據，這個表格存儲的是什麼類型的業務數據？\n")
-	prompt.WriteString("3. 這個表格與其他表格的業務關係是什麼？\n")
-	prompt.WriteString("4. 從樣本數據可以看出什麼業務模式或用戶行為？\n")
-	prompt.WriteString("5. 這個表格支持哪些業務流程？\n")
-	prompt.WriteString("6. 根據約束和索引設計，可以推斷出這個表格的主要查詢場景是什麼？\n")
-
-	prompt.WriteString("\n請用自然、易懂的語言描述這個表格的商業用途，不要過度關注技術細節。")
-
-	return prompt.String()
+	tableName, _ := summary["table_name"].(string)
+	table, err := o.reader.GetTableAnalysis(tableName)
+	if err != nil {
+		log.Printf("Warning: Failed to build DDL for table %s, using column list: %v", tableName, err)
+		return ""
+	}
+	return GenerateCreateTableDDL(o.config.Database.Type, tableName, table)
 }
 
 // GetProgress 獲取分析進度
 func (o *TableAnalysisOrchestrator) GetProgress() map[string]interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
 	total := len(o.tasks)
 	completed := 0
 	failed := 0
@@ -444,6 +637,8 @@ func (o *TableAnalysisOrchestrator) GetProgress() map[string]interface{} {
 
 // GetResults 獲取所有分析結果
 func (o *TableAnalysisOrchestrator) GetResults() map[string]*LLMAnalysisResult {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 	return o.results
 }
 
@@ -456,6 +651,9 @@ type LLMClient struct {
 // LLMResponse LLM 回應
 type LLMResponse struct {
 	Analysis string `json:"analysis"`
+
+	raw        map[string]interface{} // LLM 原始回應（供 phase2_raw 記錄）
+	requestErr error                  // 請求失敗原因，此時 Analysis 為後備內容
 }
 
 // NewLLMClient 創建 LLM 客戶端
@@ -478,64 +676,145 @@ func (c *LLMClient) AnalyzeTable(ctx context.Context, tableName, prompt string)
 		"messages": []map[string]interface{}{
 			{
 				"role":    "system",
-				"content": "你是一個資料庫及數據分析專家。請分析給定的表格結構，提供專業的見解和建議。",
+				"content": SystemPrompt(c.config, "phase2", defaultPhase2SystemPrompt),
 			},
 			{
 				"role":    "user",
 				"content": prompt,
 			},
 		},
-		"temperature": 0.7,
-		"max_tokens":  2000,
 	}
+	llm.ApplyChatParams(requestBody, c.config.LLM.ParamsFor("phase2", llm.DefaultParams(llm.DefaultTemperature, 2000)))
 
 	// 發送請求到 LLM
 	response, err := c.sendRequest(ctx, requestBody)
 	if err != nil {
 		log.Printf("LLM request failed, using fallback: %v", err)
-		return c.fallbackResponse(tableName)
+		fallback, fallbackErr := c.fallbackResponse(tableName)
+		if fallback != nil {
+			fallback.requestErr = err
+		}
+		return fallback, fallbackErr
 	}
 
 	// 解析回應
-	return c.parseResponse(response)
+	parsed, err := c.parseResponse(response)
+	if parsed != nil {
+		parsed.raw = response
+	}
+	return parsed, err
 }
 
 // sendRequest 發送請求到 LLM
 func (c *LLMClient) sendRequest(ctx context.Context, requestBody map[string]interface{}) (map[string]interface{}, error) {
+	if c.config.LLM.Provider == "anthropic" {
+		return c.sendAnthropicRequest(ctx, requestBody)
+	}
+
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
 	// 構建請求 URL
-	url := fmt.Sprintf("http://%s:%d/v1/chat/completions", c.config.LLM.Host, c.config.LLM.Port)
+	endpoint := fmt.Sprintf("%s:%d", c.config.LLM.Host, c.config.LLM.Port)
+	url := fmt.Sprintf("http://%s/v1/chat/completions", endpoint)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
+	// 連線錯誤與 5xx/429 依 llm.max_retries 以指數退避重試
+	resp, err := llm.NewRetryPolicy(c.config).Do(ctx, c.client, endpoint, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		if c.config.LLM.APIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+c.config.LLM.APIKey)
+		}
+		return req, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	req.Header.Set("Content-Type", "application/json")
-	if c.config.LLM.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.config.LLM.APIKey)
+	var response map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, llm.NewParseError(endpoint, err)
 	}
+	c.recordUsage(response)
 
-	resp, err := c.client.Do(req)
+	return response, nil
+}
+
+// sendAnthropicRequest 將 OpenAI 格式的請求轉為 Anthropic Messages API 格式送出，
+// 回應轉回 choices[0].message.content 與 usage 的 OpenAI 格式，讓後續解析與 token 記錄不需區分提供者
+func (c *LLMClient) sendAnthropicRequest(ctx context.Context, requestBody map[string]interface{}) (map[string]interface{}, error) {
+	jsonData, err := json.Marshal(llm.AnthropicRequestBody(requestBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("LLM API returned status %d", resp.StatusCode)
+	url := llm.AnthropicMessagesURL(c.config)
+	endpoint := llm.AnthropicEndpoint(c.config)
+
+	resp, err := llm.NewRetryPolicy(c.config).Do(ctx, c.client, endpoint, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range llm.AnthropicHeaders(c.config) {
+			req.Header.Set(key, value)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
 	var response map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
+		return nil, llm.NewParseError(endpoint, err)
 	}
 
-	return response, nil
+	text, err := llm.AnthropicResponseText(response)
+	if err != nil {
+		return nil, llm.NewParseError(endpoint, err)
+	}
+	inputTokens, outputTokens := llm.AnthropicUsage(response)
+
+	normalized := map[string]interface{}{
+		"id":    response["id"],
+		"model": response["model"],
+		"choices": []interface{}{
+			map[string]interface{}{
+				"message":       map[string]interface{}{"role": "assistant", "content": text},
+				"finish_reason": response["stop_reason"],
+			},
+		},
+		"usage": map[string]interface{}{
+			"prompt_tokens":     float64(inputTokens),
+			"completion_tokens": float64(outputTokens),
+		},
+	}
+	c.recordUsage(normalized)
+
+	return normalized, nil
+}
+
+// recordUsage 記錄回應中 usage 物件的 token 數（Phase 2 表格分析）
+func (c *LLMClient) recordUsage(response map[string]interface{}) {
+	usage, ok := response["usage"].(map[string]interface{})
+	if !ok {
+		llm.RecordUsage("phase2", 0, 0)
+		return
+	}
+	promptTokens, _ := usage["prompt_tokens"].(float64)
+	completionTokens, _ := usage["completion_tokens"].(float64)
+	llm.RecordUsage("phase2", int(promptTokens), int(completionTokens))
 }
 
 // parseResponse 解析 LLM 回應
@@ -597,9 +876,8 @@ func (c *LLMClient) CallLLM(prompt string) (string, error) {
 				"content": prompt,
 			},
 		},
-		"temperature": 0.7,
-		"max_tokens":  2000,
 	}
+	llm.ApplyChatParams(requestBody, c.config.LLM.ParamsFor("phase2", llm.DefaultParams(llm.DefaultTemperature, 2000)))
 
 	// 發送請求到 LLM
 	response, err := c.sendRequest(ctx, requestBody)
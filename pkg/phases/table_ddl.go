@@ -0,0 +1,190 @@
+package phases
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var plainIdentifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// ddlQuoteIdentifier 依資料庫類型引用識別字；一般小寫識別字保持原樣以節省 token
+func ddlQuoteIdentifier(dbType, name string) string {
+	if plainIdentifierPattern.MatchString(name) {
+		return name
+	}
+	if dbType == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// ddlColumnType 由 phase1 欄位信息還原欄位型別（含長度與精度）
+func ddlColumnType(col map[string]interface{}) string {
+	colType, _ := col["type"].(string)
+	if colType == "" {
+		colType = "text"
+	}
+
+	maxLength := toInt64(col["max_length"])
+	precision := toInt64(col["precision"])
+	scale := toInt64(col["scale"])
+
+	// 原生 ENUM：PostgreSQL 使用型別名稱，MySQL 使用內嵌的值列表
+	if values := nativeEnumValues(col); values != nil {
+		if enumType, ok := col["enum_type"].(string); ok && enumType != "" {
+			return enumType
+		}
+		literals := make([]string, 0, len(values))
+		for _, value := range values {
+			literals = append(literals, ddlLiteral(value))
+		}
+		return fmt.Sprintf("ENUM(%s)", strings.Join(literals, ", "))
+	}
+
+	switch strings.ToLower(colType) {
+	case "character varying", "varchar", "character", "char":
+		if maxLength > 0 {
+			return fmt.Sprintf("%s(%d)", colType, maxLength)
+		}
+	case "numeric", "decimal":
+		if precision > 0 {
+			return fmt.Sprintf("%s(%d,%d)", colType, precision, scale)
+		}
+	}
+	return colType
+}
+
+// toInt64 將 JSON 解碼後的數值轉為 int64
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// GenerateCreateTableDDL 由 phase1 的欄位與約束信息重建 CREATE TABLE 語句（依資料庫類型引用識別字）
+func GenerateCreateTableDDL(dbType, tableName string, table *TableAnalysisResult) string {
+	quote := func(name string) string { return ddlQuoteIdentifier(dbType, name) }
+	quoteAll := func(names []string) string {
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = quote(name)
+		}
+		return strings.Join(quoted, ", ")
+	}
+
+	var lines []string
+	for _, col := range table.Schema {
+		name, _ := col["name"].(string)
+		line := fmt.Sprintf("    %s %s", quote(name), ddlColumnType(col))
+		if nullable, ok := col["nullable"].(bool); ok && !nullable {
+			line += " NOT NULL"
+		}
+		if def, ok := col["default"]; ok && def != nil {
+			line += fmt.Sprintf(" DEFAULT %v", def)
+		}
+		lines = append(lines, line)
+	}
+
+	if pks := stringList(table.Constraints["primary_keys"]); len(pks) > 0 {
+		lines = append(lines, fmt.Sprintf("    PRIMARY KEY (%s)", quoteAll(pks)))
+	}
+
+	for _, uk := range mapList(table.Constraints["unique_keys"]) {
+		if columns := stringList(uk["columns"]); len(columns) > 0 {
+			lines = append(lines, fmt.Sprintf("    UNIQUE (%s)", quoteAll(columns)))
+		}
+	}
+
+	// 同一外鍵約束的多個欄位合併為一條定義
+	type foreignKey struct {
+		columns, refColumns []string
+		refTable            string
+	}
+	foreignKeys := make(map[string]*foreignKey)
+	var fkNames []string
+	for _, fk := range mapList(table.Constraints["foreign_keys"]) {
+		name, _ := fk["constraint_name"].(string)
+		refTable, _ := fk["referenced_table"].(string)
+		columns, refColumns := foreignKeyColumns(fk)
+		if len(columns) == 0 {
+			continue
+		}
+		if name == "" {
+			name = columns[0]
+		}
+		entry, ok := foreignKeys[name]
+		if !ok {
+			entry = &foreignKey{refTable: refTable}
+			foreignKeys[name] = entry
+			fkNames = append(fkNames, name)
+		}
+		entry.columns = append(entry.columns, columns...)
+		entry.refColumns = append(entry.refColumns, refColumns...)
+	}
+	sort.Strings(fkNames)
+	for _, name := range fkNames {
+		fk := foreignKeys[name]
+		lines = append(lines, fmt.Sprintf("    FOREIGN KEY (%s) REFERENCES %s (%s)", quoteAll(fk.columns), quote(fk.refTable), quoteAll(fk.refColumns)))
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);\n", quote(tableName), strings.Join(lines, ",\n"))
+}
+
+// stringList 將 []string 或 JSON 解碼後的 []interface{} 轉為字串列表
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// foreignKeyColumns 返回外鍵的本地欄位與參照欄位（依約束中的順序）
+// 新的 Phase 1 輸出每個約束一筆並帶有 columns / referenced_columns；舊輸出每個欄位一筆，只有 column / referenced_column
+func foreignKeyColumns(fk map[string]interface{}) ([]string, []string) {
+	columns := stringList(fk["columns"])
+	refColumns := stringList(fk["referenced_columns"])
+	if len(columns) > 0 {
+		return columns, refColumns
+	}
+
+	column, _ := fk["column"].(string)
+	if column == "" {
+		return nil, nil
+	}
+	refColumn, _ := fk["referenced_column"].(string)
+	return []string{column}, []string{refColumn}
+}
+
+// mapList 將 []map[string]interface{} 或 JSON 解碼後的 []interface{} 轉為 map 列表
+func mapList(value interface{}) []map[string]interface{} {
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		return v
+	case []interface{}:
+		list := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				list = append(list, m)
+			}
+		}
+		return list
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package phases
+
+import (
+	"log"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// DropFilteredTables 從 phase1 輸出中移除不符合 schema.include_patterns / exclude_patterns 的表格
+// 合併模式會保留舊輸出中的表格，因此寫入前需再過濾一次；返回被移除的表格
+func DropFilteredTables(schemaCfg config.SchemaConfig, output map[string]interface{}) []string {
+	tables, ok := output["tables"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var dropped []string
+	for tableName := range tables {
+		if !schemaCfg.TableAllowed(tableName) {
+			delete(tables, tableName)
+			dropped = append(dropped, tableName)
+		}
+	}
+	if len(dropped) > 0 {
+		output["tables_count"] = len(tables)
+		log.Printf("Dropped %d tables excluded by schema patterns from phase1 output", len(dropped))
+	}
+	return dropped
+}
@@ -0,0 +1,18 @@
+package phases
+
+import "github.com/masato25/aika-dba/pkg/types"
+
+// 共用的分析資料結構定義於 pkg/types，此處以別名提供給 phases 套件使用
+type (
+	Phase1Result         = types.Phase1Result
+	TableAnalysisResult  = types.TableAnalysisResult
+	LLMAnalysisResult    = types.LLMAnalysisResult
+	Phase2AnalysisResult = types.Phase2AnalysisResult
+	Phase2Summary        = types.Phase2Summary
+	Dimension            = types.Dimension
+	FactTable            = types.FactTable
+	MarketingQueryResult = types.MarketingQueryResult
+
+	// TableAnalysis Phase 3 讀取的單表分析，與 Phase 2 輸出使用同一結構
+	TableAnalysis = types.LLMAnalysisResult
+)
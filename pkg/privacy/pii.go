@@ -0,0 +1,282 @@
+// Package privacy 偵測可能含個人資料（PII）的欄位，並對樣本數據進行遮罩
+// MCP 工具與向量存儲使用同一套規則，確保樣本在任何出口的處理方式一致
+package privacy
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// 遮罩模式
+const (
+	MaskModeMask = "mask" // 以固定字串取代樣本值
+	MaskModeOmit = "omit" // 從樣本中移除欄位
+)
+
+// MaskedValue 遮罩後的樣本值
+const MaskedValue = "***"
+
+// piiNameTokens 欄位名稱中出現即視為 PII 的單字（以 _、-、空白切分後比對）
+var piiNameTokens = map[string]bool{
+	"email": true, "mail": true, "phone": true, "mobile": true, "tel": true, "fax": true,
+	"address": true, "addr": true, "ssn": true, "passport": true, "birthday": true,
+	"dob": true, "password": true, "passwd": true, "iban": true, "salary": true,
+}
+
+// piiNameSubstrings 欄位名稱中出現即視為 PII 的片段
+var piiNameSubstrings = []string{
+	"first_name", "last_name", "full_name", "real_name", "customer_name", "contact_name",
+	"birth_date", "date_of_birth", "credit_card", "card_number", "id_number", "national_id",
+	"ip_address", "姓名", "電話", "手機", "地址", "身分證", "生日", "信箱",
+}
+
+var (
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[A-Za-z]{2,}$`)
+	phonePattern = regexp.MustCompile(`^\+?\d{1,4}[\s-]\d{2,4}[\s-]?\d{3,4}[\s-]?\d{0,4}$`)
+)
+
+// IsPIIColumnName 依欄位名稱判斷是否可能為 PII
+func IsPIIColumnName(columnName string) bool {
+	name := strings.ToLower(columnName)
+	for _, token := range strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' '
+	}) {
+		if piiNameTokens[token] {
+			return true
+		}
+	}
+	for _, fragment := range piiNameSubstrings {
+		if strings.Contains(name, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPIIValue 依樣本值格式判斷是否為電子郵件或電話號碼
+func isPIIValue(value interface{}) bool {
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	s = strings.TrimSpace(s)
+	return emailPattern.MatchString(s) || phonePattern.MatchString(s)
+}
+
+// DetectPIIColumns 根據欄位名稱與樣本值偵測可能含 PII 的欄位
+func DetectPIIColumns(schema []map[string]interface{}, samples []map[string]interface{}) []string {
+	flagged := make(map[string]bool)
+
+	for _, col := range schema {
+		if name, ok := col["name"].(string); ok && IsPIIColumnName(name) {
+			flagged[name] = true
+		}
+	}
+
+	for _, sample := range samples {
+		for name, value := range sample {
+			if !flagged[name] && isPIIValue(value) {
+				flagged[name] = true
+			}
+		}
+	}
+
+	columns := make([]string, 0, len(flagged))
+	for name := range flagged {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// MaskSamples 依配置對表格樣本進行遮罩，返回遮罩後的副本與被遮罩的欄位
+// 未啟用或該表格的策略停用時原樣返回
+func MaskSamples(cfg config.SampleMaskingConfig, tableName string, schema []map[string]interface{}, samples []map[string]interface{}) ([]map[string]interface{}, []string) {
+	if !cfg.Enabled {
+		return samples, nil
+	}
+
+	policy := cfg.Tables[tableName]
+	if policy.Disabled {
+		return samples, nil
+	}
+
+	flagged := make(map[string]bool)
+	if cfg.DetectPII {
+		for _, name := range DetectPIIColumns(schema, samples) {
+			flagged[name] = true
+		}
+	}
+	for _, name := range cfg.Columns {
+		flagged[name] = true
+	}
+	for _, name := range policy.Columns {
+		flagged[name] = true
+	}
+	if len(flagged) == 0 {
+		return samples, nil
+	}
+
+	mode := policy.Mode
+	if mode == "" {
+		mode = cfg.Mode
+	}
+
+	masked := make([]map[string]interface{}, 0, len(samples))
+	for _, sample := range samples {
+		row := make(map[string]interface{}, len(sample))
+		for name, value := range sample {
+			if !flagged[name] {
+				row[name] = value
+				continue
+			}
+			if mode == MaskModeOmit {
+				continue
+			}
+			if value != nil {
+				value = MaskedValue
+			}
+			row[name] = value
+		}
+		masked = append(masked, row)
+	}
+
+	columns := make([]string, 0, len(flagged))
+	for name := range flagged {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+	return masked, columns
+}
+
+// RedactPhaseKnowledge 對知識中 tables.<table>.samples 的樣本套用遮罩，返回淺複製後的知識
+// 供寫入向量存儲前使用，原始知識（例如 phase1_analysis.json）不受影響
+func RedactPhaseKnowledge(cfg config.SampleMaskingConfig, knowledge map[string]interface{}) map[string]interface{} {
+	if !cfg.Enabled {
+		return knowledge
+	}
+
+	tables, ok := knowledge["tables"].(map[string]interface{})
+	if !ok {
+		return knowledge
+	}
+
+	redactedTables := make(map[string]interface{}, len(tables))
+	for tableName, tableData := range tables {
+		tableInfo, ok := tableData.(map[string]interface{})
+		if !ok {
+			redactedTables[tableName] = tableData
+			continue
+		}
+
+		samples := toMapSlice(tableInfo["samples"])
+		if len(samples) == 0 {
+			redactedTables[tableName] = tableData
+			continue
+		}
+
+		redactedTable := make(map[string]interface{}, len(tableInfo))
+		for key, value := range tableInfo {
+			redactedTable[key] = value
+		}
+		redactedTable["samples"], _ = MaskSamples(cfg, tableName, toMapSlice(tableInfo["schema"]), samples)
+		redactedTables[tableName] = redactedTable
+	}
+
+	redacted := make(map[string]interface{}, len(knowledge))
+	for key, value := range knowledge {
+		redacted[key] = value
+	}
+	redacted["tables"] = redactedTables
+	return redacted
+}
+
+// ResultMasker 對任意查詢的結果套用遮罩。結果欄位無法對應來源表格，因此欄位名稱（不分大小寫）符合全域欄位、
+// 任一未停用表格策略的欄位或 PII 名稱時整欄處理；啟用 detect_pii 時其餘欄位的值若像電子郵件或電話也逐值遮罩
+type ResultMasker struct {
+	enabled   bool
+	detectPII bool
+	omit      bool
+	flagged   map[string]bool
+}
+
+// NewResultMasker 依配置創建查詢結果的遮罩器，未啟用時不修改任何結果
+func NewResultMasker(cfg config.SampleMaskingConfig) *ResultMasker {
+	masker := &ResultMasker{
+		enabled:   cfg.Enabled,
+		detectPII: cfg.DetectPII,
+		omit:      cfg.Mode == MaskModeOmit,
+		flagged:   make(map[string]bool),
+	}
+	for _, name := range cfg.Columns {
+		masker.flagged[strings.ToLower(name)] = true
+	}
+	for _, policy := range cfg.Tables {
+		if policy.Disabled {
+			continue
+		}
+		for _, name := range policy.Columns {
+			masker.flagged[strings.ToLower(name)] = true
+		}
+	}
+	return masker
+}
+
+// isFlagged 欄位是否整欄遮罩
+func (m *ResultMasker) isFlagged(column string) bool {
+	return m.enabled && (m.flagged[strings.ToLower(column)] || (m.detectPII && IsPIIColumnName(column)))
+}
+
+// Columns 返回輸出的欄位：omit 模式下移除整欄遮罩的欄位，保留原有順序
+func (m *ResultMasker) Columns(columns []string) []string {
+	if !m.enabled || !m.omit {
+		return columns
+	}
+	kept := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if !m.isFlagged(column) {
+			kept = append(kept, column)
+		}
+	}
+	return kept
+}
+
+// MaskRow 返回遮罩後的結果行副本；omit 模式只移除整欄遮罩的欄位，逐值偵測到的 PII 一律以 MaskedValue 取代
+func (m *ResultMasker) MaskRow(row map[string]interface{}) map[string]interface{} {
+	if !m.enabled {
+		return row
+	}
+	masked := make(map[string]interface{}, len(row))
+	for name, value := range row {
+		flagged := m.isFlagged(name)
+		if flagged && m.omit {
+			continue
+		}
+		if value != nil && (flagged || (m.detectPII && isPIIValue(value))) {
+			value = MaskedValue
+		}
+		masked[name] = value
+	}
+	return masked
+}
+
+// toMapSlice 將 []map[string]interface{} 或 JSON 解碼後的 []interface{} 統一轉換
+func toMapSlice(data interface{}) []map[string]interface{} {
+	switch v := data.(type) {
+	case []map[string]interface{}:
+		return v
+	case []interface{}:
+		result := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				result = append(result, m)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
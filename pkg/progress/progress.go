@@ -58,29 +58,34 @@ type ProgressEvent struct {
 	Type     string         `json:"type"`
 	Phase    string         `json:"phase"`
 	Progress *PhaseProgress `json:"progress,omitempty"`
+	// Log AddLog 產生的事件中新增的日誌條目，讓訂閱者不必比對整個 logs 列表
+	Log *LogEntry `json:"log,omitempty"`
+	// Snapshot 訂閱時補送的當前狀態（非即時更新）
+	Snapshot bool `json:"snapshot,omitempty"`
 }
 
+// subscriberBuffer 每個訂閱者的事件緩衝，訂閱者跟不上時多出的事件會被丟棄
+const subscriberBuffer = 256
+
 // Subscribe 訂閱進度事件
 func (pm *ProgressManager) Subscribe() (<-chan ProgressEvent, func()) {
 	pm.mutex.Lock()
 	id := pm.nextSubscriberID
 	pm.nextSubscriberID++
-	ch := make(chan ProgressEvent, 16)
+	ch := make(chan ProgressEvent, subscriberBuffer)
+
+	// 在登記訂閱前放入當前快照，確保快照排在之後的即時事件之前
+	for _, progress := range pm.progresses {
+		pm.safeSend(ch, ProgressEvent{
+			Type:     "progress",
+			Phase:    progress.Phase,
+			Progress: pm.cloneProgress(progress),
+			Snapshot: true,
+		})
+	}
 	pm.subscribers[id] = ch
 	pm.mutex.Unlock()
 
-	// 傳送當前快照
-	snapshot := pm.GetAllProgress()
-	go func() {
-		for _, progress := range snapshot {
-			pm.safeSend(ch, ProgressEvent{
-				Type:     "progress",
-				Phase:    progress.Phase,
-				Progress: progress,
-			})
-		}
-	}()
-
 	unsubscribe := func() {
 		pm.mutex.Lock()
 		if existing, ok := pm.subscribers[id]; ok && existing == ch {
@@ -161,17 +166,17 @@ func (pm *ProgressManager) StartPhase(phase string, totalSteps int) {
 	pm.broadcastProgress(progressClone)
 }
 
-// AddLog 添加日誌條目
+// AddLog 添加日誌條目，廣播的事件以 Log 帶出新增的條目
 func (pm *ProgressManager) AddLog(phase string, level string, message string) {
 	pm.mutex.Lock()
 	progress, exists := pm.progresses[phase]
 	var progressClone *PhaseProgress
+	logEntry := LogEntry{
+		Timestamp: time.Now(),
+		Level:     level,
+		Message:   message,
+	}
 	if exists {
-		logEntry := LogEntry{
-			Timestamp: time.Now(),
-			Level:     level,
-			Message:   message,
-		}
 		progress.Logs = append(progress.Logs, logEntry)
 
 		// 限制日誌數量，避免記憶體過度使用
@@ -183,7 +188,12 @@ func (pm *ProgressManager) AddLog(phase string, level string, message string) {
 	pm.mutex.Unlock()
 
 	if exists {
-		pm.broadcastProgress(progressClone)
+		pm.broadcast(ProgressEvent{
+			Type:     "progress",
+			Phase:    phase,
+			Progress: progressClone,
+			Log:      &logEntry,
+		})
 	}
 }
 
@@ -305,3 +315,81 @@ func (pm *ProgressManager) ResetProgress(phase string) {
 
 	pm.broadcast(ProgressEvent{Type: "progress", Phase: phase})
 }
+
+// DefaultPipelinePhases 整體進度預設涵蓋的 phase（依執行順序）
+var DefaultPipelinePhases = []string{"phase1", "phase2_prefix", "phase2", "phase3"}
+
+// PhaseContribution 單一 phase 對整體進度的貢獻
+type PhaseContribution struct {
+	Phase    string         `json:"phase"`
+	Weight   float64        `json:"weight"`
+	Status   ProgressStatus `json:"status"`
+	Progress float64        `json:"progress"` // 0-100
+}
+
+// OverallProgress 多個 phase 依權重加總的整體進度
+type OverallProgress struct {
+	Status       ProgressStatus      `json:"status"`
+	Progress     float64             `json:"progress"` // 0-100
+	CurrentPhase string              `json:"current_phase,omitempty"`
+	Phases       []PhaseContribution `json:"phases"`
+}
+
+// GetOverallProgress 依權重彙總指定 phase 的進度；未設定或非正數的權重視為 1
+func (pm *ProgressManager) GetOverallProgress(phases []string, weights map[string]float64) *OverallProgress {
+	pm.mutex.RLock()
+	defer pm.mutex.RUnlock()
+
+	overall := &OverallProgress{
+		Status: StatusIdle,
+		Phases: make([]PhaseContribution, 0, len(phases)),
+	}
+
+	var totalWeight, weighted float64
+	started, completed, failed := 0, 0, false
+	for _, phase := range phases {
+		weight := weights[phase]
+		if weight <= 0 {
+			weight = 1
+		}
+
+		contribution := PhaseContribution{
+			Phase:  phase,
+			Weight: weight,
+			Status: StatusIdle,
+		}
+		if progress, exists := pm.progresses[phase]; exists {
+			contribution.Status = progress.Status
+			contribution.Progress = progress.Progress
+			started++
+			switch progress.Status {
+			case StatusCompleted:
+				contribution.Progress = 100
+				completed++
+			case StatusFailed:
+				failed = true
+			case StatusRunning:
+				overall.CurrentPhase = phase
+			}
+		}
+
+		totalWeight += weight
+		weighted += weight * contribution.Progress
+		overall.Phases = append(overall.Phases, contribution)
+	}
+
+	if totalWeight > 0 {
+		overall.Progress = weighted / totalWeight
+	}
+
+	switch {
+	case failed:
+		overall.Status = StatusFailed
+	case len(phases) > 0 && completed == len(phases):
+		overall.Status = StatusCompleted
+	case started > 0:
+		overall.Status = StatusRunning
+	}
+
+	return overall
+}
@@ -0,0 +1,167 @@
+// Package sqlguard 集中檢查送往資料庫的查詢，並在安全模式（app.safe_mode）下禁止所有資料修改功能
+package sqlguard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// ErrSafeMode 安全模式下嘗試使用資料修改功能時返回
+var ErrSafeMode = errors.New("disabled in safe mode")
+
+// writeKeywords 出現即視為可能修改資料的關鍵字（以詞為單位比對）
+var writeKeywords = []string{
+	"DROP", "DELETE", "UPDATE", "INSERT", "ALTER", "CREATE", "TRUNCATE",
+	"EXEC", "EXECUTE", "MERGE", "BULK", "BACKUP", "RESTORE",
+	"INTO", "GRANT", "REVOKE", "CALL", "VACUUM",
+}
+
+// sqlWords 返回查詢中的詞（不含分號與括號），註解、字串與引用識別字中的內容不列入；無法切分時返回 nil
+func sqlWords(query string) []string {
+	tokens, err := tokenize(query, DialectPostgres)
+	if err != nil {
+		return nil
+	}
+	return wordsOf(tokens)
+}
+
+// wordsOf 去除 tokenize 結果中的分號與括號
+func wordsOf(tokens []string) []string {
+	words := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if token != statementSeparator && token != openParen && token != closeParen {
+			words = append(words, token)
+		}
+	}
+	return words
+}
+
+// IsSelectStatement 查詢是否以 SELECT 或 WITH（CTE）開頭
+func IsSelectStatement(query string) bool {
+	return startsWithSelect(sqlWords(query))
+}
+
+// startsWithSelect 第一個詞是否為 SELECT 或 WITH
+func startsWithSelect(words []string) bool {
+	return len(words) > 0 && (words[0] == "SELECT" || words[0] == "WITH")
+}
+
+// TrimTerminator 移除查詢結尾的空白與單一分號
+func TrimTerminator(query string) string {
+	return strings.TrimSuffix(strings.TrimSpace(query), ";")
+}
+
+// CheckReadOnly 確認查詢為單一 SELECT（或 CTE）且不含任何寫入關鍵字；不論是否為安全模式，都不接受分號
+// 依 database.type 的方言略過註解、字串與引用識別字，因此 WHERE note = 'update me' 或 MySQL 的 `order` 不會被誤判
+func CheckReadOnly(cfg *config.Config, query string) error {
+	tokens, err := tokenize(query, DialectFor(cfg))
+	if err != nil {
+		return err
+	}
+	if err := checkSingleSelect(tokens); err != nil {
+		return err
+	}
+	return checkWriteKeywords(wordsOf(tokens))
+}
+
+// checkSingleSelect 確認 tokens 為單一 SELECT 或 CTE 語句
+func checkSingleSelect(tokens []string) error {
+	if !startsWithSelect(wordsOf(tokens)) {
+		return fmt.Errorf("only SELECT queries are allowed")
+	}
+	for _, token := range tokens {
+		if token == statementSeparator {
+			return fmt.Errorf("multiple statements are not allowed")
+		}
+	}
+	return nil
+}
+
+// checkWriteKeywords 任一詞為寫入關鍵字時返回錯誤
+func checkWriteKeywords(words []string) error {
+	for _, word := range words {
+		for _, keyword := range writeKeywords {
+			if word == keyword {
+				return fmt.Errorf("query contains disallowed keyword '%s'", keyword)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckQuery 依配置檢查查詢：一律只允許單一 SELECT 或 CTE（可有結尾分號），CTE 可包含修改資料的子句，因此一律檢查寫入關鍵字；
+// 安全模式下所有查詢都檢查寫入關鍵字
+func CheckQuery(cfg *config.Config, query string) error {
+	tokens, err := tokenize(TrimTerminator(query), DialectFor(cfg))
+	if err != nil {
+		return err
+	}
+	if err := checkSingleSelect(tokens); err != nil {
+		return err
+	}
+
+	words := wordsOf(tokens)
+	if SafeMode(cfg) {
+		if err := checkWriteKeywords(words); err != nil {
+			return fmt.Errorf("%v (safe mode)", err)
+		}
+	} else if words[0] == "WITH" {
+		return checkWriteKeywords(words)
+	}
+	return nil
+}
+
+// HasTopLevelKeyword 查詢最外層（不在括號內的子查詢或函數參數中）是否出現 keywords 之一，例如判斷查詢本身是否已有 LIMIT；
+// 依 database.type 的方言略過註解、字串與引用識別字，無法切分時返回 true，呼叫端應視為已有該子句
+func HasTopLevelKeyword(cfg *config.Config, query string, keywords ...string) bool {
+	tokens, err := tokenize(query, DialectFor(cfg))
+	if err != nil {
+		return true
+	}
+
+	depth := 0
+	for _, token := range tokens {
+		switch token {
+		case openParen:
+			depth++
+		case closeParen:
+			depth--
+		default:
+			if depth != 0 {
+				continue
+			}
+			for _, keyword := range keywords {
+				if token == strings.ToUpper(keyword) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ReferencedNames 返回查詢中的詞與引用識別字的內容，供檢查查詢是否引用特定表格；
+// 無法區分表格、欄位與關鍵字，schema.table 會拆成兩個名稱，呼叫端應保守比對
+func ReferencedNames(cfg *config.Config, query string) ([]string, error) {
+	tokens, err := scanTokens(query, DialectFor(cfg), true)
+	if err != nil {
+		return nil, err
+	}
+	return wordsOf(tokens), nil
+}
+
+// RequireWritable 在執行任何會修改資料庫的功能前呼叫，安全模式下返回 ErrSafeMode
+func RequireWritable(cfg *config.Config, feature string) error {
+	if SafeMode(cfg) {
+		return fmt.Errorf("%s is %w", feature, ErrSafeMode)
+	}
+	return nil
+}
+
+// SafeMode 是否啟用安全模式
+func SafeMode(cfg *config.Config) bool {
+	return cfg != nil && cfg.App.SafeMode
+}
@@ -0,0 +1,130 @@
+package sqlguard
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+func configFor(dbType string, safeMode bool) *config.Config {
+	cfg := &config.Config{}
+	cfg.Database.Type = dbType
+	cfg.App.SafeMode = safeMode
+	return cfg
+}
+
+func TestCheckReadOnlyDialects(t *testing.T) {
+	tests := []struct {
+		name    string
+		dbType  string
+		query   string
+		wantErr string
+	}{
+		{"plain select", "postgres", "SELECT id FROM customers", ""},
+		{"keyword inside string literal", "postgres", "SELECT * FROM notes WHERE note = 'update me'", ""},
+		{"doubled quote in literal", "postgres", "SELECT * FROM notes WHERE note = 'it''s; DROP'", ""},
+		{"keyword inside comment", "postgres", "SELECT 1 -- delete later\nFROM t", ""},
+		{"keyword inside double-quoted identifier", "postgres", `SELECT "update" FROM t`, ""},
+		{"column name containing keyword", "postgres", "SELECT created_at, updated_by FROM t", ""},
+		{"dollar-quoted string", "postgres", "SELECT $$; DELETE FROM t$$", ""},
+		{"positional parameter is not a dollar quote", "postgres", "SELECT * FROM t WHERE id = $1", ""},
+		{"mysql backtick identifiers", "mysql", "SELECT `order`, `update` FROM `order`", ""},
+		{"mysql hash comment", "mysql", "SELECT 1 # delete\nFROM t", ""},
+		{"mysql backslash in literal without ambiguity", "mysql", `SELECT * FROM t WHERE path = 'C:\dir'`, ""},
+		{"sqlite string literal", "sqlite", "SELECT * FROM t WHERE s = 'insert'", ""},
+
+		{"not a select", "postgres", "DELETE FROM customers", "only SELECT"},
+		{"write keyword in cte", "postgres", "WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d", "DELETE"},
+		{"semicolon", "postgres", "SELECT 1; SELECT 2", "multiple statements"},
+		{"trailing semicolon", "postgres", "SELECT 1;", "multiple statements"},
+		{"backtick comment trick on postgres", "postgres", "SELECT 1 /*`*/; DELETE FROM customers; --`", "multiple statements"},
+		{"backtick comment trick on mysql", "mysql", "SELECT 1 /*`*/; DELETE FROM customers; --`", "unterminated"},
+		{"backticks are not identifiers on postgres", "postgres", "SELECT `update` FROM t", "UPDATE"},
+		{"backticks are not identifiers on sqlite", "sqlite", "SELECT `delete` FROM t", "DELETE"},
+		{"nested postgres comment hides quote", "postgres", "SELECT 1 /* /* */ ' */ ; DELETE FROM t; --'", "multiple statements"},
+		{"dollar quote hides quote", "postgres", "SELECT $a$ ' $a$; DELETE FROM t; --'", "multiple statements"},
+		{"mysql minus minus is subtraction", "mysql", "SELECT 1--1; DELETE FROM t", "multiple statements"},
+		{"mysql executable comment", "mysql", "SELECT 1 /*! ; DELETE FROM t */", "executable comments"},
+		{"mysql ambiguous backslash", "mysql", `SELECT '\'; DELETE FROM t; -- '`, "ambiguous backslash"},
+		{"postgres ambiguous backslash", "postgres", `SELECT '\'; DELETE FROM t; -- '`, "ambiguous backslash"},
+		{"unterminated literal", "postgres", "SELECT 'abc", "unterminated"},
+		{"unterminated comment", "postgres", "SELECT 1 /* abc", "unterminated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckReadOnly(configFor(tt.dbType, false), tt.query)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckReadOnly(%q) = %v, want nil", tt.query, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CheckReadOnly(%q) = %v, want error containing %q", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		dbType   string
+		safeMode bool
+		query    string
+		wantErr  string
+	}{
+		{"trailing semicolon allowed", "postgres", false, "SELECT 1;", ""},
+		{"select with keyword outside safe mode", "postgres", false, "SELECT * INTO backup FROM t", ""},
+		{"multiple statements outside safe mode", "postgres", false, "SELECT 1; DELETE FROM t", "multiple statements"},
+		{"backtick comment trick outside safe mode", "postgres", false, "SELECT 1 /*`*/; DELETE FROM customers; --`", "multiple statements"},
+		{"backtick comment trick in safe mode", "mysql", true, "SELECT 1 /*`*/; DELETE FROM customers; --`", "unterminated"},
+		{"write keyword in safe mode", "postgres", true, "SELECT * INTO backup FROM t", "safe mode"},
+		{"literal keyword in safe mode", "postgres", true, "SELECT * FROM t WHERE s = 'drop'", ""},
+		{"mysql reserved word identifier in safe mode", "mysql", true, "SELECT `order` FROM t", ""},
+		{"write keyword in cte", "postgres", false, "WITH x AS (UPDATE t SET a = 1 RETURNING a) SELECT * FROM x", "UPDATE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckQuery(configFor(tt.dbType, tt.safeMode), tt.query)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckQuery(%q) = %v, want nil", tt.query, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CheckQuery(%q) = %v, want error containing %q", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHasTopLevelKeyword(t *testing.T) {
+	tests := []struct {
+		name   string
+		dbType string
+		query  string
+		want   bool
+	}{
+		{"top-level limit", "postgres", "SELECT * FROM t ORDER BY id LIMIT 10", true},
+		{"limit in subquery", "postgres", "SELECT * FROM (SELECT * FROM t LIMIT 10) s", false},
+		{"limit in cte", "postgres", "WITH x AS (SELECT * FROM t LIMIT 5) SELECT * FROM x", false},
+		{"limit in string", "postgres", "SELECT * FROM t WHERE note = 'no limit'", false},
+		{"limit in comment", "mysql", "SELECT * FROM t # limit 5", false},
+		{"quoted identifier", "mysql", "SELECT `limit` FROM t", false},
+		{"offset", "sqlite", "SELECT * FROM t LIMIT -1 OFFSET 5", true},
+		{"unterminated string", "postgres", "SELECT 'x FROM t", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasTopLevelKeyword(configFor(tt.dbType, false), tt.query, "limit", "offset"); got != tt.want {
+				t.Errorf("HasTopLevelKeyword(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
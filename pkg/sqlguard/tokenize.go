@@ -0,0 +1,268 @@
+package sqlguard
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// Dialect 決定註解、字串與引用識別字的語法
+type Dialect int
+
+const (
+	// DialectPostgres PostgreSQL（預設）：可巢狀的 /* */ 註解、$tag$ 字串、E'' 才以反斜線跳脫，反引號不是引用符號
+	DialectPostgres Dialect = iota
+	// DialectMySQL MySQL：# 與「-- 」註解、字串以反斜線跳脫、反引號引用識別字，不接受 /*! */ 與 /*M! */ 可執行註解
+	DialectMySQL
+	// DialectSQLite SQLite：不巢狀的 /* */ 註解，反引號同樣不視為引用符號（保守處理）
+	DialectSQLite
+)
+
+// DialectFor 依 database.type 返回方言，未設定或無法識別時為 PostgreSQL
+func DialectFor(cfg *config.Config) Dialect {
+	if cfg == nil {
+		return DialectPostgres
+	}
+	switch strings.ToLower(cfg.Database.Type) {
+	case "mysql", "mariadb":
+		return DialectMySQL
+	case "sqlite", "sqlite3":
+		return DialectSQLite
+	default:
+		return DialectPostgres
+	}
+}
+
+// tokenize 返回的符號：語句分隔與括號（用於判斷詞是否位於子查詢之外）
+const (
+	statementSeparator = ";"
+	openParen          = "("
+	closeParen         = ")"
+)
+
+// tokenize 略過註解、字串與引用識別字，返回大寫的詞（字母、數字與底線）、分號與括號；
+// 其餘符號不返回。註解或引用未閉合時返回錯誤，避免資料庫與此處對查詢的切分不一致
+func tokenize(query string, dialect Dialect) ([]string, error) {
+	return scanTokens(query, dialect, false)
+}
+
+// scanTokens 同 tokenize；identifiers 為 true 時另外返回引用識別字的內容（保留原大小寫），
+// 這些 token 可能與關鍵字同名，只能用於比對名稱，不能用於關鍵字檢查
+func scanTokens(query string, dialect Dialect, identifiers bool) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(query); {
+		ch := query[i]
+		next := byte(0)
+		if i+1 < len(query) {
+			next = query[i+1]
+		}
+
+		switch {
+		case ch == '-' && next == '-' && (dialect != DialectMySQL || isMySQLCommentStart(query, i+2)):
+			i = skipLine(query, i)
+		case ch == '#' && dialect == DialectMySQL:
+			i = skipLine(query, i)
+		case ch == '/' && next == '*':
+			if dialect == DialectMySQL && i+2 < len(query) && (query[i+2] == '!' || strings.HasPrefix(query[i+2:], "M!")) {
+				return nil, fmt.Errorf("executable comments are not allowed")
+			}
+			end, err := skipBlockComment(query, i, dialect != DialectMySQL)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case ch == '\'':
+			var end int
+			var err error
+			switch {
+			case escapeStringPrefix(query, i, dialect):
+				end, err = skipQuoted(query, i, '\'', true)
+			case dialect == DialectSQLite:
+				end, err = skipQuoted(query, i, '\'', false)
+			default:
+				end, err = skipStringLiteral(query, i, '\'')
+			}
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case ch == '"':
+			var end int
+			var err error
+			if dialect == DialectMySQL {
+				end, err = skipStringLiteral(query, i, '"')
+			} else {
+				end, err = skipQuoted(query, i, '"', false)
+				if err == nil && identifiers {
+					tokens = append(tokens, unquoteIdentifier(query[i:end], '"'))
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case ch == '`' && dialect == DialectMySQL:
+			end, err := skipQuoted(query, i, '`', false)
+			if err != nil {
+				return nil, err
+			}
+			if identifiers {
+				tokens = append(tokens, unquoteIdentifier(query[i:end], '`'))
+			}
+			i = end
+		case ch == '$' && dialect == DialectPostgres && !precededByWord(query, i):
+			end, ok, err := skipDollarQuoted(query, i)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				i++
+				continue
+			}
+			i = end
+		case ch == ';':
+			tokens = append(tokens, statementSeparator)
+			i++
+		case ch == '(':
+			tokens = append(tokens, openParen)
+			i++
+		case ch == ')':
+			tokens = append(tokens, closeParen)
+			i++
+		default:
+			r, size := utf8.DecodeRuneInString(query[i:])
+			if !isWordRune(r) {
+				i += size
+				continue
+			}
+			start := i
+			for i < len(query) {
+				r, size := utf8.DecodeRuneInString(query[i:])
+				if !isWordRune(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, strings.ToUpper(query[start:i]))
+		}
+	}
+	return tokens, nil
+}
+
+// unquoteIdentifier 去除引用識別字兩側的引號，並將重複的引號還原為一個
+func unquoteIdentifier(quoted string, quote byte) string {
+	q := string(quote)
+	return strings.ReplaceAll(quoted[1:len(quoted)-1], q+q, q)
+}
+
+// isWordRune 詞的組成字元：created_at 是一個詞，不會被視為 CREATE
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// isMySQLCommentStart MySQL 的 -- 後面須接空白、控制字元或結尾才是註解（1--1 是減法）
+func isMySQLCommentStart(query string, i int) bool {
+	return i >= len(query) || query[i] <= ' '
+}
+
+// skipLine 返回行尾（換行字元之後）的位置
+func skipLine(query string, i int) int {
+	if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+		return i + end + 1
+	}
+	return len(query)
+}
+
+// skipBlockComment 返回 /* */ 註解結束後的位置；nested 為 true 時依 PostgreSQL 規則計算巢狀層數
+func skipBlockComment(query string, i int, nested bool) (int, error) {
+	depth := 0
+	for i < len(query) {
+		switch {
+		case strings.HasPrefix(query[i:], "/*") && (nested || depth == 0):
+			depth++
+			i += 2
+		case strings.HasPrefix(query[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i, nil
+			}
+		default:
+			i++
+		}
+	}
+	return 0, fmt.Errorf("unterminated comment")
+}
+
+// skipQuoted 返回以 quote 引用的字串或識別字結束後的位置；重複的引號視為跳脫，backslash 為 true 時反斜線也跳脫下一個字元
+func skipQuoted(query string, i int, quote byte, backslash bool) (int, error) {
+	for i++; i < len(query); i++ {
+		switch {
+		case backslash && query[i] == '\\':
+			i++
+		case query[i] == quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted string or identifier")
+}
+
+// skipStringLiteral 處理反斜線是否跳脫取決於伺服器設定的字串（MySQL 的 NO_BACKSLASH_ESCAPES、
+// PostgreSQL 的 standard_conforming_strings）：兩種解讀的結束位置不同時返回錯誤
+func skipStringLiteral(query string, i int, quote byte) (int, error) {
+	plain, plainErr := skipQuoted(query, i, quote, false)
+	escaped, escapedErr := skipQuoted(query, i, quote, true)
+	if plainErr != nil || escapedErr != nil {
+		return 0, fmt.Errorf("unterminated quoted string or identifier")
+	}
+	if plain != escaped {
+		return 0, fmt.Errorf("ambiguous backslash escape in string literal; use a doubled quote instead")
+	}
+	return plain, nil
+}
+
+// escapeStringPrefix PostgreSQL 的 E'...' 字串以反斜線跳脫
+func escapeStringPrefix(query string, i int, dialect Dialect) bool {
+	if dialect != DialectPostgres || i == 0 || (query[i-1] != 'E' && query[i-1] != 'e') {
+		return false
+	}
+	return !precededByWord(query, i-1)
+}
+
+// precededByWord i 之前的字元是否為詞的一部分（包含 PostgreSQL 識別字中的 $）
+func precededByWord(query string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(query[:i])
+	return isWordRune(r) || r == '$'
+}
+
+// skipDollarQuoted 處理 PostgreSQL 的 $tag$...$tag$ 字串；$1 等參數不是字串，返回 ok 為 false
+func skipDollarQuoted(query string, i int) (int, bool, error) {
+	end := i + 1
+	for end < len(query) && query[end] != '$' {
+		r, size := utf8.DecodeRuneInString(query[end:])
+		if !isWordRune(r) || (end == i+1 && unicode.IsDigit(r)) {
+			return 0, false, nil
+		}
+		end += size
+	}
+	if end >= len(query) {
+		return 0, false, nil
+	}
+
+	tag := query[i : end+1]
+	closing := strings.Index(query[end+1:], tag)
+	if closing < 0 {
+		return 0, false, fmt.Errorf("unterminated dollar-quoted string")
+	}
+	return end + 1 + closing + len(tag), true, nil
+}
@@ -0,0 +1,83 @@
+// Package types 定義各 phase 共用的知識與分析資料結構
+// 所有 phase 讀寫 knowledge/*.json 時都應使用這裡的定義，避免各自宣告造成欄位不一致
+package types
+
+import (
+	"time"
+
+	"github.com/masato25/aika-dba/pkg/llm"
+)
+
+// Phase1Result Phase 1 的分析結果（knowledge/phase1_analysis.json）
+type Phase1Result struct {
+	SchemaVersion  int                            `json:"schema_version"`
+	Database       string                         `json:"database"`
+	DatabaseType   string                         `json:"database_type"`
+	Timestamp      string                         `json:"timestamp"`
+	TablesCount    int                            `json:"tables_count"`
+	SchemaLanguage string                         `json:"schema_language"`
+	Tables         map[string]TableAnalysisResult `json:"tables"`
+}
+
+// TableAnalysisResult 單個表格的 Phase 1 分析結果
+type TableAnalysisResult struct {
+	Schema      []map[string]interface{} `json:"schema"`
+	Constraints map[string]interface{}   `json:"constraints"`
+	Indexes     []map[string]interface{} `json:"indexes"`
+	Samples     []map[string]interface{} `json:"samples"`
+	Stats       map[string]interface{}   `json:"stats"`
+	Sampling    map[string]interface{}   `json:"sampling,omitempty"` // 實際取樣方式與信心程度（adaptive_sampling 啟用時）
+}
+
+// LLMAnalysisResult 單個表格的 Phase 2 LLM 分析結果
+type LLMAnalysisResult struct {
+	TableName     string    `json:"table_name"`
+	Analysis      string    `json:"analysis"`
+	Timestamp     time.Time `json:"timestamp"`
+	HumanOverride bool      `json:"human_override,omitempty"` // 分析內容來自人工修正
+	// ColumnDescriptions 欄位名稱 -> 描述（有結構化欄位描述時提供）
+	ColumnDescriptions map[string]string `json:"column_descriptions,omitempty"`
+	// LLMStatus LLM 請求失敗、分析內容為後備結果時記錄原因
+	LLMStatus *llm.Status `json:"llm_status,omitempty"`
+}
+
+// Phase2AnalysisResult Phase 2 的分析結果（knowledge/phase2_analysis.json）
+type Phase2AnalysisResult struct {
+	Phase           string                       `json:"phase"`
+	Description     string                       `json:"description"`
+	Database        string                       `json:"database"`
+	DatabaseType    string                       `json:"database_type"`
+	Timestamp       string                       `json:"timestamp"`
+	AnalysisResults map[string]LLMAnalysisResult `json:"analysis_results"`
+	Summary         Phase2Summary                `json:"summary"`
+	LLMStatus       *llm.Status                  `json:"llm_status,omitempty"`
+}
+
+// Phase2Summary Phase 2 分析摘要
+type Phase2Summary struct {
+	AnalysisTimestamp   string `json:"analysis_timestamp"`
+	Description         string `json:"description"`
+	Phase               string `json:"phase"`
+	TotalTablesAnalyzed int    `json:"total_tables_analyzed"`
+}
+
+// Dimension 維度定義
+type Dimension struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"` // people, time, product, event, location
+	Description string   `json:"description"`
+	SourceTable string   `json:"source_table"`
+	KeyFields   []string `json:"key_fields"`
+	Attributes  []string `json:"attributes"`
+	BusinessUse string   `json:"business_use"`
+	Warning     string   `json:"warning,omitempty"`
+}
+
+// FactTable 事實表定義
+type FactTable struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	SourceTable string   `json:"source_table"`
+	Measures    []string `json:"measures"`
+	Dimensions  []string `json:"dimensions"`
+}
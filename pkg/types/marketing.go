@@ -0,0 +1,34 @@
+package types
+
+import "time"
+
+// MarketingQueryResult 營銷查詢結果；CLI 的 -json 輸出與 POST /api/marketing/query 回應都直接序列化此結構
+// JSON 欄位名稱屬於對外介面，新增欄位時保持既有名稱不變
+type MarketingQueryResult struct {
+	// Query 使用者輸入的自然語言問題（已去除 A:/Q:/P: 模式前綴）
+	Query string `json:"query"`
+	// Mode 實際使用的查詢模式：analysis、raw 或 preview（不執行 SQL）
+	Mode string `json:"mode"`
+	// SQLQuery LLM 生成並通過安全檢查的 SQL，生成失敗時為空；preview 模式下未通過檢查的 SQL 也會返回，原因見 Warnings
+	SQLQuery string `json:"sql_query,omitempty"`
+	// Explanation SQL 的生成說明
+	Explanation string `json:"explanation"`
+	// Warnings preview 模式下 SQL 安全檢查的結果，有值時該 SQL 不會被執行
+	Warnings []string `json:"warnings,omitempty"`
+	// Results 查詢結果，最多 marketing.result_limit 行
+	Results []map[string]interface{} `json:"results,omitempty"`
+	// RowCount 返回的結果行數
+	RowCount int `json:"row_count"`
+	// TotalRows 查詢實際符合的總行數（可能大於 Results 的行數）；截斷後無法以 COUNT(*) 取得時為 0
+	TotalRows int `json:"total_rows"`
+	// Truncated 結果因 marketing.result_limit 被截斷，即查詢還有更多行
+	Truncated bool `json:"truncated"`
+	// BusinessInsights 依結果生成的業務洞察，沒有結果時為空
+	BusinessInsights string `json:"business_insights,omitempty"`
+	// EstimatedCost 本次查詢 LLM 呼叫的估算成本（依 llm.prompt_price_per_1k / completion_price_per_1k）
+	EstimatedCost float64 `json:"estimated_cost"`
+	// Timestamp 查詢開始時間
+	Timestamp time.Time `json:"timestamp"`
+	// Error 查詢失敗的原因；有值時其他結果欄位可能不完整
+	Error string `json:"error,omitempty"`
+}
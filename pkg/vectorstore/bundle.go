@@ -0,0 +1,282 @@
+package vectorstore
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 知識包內的檔案位置
+const (
+	bundleManifestName = "manifest.json"
+	bundleChunksName   = "chunks.json"
+	bundleKnowledgeDir = "knowledge/"
+	bundleVersion      = 1
+)
+
+// BundleManifest 知識包的說明：匯出時的嵌入設定與塊數，匯入時據此判斷向量能否直接使用
+type BundleManifest struct {
+	Version            int            `json:"version"`
+	CreatedAt          time.Time      `json:"created_at"`
+	Database           string         `json:"database"`
+	DatabaseType       string         `json:"database_type"`
+	Backend            string         `json:"backend"`
+	EmbedderType       string         `json:"embedder_type"`
+	EmbeddingDimension int            `json:"embedding_dimension"`
+	TotalChunks        int            `json:"total_chunks"`
+	PhaseChunks        map[string]int `json:"phase_chunks"`
+	Files              []string       `json:"files"`
+}
+
+// ExportBundle 將知識目錄中的 phase 結果（*.json、*.sql）、所有向量塊與 manifest 打包為單一 zip 檔，
+// 方便把完成的分析交給其他人使用
+func (km *KnowledgeManager) ExportBundle(bundlePath string) error {
+	chunks, err := km.vectorStore.GetAllChunks()
+	if err != nil {
+		return fmt.Errorf("failed to read vector chunks: %v", err)
+	}
+
+	files, err := bundleKnowledgeFiles(km.config.KnowledgeDir())
+	if err != nil {
+		return err
+	}
+
+	manifest := BundleManifest{
+		Version:            bundleVersion,
+		CreatedAt:          time.Now(),
+		Database:           km.config.Database.DBName,
+		DatabaseType:       km.config.Database.Type,
+		Backend:            km.config.VectorStore.Backend,
+		EmbedderType:       km.config.VectorStore.EmbedderType,
+		EmbeddingDimension: km.dimension,
+		TotalChunks:        len(chunks),
+		PhaseChunks:        chunkCountsByPhase(chunks),
+		Files:              files,
+	}
+
+	if dir := filepath.Dir(bundlePath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %v", err)
+		}
+	}
+	file, err := os.Create(bundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %v", err)
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	if err := writeBundleJSON(archive, bundleManifestName, manifest); err != nil {
+		return err
+	}
+	if err := writeBundleJSON(archive, bundleChunksName, chunks); err != nil {
+		return err
+	}
+	for _, name := range files {
+		if err := copyFileToBundle(archive, bundleKnowledgeDir+name, km.config.KnowledgePath(name)); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish bundle: %v", err)
+	}
+
+	log.Printf("Exported %d knowledge files and %d chunks to %s", len(files), len(chunks), bundlePath)
+	return nil
+}
+
+// ImportBundle 將知識包的 phase 結果寫入知識目錄，並把向量塊存入目前配置的向量存儲（覆蓋同 phase 的塊）
+// 嵌入類型或維度與匯出時不同時，以目前的嵌入生成器重新生成向量，否則直接使用包內的向量
+func (km *KnowledgeManager) ImportBundle(bundlePath string) (*BundleManifest, error) {
+	archive, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %v", err)
+	}
+	defer archive.Close()
+
+	var manifest BundleManifest
+	var chunks []VectorChunk
+	foundManifest := false
+	for _, entry := range archive.File {
+		switch {
+		case entry.Name == bundleManifestName:
+			if err := readBundleJSON(entry, &manifest); err != nil {
+				return nil, err
+			}
+			foundManifest = true
+		case entry.Name == bundleChunksName:
+			if err := readBundleJSON(entry, &chunks); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(entry.Name, bundleKnowledgeDir):
+			if err := extractBundleFile(entry, km.config.KnowledgeDir()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !foundManifest {
+		return nil, fmt.Errorf("%s is not a knowledge bundle: %s missing", bundlePath, bundleManifestName)
+	}
+
+	if manifest.EmbedderType != km.config.VectorStore.EmbedderType || manifest.EmbeddingDimension != km.dimension {
+		log.Printf("Bundle embedder %s (dimension %d) differs from %s (dimension %d), re-embedding %d chunks",
+			manifest.EmbedderType, manifest.EmbeddingDimension,
+			km.config.VectorStore.EmbedderType, km.dimension, len(chunks))
+		chunks = km.reembedChunks(chunks)
+	}
+
+	if err := km.storeBundleChunks(chunks); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Imported %d knowledge files and %d chunks from %s", len(manifest.Files), len(chunks), bundlePath)
+	return &manifest, nil
+}
+
+// bundleKnowledgeFiles 列出知識目錄最上層的 phase 結果檔（*.json、*.sql），不含子目錄與暫存檔
+func bundleKnowledgeFiles(knowledgeDir string) ([]string, error) {
+	entries, err := os.ReadDir(knowledgeDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read knowledge directory: %v", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || (filepath.Ext(name) != ".json" && filepath.Ext(name) != ".sql") {
+			continue
+		}
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// chunkCountsByPhase 依 metadata 的 phase 統計塊數
+func chunkCountsByPhase(chunks []VectorChunk) map[string]int {
+	counts := make(map[string]int)
+	for _, chunk := range chunks {
+		if phase, ok := chunk.Metadata["phase"].(string); ok {
+			counts[phase]++
+		}
+	}
+	return counts
+}
+
+// reembedChunks 以目前的嵌入生成器重新生成向量，保留原本的內容與 metadata
+func (km *KnowledgeManager) reembedChunks(chunks []VectorChunk) []VectorChunk {
+	knowledgeChunks := make([]KnowledgeChunk, len(chunks))
+	for i, chunk := range chunks {
+		knowledgeChunks[i] = KnowledgeChunk{Content: chunk.Content, Metadata: chunk.Metadata}
+	}
+	return km.embedChunks(knowledgeChunks, nil)
+}
+
+// storeBundleChunks 依 phase 替換向量存儲中的塊；沒有 phase 的塊直接附加
+func (km *KnowledgeManager) storeBundleChunks(chunks []VectorChunk) error {
+	byPhase := make(map[string][]VectorChunk)
+	var unphased []VectorChunk
+	for _, chunk := range chunks {
+		chunk.ID = 0
+		if phase, ok := chunk.Metadata["phase"].(string); ok {
+			byPhase[phase] = append(byPhase[phase], chunk)
+		} else {
+			unphased = append(unphased, chunk)
+		}
+	}
+
+	for phase, phaseChunks := range byPhase {
+		if err := km.vectorStore.ReplaceChunks("phase", phase, phaseChunks); err != nil {
+			return fmt.Errorf("failed to store chunks for phase %s: %v", phase, err)
+		}
+	}
+	if len(unphased) > 0 {
+		if err := km.vectorStore.AddChunks(unphased); err != nil {
+			return fmt.Errorf("failed to store chunks: %v", err)
+		}
+	}
+	return nil
+}
+
+// writeBundleJSON 將 data 以 JSON 寫入 zip 中的 name
+func writeBundleJSON(archive *zip.Writer, name string, data interface{}) error {
+	writer, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to bundle: %v", name, err)
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return fmt.Errorf("failed to write %s to bundle: %v", name, err)
+	}
+	return nil
+}
+
+// copyFileToBundle 將檔案複製到 zip 中的 name
+func copyFileToBundle(archive *zip.Writer, name, filePath string) error {
+	source, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", filePath, err)
+	}
+	defer source.Close()
+
+	writer, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to bundle: %v", name, err)
+	}
+	if _, err := io.Copy(writer, source); err != nil {
+		return fmt.Errorf("failed to write %s to bundle: %v", name, err)
+	}
+	return nil
+}
+
+// readBundleJSON 解析 zip 中的 JSON 檔案
+func readBundleJSON(entry *zip.File, target interface{}) error {
+	reader, err := entry.Open()
+	if err != nil {
+		return fmt.Errorf("failed to read %s from bundle: %v", entry.Name, err)
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(target); err != nil {
+		return fmt.Errorf("failed to parse %s from bundle: %v", entry.Name, err)
+	}
+	return nil
+}
+
+// extractBundleFile 將 knowledge/ 下的檔案寫入知識目錄；只接受最上層檔名，避免寫到知識目錄以外
+func extractBundleFile(entry *zip.File, knowledgeDir string) error {
+	name := strings.TrimPrefix(entry.Name, bundleKnowledgeDir)
+	if name == "" || name != path.Base(name) || name == ".." {
+		log.Printf("Warning: Skipping bundle entry %s", entry.Name)
+		return nil
+	}
+
+	reader, err := entry.Open()
+	if err != nil {
+		return fmt.Errorf("failed to read %s from bundle: %v", entry.Name, err)
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(knowledgeDir, 0755); err != nil {
+		return fmt.Errorf("failed to create knowledge directory: %v", err)
+	}
+	target, err := os.Create(filepath.Join(knowledgeDir, name))
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	defer target.Close()
+
+	if _, err := io.Copy(target, reader); err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	return nil
+}
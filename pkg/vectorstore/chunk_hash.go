@@ -0,0 +1,60 @@
+package vectorstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// ContentHashKey 元數據中存放塊內容雜湊的鍵，作為寫入時的冪等鍵
+const ContentHashKey = "content_hash"
+
+// volatileMetadataKeys 每次執行都會變動、不參與雜湊的元數據
+var volatileMetadataKeys = map[string]bool{
+	"timestamp":    true,
+	ContentHashKey: true,
+}
+
+// ChunkContentHash 以內容與元數據（排除 timestamp 等易變欄位）計算塊的雜湊
+// 內容與元數據都相同的塊會得到相同的雜湊，重新執行 phase 時不會重複寫入
+func ChunkContentHash(content string, metadata map[string]interface{}) string {
+	stable := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		if !volatileMetadataKeys[key] {
+			stable[key] = value
+		}
+	}
+	// encoding/json 依鍵排序輸出 map，序列化結果穩定
+	metadataJSON, _ := json.Marshal(stable)
+
+	hash := sha256.New()
+	hash.Write([]byte(content))
+	hash.Write([]byte{0})
+	hash.Write(metadataJSON)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// hashChunks 為每個塊計算雜湊並寫入元數據副本，同一批次中重複的塊只保留第一個
+func hashChunks(chunks []VectorChunk) ([]VectorChunk, []string) {
+	hashed := make([]VectorChunk, 0, len(chunks))
+	hashes := make([]string, 0, len(chunks))
+	seen := make(map[string]bool, len(chunks))
+	for _, chunk := range chunks {
+		hash := ChunkContentHash(chunk.Content, chunk.Metadata)
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		metadata := make(map[string]interface{}, len(chunk.Metadata)+1)
+		for key, value := range chunk.Metadata {
+			metadata[key] = value
+		}
+		metadata[ContentHashKey] = hash
+		chunk.Metadata = metadata
+
+		hashed = append(hashed, chunk)
+		hashes = append(hashes, hash)
+	}
+	return hashed, hashes
+}
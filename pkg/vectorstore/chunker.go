@@ -113,12 +113,9 @@ func (kc *KnowledgeChunker) chunkText(text, source string) []KnowledgeChunk {
 		// 如果添加這行會超過塊大小，保存當前塊
 		if lineCount+1 > kc.chunkSize && currentChunk != "" {
 			chunk := KnowledgeChunk{
-				Content: strings.TrimSpace(currentChunk),
-				Metadata: map[string]interface{}{
-					"source": source,
-					"type":   "knowledge_chunk",
-				},
-				Source: source,
+				Content:  strings.TrimSpace(currentChunk),
+				Metadata: kc.chunkMetadata(source),
+				Source:   source,
 			}
 			if chunk.Content != "" {
 				chunks = append(chunks, chunk)
@@ -140,12 +137,9 @@ func (kc *KnowledgeChunker) chunkText(text, source string) []KnowledgeChunk {
 	// 添加最後一個塊
 	if currentChunk != "" {
 		chunk := KnowledgeChunk{
-			Content: strings.TrimSpace(currentChunk),
-			Metadata: map[string]interface{}{
-				"source": source,
-				"type":   "knowledge_chunk",
-			},
-			Source: source,
+			Content:  strings.TrimSpace(currentChunk),
+			Metadata: kc.chunkMetadata(source),
+			Source:   source,
 		}
 		if chunk.Content != "" {
 			chunks = append(chunks, chunk)
@@ -155,6 +149,16 @@ func (kc *KnowledgeChunker) chunkText(text, source string) []KnowledgeChunk {
 	return chunks
 }
 
+// chunkMetadata 返回文本塊的元數據，記錄實際使用的塊大小與重疊（行數）
+func (kc *KnowledgeChunker) chunkMetadata(source string) map[string]interface{} {
+	return map[string]interface{}{
+		"source":        source,
+		"type":          "knowledge_chunk",
+		"chunk_size":    kc.chunkSize,
+		"chunk_overlap": kc.chunkOverlap,
+	}
+}
+
 // getOverlapLines 獲取重疊行
 func (kc *KnowledgeChunker) getOverlapLines(text string) string {
 	lines := strings.Split(text, "\n")
@@ -237,7 +241,11 @@ func (kc *KnowledgeChunker) chunkTables(data map[string]interface{}, source stri
 					colName := colMap["name"]
 					colType := colMap["type"]
 					colNullable := colMap["nullable"]
-					content.WriteString(fmt.Sprintf("  - %s (%v, nullable: %v)\n", colName, colType, colNullable))
+					content.WriteString(fmt.Sprintf("  - %s (%v, nullable: %v)", colName, colType, colNullable))
+					if description, ok := colMap["description"].(string); ok && description != "" {
+						content.WriteString(fmt.Sprintf(" -- %s", description))
+					}
+					content.WriteString("\n")
 				}
 			}
 		}
@@ -252,6 +260,10 @@ func (kc *KnowledgeChunker) chunkTables(data map[string]interface{}, source stri
 				content.WriteString("  Foreign Keys:\n")
 				for _, fkInterface := range fks {
 					if fkMap, ok := fkInterface.(map[string]interface{}); ok {
+						if columns, ok := fkMap["columns"].([]interface{}); ok && len(columns) > 1 {
+							content.WriteString(fmt.Sprintf("    - %v -> %s%v\n", columns, fkMap["referenced_table"], fkMap["referenced_columns"]))
+							continue
+						}
 						content.WriteString(fmt.Sprintf("    - %s -> %s.%s\n", fkMap["column"], fkMap["referenced_table"], fkMap["referenced_column"]))
 					}
 				}
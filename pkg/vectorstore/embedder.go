@@ -6,15 +6,37 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
 	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Embedder 嵌入生成器接口
 type Embedder interface {
 	GenerateEmbedding(text string) ([]float64, error)
+	// GenerateEmbeddings 批次生成嵌入，返回的向量與輸入順序一致；
+	// 個別文本失敗時對應位置為 nil，整批失敗時返回錯誤
+	GenerateEmbeddings(texts []string) ([][]float64, error)
+}
+
+// maxEmbeddingBatch 單次嵌入 API 請求的最大輸入數量
+const maxEmbeddingBatch = 256
+
+// generateEach 逐一生成嵌入，供沒有批次 API 的嵌入生成器實作 GenerateEmbeddings
+func generateEach(generate func(text string) ([]float64, error), texts []string) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vector, err := generate(text)
+		if err != nil {
+			return nil, err
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
 }
 
 // SimpleHashEmbedder 簡單的哈希嵌入生成器
@@ -67,30 +89,115 @@ func (e *SimpleHashEmbedder) GenerateEmbedding(text string) ([]float64, error) {
 	return vector, nil
 }
 
+// GenerateEmbeddings 批次生成基於哈希的嵌入向量
+func (e *SimpleHashEmbedder) GenerateEmbeddings(texts []string) ([][]float64, error) {
+	return generateEach(e.GenerateEmbedding, texts)
+}
+
+// DimensionDetector 可從實際嵌入回應偵測向量維度的嵌入生成器
+type DimensionDetector interface {
+	DetectDimension() (int, error)
+}
+
 // LLMEmbedder 使用LLM服務生成嵌入
 type LLMEmbedder struct {
-	host   string
-	port   int
-	model  string
-	client *http.Client
+	host      string
+	port      int
+	model     string
+	client    *http.Client
+	dimension int  // 向量維度（偵測成功後以實際回應為準）
+	useAPI    bool // 嵌入 API 可用時使用實際嵌入
+	mu        sync.Mutex
+
+	// 速率限制處理：收到 429/503 時所有並行請求共同等待到 backoffUntil
+	maxRetries   int
+	backoffUntil time.Time
+
+	// 維度偵測結果（DetectDimension 只探測一次）
+	detectMu   sync.Mutex
+	detected   bool
+	detectErr  error
+	detectedAt time.Time
 }
 
-// NewLLMEmbedder 創建LLM嵌入生成器
-func NewLLMEmbedder(host string, port int, model string) *LLMEmbedder {
+// NewLLMEmbedder 創建LLM嵌入生成器，dimension 為配置的維度（<= 0 時預設 384）
+func NewLLMEmbedder(host string, port int, model string, dimension int) *LLMEmbedder {
+	if dimension <= 0 {
+		dimension = 384
+	}
 	return &LLMEmbedder{
-		host:   host,
-		port:   port,
-		model:  model,
-		client: &http.Client{},
+		host:       host,
+		port:       port,
+		model:      model,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		dimension:  dimension,
+		maxRetries: 3,
+	}
+}
+
+// Dimension 返回目前使用的向量維度
+func (e *LLMEmbedder) Dimension() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.dimension
+}
+
+// dimensionProbeRetry 維度探測失敗後，至少間隔此時間才再次探測
+const dimensionProbeRetry = time.Minute
+
+// DetectDimension 以探測文字呼叫嵌入 API，依實際回應的長度設定向量維度
+// 每個實例只在第一次成功時探測；失敗後 dimensionProbeRetry 內直接返回上次的錯誤，不重複發出請求
+func (e *LLMEmbedder) DetectDimension() (int, error) {
+	e.detectMu.Lock()
+	defer e.detectMu.Unlock()
+
+	if e.detected {
+		return e.Dimension(), nil
+	}
+	if e.detectErr != nil && time.Since(e.detectedAt) < dimensionProbeRetry {
+		return 0, e.detectErr
+	}
+
+	_, err := e.GenerateEmbeddingWithLLM("dimension probe")
+	e.detectedAt = time.Now()
+	if err != nil {
+		e.detectErr = err
+		log.Printf("Warning: Failed to detect embedding dimension for %s, using local vectors with dimension %d: %v", e.model, e.Dimension(), err)
+		return 0, err
 	}
+
+	e.mu.Lock()
+	e.useAPI = true
+	dimension := e.dimension
+	e.mu.Unlock()
+
+	e.detected, e.detectErr = true, nil
+	return dimension, nil
+}
+
+// VectorSource 返回目前向量的來源：嵌入 API 可用時為 llm，否則為本地統計向量 llm_local（兩者不可互相比較）
+func (e *LLMEmbedder) VectorSource() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.useAPI {
+		return "llm"
+	}
+	return "llm_local"
 }
 
 // GenerateEmbedding 使用LLM生成嵌入向量
 func (e *LLMEmbedder) GenerateEmbedding(text string) ([]float64, error) {
-	// 對於簡單實現，我們使用文本長度和字符頻率來生成向量
-	// 在實際應用中，這裡應該調用LLM的嵌入API
+	e.mu.Lock()
+	useAPI := e.useAPI
+	dimension := e.dimension
+	e.mu.Unlock()
 
-	vector := make([]float64, 384) // 使用384維向量
+	if useAPI {
+		return e.GenerateEmbeddingWithLLM(text)
+	}
+
+	// 嵌入 API 不可用時，使用文本長度和字符頻率來生成向量
+	vector := make([]float64, dimension)
 
 	// 基於文本統計的簡單嵌入
 	vector[0] = float64(len(text)) / 1000.0                            // 文本長度
@@ -132,16 +239,57 @@ func (e *LLMEmbedder) GenerateEmbedding(text string) ([]float64, error) {
 	return vector, nil
 }
 
-// GenerateEmbeddingWithLLM 使用LLM API生成嵌入（未實現，需要具體的嵌入API）
+// GenerateEmbeddings 批次生成嵌入：嵌入 API 可用時每次請求送出最多 maxEmbeddingBatch 筆輸入，否則逐一使用本地嵌入
+func (e *LLMEmbedder) GenerateEmbeddings(texts []string) ([][]float64, error) {
+	e.mu.Lock()
+	useAPI := e.useAPI
+	e.mu.Unlock()
+
+	if !useAPI {
+		return generateEach(e.GenerateEmbedding, texts)
+	}
+
+	vectors := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += maxEmbeddingBatch {
+		end := start + maxEmbeddingBatch
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		batch, err := e.requestEmbeddings(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// GenerateEmbeddingWithLLM 使用LLM API生成嵌入（OpenAI 相容的 /v1/embeddings 端點）
 func (e *LLMEmbedder) GenerateEmbeddingWithLLM(text string) ([]float64, error) {
-	// 這裡應該實現對LLM嵌入API的調用
-	// 例如：OpenAI的embeddings API或其他本地LLM的嵌入端點
+	vectors, err := e.requestEmbeddings(text)
+	if err != nil {
+		return nil, err
+	}
+	if vectors[0] == nil {
+		return nil, fmt.Errorf("no embedding found in response")
+	}
+	return vectors[0], nil
+}
 
+// requestEmbeddings 呼叫嵌入 API，input 可為單一字串或字串陣列；
+// 返回的向量依回應中的 index 對應輸入順序，缺少的項目為 nil
+func (e *LLMEmbedder) requestEmbeddings(input interface{}) ([][]float64, error) {
 	ctx := context.Background()
 
+	inputCount := 1
+	if texts, ok := input.([]string); ok {
+		inputCount = len(texts)
+	}
+
 	requestBody := map[string]interface{}{
 		"model": e.model,
-		"input": text,
+		"input": input,
 	}
 
 	jsonData, err := json.Marshal(requestBody)
@@ -150,16 +298,33 @@ func (e *LLMEmbedder) GenerateEmbeddingWithLLM(text string) ([]float64, error) {
 	}
 
 	url := fmt.Sprintf("http://%s:%d/v1/embeddings", e.host, e.port)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
 
-	req.Header.Set("Content-Type", "application/json")
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		e.waitForBackoff()
 
-	resp, err := e.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
+		req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err = e.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %v", err)
+		}
+
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+			break
+		}
+		resp.Body.Close()
+
+		if attempt >= e.maxRetries {
+			return nil, fmt.Errorf("embedding API rate limited (status %d) after %d retries", resp.StatusCode, attempt)
+		}
+		delay := retryDelay(resp.Header.Get("Retry-After"), attempt)
+		log.Printf("Warning: Embedding API returned status %d, backing off for %v", resp.StatusCode, delay)
+		e.setBackoff(delay)
 	}
 	defer resp.Body.Close()
 
@@ -173,30 +338,98 @@ func (e *LLMEmbedder) GenerateEmbeddingWithLLM(text string) ([]float64, error) {
 	}
 
 	// 解析響應中的嵌入向量
-	// 這裡需要根據具體的API響應格式來調整
 	data, ok := response["data"].([]interface{})
 	if !ok || len(data) == 0 {
 		return nil, fmt.Errorf("invalid embedding response format")
 	}
 
-	embeddingData, ok := data[0].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid embedding data format")
+	vectors := make([][]float64, inputCount)
+	for position, item := range data {
+		embeddingData, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid embedding data format")
+		}
+
+		index := position
+		if value, ok := embeddingData["index"].(float64); ok {
+			index = int(value)
+		}
+		if index < 0 || index >= inputCount {
+			continue
+		}
+
+		embedding, ok := embeddingData["embedding"].([]interface{})
+		if !ok {
+			continue
+		}
+
+		vector := make([]float64, len(embedding))
+		for i, v := range embedding {
+			if val, ok := v.(float64); ok {
+				vector[i] = val
+			}
+		}
+
+		if err := e.reconcileDimension(len(vector)); err != nil {
+			return nil, err
+		}
+		vectors[index] = vector
+	}
+
+	return vectors, nil
+}
+
+// waitForBackoff 若先前的請求觸發速率限制，等待到共同的退避時間結束
+func (e *LLMEmbedder) waitForBackoff() {
+	e.mu.Lock()
+	wait := time.Until(e.backoffUntil)
+	e.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
 	}
+}
 
-	embedding, ok := embeddingData["embedding"].([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("no embedding found in response")
+// setBackoff 設定共同的退避時間，讓所有並行請求一起暫停，避免連續觸發 429
+func (e *LLMEmbedder) setBackoff(delay time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if until := time.Now().Add(delay); until.After(e.backoffUntil) {
+		e.backoffUntil = until
 	}
+}
 
-	vector := make([]float64, len(embedding))
-	for i, v := range embedding {
-		if val, ok := v.(float64); ok {
-			vector[i] = val
+// retryDelay 依 Retry-After 標頭（秒數）決定等待時間，否則使用指數退避（1s、2s、4s...，最多 30s）
+func retryDelay(retryAfter string, attempt int) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(retryAfter)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	delay := time.Second << uint(attempt)
+	if delay > 30*time.Second {
+		delay = 30 * time.Second
+	}
+	return delay
+}
+
+// reconcileDimension 以第一次實際回應的長度為準，之後長度不一致時返回錯誤
+func (e *LLMEmbedder) reconcileDimension(actual int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.useAPI {
+		if actual != e.dimension {
+			return fmt.Errorf("embedding dimension changed from %d to %d", e.dimension, actual)
 		}
+		return nil
 	}
 
-	return vector, nil
+	if actual != e.dimension {
+		log.Printf("Warning: Configured embedding dimension %d does not match model %s (%d), using %d", e.dimension, e.model, actual, actual)
+		e.dimension = actual
+	}
+	return nil
 }
 
 // QwenEmbedder 使用改進的文本嵌入生成器
@@ -303,6 +536,11 @@ func (qe *QwenEmbedder) GenerateEmbedding(text string) ([]float64, error) {
 	return vector, nil
 }
 
+// GenerateEmbeddings 批次生成改進的文本嵌入
+func (qe *QwenEmbedder) GenerateEmbeddings(texts []string) ([][]float64, error) {
+	return generateEach(qe.GenerateEmbedding, texts)
+}
+
 // addTextFeatures 添加文本統計特徵
 func (qe *QwenEmbedder) addTextFeatures(vector []float64, text string, words []string) []float64 {
 	// 如果向量不夠大，返回原向量
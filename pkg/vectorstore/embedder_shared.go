@@ -0,0 +1,137 @@
+package vectorstore
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// sharedEmbedders 程序內共用的 llm 嵌入生成器（以 sharedEmbedderKey 區分）：
+// KnowledgeManager 會在每個 HTTP 請求、phase 與排程中建立，共用後維度偵測、速率限制退避與退路警告只發生一次
+var (
+	sharedEmbeddersMu sync.Mutex
+	sharedEmbedders   = map[string]Embedder{}
+)
+
+// checkedIndexes 已檢查過與目前嵌入生成器是否相容的向量存儲（每個程序只檢查一次）
+var checkedIndexes sync.Map
+
+// sharedEmbedderKey 返回可共用的嵌入生成器鍵值；只有呼叫外部 API 的內建 llm 類型需要共用
+func sharedEmbedderKey(cfg *config.Config) (string, bool) {
+	if _, bound := boundEmbedder(cfg); bound {
+		return "", false
+	}
+	if _, registered := registeredEmbedder(cfg.VectorStore.EmbedderType); registered || cfg.VectorStore.EmbedderType != "llm" {
+		return "", false
+	}
+	return fmt.Sprintf("%s:%d/%s/%d/%d/%v", cfg.LLM.Host, cfg.LLM.Port, cfg.LLM.Model,
+		cfg.VectorStore.EmbeddingDimension, cfg.VectorStore.EmbeddingMaxRetries, cfg.VectorStore.DisableEmbeddingFallback), true
+}
+
+// prepareEmbedder 返回 KnowledgeManager 使用的嵌入生成器與實際向量維度，不修改 cfg
+// 維度以主要嵌入生成器偵測到的值為準，偵測失敗時使用配置的 embedding_dimension
+func prepareEmbedder(cfg *config.Config) (Embedder, int, error) {
+	embedder, err := sharedEmbedder(cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	primary := embedder
+	if fallback, ok := embedder.(*FallbackEmbedder); ok {
+		primary = fallback.Primary()
+	}
+
+	// 從實際嵌入回應偵測維度，與配置不一致時以實際值為準（LLMEmbedder 只在第一次呼叫時探測）
+	dimension := cfg.VectorStore.EmbeddingDimension
+	if detector, ok := primary.(DimensionDetector); ok {
+		if detected, err := detector.DetectDimension(); err == nil {
+			dimension = detected
+		}
+	}
+	return embedder, dimension, nil
+}
+
+// sharedEmbedder 返回可共用的嵌入生成器（第一次呼叫時建立並保存），不可共用的類型每次重新建立
+func sharedEmbedder(cfg *config.Config) (Embedder, error) {
+	key, shared := sharedEmbedderKey(cfg)
+	if !shared {
+		return buildEmbedder(cfg)
+	}
+
+	sharedEmbeddersMu.Lock()
+	defer sharedEmbeddersMu.Unlock()
+	if embedder, ok := sharedEmbedders[key]; ok {
+		return embedder, nil
+	}
+	embedder, err := buildEmbedder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sharedEmbedders[key] = embedder
+	return embedder, nil
+}
+
+// buildEmbedder 依配置建立嵌入生成器，並在需要時包上哈希嵌入退路
+func buildEmbedder(cfg *config.Config) (Embedder, error) {
+	primary, err := newEmbedder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// 嵌入 API 失敗時改用哈希嵌入，避免 phase 只被部分索引（disable_embedding_fallback 可關閉）
+	if !cfg.VectorStore.DisableEmbeddingFallback && embedderName(cfg) != FallbackEmbedderName {
+		return NewFallbackEmbedder(primary, embedderName(cfg), cfg.VectorStore.EmbeddingDimension), nil
+	}
+	return primary, nil
+}
+
+// vectorSource 返回嵌入生成器目前產生的向量來源名稱（記錄於塊 metadata 的 embedder）
+func vectorSource(embedder Embedder, cfg *config.Config) string {
+	if source, ok := embedder.(interface{ VectorSource() string }); ok {
+		return source.VectorSource()
+	}
+	return embedderName(cfg)
+}
+
+// storeKey 識別向量存儲的位置
+func storeKey(cfg *config.Config) string {
+	vs := cfg.VectorStore
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%s", vs.Backend, vs.DatabasePath, vs.PGVectorDSN, vs.PGVectorTable, vs.QdrantURL, vs.QdrantCollection)
+}
+
+// checkIndexCompatibility 以一個既有的塊檢查向量存儲是否由不同維度或不同來源的向量建立
+// （例如嵌入 API 先前不可用時以 llm_local 建立的索引），不相容時警告需重新索引；每個程序對每個存儲只檢查一次
+func (km *KnowledgeManager) checkIndexCompatibility() {
+	// 維度未知時無法建立探測向量
+	if km.config.VectorStore.Backend == "memory" || km.dimension <= 0 {
+		return
+	}
+	source := vectorSource(km.embedder, km.config)
+	key := fmt.Sprintf("%s|%s|%d", storeKey(km.config), source, km.dimension)
+	if _, checked := checkedIndexes.LoadOrStore(key, true); checked {
+		return
+	}
+
+	probe := make([]float64, km.dimension)
+	probe[0] = 1
+	chunks, err := km.vectorStore.SearchSimilar(probe, 1)
+	if err != nil {
+		log.Printf("Warning: Failed to check existing vector index against %s embeddings (dimension %d): %v", source, km.dimension, err)
+		return
+	}
+	if len(chunks) == 0 {
+		return
+	}
+
+	sample := chunks[0]
+	if len(sample.Vector) != km.dimension {
+		log.Printf("Warning: Existing vector index holds %d-dimensional vectors but %s produces %d; "+
+			"search results are meaningless until you reindex (POST /api/vector/reindex)", len(sample.Vector), source, km.dimension)
+		return
+	}
+	if stored, ok := sample.Metadata["embedder"].(string); ok && stored != source {
+		log.Printf("Warning: Existing vector index was built with %s vectors but %s vectors are now being used; "+
+			"the two are not comparable, reindex (POST /api/vector/reindex)", stored, source)
+	}
+}
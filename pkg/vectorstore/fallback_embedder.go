@@ -0,0 +1,117 @@
+package vectorstore
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// FallbackEmbedderName 以 FallbackEmbedder 退路產生的向量在塊 metadata 中的 embedder 標記
+const FallbackEmbedderName = "simple"
+
+// EmbeddingSourceReporter 可回報每個向量實際由哪個嵌入生成器產生的嵌入生成器
+type EmbeddingSourceReporter interface {
+	// GenerateEmbeddingsWithSource 與 GenerateEmbeddings 相同，另返回每個向量的嵌入生成器名稱
+	GenerateEmbeddingsWithSource(texts []string) ([][]float64, []string, error)
+}
+
+// FallbackEmbedder 先使用主要嵌入生成器，失敗時改用相同維度的 SimpleHashEmbedder，
+// 讓嵌入 API 短暫中斷時塊仍能寫入，而不是被略過
+type FallbackEmbedder struct {
+	primary     Embedder
+	primaryName string
+	dimension   int // 主要嵌入生成器無法回報維度時使用的配置維度
+
+	fallbackCount int64
+	warnOnce      sync.Once
+}
+
+// NewFallbackEmbedder 創建以 primary 為主、SimpleHashEmbedder 為退路的嵌入生成器
+func NewFallbackEmbedder(primary Embedder, primaryName string, dimension int) *FallbackEmbedder {
+	return &FallbackEmbedder{primary: primary, primaryName: primaryName, dimension: dimension}
+}
+
+// Primary 返回主要嵌入生成器
+func (e *FallbackEmbedder) Primary() Embedder {
+	return e.primary
+}
+
+// VectorSource 返回主要嵌入生成器的向量來源（例如 llm 或 llm_local）
+func (e *FallbackEmbedder) VectorSource() string {
+	if source, ok := e.primary.(interface{ VectorSource() string }); ok {
+		return source.VectorSource()
+	}
+	return e.primaryName
+}
+
+// FallbackCount 返回至今以退路生成的向量數量
+func (e *FallbackEmbedder) FallbackCount() int64 {
+	return atomic.LoadInt64(&e.fallbackCount)
+}
+
+// GenerateEmbedding 使用主要嵌入生成器，失敗時改用哈希嵌入
+func (e *FallbackEmbedder) GenerateEmbedding(text string) ([]float64, error) {
+	vector, _, err := e.generate(text)
+	return vector, err
+}
+
+// GenerateEmbeddings 批次生成嵌入，失敗的項目改用哈希嵌入
+func (e *FallbackEmbedder) GenerateEmbeddings(texts []string) ([][]float64, error) {
+	vectors, _, err := e.GenerateEmbeddingsWithSource(texts)
+	return vectors, err
+}
+
+// GenerateEmbeddingsWithSource 先整批使用主要嵌入生成器；整批失敗或個別項目缺少向量時逐一重試，
+// 仍失敗的項目改用哈希嵌入並標記為 FallbackEmbedderName
+func (e *FallbackEmbedder) GenerateEmbeddingsWithSource(texts []string) ([][]float64, []string, error) {
+	vectors, err := e.primary.GenerateEmbeddings(texts)
+	if err != nil || len(vectors) != len(texts) {
+		if err != nil {
+			log.Printf("Warning: Batch embedding with %s failed, retrying per text: %v", e.primaryName, err)
+		}
+		vectors = make([][]float64, len(texts))
+	}
+
+	sources := make([]string, len(texts))
+	for i, text := range texts {
+		if vectors[i] != nil {
+			sources[i] = e.VectorSource()
+			continue
+		}
+		vector, source, err := e.generate(text)
+		if err != nil {
+			return nil, nil, err
+		}
+		vectors[i], sources[i] = vector, source
+	}
+	return vectors, sources, nil
+}
+
+// generate 生成單一向量並返回實際使用的嵌入生成器名稱
+func (e *FallbackEmbedder) generate(text string) ([]float64, string, error) {
+	vector, err := e.primary.GenerateEmbedding(text)
+	if err == nil && len(vector) > 0 {
+		return vector, e.VectorSource(), nil
+	}
+
+	e.warnOnce.Do(func() {
+		log.Printf("Warning: Embedder %s failed (%v); falling back to simple hash embeddings. "+
+			"The vector index now mixes %s and simple vectors and search quality will suffer; "+
+			"reindex (POST /api/vector/reindex) once %s is available again",
+			e.primaryName, err, e.primaryName, e.primaryName)
+	})
+	atomic.AddInt64(&e.fallbackCount, 1)
+
+	vector, err = NewSimpleHashEmbedder(e.currentDimension()).GenerateEmbedding(text)
+	return vector, FallbackEmbedderName, err
+}
+
+// currentDimension 返回主要嵌入生成器目前的維度（例如 LLMEmbedder 偵測到的維度），讓退路向量與其一致
+func (e *FallbackEmbedder) currentDimension() int {
+	if sized, ok := e.primary.(interface{ Dimension() int }); ok {
+		if dimension := sized.Dimension(); dimension > 0 {
+			return dimension
+		}
+	}
+	return e.dimension
+}
@@ -8,7 +8,7 @@ import (
 
 // KnowledgeIndexer 知識庫索引器
 type KnowledgeIndexer struct {
-	vectorStore *VectorStore
+	vectorStore Store
 	embedder    Embedder
 	chunker     *KnowledgeChunker
 }
@@ -46,29 +46,27 @@ func (ki *KnowledgeIndexer) IndexKnowledgeBase(knowledgeDir string) error {
 
 	log.Printf("Generated %d knowledge chunks", len(chunks))
 
-	// 為每個塊生成嵌入並存儲
-	processed := 0
+	// 為每個塊生成嵌入
+	batch := make([]VectorChunk, 0, len(chunks))
 	for _, chunk := range chunks {
-		// 生成嵌入
 		vector, err := ki.embedder.GenerateEmbedding(chunk.Content)
 		if err != nil {
 			log.Printf("Warning: Failed to generate embedding for chunk: %v", err)
 			continue
 		}
 
-		// 存儲到向量數據庫
-		if err := ki.vectorStore.AddChunk(chunk.Content, chunk.Metadata, vector); err != nil {
-			log.Printf("Warning: Failed to store chunk: %v", err)
-			continue
+		batch = append(batch, VectorChunk{Content: chunk.Content, Metadata: chunk.Metadata, Vector: vector})
+		if len(batch)%10 == 0 {
+			log.Printf("Embedded %d/%d chunks", len(batch), len(chunks))
 		}
+	}
 
-		processed++
-		if processed%10 == 0 {
-			log.Printf("Processed %d/%d chunks", processed, len(chunks))
-		}
+	// 一次寫入向量數據庫
+	if err := ki.vectorStore.AddChunks(batch); err != nil {
+		return fmt.Errorf("failed to store knowledge chunks: %v", err)
 	}
 
-	log.Printf("Successfully indexed %d knowledge chunks", processed)
+	log.Printf("Successfully indexed %d knowledge chunks", len(batch))
 	return nil
 }
 
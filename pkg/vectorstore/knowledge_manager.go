@@ -6,35 +6,38 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/masato25/aika-dba/config"
+	"github.com/masato25/aika-dba/pkg/llm"
+	"github.com/masato25/aika-dba/pkg/privacy"
 )
 
 // KnowledgeManager 知識管理器 - 統一管理所有 phase 的向量知識
 type KnowledgeManager struct {
-	vectorStore *VectorStore
+	vectorStore Store
 	embedder    Embedder
 	chunker     *KnowledgeChunker
 	config      *config.Config
+	dimension   int
 }
 
 // NewKnowledgeManager 創建知識管理器
+// 不修改 cfg：偵測到的向量維度保存在 KnowledgeManager，向量存儲以該維度建立
 func NewKnowledgeManager(cfg *config.Config) (*KnowledgeManager, error) {
-	// 創建嵌入生成器
-	var embedder Embedder
-	switch cfg.VectorStore.EmbedderType {
-	case "qwen":
-		embedder = NewQwenEmbedder(cfg.VectorStore.QwenModelPath, cfg.VectorStore.EmbeddingDimension)
-	case "simple":
-		embedder = NewSimpleHashEmbedder(cfg.VectorStore.EmbeddingDimension)
-	default:
-		embedder = NewSimpleHashEmbedder(cfg.VectorStore.EmbeddingDimension)
+	// 創建嵌入生成器（llm 類型在程序內共用，維度只探測一次）
+	embedder, dimension, err := prepareEmbedder(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	// 創建向量存儲
-	vectorStore, err := NewVectorStore(cfg.VectorStore.DatabasePath)
+	storeConfig := *cfg
+	storeConfig.VectorStore.EmbeddingDimension = dimension
+	vectorStore, err := NewStore(&storeConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create vector store: %v", err)
 	}
@@ -42,105 +45,456 @@ func NewKnowledgeManager(cfg *config.Config) (*KnowledgeManager, error) {
 	// 創建分塊器
 	chunker := NewKnowledgeChunker(cfg.VectorStore.ChunkSize, cfg.VectorStore.ChunkOverlap)
 
-	return &KnowledgeManager{
+	km := &KnowledgeManager{
 		vectorStore: vectorStore,
 		embedder:    embedder,
 		chunker:     chunker,
 		config:      cfg,
-	}, nil
+		dimension:   dimension,
+	}
+	km.checkIndexCompatibility()
+	return km, nil
+}
+
+// EmbeddingDimension 返回實際使用的向量維度（llm 嵌入生成器偵測到的維度，否則為配置值）
+func (km *KnowledgeManager) EmbeddingDimension() int {
+	return km.dimension
+}
+
+// EmbedderFactory 依配置創建嵌入生成器
+type EmbedderFactory func(cfg *config.Config) (Embedder, error)
+
+var (
+	embedderFactoriesMu sync.RWMutex
+	embedderFactories   = map[string]EmbedderFactory{}
+)
+
+// RegisterEmbedder 註冊自訂嵌入生成器，vectorstore.embedder_type 設為 name 時使用（不可覆蓋內建類型）
+// 需在 config.LoadConfig 之前註冊，配置驗證才會接受此類型
+func RegisterEmbedder(name string, factory EmbedderFactory) error {
+	switch name {
+	case "", "simple", "qwen", "llm":
+		return fmt.Errorf("embedder type %q is reserved", name)
+	}
+
+	embedderFactoriesMu.Lock()
+	defer embedderFactoriesMu.Unlock()
+	embedderFactories[name] = factory
+	config.RegisterEmbedderType(name)
+	return nil
+}
+
+// registeredEmbedder 返回以 RegisterEmbedder 註冊的嵌入生成器工廠
+func registeredEmbedder(name string) (EmbedderFactory, bool) {
+	embedderFactoriesMu.RLock()
+	defer embedderFactoriesMu.RUnlock()
+	factory, ok := embedderFactories[name]
+	return factory, ok
+}
+
+// BoundEmbedderName 以 BindEmbedder 綁定的嵌入生成器在塊 metadata 中的 embedder 標記
+const BoundEmbedderName = "custom"
+
+// boundEmbedders 綁定到特定配置的嵌入生成器，只影響以該配置創建的 KnowledgeManager
+var (
+	boundEmbeddersMu sync.RWMutex
+	boundEmbedders   = map[*config.Config]Embedder{}
+)
+
+// BindEmbedder 讓以 cfg 創建的 KnowledgeManager 使用 embedder，取代 vectorstore.embedder_type 指定的類型
+// 與 RegisterEmbedder 不同，綁定不會新增全域類型；不再使用 cfg 時應呼叫 UnbindEmbedder
+func BindEmbedder(cfg *config.Config, embedder Embedder) {
+	boundEmbeddersMu.Lock()
+	defer boundEmbeddersMu.Unlock()
+	boundEmbedders[cfg] = embedder
+}
+
+// UnbindEmbedder 解除 BindEmbedder 的綁定
+func UnbindEmbedder(cfg *config.Config) {
+	boundEmbeddersMu.Lock()
+	defer boundEmbeddersMu.Unlock()
+	delete(boundEmbedders, cfg)
+}
+
+// boundEmbedder 返回綁定到 cfg 的嵌入生成器
+func boundEmbedder(cfg *config.Config) (Embedder, bool) {
+	boundEmbeddersMu.RLock()
+	defer boundEmbeddersMu.RUnlock()
+	embedder, ok := boundEmbedders[cfg]
+	return embedder, ok
 }
 
-// StorePhaseKnowledge 存儲特定 phase 的知識
+// newEmbedder 根據配置創建嵌入生成器
+// 未設定類型時使用 simple；無法識別的類型預設視為配置錯誤，除非啟用 fallback_to_simple_embedder
+func newEmbedder(cfg *config.Config) (Embedder, error) {
+	if embedder, ok := boundEmbedder(cfg); ok {
+		return embedder, nil
+	}
+	if factory, ok := registeredEmbedder(cfg.VectorStore.EmbedderType); ok {
+		return factory(cfg)
+	}
+
+	switch cfg.VectorStore.EmbedderType {
+	case "qwen":
+		return NewQwenEmbedder(cfg.VectorStore.QwenModelPath, cfg.VectorStore.EmbeddingDimension), nil
+	case "llm":
+		embedder := NewLLMEmbedder(cfg.LLM.Host, cfg.LLM.Port, cfg.LLM.Model, cfg.VectorStore.EmbeddingDimension)
+		if cfg.VectorStore.EmbeddingMaxRetries > 0 {
+			embedder.maxRetries = cfg.VectorStore.EmbeddingMaxRetries
+		}
+		return embedder, nil
+	case "simple", "":
+		return NewSimpleHashEmbedder(cfg.VectorStore.EmbeddingDimension), nil
+	default:
+		if !cfg.VectorStore.FallbackToSimpleEmbedder {
+			return nil, fmt.Errorf("unknown vectorstore.embedder_type %q (supported: simple, qwen, llm); set vectorstore.fallback_to_simple_embedder to use the simple embedder instead", cfg.VectorStore.EmbedderType)
+		}
+		log.Printf("Warning: Unknown embedder type %q, falling back to simple embedder", cfg.VectorStore.EmbedderType)
+		return NewSimpleHashEmbedder(cfg.VectorStore.EmbeddingDimension), nil
+	}
+}
+
+// embedderName 返回配置的嵌入生成器名稱（記錄於塊 metadata 的 embedder），未設定時為 simple，已綁定時為 custom
+func embedderName(cfg *config.Config) string {
+	if _, ok := boundEmbedder(cfg); ok {
+		return BoundEmbedderName
+	}
+	if cfg.VectorStore.EmbedderType == "" {
+		return "simple"
+	}
+	return cfg.VectorStore.EmbedderType
+}
+
+// 知識保留策略：同一 phase 重新存儲時如何處理先前的塊
+const (
+	RetentionReplace = "replace" // 在同一交易中刪除該 phase 的舊塊並寫入新塊
+	RetentionAppend  = "append"  // 保留舊塊，直接附加新塊
+	RetentionUpsert  = "upsert"  // 保留舊塊，只附加內容尚未存儲的新塊
+)
+
+// defaultRetentionPolicies 未配置時的預設策略；營銷查詢每次存儲一筆新知識，屬於刻意累加
+var defaultRetentionPolicies = map[string]string{
+	"marketing_query": RetentionAppend,
+}
+
+// retentionPolicy 返回 phase 的保留策略（vectorstore.retention_policies），預設為 replace
+func (km *KnowledgeManager) retentionPolicy(phase string) string {
+	if policy, ok := km.config.VectorStore.RetentionPolicies[phase]; ok && policy != "" {
+		return policy
+	}
+	if policy, ok := defaultRetentionPolicies[phase]; ok {
+		return policy
+	}
+	return RetentionReplace
+}
+
+// chunkerFor 返回 phase 使用的分塊器：vectorstore.phase_chunking 有該 phase 的設定時使用覆寫值，
+// size <= 0 時沿用全域 chunk_size，overlap < 0 時沿用全域 chunk_overlap
+func (km *KnowledgeManager) chunkerFor(phase string) *KnowledgeChunker {
+	override, ok := km.config.VectorStore.PhaseChunking[phase]
+	if !ok {
+		return km.chunker
+	}
+
+	size, overlap := km.chunker.chunkSize, km.chunker.chunkOverlap
+	if override.Size > 0 {
+		size = override.Size
+	}
+	if override.Overlap >= 0 {
+		overlap = override.Overlap
+	}
+	if overlap >= size {
+		log.Printf("Warning: chunk overlap %d for phase %s is not smaller than chunk size %d, using %d", overlap, phase, size, size-1)
+		overlap = size - 1
+	}
+	return NewKnowledgeChunker(size, overlap)
+}
+
+// StorePhaseKnowledge 存儲特定 phase 的知識，依保留策略處理該 phase 先前存儲的塊
 func (km *KnowledgeManager) StorePhaseKnowledge(phase string, knowledge map[string]interface{}) error {
-	log.Printf("Storing knowledge for phase: %s", phase)
+	policy := km.retentionPolicy(phase)
+	log.Printf("Storing knowledge for phase: %s (retention: %s)", phase, policy)
+
+	batch := km.embedPhaseKnowledge(phase, knowledge)
+
+	switch policy {
+	case RetentionReplace:
+		if len(batch) == 0 {
+			log.Printf("Warning: No chunks generated for phase %s, keeping existing knowledge", phase)
+			return nil
+		}
+		if err := km.vectorStore.ReplaceChunks("phase", phase, batch); err != nil {
+			return fmt.Errorf("failed to replace knowledge chunks for phase %s: %v", phase, err)
+		}
+	case RetentionAppend:
+		// append 刻意累加，即使內容與先前存儲的塊相同也不去重
+		add := km.vectorStore.AddChunks
+		if appender, ok := km.vectorStore.(ChunkAppender); ok {
+			add = appender.AppendChunks
+		}
+		if err := add(batch); err != nil {
+			return fmt.Errorf("failed to store knowledge chunks for phase %s: %v", phase, err)
+		}
+	case RetentionUpsert:
+		newChunks, err := km.unstoredChunks(phase, batch)
+		if err != nil {
+			return err
+		}
+		if err := km.vectorStore.AddChunks(newChunks); err != nil {
+			return fmt.Errorf("failed to store knowledge chunks for phase %s: %v", phase, err)
+		}
+		log.Printf("Upsert for phase %s: %d of %d chunks already stored", phase, len(batch)-len(newChunks), len(batch))
+		batch = newChunks
+	default:
+		return fmt.Errorf("unknown retention policy %q for phase %s (expected replace, append or upsert)", policy, phase)
+	}
+
+	log.Printf("Successfully stored %d knowledge chunks for phase %s", len(batch), phase)
+	return nil
+}
+
+// unstoredChunks 過濾掉內容雜湊（內容與含 phase 的元數據）已存儲的塊，同一批次中重複的塊只保留一個
+func (km *KnowledgeManager) unstoredChunks(phase string, batch []VectorChunk) ([]VectorChunk, error) {
+	hashed, hashes := hashChunks(batch)
+	stored, err := km.storedHashes(hashes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up existing chunks for phase %s: %v", phase, err)
+	}
+
+	newChunks := make([]VectorChunk, 0, len(hashed))
+	for i, chunk := range hashed {
+		if !stored[hashes[i]] {
+			newChunks = append(newChunks, chunk)
+		}
+	}
+	return newChunks, nil
+}
+
+// storedHashes 返回已存儲的內容雜湊；後端支援 HashLookup 時只以索引查詢這些雜湊，否則讀取全部塊比對
+func (km *KnowledgeManager) storedHashes(hashes []string) (map[string]bool, error) {
+	if lookup, ok := km.vectorStore.(HashLookup); ok {
+		return lookup.StoredHashes(hashes)
+	}
+
+	existing, err := km.vectorStore.GetAllChunks()
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]bool)
+	for _, chunk := range existing {
+		if hash, ok := chunk.Metadata[ContentHashKey].(string); ok {
+			stored[hash] = true
+		}
+	}
+	return stored, nil
+}
+
+// PhaseKnowledgeFiles 各 phase 對應的知識 JSON 檔名（位於知識目錄下，供重新分塊使用）
+var PhaseKnowledgeFiles = map[string]string{
+	"phase1":        "phase1_analysis.json",
+	"phase1_post":   "phase1_post_analysis.json",
+	"phase2":        "phase2_analysis.json",
+	"phase2_prefix": "phase2_prefix_analysis.json",
+	"phase3":        "phase3_analysis.json",
+	"phase4":        "phase4_dimensions.json",
+}
+
+// RechunkPhase 重新讀取 phase 的 JSON 檔案，以目前的分塊設定重新分塊與嵌入，並替換已存儲的塊
+// 舊塊的刪除與新塊的寫入在同一次替換中完成，失敗時保留原有知識
+func (km *KnowledgeManager) RechunkPhase(phase string) (int, error) {
+	name, ok := PhaseKnowledgeFiles[phase]
+	if !ok {
+		return 0, fmt.Errorf("unknown phase %q for rechunk", phase)
+	}
+	filename := km.config.KnowledgePath(name)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", filename, err)
+	}
+
+	var knowledge map[string]interface{}
+	if err := json.Unmarshal(data, &knowledge); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %v", filename, err)
+	}
+
+	batch := km.embedPhaseKnowledge(phase, knowledge)
+	if len(batch) == 0 {
+		return 0, fmt.Errorf("no chunks generated for phase %s, keeping existing knowledge", phase)
+	}
+
+	if err := km.vectorStore.ReplaceChunks("phase", phase, batch); err != nil {
+		return 0, fmt.Errorf("failed to replace knowledge chunks for phase %s: %v", phase, err)
+	}
+
+	log.Printf("Rechunked phase %s from %s into %d chunks", phase, filename, len(batch))
+	return len(batch), nil
+}
+
+// embedPhaseKnowledge 將 phase 知識轉換為文本、分塊並生成嵌入
+func (km *KnowledgeManager) embedPhaseKnowledge(phase string, knowledge map[string]interface{}) []VectorChunk {
+	// 樣本遮罩規則與 MCP 工具一致
+	knowledge = privacy.RedactPhaseKnowledge(km.config.Security.SampleMasking, knowledge)
 
 	// 將知識轉換為文本
 	knowledgeText := km.knowledgeToText(phase, knowledge)
 
 	// 分塊知識
-	chunks := km.chunker.chunkText(knowledgeText, fmt.Sprintf("phase_%s", phase))
+	chunks := km.chunkerFor(phase).chunkText(knowledgeText, fmt.Sprintf("phase_%s", phase))
 
-	// 存儲每個塊
-	for _, chunk := range chunks {
-		vector, err := km.embedder.GenerateEmbedding(chunk.Content)
+	// 為每個塊生成嵌入並添加 phase 信息到元數據
+	return km.embedChunks(chunks, map[string]interface{}{"phase": phase})
+}
+
+// embedChunks 先以一次批次呼叫生成所有塊的嵌入；批次失敗或部分缺少時，
+// 缺少的塊改以有限數量的 worker 逐一生成（vectorstore.embedding_concurrency），
+// 返回的塊保持原本的分塊順序，生成失敗的塊會被略過
+func (km *KnowledgeManager) embedChunks(chunks []KnowledgeChunk, metadata map[string]interface{}) []VectorChunk {
+	contents := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		contents[i] = chunk.Content
+	}
+
+	var vectors [][]float64
+	var sources []string
+	var err error
+	if reporter, ok := km.embedder.(EmbeddingSourceReporter); ok {
+		vectors, sources, err = reporter.GenerateEmbeddingsWithSource(contents)
+	} else {
+		vectors, err = km.embedder.GenerateEmbeddings(contents)
+	}
+	if err != nil || len(vectors) != len(chunks) {
 		if err != nil {
-			log.Printf("Warning: Failed to generate embedding for chunk: %v", err)
+			log.Printf("Warning: Batch embedding failed, falling back to per-chunk embedding: %v", err)
+		}
+		vectors = make([][]float64, len(chunks))
+	}
+
+	var missing []int
+	for i := range vectors {
+		if vectors[i] == nil {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) > 0 {
+		km.embedEachChunk(contents, missing, vectors)
+	}
+
+	source := vectorSource(km.embedder, km.config)
+	batch := make([]VectorChunk, 0, len(chunks))
+	for i, chunk := range chunks {
+		if vectors[i] == nil {
 			continue
 		}
 
-		// 添加 phase 信息到元數據
 		if chunk.Metadata == nil {
 			chunk.Metadata = make(map[string]interface{})
 		}
-		chunk.Metadata["phase"] = phase
+		for key, value := range metadata {
+			chunk.Metadata[key] = value
+		}
 		chunk.Metadata["timestamp"] = time.Now().Unix()
-
-		if err := km.vectorStore.AddChunk(chunk.Content, chunk.Metadata, vector); err != nil {
-			log.Printf("Warning: Failed to store chunk: %v", err)
-			continue
+		chunk.Metadata["embedder"] = source
+		if i < len(sources) && sources[i] != "" {
+			chunk.Metadata["embedder"] = sources[i]
 		}
+
+		batch = append(batch, VectorChunk{Content: chunk.Content, Metadata: chunk.Metadata, Vector: vectors[i]})
 	}
 
-	log.Printf("Successfully stored %d knowledge chunks for phase %s", len(chunks), phase)
-	return nil
+	return batch
 }
 
-// RetrievePhaseKnowledge 檢索特定 phase 的知識
-func (km *KnowledgeManager) RetrievePhaseKnowledge(phase string, query string, limit int) ([]KnowledgeResult, error) {
-	// 生成查詢向量
-	queryVector, err := km.embedder.GenerateEmbedding(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate query embedding: %v", err)
+// embedEachChunk 以有限數量的 worker 並行逐一生成 indexes 指定的塊的嵌入，結果寫入 vectors
+func (km *KnowledgeManager) embedEachChunk(contents []string, indexes []int, vectors [][]float64) {
+	workers := km.config.VectorStore.EmbeddingConcurrency
+	if workers <= 0 {
+		workers = 1
 	}
-
-	// 搜索所有向量，但過濾特定 phase
-	allChunks, err := km.vectorStore.GetAllChunks()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get chunks: %v", err)
+	if workers > len(indexes) {
+		workers = len(indexes)
 	}
 
-	// 過濾特定 phase 的塊
-	var phaseChunks []VectorChunk
-	for _, chunk := range allChunks {
-		if chunk.Metadata != nil {
-			if chunkPhase, ok := chunk.Metadata["phase"].(string); ok && chunkPhase == phase {
-				phaseChunks = append(phaseChunks, chunk)
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				vector, err := km.embedder.GenerateEmbedding(contents[i])
+				if err != nil {
+					log.Printf("Warning: Failed to generate embedding for chunk: %v", err)
+					continue
+				}
+				vectors[i] = vector
 			}
-		}
+		}()
+	}
+	for _, i := range indexes {
+		queue <- i
 	}
+	close(queue)
+	wg.Wait()
+}
 
-	// 計算相似度並排序
-	type scoredChunk struct {
-		chunk VectorChunk
-		score float64
+// StoreTableKnowledge 存儲單一表格的知識，先刪除該表格在同一 phase 先前存儲的塊
+func (km *KnowledgeManager) StoreTableKnowledge(phase, tableName string, knowledge map[string]interface{}) error {
+	knowledgeKey := fmt.Sprintf("%s:%s", phase, tableName)
+	if err := km.vectorStore.DeleteByMetadata("knowledge_key", knowledgeKey); err != nil {
+		return fmt.Errorf("failed to delete previous knowledge for table %s: %v", tableName, err)
 	}
 
-	var scored []scoredChunk
-	for _, chunk := range phaseChunks {
-		score := cosineSimilarity(queryVector, chunk.Vector)
-		scored = append(scored, scoredChunk{chunk: chunk, score: score})
+	knowledgeText := km.knowledgeToText(phase, knowledge)
+	chunks := km.chunkerFor(phase).chunkText(knowledgeText, fmt.Sprintf("phase_%s_%s", phase, tableName))
+
+	batch := km.embedChunks(chunks, map[string]interface{}{
+		"phase":         phase,
+		"table":         tableName,
+		"knowledge_key": knowledgeKey,
+	})
+
+	if err := km.vectorStore.AddChunks(batch); err != nil {
+		return fmt.Errorf("failed to store knowledge chunks for table %s: %v", tableName, err)
 	}
 
-	// 按相似度降序排序
-	for i := 0; i < len(scored)-1; i++ {
-		for j := i + 1; j < len(scored); j++ {
-			if scored[i].score < scored[j].score {
-				scored[i], scored[j] = scored[j], scored[i]
-			}
+	log.Printf("Successfully stored %d knowledge chunks for table %s in phase %s", len(batch), tableName, phase)
+	return nil
+}
+
+// DeleteTableKnowledge 刪除單一表格在指定 phase 以 StoreTableKnowledge 存儲的塊
+func (km *KnowledgeManager) DeleteTableKnowledge(phase, tableName string) error {
+	knowledgeKey := fmt.Sprintf("%s:%s", phase, tableName)
+	if err := km.vectorStore.DeleteByMetadata("knowledge_key", knowledgeKey); err != nil {
+		return fmt.Errorf("failed to delete knowledge for table %s: %v", tableName, err)
+	}
+	return nil
+}
+
+// RetrievePhaseKnowledge 檢索特定 phase 的知識
+func (km *KnowledgeManager) RetrievePhaseKnowledge(phase string, query string, limit int) ([]KnowledgeResult, error) {
+	// 生成查詢向量
+	queryVector, err := km.embedder.GenerateEmbedding(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %v", err)
+	}
+
+	// 後端支援時直接在存儲層過濾 phase 並保留 top-K
+	if searcher, ok := km.vectorStore.(PhaseSearcher); ok {
+		results, err := searcher.SearchByPhase(phase, queryVector, limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search phase %s: %v", phase, err)
 		}
+		return results, nil
 	}
 
-	// 返回前limit個結果
-	var results []KnowledgeResult
-	for i := 0; i < len(scored) && i < limit; i++ {
-		results = append(results, KnowledgeResult{
-			Content:  scored[i].chunk.Content,
-			Metadata: scored[i].chunk.Metadata,
-			Score:    scored[i].score,
-		})
+	// 其他後端：載入所有塊後過濾特定 phase
+	allChunks, err := km.vectorStore.GetAllChunks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks: %v", err)
 	}
 
-	return results, nil
+	return searchChunksByPhase(allChunks, phase, queryVector, limit), nil
 }
 
 // RetrieveCrossPhaseKnowledge 檢索跨 phase 的知識
@@ -172,38 +526,17 @@ func (km *KnowledgeManager) RetrieveCrossPhaseKnowledge(query string, phases []s
 		}
 	}
 
-	// 計算相似度並排序
-	type scoredChunk struct {
-		chunk VectorChunk
-		score float64
-	}
-
-	var scored []scoredChunk
+	// 掃描時只保留相似度最高的 limit 個結果
+	collector := newTopKCollector(limit)
 	for _, chunk := range filteredChunks {
-		score := cosineSimilarity(queryVector, chunk.Vector)
-		scored = append(scored, scoredChunk{chunk: chunk, score: score})
-	}
-
-	// 按相似度降序排序
-	for i := 0; i < len(scored)-1; i++ {
-		for j := i + 1; j < len(scored); j++ {
-			if scored[i].score < scored[j].score {
-				scored[i], scored[j] = scored[j], scored[i]
-			}
-		}
-	}
-
-	// 返回前limit個結果
-	var results []KnowledgeResult
-	for i := 0; i < len(scored) && i < limit; i++ {
-		results = append(results, KnowledgeResult{
-			Content:  scored[i].chunk.Content,
-			Metadata: scored[i].chunk.Metadata,
-			Score:    scored[i].score,
+		collector.add(KnowledgeResult{
+			Content:  chunk.Content,
+			Metadata: chunk.Metadata,
+			Score:    cosineSimilarity(queryVector, chunk.Vector),
 		})
 	}
 
-	return results, nil
+	return collector.results(), nil
 }
 
 // knowledgeToText 將知識對象轉換為文本
@@ -211,8 +544,8 @@ func (km *KnowledgeManager) knowledgeToText(phase string, knowledge map[string]i
 	var builder strings.Builder
 
 	builder.WriteString(fmt.Sprintf("Phase: %s\n", phase))
-	builder.WriteString(fmt.Sprintf("Description: %s\n", km.getPhaseDescription(phase)))
-	builder.WriteString(fmt.Sprintf("Timestamp: %v\n\n", time.Now()))
+	// 不寫入當下時間，相同知識重新存儲時產生相同的塊，內容雜湊才能避免重複寫入
+	builder.WriteString(fmt.Sprintf("Description: %s\n\n", km.getPhaseDescription(phase)))
 
 	km.appendKnowledgeRecursive(&builder, knowledge, 0)
 
@@ -220,14 +553,22 @@ func (km *KnowledgeManager) knowledgeToText(phase string, knowledge map[string]i
 }
 
 // appendKnowledgeRecursive 遞歸添加知識內容
+// 鍵依字母排序並略過易變的 timestamp，相同知識每次都產生相同文本
 func (km *KnowledgeManager) appendKnowledgeRecursive(builder *strings.Builder, data interface{}, depth int) {
 	indent := strings.Repeat("  ", depth)
 
 	switch v := data.(type) {
 	case map[string]interface{}:
-		for key, value := range v {
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			if !volatileMetadataKeys[key] {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
 			builder.WriteString(fmt.Sprintf("%s%s:\n", indent, key))
-			km.appendKnowledgeRecursive(builder, value, depth+1)
+			km.appendKnowledgeRecursive(builder, v[key], depth+1)
 		}
 	case []interface{}:
 		for i, item := range v {
@@ -245,6 +586,7 @@ func (km *KnowledgeManager) getPhaseDescription(phase string) string {
 		"phase1": "Database statistical analysis with table schemas, constraints, and sample data",
 		"phase2": "AI-powered business logic analysis with LLM insights and recommendations",
 		"phase3": "Business logic analysis and natural language description generation",
+		"phase5": "Star schema DDL generated from dimension modeling results",
 	}
 	return descriptions[phase]
 }
@@ -262,17 +604,109 @@ func (km *KnowledgeManager) GetKnowledgeStats() (map[string]interface{}, error)
 	}
 
 	phases := stats["phases"].(map[string]int)
+	embedders := make(map[string]int)
 	for _, chunk := range chunks {
 		if chunk.Metadata != nil {
 			if phase, ok := chunk.Metadata["phase"].(string); ok {
 				phases[phase]++
 			}
+			if embedder, ok := chunk.Metadata["embedder"].(string); ok {
+				embedders[embedder]++
+			}
 		}
 	}
 
+	// 各嵌入生成器產生的塊數（未記錄 embedder 的舊塊不計入）；超過一種時向量不可直接比較
+	stats["embedders"] = embedders
+	stats["mixed_embedders"] = len(embedders) > 1
+	if fallback, ok := km.embedder.(*FallbackEmbedder); ok {
+		stats["fallback_embeddings"] = fallback.FallbackCount()
+	}
+
+	// 附上各 phase 的 LLM token 用量與估算成本
+	if usage, err := llm.UsageReport(km.config); err != nil {
+		log.Printf("Warning: Failed to load LLM usage: %v", err)
+	} else {
+		stats["llm_usage"] = usage
+	}
+
 	return stats, nil
 }
 
+// IndexedTable 知識庫中某個表格在特定 phase 的索引情況
+type IndexedTable struct {
+	Table      string `json:"table"`
+	ChunkCount int    `json:"chunk_count"`
+}
+
+// ListIndexedTables 掃描塊元數據（table 或 source_table），返回各 phase 已存儲知識的表格及塊數
+func (km *KnowledgeManager) ListIndexedTables() (map[string][]IndexedTable, error) {
+	chunks, err := km.vectorStore.GetAllChunks()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]map[string]int)
+	for _, chunk := range chunks {
+		if chunk.Metadata == nil {
+			continue
+		}
+		table, _ := chunk.Metadata["table"].(string)
+		if table == "" {
+			table, _ = chunk.Metadata["source_table"].(string)
+		}
+		if table == "" {
+			continue
+		}
+		phase, _ := chunk.Metadata["phase"].(string)
+		if phase == "" {
+			phase = "unknown"
+		}
+
+		if counts[phase] == nil {
+			counts[phase] = make(map[string]int)
+		}
+		counts[phase][table]++
+	}
+
+	result := make(map[string][]IndexedTable, len(counts))
+	for phase, tables := range counts {
+		list := make([]IndexedTable, 0, len(tables))
+		for table, count := range tables {
+			list = append(list, IndexedTable{Table: table, ChunkCount: count})
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Table < list[j].Table
+		})
+		result[phase] = list
+	}
+
+	return result, nil
+}
+
+// PingEmbedder 以一段短文字發出嵌入請求，確認嵌入生成器可用
+// llm 嵌入器直接呼叫嵌入 API，不使用 API 失敗時的統計向量退路；嵌入退路啟用時檢查主要嵌入生成器
+func (km *KnowledgeManager) PingEmbedder() error {
+	var vector []float64
+	var err error
+	primary := km.embedder
+	if fallback, ok := primary.(*FallbackEmbedder); ok {
+		primary = fallback.Primary()
+	}
+	if embedder, ok := primary.(*LLMEmbedder); ok {
+		vector, err = embedder.GenerateEmbeddingWithLLM("health check")
+	} else {
+		vector, err = primary.GenerateEmbedding("health check")
+	}
+	if err != nil {
+		return err
+	}
+	if len(vector) == 0 {
+		return fmt.Errorf("embedder returned an empty vector")
+	}
+	return nil
+}
+
 // Close 關閉知識管理器
 func (km *KnowledgeManager) Close() error {
 	return km.vectorStore.Close()
@@ -0,0 +1,250 @@
+package vectorstore
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// newEmbeddingServer 模擬 /v1/embeddings，返回 4 維向量並計算收到的請求數
+func newEmbeddingServer(t *testing.T) (*httptest.Server, *int64) {
+	t.Helper()
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.Write([]byte(`{"data":[{"index":0,"embedding":[0.1,0.2,0.3,0.4]}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func llmConfig(t *testing.T, server *httptest.Server, backend string) *config.Config {
+	t.Helper()
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	cfg.VectorStore.Backend = backend
+	cfg.VectorStore.DatabasePath = filepath.Join(t.TempDir(), "vectors.db")
+	cfg.VectorStore.EmbedderType = "llm"
+	cfg.VectorStore.EmbeddingDimension = 64
+	cfg.VectorStore.ChunkSize = 200
+	cfg.VectorStore.ChunkOverlap = 20
+	cfg.LLM.Host = host
+	cfg.LLM.Port, _ = strconv.Atoi(port)
+	cfg.LLM.Model = t.Name()
+	return cfg
+}
+
+func TestNewKnowledgeManagerDetectsDimensionOnce(t *testing.T) {
+	server, requests := newEmbeddingServer(t)
+	cfg := llmConfig(t, server, "memory")
+
+	for i := 0; i < 3; i++ {
+		km, err := NewKnowledgeManager(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := km.EmbeddingDimension(); got != 4 {
+			t.Errorf("EmbeddingDimension() = %d, want detected 4", got)
+		}
+		km.Close()
+	}
+
+	if got := atomic.LoadInt64(requests); got != 1 {
+		t.Errorf("embedding API received %d probes, want 1", got)
+	}
+	if cfg.VectorStore.EmbeddingDimension != 64 {
+		t.Errorf("config embedding_dimension mutated to %d", cfg.VectorStore.EmbeddingDimension)
+	}
+}
+
+func TestNewKnowledgeManagerWarnsOnIndexMismatch(t *testing.T) {
+	server, _ := newEmbeddingServer(t)
+	cfg := llmConfig(t, server, "sqlite")
+
+	// 先以 8 維的 simple 向量建立索引
+	simple := *cfg
+	simple.VectorStore.EmbedderType = "simple"
+	simple.VectorStore.EmbeddingDimension = 8
+	km, err := NewKnowledgeManager(&simple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := km.StorePhaseKnowledge("phase1", map[string]interface{}{"tables": "customers orders"}); err != nil {
+		t.Fatal(err)
+	}
+	km.Close()
+
+	var logs bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&logs)
+	defer log.SetOutput(previous)
+
+	km, err = NewKnowledgeManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer km.Close()
+
+	if !strings.Contains(logs.String(), "Existing vector index holds 8-dimensional vectors but llm produces 4") {
+		t.Errorf("expected index mismatch warning, got logs:\n%s", logs.String())
+	}
+}
+
+func TestCheckIndexCompatibilityUnknownDimension(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.VectorStore.Backend = "sqlite"
+	cfg.VectorStore.DatabasePath = filepath.Join(t.TempDir(), "vectors.db")
+	cfg.VectorStore.EmbedderType = "llm"
+
+	// 維度未知（0）時不應建立探測向量，也不應觸及向量存儲
+	km := &KnowledgeManager{config: cfg}
+	km.checkIndexCompatibility()
+}
+
+// noScanStore 讀取全部塊時失敗，確認 upsert 只以內容雜湊查詢
+type noScanStore struct {
+	Store
+}
+
+func (noScanStore) GetAllChunks() ([]VectorChunk, error) {
+	return nil, errors.New("unexpected full scan")
+}
+
+func (s noScanStore) StoredHashes(hashes []string) (map[string]bool, error) {
+	return s.Store.(HashLookup).StoredHashes(hashes)
+}
+
+func (s noScanStore) AppendChunks(chunks []VectorChunk) error {
+	return s.Store.(ChunkAppender).AppendChunks(chunks)
+}
+
+func TestStorePhaseKnowledgeRetention(t *testing.T) {
+	knowledge := map[string]interface{}{"summary": "customers place orders; orders contain products"}
+
+	tests := []struct {
+		backend string
+		policy  string
+		want    func(first int) int // 第二次存儲相同知識後的塊數
+	}{
+		{"memory", RetentionAppend, func(first int) int { return 2 * first }},
+		{"memory", RetentionUpsert, func(first int) int { return first }},
+		{"sqlite", RetentionAppend, func(first int) int { return 2 * first }},
+		{"sqlite", RetentionUpsert, func(first int) int { return first }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend+"/"+tt.policy, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.VectorStore.Backend = tt.backend
+			cfg.VectorStore.DatabasePath = filepath.Join(t.TempDir(), "vectors.db")
+			cfg.VectorStore.EmbeddingDimension = 16
+			cfg.VectorStore.ChunkSize = 200
+			cfg.VectorStore.ChunkOverlap = 20
+			cfg.VectorStore.RetentionPolicies = map[string]string{"notes": tt.policy}
+
+			km, err := NewKnowledgeManager(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer km.Close()
+			store := km.vectorStore
+			km.vectorStore = noScanStore{store}
+
+			count := func() int {
+				chunks, err := store.GetAllChunks()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return len(chunks)
+			}
+
+			if err := km.StorePhaseKnowledge("notes", knowledge); err != nil {
+				t.Fatal(err)
+			}
+			first := count()
+			if first == 0 {
+				t.Fatal("no chunks stored")
+			}
+			if err := km.StorePhaseKnowledge("notes", knowledge); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := count(), tt.want(first); got != want {
+				t.Errorf("chunks after storing twice = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+// failingEmbedder 每次嵌入都失敗，模擬嵌入 API 中斷
+type failingEmbedder struct{}
+
+func (failingEmbedder) GenerateEmbedding(text string) ([]float64, error) {
+	return nil, errors.New("embedding API unavailable")
+}
+
+func (failingEmbedder) GenerateEmbeddings(texts []string) ([][]float64, error) {
+	return nil, errors.New("embedding API unavailable")
+}
+
+func TestStorePhaseKnowledgeEmbeddingFallback(t *testing.T) {
+	tests := []struct {
+		name            string
+		disableFallback bool
+		wantStored      bool
+	}{
+		{"fallback by default", false, true},
+		{"fallback disabled", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.VectorStore.Backend = "memory"
+			cfg.VectorStore.EmbeddingDimension = 16
+			cfg.VectorStore.ChunkSize = 200
+			cfg.VectorStore.ChunkOverlap = 20
+			cfg.VectorStore.DisableEmbeddingFallback = tt.disableFallback
+			BindEmbedder(cfg, failingEmbedder{})
+			defer UnbindEmbedder(cfg)
+
+			km, err := NewKnowledgeManager(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer km.Close()
+
+			if err := km.StorePhaseKnowledge("phase1", map[string]interface{}{"tables": "customers orders"}); err != nil {
+				t.Fatal(err)
+			}
+
+			chunks, err := km.vectorStore.GetAllChunks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored := len(chunks) > 0; stored != tt.wantStored {
+				t.Fatalf("stored %d chunks, want stored = %v", len(chunks), tt.wantStored)
+			}
+			for _, chunk := range chunks {
+				if chunk.Metadata["embedder"] != FallbackEmbedderName {
+					t.Errorf("chunk embedder = %v, want %s", chunk.Metadata["embedder"], FallbackEmbedderName)
+				}
+				if len(chunk.Vector) != 16 {
+					t.Errorf("fallback vector has %d dimensions, want 16", len(chunk.Vector))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MemoryStore 記憶體向量存儲後端，不寫入磁碟，適合測試與臨時使用
+type MemoryStore struct {
+	mu     sync.RWMutex
+	chunks []VectorChunk
+	nextID int
+}
+
+// NewMemoryStore 創建記憶體向量存儲
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextID: 1}
+}
+
+// Close 記憶體存儲無需釋放資源
+func (ms *MemoryStore) Close() error {
+	return nil
+}
+
+// AddChunk 添加向量塊
+func (ms *MemoryStore) AddChunk(content string, metadata map[string]interface{}, vector []float64) error {
+	return ms.AddChunks([]VectorChunk{{Content: content, Metadata: metadata, Vector: vector}})
+}
+
+// AddChunks 批次添加向量塊（忽略 ID 欄位），以內容雜湊略過已存在的塊
+func (ms *MemoryStore) AddChunks(chunks []VectorChunk) error {
+	return ms.addChunks(chunks, true)
+}
+
+// AppendChunks 批次附加向量塊，不以內容雜湊去重
+func (ms *MemoryStore) AppendChunks(chunks []VectorChunk) error {
+	return ms.addChunks(chunks, false)
+}
+
+// addChunks 寫入向量塊，dedup 時記錄內容雜湊並略過已存在的塊
+func (ms *MemoryStore) addChunks(chunks []VectorChunk, dedup bool) error {
+	prepared, err := prepareMemoryChunks(chunks, dedup)
+	if err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.appendLocked(prepared)
+	return nil
+}
+
+// StoredHashes 返回已存儲的內容雜湊中屬於 hashes 的部分
+func (ms *MemoryStore) StoredHashes(hashes []string) (map[string]bool, error) {
+	wanted := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		wanted[hash] = true
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	stored := make(map[string]bool)
+	for _, chunk := range ms.chunks {
+		if hash, ok := chunk.Metadata[ContentHashKey].(string); ok && wanted[hash] {
+			stored[hash] = true
+		}
+	}
+	return stored, nil
+}
+
+// SearchSimilar 搜索相似向量
+func (ms *MemoryStore) SearchSimilar(queryVector []float64, limit int) ([]VectorChunk, error) {
+	ms.mu.RLock()
+	type scoredChunk struct {
+		chunk VectorChunk
+		score float64
+	}
+	scored := make([]scoredChunk, 0, len(ms.chunks))
+	for _, chunk := range ms.chunks {
+		scored = append(scored, scoredChunk{chunk: copyChunk(chunk), score: cosineSimilarity(queryVector, chunk.Vector)})
+	}
+	ms.mu.RUnlock()
+
+	// 按相似度降序排序，相同分數保持寫入順序
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+
+	var results []VectorChunk
+	for i := 0; i < len(scored) && i < limit; i++ {
+		results = append(results, scored[i].chunk)
+	}
+	return results, nil
+}
+
+// SearchByPhase 搜索指定 phase 中最相似的 limit 個向量塊
+func (ms *MemoryStore) SearchByPhase(phase string, queryVector []float64, limit int) ([]KnowledgeResult, error) {
+	ms.mu.RLock()
+	results := searchChunksByPhase(ms.chunks, phase, queryVector, limit)
+	ms.mu.RUnlock()
+
+	for i := range results {
+		results[i].Metadata = copyChunk(VectorChunk{Metadata: results[i].Metadata}).Metadata
+	}
+	return results, nil
+}
+
+// GetAllChunks 獲取所有向量塊（依 ID 排序）
+func (ms *MemoryStore) GetAllChunks() ([]VectorChunk, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	chunks := make([]VectorChunk, 0, len(ms.chunks))
+	for _, chunk := range ms.chunks {
+		chunks = append(chunks, copyChunk(chunk))
+	}
+	return chunks, nil
+}
+
+// Clear 清空所有向量塊
+func (ms *MemoryStore) Clear() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.chunks = nil
+	return nil
+}
+
+// DeleteByMetadata 根據元數據刪除向量塊
+func (ms *MemoryStore) DeleteByMetadata(key string, value interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.deleteLocked(key, value)
+	return nil
+}
+
+// ReplaceChunks 原子地刪除元數據符合的塊並寫入新塊
+func (ms *MemoryStore) ReplaceChunks(key string, value interface{}, chunks []VectorChunk) error {
+	prepared, err := prepareMemoryChunks(chunks, true)
+	if err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.deleteLocked(key, value)
+	ms.appendLocked(prepared)
+	return nil
+}
+
+// appendLocked 分配 ID 並寫入向量塊，略過內容雜湊已存在的塊（沒有內容雜湊的塊一律寫入），呼叫前需持有寫鎖
+func (ms *MemoryStore) appendLocked(chunks []VectorChunk) {
+	stored := make(map[interface{}]bool, len(ms.chunks))
+	for _, chunk := range ms.chunks {
+		if hash, ok := chunk.Metadata[ContentHashKey]; ok {
+			stored[hash] = true
+		}
+	}
+
+	for _, chunk := range chunks {
+		if hash, ok := chunk.Metadata[ContentHashKey]; ok && stored[hash] {
+			continue
+		}
+		chunk.ID = ms.nextID
+		ms.nextID++
+		ms.chunks = append(ms.chunks, chunk)
+	}
+}
+
+// deleteLocked 刪除元數據符合的向量塊，呼叫前需持有寫鎖
+func (ms *MemoryStore) deleteLocked(key string, value interface{}) {
+	kept := ms.chunks[:0]
+	for _, chunk := range ms.chunks {
+		if metaValue, ok := chunk.Metadata[key]; ok && metaValue == value {
+			continue
+		}
+		kept = append(kept, chunk)
+	}
+	ms.chunks = kept
+}
+
+// prepareMemoryChunks 以 JSON 往返複製元數據，使比對與讀取行為和 SQLite 後端一致；dedup 時同時加上內容雜湊
+func prepareMemoryChunks(chunks []VectorChunk, dedup bool) ([]VectorChunk, error) {
+	if dedup {
+		chunks, _ = hashChunks(chunks)
+	}
+	prepared := make([]VectorChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		var metadata map[string]interface{}
+		if chunk.Metadata != nil {
+			data, err := json.Marshal(chunk.Metadata)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal metadata: %v", err)
+			}
+			if err := json.Unmarshal(data, &metadata); err != nil {
+				return nil, fmt.Errorf("failed to copy metadata: %v", err)
+			}
+		}
+
+		prepared = append(prepared, VectorChunk{
+			Content:  chunk.Content,
+			Metadata: metadata,
+			Vector:   append([]float64(nil), chunk.Vector...),
+		})
+	}
+	return prepared, nil
+}
+
+// copyChunk 返回向量塊的副本，避免呼叫端修改存儲內容
+func copyChunk(chunk VectorChunk) VectorChunk {
+	chunkCopy := chunk
+	chunkCopy.Vector = append([]float64(nil), chunk.Vector...)
+	if chunk.Metadata != nil {
+		chunkCopy.Metadata = make(map[string]interface{}, len(chunk.Metadata))
+		for k, v := range chunk.Metadata {
+			chunkCopy.Metadata[k] = v
+		}
+	}
+	return chunkCopy
+}
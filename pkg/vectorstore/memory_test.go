@@ -0,0 +1,168 @@
+package vectorstore
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// newMemoryKnowledgeManager 以記憶體存儲與 simple 嵌入生成器建立知識管理器
+func newMemoryKnowledgeManager(t testing.TB) *KnowledgeManager {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.VectorStore.Backend = "memory"
+	cfg.VectorStore.EmbedderType = "simple"
+	cfg.VectorStore.EmbeddingDimension = 32
+	cfg.VectorStore.ChunkSize = 200
+	cfg.VectorStore.ChunkOverlap = 20
+	km, err := NewKnowledgeManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { km.Close() })
+	return km
+}
+
+// chunkCounts 以 metadata 欄位統計存儲中的塊數
+func chunkCounts(t *testing.T, km *KnowledgeManager, key string) map[string]int {
+	t.Helper()
+	chunks, err := km.vectorStore.GetAllChunks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, chunk := range chunks {
+		value, _ := chunk.Metadata[key].(string)
+		counts[value]++
+	}
+	return counts
+}
+
+func TestMemoryStorePhaseKnowledgeRoundTrip(t *testing.T) {
+	km := newMemoryKnowledgeManager(t)
+
+	knowledge := map[string]map[string]interface{}{
+		"phase1": {"tables": "customers orders payments"},
+		"phase2": {"business_logic": "orders belong to customers and are paid through payments"},
+	}
+	for phase, data := range knowledge {
+		if err := km.StorePhaseKnowledge(phase, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		phase       string
+		query       string
+		wantContent string
+	}{
+		{"phase1", "customers", "customers orders payments"},
+		{"phase2", "orders", "paid through payments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phase, func(t *testing.T) {
+			results, err := km.RetrievePhaseKnowledge(tt.phase, tt.query, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) == 0 {
+				t.Fatal("no results")
+			}
+			for _, result := range results {
+				if got := result.Metadata["phase"]; got != tt.phase {
+					t.Errorf("result from phase %v, want %s", got, tt.phase)
+				}
+			}
+			if !strings.Contains(results[0].Content, tt.wantContent) {
+				t.Errorf("top result %q does not contain %q", results[0].Content, tt.wantContent)
+			}
+		})
+	}
+
+	if results, err := km.RetrievePhaseKnowledge("phase3", "customers", 10); err != nil || len(results) != 0 {
+		t.Errorf("RetrievePhaseKnowledge(phase3) = %d results, %v; want none", len(results), err)
+	}
+}
+
+func TestMemoryStoreReplacesPhaseKnowledge(t *testing.T) {
+	km := newMemoryKnowledgeManager(t)
+
+	if err := km.StorePhaseKnowledge("phase1", map[string]interface{}{"tables": "legacy_orders"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := km.StorePhaseKnowledge("phase2", map[string]interface{}{"summary": "sales"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := km.StorePhaseKnowledge("phase1", map[string]interface{}{"tables": "orders"}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := km.RetrievePhaseKnowledge("phase1", "orders", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if strings.Contains(result.Content, "legacy_orders") {
+			t.Errorf("replaced knowledge still stored: %q", result.Content)
+		}
+	}
+	if counts := chunkCounts(t, km, "phase"); counts["phase2"] == 0 {
+		t.Errorf("other phase knowledge deleted: %v", counts)
+	}
+
+	if err := km.DeletePhaseKnowledge("phase1"); err != nil {
+		t.Fatal(err)
+	}
+	if counts := chunkCounts(t, km, "phase"); counts["phase1"] != 0 || counts["phase2"] == 0 {
+		t.Errorf("after DeletePhaseKnowledge(phase1) counts = %v", counts)
+	}
+}
+
+func TestMemoryStoreTableKnowledge(t *testing.T) {
+	km := newMemoryKnowledgeManager(t)
+
+	for _, table := range []string{"customers", "orders"} {
+		if err := km.StoreTableKnowledge("phase2", table, map[string]interface{}{"analysis": table + " analysis"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 重新存儲同一表格時應取代先前的塊
+	if err := km.StoreTableKnowledge("phase2", "orders", map[string]interface{}{"analysis": "orders analysis v2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	indexed, err := km.ListIndexedTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]IndexedTable{"phase2": {{Table: "customers", ChunkCount: 1}, {Table: "orders", ChunkCount: 1}}}
+	if !reflect.DeepEqual(indexed, want) {
+		t.Errorf("ListIndexedTables() = %v, want %v", indexed, want)
+	}
+
+	if err := km.DeleteTableKnowledge("phase2", "customers"); err != nil {
+		t.Fatal(err)
+	}
+	indexed, err = km.ListIndexedTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string][]IndexedTable{"phase2": {{Table: "orders", ChunkCount: 1}}}
+	if !reflect.DeepEqual(indexed, want) {
+		t.Errorf("after DeleteTableKnowledge ListIndexedTables() = %v, want %v", indexed, want)
+	}
+}
+
+func TestMemoryStoreIsolatedPerManager(t *testing.T) {
+	first := newMemoryKnowledgeManager(t)
+	second := newMemoryKnowledgeManager(t)
+
+	if err := first.StorePhaseKnowledge("phase1", map[string]interface{}{"tables": "customers"}); err != nil {
+		t.Fatal(err)
+	}
+	if counts := chunkCounts(t, second, "phase"); len(counts) != 0 {
+		t.Errorf("second manager sees chunks from the first: %v", counts)
+	}
+}
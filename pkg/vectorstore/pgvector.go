@@ -0,0 +1,349 @@
+package vectorstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/masato25/aika-dba/config"
+)
+
+// validIdentifier 合法的 PostgreSQL 識別字（表格名稱）
+var validIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// PGVectorStore 以 pgvector 擴充的 PostgreSQL 表格作為向量存儲
+type PGVectorStore struct {
+	db        *sql.DB
+	table     string
+	dimension int
+}
+
+// NewPGVectorStore 創建 pgvector 向量存儲
+// 未設定 pgvector_dsn 時重用分析用的 PostgreSQL 資料庫連接設定
+func NewPGVectorStore(cfg *config.Config) (*PGVectorStore, error) {
+	dsn := cfg.VectorStore.PGVectorDSN
+	if dsn == "" {
+		if cfg.Database.Type != "postgres" {
+			return nil, fmt.Errorf("pgvector backend requires vectorstore.pgvector_dsn when database type is %s", cfg.Database.Type)
+		}
+		dsn = cfg.GetDatabaseDSN()
+	}
+
+	table := cfg.VectorStore.PGVectorTable
+	if table == "" {
+		table = "aika_vector_chunks"
+	}
+	if !validIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid pgvector table name: %s", table)
+	}
+
+	if cfg.VectorStore.EmbeddingDimension <= 0 {
+		return nil, fmt.Errorf("pgvector backend requires a positive embedding_dimension")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pgvector database: %v", err)
+	}
+
+	store := &PGVectorStore{
+		db:        db,
+		table:     table,
+		dimension: cfg.VectorStore.EmbeddingDimension,
+	}
+
+	if err := store.initTables(cfg.VectorStore.PGVectorIndex); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize pgvector tables: %v", err)
+	}
+
+	return store, nil
+}
+
+// initTables 建立 pgvector 擴充、向量表格及相似度索引
+func (vs *PGVectorStore) initTables(indexType string) error {
+	if _, err := vs.db.Exec("CREATE EXTENSION IF NOT EXISTS vector"); err != nil {
+		return fmt.Errorf("failed to enable pgvector extension: %v", err)
+	}
+
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		id SERIAL PRIMARY KEY,
+		content TEXT NOT NULL,
+		metadata JSONB,
+		vector vector(%d) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`, vs.table, vs.dimension)
+	if _, err := vs.db.Exec(createTableSQL); err != nil {
+		return err
+	}
+
+	// 內容雜湊作為冪等鍵；舊表格補上欄位後既有的塊為 NULL，不受唯一索引限制
+	if _, err := vs.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS content_hash TEXT", vs.table)); err != nil {
+		return fmt.Errorf("failed to add content_hash column: %v", err)
+	}
+	if _, err := vs.db.Exec(fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s_content_hash_idx ON %s (content_hash)", vs.table, vs.table)); err != nil {
+		return fmt.Errorf("failed to create content_hash index: %v", err)
+	}
+
+	// phase 過濾索引，供 SearchByPhase 使用
+	if _, err := vs.db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_phase_idx ON %s ((metadata->>'phase'))", vs.table, vs.table)); err != nil {
+		return fmt.Errorf("failed to create phase index: %v", err)
+	}
+
+	var indexSQL string
+	switch indexType {
+	case "", "hnsw":
+		indexSQL = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_vector_idx ON %s USING hnsw (vector vector_cosine_ops)", vs.table, vs.table)
+	case "ivfflat":
+		indexSQL = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_vector_idx ON %s USING ivfflat (vector vector_cosine_ops) WITH (lists = 100)", vs.table, vs.table)
+	case "none":
+		return nil
+	default:
+		return fmt.Errorf("unknown pgvector index type: %s (supported: hnsw, ivfflat, none)", indexType)
+	}
+
+	_, err := vs.db.Exec(indexSQL)
+	return err
+}
+
+// Close 關閉向量存儲
+func (vs *PGVectorStore) Close() error {
+	if vs.db != nil {
+		return vs.db.Close()
+	}
+	return nil
+}
+
+// AddChunk 添加向量塊，已存在相同內容與元數據的塊時略過
+func (vs *PGVectorStore) AddChunk(content string, metadata map[string]interface{}, vector []float64) error {
+	return vs.AddChunks([]VectorChunk{{Content: content, Metadata: metadata, Vector: vector}})
+}
+
+// AddChunks 在單一交易中批次添加向量塊（忽略 ID 欄位），以內容雜湊略過已存在的塊
+func (vs *PGVectorStore) AddChunks(chunks []VectorChunk) error {
+	return vs.addChunks(chunks, true)
+}
+
+// AppendChunks 在單一交易中附加向量塊，不以內容雜湊去重（content_hash 為 NULL，不受唯一索引限制）
+func (vs *PGVectorStore) AppendChunks(chunks []VectorChunk) error {
+	return vs.addChunks(chunks, false)
+}
+
+// StoredHashes 以 content_hash 索引查詢已存儲的內容雜湊，不讀取其他塊
+func (vs *PGVectorStore) StoredHashes(hashes []string) (map[string]bool, error) {
+	stored := make(map[string]bool)
+	if len(hashes) == 0 {
+		return stored, nil
+	}
+
+	rows, err := vs.db.Query(fmt.Sprintf("SELECT content_hash FROM %s WHERE content_hash = ANY($1)", vs.table), pq.Array(hashes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up content hashes: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("failed to scan content hash: %v", err)
+		}
+		stored[hash] = true
+	}
+	return stored, rows.Err()
+}
+
+// addChunks 在單一交易中寫入向量塊
+func (vs *PGVectorStore) addChunks(chunks []VectorChunk, dedup bool) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	tx, err := vs.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := vs.insertChunksTx(tx, chunks, dedup); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceChunks 在單一交易中刪除元數據符合的塊並寫入新塊
+func (vs *PGVectorStore) ReplaceChunks(key string, value interface{}, chunks []VectorChunk) error {
+	tx, err := vs.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE metadata->>$1 = $2", vs.table), key, fmt.Sprintf("%v", value)); err != nil {
+		return fmt.Errorf("failed to delete chunks: %v", err)
+	}
+	if err := vs.insertChunksTx(tx, chunks, true); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertChunksTx 在交易中寫入向量塊；dedup 時 content_hash 已存在的塊會被略過，否則不記錄 content_hash 直接附加
+func (vs *PGVectorStore) insertChunksTx(tx *sql.Tx, chunks []VectorChunk, dedup bool) error {
+	insertSQL := fmt.Sprintf("INSERT INTO %s (content, metadata, vector, content_hash) VALUES ($1, $2, $3::vector, $4)", vs.table)
+	if dedup {
+		insertSQL += " ON CONFLICT (content_hash) DO NOTHING"
+	}
+	stmt, err := tx.Prepare(insertSQL)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %v", err)
+	}
+	defer stmt.Close()
+
+	var hashes []string
+	if dedup {
+		chunks, hashes = hashChunks(chunks)
+	}
+	for i, chunk := range chunks {
+		if len(chunk.Vector) != vs.dimension {
+			return fmt.Errorf("vector dimension %d does not match pgvector column dimension %d", len(chunk.Vector), vs.dimension)
+		}
+
+		metadataJSON, err := json.Marshal(chunk.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %v", err)
+		}
+
+		var hash interface{}
+		if dedup {
+			hash = hashes[i]
+		}
+		if _, err := stmt.Exec(chunk.Content, string(metadataJSON), formatPGVector(chunk.Vector), hash); err != nil {
+			return fmt.Errorf("failed to insert chunk: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// SearchSimilar 使用 <=> 餘弦距離運算子搜索相似向量
+func (vs *PGVectorStore) SearchSimilar(queryVector []float64, limit int) ([]VectorChunk, error) {
+	rows, err := vs.db.Query(
+		fmt.Sprintf("SELECT id, content, metadata, vector::text FROM %s ORDER BY vector <=> $1::vector LIMIT $2", vs.table),
+		formatPGVector(queryVector), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPGVectorChunks(rows)
+}
+
+// SearchByPhase 在資料庫中過濾指定 phase 並以 <=> 餘弦距離取最相似的 limit 個塊，分數為 1 - 距離
+func (vs *PGVectorStore) SearchByPhase(phase string, queryVector []float64, limit int) ([]KnowledgeResult, error) {
+	rows, err := vs.db.Query(
+		fmt.Sprintf("SELECT content, metadata, 1 - (vector <=> $1::vector) FROM %s WHERE metadata->>'phase' = $2 ORDER BY vector <=> $1::vector LIMIT $3", vs.table),
+		formatPGVector(queryVector), phase, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []KnowledgeResult
+	for rows.Next() {
+		var content string
+		var metadataStr sql.NullString
+		var score float64
+		if err := rows.Scan(&content, &metadataStr, &score); err != nil {
+			continue
+		}
+
+		var metadata map[string]interface{}
+		if metadataStr.Valid && metadataStr.String != "" {
+			json.Unmarshal([]byte(metadataStr.String), &metadata)
+		}
+
+		results = append(results, KnowledgeResult{
+			Content:  content,
+			Metadata: metadata,
+			Score:    score,
+		})
+	}
+
+	return results, rows.Err()
+}
+
+// GetAllChunks 獲取所有向量塊
+func (vs *PGVectorStore) GetAllChunks() ([]VectorChunk, error) {
+	rows, err := vs.db.Query(fmt.Sprintf("SELECT id, content, metadata, vector::text FROM %s ORDER BY id", vs.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPGVectorChunks(rows)
+}
+
+// Clear 清空所有向量塊
+func (vs *PGVectorStore) Clear() error {
+	_, err := vs.db.Exec(fmt.Sprintf("DELETE FROM %s", vs.table))
+	return err
+}
+
+// DeleteByMetadata 根據元數據刪除向量塊
+func (vs *PGVectorStore) DeleteByMetadata(key string, value interface{}) error {
+	_, err := vs.db.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE metadata->>$1 = $2", vs.table),
+		key, fmt.Sprintf("%v", value),
+	)
+	return err
+}
+
+// scanPGVectorChunks 讀取查詢結果中的向量塊
+func scanPGVectorChunks(rows *sql.Rows) ([]VectorChunk, error) {
+	var chunks []VectorChunk
+	for rows.Next() {
+		var id int
+		var content, vectorStr string
+		var metadataStr sql.NullString
+
+		if err := rows.Scan(&id, &content, &metadataStr, &vectorStr); err != nil {
+			continue
+		}
+
+		// pgvector 的文字格式與 JSON 陣列相同，例如 [0.1,0.2,0.3]
+		var vector []float64
+		if err := json.Unmarshal([]byte(vectorStr), &vector); err != nil {
+			continue
+		}
+
+		var metadata map[string]interface{}
+		if metadataStr.Valid && metadataStr.String != "" {
+			json.Unmarshal([]byte(metadataStr.String), &metadata)
+		}
+
+		chunks = append(chunks, VectorChunk{
+			ID:       id,
+			Content:  content,
+			Metadata: metadata,
+			Vector:   vector,
+		})
+	}
+
+	return chunks, rows.Err()
+}
+
+// formatPGVector 將向量轉換為 pgvector 的文字格式
+func formatPGVector(vector []float64) string {
+	parts := make([]string, len(vector))
+	for i, v := range vector {
+		parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
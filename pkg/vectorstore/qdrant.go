@@ -0,0 +1,453 @@
+package vectorstore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// QdrantStore 透過 HTTP API 使用外部 Qdrant 實例作為向量存儲
+type QdrantStore struct {
+	baseURL    string
+	collection string
+	apiKey     string
+	dimension  int
+	httpClient *http.Client
+}
+
+// qdrantPoint Qdrant 點資料；新寫入的點 ID 為 UUID 字串，舊版寫入的點為整數
+type qdrantPoint struct {
+	ID      interface{}            `json:"id"`
+	Vector  []float64              `json:"vector,omitempty"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+	Score   float64                `json:"score,omitempty"`
+}
+
+// NewQdrantStore 創建 Qdrant 向量存儲，集合不存在時自動建立
+func NewQdrantStore(cfg *config.Config) (*QdrantStore, error) {
+	baseURL := strings.TrimRight(cfg.VectorStore.QdrantURL, "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:6333"
+	}
+
+	collection := cfg.VectorStore.QdrantCollection
+	if collection == "" {
+		collection = "aika_knowledge"
+	}
+
+	if cfg.VectorStore.EmbeddingDimension <= 0 {
+		return nil, fmt.Errorf("qdrant backend requires a positive embedding_dimension")
+	}
+
+	store := &QdrantStore{
+		baseURL:    baseURL,
+		collection: collection,
+		apiKey:     cfg.VectorStore.QdrantAPIKey,
+		dimension:  cfg.VectorStore.EmbeddingDimension,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	if err := store.ensureCollection(); err != nil {
+		return nil, fmt.Errorf("failed to initialize qdrant collection %s: %v", collection, err)
+	}
+
+	return store, nil
+}
+
+// ensureCollection 確認集合存在，否則以餘弦距離建立
+func (qs *QdrantStore) ensureCollection() error {
+	status, _, err := qs.do(http.MethodGet, "/collections/"+qs.collection, nil)
+	if err != nil && status != http.StatusNotFound {
+		return err
+	}
+	if status == http.StatusOK {
+		return nil
+	}
+
+	_, _, err = qs.do(http.MethodPut, "/collections/"+qs.collection, map[string]interface{}{
+		"vectors": map[string]interface{}{
+			"size":     qs.dimension,
+			"distance": "Cosine",
+		},
+	})
+	return err
+}
+
+// Close 關閉向量存儲（HTTP 客戶端無需釋放資源）
+func (qs *QdrantStore) Close() error {
+	return nil
+}
+
+// AddChunk 以 upsert 方式添加向量塊，元數據存放於 payload 中
+func (qs *QdrantStore) AddChunk(content string, metadata map[string]interface{}, vector []float64) error {
+	return qs.AddChunks([]VectorChunk{{Content: content, Metadata: metadata, Vector: vector}})
+}
+
+// AddChunks 以單一 upsert 請求批次添加向量塊（忽略 ID 欄位），以內容雜湊略過已存在的塊
+func (qs *QdrantStore) AddChunks(chunks []VectorChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	chunks, hashes := hashChunks(chunks)
+	stored, err := qs.StoredHashes(hashes)
+	if err != nil {
+		return err
+	}
+
+	fresh := make([]VectorChunk, 0, len(chunks))
+	for i, chunk := range chunks {
+		if !stored[hashes[i]] {
+			fresh = append(fresh, chunk)
+		}
+	}
+	return qs.upsertPoints(fresh, hashPointID)
+}
+
+// AppendChunks 以單一 upsert 請求附加向量塊，不以內容雜湊去重（每個點使用隨機 UUID，相同內容不會互相覆寫）
+func (qs *QdrantStore) AppendChunks(chunks []VectorChunk) error {
+	return qs.upsertPoints(chunks, randomPointID)
+}
+
+// upsertPoints 以 pointID 為每個塊產生點 ID 並寫入集合
+func (qs *QdrantStore) upsertPoints(chunks []VectorChunk, pointID func(VectorChunk) (string, error)) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	points := make([]qdrantPoint, 0, len(chunks))
+	for _, chunk := range chunks {
+		if len(chunk.Vector) != qs.dimension {
+			return fmt.Errorf("vector dimension %d does not match qdrant collection dimension %d", len(chunk.Vector), qs.dimension)
+		}
+		id, err := pointID(chunk)
+		if err != nil {
+			return err
+		}
+		points = append(points, qdrantPoint{
+			ID:     id,
+			Vector: chunk.Vector,
+			Payload: map[string]interface{}{
+				"content":  chunk.Content,
+				"metadata": chunk.Metadata,
+			},
+		})
+	}
+
+	_, _, err := qs.do(http.MethodPut, "/collections/"+qs.collection+"/points?wait=true", map[string]interface{}{
+		"points": points,
+	})
+	return err
+}
+
+// hashPointID 由內容雜湊產生 UUID 點 ID：同一個塊在任何程序中都對應同一個點，重複寫入只會覆寫自身
+func hashPointID(chunk VectorChunk) (string, error) {
+	hash, ok := chunk.Metadata[ContentHashKey].(string)
+	if !ok || hash == "" {
+		hash = ChunkContentHash(chunk.Content, chunk.Metadata)
+	}
+	digest, err := hex.DecodeString(hash)
+	if err != nil || len(digest) < 16 {
+		return "", fmt.Errorf("invalid content hash %q for qdrant point id", hash)
+	}
+	return formatUUID(digest[:16]), nil
+}
+
+// randomPointID 返回隨機 UUID 點 ID
+func randomPointID(VectorChunk) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate qdrant point id: %v", err)
+	}
+	return formatUUID(b), nil
+}
+
+// formatUUID 將 16 bytes 設定 RFC 4122 版本與變體位元後格式化為 UUID 字串
+func formatUUID(b []byte) string {
+	u := make([]byte, 16)
+	copy(u, b)
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(u)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// StoredHashes 以 payload 過濾查詢集合中已存在的內容雜湊
+func (qs *QdrantStore) StoredHashes(hashes []string) (map[string]bool, error) {
+	stored := make(map[string]bool)
+	if len(hashes) == 0 {
+		return stored, nil
+	}
+
+	var offset interface{}
+	for {
+		body := map[string]interface{}{
+			"limit":        256,
+			"with_payload": []string{"metadata." + ContentHashKey},
+			"with_vector":  false,
+			"filter": map[string]interface{}{
+				"must": []map[string]interface{}{
+					{"key": "metadata." + ContentHashKey, "match": map[string]interface{}{"any": hashes}},
+				},
+			},
+		}
+		if offset != nil {
+			body["offset"] = offset
+		}
+
+		_, respBody, err := qs.do(http.MethodPost, "/collections/"+qs.collection+"/points/scroll", body)
+		if err != nil {
+			return nil, err
+		}
+
+		var response struct {
+			Result struct {
+				Points         []qdrantPoint `json:"points"`
+				NextPageOffset interface{}   `json:"next_page_offset"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(respBody, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse qdrant scroll response: %v", err)
+		}
+
+		for _, point := range response.Result.Points {
+			if hash, ok := point.toChunk().Metadata[ContentHashKey].(string); ok {
+				stored[hash] = true
+			}
+		}
+
+		if response.Result.NextPageOffset == nil {
+			break
+		}
+		offset = response.Result.NextPageOffset
+	}
+	return stored, nil
+}
+
+// SearchSimilar 搜索相似向量
+func (qs *QdrantStore) SearchSimilar(queryVector []float64, limit int) ([]VectorChunk, error) {
+	return qs.SearchSimilarWithFilter(queryVector, limit, nil)
+}
+
+// SearchSimilarWithFilter 搜索相似向量，只返回元數據符合過濾條件的塊
+func (qs *QdrantStore) SearchSimilarWithFilter(queryVector []float64, limit int, filter map[string]interface{}) ([]VectorChunk, error) {
+	points, err := qs.search(queryVector, limit, filter, true)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make([]VectorChunk, 0, len(points))
+	for _, point := range points {
+		chunks = append(chunks, point.toChunk())
+	}
+	return chunks, nil
+}
+
+// SearchByPhase 以 payload 的 metadata.phase 過濾，由 Qdrant 返回指定 phase 中最相似的 limit 個塊，分數為餘弦相似度
+func (qs *QdrantStore) SearchByPhase(phase string, queryVector []float64, limit int) ([]KnowledgeResult, error) {
+	points, err := qs.search(queryVector, limit, map[string]interface{}{"phase": phase}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]KnowledgeResult, 0, len(points))
+	for _, point := range points {
+		chunk := point.toChunk()
+		results = append(results, KnowledgeResult{
+			Content:  chunk.Content,
+			Metadata: chunk.Metadata,
+			Score:    point.Score,
+		})
+	}
+	return results, nil
+}
+
+// search 發送相似度搜索請求，filter 為元數據過濾條件
+func (qs *QdrantStore) search(queryVector []float64, limit int, filter map[string]interface{}, withVector bool) ([]qdrantPoint, error) {
+	body := map[string]interface{}{
+		"vector":       queryVector,
+		"limit":        limit,
+		"with_payload": true,
+		"with_vector":  withVector,
+	}
+	if len(filter) > 0 {
+		body["filter"] = qdrantMetadataFilter(filter)
+	}
+
+	_, respBody, err := qs.do(http.MethodPost, "/collections/"+qs.collection+"/points/search", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Result []qdrantPoint `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse qdrant search response: %v", err)
+	}
+	return response.Result, nil
+}
+
+// GetAllChunks 以分頁方式獲取所有向量塊
+func (qs *QdrantStore) GetAllChunks() ([]VectorChunk, error) {
+	var chunks []VectorChunk
+	var offset interface{}
+
+	for {
+		body := map[string]interface{}{
+			"limit":        256,
+			"with_payload": true,
+			"with_vector":  true,
+		}
+		if offset != nil {
+			body["offset"] = offset
+		}
+
+		_, respBody, err := qs.do(http.MethodPost, "/collections/"+qs.collection+"/points/scroll", body)
+		if err != nil {
+			return nil, err
+		}
+
+		var response struct {
+			Result struct {
+				Points         []qdrantPoint `json:"points"`
+				NextPageOffset interface{}   `json:"next_page_offset"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(respBody, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse qdrant scroll response: %v", err)
+		}
+
+		for _, point := range response.Result.Points {
+			chunks = append(chunks, point.toChunk())
+		}
+
+		if response.Result.NextPageOffset == nil {
+			break
+		}
+		offset = response.Result.NextPageOffset
+	}
+
+	// UUID 點沒有整數 ID（為 0），排序只影響舊版寫入的點
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].ID < chunks[j].ID
+	})
+	return chunks, nil
+}
+
+// Clear 刪除並重建集合以清空所有向量塊
+func (qs *QdrantStore) Clear() error {
+	if _, _, err := qs.do(http.MethodDelete, "/collections/"+qs.collection, nil); err != nil {
+		return err
+	}
+	return qs.ensureCollection()
+}
+
+// DeleteByMetadata 根據元數據刪除向量塊
+func (qs *QdrantStore) DeleteByMetadata(key string, value interface{}) error {
+	_, _, err := qs.do(http.MethodPost, "/collections/"+qs.collection+"/points/delete?wait=true", map[string]interface{}{
+		"filter": qdrantMetadataFilter(map[string]interface{}{key: value}),
+	})
+	return err
+}
+
+// ReplaceChunks 刪除元數據符合的塊並寫入新塊
+// Qdrant 不支援跨請求交易，因此先寫入新塊再刪除舊塊，失敗時最多留下重複的塊而不會遺失知識
+func (qs *QdrantStore) ReplaceChunks(key string, value interface{}, chunks []VectorChunk) error {
+	if err := qs.AddChunks(chunks); err != nil {
+		return err
+	}
+
+	// 以過濾條件刪除舊塊，不需讀取整個集合；內容雜湊屬於新塊的點（包括寫入時因重複而保留的舊塊）不刪除
+	filter := qdrantMetadataFilter(map[string]interface{}{key: value})
+	if _, newHashes := hashChunks(chunks); len(newHashes) > 0 {
+		filter["must_not"] = []map[string]interface{}{
+			{"key": "metadata." + ContentHashKey, "match": map[string]interface{}{"any": newHashes}},
+		}
+	}
+
+	_, _, err := qs.do(http.MethodPost, "/collections/"+qs.collection+"/points/delete?wait=true", map[string]interface{}{
+		"filter": filter,
+	})
+	return err
+}
+
+// qdrantMetadataFilter 將元數據過濾條件轉換為 Qdrant 的 filter 語法（所有條件皆須符合）
+func qdrantMetadataFilter(filter map[string]interface{}) map[string]interface{} {
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	must := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		must = append(must, map[string]interface{}{
+			"key":   "metadata." + key,
+			"match": map[string]interface{}{"value": filter[key]},
+		})
+	}
+
+	return map[string]interface{}{"must": must}
+}
+
+// toChunk 將 Qdrant 點轉換為向量塊
+func (p qdrantPoint) toChunk() VectorChunk {
+	content, _ := p.Payload["content"].(string)
+	metadata, _ := p.Payload["metadata"].(map[string]interface{})
+	// JSON 數字解碼為 float64；UUID 點 ID 無法對應整數 ID
+	id, _ := p.ID.(float64)
+	return VectorChunk{
+		ID:       int(id),
+		Content:  content,
+		Metadata: metadata,
+		Vector:   p.Vector,
+	}
+}
+
+// do 發送 HTTP 請求到 Qdrant，返回狀態碼及回應內容
+func (qs *QdrantStore) do(method, path string, body interface{}) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to marshal qdrant request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, qs.baseURL+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if qs.apiKey != "" {
+		req.Header.Set("api-key", qs.apiKey)
+	}
+
+	resp, err := qs.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("qdrant request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("failed to read qdrant response: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, respBody, fmt.Errorf("qdrant %s %s returned status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return resp.StatusCode, respBody, nil
+}
@@ -0,0 +1,195 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/masato25/aika-dba/config"
+)
+
+// qdrantRequest 假 Qdrant 伺服器收到的請求
+type qdrantRequest struct {
+	Method string
+	Path   string
+	Body   map[string]interface{}
+}
+
+// newFakeQdrant 記錄所有請求，搜索請求返回 searchResult，其餘返回空結果
+func newFakeQdrant(t *testing.T, searchResult string) (*QdrantStore, func() []qdrantRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []qdrantRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		requests = append(requests, qdrantRequest{Method: r.Method, Path: r.URL.Path, Body: body})
+		mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/points/search"):
+			w.Write([]byte(searchResult))
+		case strings.HasSuffix(r.URL.Path, "/points/scroll"):
+			w.Write([]byte(`{"result":{"points":[],"next_page_offset":null}}`))
+		default:
+			w.Write([]byte(`{"result":{}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.VectorStore.QdrantURL = server.URL
+	cfg.VectorStore.QdrantCollection = "test"
+	cfg.VectorStore.EmbeddingDimension = 2
+	store, err := NewQdrantStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, func() []qdrantRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]qdrantRequest(nil), requests...)
+	}
+}
+
+func TestQdrantSearchByPhase(t *testing.T) {
+	store, requests := newFakeQdrant(t, `{"result":[
+		{"id":1,"score":0.9,"payload":{"content":"orders","metadata":{"phase":"phase2"}}},
+		{"id":2,"score":0.4,"payload":{"content":"customers","metadata":{"phase":"phase2"}}}
+	]}`)
+
+	var searcher PhaseSearcher = store
+	results, err := searcher.SearchByPhase("phase2", []float64{1, 0}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []KnowledgeResult{
+		{Content: "orders", Metadata: map[string]interface{}{"phase": "phase2"}, Score: 0.9},
+		{Content: "customers", Metadata: map[string]interface{}{"phase": "phase2"}, Score: 0.4},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("SearchByPhase() = %+v, want %+v", results, want)
+	}
+
+	var search *qdrantRequest
+	for _, request := range requests() {
+		if strings.HasSuffix(request.Path, "/points/search") {
+			request := request
+			search = &request
+		}
+	}
+	if search == nil {
+		t.Fatal("no search request sent")
+	}
+	wantFilter := map[string]interface{}{
+		"must": []interface{}{
+			map[string]interface{}{"key": "metadata.phase", "match": map[string]interface{}{"value": "phase2"}},
+		},
+	}
+	if !reflect.DeepEqual(search.Body["filter"], wantFilter) {
+		t.Errorf("search filter = %v, want %v", search.Body["filter"], wantFilter)
+	}
+	if search.Body["limit"] != float64(2) {
+		t.Errorf("search limit = %v, want 2", search.Body["limit"])
+	}
+}
+
+func TestQdrantReplaceChunksDeletesByFilter(t *testing.T) {
+	store, requests := newFakeQdrant(t, `{"result":[]}`)
+
+	chunks := []VectorChunk{{Content: "orders", Metadata: map[string]interface{}{"phase": "phase2"}, Vector: []float64{1, 0}}}
+	if err := store.ReplaceChunks("phase", "phase2", chunks); err != nil {
+		t.Fatal(err)
+	}
+
+	var deletes []qdrantRequest
+	for _, request := range requests() {
+		if strings.HasSuffix(request.Path, "/points/delete") {
+			deletes = append(deletes, request)
+		}
+		// 只應以內容雜湊查詢既有塊，不應讀取整個集合
+		if strings.HasSuffix(request.Path, "/points/scroll") && request.Body["filter"] == nil {
+			t.Errorf("ReplaceChunks scrolled the whole collection: %v", request.Body)
+		}
+	}
+	if len(deletes) != 1 {
+		t.Fatalf("got %d delete requests, want 1", len(deletes))
+	}
+
+	filter, ok := deletes[0].Body["filter"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("delete request has no filter: %v", deletes[0].Body)
+	}
+	if _, byID := deletes[0].Body["points"]; byID {
+		t.Errorf("delete request lists point IDs: %v", deletes[0].Body)
+	}
+
+	wantMust := []interface{}{
+		map[string]interface{}{"key": "metadata.phase", "match": map[string]interface{}{"value": "phase2"}},
+	}
+	if !reflect.DeepEqual(filter["must"], wantMust) {
+		t.Errorf("delete must = %v, want %v", filter["must"], wantMust)
+	}
+	wantMustNot := []interface{}{
+		map[string]interface{}{"key": "metadata." + ContentHashKey, "match": map[string]interface{}{
+			"any": []interface{}{ChunkContentHash("orders", map[string]interface{}{"phase": "phase2"})},
+		}},
+	}
+	if !reflect.DeepEqual(filter["must_not"], wantMustNot) {
+		t.Errorf("delete must_not = %v, want %v", filter["must_not"], wantMustNot)
+	}
+}
+
+func TestQdrantPointIDs(t *testing.T) {
+	store, requests := newFakeQdrant(t, `{"result":[]}`)
+	chunk := VectorChunk{Content: "orders", Metadata: map[string]interface{}{"phase": "phase2"}, Vector: []float64{1, 0}}
+
+	upsertedIDs := func() []string {
+		var ids []string
+		for _, req := range requests() {
+			if req.Method != http.MethodPut || !strings.HasSuffix(req.Path, "/points") {
+				continue
+			}
+			for _, point := range req.Body["points"].([]interface{}) {
+				ids = append(ids, point.(map[string]interface{})["id"].(string))
+			}
+		}
+		return ids
+	}
+
+	// 相同的塊每次寫入都對應同一個由內容雜湊產生的 UUID
+	if err := store.AddChunks([]VectorChunk{chunk}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddChunks([]VectorChunk{chunk}); err != nil {
+		t.Fatal(err)
+	}
+	want, err := hashPointID(chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := upsertedIDs(); len(ids) != 2 || ids[0] != want || ids[1] != want {
+		t.Errorf("AddChunks point ids = %v, want [%s %s]", ids, want, want)
+	}
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if !uuidPattern.MatchString(want) {
+		t.Errorf("point id %q is not a UUID", want)
+	}
+
+	// append 刻意保留重複的塊，每個點使用不同的 ID
+	if err := store.AppendChunks([]VectorChunk{chunk, chunk}); err != nil {
+		t.Fatal(err)
+	}
+	ids := upsertedIDs()
+	if len(ids) != 4 || ids[2] == ids[3] || ids[2] == want {
+		t.Errorf("AppendChunks point ids = %v, want two distinct random ids", ids[2:])
+	}
+}
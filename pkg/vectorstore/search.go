@@ -0,0 +1,98 @@
+package vectorstore
+
+import (
+	"fmt"
+	"sort"
+)
+
+// KnowledgeSearch 跨 phase 知識搜索的條件
+type KnowledgeSearch struct {
+	Phases   []string // 為空時搜索所有已索引的 phase
+	Limit    int
+	Offset   int
+	MinScore float64 // 相似度低於此值的塊不列入結果與總數
+}
+
+// KnowledgeSearchResult 一頁搜索結果、符合條件的總數與實際搜索的 phase
+type KnowledgeSearchResult struct {
+	Results []KnowledgeResult
+	Total   int
+	Phases  []string
+}
+
+// SearchKnowledge 在指定 phase（預設為所有已索引的 phase）中搜索，返回依相似度排序的第 offset 起 limit 個結果
+func (km *KnowledgeManager) SearchKnowledge(query string, search KnowledgeSearch) (*KnowledgeSearchResult, error) {
+	queryVector, err := km.embedder.GenerateEmbedding(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %v", err)
+	}
+
+	allChunks, err := km.vectorStore.GetAllChunks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks: %v", err)
+	}
+
+	phases := search.Phases
+	if len(phases) == 0 {
+		phases = indexedPhases(allChunks)
+	}
+	wanted := make(map[string]bool, len(phases))
+	for _, phase := range phases {
+		wanted[phase] = true
+	}
+
+	if search.Offset < 0 {
+		search.Offset = 0
+	}
+
+	// 只保留到 offset+limit 名（不超過候選塊數），其餘只計入總數
+	collector := newTopKCollector(topKSize(search.Offset, search.Limit, len(allChunks)))
+	total := 0
+	for _, chunk := range allChunks {
+		if !wanted[chunkPhase(chunk)] {
+			continue
+		}
+		score := cosineSimilarity(queryVector, chunk.Vector)
+		if score < search.MinScore {
+			continue
+		}
+		total++
+		collector.add(KnowledgeResult{
+			Content:  chunk.Content,
+			Metadata: chunk.Metadata,
+			Score:    score,
+		})
+	}
+
+	results := collector.results()
+	if search.Offset >= len(results) {
+		results = []KnowledgeResult{}
+	} else {
+		results = results[search.Offset:]
+	}
+
+	return &KnowledgeSearchResult{Results: results, Total: total, Phases: phases}, nil
+}
+
+// topKSize 返回 min(offset+limit, candidates)，offset+limit 溢位時視為 candidates
+func topKSize(offset, limit, candidates int) int {
+	size := offset + limit
+	if size < offset || size > candidates {
+		return candidates
+	}
+	return size
+}
+
+// indexedPhases 返回向量塊中出現的所有 phase（排序後）
+func indexedPhases(chunks []VectorChunk) []string {
+	seen := make(map[string]bool)
+	var phases []string
+	for _, chunk := range chunks {
+		if phase := chunkPhase(chunk); phase != "" && !seen[phase] {
+			seen[phase] = true
+			phases = append(phases, phase)
+		}
+	}
+	sort.Strings(phases)
+	return phases
+}
@@ -0,0 +1,160 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// addSyntheticChunks 直接寫入 n 個屬於 phase 的塊
+func addSyntheticChunks(t testing.TB, km *KnowledgeManager, phase string, n int) {
+	t.Helper()
+	chunks := make([]VectorChunk, n)
+	for i := range chunks {
+		content := fmt.Sprintf("%s chunk %d about table_%d", phase, i, i%50)
+		vector, err := km.embedder.GenerateEmbedding(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks[i] = VectorChunk{Content: content, Metadata: map[string]interface{}{"phase": phase}, Vector: vector}
+	}
+	if err := km.vectorStore.AddChunks(chunks); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchKnowledgeLargeOffset(t *testing.T) {
+	km := newMemoryKnowledgeManager(t)
+	addSyntheticChunks(t, km, "phase2", 10)
+
+	tests := []struct {
+		name        string
+		offset      int
+		limit       int
+		wantResults int
+	}{
+		{"first page", 0, 5, 5},
+		{"last partial page", 8, 5, 2},
+		{"offset past candidates", 50, 5, 0},
+		{"huge offset", math.MaxInt - 2, 5, 0},
+		{"negative offset", -3, 5, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := km.SearchKnowledge("customers", KnowledgeSearch{Offset: tt.offset, Limit: tt.limit})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found.Results) != tt.wantResults {
+				t.Errorf("got %d results, want %d", len(found.Results), tt.wantResults)
+			}
+			if found.Total != 10 {
+				t.Errorf("Total = %d, want 10", found.Total)
+			}
+		})
+	}
+}
+
+// scanOnlyStore 隱藏後端的 SearchByPhase，讓 RetrievePhaseKnowledge 走讀取全部塊再過濾的路徑
+type scanOnlyStore struct {
+	Store
+}
+
+func BenchmarkSearchKnowledge(b *testing.B) {
+	km := newMemoryKnowledgeManager(b)
+	for _, phase := range []string{"phase1", "phase2", "phase3", "phase4", "phase5"} {
+		addSyntheticChunks(b, km, phase, 1000)
+	}
+	scanning := &KnowledgeManager{vectorStore: scanOnlyStore{km.vectorStore}, embedder: km.embedder, config: km.config}
+
+	b.Run("SearchByPhase", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := km.RetrievePhaseKnowledge("phase2", "customers orders", 10); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("ScanAllChunks", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := scanning.RetrievePhaseKnowledge("phase2", "customers orders", 10); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("AllPhases", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := km.SearchKnowledge("customers orders", KnowledgeSearch{Limit: 10}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestRetrievePhaseKnowledgeMatchesScan(t *testing.T) {
+	km := newMemoryKnowledgeManager(t)
+	addSyntheticChunks(t, km, "phase1", 200)
+	addSyntheticChunks(t, km, "phase2", 200)
+	scanning := &KnowledgeManager{vectorStore: scanOnlyStore{km.vectorStore}, embedder: km.embedder, config: km.config}
+
+	for _, limit := range []int{1, 10, 500} {
+		indexed, err := km.RetrievePhaseKnowledge("phase2", "table_7", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanned, err := scanning.RetrievePhaseKnowledge("phase2", "table_7", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(indexed, scanned) {
+			t.Errorf("limit %d: SearchByPhase and full scan returned different results", limit)
+		}
+	}
+}
+
+func TestStreamCrossPhaseKnowledgeAppliesSearch(t *testing.T) {
+	km := newMemoryKnowledgeManager(t)
+	addSyntheticChunks(t, km, "phase1", 20)
+	addSyntheticChunks(t, km, "phase2", 20)
+	addSyntheticChunks(t, km, "phase4", 20)
+
+	tests := []struct {
+		name   string
+		search KnowledgeSearch
+	}{
+		{"all indexed phases", KnowledgeSearch{Limit: 5}},
+		{"selected phases with offset", KnowledgeSearch{Phases: []string{"phase2", "phase4"}, Limit: 3, Offset: 4}},
+		{"min score", KnowledgeSearch{Limit: 50, MinScore: 0.2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := km.SearchKnowledge("table_7", tt.search)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var streamed []KnowledgeResult
+			err = km.StreamCrossPhaseKnowledge(context.Background(), "table_7", tt.search, func(result KnowledgeResult) error {
+				streamed = append(streamed, result)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 相同分數的結果順序可能不同，只比較分數
+			if len(streamed) != len(found.Results) {
+				t.Fatalf("streamed %d results, SearchKnowledge returned %d", len(streamed), len(found.Results))
+			}
+			for i := range streamed {
+				if streamed[i].Score != found.Results[i].Score {
+					t.Errorf("result %d score = %v, want %v", i, streamed[i].Score, found.Results[i].Score)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sort"
+)
+
+// scoredChunkHeap 以相似度排序的最大堆，用於逐一取出 top-K 結果
+type scoredChunkHeap []KnowledgeResult
+
+func (h scoredChunkHeap) Len() int            { return len(h) }
+func (h scoredChunkHeap) Less(i, j int) bool  { return h[i].Score > h[j].Score }
+func (h scoredChunkHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *scoredChunkHeap) Push(x interface{}) { *h = append(*h, x.(KnowledgeResult)) }
+func (h *scoredChunkHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// StreamCrossPhaseKnowledge 依 search 的 phase（預設為所有已索引的 phase）、min_score 與 offset 檢索知識，
+// 每選出一個 top 結果就立即呼叫 emit，最多 search.Limit 個；ctx 取消（例如客戶端斷線）或 emit 返回錯誤時停止
+func (km *KnowledgeManager) StreamCrossPhaseKnowledge(ctx context.Context, query string, search KnowledgeSearch, emit func(KnowledgeResult) error) error {
+	queryVector, err := km.embedder.GenerateEmbedding(query)
+	if err != nil {
+		return fmt.Errorf("failed to generate query embedding: %v", err)
+	}
+
+	allChunks, err := km.vectorStore.GetAllChunks()
+	if err != nil {
+		return fmt.Errorf("failed to get chunks: %v", err)
+	}
+
+	phases := search.Phases
+	if len(phases) == 0 {
+		phases = indexedPhases(allChunks)
+	}
+	targetPhases := make(map[string]bool, len(phases))
+	for _, phase := range phases {
+		targetPhases[phase] = true
+	}
+
+	scored := make(scoredChunkHeap, 0, len(allChunks))
+	for i, chunk := range allChunks {
+		if i%1000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if !targetPhases[chunkPhase(chunk)] {
+			continue
+		}
+		score := cosineSimilarity(queryVector, chunk.Vector)
+		if score < search.MinScore {
+			continue
+		}
+		scored = append(scored, KnowledgeResult{
+			Content:  chunk.Content,
+			Metadata: chunk.Metadata,
+			Score:    score,
+		})
+	}
+
+	// 建堆為 O(n)，每次取出為 O(log n)，第一個結果不需等待完整排序
+	heap.Init(&scored)
+	for skipped := 0; skipped < search.Offset && scored.Len() > 0; skipped++ {
+		heap.Pop(&scored)
+	}
+	for emitted := 0; emitted < search.Limit && scored.Len() > 0; emitted++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := emit(heap.Pop(&scored).(KnowledgeResult)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rankedResult 帶掃描順序的結果，相同分數時較早掃描到的排在前面
+type rankedResult struct {
+	result KnowledgeResult
+	seq    int
+}
+
+// minScoreHeap 以相似度排序的最小堆，堆頂為目前 top-K 中最差的結果
+type minScoreHeap []rankedResult
+
+func (h minScoreHeap) Len() int { return len(h) }
+func (h minScoreHeap) Less(i, j int) bool {
+	return rankedBefore(h[j], h[i])
+}
+func (h minScoreHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *minScoreHeap) Push(x interface{}) { *h = append(*h, x.(rankedResult)) }
+func (h *minScoreHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// rankedBefore a 是否應排在 b 之前（分數降序，相同分數依掃描順序）
+func rankedBefore(a, b rankedResult) bool {
+	if a.result.Score != b.result.Score {
+		return a.result.Score > b.result.Score
+	}
+	return a.seq < b.seq
+}
+
+// topKCollector 掃描時只保留前 limit 個結果，記憶體為 O(limit)、每次加入為 O(log limit)
+type topKCollector struct {
+	limit int
+	seq   int
+	items minScoreHeap
+}
+
+// maxTopKPrealloc 預先配置的最大容量；limit 來自請求參數，更大的 limit 在加入結果時才逐步擴充
+const maxTopKPrealloc = 1024
+
+// newTopKCollector 創建 top-K 收集器
+func newTopKCollector(limit int) *topKCollector {
+	if limit < 0 {
+		limit = 0
+	}
+	capacity := limit
+	if capacity > maxTopKPrealloc {
+		capacity = maxTopKPrealloc
+	}
+	return &topKCollector{limit: limit, items: make(minScoreHeap, 0, capacity)}
+}
+
+// add 加入一個結果，若比目前第 limit 名差則直接丟棄
+func (c *topKCollector) add(result KnowledgeResult) {
+	item := rankedResult{result: result, seq: c.seq}
+	c.seq++
+	if c.limit == 0 {
+		return
+	}
+	if c.items.Len() < c.limit {
+		heap.Push(&c.items, item)
+		return
+	}
+	if rankedBefore(item, c.items[0]) {
+		c.items[0] = item
+		heap.Fix(&c.items, 0)
+	}
+}
+
+// results 按相似度降序返回收集到的結果
+func (c *topKCollector) results() []KnowledgeResult {
+	ranked := append(minScoreHeap(nil), c.items...)
+	sort.Slice(ranked, func(i, j int) bool {
+		return rankedBefore(ranked[i], ranked[j])
+	})
+
+	results := make([]KnowledgeResult, 0, len(ranked))
+	for _, item := range ranked {
+		results = append(results, item.result)
+	}
+	return results
+}
+
+// chunkPhase 返回向量塊元數據中的 phase
+func chunkPhase(chunk VectorChunk) string {
+	phase, _ := chunk.Metadata["phase"].(string)
+	return phase
+}
+
+// searchChunksByPhase 在已載入的向量塊中掃描指定 phase，保留相似度最高的 limit 個
+func searchChunksByPhase(chunks []VectorChunk, phase string, queryVector []float64, limit int) []KnowledgeResult {
+	collector := newTopKCollector(limit)
+	for _, chunk := range chunks {
+		if chunkPhase(chunk) != phase {
+			continue
+		}
+		collector.add(KnowledgeResult{
+			Content:  chunk.Content,
+			Metadata: chunk.Metadata,
+			Score:    cosineSimilarity(queryVector, chunk.Vector),
+		})
+	}
+	return collector.results()
+}
@@ -7,11 +7,57 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/masato25/aika-dba/config"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// VectorStore 向量存儲結構
+// Store 向量存儲後端介面
+type Store interface {
+	AddChunk(content string, metadata map[string]interface{}, vector []float64) error
+	AddChunks(chunks []VectorChunk) error
+	SearchSimilar(queryVector []float64, limit int) ([]VectorChunk, error)
+	GetAllChunks() ([]VectorChunk, error)
+	Clear() error
+	DeleteByMetadata(key string, value interface{}) error
+	ReplaceChunks(key string, value interface{}, chunks []VectorChunk) error
+	Close() error
+}
+
+// PhaseSearcher 可在存儲層依 phase 過濾並返回 top-K 結果的後端（可選介面）
+type PhaseSearcher interface {
+	SearchByPhase(phase string, queryVector []float64, limit int) ([]KnowledgeResult, error)
+}
+
+// ChunkAppender 可不經內容雜湊去重、直接附加塊的後端（可選介面，供 append 保留策略使用）
+type ChunkAppender interface {
+	AppendChunks(chunks []VectorChunk) error
+}
+
+// HashLookup 可只查詢指定內容雜湊是否已存儲的後端（可選介面，供 upsert 保留策略使用）
+type HashLookup interface {
+	StoredHashes(hashes []string) (map[string]bool, error)
+}
+
+// NewStore 根據配置創建向量存儲後端（sqlite、pgvector、qdrant 或 memory）
+func NewStore(cfg *config.Config) (Store, error) {
+	switch cfg.VectorStore.Backend {
+	case "", "sqlite":
+		return NewVectorStore(cfg.VectorStore.DatabasePath)
+	case "pgvector":
+		return NewPGVectorStore(cfg)
+	case "qdrant":
+		return NewQdrantStore(cfg)
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown vectorstore.backend %q (supported: sqlite, pgvector, qdrant, memory)", cfg.VectorStore.Backend)
+	}
+}
+
+// VectorStore 向量存儲結構（SQLite 後端）
 type VectorStore struct {
 	db *sql.DB
 }
@@ -61,7 +107,22 @@ func initTables(db *sql.DB) error {
 	CREATE INDEX IF NOT EXISTS idx_content ON vector_chunks(content);
 	`
 
-	_, err := db.Exec(createTableSQL)
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return err
+	}
+
+	// 舊版資料庫沒有 content_hash 欄位，補上後既有的塊為 NULL，不受唯一索引限制
+	var hasHash int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('vector_chunks') WHERE name = 'content_hash'").Scan(&hasHash); err != nil {
+		return err
+	}
+	if hasHash == 0 {
+		if _, err := db.Exec("ALTER TABLE vector_chunks ADD COLUMN content_hash TEXT"); err != nil {
+			return fmt.Errorf("failed to add content_hash column: %v", err)
+		}
+	}
+
+	_, err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_content_hash ON vector_chunks(content_hash)")
 	return err
 }
 
@@ -73,24 +134,110 @@ func (vs *VectorStore) Close() error {
 	return nil
 }
 
-// AddChunk 添加向量塊
+// AddChunk 添加向量塊，已存在相同內容與元數據的塊時略過
 func (vs *VectorStore) AddChunk(content string, metadata map[string]interface{}, vector []float64) error {
-	vectorJSON, err := json.Marshal(vector)
+	return vs.AddChunks([]VectorChunk{{Content: content, Metadata: metadata, Vector: vector}})
+}
+
+// AddChunks 在單一交易中批次添加向量塊（忽略 ID 欄位），以內容雜湊略過已存在的塊
+func (vs *VectorStore) AddChunks(chunks []VectorChunk) error {
+	return vs.addChunks(chunks, true)
+}
+
+// AppendChunks 在單一交易中附加向量塊，不以內容雜湊去重（content_hash 為 NULL，不受唯一索引限制）
+func (vs *VectorStore) AppendChunks(chunks []VectorChunk) error {
+	return vs.addChunks(chunks, false)
+}
+
+// addChunks 在單一交易中寫入向量塊
+func (vs *VectorStore) addChunks(chunks []VectorChunk, dedup bool) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	tx, err := vs.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to marshal vector: %v", err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertChunksTx(tx, chunks, dedup); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hashLookupBatch 每次以 IN 查詢的內容雜湊數量上限（低於 SQLite 的參數數量限制）
+const hashLookupBatch = 500
+
+// StoredHashes 以 content_hash 索引查詢已存儲的內容雜湊，不讀取其他塊
+func (vs *VectorStore) StoredHashes(hashes []string) (map[string]bool, error) {
+	stored := make(map[string]bool)
+	for start := 0; start < len(hashes); start += hashLookupBatch {
+		batch := hashes[start:min(start+hashLookupBatch, len(hashes))]
+		args := make([]interface{}, len(batch))
+		for i, hash := range batch {
+			args[i] = hash
+		}
+
+		rows, err := vs.db.Query("SELECT content_hash FROM vector_chunks WHERE content_hash IN (?"+strings.Repeat(", ?", len(batch)-1)+")", args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up content hashes: %v", err)
+		}
+		for rows.Next() {
+			var hash string
+			if err := rows.Scan(&hash); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan content hash: %v", err)
+			}
+			stored[hash] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up content hashes: %v", err)
+		}
 	}
+	return stored, nil
+}
 
-	metadataJSON, err := json.Marshal(metadata)
+// insertChunksTx 在交易中寫入向量塊；dedup 時 content_hash 已存在的塊會被略過，否則不記錄 content_hash 直接附加
+func insertChunksTx(tx *sql.Tx, chunks []VectorChunk, dedup bool) error {
+	insertSQL := "INSERT INTO vector_chunks (content, metadata, vector, content_hash) VALUES (?, ?, ?, ?)"
+	if dedup {
+		insertSQL = "INSERT OR IGNORE INTO vector_chunks (content, metadata, vector, content_hash) VALUES (?, ?, ?, ?)"
+	}
+	stmt, err := tx.Prepare(insertSQL)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %v", err)
+		return fmt.Errorf("failed to prepare insert: %v", err)
 	}
+	defer stmt.Close()
 
-	_, err = vs.db.Exec(
-		"INSERT INTO vector_chunks (content, metadata, vector) VALUES (?, ?, ?)",
-		content, string(metadataJSON), string(vectorJSON),
-	)
+	var hashes []string
+	if dedup {
+		chunks, hashes = hashChunks(chunks)
+	}
+	for i, chunk := range chunks {
+		vectorJSON, err := json.Marshal(chunk.Vector)
+		if err != nil {
+			return fmt.Errorf("failed to marshal vector: %v", err)
+		}
 
-	return err
+		metadataJSON, err := json.Marshal(chunk.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %v", err)
+		}
+
+		var hash interface{}
+		if dedup {
+			hash = hashes[i]
+		}
+		if _, err := stmt.Exec(chunk.Content, string(metadataJSON), string(vectorJSON), hash); err != nil {
+			return fmt.Errorf("failed to insert chunk: %v", err)
+		}
+	}
+
+	return nil
 }
 
 // SearchSimilar 搜索相似向量
@@ -142,14 +289,10 @@ func (vs *VectorStore) SearchSimilar(queryVector []float64, limit int) ([]Vector
 		scored = append(scored, scoredChunk{chunk: chunk, score: score})
 	}
 
-	// 按相似度降序排序
-	for i := 0; i < len(scored)-1; i++ {
-		for j := i + 1; j < len(scored); j++ {
-			if scored[i].score < scored[j].score {
-				scored[i], scored[j] = scored[j], scored[i]
-			}
-		}
-	}
+	// 按相似度降序排序，相同分數保持寫入順序
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
 
 	// 返回前limit個結果
 	var results []VectorChunk
@@ -160,6 +303,45 @@ func (vs *VectorStore) SearchSimilar(queryVector []float64, limit int) ([]Vector
 	return results, nil
 }
 
+// SearchByPhase 搜索指定 phase 中最相似的 limit 個向量塊
+// phase 在 SQL 中過濾，掃描時只保留 top-K，不需載入與排序整個知識庫
+func (vs *VectorStore) SearchByPhase(phase string, queryVector []float64, limit int) ([]KnowledgeResult, error) {
+	rows, err := vs.db.Query("SELECT content, metadata, vector FROM vector_chunks WHERE json_extract(metadata, '$.phase') = ? ORDER BY id", phase)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collector := newTopKCollector(limit)
+	for rows.Next() {
+		var content, metadataStr, vectorStr string
+		if err := rows.Scan(&content, &metadataStr, &vectorStr); err != nil {
+			continue
+		}
+
+		var vector []float64
+		if err := json.Unmarshal([]byte(vectorStr), &vector); err != nil {
+			continue
+		}
+
+		var metadata map[string]interface{}
+		if metadataStr != "" {
+			json.Unmarshal([]byte(metadataStr), &metadata)
+		}
+
+		collector.add(KnowledgeResult{
+			Content:  content,
+			Metadata: metadata,
+			Score:    cosineSimilarity(queryVector, vector),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collector.results(), nil
+}
+
 // cosineSimilarity 計算餘弦相似度
 func cosineSimilarity(a, b []float64) float64 {
 	if len(a) != len(b) {
@@ -226,12 +408,42 @@ func (vs *VectorStore) Clear() error {
 
 // DeleteByMetadata 根據元數據刪除向量塊
 func (vs *VectorStore) DeleteByMetadata(key string, value interface{}) error {
+	tx, err := vs.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteByMetadataTx(tx, key, value); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceChunks 在單一交易中刪除元數據符合的塊並寫入新塊
+func (vs *VectorStore) ReplaceChunks(key string, value interface{}, chunks []VectorChunk) error {
+	tx, err := vs.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteByMetadataTx(tx, key, value); err != nil {
+		return err
+	}
+	if err := insertChunksTx(tx, chunks, true); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteByMetadataTx 在交易中刪除元數據符合的向量塊
+func deleteByMetadataTx(tx *sql.Tx, key string, value interface{}) error {
 	// 獲取所有塊，然後過濾並刪除
-	rows, err := vs.db.Query("SELECT id, metadata FROM vector_chunks")
+	rows, err := tx.Query("SELECT id, metadata FROM vector_chunks")
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 
 	var idsToDelete []int
 	for rows.Next() {
@@ -258,10 +470,11 @@ func (vs *VectorStore) DeleteByMetadata(key string, value interface{}) error {
 			}
 		}
 	}
+	rows.Close()
 
 	// 刪除匹配的記錄
 	for _, id := range idsToDelete {
-		_, err := vs.db.Exec("DELETE FROM vector_chunks WHERE id = ?", id)
+		_, err := tx.Exec("DELETE FROM vector_chunks WHERE id = ?", id)
 		if err != nil {
 			return fmt.Errorf("failed to delete chunk %d: %v", id, err)
 		}
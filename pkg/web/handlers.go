@@ -6,15 +6,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/masato25/aika-dba/config"
 	"github.com/masato25/aika-dba/pkg/analyzer"
+	"github.com/masato25/aika-dba/pkg/health"
 	"github.com/masato25/aika-dba/pkg/llm"
 	"github.com/masato25/aika-dba/pkg/phases"
 	"github.com/masato25/aika-dba/pkg/progress"
@@ -32,6 +38,12 @@ type APIServer struct {
 	vectorStore *vectorstore.KnowledgeManager
 	progressMgr *progress.ProgressManager
 	analyzer    *analyzer.DatabaseAnalyzer
+	marketing   *phases.MarketingQueryRunner
+	overview    *analyzer.OverviewCache
+
+	// phaseLocks 記錄執行中的 phase，避免手動觸發與排程刷新重疊
+	phaseLocksMu sync.Mutex
+	phaseLocks   map[string]bool
 }
 
 // NewAPIServer 創建 API 服務器
@@ -46,7 +58,7 @@ func NewAPIServer(db *sql.DB, dbType string, cfg *config.Config) (*APIServer, er
 	}
 
 	// 創建數據庫分析器
-	dbAnalyzer := analyzer.NewDatabaseAnalyzer(db)
+	dbAnalyzer := analyzer.NewDatabaseAnalyzerWithSchemas(db, dbType, cfg.GetDatabaseSchemas()).WithCollapsePartitions(cfg.Schema.CollapsePartitions)
 
 	// 創建 Gin 引擎
 	router := gin.Default()
@@ -60,6 +72,9 @@ func NewAPIServer(db *sql.DB, dbType string, cfg *config.Config) (*APIServer, er
 		vectorStore: vectorStore,
 		progressMgr: progress.NewProgressManager(),
 		analyzer:    dbAnalyzer,
+		marketing:   phases.NewMarketingQueryRunner(cfg, db),
+		overview:    analyzer.NewOverviewCache(dbAnalyzer, cfg.GetOverviewCacheTTL(), cfg.Schema.TableAllowed),
+		phaseLocks:  make(map[string]bool),
 	}
 
 	server.setupRoutes()
@@ -79,18 +94,28 @@ func (s *APIServer) setupRoutes() {
 	{
 		// 健康檢查
 		api.GET("/health", s.handleHealth)
+		api.GET("/health/deep", s.handleDeepHealth)
 
 		// Phase 相關 API
 		api.POST("/phases/trigger/:phase", s.handleTriggerPhase)
 		api.GET("/phases/status", s.handlePhaseStatus)
+		api.GET("/phases/progress/overall", s.handleOverallProgress)
 		api.GET("/phases/progress/:phase", s.handlePhaseProgress)
 		api.GET("/phases/progress", s.handleAllProgress)
+		api.GET("/phases/stream", s.handlePhaseStream)
+		api.GET("/phases/:phase/plan", s.handlePhasePlan)
+		api.GET("/phases/diff", s.handlePhaseDiff)
 		api.GET("/phases/logs/:phase", s.handlePhaseLogs)
+		api.POST("/phases/phase2/corrections/:table", s.handlePhase2Correction)
 
 		// 向量數據庫 API
 		api.GET("/vector/stats", s.handleVectorStats)
+		api.POST("/vector/reindex", s.handleVectorReindex)
 		api.GET("/vector/search", s.handleVectorSearch)
+		api.GET("/vector/search/stream", s.handleVectorSearchStream)
 		api.GET("/vector/knowledge/:phase", s.handleVectorKnowledge)
+		api.GET("/vector/gaps", s.handleVectorGaps)
+		api.GET("/vector/tables", s.handleVectorTables)
 
 		// 進度推送 WebSocket
 		api.GET("/ws/progress", s.handleProgressWebsocket)
@@ -98,16 +123,82 @@ func (s *APIServer) setupRoutes() {
 		// 知識文件瀏覽
 		api.GET("/knowledge/files", s.handleKnowledgeFiles)
 		api.GET("/knowledge/files/:name", s.handleKnowledgeFile)
+		api.DELETE("/knowledge/files/:name", s.handleDeleteKnowledgeFile)
+		api.GET("/knowledge/history/:phase", s.handleKnowledgeHistory)
+		api.GET("/knowledge/history/:phase/:timestamp", s.handleKnowledgeHistoryVersion)
 
 		// 資料庫總覽
 		api.GET("/database/overview", s.handleDatabaseOverview)
+
+		// 營銷查詢
+		api.POST("/marketing/query", s.handleMarketingQuery)
+		api.POST("/marketing/download", s.handleMarketingDownload)
+
+		// 查詢提示診斷（不呼叫 LLM）
+		api.GET("/query/debug", s.handleQueryDebug)
+		api.POST("/query/debug", s.handleQueryDebug)
+
+		// Lua 規則驗證
+		api.GET("/rules/validate", s.handleValidateRules)
+
+		// 整合報告
+		api.GET("/report", s.handleReport)
+		api.GET("/report/markdown", s.handleReportMarkdown)
 	}
 }
 
-// Start 啟動服務器
+// Start 啟動服務器，收到中斷信號時停止排程刷新並優雅關閉
 func (s *APIServer) Start(port int) error {
-	addr := fmt.Sprintf(":%d", port)
-	return s.router.Run(addr)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s.startSchemaRefresh(ctx)
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: s.router,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		log.Println("Shutting down server...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// tryLockPhases 嘗試同時鎖定多個 phase，任一 phase 正在執行時不鎖定並返回 false
+func (s *APIServer) tryLockPhases(phaseNames ...string) bool {
+	s.phaseLocksMu.Lock()
+	defer s.phaseLocksMu.Unlock()
+
+	for _, phase := range phaseNames {
+		if s.phaseLocks[phase] {
+			return false
+		}
+	}
+	for _, phase := range phaseNames {
+		s.phaseLocks[phase] = true
+	}
+	return true
+}
+
+// unlockPhases 釋放 phase 鎖
+func (s *APIServer) unlockPhases(phaseNames ...string) {
+	s.phaseLocksMu.Lock()
+	defer s.phaseLocksMu.Unlock()
+
+	for _, phase := range phaseNames {
+		delete(s.phaseLocks, phase)
+	}
 }
 
 // handleIndex 處理首頁請求
@@ -126,46 +217,81 @@ func (s *APIServer) handleIndex(c *gin.Context) {
 // handleHealth 健康檢查
 func (s *APIServer) handleHealth(c *gin.Context) {
 	response := map[string]interface{}{
-		"status": "healthy",
-		"time":   time.Now(),
+		"status":    "healthy",
+		"time":      time.Now(),
+		"safe_mode": s.config.App.SafeMode,
 	}
 	c.JSON(200, response)
 }
 
-// handleDatabaseOverview 資料庫總覽
+// handleDeepHealth 深度健康檢查：實際連線資料庫、LLM 與嵌入生成器，返回各元件狀態與延遲
+// 資料庫無法連線時返回 503，其他元件失敗時狀態為 degraded
+func (s *APIServer) handleDeepHealth(c *gin.Context) {
+	report := health.Check(c.Request.Context(), s.db, s.llmClient, s.vectorStore)
+	code := 200
+	if report.Status == health.StatusUnhealthy {
+		code = 503
+	}
+	c.JSON(code, report)
+}
+
+// handleDatabaseOverview 資料庫總覽：表格數、欄位數、估計總行數、外鍵關係數與最大的 10 個表格（依 app.overview_cache_ttl 快取）
 func (s *APIServer) handleDatabaseOverview(c *gin.Context) {
-	response := map[string]interface{}{
-		"message": "Database analysis functionality is not yet implemented",
-		"status":  "coming_soon",
-		"time":    time.Now(),
+	overview, cached, err := s.overview.Get(c.Request.Context())
+	if err != nil {
+		c.JSON(500, map[string]string{"error": fmt.Sprintf("Failed to build database overview: %v", err)})
+		return
 	}
-	c.JSON(200, response)
+
+	c.JSON(200, map[string]interface{}{
+		"overview":     overview,
+		"generated_at": overview.GeneratedAt,
+		"cached":       cached,
+	})
 }
 
 // handleTriggerPhase 處理觸發 phase 的請求
 func (s *APIServer) handleTriggerPhase(c *gin.Context) {
 	phase := c.Param("phase")
 
-	// 檢查是否已經在運行
-	if progress, exists := s.progressMgr.GetProgress(phase); exists && progress.Status == "running" {
+	// 檢查是否已經在運行（包含排程的 schema 刷新）
+	if !s.tryLockPhases(phase) {
 		c.JSON(409, map[string]string{"error": "Phase " + phase + " is already running"})
 		return
 	}
 
+	// phase2_prefix 可透過 ?question_types=enum_check,unused_column_check 覆寫分析重點
+	var questionTypes []string
+	if qt := c.Query("question_types"); qt != "" {
+		questionTypes = strings.Split(qt, ",")
+	}
+
+	// phase1 可透過 ?incremental=true 只重新分析 schema 或行數有變化的表格
+	incremental := c.Query("incremental") == "true"
+
+	// phase2 可透過 ?resume=true 從檢查點繼續，略過上次中斷前已完成的表格
+	resume := c.Query("resume") == "true"
+
 	// 根據 phase 執行相應的操作
 	go func() {
+		defer s.unlockPhases(phase)
+
 		var err error
 		switch phase {
 		case "phase1":
-			err = s.runPhase1()
+			if incremental {
+				err = s.runPhase1Incremental()
+			} else {
+				err = s.runPhase1()
+			}
 		case "phase1_post":
 			err = s.runPhase1Post()
 		case "phase1_put":
 			err = s.runPhase1Put()
 		case "phase2_prefix":
-			err = s.runPhase2Prefix()
+			err = s.runPhase2Prefix(questionTypes)
 		case "phase2":
-			err = s.runPhase2()
+			err = s.runPhase2(resume)
 		case "phase3":
 			err = s.runPhase3()
 		default:
@@ -185,12 +311,22 @@ func (s *APIServer) handleTriggerPhase(c *gin.Context) {
 
 // handlePhaseStatus 處理獲取 phase 狀態的請求
 func (s *APIServer) handlePhaseStatus(c *gin.Context) {
-	// 這裡可以實現更複雜的狀態追蹤邏輯
-	// 目前返回簡單的狀態
+	// 附上各 phase 最近一次執行的 LLM 狀態，任何 phase 使用了後備結果時 llm_fallback_used 為 true
+	llmStatus := phases.LastLLMStatus(s.config)
+	fallbackUsed := false
+	for _, phaseStatus := range llmStatus {
+		if phaseStatus.UsedFallback {
+			fallbackUsed = true
+			break
+		}
+	}
+
 	status := map[string]interface{}{
-		"status":  "ready",
-		"message": "System is ready to execute phases",
-		"time":    time.Now(),
+		"status":            "ready",
+		"message":           "System is ready to execute phases",
+		"time":              time.Now(),
+		"llm_status":        llmStatus,
+		"llm_fallback_used": fallbackUsed,
 	}
 	c.JSON(200, status)
 }
@@ -214,6 +350,29 @@ func (s *APIServer) handleAllProgress(c *gin.Context) {
 	c.JSON(200, allProgress)
 }
 
+// handleOverallProgress 處理獲取整個準備流程加權整體進度的請求
+func (s *APIServer) handleOverallProgress(c *gin.Context) {
+	c.JSON(200, s.progressMgr.GetOverallProgress(s.pipelinePhases(), s.config.App.ProgressWeights))
+}
+
+// pipelinePhases 返回整體進度涵蓋的 phase：預設流程加上權重設定中額外列出的 phase
+func (s *APIServer) pipelinePhases() []string {
+	phases := append([]string{}, progress.DefaultPipelinePhases...)
+	known := make(map[string]bool, len(phases))
+	for _, phase := range phases {
+		known[phase] = true
+	}
+
+	var extra []string
+	for phase := range s.config.App.ProgressWeights {
+		if !known[phase] {
+			extra = append(extra, phase)
+		}
+	}
+	sort.Strings(extra)
+	return append(phases, extra...)
+}
+
 // handlePhaseLogs 處理獲取指定 phase 日誌的請求
 func (s *APIServer) handlePhaseLogs(c *gin.Context) {
 	phase := c.Param("phase")
@@ -238,7 +397,134 @@ func (s *APIServer) handleVectorStats(c *gin.Context) {
 	c.JSON(200, stats)
 }
 
-// handleVectorSearch 處理向量搜索請求
+// handleVectorReindex 以目前的分塊與嵌入設定，從知識 JSON 檔重建指定 phase 的向量知識，無需重新執行 phase
+// ?phases= 為逗號分隔的 phase 列表，留空時重建所有存在知識檔的 phase；返回重建前後的塊數
+func (s *APIServer) handleVectorReindex(c *gin.Context) {
+	var phaseNames []string
+	if phasesParam := c.Query("phases"); phasesParam != "" {
+		for _, phase := range strings.Split(phasesParam, ",") {
+			phase = strings.TrimSpace(phase)
+			if phase == "" {
+				continue
+			}
+			if _, ok := vectorstore.PhaseKnowledgeFiles[phase]; !ok {
+				c.JSON(400, map[string]string{"error": fmt.Sprintf("Unknown phase %q", phase)})
+				return
+			}
+			phaseNames = append(phaseNames, phase)
+		}
+	} else {
+		for phase, name := range vectorstore.PhaseKnowledgeFiles {
+			if _, err := os.Stat(s.config.KnowledgePath(name)); err == nil {
+				phaseNames = append(phaseNames, phase)
+			}
+		}
+		sort.Strings(phaseNames)
+	}
+	if len(phaseNames) == 0 {
+		c.JSON(400, map[string]string{"error": "No phase knowledge files to reindex"})
+		return
+	}
+
+	// 與 phase 執行共用鎖，避免重建時 phase 同時寫入知識
+	if !s.tryLockPhases(phaseNames...) {
+		c.JSON(409, map[string]string{"error": "One of the requested phases is running"})
+		return
+	}
+	defer s.unlockPhases(phaseNames...)
+
+	before, err := s.vectorStore.GetKnowledgeStats()
+	if err != nil {
+		c.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	results := make([]map[string]interface{}, 0, len(phaseNames))
+	failed := 0
+	for _, phase := range phaseNames {
+		result := map[string]interface{}{"phase": phase}
+		count, err := s.vectorStore.RechunkPhase(phase)
+		if err != nil {
+			log.Printf("Warning: Failed to reindex phase %s: %v", phase, err)
+			result["error"] = err.Error()
+			failed++
+		} else {
+			result["chunks"] = count
+		}
+		results = append(results, result)
+	}
+
+	after, err := s.vectorStore.GetKnowledgeStats()
+	if err != nil {
+		c.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, map[string]interface{}{
+		"results": results,
+		"failed":  failed,
+		"before":  reindexChunkCounts(before),
+		"after":   reindexChunkCounts(after),
+	})
+}
+
+// reindexChunkCounts 從 GetKnowledgeStats 取出總塊數與各 phase 塊數
+func reindexChunkCounts(stats map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"total_chunks": stats["total_chunks"],
+		"phases":       stats["phases"],
+	}
+}
+
+// GET /api/vector/search 的預設與最大結果數，以及最大 offset
+const (
+	defaultVectorSearchLimit = 5
+	maxVectorSearchLimit     = 50
+	maxVectorSearchOffset    = 1000
+)
+
+// parseVectorSearch 解析向量搜索共用的查詢參數：phases（逗號分隔，預設為所有已索引的 phase）、
+// limit（預設 5，最多 50）、offset（最多 1000）與 min_score
+func parseVectorSearch(c *gin.Context) (vectorstore.KnowledgeSearch, error) {
+	search := vectorstore.KnowledgeSearch{Limit: defaultVectorSearchLimit}
+	if phasesParam := c.Query("phases"); phasesParam != "" {
+		for _, phase := range strings.Split(phasesParam, ",") {
+			if phase = strings.TrimSpace(phase); phase != "" {
+				search.Phases = append(search.Phases, phase)
+			}
+		}
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			return search, fmt.Errorf("Query parameter 'limit' must be a positive integer")
+		}
+		search.Limit = parsed
+	}
+	if search.Limit > maxVectorSearchLimit {
+		search.Limit = maxVectorSearchLimit
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		parsed, err := strconv.Atoi(offsetStr)
+		if err != nil || parsed < 0 {
+			return search, fmt.Errorf("Query parameter 'offset' must be a non-negative integer")
+		}
+		search.Offset = parsed
+	}
+	if search.Offset > maxVectorSearchOffset {
+		search.Offset = maxVectorSearchOffset
+	}
+	if minScoreStr := c.Query("min_score"); minScoreStr != "" {
+		parsed, err := strconv.ParseFloat(minScoreStr, 64)
+		if err != nil {
+			return search, fmt.Errorf("Query parameter 'min_score' must be a number")
+		}
+		search.MinScore = parsed
+	}
+	return search, nil
+}
+
+// handleVectorSearch 處理向量搜索請求（查詢參數見 parseVectorSearch）
 func (s *APIServer) handleVectorSearch(c *gin.Context) {
 	query := c.Query("q")
 	if query == "" {
@@ -246,16 +532,21 @@ func (s *APIServer) handleVectorSearch(c *gin.Context) {
 		return
 	}
 
-	// 搜索所有 phase 的知識
-	results, err := s.vectorStore.RetrieveCrossPhaseKnowledge(query, []string{"phase1", "phase2", "phase3"}, 5)
+	search, err := parseVectorSearch(c)
+	if err != nil {
+		c.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	found, err := s.vectorStore.SearchKnowledge(query, search)
 	if err != nil {
 		c.JSON(500, map[string]string{"error": err.Error()})
 		return
 	}
 
 	// 格式化結果
-	formattedResults := make([]map[string]interface{}, len(results))
-	for i, result := range results {
+	formattedResults := make([]map[string]interface{}, len(found.Results))
+	for i, result := range found.Results {
 		formattedResults[i] = map[string]interface{}{
 			"content":  result.Content,
 			"metadata": result.Metadata,
@@ -263,7 +554,110 @@ func (s *APIServer) handleVectorSearch(c *gin.Context) {
 		}
 	}
 
-	c.JSON(200, formattedResults)
+	c.JSON(200, map[string]interface{}{
+		"results": formattedResults,
+		"total":   found.Total,
+		"phases":  found.Phases,
+		"limit":   search.Limit,
+		"offset":  search.Offset,
+	})
+}
+
+// handleVectorSearchStream 以 SSE 逐一推送向量搜索結果，客戶端斷線時停止搜索
+// 查詢參數與 GET /api/vector/search 相同（見 parseVectorSearch）
+func (s *APIServer) handleVectorSearchStream(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(400, map[string]string{"error": "Query parameter 'q' is required"})
+		return
+	}
+
+	search, err := parseVectorSearch(c)
+	if err != nil {
+		c.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	count := 0
+	err = s.vectorStore.StreamCrossPhaseKnowledge(c.Request.Context(), query, search,
+		func(result vectorstore.KnowledgeResult) error {
+			count++
+			c.SSEvent("result", map[string]interface{}{
+				"rank":     search.Offset + count,
+				"content":  result.Content,
+				"metadata": result.Metadata,
+				"score":    result.Score,
+			})
+			c.Writer.Flush()
+			return nil
+		})
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			log.Printf("Vector search stream cancelled by client after %d results", count)
+			return
+		}
+		c.SSEvent("error", map[string]string{"error": err.Error()})
+		c.Writer.Flush()
+		return
+	}
+
+	c.SSEvent("done", map[string]interface{}{"count": count})
+	c.Writer.Flush()
+}
+
+// handleVectorGaps 處理獲取知識覆蓋缺口報告的請求
+func (s *APIServer) handleVectorGaps(c *gin.Context) {
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			c.JSON(400, map[string]string{"error": "Query parameter 'limit' must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	allGaps, err := phases.LoadCoverageGaps(phases.CoverageGapsPath(s.config), 0)
+	if err != nil {
+		c.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	recent := allGaps
+	if len(recent) > limit {
+		recent = recent[:limit]
+	}
+
+	c.JSON(200, map[string]interface{}{
+		"total_gaps":  len(allGaps),
+		"recent_gaps": recent,
+		"by_query":    phases.SummarizeCoverageGaps(allGaps),
+	})
+}
+
+// handleVectorTables 處理列出知識庫中已索引表格的請求（依 phase 分組）
+func (s *APIServer) handleVectorTables(c *gin.Context) {
+	tables, err := s.vectorStore.ListIndexedTables()
+	if err != nil {
+		c.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	totalTables := make(map[string]bool)
+	for _, phaseTables := range tables {
+		for _, table := range phaseTables {
+			totalTables[table.Table] = true
+		}
+	}
+
+	c.JSON(200, map[string]interface{}{
+		"total_tables": len(totalTables),
+		"phases":       tables,
+	})
 }
 
 // handleVectorKnowledge 處理獲取指定 phase 知識的請求
@@ -292,7 +686,7 @@ func (s *APIServer) handleVectorKnowledge(c *gin.Context) {
 
 // handleKnowledgeFiles 列出知識文件
 func (s *APIServer) handleKnowledgeFiles(c *gin.Context) {
-	const knowledgeDir = "knowledge"
+	knowledgeDir := s.config.KnowledgeDir()
 
 	entries, err := os.ReadDir(knowledgeDir)
 	if err != nil {
@@ -338,34 +732,8 @@ func (s *APIServer) handleKnowledgeFiles(c *gin.Context) {
 
 // handleKnowledgeFile 讀取特定知識文件內容
 func (s *APIServer) handleKnowledgeFile(c *gin.Context) {
-	name := c.Param("name")
-	if name == "" {
-		c.JSON(400, map[string]string{"error": "File name is required"})
-		return
-	}
-
-	name = filepath.Base(name)
-	if strings.Contains(name, "..") || strings.ContainsAny(name, `/\`) {
-		c.JSON(400, map[string]string{"error": "Invalid file name"})
-		return
-	}
-
-	knowledgeDir := "knowledge"
-	baseDir, err := filepath.Abs(knowledgeDir)
-	if err != nil {
-		c.JSON(500, map[string]string{"error": "Failed to resolve knowledge directory"})
-		return
-	}
-
-	filePath := filepath.Join(knowledgeDir, name)
-	absPath, err := filepath.Abs(filePath)
-	if err != nil {
-		c.JSON(500, map[string]string{"error": "Failed to resolve file path"})
-		return
-	}
-
-	if !strings.HasPrefix(absPath, baseDir) {
-		c.JSON(400, map[string]string{"error": "Invalid file path"})
+	name, absPath, ok := s.resolveKnowledgeFile(c)
+	if !ok {
 		return
 	}
 
@@ -389,6 +757,12 @@ func (s *APIServer) handleKnowledgeFile(c *gin.Context) {
 		response["mod_time"] = info.ModTime()
 	}
 
+	setKnowledgeFileContent(response, name, data)
+	c.JSON(200, response)
+}
+
+// setKnowledgeFileContent 將檔案內容放入回應：.json 檔解析為 JSON，其餘或解析失敗時以文字返回
+func setKnowledgeFileContent(response map[string]interface{}, name string, data []byte) {
 	var parsed interface{}
 	if strings.HasSuffix(strings.ToLower(name), ".json") {
 		if err := json.Unmarshal(data, &parsed); err == nil {
@@ -403,10 +777,318 @@ func (s *APIServer) handleKnowledgeFile(c *gin.Context) {
 		response["type"] = "text"
 		response["content"] = string(data)
 	}
+}
+
+// handleKnowledgeHistory 列出 phase 輸出的歷史版本（需啟用 knowledge.keep_history），最新的在前
+func (s *APIServer) handleKnowledgeHistory(c *gin.Context) {
+	phase := c.Param("phase")
+	versions, err := phases.ListKnowledgeHistory(s.config, phase)
+	if err != nil {
+		c.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, map[string]interface{}{
+		"phase":        phase,
+		"keep_history": s.config.Knowledge.KeepHistory,
+		"versions":     versions,
+	})
+}
+
+// handleKnowledgeHistoryVersion 讀取 phase 輸出的單一歷史版本
+func (s *APIServer) handleKnowledgeHistoryVersion(c *gin.Context) {
+	phase, timestamp := c.Param("phase"), c.Param("timestamp")
+	path, err := phases.KnowledgeHistoryPath(s.config, phase, timestamp)
+	if err != nil {
+		c.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(404, map[string]string{"error": fmt.Sprintf("No %s version at %s", phase, timestamp)})
+			return
+		}
+		c.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	name := filepath.Base(path)
+	response := map[string]interface{}{
+		"phase":      phase,
+		"timestamp":  timestamp,
+		"name":       name,
+		"size_bytes": len(data),
+	}
+	if info, err := os.Stat(path); err == nil {
+		response["mod_time"] = info.ModTime()
+	}
+	setKnowledgeFileContent(response, name, data)
 
 	c.JSON(200, response)
 }
 
+// protectedKnowledgeFiles 各 phase 的主要輸出，刪除時需要 ?force=true
+var protectedKnowledgeFiles = map[string]bool{
+	"phase1_analysis.json":   true,
+	"phase2_analysis.json":   true,
+	"phase3_analysis.json":   true,
+	"phase4_dimensions.json": true,
+}
+
+// handleDeleteKnowledgeFile 刪除知識目錄中的單一檔案，phase 主要輸出需加上 ?force=true
+func (s *APIServer) handleDeleteKnowledgeFile(c *gin.Context) {
+	name, absPath, ok := s.resolveKnowledgeFile(c)
+	if !ok {
+		return
+	}
+
+	if protectedKnowledgeFiles[name] && c.Query("force") != "true" {
+		c.JSON(409, map[string]string{"error": fmt.Sprintf("%s is a phase output; add ?force=true to delete it", name)})
+		return
+	}
+
+	if err := os.Remove(absPath); err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(404, map[string]string{"error": "File not found"})
+			return
+		}
+		c.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Deleted knowledge file %s", name)
+	c.JSON(200, map[string]string{"message": "File deleted", "name": name})
+}
+
+// resolveKnowledgeFile 驗證路徑參數中的檔名並返回其在知識目錄中的絕對路徑，驗證失敗時已寫入錯誤回應
+func (s *APIServer) resolveKnowledgeFile(c *gin.Context) (string, string, bool) {
+	return s.resolveKnowledgeFileName(c, c.Param("name"))
+}
+
+// resolveKnowledgeFileName 驗證檔名只指向知識目錄中的檔案並返回其絕對路徑，驗證失敗時已寫入錯誤回應
+func (s *APIServer) resolveKnowledgeFileName(c *gin.Context, name string) (string, string, bool) {
+	if name == "" {
+		c.JSON(400, map[string]string{"error": "File name is required"})
+		return "", "", false
+	}
+
+	name = filepath.Base(name)
+	if strings.Contains(name, "..") || strings.ContainsAny(name, `/\`) {
+		c.JSON(400, map[string]string{"error": "Invalid file name"})
+		return "", "", false
+	}
+
+	knowledgeDir := s.config.KnowledgeDir()
+	baseDir, err := filepath.Abs(knowledgeDir)
+	if err != nil {
+		c.JSON(500, map[string]string{"error": "Failed to resolve knowledge directory"})
+		return "", "", false
+	}
+
+	filePath := filepath.Join(knowledgeDir, name)
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		c.JSON(500, map[string]string{"error": "Failed to resolve file path"})
+		return "", "", false
+	}
+
+	if !strings.HasPrefix(absPath, baseDir) {
+		c.JSON(400, map[string]string{"error": "Invalid file path"})
+		return "", "", false
+	}
+
+	return name, absPath, true
+}
+
+// handlePhaseDiff 比較知識目錄中兩份 Phase 1 分析的 schema 漂移
+// from 為較舊的分析檔名（如 phase1_analysis_2024-01-01.json），to 預設為目前的 phase1_analysis.json
+func (s *APIServer) handlePhaseDiff(c *gin.Context) {
+	if c.Query("from") == "" {
+		c.JSON(400, map[string]string{"error": "from parameter is required"})
+		return
+	}
+	_, fromPath, ok := s.resolveKnowledgeFileName(c, c.Query("from"))
+	if !ok {
+		return
+	}
+	_, toPath, ok := s.resolveKnowledgeFileName(c, c.DefaultQuery("to", "phase1_analysis.json"))
+	if !ok {
+		return
+	}
+
+	for _, path := range []string{fromPath, toPath} {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			c.JSON(404, map[string]string{"error": fmt.Sprintf("File not found: %s", filepath.Base(path))})
+			return
+		}
+	}
+
+	report, err := phases.SchemaDiff(s.config, fromPath, toPath)
+	if err != nil {
+		c.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+	report.From = filepath.Base(fromPath)
+	report.To = filepath.Base(toPath)
+
+	c.JSON(200, report)
+}
+
+// handlePhase2Correction 提交 Phase 2 表格分析的人工修正
+func (s *APIServer) handlePhase2Correction(c *gin.Context) {
+	var correction phases.TableCorrection
+	if err := c.ShouldBindJSON(&correction); err != nil {
+		c.JSON(400, map[string]string{"error": fmt.Sprintf("Invalid correction: %v", err)})
+		return
+	}
+	correction.TableName = c.Param("table")
+
+	if err := phases.SubmitTableCorrection(s.config, s.vectorStore, &correction); err != nil {
+		c.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, correction)
+}
+
+// handleMarketingQuery 處理營銷查詢請求，結果行數受 result_limit 限制；回應即 MarketingQueryResult
+// "execute": false 時只返回生成的 SQL 與安全檢查警告，不查詢資料庫
+func (s *APIServer) handleMarketingQuery(c *gin.Context) {
+	var req struct {
+		Query   string `json:"query"`
+		Mode    string `json:"mode"`    // auto（預設，依 A:/Q:/P: 前綴）、analysis、raw 或 preview
+		Execute *bool  `json:"execute"` // false 時只生成並檢查 SQL，不執行（等同 preview 模式）
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Query) == "" {
+		c.JSON(400, map[string]string{"error": "Field 'query' is required"})
+		return
+	}
+	if req.Execute != nil && !*req.Execute {
+		req.Mode = phases.MarketingModePreview
+	}
+	if _, _, err := phases.ResolveMarketingMode(req.Mode, req.Query); err != nil {
+		c.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	result, err := s.marketing.ExecuteMarketingQueryWithMode(req.Query, req.Mode)
+	if err != nil {
+		c.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+// handleQueryDebug 返回營銷查詢將送給 LLM 的完整提示與檢索到的知識，不實際呼叫 LLM
+// GET 使用 ?q=，POST 使用 {"query": "..."}
+func (s *APIServer) handleQueryDebug(c *gin.Context) {
+	query := c.Query("q")
+	if c.Request.Method == "POST" {
+		var req struct {
+			Query string `json:"query"`
+		}
+		if err := c.ShouldBindJSON(&req); err == nil {
+			query = req.Query
+		}
+	}
+	if strings.TrimSpace(query) == "" {
+		c.JSON(400, map[string]string{"error": "Query parameter 'q' or field 'query' is required"})
+		return
+	}
+
+	debug, err := s.marketing.DebugPrompt(query)
+	if err != nil {
+		c.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, debug)
+}
+
+// handlePhasePlan 預覽 phase 將處理的範圍（表格數、LLM 呼叫次數），不實際執行
+func (s *APIServer) handlePhasePlan(c *gin.Context) {
+	plan, err := phases.PlanPhase(s.config, s.analyzer, s.vectorStore, c.Param("phase"))
+	if err != nil {
+		c.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+	c.JSON(200, plan)
+}
+
+// handleValidateRules 載入並驗證 Phase 4 的 Lua 規則，規則無效時返回 422
+func (s *APIServer) handleValidateRules(c *gin.Context) {
+	result := phases.ValidatePhase4Rules(s.config, s.vectorStore)
+	if !result.Valid {
+		c.JSON(422, result)
+		return
+	}
+	c.JSON(200, result)
+}
+
+// maxDownloadRequestBytes 下載請求本文的大小上限
+const maxDownloadRequestBytes = 64 << 10
+
+// handleMarketingDownload 重新執行營銷查詢返回的 SQL（POST {"sql": "..."}），以 CSV 串流下載結果；
+// 行數受 marketing.download_row_limit 限制，是否截斷以 X-Result-Truncated trailer 標示
+func (s *APIServer) handleMarketingDownload(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDownloadRequestBytes)
+	var req struct {
+		SQL string `json:"sql"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.SQL) == "" {
+		c.JSON(400, map[string]string{"error": "Field 'sql' is required"})
+		return
+	}
+
+	filename := fmt.Sprintf("marketing_result_%s.csv", time.Now().Format("20060102_150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Trailer", "X-Result-Truncated")
+
+	rows, truncated, err := s.marketing.ExportResultsCSV(c.Request.Context(), c.Writer, req.SQL)
+	if err != nil {
+		if c.Writer.Written() {
+			log.Printf("Warning: Failed to write CSV download: %v", err)
+			return
+		}
+		// 尚未輸出任何內容（查詢被拒絕或執行失敗），改以 JSON 返回錯誤
+		for _, header := range []string{"Content-Type", "Content-Disposition", "Trailer"} {
+			c.Writer.Header().Del(header)
+		}
+		c.JSON(400, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.Writer.Header().Set("X-Result-Truncated", strconv.FormatBool(truncated))
+	log.Printf("Marketing download wrote %d rows (truncated: %v)", rows, truncated)
+}
+
+// handleReport 產生整合報告並寫入 knowledge/report.html
+func (s *APIServer) handleReport(c *gin.Context) {
+	content, err := phases.NewReportGenerator(s.config.KnowledgeDir()).WriteReport(phases.ReportPath(s.config))
+	if err != nil {
+		c.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.Data(200, "text/html; charset=utf-8", content)
+}
+
+// handleReportMarkdown 產生商業邏輯分析 Markdown 並以檔案下載
+func (s *APIServer) handleReportMarkdown(c *gin.Context) {
+	content, err := phases.NewReportGenerator(s.config.KnowledgeDir()).WriteMarkdown(phases.MarkdownPath(s.config))
+	if err != nil {
+		c.JSON(500, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="analysis.md"`)
+	c.Data(200, "text/markdown; charset=utf-8", content)
+}
+
 // handleProgressWebsocket 推送進度更新的 WebSocket
 func (s *APIServer) handleProgressWebsocket(c *gin.Context) {
 	handler := websocket.Handler(func(ws *websocket.Conn) {
@@ -430,6 +1112,66 @@ func (s *APIServer) handleProgressWebsocket(c *gin.Context) {
 	handler.ServeHTTP(c.Writer, c.Request)
 }
 
+// progressStreamKeepAlive 進度串流沒有事件時送出註解行的間隔，避免代理伺服器切斷閒置連線
+const progressStreamKeepAlive = 30 * time.Second
+
+// handlePhaseStream 以 Server-Sent Events 推送 phase 進度，取代輪詢 /api/phases/progress/:phase
+// 連線時先以 progress 事件補送所有 phase 的目前狀態（含日誌），之後即時推送 progress（不含日誌）與 log（單一新增條目）事件
+// 指定 ?phase= 時只推送該 phase，並在其完成或失敗後送出 done 事件並關閉連線
+func (s *APIServer) handlePhaseStream(c *gin.Context) {
+	phase := c.Query("phase")
+
+	updates, unsubscribe := s.progressMgr.Subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(progressStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			c.Writer.WriteString(": keep-alive\n\n")
+			c.Writer.Flush()
+		case event, ok := <-updates:
+			if !ok {
+				return
+			}
+			if phase != "" && event.Phase != phase {
+				continue
+			}
+
+			switch {
+			case event.Log != nil && !event.Snapshot:
+				c.SSEvent("log", map[string]interface{}{"phase": event.Phase, "log": event.Log})
+			case event.Progress == nil:
+				// ResetProgress：phase 回到閒置
+				c.SSEvent("progress", &progress.PhaseProgress{Phase: event.Phase, Status: progress.StatusIdle})
+			default:
+				update := *event.Progress
+				if !event.Snapshot {
+					update.Logs = nil
+				}
+				c.SSEvent("progress", update)
+			}
+			c.Writer.Flush()
+
+			if phase != "" && event.Progress != nil &&
+				(event.Progress.Status == progress.StatusCompleted || event.Progress.Status == progress.StatusFailed) {
+				c.SSEvent("done", map[string]interface{}{"phase": phase, "status": event.Progress.Status})
+				c.Writer.Flush()
+				return
+			}
+		}
+	}
+}
+
 // writeOutput 寫入輸出到文件
 func (s *APIServer) writeOutput(data interface{}, filename string) error {
 	jsonData, err := json.MarshalIndent(data, "", "  ")
@@ -437,6 +1179,7 @@ func (s *APIServer) writeOutput(data interface{}, filename string) error {
 		return err
 	}
 
+	phases.ArchiveKnowledgeFile(s.config, filename)
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -460,7 +1203,9 @@ func RunServer(db *sql.DB, cfg *config.Config) {
 	}
 
 	// 啟動服務器
-	log.Fatal(server.Start(cfg.App.Port))
+	if err := server.Start(cfg.App.Port); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
 
 // runPhase1 執行 Phase 1: 統計分析
@@ -476,9 +1221,24 @@ func (s *APIServer) runPhase1() error {
 	s.progressMgr.UpdateProgress(phase, 0, "Collecting table metadata")
 	logger.Info("Collecting database tables for analysis")
 
-	tables, err := s.analyzer.GetAllTables()
-	if err != nil {
-		return err
+	var tables []string
+	var err error
+	tableAnalyses := make(map[string]interface{})
+	timedOutTables := []map[string]interface{}{}
+
+	// 離線模式：從 schema 匯出檔解析結構
+	if s.config.Schema.DumpFile != "" {
+		logger.Info(fmt.Sprintf("Reading schema from dump file %s", s.config.Schema.DumpFile))
+		tables, tableAnalyses, err = phases.AnalyzeSchemaDump(s.config.Schema)
+		if err != nil {
+			return err
+		}
+	} else {
+		tables, err = s.analyzer.GetAllTables()
+		if err != nil {
+			return err
+		}
+		tables = s.config.Schema.FilterTables(tables)
 	}
 
 	totalTables := len(tables)
@@ -487,36 +1247,64 @@ func (s *APIServer) runPhase1() error {
 
 	logger.Info(fmt.Sprintf("Starting Phase 1: Statistical Analysis - Found %d tables", totalTables))
 
-	// 分析每個表格
-	tableAnalyses := make(map[string]interface{})
-	for i, tableName := range tables {
-		logger.Info(fmt.Sprintf("Analyzing table %d/%d: %s", i+1, totalTables, tableName))
-
-		// 使用分析器的 AnalyzeTable 方法
-		analysis, err := s.analyzer.AnalyzeTable(tableName, s.config.Schema.MaxSamples)
-		if err != nil {
-			logger.Warn(fmt.Sprintf("Failed to analyze table %s: %v", tableName, err))
-			continue
-		}
+	// 分析每個表格（離線模式下已由匯出檔解析完成）
+	if s.config.Schema.DumpFile != "" {
+		s.progressMgr.UpdateProgress(phase, totalTables, fmt.Sprintf("Parsed %d tables from schema dump", totalTables))
+	} else {
+		for i, tableName := range tables {
+			logger.Info(fmt.Sprintf("Analyzing table %d/%d: %s", i+1, totalTables, tableName))
+
+			// 使用分析器的 AnalyzeTable 方法，每個表格有獨立的逾時限制
+			analysis, err := phases.AnalyzeTableWithTimeout(s.analyzer, tableName, phases.SamplingPolicyFromConfig(s.config.Schema), s.config.Schema.TableTimeoutSeconds)
+			if timedOut := phases.TimedOutTableEntry(tableName, analysis, err); timedOut != nil {
+				logger.Warn(fmt.Sprintf("Table %s timed out: %v", tableName, timedOut["reason"]))
+				timedOutTables = append(timedOutTables, timedOut)
+			}
+			if err != nil {
+				logger.Warn(fmt.Sprintf("Failed to analyze table %s: %v", tableName, err))
+				continue
+			}
 
-		tableAnalyses[tableName] = analysis
+			tableAnalyses[tableName] = analysis
 
-		// 更新進度
-		s.progressMgr.UpdateProgress(phase, i+1, fmt.Sprintf("Analyzed table: %s", tableName))
-		logger.Debug(fmt.Sprintf("Completed analysis of table: %s", tableName))
+			// 更新進度
+			s.progressMgr.UpdateProgress(phase, i+1, fmt.Sprintf("Analyzed table: %s", tableName))
+			logger.Debug(fmt.Sprintf("Completed analysis of table: %s", tableName))
+		}
 	}
 
 	// 創建輸出
 	output := map[string]interface{}{
-		"database":      s.config.Database.DBName,
-		"database_type": s.config.Database.Type,
-		"timestamp":     time.Now(),
-		"tables_count":  len(tables),
-		"tables":        tableAnalyses,
+		"schema_version":   phases.Phase1SchemaVersion,
+		"database":         s.config.Database.DBName,
+		"database_type":    s.config.Database.Type,
+		"timestamp":        time.Now(),
+		"tables_count":     len(tables),
+		"tables":           tableAnalyses,
+		"timed_out_tables": timedOutTables,
+		"schema_language":  phases.DetectSchemaLanguage(tableAnalyses),
+	}
+
+	// 合併模式：只更新本次分析的表格，保留其他表格
+	if s.config.Schema.MergeOutput {
+		existing, err := phases.LoadPhase1Output(s.config.KnowledgePath("phase1_analysis.json"))
+		if err != nil {
+			logger.Warn(fmt.Sprintf("Failed to load existing phase1 output for merge, writing fresh output: %v", err))
+		} else {
+			output = phases.MergeTableAnalyses(existing, output)
+		}
+	}
+	phases.DropFilteredTables(s.config.Schema, output)
+
+	// 記錄沒有主鍵的表格，供維度建模參考
+	missing := phases.TablesWithoutPrimaryKey(output["tables"].(map[string]interface{}))
+	output["tables_without_primary_key"] = missing
+	if len(missing) > 0 {
+		logger.Warn(fmt.Sprintf("%d tables have no primary key: %v", len(missing), missing))
 	}
 
 	// 寫入文件
-	if err := s.writeOutput(output, "knowledge/phase1_analysis.json"); err != nil {
+	if err := s.writeOutput(output, s.config.KnowledgePath("phase1_analysis.json")); err != nil {
 		return err
 	}
 
@@ -532,6 +1320,29 @@ func (s *APIServer) runPhase1() error {
 	return nil
 }
 
+// runPhase1Incremental 增量執行 Phase 1：只重新分析 schema 或行數有變化的表格，其餘表格保留原有分析
+func (s *APIServer) runPhase1Incremental() error {
+	phase := "phase1"
+	s.progressMgr.StartPhase(phase, 1)
+	s.progressMgr.UpdateProgress(phase, 0, "Comparing tables with previous analysis")
+
+	runner, err := phases.NewPhase1Runner(s.analyzer, s.config)
+	if err != nil {
+		return fmt.Errorf("failed to create Phase 1 runner: %w", err)
+	}
+	defer runner.Close()
+
+	diff, err := runner.RunIncremental()
+	if err != nil {
+		return fmt.Errorf("incremental Phase 1 failed: %w", err)
+	}
+
+	s.progressMgr.AddLog(phase, "info", fmt.Sprintf("Incremental analysis: %d added, %d removed, %d changed, %d row count changed tables",
+		len(diff.AddedTables), len(diff.RemovedTables), len(diff.ChangedTables), len(diff.RowCountChanges)))
+	s.progressMgr.UpdateProgress(phase, 1, fmt.Sprintf("Re-analyzed %d tables", len(diff.TablesToReanalyze())))
+	return nil
+}
+
 // runPhase1Post 執行 Phase 1 後置處理
 func (s *APIServer) runPhase1Post() error {
 	phase := "phase1_post"
@@ -545,7 +1356,7 @@ func (s *APIServer) runPhase1Post() error {
 	s.progressMgr.AddLog(phase, "info", "Starting Phase 1 post-processing workflow")
 	logger.Info("Starting Phase 1 Post-Processing")
 
-	responseFile := "knowledge/phase1_post_responses.json"
+	responseFile := s.config.KnowledgePath("phase1_post_responses.json")
 	if _, err := os.Stat(responseFile); err == nil {
 		s.progressMgr.UpdateProgress(phase, 2, "Applying user responses to post-processing workflow")
 		s.progressMgr.AddLog(phase, "info", "Found phase1_post_responses.json, applying decisions")
@@ -586,14 +1397,14 @@ func (s *APIServer) runPhase1Put() error {
 	logger.Info("Starting Phase 1 Put: updating Phase 1 analysis with post decisions")
 
 	requiredFiles := []string{
-		"knowledge/phase1_analysis.json",
-		"knowledge/phase1_post_analysis.json",
+		s.config.KnowledgePath("phase1_analysis.json"),
+		s.config.KnowledgePath("phase1_post_analysis.json"),
 	}
 
 	for _, file := range requiredFiles {
 		if _, err := os.Stat(file); err != nil {
 			if os.IsNotExist(err) {
-				if file == "knowledge/phase1_post_analysis.json" {
+				if file == s.config.KnowledgePath("phase1_post_analysis.json") {
 					// 自動觸發 phase1_post 以生成所需的分析文件
 					autoMsg := "Required phase1_post_analysis.json missing, auto-running Phase 1 Post"
 					s.progressMgr.AddLog(phase, "warn", autoMsg)
@@ -640,7 +1451,7 @@ func (s *APIServer) runPhase1Put() error {
 }
 
 // runPhase2Prefix 執行 Phase 2 前置處理: 欄位深度分析
-func (s *APIServer) runPhase2Prefix() error {
+func (s *APIServer) runPhase2Prefix(questionTypes []string) error {
 	phase := "phase2_prefix"
 	debugEnabled := strings.ToLower(s.config.Logging.Level) == "debug"
 
@@ -657,6 +1468,10 @@ func (s *APIServer) runPhase2Prefix() error {
 	if err != nil {
 		return fmt.Errorf("failed to create Phase 2 prefix runner: %w", err)
 	}
+	if len(questionTypes) > 0 {
+		runner.SetQuestionTypes(questionTypes)
+		logger.Info(fmt.Sprintf("Question types limited to: %s", strings.Join(questionTypes, ", ")))
+	}
 	logger.Info("Phase 2 prefix runner created successfully")
 
 	s.progressMgr.UpdateProgress(phase, 1, "Phase 2 prefix runner created")
@@ -672,35 +1487,47 @@ func (s *APIServer) runPhase2Prefix() error {
 	return nil
 }
 
-// runPhase2 執行 Phase 2: AI 分析
-func (s *APIServer) runPhase2() error {
+// runPhase2 執行 Phase 2: AI 分析，resume 時從檢查點繼續
+func (s *APIServer) runPhase2(resume bool) error {
 	phase := "phase2"
 	debugEnabled := strings.ToLower(s.config.Logging.Level) == "debug"
 
 	// 創建 phase 日誌器
 	logger := progress.NewPhaseLogger(phase, s.progressMgr, debugEnabled)
 
-	// 開始進度追蹤 - 估計步驟數
-	s.progressMgr.StartPhase(phase, 10) // 估計 10 個步驟
+	// 開始進度追蹤，總步驟數在任務初始化後改為實際表格數
+	s.progressMgr.StartPhase(phase, 1)
 
 	logger.Info("Starting Phase 2: AI Business Logic Analysis")
 
-	runner, err := phases.NewPhase2Runner(s.config, s.db)
+	runner, err := phases.NewPhase2Runner(s.config, s.db, resume)
 	if err != nil {
 		return fmt.Errorf("failed to create Phase 2 runner: %w", err)
 	}
+	runner.SetProgressFunc(s.phaseProgressFunc(phase))
 
-	s.progressMgr.UpdateProgress(phase, 1, "Phase 2 runner created")
+	s.progressMgr.UpdateProgress(phase, 0, "Phase 2 runner created")
 
 	if err := runner.Run(); err != nil {
 		return fmt.Errorf("Phase 2 failed: %w", err)
 	}
 
-	s.progressMgr.UpdateProgress(phase, 10, "Phase 2 completed")
 	logger.Info("Phase 2 completed successfully")
 	return nil
 }
 
+// phaseProgressFunc 將執行器回報的實際完成數／總數轉成 progressMgr 的進度更新
+func (s *APIServer) phaseProgressFunc(phase string) phases.ProgressFunc {
+	lastTotal := -1
+	return func(completed, total int, message string) {
+		if total != lastTotal {
+			s.progressMgr.SetTotalSteps(phase, total)
+			lastTotal = total
+		}
+		s.progressMgr.UpdateProgress(phase, completed, message)
+	}
+}
+
 // runPhase3 執行 Phase 3: 商業邏輯描述生成
 func (s *APIServer) runPhase3() error {
 	phase := "phase3"
@@ -709,16 +1536,14 @@ func (s *APIServer) runPhase3() error {
 	// 創建 phase 日誌器
 	logger := progress.NewPhaseLogger(phase, s.progressMgr, debugEnabled)
 
-	// 開始進度追蹤 - 估計步驟數
-	s.progressMgr.StartPhase(phase, 10) // 估計 10 個步驟
+	// 開始進度追蹤，總步驟數由 Phase 3 執行器回報
+	s.progressMgr.StartPhase(phase, 1)
 
 	logger.Info("Starting Phase 3: Business Logic Description Generation")
 
 	// 創建 LLM 客戶端
 	llmClient := llm.NewClient(s.config)
 
-	s.progressMgr.UpdateProgress(phase, 1, "LLM client created")
-
 	// 創建知識管理器
 	knowledgeMgr, err := vectorstore.NewKnowledgeManager(s.config)
 	if err != nil {
@@ -726,18 +1551,16 @@ func (s *APIServer) runPhase3() error {
 	}
 	defer knowledgeMgr.Close()
 
-	s.progressMgr.UpdateProgress(phase, 2, "Knowledge manager created")
-
 	// 創建 Phase 3 執行器
 	runner := phases.NewPhase3Runner(s.config, llmClient, knowledgeMgr)
+	runner.SetProgressFunc(s.phaseProgressFunc(phase))
 
-	s.progressMgr.UpdateProgress(phase, 3, "Phase 3 runner created")
+	s.progressMgr.UpdateProgress(phase, 0, "Phase 3 runner created")
 
 	if err := runner.Run(context.Background()); err != nil {
 		return fmt.Errorf("Phase 3 failed: %w", err)
 	}
 
-	s.progressMgr.UpdateProgress(phase, 10, "Phase 3 completed")
 	logger.Info("Phase 3 completed successfully")
 	return nil
 }
@@ -769,13 +1592,29 @@ func runMarketingQuery(db *sql.DB, cfg *config.Config, query string) {
 
 	fmt.Printf("SQL Query: %s\n", result.SQLQuery)
 	fmt.Printf("Explanation: %s\n", result.Explanation)
-	fmt.Printf("Results: %d rows\n", len(result.Results))
+	// 顯示行數限制
+	displayLimit := cfg.Marketing.DisplayLimit
+	if displayLimit <= 0 {
+		displayLimit = 5
+	}
+	shown := len(result.Results)
+	if shown > displayLimit {
+		shown = displayLimit
+	}
+	if result.Truncated && result.TotalRows == 0 {
+		// 總行數無法取得
+		fmt.Printf("Results: more than %d rows (showing %d)\n", len(result.Results), shown)
+	} else {
+		fmt.Printf("Results: %d rows (showing %d)\n", result.TotalRows, shown)
+	}
+	if result.Truncated {
+		fmt.Printf("Note: only the first %d rows were returned. Use POST /api/marketing/download to fetch the full result.\n", len(result.Results))
+	}
 
 	if len(result.Results) > 0 {
 		fmt.Println("\nSample Results:")
-		// 顯示前 5 行結果
 		for i, row := range result.Results {
-			if i >= 5 {
+			if i >= displayLimit {
 				break
 			}
 			fmt.Printf("Row %d: ", i+1)
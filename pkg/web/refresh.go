@@ -0,0 +1,101 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/masato25/aika-dba/pkg/phases"
+)
+
+// schemaRefreshPhase 排程刷新在進度管理器中使用的名稱
+const schemaRefreshPhase = "schema_refresh"
+
+// startSchemaRefresh 依 app.refresh_interval 啟動背景 schema 刷新，ctx 取消時停止
+func (s *APIServer) startSchemaRefresh(ctx context.Context) {
+	if s.config.App.RefreshInterval == "" {
+		return
+	}
+
+	interval, err := time.ParseDuration(s.config.App.RefreshInterval)
+	if err != nil {
+		log.Printf("Warning: Invalid app.refresh_interval %q, schema refresh disabled: %v", s.config.App.RefreshInterval, err)
+		return
+	}
+	if interval <= 0 {
+		return
+	}
+
+	log.Printf("Schema refresh scheduled every %s", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("Schema refresh scheduler stopped")
+				return
+			case <-ticker.C:
+				s.refreshSchema()
+			}
+		}
+	}()
+}
+
+// refreshSchema 增量重新執行 Phase 1，偵測到 schema 變化時只重新分析變更的表格
+// 與手動觸發共用 phase 鎖，phase1 或 phase2 執行中時跳過本次刷新
+func (s *APIServer) refreshSchema() {
+	if !s.tryLockPhases("phase1", "phase2") {
+		log.Println("Schema refresh skipped: phase1 or phase2 is already running")
+		return
+	}
+	defer s.unlockPhases("phase1", "phase2")
+
+	s.progressMgr.StartPhase(schemaRefreshPhase, 2)
+	if err := s.runSchemaRefresh(); err != nil {
+		log.Printf("Warning: Schema refresh failed: %v", err)
+		s.progressMgr.FailPhase(schemaRefreshPhase, err)
+		return
+	}
+	s.progressMgr.CompletePhase(schemaRefreshPhase)
+}
+
+// runSchemaRefresh 執行一次增量刷新
+func (s *APIServer) runSchemaRefresh() error {
+	s.progressMgr.UpdateProgress(schemaRefreshPhase, 0, "Checking schema for changes")
+
+	runner, err := phases.NewPhase1Runner(s.analyzer, s.config)
+	if err != nil {
+		return fmt.Errorf("failed to create Phase 1 runner: %w", err)
+	}
+	defer runner.Close()
+
+	diff, err := runner.RunIncremental()
+	if err != nil {
+		return fmt.Errorf("incremental Phase 1 failed: %w", err)
+	}
+	// 只有行數變化的表格已由 Phase 1 更新樣本，不需重新執行 LLM 分析
+	if !diff.HasSchemaChanges() {
+		s.progressMgr.UpdateProgress(schemaRefreshPhase, 2, "No schema changes detected")
+		return nil
+	}
+
+	s.progressMgr.AddLog(schemaRefreshPhase, "info", fmt.Sprintf("Schema changed: %d added, %d removed, %d changed tables",
+		len(diff.AddedTables), len(diff.RemovedTables), len(diff.ChangedTables)))
+	s.progressMgr.UpdateProgress(schemaRefreshPhase, 1, "Re-analyzing changed tables")
+
+	phase2Runner, err := phases.NewPhase2Runner(s.config, s.db, false)
+	if err != nil {
+		return fmt.Errorf("failed to create Phase 2 runner: %w", err)
+	}
+	defer phase2Runner.Close()
+
+	if err := phase2Runner.RunTables(diff.SchemaChangedTables(), diff.RemovedTables); err != nil {
+		return fmt.Errorf("Phase 2 re-analysis failed: %w", err)
+	}
+
+	s.progressMgr.UpdateProgress(schemaRefreshPhase, 2, "Changed tables re-analyzed")
+	return nil
+}
@@ -43,7 +43,7 @@ func main() {
 
 	// Phase 2: AI 業務邏輯分析
 	fmt.Println("\n--- Phase 2: AI Business Logic Analysis ---")
-	phase2Runner, err := phases.NewPhase2Runner(cfg, db)
+	phase2Runner, err := phases.NewPhase2Runner(cfg, db, false)
 	if err != nil {
 		log.Fatalf("Failed to create phase2 runner: %v", err)
 	}
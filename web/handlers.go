@@ -12,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/masato25/aika-dba/config"
 	"github.com/masato25/aika-dba/pkg/analyzer"
+	"github.com/masato25/aika-dba/pkg/health"
 	"github.com/masato25/aika-dba/pkg/llm"
 	"github.com/masato25/aika-dba/pkg/phases"
 	"github.com/masato25/aika-dba/pkg/vectorstore"
@@ -25,6 +26,7 @@ type APIServer struct {
 	config      *config.Config
 	llmClient   *llm.Client
 	vectorStore *vectorstore.KnowledgeManager
+	overview    *analyzer.OverviewCache
 }
 
 // NewAPIServer 創建 API 服務器
@@ -48,6 +50,8 @@ func NewAPIServer(db *sql.DB, dbType string, cfg *config.Config) (*APIServer, er
 		config:      cfg,
 		llmClient:   llmClient,
 		vectorStore: vectorStore,
+		overview: analyzer.NewOverviewCache(analyzer.NewDatabaseAnalyzerWithSchemas(db, dbType, cfg.GetDatabaseSchemas()),
+			cfg.GetOverviewCacheTTL(), cfg.Schema.TableAllowed),
 	}
 
 	server.setupRoutes()
@@ -67,6 +71,7 @@ func (s *APIServer) setupRoutes() {
 	{
 		// 健康檢查
 		api.GET("/health", s.handleHealth)
+		api.GET("/health/deep", s.handleDeepHealth)
 
 		// Phase 相關 API
 		api.POST("/phases/trigger/:phase", s.handleTriggerPhase)
@@ -110,14 +115,30 @@ func (s *APIServer) handleHealth(c *gin.Context) {
 	c.JSON(200, response)
 }
 
-// handleDatabaseOverview 資料庫總覽
+// handleDeepHealth 深度健康檢查：實際連線資料庫、LLM 與嵌入生成器，返回各元件狀態與延遲
+// 資料庫無法連線時返回 503，其他元件失敗時狀態為 degraded
+func (s *APIServer) handleDeepHealth(c *gin.Context) {
+	report := health.Check(c.Request.Context(), s.db, s.llmClient, s.vectorStore)
+	code := 200
+	if report.Status == health.StatusUnhealthy {
+		code = 503
+	}
+	c.JSON(code, report)
+}
+
+// handleDatabaseOverview 資料庫總覽（依 app.overview_cache_ttl 快取）
 func (s *APIServer) handleDatabaseOverview(c *gin.Context) {
-	response := map[string]interface{}{
-		"message": "Database analysis functionality is not yet implemented",
-		"status":  "coming_soon",
-		"time":    time.Now(),
+	overview, cached, err := s.overview.Get(c.Request.Context())
+	if err != nil {
+		c.JSON(500, map[string]string{"error": fmt.Sprintf("Failed to build database overview: %v", err)})
+		return
 	}
-	c.JSON(200, response)
+
+	c.JSON(200, map[string]interface{}{
+		"overview":     overview,
+		"generated_at": overview.GeneratedAt,
+		"cached":       cached,
+	})
 }
 
 // handleTriggerPhase 處理觸發 phase 的請求
@@ -273,7 +294,7 @@ func runPhase1Put(cfg *config.Config) {
 
 // runPhase2 執行 Phase 2: AI 分析
 func runPhase2(db *sql.DB, cfg *config.Config) {
-	runner, err := phases.NewPhase2Runner(cfg, db)
+	runner, err := phases.NewPhase2Runner(cfg, db, false)
 	if err != nil {
 		log.Fatalf("Failed to create Phase 2 runner: %v", err)
 	}